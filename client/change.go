@@ -167,6 +167,8 @@ func (c ChangeSelector) String() string {
 		return "ready"
 	case ChangesAll:
 		return "all"
+	case ChangesArchived:
+		return "archived"
 	}
 
 	panic(fmt.Sprintf("unknown ChangeSelector %d", c))
@@ -178,6 +180,10 @@ const (
 	ChangesAll = ChangesReady | ChangesInProgress
 )
 
+// ChangesArchived selects the changes which were pruned from the state and
+// kept in the changes archive.
+const ChangesArchived ChangeSelector = 1 << 2
+
 type ChangesOptions struct {
 	SnapName string // if empty, no filtering by name is done
 	Selector ChangeSelector
@@ -135,6 +135,7 @@ func (cs *clientSuite) TestClientChangesString(c *check.C) {
 		client.ChangesAll:        "all",
 		client.ChangesReady:      "ready",
 		client.ChangesInProgress: "in-progress",
+		client.ChangesArchived:   "archived",
 	} {
 		c.Check(k.String(), check.Equals, v)
 	}
@@ -154,6 +155,7 @@ func (cs *clientSuite) TestClientChanges(c *check.C) {
 		{Selector: client.ChangesAll},
 		{Selector: client.ChangesReady},
 		{Selector: client.ChangesInProgress},
+		{Selector: client.ChangesArchived},
 		{SnapName: "foo"},
 		nil,
 	} {
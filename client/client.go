@@ -38,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/snap"
 )
 
 func unixDialer(socketPath string) func(string, string) (net.Conn, error) {
@@ -672,6 +673,29 @@ type RefreshInfo struct {
 	Last     string `json:"last,omitempty"`
 	Hold     string `json:"hold,omitempty"`
 	Next     string `json:"next,omitempty"`
+	// Rollout contains the status of the refresh rollout policy, if any.
+	Rollout *RolloutInfo `json:"rollout,omitempty"`
+}
+
+// RolloutInfo contains the status of the refresh rollout policy.
+type RolloutInfo struct {
+	// Bucket is the bucket of the device, from 0 to 99.
+	Bucket int `json:"bucket"`
+	// Soak is the refresh.rollout.soak setting.
+	Soak string `json:"soak,omitempty"`
+	// Delay is how long new revisions soak for the bucket of the device.
+	Delay string `json:"delay,omitempty"`
+	// Held lists the unhealthy snaps holding auto-refreshes.
+	Held    []string         `json:"held,omitempty"`
+	Pending []RolloutPending `json:"pending,omitempty"`
+}
+
+// RolloutPending is a revision of a snap soaking before being
+// auto-refreshed to.
+type RolloutPending struct {
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	Due      string        `json:"due"`
 }
 
 // SysInfo holds system information
@@ -694,6 +718,29 @@ type SysInfo struct {
 	Features map[string]features.FeatureInfo `json:"features,omitempty"`
 
 	SnapdBinFrom string `json:"snapd-bin-from"`
+
+	// MaintenanceWindows contains the status of the maintenance windows
+	// policy, if any.
+	MaintenanceWindows *MaintenanceWindowsInfo `json:"maintenance-windows,omitempty"`
+}
+
+// MaintenanceWindowsInfo contains the status of the maintenance windows
+// governing when changes requiring a reboot may run.
+type MaintenanceWindowsInfo struct {
+	// Windows is the maintenance.windows setting.
+	Windows string `json:"windows,omitempty"`
+	// Next is when the next maintenance window opens, it is empty while
+	// a window is open.
+	Next string `json:"next,omitempty"`
+	// PendingReboot is set if a system reboot is deferred until the next
+	// maintenance window.
+	PendingReboot *PendingRebootInfo `json:"pending-reboot,omitempty"`
+}
+
+// PendingRebootInfo describes a system reboot deferred until the next
+// maintenance window.
+type PendingRebootInfo struct {
+	RequestedAt string `json:"requested-at"`
 }
 
 func (rsp *response) err(cli *Client, statusCode int) error {
@@ -23,27 +23,87 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"time"
 )
 
-func (c *Client) ConfdbGetViaView(viewID string, requests []string, constraints map[string]any) (changeID string, err error) {
+func confdbQuery(requests []string, constraints map[string]any) (url.Values, error) {
 	query := url.Values{}
 	query.Add("keys", strings.Join(requests, ","))
 
 	if len(constraints) > 0 {
 		data, err := json.Marshal(constraints)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		query.Add("constraints", string(data))
 	}
+	return query, nil
+}
+
+func (c *Client) ConfdbGetViaView(viewID string, requests []string, constraints map[string]any) (changeID string, err error) {
+	query, err := confdbQuery(requests, constraints)
+	if err != nil {
+		return "", err
+	}
 
 	endpoint := fmt.Sprintf("/v2/confdb/%s", viewID)
 	return c.doAsync("GET", endpoint, query, nil, nil)
 }
 
+// ConfdbRevision holds the values visible through a view in a revision of
+// the confdb's history.
+type ConfdbRevision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// View is the view through which the confdb was changed.
+	View string `json:"view,omitempty"`
+	// Snap is the snap that changed the confdb, if any.
+	Snap string `json:"snap,omitempty"`
+	// UserID is the uid of the user that requested the change, if known.
+	UserID *int `json:"user-id,omitempty"`
+	// RollbackOf is the revision restored by this revision, if any.
+	RollbackOf int `json:"rollback-of,omitempty"`
+	Values     any `json:"values,omitempty"`
+}
+
+// ConfdbHistoryViaView returns the confdb's history of changes, as seen
+// through the view, from oldest to newest.
+func (c *Client) ConfdbHistoryViaView(viewID string, requests []string, constraints map[string]any) ([]*ConfdbRevision, error) {
+	query, err := confdbQuery(requests, constraints)
+	if err != nil {
+		return nil, err
+	}
+	query.Set("history", "true")
+
+	var revs []*ConfdbRevision
+	endpoint := fmt.Sprintf("/v2/confdb/%s", viewID)
+	if _, err := c.doSync("GET", endpoint, query, nil, nil, &revs); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// ConfdbRollbackViaView restores the confdb to a revision of its history,
+// running the hooks of the view's custodians.
+func (c *Client) ConfdbRollbackViaView(viewID string, revision int) (changeID string, err error) {
+	body := map[string]any{
+		"action":   "rollback",
+		"revision": revision,
+	}
+	bodyRaw, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	endpoint := fmt.Sprintf("/v2/confdb/%s", viewID)
+	return c.doAsync("PUT", endpoint, nil, headers, bytes.NewReader(bodyRaw))
+}
+
 func (c *Client) ConfdbSetViaView(viewID string, requestValues map[string]any) (changeID string, err error) {
 	type setBody struct {
 		Values map[string]any `json:"values"`
@@ -61,3 +121,23 @@ func (c *Client) ConfdbSetViaView(viewID string, requestValues map[string]any) (
 	endpoint := fmt.Sprintf("/v2/confdb/%s", viewID)
 	return c.doAsync("PUT", endpoint, nil, headers, bytes.NewReader(bodyRaw))
 }
+
+// ConfdbExport returns the whole databag of the confdb schema, including
+// values that no view exposes.
+func (c *Client) ConfdbExport(schemaID string) (json.RawMessage, error) {
+	var databag json.RawMessage
+	endpoint := fmt.Sprintf("/v2/confdb/%s", schemaID)
+	if _, err := c.doSync("GET", endpoint, nil, nil, nil, &databag); err != nil {
+		return nil, err
+	}
+	return databag, nil
+}
+
+// ConfdbImport replaces the databag of the confdb schema with the JSON object
+// read from data. The data is validated against the confdb schema.
+func (c *Client) ConfdbImport(schemaID string, data io.Reader) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	endpoint := fmt.Sprintf("/v2/confdb/%s", schemaID)
+	_, err := c.doSync("PUT", endpoint, nil, headers, data, nil)
+	return err
+}
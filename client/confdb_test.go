@@ -23,6 +23,8 @@ import (
 	"encoding/json"
 	"io"
 	"net/url"
+	"strings"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -88,3 +90,93 @@ func (cs *clientSuite) TestConfdbSet(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(res, DeepEquals, map[string]any{"values": map[string]any{"foo": "bar", "baz": float64(1)}})
 }
+
+func (cs *clientSuite) TestConfdbHistory(c *C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [
+			{"revision": 1, "time": "2026-10-01T12:00:00Z", "view": "a/b/c", "values": {"foo": "bar"}},
+			{"revision": 2, "time": "2026-10-01T13:00:00Z", "snap": "some-snap", "user-id": 1000, "rollback-of": 1}
+		]
+	}`
+
+	revs, err := cs.cli.ConfdbHistoryViaView("a/b/c", []string{"foo"}, map[string]any{"bar": "baz"})
+	c.Assert(err, IsNil)
+	c.Check(cs.reqs[0].Method, Equals, "GET")
+	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/confdb/a/b/c")
+	c.Check(cs.reqs[0].URL.Query(), DeepEquals, url.Values{
+		"keys":        []string{"foo"},
+		"constraints": []string{`{"bar":"baz"}`},
+		"history":     []string{"true"},
+	})
+
+	c.Assert(revs, HasLen, 2)
+	c.Check(revs[0].Revision, Equals, 1)
+	c.Check(revs[0].Time.Equal(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+	c.Check(revs[0].View, Equals, "a/b/c")
+	c.Check(revs[0].UserID, IsNil)
+	c.Check(revs[0].Values, DeepEquals, map[string]any{"foo": "bar"})
+	c.Check(revs[1].Revision, Equals, 2)
+	c.Check(revs[1].Snap, Equals, "some-snap")
+	c.Assert(revs[1].UserID, NotNil)
+	c.Check(*revs[1].UserID, Equals, 1000)
+	c.Check(revs[1].RollbackOf, Equals, 1)
+	c.Check(revs[1].Values, IsNil)
+}
+
+func (cs *clientSuite) TestConfdbRollback(c *C) {
+	cs.status = 202
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "123"}`
+
+	chgID, err := cs.cli.ConfdbRollbackViaView("a/b/c", 3)
+	c.Assert(err, IsNil)
+	c.Check(chgID, Equals, "123")
+	c.Assert(cs.reqs, HasLen, 1)
+	c.Check(cs.reqs[0].Method, Equals, "PUT")
+	c.Check(cs.reqs[0].Header.Get("Content-Type"), Equals, "application/json")
+	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/confdb/a/b/c")
+	data, err := io.ReadAll(cs.reqs[0].Body)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"action":"rollback","revision":3}`)
+}
+
+func (cs *clientSuite) TestConfdbExport(c *C) {
+	cs.status = 200
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"wifi": {"ssid": "foo", "retries": 12345678901234567890}}
+	}`
+
+	databag, err := cs.cli.ConfdbExport("a/b")
+	c.Assert(err, IsNil)
+	c.Assert(cs.reqs, HasLen, 1)
+	c.Check(cs.reqs[0].Method, Equals, "GET")
+	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/confdb/a/b")
+	// the values are kept as they were sent
+	c.Check(string(databag), Equals, `{"wifi": {"ssid": "foo", "retries": 12345678901234567890}}`)
+}
+
+func (cs *clientSuite) TestConfdbImport(c *C) {
+	cs.status = 200
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": null}`
+
+	err := cs.cli.ConfdbImport("a/b", strings.NewReader(`{"wifi": {"ssid": "foo"}}`))
+	c.Assert(err, IsNil)
+	c.Assert(cs.reqs, HasLen, 1)
+	c.Check(cs.reqs[0].Method, Equals, "PUT")
+	c.Check(cs.reqs[0].Header.Get("Content-Type"), Equals, "application/json")
+	c.Check(cs.reqs[0].URL.Path, Equals, "/v2/confdb/a/b")
+	data, err := io.ReadAll(cs.reqs[0].Body)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"wifi": {"ssid": "foo"}}`)
+}
+
+func (cs *clientSuite) TestConfdbImportError(c *C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "status-code": 400, "result": {"message": "cannot import confdb a/b: boom"}}`
+
+	err := cs.cli.ConfdbImport("a/b", strings.NewReader(`{}`))
+	c.Assert(err, ErrorMatches, "cannot import confdb a/b: boom")
+}
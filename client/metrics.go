@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Metrics returns the metrics about the internals of snapd, in the
+// OpenMetrics text format.
+func (client *Client) Metrics() (string, error) {
+	rsp, err := client.raw(context.Background(), "GET", "/v2/metrics", nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != 200 {
+		var r response
+		dec := json.NewDecoder(rsp.Body)
+		if err := dec.Decode(&r); err == nil {
+			if specificErr := r.err(client, rsp.StatusCode); specificErr != nil {
+				return "", specificErr
+			}
+		}
+		return "", fmt.Errorf("unexpected status code: %v", rsp.Status)
+	}
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read metrics: %v", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,44 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"net/http"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientMetrics(c *check.C) {
+	cs.rsp = "# TYPE snapd_tasks gauge\nsnapd_tasks{status=\"Done\"} 1\n# EOF\n"
+	cs.header = http.Header{"Content-Type": []string{"application/openmetrics-text; version=1.0.0; charset=utf-8"}}
+	out, err := cs.cli.Metrics()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/metrics")
+	c.Check(out, check.Equals, cs.rsp)
+}
+
+func (cs *clientSuite) TestClientMetricsError(c *check.C) {
+	cs.rsp = `{"type": "error", "result": {"message": "feature flag \"metrics\" is disabled: set 'experimental.metrics' to true"}}`
+	cs.status = 400
+	cs.header = http.Header{"Content-Type": []string{"application/json"}}
+	_, err := cs.cli.Metrics()
+	c.Check(err, check.ErrorMatches, `feature flag "metrics" is disabled: .*`)
+}
@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PromptingPermission holds the outcome and lifespan of a permission of a
+// prompting rule.
+type PromptingPermission struct {
+	Outcome    string     `json:"outcome"`
+	Lifespan   string     `json:"lifespan"`
+	Duration   string     `json:"duration,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// PromptingConstraints holds the path pattern and permissions of a prompting
+// rule.
+type PromptingConstraints struct {
+	PathPattern string                          `json:"path-pattern,omitempty"`
+	Permissions map[string]*PromptingPermission `json:"permissions"`
+}
+
+// PromptingRule is a prompting rule of a user.
+type PromptingRule struct {
+	ID          string                `json:"id"`
+	Timestamp   time.Time             `json:"timestamp"`
+	User        uint32                `json:"user"`
+	Snap        string                `json:"snap"`
+	Interface   string                `json:"interface"`
+	Constraints *PromptingConstraints `json:"constraints"`
+}
+
+// PromptingAdminRule is a prompting rule defined by the administrator of the
+// system or by the gadget, which applies to every user and takes precedence
+// over the rules of the user.
+type PromptingAdminRule struct {
+	// Snap is empty if the rule applies to every snap.
+	Snap        string                `json:"snap,omitempty"`
+	Interface   string                `json:"interface"`
+	Constraints *PromptingConstraints `json:"constraints"`
+	Source      string                `json:"source"`
+}
+
+// PromptingRuleContents holds the portable contents of a prompting rule, as
+// exported and imported.
+type PromptingRuleContents struct {
+	Snap        string                `json:"snap,omitempty"`
+	Interface   string                `json:"interface"`
+	Constraints *PromptingConstraints `json:"constraints"`
+}
+
+// PromptingRulesOptions selects the prompting rules to return by snap and/or
+// interface.
+type PromptingRulesOptions struct {
+	Snap      string
+	Interface string
+}
+
+func (opts *PromptingRulesOptions) query() url.Values {
+	q := make(url.Values)
+	if opts == nil {
+		return q
+	}
+	if opts.Snap != "" {
+		q.Set("snap", opts.Snap)
+	}
+	if opts.Interface != "" {
+		q.Set("interface", opts.Interface)
+	}
+	return q
+}
+
+// PromptingRules returns the prompting rules of the current user.
+func (client *Client) PromptingRules(opts *PromptingRulesOptions) ([]*PromptingRule, error) {
+	var rules []*PromptingRule
+	_, err := client.doSync("GET", "/v2/interfaces/requests/rules", opts.query(), nil, nil, &rules)
+	return rules, err
+}
+
+// PromptingAdminRules returns the prompting rules defined by the
+// administrator or the gadget.
+func (client *Client) PromptingAdminRules(opts *PromptingRulesOptions) ([]*PromptingAdminRule, error) {
+	var rules []*PromptingAdminRule
+	_, err := client.doSync("GET", "/v2/interfaces/requests/admin-rules", opts.query(), nil, nil, &rules)
+	return rules, err
+}
+
+// ExportPromptingRules returns the contents of the prompting rules of the
+// current user which can be imported again, on this or another system.
+func (client *Client) ExportPromptingRules(opts *PromptingRulesOptions) ([]*PromptingRuleContents, error) {
+	q := opts.query()
+	q.Set("export", "true")
+	var rules []*PromptingRuleContents
+	_, err := client.doSync("GET", "/v2/interfaces/requests/rules", q, nil, nil, &rules)
+	return rules, err
+}
+
+type importPromptingRulesAction struct {
+	Action string                   `json:"action"`
+	Rules  []*PromptingRuleContents `json:"rules"`
+}
+
+// ImportPromptingRules creates prompting rules for the current user with the
+// given contents. Either all of the rules are imported, or none of them.
+func (client *Client) ImportPromptingRules(rules []*PromptingRuleContents) ([]*PromptingRule, error) {
+	var body bytes.Buffer
+	action := importPromptingRulesAction{Action: "import", Rules: rules}
+	if err := json.NewEncoder(&body).Encode(action); err != nil {
+		return nil, err
+	}
+	var imported []*PromptingRule
+	_, err := client.doSync("POST", "/v2/interfaces/requests/rules", nil, nil, &body, &imported)
+	return imported, err
+}
+
+// PromptingAuditEntry records the decision made for a request from a snap
+// when AppArmor prompting is enabled.
+type PromptingAuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	User        uint32    `json:"user"`
+	Snap        string    `json:"snap"`
+	PID         int32     `json:"pid,omitempty"`
+	Interface   string    `json:"interface"`
+	Path        string    `json:"path,omitempty"`
+	Permissions []string  `json:"permissions"`
+	Allowed     []string  `json:"allowed,omitempty"`
+	Denied      []string  `json:"denied,omitempty"`
+	Outstanding []string  `json:"outstanding,omitempty"`
+	DecidedBy   string    `json:"decided-by"`
+	PromptID    string    `json:"prompt-id,omitempty"`
+	Rules       []string  `json:"rules,omitempty"`
+	Client      string    `json:"client,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// PromptingAuditOptions selects the entries of the prompting audit log to
+// return.
+type PromptingAuditOptions struct {
+	// User, if not nil, selects only entries for the given user.
+	User      *uint32
+	Snap      string
+	Interface string
+	// Since, if not zero, selects only entries recorded after the given time.
+	Since time.Time
+	// Limit, if positive, selects only the given number of most recent
+	// entries.
+	Limit int
+}
+
+// PromptingAudit returns the entries of the audit log of decisions made for
+// requests from snaps when AppArmor prompting is enabled, from oldest to
+// newest.
+func (client *Client) PromptingAudit(opts *PromptingAuditOptions) ([]*PromptingAuditEntry, error) {
+	q := make(url.Values)
+	if opts != nil {
+		if opts.User != nil {
+			q.Set("user-id", strconv.FormatUint(uint64(*opts.User), 10))
+		}
+		if opts.Snap != "" {
+			q.Set("snap", opts.Snap)
+		}
+		if opts.Interface != "" {
+			q.Set("interface", opts.Interface)
+		}
+		if !opts.Since.IsZero() {
+			q.Set("since", opts.Since.Format(time.RFC3339))
+		}
+		if opts.Limit > 0 {
+			q.Set("limit", strconv.Itoa(opts.Limit))
+		}
+	}
+	var entries []*PromptingAuditEntry
+	_, err := client.doSync("GET", "/v2/interfaces/requests/audit", q, nil, nil, &entries)
+	return entries, err
+}
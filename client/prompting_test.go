@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestPromptingRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"id": "0000000000000002",
+			"timestamp": "2026-03-01T10:00:00Z",
+			"user": 1000,
+			"snap": "firefox",
+			"interface": "home",
+			"constraints": {
+				"path-pattern": "/home/test/Downloads/**",
+				"permissions": {"read": {"outcome": "allow", "lifespan": "forever"}}
+			}
+		}]
+	}`
+	rules, err := cs.cli.PromptingRules(&client.PromptingRulesOptions{Snap: "firefox"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Check(rules[0].ID, check.Equals, "0000000000000002")
+	c.Check(rules[0].Constraints, check.DeepEquals, &client.PromptingConstraints{
+		PathPattern: "/home/test/Downloads/**",
+		Permissions: map[string]*client.PromptingPermission{
+			"read": {Outcome: "allow", Lifespan: "forever"},
+		},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+	c.Check(cs.req.URL.Query().Get("snap"), check.Equals, "firefox")
+	c.Check(cs.req.URL.Query().Get("export"), check.Equals, "")
+}
+
+func (cs *clientSuite) TestPromptingAdminRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"interface": "home",
+			"constraints": {
+				"path-pattern": "/home/*/.ssh/**",
+				"permissions": {"read": {"outcome": "deny", "lifespan": "forever"}}
+			},
+			"source": "gadget"
+		}]
+	}`
+	rules, err := cs.cli.PromptingAdminRules(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Check(rules[0].Snap, check.Equals, "")
+	c.Check(rules[0].Source, check.Equals, "gadget")
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces/requests/admin-rules")
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+}
+
+func (cs *clientSuite) TestExportImportPromptingRules(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"snap": "firefox",
+			"interface": "home",
+			"constraints": {
+				"path-pattern": "/home/test/Downloads/**",
+				"permissions": {"read": {"outcome": "allow", "lifespan": "forever"}}
+			}
+		}]
+	}`
+	exported, err := cs.cli.ExportPromptingRules(&client.PromptingRulesOptions{Interface: "home"})
+	c.Assert(err, check.IsNil)
+	c.Assert(exported, check.HasLen, 1)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+	c.Check(cs.req.URL.Query().Get("interface"), check.Equals, "home")
+	c.Check(cs.req.URL.Query().Get("export"), check.Equals, "true")
+
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"id": "0000000000000003", "snap": "firefox", "interface": "home"}]
+	}`
+	imported, err := cs.cli.ImportPromptingRules(exported)
+	c.Assert(err, check.IsNil)
+	c.Assert(imported, check.HasLen, 1)
+	c.Check(imported[0].ID, check.Equals, "0000000000000003")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var action map[string]any
+	c.Assert(json.Unmarshal(body, &action), check.IsNil)
+	c.Check(action, check.DeepEquals, map[string]any{
+		"action": "import",
+		"rules": []any{map[string]any{
+			"snap":      "firefox",
+			"interface": "home",
+			"constraints": map[string]any{
+				"path-pattern": "/home/test/Downloads/**",
+				"permissions": map[string]any{
+					"read": map[string]any{"outcome": "allow", "lifespan": "forever"},
+				},
+			},
+		}},
+	})
+}
+
+func (cs *clientSuite) TestPromptingAudit(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"timestamp": "2026-03-01T10:00:00Z",
+			"user": 1000,
+			"snap": "firefox",
+			"interface": "home",
+			"path": "/home/test/foo",
+			"permissions": ["read"],
+			"allowed": ["read"],
+			"decided-by": "reply",
+			"prompt-id": "0000000000000003",
+			"client": "pid=1234"
+		}]
+	}`
+	user := uint32(1000)
+	entries, err := cs.cli.PromptingAudit(&client.PromptingAuditOptions{
+		User:  &user,
+		Snap:  "firefox",
+		Since: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		Limit: 5,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Check(entries[0].DecidedBy, check.Equals, "reply")
+	c.Check(entries[0].Client, check.Equals, "pid=1234")
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces/requests/audit")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"user-id": []string{"1000"},
+		"snap":    []string{"firefox"},
+		"since":   []string{"2026-03-01T09:00:00Z"},
+		"limit":   []string{"5"},
+	})
+
+	_, err = cs.cli.PromptingAudit(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+}
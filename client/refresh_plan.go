@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// RefreshPlan describes what a refresh would do without doing it.
+type RefreshPlan struct {
+	Snaps []RefreshPlanSnap `json:"snaps,omitempty"`
+	// Conflicts lists the changes in progress a refresh conflicts with.
+	Conflicts []RefreshPlanConflict `json:"conflicts,omitempty"`
+	// DownloadSize is the total size of what needs to be downloaded.
+	DownloadSize   int64 `json:"download-size"`
+	RebootRequired bool  `json:"reboot-required"`
+}
+
+// RefreshPlanSnap describes the refresh of a single snap.
+type RefreshPlanSnap struct {
+	Name            string                    `json:"name"`
+	Type            string                    `json:"type"`
+	Channel         string                    `json:"channel,omitempty"`
+	CurrentRevision snap.Revision             `json:"current-revision"`
+	Revision        snap.Revision             `json:"revision"`
+	DownloadSize    int64                     `json:"download-size,omitempty"`
+	Components      []RefreshPlanComponent    `json:"components,omitempty"`
+	Prerequisites   []RefreshPlanPrerequisite `json:"prerequisites,omitempty"`
+	AutoConnect     bool                      `json:"auto-connect,omitempty"`
+	RebootRequired  bool                      `json:"reboot-required,omitempty"`
+	// Tasks are the summaries of the tasks the refresh would run.
+	Tasks []string `json:"tasks,omitempty"`
+}
+
+// RefreshPlanComponent describes the refresh of a component of a snap.
+type RefreshPlanComponent struct {
+	Name            string        `json:"name"`
+	CurrentRevision snap.Revision `json:"current-revision"`
+	Revision        snap.Revision `json:"revision"`
+	DownloadSize    int64         `json:"download-size,omitempty"`
+}
+
+// RefreshPlanPrerequisite is a snap needed by a refreshed snap, missing
+// prerequisites are installed by the refresh.
+type RefreshPlanPrerequisite struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	// Content lists the content tags auto-connected from the
+	// prerequisite.
+	Content []string `json:"content,omitempty"`
+}
+
+// RefreshPlanConflict is a change in progress that a refresh conflicts
+// with.
+type RefreshPlanConflict struct {
+	Snap       string `json:"snap"`
+	ChangeKind string `json:"change-kind,omitempty"`
+	ChangeID   string `json:"change-id,omitempty"`
+	Message    string `json:"message"`
+}
+
+// RefreshManyDryRun returns what refreshing the given snaps, or all snaps
+// if none are given, would do without refreshing them.
+func (client *Client) RefreshManyDryRun(names []string, options *SnapOptions) (*RefreshPlan, error) {
+	action := multiActionData{
+		Action: "refresh",
+		Snaps:  names,
+		DryRun: true,
+	}
+	if options != nil {
+		action.Transaction = options.Transaction
+		action.IgnoreRunning = options.IgnoreRunning
+		action.ValidationSets = options.ValidationSets
+	}
+
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan RefreshPlan
+	if _, err := client.doSync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
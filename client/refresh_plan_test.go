@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientRefreshManyDryRun(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"snaps": [{
+				"name": "foo",
+				"type": "app",
+				"channel": "stable",
+				"current-revision": "1",
+				"revision": "2",
+				"download-size": 1024,
+				"prerequisites": [{"name": "core22", "installed": true}],
+				"auto-connect": true,
+				"tasks": ["Download snap \"foo\" (2) from channel \"stable\""]
+			}],
+			"conflicts": [{"snap": "bar", "change-kind": "install", "change-id": "7", "message": "snap \"bar\" has \"install\" change in progress"}],
+			"download-size": 1024,
+			"reboot-required": false
+		}
+	}`
+	plan, err := cs.cli.RefreshManyDryRun([]string{"foo", "bar"}, &client.SnapOptions{IgnoreRunning: true})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, &client.RefreshPlan{
+		Snaps: []client.RefreshPlanSnap{{
+			Name:            "foo",
+			Type:            "app",
+			Channel:         "stable",
+			CurrentRevision: snap.R(1),
+			Revision:        snap.R(2),
+			DownloadSize:    1024,
+			Prerequisites:   []client.RefreshPlanPrerequisite{{Name: "core22", Installed: true}},
+			AutoConnect:     true,
+			Tasks:           []string{`Download snap "foo" (2) from channel "stable"`},
+		}},
+		Conflicts: []client.RefreshPlanConflict{{
+			Snap:       "bar",
+			ChangeKind: "install",
+			ChangeID:   "7",
+			Message:    `snap "bar" has "install" change in progress`,
+		}},
+		DownloadSize: 1024,
+	})
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]any
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]any{
+		"action":         "refresh",
+		"snaps":          []any{"foo", "bar"},
+		"ignore-running": true,
+		"dry-run":        true,
+	})
+}
@@ -136,6 +136,10 @@ type multiActionData struct {
 	Time           string              `json:"time,omitempty"`
 	HoldLevel      string              `json:"hold-level,omitempty"`
 	Components     map[string][]string `json:"components,omitempty"`
+	DryRun         bool                `json:"dry-run,omitempty"`
+
+	SnapshotEncrypt    string `json:"snapshot-encrypt,omitempty"`
+	SnapshotPassphrase string `json:"snapshot-passphrase,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -206,7 +210,44 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
 func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, nil, &SnapOptions{Users: users})
+	return client.snapshotMany(names, users, nil)
+}
+
+// SnapshotEncryptOptions describe how to encrypt a snapshot set.
+type SnapshotEncryptOptions struct {
+	// KeyProtection is either "passphrase" or "device"
+	KeyProtection string
+	// Passphrase is required when protecting the key with a passphrase
+	Passphrase string
+}
+
+// SnapshotManyEncrypted snapshots many snaps like SnapshotMany, encrypting
+// the snapshots as described by the options.
+func (client *Client) SnapshotManyEncrypted(names []string, users []string, opts *SnapshotEncryptOptions) (setID uint64, changeID string, err error) {
+	if opts == nil || opts.KeyProtection == "" {
+		return 0, "", fmt.Errorf("cannot encrypt snapshot: no key protection given")
+	}
+	return client.snapshotMany(names, users, opts)
+}
+
+func (client *Client) snapshotMany(names []string, users []string, encOpts *SnapshotEncryptOptions) (setID uint64, changeID string, err error) {
+	action := multiActionData{
+		Action: "snapshot",
+		Snaps:  names,
+		Users:  users,
+	}
+	if encOpts != nil {
+		action.SnapshotEncrypt = encOpts.KeyProtection
+		action.SnapshotPassphrase = encOpts.Passphrase
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	result, changeID, err := client.doAsyncFull("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), nil)
 	if err != nil {
 		return 0, "", err
 	}
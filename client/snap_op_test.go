@@ -309,6 +309,37 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientMultiSnapshotEncrypted(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"result": {"set-id": 42},
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	setID, changeID, err := cs.cli.SnapshotManyEncrypted([]string{pkgName}, nil, &client.SnapshotEncryptOptions{
+		KeyProtection: "passphrase",
+		Passphrase:    "s3kr1t",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(42))
+	c.Check(changeID, check.Equals, "d728")
+
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]any)
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]any{
+		"action":              "snapshot",
+		"snaps":               []any{pkgName},
+		"snapshot-encrypt":    "passphrase",
+		"snapshot-passphrase": "s3kr1t",
+	})
+
+	_, _, err = cs.cli.SnapshotManyEncrypted(nil, nil, nil)
+	c.Check(err, check.ErrorMatches, "cannot encrypt snapshot: no key protection given")
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
@@ -46,10 +46,11 @@ var (
 
 // A snapshotAction is used to request an operation on a snapshot.
 type snapshotAction struct {
-	SetID  uint64   `json:"set"`
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
-	Users  []string `json:"users,omitempty"`
+	SetID      uint64   `json:"set"`
+	Action     string   `json:"action"`
+	Snaps      []string `json:"snaps,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Passphrase string   `json:"passphrase,omitempty"`
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -81,6 +82,9 @@ type Snapshot struct {
 	// dynamic snapshot options
 	Options *snap.SnapshotOptions `json:"options,omitempty"`
 
+	// how the archives are encrypted, if they are
+	Encryption *SnapshotEncryption `json:"encryption,omitempty"`
+
 	// if the snapshot failed to open this will be the reason why
 	Broken string `json:"broken,omitempty"`
 
@@ -91,6 +95,22 @@ type Snapshot struct {
 	Auto bool `json:"auto,omitempty"`
 }
 
+// SnapshotEncryption describes how the archives of an encrypted snapshot are
+// encrypted, and how the key they are encrypted with is protected.
+type SnapshotEncryption struct {
+	Cipher string `json:"cipher"`
+	// KeyProtection is either "passphrase" or "device"
+	KeyProtection string `json:"key-protection"`
+	// the key derivation parameters for passphrase protected keys
+	KDF        string `json:"kdf,omitempty"`
+	KDFTime    uint32 `json:"kdf-time,omitempty"`
+	KDFMemory  uint32 `json:"kdf-memory,omitempty"`
+	KDFThreads uint8  `json:"kdf-threads,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	// the key the archives are encrypted with, itself encrypted
+	WrappedKey []byte `json:"wrapped-key"`
+}
+
 // IsValid checks whether the snapshot is missing information that
 // should be there for a snapshot that's just been opened.
 func (sh *Snapshot) IsValid() bool {
@@ -197,11 +217,19 @@ func (client *Client) ForgetSnapshots(setID uint64, snaps []string) (changeID st
 // If snaps or users are non-empty, limit to checking only those
 // archives of the snapshot.
 func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+	return client.CheckSnapshotsWithPassphrase(setID, snaps, users, "")
+}
+
+// CheckSnapshotsWithPassphrase verifies the given snapshot set like
+// CheckSnapshots, using the passphrase to unlock encrypted snapshots so that
+// their archives can be authenticated as well.
+func (client *Client) CheckSnapshotsWithPassphrase(setID uint64, snaps []string, users []string, passphrase string) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
-		SetID:  setID,
-		Action: "check",
-		Snaps:  snaps,
-		Users:  users,
+		SetID:      setID,
+		Action:     "check",
+		Snaps:      snaps,
+		Users:      users,
+		Passphrase: passphrase,
 	})
 }
 
@@ -210,11 +238,18 @@ func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []strin
 // If snaps or users are non-empty, limit to checking only those
 // archives of the snapshot.
 func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+	return client.RestoreSnapshotsWithPassphrase(setID, snaps, users, "")
+}
+
+// RestoreSnapshotsWithPassphrase extracts the given snapshot set like
+// RestoreSnapshots, using the passphrase to unlock encrypted snapshots.
+func (client *Client) RestoreSnapshotsWithPassphrase(setID uint64, snaps []string, users []string, passphrase string) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
-		SetID:  setID,
-		Action: "restore",
-		Snaps:  snaps,
-		Users:  users,
+		SetID:      setID,
+		Action:     "restore",
+		Snaps:      snaps,
+		Users:      users,
+		Passphrase: passphrase,
 	})
 }
 
@@ -280,3 +315,49 @@ func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (Snapsh
 
 	return importSet, nil
 }
+
+// RemoteSnapshot is a snapshot set offloaded to a snapshot target.
+type RemoteSnapshot struct {
+	// Name is the name the set is stored under on the target.
+	Name string `json:"name"`
+	// Host is the hostname of the system the set was offloaded from.
+	Host string `json:"host"`
+	// SetID is the ID of the set on the system it was offloaded from.
+	SetID uint64 `json:"set"`
+	// Time is when the set was offloaded.
+	Time time.Time `json:"time"`
+}
+
+// SnapshotTarget is the remote target snapshot sets are offloaded to.
+type SnapshotTarget struct {
+	Type      string           `json:"type"`
+	URL       string           `json:"url"`
+	Snapshots []RemoteSnapshot `json:"snapshots"`
+}
+
+// SnapshotTarget returns the configured snapshot target and the snapshot sets
+// stored there.
+func (client *Client) SnapshotTarget() (*SnapshotTarget, error) {
+	var target SnapshotTarget
+	if _, err := client.doSync("GET", "/v2/snapshots/target", nil, nil, nil, &target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// FetchSnapshot downloads the named snapshot set from the snapshot target and
+// imports it.
+func (client *Client) FetchSnapshot(name string) (SnapshotImportSet, error) {
+	var importSet SnapshotImportSet
+	data, err := json.Marshal(map[string]string{"action": "fetch", "name": name})
+	if err != nil {
+		return importSet, fmt.Errorf("cannot marshal snapshot target action: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	if _, err := client.doSync("POST", "/v2/snapshots/target", nil, headers, bytes.NewBuffer(data), &importSet); err != nil {
+		return importSet, err
+	}
+	return importSet, nil
+}
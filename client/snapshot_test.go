@@ -143,6 +143,25 @@ func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
 	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
 }
 
+func (cs *clientSuite) TestClientSnapshotActionsWithPassphrase(c *check.C) {
+	for action, f := range map[string]func(uint64, []string, []string, string) (string, error){
+		"check":   cs.cli.CheckSnapshotsWithPassphrase,
+		"restore": cs.cli.RestoreSnapshotsWithPassphrase,
+	} {
+		cs.status = 202
+		cs.rsp = `{"status-code": 202, "type": "async", "change": "1too3"}`
+		id, err := f(42, []string{"asnap"}, nil, "s3kr1t")
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "1too3")
+
+		act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(act.Action, check.Equals, action)
+		c.Check(act.SetID, check.Equals, uint64(42))
+		c.Check(act.Passphrase, check.Equals, "s3kr1t")
+	}
+}
+
 func (cs *clientSuite) TestClientExportSnapshotSpecificErr(c *check.C) {
 	content := `{"type":"error","status-code":400,"result":{"message":"boom","kind":"err-kind","value":"err-value"}}`
 	cs.contentLength = int64(len(content))
@@ -229,6 +248,39 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientSnapshotTarget(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"type": "s3", "url": "https://s3.example.com/bucket", "snapshots": [
+		{"name": "box_20260102T030405Z_7.snapshot", "host": "box", "set": 7, "time": "2026-01-02T03:04:05Z"}
+	]}}`
+	target, err := cs.cli.SnapshotTarget()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots/target")
+	c.Check(target, check.DeepEquals, &client.SnapshotTarget{
+		Type: "s3",
+		URL:  "https://s3.example.com/bucket",
+		Snapshots: []client.RemoteSnapshot{{
+			Name:  "box_20260102T030405Z_7.snapshot",
+			Host:  "box",
+			SetID: 7,
+			Time:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		}},
+	})
+}
+
+func (cs *clientSuite) TestClientFetchSnapshot(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["foo"]}}`
+	importSet, err := cs.cli.FetchSnapshot("box_20260102T030405Z_7.snapshot")
+	c.Assert(err, check.IsNil)
+	c.Check(importSet, check.DeepEquals, client.SnapshotImportSet{ID: 42, Snaps: []string{"foo"}})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots/target")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"fetch","name":"box_20260102T030405Z_7.snapshot"}`)
+}
+
 func (cs *clientSuite) TestClientSnapshotContentHash(c *check.C) {
 	now := time.Now()
 	revno := snap.R(1)
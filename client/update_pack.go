@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"io"
+)
+
+const (
+	// UpdatePackManifestName is the name of the update pack member
+	// holding the JSON encoded UpdatePackManifest.
+	UpdatePackManifestName = "manifest.json"
+	// UpdatePackAssertionsName is the name of the update pack member
+	// holding the stream of assertions needed to verify the snaps and
+	// components in the pack.
+	UpdatePackAssertionsName = "assertions"
+	// UpdatePackSnapsDir is the directory in the update pack holding
+	// the snap and component files.
+	UpdatePackSnapsDir = "snaps"
+
+	// UpdatePackFormat is the current version of the update pack
+	// manifest format.
+	UpdatePackFormat = 1
+)
+
+// UpdatePackManifest describes the content of an update pack, an
+// uncompressed tar archive created by "snap export-update-pack" that
+// carries snaps, components and their assertions to devices without
+// access to the store.
+type UpdatePackManifest struct {
+	Format int `json:"format"`
+	// Model optionally references the model the pack was exported for.
+	Model *UpdatePackModel `json:"model,omitempty"`
+	// ValidationSets lists the validation sets, in the
+	// account/name=sequence form, whose assertions are in the pack.
+	ValidationSets []string         `json:"validation-sets,omitempty"`
+	Snaps          []UpdatePackSnap `json:"snaps"`
+}
+
+type UpdatePackModel struct {
+	BrandID string `json:"brand-id"`
+	Model   string `json:"model"`
+}
+
+// UpdatePackSnap describes a snap file in an update pack.
+type UpdatePackSnap struct {
+	Name string `json:"name"`
+	// File is the path of the snap file relative to the root of the pack.
+	File     string `json:"file"`
+	Revision string `json:"revision"`
+	// Channel is the channel the snap was downloaded from, if any.
+	Channel    string                `json:"channel,omitempty"`
+	Components []UpdatePackComponent `json:"components,omitempty"`
+}
+
+// UpdatePackComponent describes a component file in an update pack.
+type UpdatePackComponent struct {
+	Name string `json:"name"`
+	// File is the path of the component file relative to the root of
+	// the pack.
+	File     string `json:"file"`
+	Revision string `json:"revision"`
+}
+
+// ApplyUpdatePack sends the update pack read from r to snapd which
+// imports its assertions and refreshes the installed snaps it
+// carries, returning the ID of the background operation.
+func (client *Client) ApplyUpdatePack(r io.Reader) (changeID string, err error) {
+	headers := map[string]string{
+		"Content-Type": "application/x-tar",
+	}
+	_, changeID, err = client.doAsyncFull("POST", "/v2/update-pack", nil, headers, r, doNoTimeoutAndRetry)
+	return changeID, err
+}
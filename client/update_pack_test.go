@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientApplyUpdatePack(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+	id, err := cs.cli.ApplyUpdatePack(strings.NewReader("pack-data"))
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "42")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/update-pack")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/x-tar")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, "pack-data")
+}
+
+func (cs *clientSuite) TestClientApplyUpdatePackError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 400,
+		"result": {"message": "update pack has no updates for installed snaps", "kind": "snap-no-update-available"}
+	}`
+	_, err := cs.cli.ApplyUpdatePack(strings.NewReader("pack-data"))
+	c.Assert(err, check.ErrorMatches, "update pack has no updates for installed snaps")
+}
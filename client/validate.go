@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"time"
 
 	"golang.org/x/xerrors"
 )
@@ -32,6 +33,11 @@ import (
 type ValidateApplyOptions struct {
 	Mode     string
 	Sequence int
+
+	// At and InMaintenanceWindow schedule enforcing the given sequence
+	// of an already enforced validation set instead of doing it right away.
+	At                  time.Time
+	InMaintenanceWindow bool
 }
 
 // ValidationSetResult holds information about a single validation set.
@@ -45,12 +51,27 @@ type ValidationSetResult struct {
 	Mode  string `json:"mode"`
 	Valid bool   `json:"valid"`
 	// TODO: flags/states for notes column
+
+	Transition *ValidationSetTransition `json:"transition,omitempty"`
+}
+
+// ValidationSetTransition holds information about a scheduled move of an
+// enforced validation set to a new sequence.
+type ValidationSetTransition struct {
+	Sequence            int        `json:"sequence"`
+	At                  *time.Time `json:"at,omitempty"`
+	InMaintenanceWindow bool       `json:"in-maintenance-window,omitempty"`
+	PreDownloadChange   string     `json:"pre-download-change,omitempty"`
+	Change              string     `json:"change,omitempty"`
 }
 
 type postValidationSetData struct {
 	Action   string `json:"action"`
 	Mode     string `json:"mode,omitempty"`
 	Sequence int    `json:"sequence,omitempty"`
+
+	At                  string `json:"at,omitempty"`
+	InMaintenanceWindow bool   `json:"in-maintenance-window,omitempty"`
 }
 
 // ForgetValidationSet forgets the given validation set identified by account,
@@ -77,6 +98,29 @@ func (client *Client) ForgetValidationSet(accountID, name string, sequence int)
 	return nil
 }
 
+// CancelValidationSetTransition cancels the scheduled transition of the given
+// validation set identified by account and name.
+func (client *Client) CancelValidationSetTransition(accountID, name string) error {
+	if accountID == "" || name == "" {
+		return xerrors.Errorf("cannot cancel validation set transition without account ID and name")
+	}
+
+	data := &postValidationSetData{
+		Action: "cancel-transition",
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/v2/validation-sets/%s/%s", accountID, name)
+	if _, err := client.doSync("POST", path, nil, nil, &body, nil); err != nil {
+		fmt := "cannot cancel validation set transition: %w"
+		return xerrors.Errorf(fmt, err)
+	}
+	return nil
+}
+
 // ApplyValidationSet applies the given validation set identified by account and name and returns
 // the new validation set tracking info. For monitoring mode the returned res may indicate invalid
 // state.
@@ -86,9 +130,13 @@ func (client *Client) ApplyValidationSet(accountID, name string, opts *ValidateA
 	}
 
 	data := &postValidationSetData{
-		Action:   "apply",
-		Mode:     opts.Mode,
-		Sequence: opts.Sequence,
+		Action:              "apply",
+		Mode:                opts.Mode,
+		Sequence:            opts.Sequence,
+		InMaintenanceWindow: opts.InMaintenanceWindow,
+	}
+	if !opts.At.IsZero() {
+		data.At = opts.At.Format(time.RFC3339)
 	}
 
 	var body bytes.Buffer
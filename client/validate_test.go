@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/url"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -238,3 +239,63 @@ func (cs *clientSuite) TestValidationSetWithSequence(c *check.C) {
 		AccountID: "abc", Name: "def", Mode: "monitor", Sequence: 9, Valid: false,
 	})
 }
+
+func (cs *clientSuite) TestApplyValidationSetEnforceScheduled(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"account-id": "foo", "name": "bar", "mode": "enforce", "sequence": 3, "pinned-at": 3, "valid": true,
+			"transition": {"sequence": 4, "at": "2030-11-20T02:00:00Z", "in-maintenance-window": true, "pre-download-change": "42"}}
+	}`
+	at := time.Date(2030, 11, 20, 2, 0, 0, 0, time.UTC)
+	opts := &client.ValidateApplyOptions{Mode: "enforce", Sequence: 4, At: at, InMaintenanceWindow: true}
+	vs, err := cs.cli.ApplyValidationSet("foo", "bar", opts)
+	c.Assert(err, check.IsNil)
+	c.Check(vs, check.DeepEquals, &client.ValidationSetResult{
+		AccountID: "foo",
+		Name:      "bar",
+		Mode:      "enforce",
+		Sequence:  3,
+		PinnedAt:  3,
+		Valid:     true,
+		Transition: &client.ValidationSetTransition{
+			Sequence:            4,
+			At:                  &at,
+			InMaintenanceWindow: true,
+			PreDownloadChange:   "42",
+		},
+	})
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]any
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]any{
+		"action":                "apply",
+		"mode":                  "enforce",
+		"sequence":              float64(4),
+		"at":                    "2030-11-20T02:00:00Z",
+		"in-maintenance-window": true,
+	})
+}
+
+func (cs *clientSuite) TestCancelValidationSetTransition(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200
+	}`
+	c.Assert(cs.cli.CancelValidationSetTransition("foo", "bar"), check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]any
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]any{
+		"action": "cancel-transition",
+	})
+
+	err = cs.cli.CancelValidationSetTransition("", "bar")
+	c.Assert(err, check.ErrorMatches, `cannot cancel validation set transition without account ID and name`)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snapdtool"
 )
@@ -173,6 +174,12 @@ func (c *cmdSnapd) Execute(args []string) error {
 		return osutil.OutputErrCombine(stdout, stderr, err)
 	}
 
+	// the previous snapd might not know about the state journal, make sure
+	// the state file holds the whole state
+	if err := state.FoldJournal(dirs.SnapStateFile); err != nil {
+		return err
+	}
+
 	logger.Noticef("restoring invoking snapd from: %v", snapdPath)
 	if prevRev != "0" {
 		// if prevRev was "0" it means we did *not* find a
@@ -31,6 +31,7 @@ import (
 	failure "github.com/snapcore/snapd/cmd/snap-failure"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -104,6 +105,41 @@ set -eu
 	})
 }
 
+func (r *failureSuite) TestCallPrevSnapdFoldsStateJournal(c *C) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+
+	writeSeqFile(c, "snapd", snap.R(123), []*snap.SideInfo{
+		{Revision: snap.R(100)},
+		{Revision: snap.R(123)},
+	})
+
+	base := []byte(`{"data":{"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(os.WriteFile(dirs.SnapStateFile, base, 0600), IsNil)
+	journalPath := state.JournalPath(dirs.SnapStateFile)
+	journal := string(state.JournalHeader(base)) + `{"data":{"some":"other-data"}}` + "\n"
+	c.Assert(os.WriteFile(journalPath, []byte(journal), 0600), IsNil)
+
+	// the previous snapd finds the whole state in the state file
+	mockScript := `
+set -eu
+
+[ ! -e '%[1]s' ]
+grep -q '"some":"other-data"' '%[2]s'
+`
+	systemdRunCmd := testutil.MockCommand(c, "systemd-run", fmt.Sprintf(mockScript, journalPath, dirs.SnapStateFile))
+	defer systemdRunCmd.Restore()
+
+	err := os.MkdirAll(filepath.Join(dirs.SnapMountDir, "snapd"), 0755)
+	c.Assert(err, IsNil)
+
+	os.Args = []string{"snap-failure", "snapd"}
+	err = failure.Run()
+	c.Check(err, IsNil)
+	c.Check(systemdRunCmd.Calls(), HasLen, 1)
+}
+
 func (r *failureSuite) TestCallPrevSnapdFromSnapRestartSnapdFallback(c *C) {
 	defer failure.MockWaitTimes(1*time.Millisecond, 1*time.Millisecond)()
 
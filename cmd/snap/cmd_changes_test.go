@@ -250,3 +250,32 @@ func (s *SnapSuite) TestNoChanges(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Check(s.Stderr(), check.Equals, "no changes found\n")
 }
+
+func (s *SnapSuite) TestChangesArchived(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "archived")
+			c.Check(r.URL.Query().Get("for"), check.Equals, "foo")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"id": "7", "kind": "refresh-snap", "summary": "Refresh foo", "status": "Error", "ready": true, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}]}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--archived", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+7 +Error +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +Refresh foo
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdConfdb struct{}
+
+type cmdConfdbExport struct {
+	clientMixin
+	Positional struct {
+		Schema string `positional-arg-name:"<account-id>/<confdb-schema>" description:"Confdb schema whose databag to export"`
+	} `positional-args:"true" required:"true"`
+}
+
+type cmdConfdbImport struct {
+	clientMixin
+	Positional struct {
+		Schema string         `positional-arg-name:"<account-id>/<confdb-schema>" description:"Confdb schema whose databag to replace"`
+		File   flags.Filename `positional-arg-name:"<file>" description:"JSON file with the databag to import, or - for standard input"`
+	} `positional-args:"true" required:"true"`
+}
+
+var shortConfdbHelp = i18n.G("Export and import confdb data")
+var longConfdbHelp = i18n.G(`
+The confdb command manages the whole data of a confdb schema, bypassing its
+views.
+
+'snap confdb export' writes the databag of the confdb schema to standard
+output as JSON, including values that no view exposes, such as secrets.
+
+'snap confdb import' replaces the databag of the confdb schema with the JSON
+object in the given file, as written by 'snap confdb export'. The data is
+validated against the confdb schema and is either imported as a whole or not
+at all. Custodian snaps are not notified of the change.
+`)
+
+func init() {
+	cmd := addCommand("confdb", shortConfdbHelp, longConfdbHelp, func() flags.Commander {
+		return &cmdConfdb{}
+	}, nil, nil)
+	cmd.extra = func(c *flags.Command) {
+		c.AddCommand("export", i18n.G("Export the databag of a confdb schema"), "", &cmdConfdbExport{})
+		c.AddCommand("import", i18n.G("Import the databag of a confdb schema"), "", &cmdConfdbImport{})
+	}
+}
+
+func (x *cmdConfdb) Execute(args []string) error {
+	return flag.ErrHelp
+}
+
+func validateConfdbSchemaID(id string) error {
+	parts := strings.Split(id, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.New(i18n.G("confdb-schema id must conform to format: <account-id>/<confdb-schema>"))
+	}
+	return nil
+}
+
+func (x *cmdConfdbExport) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if err := validateConfdbFeatureFlag(); err != nil {
+		return err
+	}
+
+	if err := validateConfdbSchemaID(x.Positional.Schema); err != nil {
+		return err
+	}
+
+	// subcommands don't get a client when the parser is built
+	x.setClient(mkClient())
+	databag, err := x.client.ConfdbExport(x.Positional.Schema)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, databag, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(Stdout)
+	return err
+}
+
+func (x *cmdConfdbImport) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if err := validateConfdbFeatureFlag(); err != nil {
+		return err
+	}
+
+	if err := validateConfdbSchemaID(x.Positional.Schema); err != nil {
+		return err
+	}
+
+	var r io.Reader = Stdin
+	if x.Positional.File != "-" {
+		f, err := os.Open(string(x.Positional.File))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// read the data upfront so that a bad file isn't sent to snapd
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf(i18n.G("cannot read confdb data from %q: invalid JSON"), x.Positional.File)
+	}
+
+	x.setClient(mkClient())
+	if err := x.client.ConfdbImport(x.Positional.Schema, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Imported confdb %s.\n"), x.Positional.Schema)
+	return nil
+}
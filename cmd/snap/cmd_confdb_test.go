@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *confdbSuite) TestConfdbExport(c *C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	var reqs int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/confdb/foo/bar")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"private": {"key": "secret"}, "wifi": {"ssid": "foo", "retries": 3}}}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"confdb", "export", "foo/bar"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, `{
+  "private": {
+    "key": "secret"
+  },
+  "wifi": {
+    "ssid": "foo",
+    "retries": 3
+  }
+}
+`)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(reqs, Equals, 1)
+}
+
+func (s *confdbSuite) TestConfdbImport(c *C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	var reqs int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/v2/confdb/foo/bar")
+		data, err := io.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, `{"wifi": {"ssid": "foo"}}`)
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	path := filepath.Join(c.MkDir(), "databag.json")
+	c.Assert(os.WriteFile(path, []byte(`{"wifi": {"ssid": "foo"}}`), 0644), IsNil)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"confdb", "import", "foo/bar", path})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, "Imported confdb foo/bar.\n")
+	c.Check(s.Stderr(), Equals, "")
+
+	// the data can also come from stdin
+	s.stdout.Reset()
+	s.stdin.WriteString(`{"wifi": {"ssid": "foo"}}`)
+	rest, err = snap.Parser(snap.Client()).ParseArgs([]string{"confdb", "import", "foo/bar", "-"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, "Imported confdb foo/bar.\n")
+	c.Check(reqs, Equals, 2)
+}
+
+func (s *confdbSuite) TestConfdbImportExportErrors(c *C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot import confdb foo/bar: boom"}}`)
+	})
+
+	path := filepath.Join(c.MkDir(), "databag.json")
+	c.Assert(os.WriteFile(path, []byte(`{"wifi": `), 0644), IsNil)
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"confdb", "export", "foo"}, err: `confdb-schema id must conform to format: <account-id>/<confdb-schema>`},
+		{args: []string{"confdb", "export", "foo/bar/baz"}, err: `confdb-schema id must conform to format: <account-id>/<confdb-schema>`},
+		{args: []string{"confdb", "import", "/bar", path}, err: `confdb-schema id must conform to format: <account-id>/<confdb-schema>`},
+		{args: []string{"confdb", "import", "foo/bar", path}, err: `cannot read confdb data from ".*": invalid JSON`},
+		{args: []string{"confdb", "import", "foo/bar", filepath.Join(c.MkDir(), "missing")}, err: `open .*: no such file or directory`},
+		{args: []string{"confdb", "export", "foo/bar"}, err: `cannot import confdb foo/bar: boom`},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(tc.args)
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.args))
+	}
+}
+
+func (s *confdbSuite) TestConfdbFeatureFlag(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %v", r)
+	})
+
+	for _, args := range [][]string{
+		{"confdb", "export", "foo/bar"},
+		{"confdb", "import", "foo/bar", "-"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(args)
+		c.Check(err, ErrorMatches, `the "confdb" feature is disabled: set 'experimental.confdb' to true`)
+	}
+}
@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	squashfsGenerateDelta = squashfs.GenerateDelta
+	squashfsApplyDelta    = squashfs.ApplyDelta
+)
+
+type cmdDebugDelta struct{}
+
+type cmdDebugDeltaCreate struct {
+	Format string `long:"format" default:"xdelta3" description:"Delta format, one of xdelta3 or snap-1-1-xdelta3"`
+	Verify bool   `long:"verify" description:"Check that applying the delta to the source rebuilds the target"`
+
+	Positionals struct {
+		Source flags.Filename `positional-arg-name:"<source>" description:"Snap to generate the delta from"`
+		Target flags.Filename `positional-arg-name:"<target>" description:"Snap to generate the delta to"`
+		Delta  flags.Filename `positional-arg-name:"<delta>" description:"Delta file to write"`
+	} `positional-args:"true" required:"true"`
+}
+
+type cmdDebugDeltaApply struct {
+	Sha3_384 string `long:"sha3-384" description:"Expected SHA3-384 digest of the rebuilt target"`
+
+	Positionals struct {
+		Source flags.Filename `positional-arg-name:"<source>" description:"Snap to apply the delta to"`
+		Delta  flags.Filename `positional-arg-name:"<delta>" description:"Delta file to apply"`
+		Target flags.Filename `positional-arg-name:"<target>" description:"Snap file to write"`
+	} `positional-args:"true" required:"true"`
+}
+
+const longDebugDeltaHelp = `
+Generate and apply binary deltas between snap files offline, with the same
+formats snapd uses when downloading deltas from the store or from peers on
+the local network.
+`
+
+func init() {
+	cmd := addDebugCommand("delta",
+		"Generate and apply binary deltas between snaps",
+		longDebugDeltaHelp,
+		func() flags.Commander { return &cmdDebugDelta{} },
+		nil,
+		nil,
+	)
+	cmd.extra = func(c *flags.Command) {
+		c.AddCommand("create", "Generate a delta between two snaps", "", &cmdDebugDeltaCreate{})
+		c.AddCommand("apply", "Rebuild a snap from a delta", "", &cmdDebugDeltaApply{})
+	}
+}
+
+func (x *cmdDebugDelta) Execute(args []string) error {
+	return flag.ErrHelp
+}
+
+func fileSha3_384(path string) (string, error) {
+	digest, _, err := osutil.FileDigest(path, crypto.SHA3_384)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", digest), nil
+}
+
+func (x *cmdDebugDeltaCreate) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	format, err := squashfs.DeltaFormatFromString(x.Format)
+	if err != nil {
+		return err
+	}
+	source, target, delta := string(x.Positionals.Source), string(x.Positionals.Target), string(x.Positionals.Delta)
+
+	ctx := context.Background()
+	if err := squashfsGenerateDelta(ctx, source, target, delta, format); err != nil {
+		return fmt.Errorf("cannot generate delta: %v", err)
+	}
+	fi, err := os.Stat(delta)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "Created %s delta %s (%s)\n", format, delta, strutil.SizeToStr(fi.Size()))
+
+	if !x.Verify {
+		return nil
+	}
+	expected, err := fileSha3_384(target)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "snap-delta-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	rebuilt := filepath.Join(tmpDir, filepath.Base(target))
+	if err := squashfsApplyDelta(ctx, source, delta, rebuilt); err != nil {
+		return fmt.Errorf("cannot verify delta: %v", err)
+	}
+	actual, err := fileSha3_384(rebuilt)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("cannot verify delta: rebuilt target has sha3-384 %s instead of %s", actual, expected)
+	}
+	fmt.Fprintf(Stdout, "Verified that the delta rebuilds %s\n", target)
+	return nil
+}
+
+func (x *cmdDebugDeltaApply) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	source, delta, target := string(x.Positionals.Source), string(x.Positionals.Delta), string(x.Positionals.Target)
+
+	if err := squashfsApplyDelta(context.Background(), source, delta, target); err != nil {
+		return fmt.Errorf("cannot apply delta: %v", err)
+	}
+	digest, err := fileSha3_384(target)
+	if err != nil {
+		return err
+	}
+	if x.Sha3_384 != "" && x.Sha3_384 != digest {
+		return fmt.Errorf("rebuilt %s has sha3-384 %s instead of %s", target, digest, x.Sha3_384)
+	}
+	fmt.Fprintf(Stdout, "sha3-384: %s\n", digest)
+	return nil
+}
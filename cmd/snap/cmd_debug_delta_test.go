@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/snap/squashfs"
+)
+
+// sha3-384 of "target"
+const targetSha3_384 = "4c4df05765588425fe32556e4ec64c7e780a417b3007492b50b5ada82f3864a57a6154042cfbe116b756c627d0c2e6e5"
+
+func (s *SnapSuite) mockDeltaFiles(c *C) (source, target string) {
+	d := c.MkDir()
+	source = filepath.Join(d, "foo_1.snap")
+	c.Assert(os.WriteFile(source, []byte("source"), 0644), IsNil)
+	target = filepath.Join(d, "foo_2.snap")
+	c.Assert(os.WriteFile(target, []byte("target"), 0644), IsNil)
+	return source, target
+}
+
+func (s *SnapSuite) TestDebugDeltaCreate(c *C) {
+	source, target := s.mockDeltaFiles(c)
+	delta := filepath.Join(c.MkDir(), "foo.delta")
+
+	restore := snap.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, deltaPath string, format squashfs.DeltaFormat) error {
+		c.Check(sourceSnap, Equals, source)
+		c.Check(targetSnap, Equals, target)
+		c.Check(deltaPath, Equals, delta)
+		c.Check(format, Equals, squashfs.SnapXdelta3Format)
+		return os.WriteFile(deltaPath, []byte("delta"), 0644)
+	})
+	defer restore()
+	restore = snap.MockSquashfsApplyDelta(func(ctx context.Context, sourceSnap, deltaPath, targetSnap string) error {
+		c.Fatalf("unexpected delta application")
+		return nil
+	})
+	defer restore()
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "create", "--format", "snap-1-1-xdelta3", source, target, delta})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Created snap-1-1-xdelta3 delta "+delta+" (5B)\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestDebugDeltaCreateVerify(c *C) {
+	source, target := s.mockDeltaFiles(c)
+	delta := filepath.Join(c.MkDir(), "foo.delta")
+
+	restore := snap.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, deltaPath string, format squashfs.DeltaFormat) error {
+		c.Check(format, Equals, squashfs.Xdelta3Format)
+		return os.WriteFile(deltaPath, []byte("delta"), 0644)
+	})
+	defer restore()
+	rebuilt := "target"
+	restore = snap.MockSquashfsApplyDelta(func(ctx context.Context, sourceSnap, deltaPath, targetSnap string) error {
+		c.Check(sourceSnap, Equals, source)
+		c.Check(deltaPath, Equals, delta)
+		c.Check(filepath.Base(targetSnap), Equals, "foo_2.snap")
+		return os.WriteFile(targetSnap, []byte(rebuilt), 0644)
+	})
+	defer restore()
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "create", "--verify", source, target, delta})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Created xdelta3 delta "+delta+" (5B)\n"+
+		"Verified that the delta rebuilds "+target+"\n")
+
+	s.ResetStdStreams()
+	rebuilt = "not the target"
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "create", "--verify", source, target, delta})
+	c.Assert(err, ErrorMatches, "cannot verify delta: rebuilt target has sha3-384 [0-9a-f]+ instead of "+targetSha3_384)
+}
+
+func (s *SnapSuite) TestDebugDeltaCreateErrors(c *C) {
+	source, target := s.mockDeltaFiles(c)
+	delta := filepath.Join(c.MkDir(), "foo.delta")
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "create", "--format", "bsdiff", source, target, delta})
+	c.Assert(err, ErrorMatches, `unsupported delta format "bsdiff"`)
+
+	restore := snap.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, deltaPath string, format squashfs.DeltaFormat) error {
+		return errors.New("boom")
+	})
+	defer restore()
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "create", source, target, delta})
+	c.Assert(err, ErrorMatches, "cannot generate delta: boom")
+}
+
+func (s *SnapSuite) TestDebugDeltaApply(c *C) {
+	source, _ := s.mockDeltaFiles(c)
+	delta := filepath.Join(c.MkDir(), "foo.delta")
+	c.Assert(os.WriteFile(delta, []byte("delta"), 0644), IsNil)
+	target := filepath.Join(c.MkDir(), "foo_2.snap")
+
+	restore := snap.MockSquashfsApplyDelta(func(ctx context.Context, sourceSnap, deltaPath, targetSnap string) error {
+		c.Check(sourceSnap, Equals, source)
+		c.Check(deltaPath, Equals, delta)
+		c.Check(targetSnap, Equals, target)
+		return os.WriteFile(targetSnap, []byte("target"), 0644)
+	})
+	defer restore()
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "apply", source, delta, target})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "sha3-384: "+targetSha3_384+"\n")
+
+	s.ResetStdStreams()
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "apply", "--sha3-384", targetSha3_384, source, delta, target})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "sha3-384: "+targetSha3_384+"\n")
+
+	s.ResetStdStreams()
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "delta", "apply", "--sha3-384", "abcd", source, delta, target})
+	c.Assert(err, ErrorMatches, "rebuilt "+target+" has sha3-384 "+targetSha3_384+" instead of abcd")
+}
@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugDeviceManagement struct {
+	clientMixin
+	timeMixin
+	unicodeMixin
+
+	Inject string `long:"inject"`
+}
+
+var shortDebugDeviceManagementHelp = i18n.G("Inspect or inject remote device management messages")
+var longDebugDeviceManagementHelp = i18n.G(`
+The device-management command shows the request-messages being processed by
+the device, and the response-messages queued to be sent to the store in the
+next message exchange.
+
+With --inject, the given signed request-message assertion (or standard input
+if "-") is queued for processing as if it had been received from the store.
+`)
+
+func init() {
+	addDebugCommand("device-management", shortDebugDeviceManagementHelp, longDebugDeviceManagementHelp,
+		func() flags.Commander {
+			return &cmdDebugDeviceManagement{}
+		}, timeDescs.also(unicodeDescs).also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"inject": i18n.G("Inject the request-message assertion in the given file"),
+		}), nil)
+}
+
+type deviceMgmtInfo struct {
+	LastExchangeTime  time.Time `json:"last-exchange-time"`
+	LastReceivedToken string    `json:"last-received-token"`
+	Pending           []struct {
+		MessageID   string    `json:"message-id"`
+		AccountID   string    `json:"account-id"`
+		Kind        string    `json:"kind"`
+		ReceiveTime time.Time `json:"receive-time"`
+		ChangeID    string    `json:"change-id"`
+		Error       string    `json:"error"`
+		ErrorStatus string    `json:"error-status"`
+	} `json:"pending"`
+	Responses []struct {
+		MessageID string `json:"message-id"`
+		Status    string `json:"status"`
+	} `json:"responses"`
+}
+
+func (x *cmdDebugDeviceManagement) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if x.Inject != "" {
+		return x.inject()
+	}
+
+	var info deviceMgmtInfo
+	if err := x.client.DebugGet("device-management", &info, nil); err != nil {
+		return err
+	}
+
+	esc := x.getEscapes()
+	w := tabWriter()
+
+	lastExchange := esc.dash
+	if !info.LastExchangeTime.IsZero() {
+		lastExchange = x.fmtTime(info.LastExchangeTime)
+	}
+	lastToken := esc.dash
+	if info.LastReceivedToken != "" {
+		lastToken = info.LastReceivedToken
+	}
+	fmt.Fprintf(w, "last-exchange:\t%s\n", lastExchange)
+	fmt.Fprintf(w, "last-token:\t%s\n", lastToken)
+	w.Flush()
+
+	fmt.Fprintln(Stdout)
+	if len(info.Pending) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No pending request-messages."))
+	} else {
+		fmt.Fprintln(w, i18n.G("Message\tKind\tAccount\tReceived\tStatus"))
+		for _, msg := range info.Pending {
+			status := i18n.G("pending")
+			switch {
+			case msg.Error != "":
+				status = fmt.Sprintf("%s: %s", msg.ErrorStatus, msg.Error)
+			case msg.ChangeID != "":
+				status = fmt.Sprintf(i18n.G("applying in change %s"), msg.ChangeID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", msg.MessageID, msg.Kind, msg.AccountID, x.fmtTime(msg.ReceiveTime), status)
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintln(Stdout)
+	if len(info.Responses) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No queued response-messages."))
+	} else {
+		fmt.Fprintln(w, i18n.G("Response to\tStatus"))
+		for _, resp := range info.Responses {
+			fmt.Fprintf(w, "%s\t%s\n", resp.MessageID, resp.Status)
+		}
+		w.Flush()
+	}
+
+	return nil
+}
+
+func (x *cmdDebugDeviceManagement) inject() error {
+	var data []byte
+	var err error
+	if x.Inject == "-" {
+		data, err = io.ReadAll(Stdin)
+	} else {
+		data, err = os.ReadFile(x.Inject)
+	}
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read request-message: %v"), err)
+	}
+
+	var result struct {
+		MessageID string `json:"message-id"`
+		Change    string `json:"change"`
+	}
+	params := map[string]string{"request-message": string(data)}
+	if err := x.client.Debug("inject-request-message", params, &result); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Message %s is processed in change %s.\n"), result.MessageID, result.Change)
+	return nil
+}
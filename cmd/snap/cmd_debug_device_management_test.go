@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugDeviceManagement(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/debug")
+		c.Check(r.URL.Query().Get("aspect"), Equals, "device-management")
+		fmt.Fprint(w, `{"type": "sync", "result": {
+			"last-exchange-time": "2026-10-01T12:00:00Z",
+			"last-received-token": "token-123",
+			"pending": [
+				{"message-id": "msgA", "account-id": "my-brand", "kind": "snap-install", "receive-time": "2026-10-01T12:00:01Z", "change-id": "42"},
+				{"message-id": "msgB-2", "account-id": "my-brand", "kind": "confdb", "receive-time": "2026-10-01T12:00:02Z"}
+			],
+			"responses": [
+				{"message-id": "msgC", "status": "unauthorized"}
+			]
+		}}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-management", "--abs-time"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, `last-exchange:  2026-10-01T12:00:00Z
+last-token:     token-123
+
+Message  Kind          Account   Received              Status
+msgA     snap-install  my-brand  2026-10-01T12:00:01Z  applying in change 42
+msgB-2   confdb        my-brand  2026-10-01T12:00:02Z  pending
+
+Response to  Status
+msgC         unauthorized
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestDebugDeviceManagementNoMessages(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type": "sync", "result": {"pending": [], "responses": []}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-management", "--unicode=never"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `last-exchange:  --
+last-token:     --
+
+No pending request-messages.
+
+No queued response-messages.
+`)
+}
+
+func (s *SnapSuite) TestDebugDeviceManagementInject(c *C) {
+	assertFile := filepath.Join(c.MkDir(), "message.assert")
+	c.Assert(os.WriteFile(assertFile, []byte("type: request-message\n..."), 0644), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/debug")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]any{
+			"action": "inject-request-message",
+			"params": map[string]any{"request-message": "type: request-message\n..."},
+		})
+		fmt.Fprint(w, `{"type": "sync", "result": {"message-id": "someId", "change": "7"}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-management", "--inject", assertFile})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, "Message someId is processed in change 7.\n")
+}
+
+func (s *SnapSuite) TestDebugDeviceManagementInjectError(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot inject request-message: cannot decode assertion: boom"}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-management", "--inject", "-"})
+	c.Assert(err, ErrorMatches, "cannot inject request-message: cannot decode assertion: boom")
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-management", "--inject", filepath.Join(c.MkDir(), "missing")})
+	c.Assert(err, ErrorMatches, "cannot read request-message: .*no such file or directory")
+}
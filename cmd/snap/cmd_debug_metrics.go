@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugMetrics struct {
+	clientMixin
+}
+
+func init() {
+	addDebugCommand("metrics",
+		i18n.G("Show metrics about the internals of snapd"),
+		i18n.G(`
+The metrics command shows counters, gauges and histograms about the internals
+of snapd, such as the duration of ensure loops, contention on the state lock,
+task retries, store downloads and the outcome of changes, in the OpenMetrics
+text format.
+
+Metrics are only available when the experimental.metrics feature is enabled.
+`),
+		func() flags.Commander {
+			return &cmdDebugMetrics{}
+		}, nil, nil)
+}
+
+func (x *cmdDebugMetrics) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	out, err := x.client.Metrics()
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(Stdout, out)
+	return nil
+}
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugMetrics(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/metrics")
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		fmt.Fprint(w, "# TYPE snapd_tasks gauge\n# HELP snapd_tasks Number of tasks in the state, by status.\nsnapd_tasks{status=\"Done\"} 3\n# EOF\n")
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "metrics"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, "# TYPE snapd_tasks gauge\n# HELP snapd_tasks Number of tasks in the state, by status.\nsnapd_tasks{status=\"Done\"} 3\n# EOF\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDebugMetricsDisabled(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "feature flag \"metrics\" is disabled: set 'experimental.metrics' to true"}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "metrics"})
+	c.Assert(err, check.ErrorMatches, `feature flag "metrics" is disabled: set 'experimental.metrics' to true`)
+}
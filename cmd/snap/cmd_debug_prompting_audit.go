@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugPromptingAudit struct {
+	clientMixin
+	timeMixin
+	UserID    string `long:"user-id"`
+	Snap      string `long:"snap"`
+	Interface string `long:"interface"`
+	Since     string `long:"since"`
+	Limit     int    `long:"limit"`
+}
+
+func init() {
+	addDebugCommand("prompting-audit",
+		i18n.G("Show the audit log of prompting decisions"),
+		i18n.G(`
+The prompting-audit command shows the decisions made for requests from snaps
+when AppArmor prompting is enabled, from oldest to newest: whether each
+requested permission was allowed, denied, or prompted for, what decided it,
+the rules responsible, and the client which replied to the prompt, if any.
+`),
+		func() flags.Commander {
+			return &cmdDebugPromptingAudit{}
+		}, timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"user-id": i18n.G("Only show decisions for requests of the user with the given ID"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"snap": i18n.G("Only show decisions for requests from the given snap"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"interface": i18n.G("Only show decisions for requests through the given interface"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"since": i18n.G("Only show decisions made after the given time (in RFC 3339 format), or within the given duration (such as 24h)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"limit": i18n.G("Only show the given number of most recent decisions"),
+		}), nil)
+}
+
+func fmtAuditList(list []string) string {
+	if len(list) == 0 {
+		return "-"
+	}
+	return strings.Join(list, ",")
+}
+
+func (x *cmdDebugPromptingAudit) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	opts := &client.PromptingAuditOptions{
+		Snap:      x.Snap,
+		Interface: x.Interface,
+		Limit:     x.Limit,
+	}
+	if x.UserID != "" {
+		userID, err := strconv.ParseUint(x.UserID, 10, 32)
+		if err != nil {
+			return fmt.Errorf(i18n.G("invalid user ID %q"), x.UserID)
+		}
+		uid := uint32(userID)
+		opts.User = &uid
+	}
+	if x.Since != "" {
+		since, err := time.Parse(time.RFC3339, x.Since)
+		if err != nil {
+			dur, durErr := time.ParseDuration(x.Since)
+			if durErr != nil {
+				return fmt.Errorf(i18n.G("invalid time or duration %q"), x.Since)
+			}
+			since = timeNow().Add(-dur)
+		}
+		opts.Since = since
+	}
+
+	entries, err := x.client.PromptingAudit(opts)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No prompting decisions recorded."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Time\tUser\tSnap\tInterface\tPath\tAllowed\tDenied\tPrompted\tDecided-by\tRules\tClient"))
+	for _, entry := range entries {
+		path := entry.Path
+		if path == "" {
+			path = "-"
+		}
+		client := entry.Client
+		if client == "" {
+			client = "-"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			x.fmtTime(entry.Timestamp), entry.User, entry.Snap, entry.Interface, path,
+			fmtAuditList(entry.Allowed), fmtAuditList(entry.Denied), fmtAuditList(entry.Outstanding),
+			entry.DecidedBy, fmtAuditList(entry.Rules), client)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugPromptingAudit(c *check.C) {
+	restore := snap.MockTimeNow(func() time.Time {
+		return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	})
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/interfaces/requests/audit")
+		c.Check(r.URL.Query(), check.DeepEquals, url.Values{
+			"user-id": []string{"1000"},
+			"snap":    []string{"firefox"},
+			"since":   []string{"2026-03-01T10:00:00Z"},
+			"limit":   []string{"2"},
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": [{
+			"timestamp": "2026-03-01T10:00:01Z",
+			"user": 1000,
+			"snap": "firefox",
+			"interface": "home",
+			"path": "/home/test/foo",
+			"permissions": ["read", "write"],
+			"allowed": ["read"],
+			"outstanding": ["write"],
+			"decided-by": "prompt",
+			"prompt-id": "0000000000000003",
+			"rules": ["0000000000000002"]
+		}, {
+			"timestamp": "2026-03-01T10:00:02Z",
+			"user": 1000,
+			"snap": "firefox",
+			"interface": "home",
+			"path": "/home/test/foo",
+			"permissions": ["write"],
+			"denied": ["write"],
+			"decided-by": "reply",
+			"prompt-id": "0000000000000003",
+			"client": "snap.prompting-client.daemon pid=1234"
+		}]}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "prompting-audit", "--abs-time", "--user-id=1000", "--snap=firefox", "--since=24h", "--limit=2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, ""+
+		"Time                  User  Snap     Interface  Path            Allowed  Denied  Prompted  Decided-by  Rules             Client\n"+
+		"2026-03-01T10:00:01Z  1000  firefox  home       /home/test/foo  read     -       write     prompt      0000000000000002  -\n"+
+		"2026-03-01T10:00:02Z  1000  firefox  home       /home/test/foo  -        write   -         reply       -                 snap.prompting-client.daemon pid=1234\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDebugPromptingAuditNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("since"), check.Equals, "2026-03-01T10:00:00Z")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "prompting-audit", "--since=2026-03-01T10:00:00Z"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No prompting decisions recorded.\n")
+}
+
+func (s *SnapSuite) TestDebugPromptingAuditErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "prompting-audit", "--user-id=foo"})
+	c.Check(err, check.ErrorMatches, `invalid user ID "foo"`)
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "prompting-audit", "--since=yesterday"})
+	c.Check(err, check.ErrorMatches, `invalid time or duration "yesterday"`)
+}
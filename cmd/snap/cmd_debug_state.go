@@ -22,7 +22,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -76,13 +75,7 @@ func loadState(path string) (*state.State, error) {
 	if path == "" {
 		path = "state.json"
 	}
-	r, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read the state file: %s", err)
-	}
-	defer r.Close()
-
-	return state.ReadState(nil, r)
+	return state.ReadStateFile(nil, path)
 }
 
 func init() {
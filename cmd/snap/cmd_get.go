@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/jessevdk/go-flags"
@@ -64,10 +65,13 @@ Use --with to provide constraints in the form of param=constraint pairs.
 Constraints are parsed as JSON values. If they cannot be interpreted as
 non-null JSON scalars, snap defaults to interpreting values as strings 
 unless -t is also provided.
+Use --history to list the recorded revisions of the confdb, as seen through
+the view.
 `)
 
 type cmdGet struct {
 	mustWaitMixin
+	timeMixin
 	Positional struct {
 		Snap installedSnapName `required:"yes"`
 		Keys []string
@@ -78,6 +82,7 @@ type cmdGet struct {
 	List     bool     `short:"l"`
 	Default  string   `long:"default" unquote:"false"`
 	With     []string `long:"with" value-name:"<param>=<constraint>"`
+	History  bool     `long:"history"`
 }
 
 func init() {
@@ -90,7 +95,7 @@ func init() {
 		// consistent when running hooks (i.e., not run for some but not others)
 		return &cmdGet{mustWaitMixin: mustWaitMixin{skipAbort: true}}
 	},
-		map[string]string{
+		timeDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"d": i18n.G("Always return document, even with single key"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -101,7 +106,9 @@ func init() {
 			"default": i18n.G("A strictly typed default value to be used when none is found"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"with": i18n.G("Parameter constraints for filtering confdb queries"),
-		}, []argDesc{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"history": i18n.G("List the recorded revisions of the confdb"),
+		}), []argDesc{
 			{
 				name: "<snap>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -272,6 +279,10 @@ func (x *cmdGet) Execute(args []string) error {
 	snapName := string(x.Positional.Snap)
 	confKeys := x.Positional.Keys
 
+	if x.History {
+		return x.confdbHistory(snapName, confKeys)
+	}
+
 	var conf map[string]any
 	var err error
 	if isConfdbViewID(snapName) {
@@ -363,6 +374,74 @@ func (x *cmdGet) getConfdb(confdbViewID string, confKeys []string) (map[string]a
 	return conf, nil
 }
 
+func (x *cmdGet) confdbHistory(confdbViewID string, confKeys []string) error {
+	if !isConfdbViewID(confdbViewID) {
+		return fmt.Errorf(`cannot use --history in non-confdb read`)
+	}
+
+	if x.Default != "" || x.Document || x.List {
+		return fmt.Errorf("cannot use --history with --default, -d or -l")
+	}
+
+	if err := validateConfdbFeatureFlag(); err != nil {
+		return err
+	}
+
+	if err := validateConfdbViewID(confdbViewID); err != nil {
+		return err
+	}
+
+	opts := clientutil.ConfdbOptions{Typed: x.Typed}
+	constraints, err := clientutil.ParseConfdbConstraints(x.With, opts)
+	if err != nil {
+		return err
+	}
+
+	revs, err := x.client.ConfdbHistoryViaView(confdbViewID, confKeys, constraints)
+	if err != nil {
+		return err
+	}
+
+	if len(revs) == 0 {
+		fmt.Fprintf(Stderr, i18n.G("No recorded revisions for %s.\n"), confdbViewID)
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Rev\tTime\tSnap\tUser\tValues\tNotes"))
+	for _, rev := range revs {
+		snapName := rev.Snap
+		if snapName == "" {
+			snapName = "-"
+		}
+
+		user := "-"
+		if rev.UserID != nil {
+			user = strconv.Itoa(*rev.UserID)
+		}
+
+		values := "-"
+		if rev.Values != nil {
+			data, err := json.Marshal(rev.Values)
+			if err != nil {
+				return err
+			}
+			values = string(data)
+		}
+
+		notes := "-"
+		if rev.RollbackOf != 0 {
+			notes = fmt.Sprintf(i18n.G("rollback of %d"), rev.RollbackOf)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rev.Revision, x.fmtTime(rev.Time), snapName, user, values, notes)
+	}
+
+	return nil
+}
+
 func (x *cmdGet) buildDefaultOutput(request string) (map[string]any, error) {
 	var defaultVal any
 	if err := jsonutil.DecodeWithNumber(strings.NewReader(x.Default), &defaultVal); err != nil {
@@ -1070,3 +1070,83 @@ func (s *confdbSuite) TestForbidWithWithNonConfdbGet(c *check.C) {
 	c.Check(s.Stdout(), Equals, "")
 	c.Check(s.Stderr(), Equals, "")
 }
+
+func (s *confdbSuite) TestConfdbGetHistory(c *check.C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	var reqs int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch reqs {
+		case 0:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/confdb/foo/bar/baz")
+
+			q := r.URL.Query()
+			c.Check(q.Get("keys"), Equals, "abc")
+			c.Check(q.Get("constraints"), Equals, `{"param":"value"}`)
+			c.Check(q.Get("history"), Equals, "true")
+
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [
+				{"revision": 1, "time": "2026-10-01T12:00:00Z", "view": "foo/bar/other"},
+				{"revision": 2, "time": "2026-10-01T13:00:00Z", "view": "foo/bar/baz", "snap": "some-snap", "user-id": 0, "values": {"abc": "cba"}},
+				{"revision": 3, "time": "2026-10-01T14:00:00Z", "view": "foo/bar/baz", "user-id": 1000, "rollback-of": 1}
+			]}`)
+		default:
+			err := fmt.Errorf("expected to get 1 request, now on %d (%v)", reqs+1, r)
+			w.WriteHeader(500)
+			fmt.Fprintf(w, `{"type": "error", "result": {"message": %q}}`, err)
+			c.Error(err)
+		}
+
+		reqs++
+	})
+
+	rest, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--history", "--abs-time", "--with", "param=value", "foo/bar/baz", "abc"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, `Rev  Time                  Snap       User  Values         Notes
+1    2026-10-01T12:00:00Z  -          -     -              -
+2    2026-10-01T13:00:00Z  some-snap  0     {"abc":"cba"}  -
+3    2026-10-01T14:00:00Z  -          1000  -              rollback of 1
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *confdbSuite) TestConfdbGetHistoryEmpty(c *check.C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/confdb/foo/bar/baz")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	rest, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--history", "foo/bar/baz"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No recorded revisions for foo/bar/baz.\n")
+}
+
+func (s *confdbSuite) TestConfdbGetHistoryErrors(c *check.C) {
+	restore := s.mockConfdbFlag(c)
+	defer restore()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %v", r)
+	})
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"get", "--history", "some-snap"}, "cannot use --history in non-confdb read"},
+		{[]string{"get", "--history", "-d", "foo/bar/baz"}, "cannot use --history with --default, -d or -l"},
+		{[]string{"get", "--history", "--default", "1", "foo/bar/baz"}, "cannot use --history with --default, -d or -l"},
+		{[]string{"get", "--history", "foo//baz"}, "confdb-schema view id must conform to format: <account-id>/<confdb-schema>/<view>"},
+	} {
+		_, err := snapset.Parser(snapset.Client()).ParseArgs(tc.args)
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.args))
+	}
+}
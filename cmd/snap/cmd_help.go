@@ -206,9 +206,10 @@ var helpCategories = []helpCategory{
 		Description: i18n.G("basic snap management"),
 		Commands:    []string{"find", "info", "install", "remove", "list", "components", "component"},
 	}, {
-		Label:       i18n.G("...more"),
-		Description: i18n.G("slightly more advanced snap management"),
-		Commands:    []string{"refresh", "revert", "switch", "disable", "enable", "create-cohort"},
+		Label:           i18n.G("...more"),
+		Description:     i18n.G("slightly more advanced snap management"),
+		Commands:        []string{"refresh", "revert", "switch", "disable", "enable", "create-cohort"},
+		AllOnlyCommands: []string{"export-update-pack", "apply-update-pack"},
 	}, {
 		Label:       i18n.G("History"),
 		Description: i18n.G("manage system change transactions"),
@@ -218,13 +219,15 @@ var helpCategories = []helpCategory{
 		Description: i18n.G("manage services"),
 		Commands:    []string{"services", "start", "stop", "restart", "logs"},
 	}, {
-		Label:       i18n.G("Permissions"),
-		Description: i18n.G("manage permissions"),
-		Commands:    []string{"connections", "interface", "connect", "disconnect"},
+		Label:           i18n.G("Permissions"),
+		Description:     i18n.G("manage permissions"),
+		Commands:        []string{"connections", "interface", "connect", "disconnect"},
+		AllOnlyCommands: []string{"prompting-rules", "import-prompting-rules"},
 	}, {
-		Label:       i18n.G("Configuration"),
-		Description: i18n.G("system administration and configuration"),
-		Commands:    []string{"get", "set", "unset", "wait"},
+		Label:           i18n.G("Configuration"),
+		Description:     i18n.G("system administration and configuration"),
+		Commands:        []string{"get", "set", "unset", "wait"},
+		AllOnlyCommands: []string{"confdb"},
 	}, {
 		Label:       i18n.G("App Aliases"),
 		Description: i18n.G("manage aliases"),
@@ -237,7 +240,7 @@ var helpCategories = []helpCategory{
 		Label:           i18n.G("Snapshots"),
 		Description:     i18n.G("archives of snap data"),
 		Commands:        []string{"saved", "save", "check-snapshot", "restore", "forget"},
-		AllOnlyCommands: []string{"export-snapshot", "import-snapshot"},
+		AllOnlyCommands: []string{"export-snapshot", "import-snapshot", "snapshot-target"},
 	}, {
 		Label:       i18n.G("Issue reporting"),
 		Description: i18n.G("report issues with a specific snap"),
@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdPromptingRules struct {
+	clientMixin
+	Snap      string `long:"snap"`
+	Interface string `long:"interface"`
+	Export    bool   `long:"export"`
+}
+
+type cmdImportPromptingRules struct {
+	clientMixin
+	Positional struct {
+		File flags.Filename `positional-arg-name:"<file>"`
+	} `positional-args:"true" required:"true"`
+}
+
+var shortPromptingRulesHelp = i18n.G("List prompting rules")
+var longPromptingRulesHelp = i18n.G(`
+The prompting-rules command lists the rules which decide the outcome of
+requests from snaps when AppArmor prompting is enabled.
+
+Rules defined by the administrator of the system or by the gadget snap apply to
+every user and are listed first. They take precedence over the rules of the
+user: when an admin rule matches a request, the rules of the user are not
+considered.
+
+With --export, the rules of the user are written to standard output in a form
+which can be imported again with 'snap import-prompting-rules', or installed as
+admin rules in /etc/snapd/interfaces-requests/rules.d/. Only permissions which
+last forever are exported.
+`)
+
+var shortImportPromptingRulesHelp = i18n.G("Import prompting rules")
+var longImportPromptingRulesHelp = i18n.G(`
+The import-prompting-rules command adds the rules from the given file, as
+written by 'snap prompting-rules --export', to the prompting rules of the user.
+
+Either all of the rules are imported, or none of them.
+`)
+
+func init() {
+	addCommand("prompting-rules", shortPromptingRulesHelp, longPromptingRulesHelp, func() flags.Commander {
+		return &cmdPromptingRules{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"snap": i18n.G("Only list rules for the given snap"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"interface": i18n.G("Only list rules for the given interface"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"export": i18n.G("Write the rules of the user in a form which can be imported"),
+	}, nil)
+	addCommand("import-prompting-rules", shortImportPromptingRulesHelp, longImportPromptingRulesHelp, func() flags.Commander {
+		return &cmdImportPromptingRules{}
+	}, nil, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("File with the rules to import"),
+	}})
+}
+
+// promptingRulesFile is the format in which rules are exported and imported,
+// which is also the format of admin rules files.
+type promptingRulesFile struct {
+	Rules []*client.PromptingRuleContents `json:"rules"`
+}
+
+func fmtPromptingPermissions(constraints *client.PromptingConstraints) string {
+	if constraints == nil || len(constraints.Permissions) == 0 {
+		return "-"
+	}
+	perms := make([]string, 0, len(constraints.Permissions))
+	for perm, entry := range constraints.Permissions {
+		s := fmt.Sprintf("%s:%s", perm, entry.Outcome)
+		if entry.Lifespan != "forever" {
+			s += fmt.Sprintf("(%s)", entry.Lifespan)
+		}
+		perms = append(perms, s)
+	}
+	sort.Strings(perms)
+	return strings.Join(perms, ",")
+}
+
+func fmtPromptingPathPattern(constraints *client.PromptingConstraints) string {
+	if constraints == nil || constraints.PathPattern == "" {
+		return "-"
+	}
+	return constraints.PathPattern
+}
+
+func (x *cmdPromptingRules) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	opts := &client.PromptingRulesOptions{
+		Snap:      x.Snap,
+		Interface: x.Interface,
+	}
+
+	if x.Export {
+		rules, err := x.client.ExportPromptingRules(opts)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(promptingRulesFile{Rules: rules})
+	}
+
+	adminRules, err := x.client.PromptingAdminRules(opts)
+	if err != nil {
+		return err
+	}
+	rules, err := x.client.PromptingRules(opts)
+	if err != nil {
+		return err
+	}
+	if len(adminRules) == 0 && len(rules) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No prompting rules."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("ID\tSource\tSnap\tInterface\tPath-pattern\tPermissions"))
+	for _, rule := range adminRules {
+		snap := rule.Snap
+		if snap == "" {
+			snap = "*"
+		}
+		fmt.Fprintf(w, "-\t%s\t%s\t%s\t%s\t%s\n", rule.Source, snap, rule.Interface,
+			fmtPromptingPathPattern(rule.Constraints), fmtPromptingPermissions(rule.Constraints))
+	}
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%s\tuser\t%s\t%s\t%s\t%s\n", rule.ID, rule.Snap, rule.Interface,
+			fmtPromptingPathPattern(rule.Constraints), fmtPromptingPermissions(rule.Constraints))
+	}
+	return nil
+}
+
+func (x *cmdImportPromptingRules) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	f, err := os.Open(string(x.Positional.File))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var rulesFile promptingRulesFile
+	if err := json.NewDecoder(f).Decode(&rulesFile); err != nil {
+		return fmt.Errorf(i18n.G("cannot read prompting rules from %q: %v"), x.Positional.File, err)
+	}
+	if len(rulesFile.Rules) == 0 {
+		return fmt.Errorf(i18n.G("no prompting rules found in %q"), x.Positional.File)
+	}
+
+	imported, err := x.client.ImportPromptingRules(rulesFile.Rules)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, i18n.NG("Imported %d prompting rule.\n", "Imported %d prompting rules.\n", len(imported)), len(imported))
+	return nil
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const mockPromptingRuleContentsJSON = `{
+	"snap": "firefox",
+	"interface": "home",
+	"constraints": {
+		"path-pattern": "/home/test/Downloads/**",
+		"permissions": {"read": {"outcome": "allow", "lifespan": "forever"}}
+	}
+}`
+
+func (s *SnapSuite) TestPromptingRules(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Query().Get("interface"), check.Equals, "home")
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/interfaces/requests/admin-rules")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{
+				"interface": "home",
+				"constraints": {
+					"path-pattern": "/home/*/.ssh/**",
+					"permissions": {"read": {"outcome": "deny", "lifespan": "forever"}, "write": {"outcome": "deny", "lifespan": "forever"}}
+				},
+				"source": "admin:corp.json"
+			}]}`)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{
+				"id": "0000000000000002",
+				"snap": "firefox",
+				"interface": "home",
+				"constraints": {
+					"path-pattern": "/home/test/Downloads/**",
+					"permissions": {"read": {"outcome": "allow", "lifespan": "session"}}
+				}
+			}]}`)
+		default:
+			c.Errorf("expected 2 queries, currently on %d", n)
+		}
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prompting-rules", "--interface=home"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, ""+
+		"ID                Source           Snap     Interface  Path-pattern             Permissions\n"+
+		"-                 admin:corp.json  *        home       /home/*/.ssh/**          read:deny,write:deny\n"+
+		"0000000000000002  user             firefox  home       /home/test/Downloads/**  read:allow(session)\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *SnapSuite) TestPromptingRulesNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"prompting-rules"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No prompting rules.\n")
+}
+
+func (s *SnapSuite) TestPromptingRulesExport(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+		c.Check(r.URL.Query().Get("export"), check.Equals, "true")
+		fmt.Fprintf(w, `{"type": "sync", "result": [%s]}`, mockPromptingRuleContentsJSON)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"prompting-rules", "--export"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `{
+  "rules": [
+    {
+      "snap": "firefox",
+      "interface": "home",
+      "constraints": {
+        "path-pattern": "/home/test/Downloads/**",
+        "permissions": {
+          "read": {
+            "outcome": "allow",
+            "lifespan": "forever"
+          }
+        }
+      }
+    }
+  ]
+}
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestImportPromptingRules(c *check.C) {
+	path := filepath.Join(c.MkDir(), "rules.json")
+	c.Assert(os.WriteFile(path, []byte(fmt.Sprintf(`{"rules": [%s]}`, mockPromptingRuleContentsJSON)), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/interfaces/requests/rules")
+		body := DecodedRequestBody(c, r)
+		c.Check(body["action"], check.Equals, "import")
+		c.Check(body["rules"], check.HasLen, 1)
+		fmt.Fprintln(w, `{"type": "sync", "result": [{"id": "0000000000000003", "snap": "firefox", "interface": "home"}]}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"import-prompting-rules", path})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Imported 1 prompting rule.\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestImportPromptingRulesEmpty(c *check.C) {
+	path := filepath.Join(c.MkDir(), "rules.json")
+	c.Assert(os.WriteFile(path, []byte(`{"rules": []}`), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"import-prompting-rules", path})
+	c.Assert(err, check.ErrorMatches, `no prompting rules found in ".*/rules.json"`)
+}
@@ -976,6 +976,7 @@ type cmdRefresh struct {
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	Hold             string                 `long:"hold" optional:"yes" optional-value:"forever"`
 	Unhold           bool                   `long:"unhold"`
+	DryRun           bool                   `long:"dry-run"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -1096,9 +1097,42 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		fmt.Fprintf(Stdout, "next: n/a\n")
 	}
+	if rollout := sysinfo.Refresh.Rollout; rollout != nil {
+		x.showRolloutStatus(rollout)
+	}
+	if maintenance := sysinfo.MaintenanceWindows; maintenance != nil {
+		x.showMaintenanceWindows(maintenance)
+	}
 	return nil
 }
 
+func (x *cmdRefresh) showMaintenanceWindows(maintenance *client.MaintenanceWindowsInfo) {
+	if maintenance.Windows != "" {
+		fmt.Fprintf(Stdout, "maintenance-windows: %s\n", maintenance.Windows)
+	}
+	if next := parseSysinfoTime(maintenance.Next); !next.IsZero() {
+		fmt.Fprintf(Stdout, "maintenance-next: %s\n", x.fmtTime(next))
+	}
+	if maintenance.PendingReboot != nil {
+		requested := parseSysinfoTime(maintenance.PendingReboot.RequestedAt)
+		fmt.Fprintf(Stdout, "pending-reboot: requested %s\n", x.fmtTime(requested))
+	}
+}
+
+func (x *cmdRefresh) showRolloutStatus(rollout *client.RolloutInfo) {
+	fmt.Fprintf(Stdout, "rollout-bucket: %d\n", rollout.Bucket)
+	if rollout.Soak != "" {
+		fmt.Fprintf(Stdout, "rollout-delay: %s (of %s)\n", rollout.Delay, rollout.Soak)
+	}
+	if len(rollout.Held) > 0 {
+		fmt.Fprintf(Stdout, "rollout-held: unhealthy %s\n", strings.Join(rollout.Held, ", "))
+	}
+	for _, p := range rollout.Pending {
+		due := parseSysinfoTime(p.Due)
+		fmt.Fprintf(Stdout, "rollout-pending: %s (%s) until %s\n", p.Snap, p.Revision, x.fmtTime(due))
+	}
+}
+
 func (x *cmdRefresh) listRefresh() error {
 	snaps, _, err := x.client.Find(&client.FindOptions{
 		Refresh: true,
@@ -1126,6 +1160,62 @@ func (x *cmdRefresh) listRefresh() error {
 	return nil
 }
 
+func (x *cmdRefresh) showRefreshPlan(names []string, opts *client.SnapOptions) error {
+	plan, err := x.client.RefreshManyDryRun(names, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, conflict := range plan.Conflicts {
+		fmt.Fprintf(Stderr, i18n.G("Conflict: %s\n"), conflict.Message)
+	}
+	if len(plan.Snaps) == 0 {
+		if len(plan.Conflicts) == 0 {
+			fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		}
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Name\tRev\tNew\tTracking\tSize\tNotes"))
+	for _, ps := range plan.Snaps {
+		var notes []string
+		if ps.RebootRequired {
+			notes = append(notes, "reboot")
+		}
+		if ps.AutoConnect {
+			notes = append(notes, "auto-connect")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", ps.Name, ps.CurrentRevision, ps.Revision, fmtChannel(ps.Channel), fmtSize(ps.DownloadSize), strings.Join(notes, ","))
+		for _, comp := range ps.Components {
+			current := "-"
+			if !comp.CurrentRevision.Unset() {
+				current = comp.CurrentRevision.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", ps.Name+"+"+comp.Name, current, comp.Revision, "-", fmtSize(comp.DownloadSize), "component")
+		}
+	}
+	w.Flush()
+
+	var missing []string
+	for _, ps := range plan.Snaps {
+		for _, prereq := range ps.Prerequisites {
+			if !prereq.Installed && !strutil.ListContains(missing, prereq.Name) {
+				missing = append(missing, prereq.Name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(Stdout, i18n.G("Prerequisites to install: %s\n"), strings.Join(missing, ", "))
+	}
+	fmt.Fprintf(Stdout, i18n.G("Download size: %s\n"), fmtSize(plan.DownloadSize))
+	if plan.RebootRequired {
+		fmt.Fprintln(Stdout, i18n.G("A reboot will be required to complete the refresh."))
+	}
+
+	return nil
+}
+
 func (x *cmdRefresh) Execute([]string) error {
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
@@ -1156,7 +1246,7 @@ func (x *cmdRefresh) Execute([]string) error {
 
 	otherFlags := x.Amend || x.Revision != "" || x.Cohort != "" ||
 		x.LeaveCohort || x.List || x.Time || x.IgnoreValidation || x.IgnoreRunning ||
-		x.Transaction != client.TransactionPerSnap
+		x.Transaction != client.TransactionPerSnap || x.DryRun
 
 	switch {
 	case x.Tracking:
@@ -1177,6 +1267,16 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 
 	names := installedSnapNames(x.Positional.Snaps)
+	if x.DryRun {
+		if x.Amend || x.Revision != "" || x.Cohort != "" || x.LeaveCohort || x.IgnoreValidation || x.asksForMode() || x.asksForChannel() {
+			return errors.New(i18n.G("--dry-run cannot be used with revision, channel, cohort, mode or validation flags"))
+		}
+		opts := &client.SnapOptions{
+			IgnoreRunning: x.IgnoreRunning,
+			Transaction:   x.Transaction,
+		}
+		return x.showRefreshPlan(names, opts)
+	}
 	if len(names) == 1 {
 		opts := &client.SnapOptions{
 			Amend:            x.Amend,
@@ -1664,6 +1764,8 @@ func init() {
 			"hold": i18n.G("Hold refreshes for a specified duration (or forever, if no value is specified)"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"unhold": i18n.G("Remove refresh hold"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what a refresh would do without performing it"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
@@ -1849,6 +1849,62 @@ foo +4.2update1 +17 +436MB +bar +-.*
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshDryRun(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]any{
+				"action":      "refresh",
+				"snaps":       []any{"foo", "bar"},
+				"transaction": "per-snap",
+				"dry-run":     true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+"snaps": [{"name": "foo", "type": "kernel", "channel": "latest/stable", "current-revision": "1", "revision": "2", "download-size": 2000000, "reboot-required": true,
+           "prerequisites": [{"name": "core22", "installed": true}]},
+          {"name": "bar", "type": "app", "current-revision": "3", "revision": "4", "download-size": 1000000, "auto-connect": true,
+           "components": [{"name": "comp", "current-revision": "1", "revision": "2", "download-size": 500000}],
+           "prerequisites": [{"name": "gtk-common-themes", "content": ["gtk-3-themes"]}]}],
+"conflicts": [{"snap": "baz", "change-kind": "install", "change-id": "7", "message": "snap \"baz\" has \"install\" change in progress"}],
+"download-size": 3500000,
+"reboot-required": true}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `Name +Rev +New +Tracking +Size +Notes
+foo +1 +2 +latest/stable +2.00MB +reboot
+bar +3 +4 +- +1.00MB +auto-connect
+bar\+comp +1 +2 +- +500kB +component
+Prerequisites to install: gtk-common-themes
+Download size: 3.50MB
+A reboot will be required to complete the refresh.
+`)
+	c.Check(s.Stderr(), check.Equals, "Conflict: snap \"baz\" has \"install\" change in progress\n")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshDryRunUnsupportedFlags(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatal("expected to get 0 requests")
+	})
+
+	for _, flag := range []string{"--beta", "--revision=2", "--amend", "--classic", "--ignore-validation"} {
+		_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", flag, "foo"})
+		c.Check(err, check.ErrorMatches, "--dry-run cannot be used with revision, channel, cohort, mode or validation flags")
+	}
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "--hold"})
+	c.Check(err, check.ErrorMatches, "cannot use --hold with other flags")
+}
+
 func mockTrackingResponse(w io.Writer, snaps map[string]string) {
 	type snapResult struct {
 		Name    string `json:"name"`
@@ -2081,6 +2137,65 @@ next: 2017-04-26T00:58:00+02:00
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshTimeShowsRollout(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "0:00-24:00/4", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00", "rollout": {"bucket": 79, "soak": "100h0m0s", "delay": "79h0m0s", "held": ["foo", "bar"], "pending": [{"snap": "some-snap", "revision": "12", "due": "2017-04-28T07:35:00+02:00"}]}}}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `timer: 0:00-24:00/4
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+rollout-bucket: 79
+rollout-delay: 79h0m0s (of 100h0m0s)
+rollout-held: unhealthy foo, bar
+rollout-pending: some-snap (12) until 2017-04-28T07:35:00+02:00
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshTimeShowsMaintenanceWindows(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "0:00-24:00/4", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00"}, "maintenance-windows": {"windows": "mon-fri,02:00-04:00", "next": "2017-04-27T02:00:00+02:00", "pending-reboot": {"requested-at": "2017-04-26T01:10:00+02:00"}}}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `timer: 0:00-24:00/4
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+maintenance-windows: mon-fri,02:00-04:00
+maintenance-next: 2017-04-27T02:00:00+02:00
+pending-reboot: requested 2017-04-26T01:10:00+02:00
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *SnapSuite) TestRefreshTimeShowsHolds(c *check.C) {
 	type testcase struct {
 		in  string
@@ -20,6 +20,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -47,6 +49,7 @@ var (
 	shortRestoreHelp        = i18n.G("Restore a snapshot")
 	shortExportSnapshotHelp = i18n.G("Export a snapshot")
 	shortImportSnapshotHelp = i18n.G("Import a snapshot")
+	shortSnapshotTargetHelp = i18n.G("List or fetch snapshots on the snapshot target")
 )
 
 var longSavedHelp = i18n.G(`
@@ -64,6 +67,13 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+With --encrypt, the snapshot archives are encrypted and authenticated. The
+key is protected with a passphrase that is asked for, and that is needed
+again to check or restore the snapshot. With --encrypt=device, the key is
+instead sealed by the TPM of the device, which is only possible on systems
+using TPM-backed full disk encryption; such snapshots can only be checked or
+restored on the same device.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -80,7 +90,8 @@ The check-snapshot command verifies the user, system and configuration
 data of the snaps included in the specified snapshot.
 
 The check operation runs the same data integrity verification that is
-performed when a snapshot is restored.
+performed when a snapshot is restored. For encrypted snapshots this includes
+authenticating their archives, so the passphrase is asked for if needed.
 
 By default, this command checks all the data in a snapshot.
 Alternatively, you can specify the data of which snaps to check, or
@@ -102,6 +113,8 @@ for which users, or a combination of these.
 If a snap is included in a restore operation, excluding its system and
 configuration data from the restore is not currently possible. This
 restriction may be lifted in the future.
+
+The passphrase of snapshots encrypted with one is asked for.
 `)
 
 var longExportSnapshotHelp = i18n.G(`
@@ -113,6 +126,19 @@ Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
 `)
 
+var longSnapshotTargetHelp = i18n.G(`
+The snapshot-target command lists the snapshots stored on the configured
+snapshot target.
+
+When a snapshot target is configured with the snapshots.target.* system
+options, snapshots created with the save command are offloaded to it once
+they have been saved locally.
+
+With --fetch, the given snapshot is downloaded from the target and imported
+with a new snapshot ID, after which it can be restored using the restore
+command.
+`)
+
 type savedCmd struct {
 	clientMixin
 	durationMixin
@@ -162,6 +188,9 @@ func (x *savedCmd) Execute([]string) error {
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Encryption != nil {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -177,10 +206,58 @@ func (x *savedCmd) Execute([]string) error {
 	return nil
 }
 
+// readSnapshotPassphrase asks for the passphrase of an encrypted snapshot
+// set, twice when it is a new one.
+func readSnapshotPassphrase(confirm bool) (string, error) {
+	fmt.Fprint(Stdout, i18n.G("Snapshot passphrase: "))
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	// strings.TrimSpace needed because we get \r from the pty in the tests
+	pass := strings.TrimSpace(string(passphrase))
+	if pass == "" {
+		return "", errors.New(i18n.G("passphrase cannot be empty"))
+	}
+	if !confirm {
+		return pass, nil
+	}
+
+	fmt.Fprint(Stdout, i18n.G("Repeat snapshot passphrase: "))
+	again, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(again)) != pass {
+		return "", errors.New(i18n.G("passphrases do not match"))
+	}
+	return pass, nil
+}
+
+// snapshotPassphraseIfNeeded asks for the passphrase of the given snapshot
+// set if any of the given snaps' snapshots in it are protected by one.
+func snapshotPassphraseIfNeeded(cli *client.Client, setID uint64, snaps []string) (string, error) {
+	sets, err := cli.SnapshotSets(setID, snaps)
+	if err != nil {
+		return "", err
+	}
+	for _, set := range sets {
+		for _, sh := range set.Snapshots {
+			if sh.Encryption != nil && sh.Encryption.KeyProtection == "passphrase" {
+				return readSnapshotPassphrase(false)
+			}
+		}
+	}
+	return "", nil
+}
+
 type saveCmd struct {
 	waitMixin
 	durationMixin
 	Users      string `long:"users"`
+	Encrypt    string `long:"encrypt" optional:"yes" optional-value:"passphrase" choice:"passphrase" choice:"device"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -189,7 +266,21 @@ type saveCmd struct {
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	var setID uint64
+	var changeID string
+	var err error
+	if x.Encrypt != "" {
+		opts := &client.SnapshotEncryptOptions{KeyProtection: x.Encrypt}
+		if x.Encrypt == "passphrase" {
+			opts.Passphrase, err = readSnapshotPassphrase(true)
+			if err != nil {
+				return err
+			}
+		}
+		setID, changeID, err = x.client.SnapshotManyEncrypted(snaps, users, opts)
+	} else {
+		setID, changeID, err = x.client.SnapshotMany(snaps, users)
+	}
 	if err != nil {
 		return err
 	}
@@ -259,7 +350,11 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.CheckSnapshots(setID, snaps, users)
+	passphrase, err := snapshotPassphraseIfNeeded(x.client, setID, snaps)
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.CheckSnapshotsWithPassphrase(setID, snaps, users, passphrase)
 	if err != nil {
 		return err
 	}
@@ -298,7 +393,11 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
+	passphrase, err := snapshotPassphraseIfNeeded(x.client, setID, snaps)
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.RestoreSnapshotsWithPassphrase(setID, snaps, users, passphrase)
 	if err != nil {
 		return err
 	}
@@ -342,6 +441,8 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot, with the key protected by a passphrase (default) or by the device"),
 		}), nil)
 
 	addCommand("restore",
@@ -420,6 +521,16 @@ func init() {
 			},
 		})
 
+	addCommand("snapshot-target",
+		shortSnapshotTargetHelp,
+		longSnapshotTargetHelp,
+		func() flags.Commander {
+			return &snapshotTargetCmd{}
+		}, durationDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"fetch": i18n.G("Download and import the named snapshot from the target"),
+		}), nil)
+
 	addCommand("import-snapshot",
 		shortImportSnapshotHelp,
 		longImportSnapshotHelp,
@@ -523,3 +634,56 @@ func (x *importSnapshotCmd) Execute([]string) error {
 	}
 	return y.Execute(nil)
 }
+
+type snapshotTargetCmd struct {
+	clientMixin
+	durationMixin
+	Fetch string `long:"fetch" value-name:"<name>"`
+}
+
+func (x *snapshotTargetCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if x.Fetch != "" {
+		importSet, err := x.client.FetchSnapshot(x.Fetch)
+		if err != nil {
+			return err
+		}
+		// TRANSLATORS: the first argument is the name of the snapshot on the target, the second one its new identifier.
+		fmt.Fprintf(Stdout, i18n.G("Fetched snapshot %q as #%d\n"), x.Fetch, importSet.ID)
+		y := &savedCmd{
+			clientMixin:   x.clientMixin,
+			durationMixin: x.durationMixin,
+			ID:            snapshotID(strconv.FormatUint(importSet.ID, 10)),
+		}
+		return y.Execute(nil)
+	}
+
+	target, err := x.client.SnapshotTarget()
+	if err != nil {
+		return err
+	}
+	// TRANSLATORS: the first argument is the type of the target (s3, ssh), the second one its URL.
+	fmt.Fprintf(Stdout, i18n.G("Target: %s %s\n"), target.Type, target.URL)
+	if len(target.Snapshots) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No snapshots found."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		i18n.G("Name"),
+		i18n.G("Host"),
+		// TRANSLATORS: 'Set' as in group or bag of things
+		i18n.G("Set"),
+		// TRANSLATORS: 'Age' as in how old something is
+		i18n.G("Age"))
+	for _, sh := range target.Snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", sh.Name, sh.Host, sh.SetID, x.fmtDuration(sh.Time))
+	}
+	return nil
+}
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -167,3 +168,147 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) mockSnapshotTargetServer(c *C, snapshots string) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots/target":
+			if r.Method == "GET" {
+				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":{"type":"ssh","url":"ssh://backup@host/srv/snapshots","snapshots":%s}}`, snapshots)
+				return
+			}
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]any{
+				"action": "fetch",
+				"name":   "box_20260102T030405Z_7.snapshot",
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {"set-id": 42, "snaps": ["htop"]}}`)
+		case "/v2/snapshots":
+			c.Check(r.URL.Query().Get("set"), Equals, "42")
+			snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+			fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":42,"snapshots":[{"set":42,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+}
+
+func (s *SnapSuite) TestSnapshotTargetList(c *C) {
+	s.mockSnapshotTargetServer(c, `[{"name":"box_20260102T030405Z_7.snapshot","host":"box","set":7,"time":"2026-01-02T03:04:05Z"}]`)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot-target", "--abs-time"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(s.Stdout(), Equals, `Target: ssh ssh://backup@host/srv/snapshots
+Name                             Host  Set  Age
+box_20260102T030405Z_7.snapshot  box   7    2026-01-02T03:04:05Z
+`)
+}
+
+func (s *SnapSuite) TestSnapshotTargetListEmpty(c *C) {
+	s.mockSnapshotTargetServer(c, `[]`)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot-target"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Target: ssh ssh://backup@host/srv/snapshots\nNo snapshots found.\n")
+}
+
+func (s *SnapSuite) TestSnapshotTargetFetch(c *C) {
+	s.mockSnapshotTargetServer(c, `[]`)
+
+	expectedAge := time.Since(time.Now().AddDate(0, -1, 0))
+	ageStr := quantity.FormatDuration(expectedAge.Seconds())
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot-target", "--fetch", "box_20260102T030405Z_7.snapshot"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(s.Stdout(), testutil.MatchesWrapped, fmt.Sprintf(`Fetched snapshot "box_20260102T030405Z_7.snapshot" as #42
+Set  Snap  Age    Version  Rev   Size    Notes
+42   htop  %-6s 2        1168      1B  -
+`, ageStr))
+}
+
+func (s *SnapSuite) TestSnapshotTargetExtraArgs(c *C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot-target", "foo"})
+	c.Assert(err, Equals, main.ErrExtraArgs)
+}
+
+func (s *SnapSuite) mockEncryptedSnapshotServer(c *C, expected map[string]any) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, expected)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 7}}`)
+		case "/v2/snapshots":
+			if r.Method == "GET" {
+				c.Check(r.URL.Query().Get("set"), Equals, "7")
+				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":7,"snapshots":[{"set":7,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1,"encryption":{"cipher":"aes-256-gcm","key-protection":"passphrase"}}]}]}`, snapshotTime)
+				return
+			}
+			c.Check(DecodedRequestBody(c, r), DeepEquals, expected)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedPassphrase(c *C) {
+	s.mockEncryptedSnapshotServer(c, map[string]any{
+		"action":              "snapshot",
+		"snaps":               []any{"htop"},
+		"snapshot-encrypt":    "passphrase",
+		"snapshot-passphrase": "s3kr1t",
+	})
+	s.password = "s3kr1t"
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `(?s)Snapshot passphrase: \nRepeat snapshot passphrase: \nSet  Snap  Age    Version  Rev   Size    Notes\n7    htop  .*encrypted\n`)
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedDevice(c *C) {
+	s.mockEncryptedSnapshotServer(c, map[string]any{
+		"action":           "snapshot",
+		"snaps":            []any{"htop"},
+		"snapshot-encrypt": "device",
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt=device", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Not(Matches), "(?s).*passphrase.*")
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedErrors(c *C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt=tpm", "htop"})
+	c.Check(err, ErrorMatches, `Invalid value .tpm. for option .--encrypt.*`)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Check(err, ErrorMatches, "passphrase cannot be empty")
+}
+
+func (s *SnapSuite) TestSnapshotRestoreAndCheckEncrypted(c *C) {
+	for _, cmd := range []string{"restore", "check-snapshot"} {
+		action := cmd
+		if cmd == "check-snapshot" {
+			action = "check"
+		}
+		s.mockEncryptedSnapshotServer(c, map[string]any{
+			"set":        json.Number("7"),
+			"action":     action,
+			"passphrase": "s3kr1t",
+		})
+		s.password = "s3kr1t"
+		s.stdout.Truncate(0)
+
+		_, err := main.Parser(main.Client()).ParseArgs([]string{cmd, "7"})
+		c.Assert(err, IsNil)
+		c.Check(s.Stdout(), Matches, `Snapshot passphrase: \n(Restored|Snapshot #7 verified).*\n`)
+	}
+}
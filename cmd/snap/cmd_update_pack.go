@@ -0,0 +1,530 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store/tooling"
+	"github.com/snapcore/snapd/strutil"
+)
+
+type cmdExportUpdatePack struct {
+	channelMixin
+	Model          flags.Filename `long:"model"`
+	ValidationSets []string       `long:"validation-set" value-name:"<account-id>/<name>[=<sequence>]"`
+
+	Positional struct {
+		PackFile flags.Filename `required:"yes"`
+		Snaps    []remoteSnapName
+	} `positional-args:"yes"`
+}
+
+type cmdApplyUpdatePack struct {
+	waitMixin
+	colorMixin
+
+	Positional struct {
+		PackFile flags.Filename
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortExportUpdatePackHelp = i18n.G("Export snaps and assertions into an update pack")
+var longExportUpdatePackHelp = i18n.G(`
+The export-update-pack command downloads snaps, their components and all the
+assertions needed to verify them into a single update pack file, to be applied
+with 'snap apply-update-pack' on devices without access to the store.
+
+The snaps in the pack are the ones given on the command line, the snaps of the
+model given with --model and the snaps required by the validation sets given
+with --validation-set or referenced by the model. Snaps required at a specific
+revision by a validation set are exported at that revision.
+`)
+
+var shortApplyUpdatePackHelp = i18n.G("Refresh snaps from an update pack")
+var longApplyUpdatePackHelp = i18n.G(`
+The apply-update-pack command imports the assertions of an update pack created
+with 'snap export-update-pack' and refreshes, in a single change, the installed
+snaps carried by the pack. The snaps keep tracking their channels and cohorts.
+Snaps in the pack that are not installed are ignored.
+`)
+
+func init() {
+	addCommand("export-update-pack", shortExportUpdatePackHelp, longExportUpdatePackHelp, func() flags.Commander {
+		return &cmdExportUpdatePack{}
+	}, channelDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"model": i18n.G("Export the snaps of the model in the given model assertion file"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"validation-set": i18n.G("Export the snaps required by the given validation set (can be repeated)"),
+	}), []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<pack-file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Update pack file to create"),
+	}, {
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<snap>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Snap and, optionally, component names (snap+component)"),
+	}})
+	addCommand("apply-update-pack", shortApplyUpdatePackHelp, longApplyUpdatePackHelp, func() flags.Commander {
+		return &cmdApplyUpdatePack{}
+	}, colorDescs.also(waitDescs), []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<pack-file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Update pack file to apply"),
+	}})
+}
+
+// updatePackValidationSet references a validation set to export, a
+// sequence of 0 stands for the latest sequence.
+type updatePackValidationSet struct {
+	accountID string
+	name      string
+	sequence  int
+}
+
+func (vs updatePackValidationSet) String() string {
+	if vs.sequence == 0 {
+		return fmt.Sprintf("%s/%s", vs.accountID, vs.name)
+	}
+	return fmt.Sprintf("%s/%s=%d", vs.accountID, vs.name, vs.sequence)
+}
+
+// updatePackSnap is a snap to download into an update pack.
+type updatePackSnap struct {
+	name       string
+	components []string
+	channel    string
+	revision   snap.Revision
+}
+
+func (sn *updatePackSnap) addComponents(comps ...string) {
+	for _, comp := range comps {
+		if !strutil.ListContains(sn.components, comp) {
+			sn.components = append(sn.components, comp)
+		}
+	}
+}
+
+var (
+	fetchUpdatePackAssertions = fetchUpdatePackAssertionsImpl
+	newUpdatePackToolingStore = newUpdatePackToolingStoreImpl
+)
+
+func newUpdatePackToolingStoreImpl(model *asserts.Model) (*tooling.ToolingStore, error) {
+	if model != nil {
+		return tooling.NewToolingStoreFromModel(model, arch.DpkgArchitecture())
+	}
+	return tooling.NewToolingStore()
+}
+
+// fetchUpdatePackAssertionsImpl writes the model and the given
+// validation sets, together with their prerequisites, to w and returns
+// the fetched validation sets.
+func fetchUpdatePackAssertionsImpl(tsto *tooling.ToolingStore, model *asserts.Model, vsets []updatePackValidationSet, w io.Writer) ([]*asserts.ValidationSet, error) {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   sysdb.Trusted(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := asserts.NewEncoder(w)
+	save := func(a asserts.Assertion) error {
+		return encoder.Encode(a)
+	}
+	f := tsto.AssertionSequenceFormingFetcher(db, save)
+
+	if model != nil {
+		if err := f.Save(model); err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot fetch assertions for the model: %v"), err)
+		}
+	}
+
+	fetched := make([]*asserts.ValidationSet, 0, len(vsets))
+	for _, vs := range vsets {
+		seqKey := []string{release.Series, vs.accountID, vs.name}
+		if err := f.FetchSequence(&asserts.AtSequence{
+			Type:        asserts.ValidationSetType,
+			SequenceKey: seqKey,
+			Sequence:    vs.sequence,
+			Revision:    asserts.RevisionNotKnown,
+		}); err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot fetch validation set %s: %v"), vs, err)
+		}
+		// the first member of the sequence after the previous sequence
+		// number is the one that got fetched, or the latest one
+		after := vs.sequence - 1
+		if vs.sequence == 0 {
+			after = -1
+		}
+		a, err := db.FindSequence(asserts.ValidationSetType, map[string]string{
+			"series":     release.Series,
+			"account-id": vs.accountID,
+			"name":       vs.name,
+		}, after, -1)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, a.(*asserts.ValidationSet))
+	}
+	return fetched, nil
+}
+
+func readModelAssertionFile(fn string) (*asserts.Model, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	a, err := asserts.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot decode model assertion %q: %v"), fn, err)
+	}
+	model, ok := a.(*asserts.Model)
+	if !ok {
+		return nil, fmt.Errorf(i18n.G("cannot use %q: not a model assertion"), fn)
+	}
+	return model, nil
+}
+
+// updatePackSnaps computes the snaps to export, in a stable order.
+func (x *cmdExportUpdatePack) updatePackSnaps(model *asserts.Model, vsets []*asserts.ValidationSet) []*updatePackSnap {
+	byName := make(map[string]*updatePackSnap)
+	get := func(name string) *updatePackSnap {
+		sn := byName[name]
+		if sn == nil {
+			sn = &updatePackSnap{name: name}
+			byName[name] = sn
+		}
+		return sn
+	}
+
+	for _, name := range x.Positional.Snaps {
+		snapName, comps := snap.SplitSnapInstanceAndComponents(string(name))
+		sn := get(snapName)
+		sn.channel = x.Channel
+		sn.addComponents(comps...)
+	}
+
+	if model != nil {
+		for _, ms := range model.AllSnaps() {
+			sn := get(ms.Name)
+			if sn.channel == "" {
+				sn.channel = ms.DefaultChannel
+			}
+			for comp, mc := range ms.Components {
+				if mc.Presence == "required" {
+					sn.addComponents(comp)
+				}
+			}
+		}
+	}
+
+	for _, vs := range vsets {
+		for _, vsn := range vs.Snaps() {
+			if vsn.Presence == asserts.PresenceInvalid {
+				continue
+			}
+			// optional snaps are only exported if wanted otherwise
+			if vsn.Presence == asserts.PresenceOptional && byName[vsn.Name] == nil {
+				continue
+			}
+			sn := get(vsn.Name)
+			if vsn.Revision != 0 {
+				sn.revision = snap.R(vsn.Revision)
+			}
+			for comp, vsc := range vsn.Components {
+				if vsc.Presence == asserts.PresenceRequired {
+					sn.addComponents(comp)
+				}
+			}
+		}
+	}
+
+	snaps := make([]*updatePackSnap, 0, len(byName))
+	for _, sn := range byName {
+		sort.Strings(sn.components)
+		snaps = append(snaps, sn)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].name < snaps[j].name })
+	return snaps
+}
+
+type updatePackFile struct {
+	name string
+	path string
+}
+
+func writeUpdatePack(fn string, manifest *client.UpdatePackManifest, assertions []byte, files []updatePackFile) (err error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(fn)
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+	addData := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := addData(client.UpdatePackManifestName, data); err != nil {
+		return err
+	}
+	if err := addData(client.UpdatePackAssertionsName, assertions); err != nil {
+		return err
+	}
+
+	for _, pf := range files {
+		if err := addUpdatePackFile(tw, pf); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func addUpdatePackFile(tw *tar.Writer, pf updatePackFile) error {
+	f, err := os.Open(pf.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     pf.name,
+		Mode:     0644,
+		Size:     fi.Size(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func (x *cmdExportUpdatePack) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if err := x.setChannelFromCommandline(); err != nil {
+		return err
+	}
+
+	var model *asserts.Model
+	if x.Model != "" {
+		var err error
+		model, err = readModelAssertionFile(string(x.Model))
+		if err != nil {
+			return err
+		}
+	}
+
+	var vsets []updatePackValidationSet
+	if model != nil {
+		for _, mvs := range model.ValidationSets() {
+			vsets = append(vsets, updatePackValidationSet{
+				accountID: mvs.AccountID,
+				name:      mvs.Name,
+				sequence:  mvs.Sequence,
+			})
+		}
+	}
+	for _, arg := range x.ValidationSets {
+		accountID, name, seq, err := snapasserts.ParseValidationSet(arg)
+		if err != nil {
+			return err
+		}
+		vsets = append(vsets, updatePackValidationSet{
+			accountID: accountID,
+			name:      name,
+			sequence:  seq,
+		})
+	}
+
+	if len(x.Positional.Snaps) == 0 && model == nil && len(vsets) == 0 {
+		return errors.New(i18n.G("cannot export an empty update pack: no snaps, model or validation sets given"))
+	}
+
+	tsto, err := newUpdatePackToolingStore(model)
+	if err != nil {
+		return err
+	}
+	tsto.Stdout = Stdout
+
+	var assertions bytes.Buffer
+	fetchedVsets, err := fetchUpdatePackAssertions(tsto, model, vsets, &assertions)
+	if err != nil {
+		return err
+	}
+
+	manifest := &client.UpdatePackManifest{
+		Format: client.UpdatePackFormat,
+	}
+	if model != nil {
+		manifest.Model = &client.UpdatePackModel{
+			BrandID: model.BrandID(),
+			Model:   model.Model(),
+		}
+	}
+	for _, vs := range fetchedVsets {
+		manifest.ValidationSets = append(manifest.ValidationSets, fmt.Sprintf("%s/%s=%d", vs.AccountID(), vs.Name(), vs.Sequence()))
+	}
+
+	tmpdir, err := os.MkdirTemp("", "snap-update-pack-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	var files []updatePackFile
+	snaps := x.updatePackSnaps(model, fetchedVsets)
+	for _, sn := range snaps {
+		fmt.Fprintf(Stdout, i18n.G("Fetching snap %q\n"), sn.name)
+		opts := tooling.DownloadSnapOptions{
+			TargetDir: tmpdir,
+			Revision:  sn.revision,
+		}
+		if sn.revision.Unset() {
+			opts.Channel = sn.channel
+		}
+		dl, err := downloadContainers(sn.name, sn.components, tsto, opts)
+		if err != nil {
+			return err
+		}
+
+		ps := client.UpdatePackSnap{
+			Name:     dl.Info.SnapName(),
+			File:     path.Join(client.UpdatePackSnapsDir, filepath.Base(dl.Path)),
+			Revision: dl.Info.Revision.String(),
+			Channel:  sn.channel,
+		}
+		files = append(files, updatePackFile{name: ps.File, path: dl.Path})
+
+		compInfos := make(map[string]*snap.ComponentInfo, len(dl.Components))
+		for _, comp := range dl.Components {
+			compInfos[comp.Path] = comp.Info
+			pc := client.UpdatePackComponent{
+				Name:     comp.Info.Component.ComponentName,
+				File:     path.Join(client.UpdatePackSnapsDir, filepath.Base(comp.Path)),
+				Revision: comp.Info.Revision.String(),
+			}
+			ps.Components = append(ps.Components, pc)
+			files = append(files, updatePackFile{name: pc.File, path: comp.Path})
+		}
+		manifest.Snaps = append(manifest.Snaps, ps)
+
+		assertsPath, err := downloadAssertions(dl.Info, dl.Path, compInfos, tsto, opts)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(assertsPath)
+		if err != nil {
+			return err
+		}
+		assertions.Write(data)
+	}
+
+	if err := writeUpdatePack(string(x.Positional.PackFile), manifest, assertions.Bytes(), files); err != nil {
+		return fmt.Errorf(i18n.G("cannot write update pack: %v"), err)
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Exported %d snaps to update pack %s\n"), len(snaps), x.Positional.PackFile)
+	return nil
+}
+
+func (x *cmdApplyUpdatePack) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	f, err := os.Open(string(x.Positional.PackFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	changeID, err := x.client.ApplyUpdatePack(f)
+	if err != nil {
+		if e, ok := err.(*client.Error); ok && e.Kind == client.ErrorKindSnapNoUpdateAvailable {
+			fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+			return nil
+		}
+		return err
+	}
+
+	chg, err := x.wait(changeID)
+	if err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	changedSnaps, err := changedSnapsFromChange(chg)
+	if err != nil {
+		return err
+	}
+	return showDone(x.client, chg, changedSnaps, "refresh", nil, x.getEscapes())
+}
@@ -0,0 +1,271 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/client"
+	snapCmd "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store/tooling"
+)
+
+func readUpdatePack(c *check.C, fn string) (manifest *client.UpdatePackManifest, assertions string, files map[string]string) {
+	f, err := os.Open(fn)
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+
+	files = make(map[string]string)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		data, err := io.ReadAll(tr)
+		c.Assert(err, check.IsNil)
+		switch hdr.Name {
+		case client.UpdatePackManifestName:
+			c.Assert(json.Unmarshal(data, &manifest), check.IsNil)
+		case client.UpdatePackAssertionsName:
+			assertions = string(data)
+		default:
+			files[hdr.Name] = string(data)
+		}
+	}
+	return manifest, assertions, files
+}
+
+func (s *SnapSuite) TestExportUpdatePack(c *check.C) {
+	storeStack := assertstest.NewStoreStack("canonical", nil)
+	model, err := storeStack.Sign(asserts.ModelType, map[string]any{
+		"series":         "16",
+		"brand-id":       "canonical",
+		"model":          "my-model",
+		"classic":        "true",
+		"required-snaps": []any{"bar"},
+		"timestamp":      time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	modelFile := filepath.Join(c.MkDir(), "model.assert")
+	c.Assert(os.WriteFile(modelFile, asserts.Encode(model), 0644), check.IsNil)
+
+	vs, err := storeStack.Sign(asserts.ValidationSetType, map[string]any{
+		"type":         "validation-set",
+		"authority-id": "canonical",
+		"series":       "16",
+		"account-id":   "canonical",
+		"name":         "base-set",
+		"sequence":     "2",
+		"snaps": []any{
+			map[string]any{
+				"name":     "baz",
+				"id":       snaptest.AssertedSnapID("baz"),
+				"presence": "required",
+				"revision": "7",
+			},
+			map[string]any{
+				"name":     "optional-snap",
+				"id":       snaptest.AssertedSnapID("optional-snap"),
+				"presence": "optional",
+			},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	var fetchCalls int
+	s.AddCleanup(snapCmd.MockFetchUpdatePackAssertions(func(tsto *tooling.ToolingStore, m *asserts.Model, vsets []snapCmd.UpdatePackValidationSet, w io.Writer) ([]*asserts.ValidationSet, error) {
+		fetchCalls++
+		c.Check(m.Model(), check.Equals, "my-model")
+		c.Assert(vsets, check.HasLen, 1)
+		c.Check(vsets[0].String(), check.Equals, "canonical/base-set=2")
+		fmt.Fprint(w, "model-assertions\n")
+		return []*asserts.ValidationSet{vs.(*asserts.ValidationSet)}, nil
+	}))
+
+	var downloaded []string
+	s.AddCleanup(snapCmd.MockDownloadContainers(func(snapName string, components []string, tsto *tooling.ToolingStore, opts tooling.DownloadSnapOptions) (*tooling.DownloadedSnap, error) {
+		downloaded = append(downloaded, snapName)
+		rev := snap.R(1)
+		switch snapName {
+		case "foo":
+			c.Check(components, check.DeepEquals, []string{"comp1"})
+			c.Check(opts.Channel, check.Equals, "beta")
+			c.Check(opts.Revision.Unset(), check.Equals, true)
+		case "bar":
+			c.Check(components, check.HasLen, 0)
+			c.Check(opts.Channel, check.Equals, "")
+			c.Check(opts.Revision.Unset(), check.Equals, true)
+		case "baz":
+			c.Check(components, check.HasLen, 0)
+			c.Check(opts.Channel, check.Equals, "")
+			c.Check(opts.Revision, check.Equals, snap.R(7))
+			rev = snap.R(7)
+		}
+
+		dl := &tooling.DownloadedSnap{
+			Path: filepath.Join(opts.TargetDir, fmt.Sprintf("%s_%s.snap", snapName, rev)),
+			Info: &snap.Info{SideInfo: snap.SideInfo{RealName: snapName, Revision: rev}},
+		}
+		c.Assert(os.WriteFile(dl.Path, []byte(snapName+"-data"), 0644), check.IsNil)
+		for _, comp := range components {
+			cdl := &tooling.DownloadedComponent{
+				Path: filepath.Join(opts.TargetDir, fmt.Sprintf("%s+%s_3.comp", snapName, comp)),
+				Info: &snap.ComponentInfo{
+					Component:         naming.NewComponentRef(snapName, comp),
+					ComponentSideInfo: snap.ComponentSideInfo{Revision: snap.R(3)},
+				},
+			}
+			c.Assert(os.WriteFile(cdl.Path, []byte(comp+"-data"), 0644), check.IsNil)
+			dl.Components = append(dl.Components, cdl)
+		}
+		return dl, nil
+	}))
+
+	s.AddCleanup(snapCmd.MockDownloadAssertions(func(info *snap.Info, snapPath string, components map[string]*snap.ComponentInfo, tsto *tooling.ToolingStore, opts tooling.DownloadSnapOptions) (string, error) {
+		if info.SnapName() == "foo" {
+			c.Check(components, check.HasLen, 1)
+		}
+		assertPath := filepath.Join(opts.TargetDir, info.SnapName()+".assert")
+		c.Assert(os.WriteFile(assertPath, []byte(info.SnapName()+"-assertions\n"), 0644), check.IsNil)
+		return assertPath, nil
+	}))
+
+	packFile := filepath.Join(c.MkDir(), "pack.tar")
+	rest, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{
+		"export-update-pack",
+		"--model", modelFile,
+		"--validation-set", "canonical/base-set=2",
+		"--channel", "beta",
+		packFile,
+		"foo+comp1",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(fetchCalls, check.Equals, 1)
+	c.Check(downloaded, check.DeepEquals, []string{"bar", "baz", "foo"})
+	c.Check(s.Stdout(), check.Equals, fmt.Sprintf(`Fetching snap "bar"
+Fetching snap "baz"
+Fetching snap "foo"
+Exported 3 snaps to update pack %s
+`, packFile))
+	c.Check(s.Stderr(), check.Equals, "")
+
+	manifest, assertions, files := readUpdatePack(c, packFile)
+	c.Check(manifest, check.DeepEquals, &client.UpdatePackManifest{
+		Format:         client.UpdatePackFormat,
+		Model:          &client.UpdatePackModel{BrandID: "canonical", Model: "my-model"},
+		ValidationSets: []string{"canonical/base-set=2"},
+		Snaps: []client.UpdatePackSnap{{
+			Name:     "bar",
+			File:     "snaps/bar_1.snap",
+			Revision: "1",
+		}, {
+			Name:     "baz",
+			File:     "snaps/baz_7.snap",
+			Revision: "7",
+		}, {
+			Name:     "foo",
+			File:     "snaps/foo_1.snap",
+			Revision: "1",
+			Channel:  "beta",
+			Components: []client.UpdatePackComponent{{
+				Name:     "comp1",
+				File:     "snaps/foo+comp1_3.comp",
+				Revision: "3",
+			}},
+		}},
+	})
+	c.Check(assertions, check.Equals, "model-assertions\nbar-assertions\nbaz-assertions\nfoo-assertions\n")
+	c.Check(files, check.DeepEquals, map[string]string{
+		"snaps/bar_1.snap":       "bar-data",
+		"snaps/baz_7.snap":       "baz-data",
+		"snaps/foo_1.snap":       "foo-data",
+		"snaps/foo+comp1_3.comp": "comp1-data",
+	})
+}
+
+func (s *SnapSuite) TestExportUpdatePackNothingToExport(c *check.C) {
+	_, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{"export-update-pack", "pack.tar"})
+	c.Assert(err, check.ErrorMatches, "cannot export an empty update pack: no snaps, model or validation sets given")
+}
+
+func (s *SnapSuite) TestExportUpdatePackBadValidationSet(c *check.C) {
+	_, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{"export-update-pack", "--validation-set", "foo", "pack.tar"})
+	c.Assert(err, check.ErrorMatches, `cannot parse validation set "foo": expected a single account/name`)
+}
+
+func (s *SnapSuite) TestExportUpdatePackNotAModel(c *check.C) {
+	storeStack := assertstest.NewStoreStack("canonical", nil)
+	fn := filepath.Join(c.MkDir(), "key.assert")
+	c.Assert(os.WriteFile(fn, asserts.Encode(storeStack.StoreAccountKey("")), 0644), check.IsNil)
+
+	_, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{"export-update-pack", "--model", fn, "pack.tar"})
+	c.Assert(err, check.ErrorMatches, `cannot use ".*/key.assert": not a model assertion`)
+}
+
+func (s *SnapOpSuite) TestApplyUpdatePack(c *check.C) {
+	packFile := filepath.Join(c.MkDir(), "pack.tar")
+	c.Assert(os.WriteFile(packFile, []byte("pack-data"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/update-pack")
+		c.Check(r.Header.Get("Content-Type"), check.Equals, "application/x-tar")
+		body, err := io.ReadAll(r.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(string(body), check.Equals, "pack-data")
+	}
+	_, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{"apply-update-pack", packFile})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from Bar refreshed`)
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestApplyUpdatePackNoUpdates(c *check.C) {
+	packFile := filepath.Join(c.MkDir(), "pack.tar")
+	c.Assert(os.WriteFile(packFile, []byte("pack-data"), 0644), check.IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/update-pack")
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "update pack has no updates for installed snaps", "kind": "snap-no-update-available"}, "status-code": 400}`)
+	})
+	_, err := snapCmd.Parser(snapCmd.Client()).ParseArgs([]string{"apply-update-pack", packFile})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+	c.Check(s.Stdout(), check.Equals, "")
+}
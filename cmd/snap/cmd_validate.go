@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -40,6 +41,11 @@ type cmdValidate struct {
 	Positional struct {
 		ValidationSet string `positional-arg-name:"<validation-set>"`
 	} `positional-args:"yes"`
+
+	At                  string `long:"at"`
+	InMaintenanceWindow bool   `long:"in-maintenance-window"`
+	CancelTransition    bool   `long:"cancel-transition"`
+
 	colorMixin
 	waitMixin
 }
@@ -53,6 +59,11 @@ fixed revisions.
 A validation set can either be in monitoring mode, in which case its constraints
 aren't enforced, or in enforcing mode, in which case snapd will not allow
 operations which would result in snaps breaking the validation set's constraints.
+
+Moving an enforced validation set to a newer sequence can be scheduled with
+--enforce account-id/name=seq together with --at and/or --in-maintenance-window.
+Snaps required by the new sequence are downloaded ahead of time and refreshed
+together once the transition is due.
 `)
 
 func init() {
@@ -65,6 +76,12 @@ func init() {
 		"forget": i18n.G("Forget the given validation set"),
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"refresh": i18n.G("Refresh or install snaps to satisfy enforced validation sets"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"at": i18n.G("Enforce the given sequence of the validation set at the given time (RFC3339)"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"in-maintenance-window": i18n.G("Enforce the given sequence of the validation set within a maintenance window"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"cancel-transition": i18n.G("Cancel the scheduled transition of the given validation set"),
 	})), []argDesc{{
 		// TRANSLATORS: This needs to begin with < and end with >
 		name: i18n.G("<validation-set>"),
@@ -87,6 +104,17 @@ func fmtValidationSet(res *client.ValidationSetResult) string {
 	return fmt.Sprintf("%s/%s=%d", res.AccountID, res.Name, res.PinnedAt)
 }
 
+func fmtTransition(tr *client.ValidationSetTransition) string {
+	var when []string
+	if tr.At != nil {
+		when = append(when, fmt.Sprintf(i18n.G("at %s"), tr.At.Format(time.RFC3339)))
+	}
+	if tr.InMaintenanceWindow {
+		when = append(when, i18n.G("in a maintenance window"))
+	}
+	return strings.Join(when, " ")
+}
+
 func (cmd *cmdValidate) Execute(args []string) error {
 	// check that only one action is used at a time
 	var action string
@@ -97,6 +125,7 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		{"monitor", cmd.Monitor},
 		{"enforce", cmd.Enforce},
 		{"forget", cmd.Forget},
+		{"cancel-transition", cmd.CancelTransition},
 	} {
 		if a.set {
 			if action != "" {
@@ -110,6 +139,19 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		return fmt.Errorf("--refresh can only be used together with --enforce")
 	}
 
+	scheduled := cmd.At != "" || cmd.InMaintenanceWindow
+	if scheduled && (!cmd.Enforce || cmd.Refresh) {
+		return fmt.Errorf("--at and --in-maintenance-window can only be used together with --enforce and without --refresh")
+	}
+	var at time.Time
+	if cmd.At != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, cmd.At)
+		if err != nil {
+			return fmt.Errorf("cannot parse --at time %q: expected RFC3339 format", cmd.At)
+		}
+	}
+
 	if cmd.Positional.ValidationSet == "" && action != "" {
 		return fmt.Errorf("missing validation set argument")
 	}
@@ -124,6 +166,10 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		}
 	}
 
+	if scheduled && seq == 0 {
+		return fmt.Errorf("cannot schedule enforcing a validation set without a sequence, i.e. account-id/name=seq")
+	}
+
 	if action != "" {
 		if cmd.Refresh {
 			changeID, err := cmd.client.RefreshMany(nil, nil, &client.SnapOptions{
@@ -158,15 +204,27 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		if cmd.Forget {
 			return cmd.client.ForgetValidationSet(accountID, name, seq)
 		}
+		if cmd.CancelTransition {
+			return cmd.client.CancelValidationSetTransition(accountID, name)
+		}
 		// apply
 		opts := &client.ValidateApplyOptions{
-			Mode:     action,
-			Sequence: seq,
+			Mode:                action,
+			Sequence:            seq,
+			At:                  at,
+			InMaintenanceWindow: cmd.InMaintenanceWindow,
 		}
 		res, err := cmd.client.ApplyValidationSet(accountID, name, opts)
 		if err != nil {
 			return err
 		}
+		if scheduled && res.Transition != nil {
+			fmt.Fprintf(Stdout, i18n.G("Validation set %s/%s will move to sequence %d %s\n"), accountID, name, res.Transition.Sequence, fmtTransition(res.Transition))
+			if res.Transition.PreDownloadChange != "" {
+				fmt.Fprintf(Stdout, i18n.G("Downloading required snaps in change %s\n"), res.Transition.PreDownloadChange)
+			}
+			return nil
+		}
 		// only print valid/invalid status for monitor mode; enforce fails with an error if invalid
 		// and otherwise has no output.
 		if action == "monitor" {
@@ -194,6 +252,9 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		for _, res := range vsets {
 			// TODO: fill notes when've clarity about them
 			var notes string
+			if res.Transition != nil {
+				notes = strings.TrimSpace(fmt.Sprintf(i18n.G("next=%d %s"), res.Transition.Sequence, fmtTransition(res.Transition)))
+			}
 			// doing it this way because otherwise it's a sea of %s\t%s\t%s
 			line := []string{
 				fmtValidationSet(res),
@@ -303,3 +303,82 @@ func (s *validateSuite) TestValidationSetsRefreshEnforceNoUnmetConstraints(c *ch
 	c.Check(s.Stderr(), check.Equals, "")
 	c.Check(s.Stdout(), check.Equals, "Enforced validation set \"foo/bar\"\n")
 }
+
+func (s *validateSuite) TestValidateEnforceScheduled(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+		c.Check(r.Method, check.Equals, "POST")
+		buf, err := io.ReadAll(r.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(string(buf), check.Equals, `{"action":"apply","mode":"enforce","sequence":4,"at":"2030-11-20T02:00:00Z","in-maintenance-window":true}`+"\n")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"account-id":"foo","name":"bar","mode":"enforce","pinned-at":3,"sequence":3,"valid":true,
+			"transition":{"sequence":4,"at":"2030-11-20T02:00:00Z","in-maintenance-window":true,"pre-download-change":"42"}}}`)
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--enforce", "--at", "2030-11-20T02:00:00Z", "--in-maintenance-window", "foo/bar=4"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "Validation set foo/bar will move to sequence 4 at 2030-11-20T02:00:00Z in a maintenance window\n"+
+		"Downloading required snaps in change 42\n")
+}
+
+func (s *validateSuite) TestValidateCancelTransition(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+		c.Check(r.Method, check.Equals, "POST")
+		buf, err := io.ReadAll(r.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(string(buf), check.Equals, `{"action":"cancel-transition"}`+"\n")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--cancel-transition", "foo/bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *validateSuite) TestValidateScheduledInvalidArgs(c *check.C) {
+	for _, args := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--monitor", "--at", "2030-11-20T02:00:00Z", "foo/bar=4"}, `--at and --in-maintenance-window can only be used together with --enforce and without --refresh`},
+		{[]string{"--enforce", "--refresh", "--in-maintenance-window", "foo/bar=4"}, `--at and --in-maintenance-window can only be used together with --enforce and without --refresh`},
+		{[]string{"--enforce", "--at", "tomorrow", "foo/bar=4"}, `cannot parse --at time "tomorrow": expected RFC3339 format`},
+		{[]string{"--enforce", "--in-maintenance-window", "foo/bar"}, `cannot schedule enforcing a validation set without a sequence, i.e. account-id/name=seq`},
+		{[]string{"--forget", "--cancel-transition", "foo/bar"}, `cannot use --forget and --cancel-transition together`},
+		{[]string{"--cancel-transition"}, `missing validation set argument`},
+	} {
+		_, err := main.Parser(main.Client()).ParseArgs(append([]string{"validate"}, args.args...))
+		c.Check(err, check.ErrorMatches, args.err)
+	}
+}
+
+func (s *validateSuite) TestValidationSetsListWithTransition(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(makeFakeListValidationsSetsHandler(c, `{"type": "sync", "status-code": 200, "result": [
+		{"account-id":"foo","name":"bar","mode":"enforce","pinned-at":3,"sequence":3,"valid":true,
+		 "transition":{"sequence":4,"at":"2030-11-20T02:00:00Z"}},
+		{"account-id":"foo","name":"baz","mode":"enforce","pinned-at":1,"sequence":1,"valid":true,
+		 "transition":{"sequence":2,"in-maintenance-window":true}}
+	]}`))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "Validation  Mode     Seq  Current       Notes\n"+
+		"foo/bar=3   enforce  3    valid    next=4 at 2030-11-20T02:00:00Z\n"+
+		"foo/baz=1   enforce  1    valid    next=2 in a maintenance window\n",
+	)
+}
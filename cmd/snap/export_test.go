@@ -21,11 +21,13 @@ package main
 
 import (
 	"context"
+	"io"
 	"os"
 	"time"
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/cmd/snaplock/runinhibit"
 	"github.com/snapcore/snapd/image"
@@ -35,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/sandbox/selinux"
 	"github.com/snapcore/snapd/seed/seedwriter"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/tooling"
 	"github.com/snapcore/snapd/testutil"
@@ -418,6 +421,12 @@ func MockDownloadAssertions(f func(info *snap.Info, snapPath string, components
 	}
 }
 
+type UpdatePackValidationSet = updatePackValidationSet
+
+func MockFetchUpdatePackAssertions(f func(tsto *tooling.ToolingStore, model *asserts.Model, vsets []UpdatePackValidationSet, w io.Writer) ([]*asserts.ValidationSet, error)) (restore func()) {
+	return testutil.Mock(&fetchUpdatePackAssertions, f)
+}
+
 func MockSnapdAPIInterval(t time.Duration) (restore func()) {
 	old := snapdAPIInterval
 	snapdAPIInterval = t
@@ -496,3 +505,11 @@ func MockTimeAfter(f func(d time.Duration) <-chan time.Time) (restore func()) {
 func MockSnapdtoolIsReexecd(f func() (bool, error)) (restore func()) {
 	return testutil.Mock(&snapdtoolIsReexecd, f)
 }
+
+func MockSquashfsGenerateDelta(f func(ctx context.Context, sourceSnap, targetSnap, delta string, deltaFormat squashfs.DeltaFormat) error) (restore func()) {
+	return testutil.Mock(&squashfsGenerateDelta, f)
+}
+
+func MockSquashfsApplyDelta(f func(ctx context.Context, sourceSnap, delta, targetSnap string) error) (restore func()) {
+	return testutil.Mock(&squashfsApplyDelta, f)
+}
@@ -70,6 +70,7 @@ var api = []*Command{
 	debugCmd,
 	snapshotCmd,
 	snapshotExportCmd,
+	snapshotTargetCmd,
 	connectionsCmd,
 	modelCmd,
 	cohortsCmd,
@@ -85,6 +86,7 @@ var api = []*Command{
 	quotaGroupsCmd,
 	quotaGroupInfoCmd,
 	confdbCmd,
+	confdbDatabagCmd,
 	confdbControlCmd,
 	noticesCmd,
 	noticeCmd,
@@ -93,8 +95,12 @@ var api = []*Command{
 	requestsPromptCmd,
 	requestsRulesCmd,
 	requestsRuleCmd,
+	requestsAdminRulesCmd,
+	requestsAuditCmd,
 	systemSecurebootCmd,
 	systemVolumesCmd,
+	metricsCmd,
+	updatePackCmd,
 }
 
 type featureEndpoint struct {
@@ -221,6 +227,10 @@ var (
 	confdbstateGetTransactionToSet = confdbstate.GetTransactionToSet
 	confdbstateSetViaView          = confdbstate.SetViaView
 	confdbstateLoadConfdbAsync     = confdbstate.LoadConfdbAsync
+	confdbstateGetHistoryViaView   = confdbstate.GetHistoryViaView
+	confdbstateRollbackViaView     = confdbstate.RollbackViaView
+	confdbstateExportDatabag       = confdbstate.ExportDatabag
+	confdbstateImportDatabag       = confdbstate.ImportDatabag
 
 	devicestateSignConfdbControl = (*devicestate.DeviceManager).SignConfdbControl
 )
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
@@ -45,6 +46,14 @@ var (
 		ReadAccess:  authenticatedAccess{Polkit: polkitActionManage},
 		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
 	}
+	confdbDatabagCmd = &Command{
+		Path: "/v2/confdb/{account}/{confdb-schema}",
+		GET:  exportDatabag,
+		PUT:  importDatabag,
+		// databags may contain secrets that views would otherwise hide
+		ReadAccess:  rootAccess{},
+		WriteAccess: rootAccess{},
+	}
 	confdbControlCmd = &Command{
 		Path:        "/v2/confdb",
 		POST:        handleConfdbControlAction,
@@ -84,6 +93,15 @@ func getView(c *Command, r *http.Request, _ *auth.UserState) Response {
 		}
 	}
 
+	var history bool
+	if historyStr := r.URL.Query().Get("history"); historyStr != "" {
+		var err error
+		history, err = strconv.ParseBool(historyStr)
+		if err != nil {
+			return BadRequest(`"history" must be a boolean`)
+		}
+	}
+
 	view, err := confdbstateGetView(st, account, schemaName, viewName)
 	if err != nil {
 		return toAPIError(err)
@@ -93,6 +111,16 @@ func getView(c *Command, r *http.Request, _ *auth.UserState) Response {
 	if err != nil {
 		return toAPIError(err)
 	}
+
+	if history {
+		// the history is kept in the state so there are no hooks to run
+		revs, err := confdbstateGetHistoryViaView(st, view, keys, constraints, int(ucred.Uid))
+		if err != nil {
+			return toAPIError(err)
+		}
+		return SyncResponse(revs)
+	}
+
 	chgID, err := confdbstateLoadConfdbAsync(st, view, keys, constraints, int(ucred.Uid))
 	if err != nil {
 		return toAPIError(err)
@@ -135,7 +163,9 @@ func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
 	account, schemaName, viewName := vars["account"], vars["confdb-schema"], vars["view"]
 
 	type setAction struct {
-		Values map[string]any `json:"values"`
+		Action   string         `json:"action"`
+		Values   map[string]any `json:"values"`
+		Revision int            `json:"revision"`
 	}
 
 	var action setAction
@@ -144,6 +174,19 @@ func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
 		return BadRequest("cannot decode confdb request body: %v", err)
 	}
 
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	switch action.Action {
+	case "":
+	case "rollback":
+		return rollbackView(st, account, schemaName, viewName, action.Revision, action.Values, int(ucred.Uid))
+	default:
+		return BadRequest("unknown confdb action %q", action.Action)
+	}
+
 	if len(action.Values) == 0 {
 		return BadRequest("cannot set confdb: request body contains no values")
 	}
@@ -155,7 +198,7 @@ func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
 		return toAPIError(err)
 	}
 
-	tx, commitTxFunc, err := confdbstateGetTransactionToSet(nil, st, view)
+	tx, commitTxFunc, err := confdbstateGetTransactionToSet(nil, st, view, int(ucred.Uid))
 	if err != nil {
 		return toAPIError(err)
 	}
@@ -173,6 +216,72 @@ func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
 	return AsyncResponse(nil, changeID)
 }
 
+func rollbackView(st *state.State, account, schemaName, viewName string, revision int, values map[string]any, userID int) Response {
+	if len(values) != 0 {
+		return BadRequest("cannot rollback confdb: request body cannot contain values")
+	}
+	if revision <= 0 {
+		return BadRequest("cannot rollback confdb: revision must be a positive number")
+	}
+
+	view, err := confdbstateGetView(st, account, schemaName, viewName)
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	changeID, err := confdbstateRollbackViaView(st, view, revision, userID)
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	return AsyncResponse(nil, changeID)
+}
+
+func exportDatabag(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.state
+	st.Lock()
+	defer st.Unlock()
+
+	if err := validateFeatureFlag(st, features.Confdb); err != nil {
+		return err
+	}
+
+	vars := muxVars(r)
+	account, schemaName := vars["account"], vars["confdb-schema"]
+
+	databag, err := confdbstateExportDatabag(st, account, schemaName)
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	return SyncResponse(databag)
+}
+
+func importDatabag(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.state
+	st.Lock()
+	defer st.Unlock()
+
+	if err := validateFeatureFlag(st, features.Confdb); err != nil {
+		return err
+	}
+
+	vars := muxVars(r)
+	account, schemaName := vars["account"], vars["confdb-schema"]
+
+	var data json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode confdb databag: %v", err)
+	}
+
+	if err := confdbstateImportDatabag(st, account, schemaName, data, ""); err != nil {
+		return toAPIError(err)
+	}
+
+	return SyncResponse(nil)
+}
+
 func toAPIError(err error) *apiError {
 	switch {
 	case errors.Is(err, &asserts.NotFoundError{}):
@@ -198,7 +307,9 @@ func toAPIError(err error) *apiError {
 			Kind:    client.ErrorKindConfigNoSuchOption,
 			Value:   err,
 		}
-	case errors.Is(err, &confdb.BadRequestError{}),
+	case errors.Is(err, &confdbstate.RevisionNotFoundError{}),
+		errors.Is(err, &confdbstate.InvalidDatabagError{}),
+		errors.Is(err, &confdb.BadRequestError{}),
 		errors.Is(err, &confdb.UnconstrainedParamsError{}),
 		errors.Is(err, &confdb.UnmatchedConstraintsError{}):
 		return BadRequest(err.Error())
@@ -180,11 +180,12 @@ func (s *confdbSuite) TestViewSetMany(c *C) {
 	defer restore()
 
 	var calls int
-	restore = daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore = daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		c.Assert(ctx, IsNil)
 		c.Assert(view.Name, Equals, "wifi-setup")
 		c.Assert(view.Schema().Account, Equals, "system")
 		c.Assert(view.Schema().Name, Equals, "network")
+		c.Assert(userID, Equals, 1000)
 
 		return nil, func() (string, <-chan struct{}, error) { calls++; return "123", nil, nil }, nil
 	})
@@ -200,6 +201,7 @@ func (s *confdbSuite) TestViewSetMany(c *C) {
 	buf := bytes.NewBufferString(`{"values":{"ssid": "foo", "password": "bar"}}`)
 	req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
 
 	rspe := s.asyncReq(c, req, nil, actionIsExpected)
 	c.Check(rspe.Status, Equals, 202)
@@ -242,6 +244,7 @@ func (s *confdbSuite) TestGetViewError(c *C) {
 		buf := bytes.NewBufferString(`{"values":{"ssid": "foo", "password": "bar"}}`)
 		req, err = http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 		c.Assert(err, IsNil, cmt)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
 
 		rspe = s.errorReq(c, req, nil, actionIsExpected)
 		c.Check(rspe.Status, Equals, t.status, cmt)
@@ -344,7 +347,7 @@ func (s *confdbSuite) TestSetView(c *C) {
 		c.Assert(err, IsNil, cmt)
 
 		var calls int
-		restoreGetTx := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		restoreGetTx := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 			calls++
 			c.Assert(ctx, IsNil, cmt)
 			c.Assert(view.Name, Equals, "wifi-setup", cmt)
@@ -368,6 +371,7 @@ func (s *confdbSuite) TestSetView(c *C) {
 		buf := bytes.NewBufferString(fmt.Sprintf(`{"values":{"ssid": %s}}`, jsonVal))
 		req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 		c.Check(err, IsNil, cmt)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
 		req.Header.Set("Content-Type", "application/json")
 
 		rspe := s.asyncReq(c, req, nil, actionIsExpected)
@@ -399,6 +403,7 @@ func (s *confdbSuite) TestSetEmpty(c *C) {
 		buf := bytes.NewBufferString(fmt.Sprintf(`{%s}`, val))
 		req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 		c.Check(err, IsNil)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
 		req.Header.Set("Content-Type", "application/json")
 
 		rspe := s.errorReq(c, req, nil, actionIsExpected)
@@ -428,7 +433,7 @@ func (s *confdbSuite) TestUnsetView(c *C) {
 	c.Assert(err, IsNil)
 
 	var calls int
-	restore = daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore = daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		calls++
 		c.Assert(ctx, IsNil)
 		c.Assert(view.Name, Equals, "wifi-setup")
@@ -451,6 +456,7 @@ func (s *confdbSuite) TestUnsetView(c *C) {
 	buf := bytes.NewBufferString(`{"values":{"ssid": null}}`)
 	req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 	c.Check(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
 	req.Header.Set("Content-Type", "application/json")
 
 	rspe := s.asyncReq(c, req, nil, actionIsExpected)
@@ -481,7 +487,7 @@ func (s *confdbSuite) TestSetViewError(c *C) {
 		{name: "internal", err: errors.New("internal"), status: 500},
 		{name: "bad query", err: &confdb.BadRequestError{}, status: 400},
 	} {
-		restore := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		restore := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 			return nil, nil, t.err
 		})
 		cmt := Commentf("%s test", t.name)
@@ -489,6 +495,7 @@ func (s *confdbSuite) TestSetViewError(c *C) {
 		buf := bytes.NewBufferString(`{"values":{"ssid": "foo"}}`)
 		req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 		c.Assert(err, IsNil, cmt)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
 		req.Header.Set("Content-Type", "application/json")
 
 		rspe := s.errorReq(c, req, nil, actionIsExpected)
@@ -501,7 +508,7 @@ func (s *confdbSuite) TestSetViewError(c *C) {
 func (s *confdbSuite) TestSetViewBadRequests(c *C) {
 	s.setFeatureFlag(c)
 
-	restore := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := daemon.MockConfdbstateGetTransaction(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		err := errors.New("unexpected call to confdbstate.Set")
 		c.Error(err)
 		return nil, nil, err
@@ -527,6 +534,7 @@ func (s *confdbSuite) TestSetViewBadRequests(c *C) {
 		req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", tc.body)
 		req.Header.Set("Content-Type", "application/json")
 		c.Assert(err, IsNil)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
 
 		rspe := s.errorReq(c, req, nil, actionIsExpected)
 		c.Check(rspe.Status, Equals, 400)
@@ -546,6 +554,7 @@ func (s *confdbSuite) TestSetFailUnsetFeatureFlag(c *C) {
 	req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
 	req.Header.Set("Content-Type", "application/json")
 	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
 
 	rspe := s.errorReq(c, req, nil, actionIsExpected)
 	c.Check(rspe.Status, Equals, 400)
@@ -959,3 +968,260 @@ func (s *confdbControlSuite) TestConfdbControlActionInvalidRequest(c *C) {
 		c.Check(rspe.Message, Equals, tc.errMsg)
 	}
 }
+
+func (s *confdbSuite) TestGetViewHistory(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, _, _, viewName string) (*confdb.View, error) {
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	restore = daemon.MockConfdbstateLoadConfdbAsync(func(*state.State, *confdb.View, []string, map[string]any, int) (string, error) {
+		err := errors.New("unexpected call to LoadConfdbAsync")
+		c.Error(err)
+		return "", err
+	})
+	defer restore()
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	history := []*confdbstate.ViewRevision{
+		{Revision: 1, Time: t0, View: "system/network/wifi-setup"},
+		{Revision: 2, Time: t0.Add(time.Hour), Snap: "test-snap", Values: map[string]any{"ssid": "foo"}},
+	}
+	var calls int
+	restore = daemon.MockConfdbstateGetHistoryViaView(func(_ *state.State, view *confdb.View, requests []string, cstrs map[string]any, userID int) ([]*confdbstate.ViewRevision, error) {
+		calls++
+		c.Check(view.Name, Equals, "wifi-setup")
+		c.Check(requests, DeepEquals, []string{"ssid"})
+		c.Check(cstrs, IsNil)
+		c.Check(userID, Equals, 1000)
+		return history, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb/system/network/wifi-setup?keys=ssid&history=true", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, DeepEquals, history)
+	c.Check(calls, Equals, 1)
+}
+
+func (s *confdbSuite) TestGetViewHistoryErrors(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, _, _, viewName string) (*confdb.View, error) {
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	restore = daemon.MockConfdbstateGetHistoryViaView(func(*state.State, *confdb.View, []string, map[string]any, int) ([]*confdbstate.ViewRevision, error) {
+		return nil, &confdb.UnmatchedConstraintsError{}
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb/system/network/wifi-setup?history=yes", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `"history" must be a boolean`)
+
+	req, err = http.NewRequest("GET", "/v2/confdb/system/network/wifi-setup?history=true", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+
+	rspe = s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+}
+
+func (s *confdbSuite) TestRollbackView(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, _, _, viewName string) (*confdb.View, error) {
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	restore = daemon.MockConfdbstateGetTransaction(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		err := errors.New("unexpected call to GetTransactionToSet")
+		c.Error(err)
+		return nil, nil, err
+	})
+	defer restore()
+
+	var calls int
+	restore = daemon.MockConfdbstateRollbackViaView(func(_ *state.State, view *confdb.View, revision, userID int) (string, error) {
+		calls++
+		c.Check(view.ID(), Equals, "system/network/wifi-setup")
+		c.Check(revision, Equals, 3)
+		c.Check(userID, Equals, 1000)
+		return "123", nil
+	})
+	defer restore()
+
+	buf := bytes.NewBufferString(`{"action": "rollback", "revision": 3}`)
+	req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 202)
+	c.Check(rspe.Change, Equals, "123")
+	c.Check(calls, Equals, 1)
+}
+
+func (s *confdbSuite) TestRollbackViewErrors(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, _, _, viewName string) (*confdb.View, error) {
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	var rollbackErr error
+	restore = daemon.MockConfdbstateRollbackViaView(func(*state.State, *confdb.View, int, int) (string, error) {
+		return "", rollbackErr
+	})
+	defer restore()
+
+	for _, tc := range []struct {
+		body   string
+		err    error
+		status int
+		errMsg string
+	}{
+		{body: `{"action": "rollback"}`, status: 400, errMsg: "cannot rollback confdb: revision must be a positive number"},
+		{body: `{"action": "rollback", "revision": 1, "values": {"ssid": "foo"}}`, status: 400, errMsg: "cannot rollback confdb: request body cannot contain values"},
+		{body: `{"action": "foo", "values": {"ssid": "foo"}}`, status: 400, errMsg: `unknown confdb action "foo"`},
+		{body: `{"action": "rollback", "revision": 1}`, err: &confdbstate.RevisionNotFoundError{}, status: 400},
+		{body: `{"action": "rollback", "revision": 1}`, err: errors.New("boom"), status: 500, errMsg: "boom"},
+	} {
+		rollbackErr = tc.err
+		cmt := Commentf(tc.body)
+
+		req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", bytes.NewBufferString(tc.body))
+		c.Assert(err, IsNil)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, Equals, tc.status, cmt)
+		if tc.errMsg != "" {
+			c.Check(rspe.Message, Equals, tc.errMsg, cmt)
+		}
+	}
+}
+
+func (s *confdbSuite) TestExportDatabag(c *C) {
+	s.expectRootAccess()
+	s.setFeatureFlag(c)
+
+	databag := confdb.JSONDatabag{"wifi": json.RawMessage(`{"ssid":"foo"}`)}
+
+	var calls int
+	restore := daemon.MockConfdbstateExportDatabag(func(_ *state.State, account, schemaName string) (confdb.JSONDatabag, error) {
+		calls++
+		c.Check(account, Equals, "system")
+		c.Check(schemaName, Equals, "network")
+		return databag, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb/system/network", nil)
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, DeepEquals, databag)
+	c.Check(calls, Equals, 1)
+}
+
+func (s *confdbSuite) TestExportDatabagErrors(c *C) {
+	s.expectRootAccess()
+
+	var exportErr error
+	restore := daemon.MockConfdbstateExportDatabag(func(*state.State, string, string) (confdb.JSONDatabag, error) {
+		return nil, exportErr
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb/system/network", nil)
+	c.Assert(err, IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `feature flag "confdb" is disabled: set 'experimental.confdb' to true`)
+
+	s.setFeatureFlag(c)
+	exportErr = &asserts.NotFoundError{Type: asserts.ConfdbSchemaType}
+	rspe = s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Kind, Equals, client.ErrorKindAssertionNotFound)
+}
+
+func (s *confdbSuite) TestImportDatabag(c *C) {
+	s.expectRootAccess()
+	s.setFeatureFlag(c)
+
+	var calls int
+	restore := daemon.MockConfdbstateImportDatabag(func(_ *state.State, account, schemaName string, data []byte, callingSnap string) error {
+		calls++
+		c.Check(account, Equals, "system")
+		c.Check(schemaName, Equals, "network")
+		c.Check(string(data), Equals, `{"wifi": {"ssid": "foo"}}`)
+		c.Check(callingSnap, Equals, "")
+		return nil
+	})
+	defer restore()
+
+	buf := bytes.NewBufferString(`{"wifi": {"ssid": "foo"}}`)
+	req, err := http.NewRequest("PUT", "/v2/confdb/system/network", buf)
+	c.Assert(err, IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(calls, Equals, 1)
+}
+
+func (s *confdbSuite) TestImportDatabagErrors(c *C) {
+	s.expectRootAccess()
+	s.setFeatureFlag(c)
+
+	var importErr error
+	restore := daemon.MockConfdbstateImportDatabag(func(*state.State, string, string, []byte, string) error {
+		return importErr
+	})
+	defer restore()
+
+	for _, tc := range []struct {
+		body   string
+		err    error
+		status int
+		errMsg string
+	}{
+		{body: `{`, status: 400, errMsg: "cannot decode confdb databag: unexpected EOF"},
+		{body: `{}`, err: &confdbstate.InvalidDatabagError{}, status: 400},
+		{body: `{}`, err: &asserts.NotFoundError{Type: asserts.ConfdbSchemaType}, status: 400},
+		{body: `{}`, err: errors.New("boom"), status: 500, errMsg: "boom"},
+	} {
+		importErr = tc.err
+		cmt := Commentf(tc.body)
+
+		req, err := http.NewRequest("PUT", "/v2/confdb/system/network", bytes.NewBufferString(tc.body))
+		c.Assert(err, IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, Equals, tc.status, cmt)
+		if tc.errMsg != "" {
+			c.Check(rspe.Message, Equals, tc.errMsg, cmt)
+		}
+	}
+}
@@ -46,6 +46,7 @@ var debugCmd = &Command{
 		"add-warning", "unshow-warnings", "ensure-state-soon",
 		"can-manage-refreshes", "prune", "stacktraces",
 		"create-recovery-system", "migrate-home",
+		"inject-request-message",
 	},
 	ReadAccess:  openAccess{},
 	WriteAccess: rootAccess{},
@@ -58,6 +59,8 @@ type debugAction struct {
 		ChgID string `json:"chg-id"`
 
 		RecoverySystemLabel string `json:"recovery-system-label"`
+
+		RequestMessage string `json:"request-message"`
 	} `json:"params"`
 	Snaps []string `json:"snaps"`
 }
@@ -418,6 +421,8 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return getRAAInfo(st)
 	case "features":
 		return getFeatures(c)
+	case "device-management":
+		return getDeviceManagementInfo(c.d.overlord.DeviceMgmtManager())
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
@@ -459,6 +464,8 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return createRecovery(st, a.Params.RecoverySystemLabel)
 	case "migrate-home":
 		return migrateHome(st, a.Snaps)
+	case "inject-request-message":
+		return injectRequestMessage(st, c.d.overlord.DeviceMgmtManager(), a.Params.RequestMessage)
 	default:
 		return BadRequest("unknown debug action: %v", a.Action)
 	}
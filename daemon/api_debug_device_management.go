@@ -0,0 +1,51 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"github.com/snapcore/snapd/overlord/devicemgmtstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func getDeviceManagementInfo(mgr *devicemgmtstate.DeviceMgmtManager) Response {
+	info, err := mgr.MessagesInfo()
+	if err != nil {
+		return InternalError("cannot get device management messages: %v", err)
+	}
+
+	return SyncResponse(info)
+}
+
+func injectRequestMessage(st *state.State, mgr *devicemgmtstate.DeviceMgmtManager, encoded string) Response {
+	if encoded == "" {
+		return BadRequest("cannot inject request-message: no message provided")
+	}
+
+	msg, chg, err := mgr.InjectRequestMessage([]byte(encoded))
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	ensureStateSoon(st)
+
+	return SyncResponse(map[string]any{
+		"message-id": msg.ID(),
+		"change":     chg.ID(),
+	})
+}
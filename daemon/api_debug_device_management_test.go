@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/devicemgmtstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = Suite(&deviceMgmtDebugSuite{})
+
+type deviceMgmtDebugSuite struct {
+	apiBaseSuite
+
+	ensureSoon int
+}
+
+func (s *deviceMgmtDebugSuite) SetUpTest(c *C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+
+	s.ensureSoon = 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		s.ensureSoon++
+	})
+	s.AddCleanup(restore)
+}
+
+func (s *deviceMgmtDebugSuite) requestMessage(c *C, id string) string {
+	storeStack := assertstest.NewStoreStack("my-brand", nil)
+	now := time.Now()
+	a, err := storeStack.Sign(asserts.RequestMessageType, map[string]any{
+		"authority-id": "my-brand",
+		"account-id":   "my-brand",
+		"message-id":   id,
+		"message-kind": "snap-install",
+		"devices":      []any{"serial-1.my-model.my-brand"},
+		"valid-since":  now.Add(-time.Hour).UTC().Format(time.RFC3339),
+		"valid-until":  now.Add(time.Hour).UTC().Format(time.RFC3339),
+		"timestamp":    now.UTC().Format(time.RFC3339),
+	}, []byte(`{"snaps": [{"name": "foo"}]}`), "")
+	c.Assert(err, IsNil)
+
+	return string(asserts.Encode(a))
+}
+
+func (s *deviceMgmtDebugSuite) postInject(c *C, encoded string) *http.Request {
+	body, err := json.Marshal(map[string]any{
+		"action": "inject-request-message",
+		"params": map[string]any{"request-message": encoded},
+	})
+	c.Assert(err, IsNil)
+	req, err := http.NewRequest("POST", "/v2/debug", bytes.NewBuffer(body))
+	c.Assert(err, IsNil)
+	return req
+}
+
+func (s *deviceMgmtDebugSuite) TestGetDeviceManagementNoMessages(c *C) {
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=device-management", nil)
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Result, DeepEquals, &devicemgmtstate.MessagesInfo{
+		Pending:   []*devicemgmtstate.PendingInfo{},
+		Responses: []*devicemgmtstate.ResponseInfo{},
+	})
+}
+
+func (s *deviceMgmtDebugSuite) TestInjectRequestMessage(c *C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	rsp := s.syncReq(c, s.postInject(c, s.requestMessage(c, "someId")), nil, actionIsExpected)
+	result, ok := rsp.Result.(map[string]any)
+	c.Assert(ok, Equals, true)
+	c.Check(result["message-id"], Equals, "someId")
+	c.Check(s.ensureSoon, Equals, 1)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	chg := st.Change(result["change"].(string))
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "device-management-exchange")
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=device-management", nil)
+	c.Assert(err, IsNil)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	info, ok := rsp.Result.(*devicemgmtstate.MessagesInfo)
+	c.Assert(ok, Equals, true)
+	c.Assert(info.Pending, HasLen, 1)
+	c.Check(info.Pending[0].MessageID, Equals, "someId")
+	c.Check(info.Pending[0].Kind, Equals, "snap-install")
+	c.Check(info.Responses, HasLen, 0)
+}
+
+func (s *deviceMgmtDebugSuite) TestInjectRequestMessageErrors(c *C) {
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	rspe := s.errorReq(c, s.postInject(c, ""), nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, "cannot inject request-message: no message provided")
+
+	rspe = s.errorReq(c, s.postInject(c, "not-an-assertion"), nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Matches, "cannot inject request-message: cannot decode assertion: .*")
+	c.Check(s.ensureSoon, Equals, 0)
+}
@@ -35,10 +35,12 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/fdestate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
@@ -161,6 +163,11 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	} else {
 		refreshInfo.Schedule = refreshScheduleStr
 	}
+	rollout, err := snapstate.RefreshRolloutStatus(st)
+	if err != nil {
+		return InternalError("cannot get refresh rollout status: %s", err)
+	}
+	refreshInfo.Rollout = rolloutInfo(rollout)
 
 	m := map[string]any{
 		"series":         release.Series,
@@ -183,6 +190,13 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if systemdVirt != "" {
 		m["virtualization"] = systemdVirt
 	}
+	maintenance, err := maintenanceWindowsInfo(st, tr)
+	if err != nil {
+		return InternalError("cannot get maintenance windows status: %s", err)
+	}
+	if maintenance != nil {
+		m["maintenance-windows"] = maintenance
+	}
 
 	// NOTE: Right now we don't have a good way to differentiate if we
 	// only have partial confinement (ala AppArmor disabled and Seccomp
@@ -260,6 +274,58 @@ func formatRefreshTime(t time.Time) string {
 	return t.Truncate(time.Minute).Format(time.RFC3339)
 }
 
+func maintenanceWindowsInfo(st *state.State, tr *config.Transaction) (*client.MaintenanceWindowsInfo, error) {
+	var windows string
+	if err := tr.GetMaybe("core", "maintenance.windows", &windows); err != nil {
+		return nil, err
+	}
+	pending, err := restart.PendingRebootStatus(st)
+	if err != nil {
+		return nil, err
+	}
+	if windows == "" && pending == nil {
+		return nil, nil
+	}
+	info := &client.MaintenanceWindowsInfo{
+		Windows: windows,
+	}
+	open, next, err := restart.InMaintenanceWindow(st, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !open {
+		info.Next = formatRefreshTime(next)
+	}
+	if pending != nil {
+		info.PendingReboot = &client.PendingRebootInfo{
+			RequestedAt: formatRefreshTime(pending.RequestedAt),
+		}
+	}
+	return info, nil
+}
+
+func rolloutInfo(status *snapstate.RolloutStatus) *client.RolloutInfo {
+	if status == nil {
+		return nil
+	}
+	info := &client.RolloutInfo{
+		Bucket: status.Bucket,
+		Held:   status.Held,
+	}
+	if status.Soak != 0 {
+		info.Soak = status.Soak.String()
+		info.Delay = status.Delay.String()
+	}
+	for _, p := range status.Pending {
+		info.Pending = append(info.Pending, client.RolloutPending{
+			Snap:     p.Snap,
+			Revision: p.Revision,
+			Due:      formatRefreshTime(p.Due),
+		})
+	}
+	return info
+}
+
 func sandboxFeatures(backends []interfaces.SecurityBackend) map[string][]string {
 	result := make(map[string][]string, len(backends)+1)
 	for _, backend := range backends {
@@ -289,13 +355,25 @@ func getChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
 	state.Lock()
-	defer state.Unlock()
-	chg := state.Change(chID)
-	if chg == nil {
-		return NotFound("cannot find change with id %q", chID)
+	var chgInfo *changeInfo
+	if chg := state.Change(chID); chg != nil {
+		chgInfo = change2changeInfo(chg)
+	}
+	state.Unlock()
+	if chgInfo != nil {
+		return SyncResponse(chgInfo)
 	}
 
-	return SyncResponse(change2changeInfo(chg))
+	// the change might have been pruned already, changes are archived as
+	// they are pruned so it is there if not in the state anymore
+	archived, err := c.d.overlord.ChangeArchive().Change(chID)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if archived == nil {
+		return NotFound("cannot find change with id %q", chID)
+	}
+	return SyncResponse(archivedChange2changeInfo(archived))
 }
 
 func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -312,8 +390,10 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		filter = func(chg *state.Change) bool { return !chg.IsReady() }
 	case "ready":
 		filter = func(chg *state.Change) bool { return chg.IsReady() }
+	case "archived":
+		return getArchivedChanges(c, query.Get("for"))
 	default:
-		return BadRequest("select should be one of: all,in-progress,ready")
+		return BadRequest("select should be one of: all,in-progress,ready,archived")
 	}
 
 	if wantedName := query.Get("for"); wantedName != "" {
@@ -357,6 +437,33 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos)
 }
 
+// getArchivedChanges returns the changes which were pruned from the state,
+// optionally only those affecting the given snap.
+func getArchivedChanges(c *Command, wantedName string) Response {
+	archived, err := c.d.overlord.ChangeArchive().Changes()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	chgInfos := make([]*changeInfo, 0, len(archived))
+	for _, chg := range archived {
+		if wantedName != "" && !archivedChangeAffects(chg, wantedName) {
+			continue
+		}
+		chgInfos = append(chgInfos, archivedChange2changeInfo(chg))
+	}
+	return SyncResponse(chgInfos)
+}
+
+func archivedChangeAffects(chg *changearchive.Change, wantedName string) bool {
+	for _, name := range chg.SnapNames {
+		snapName, _ := snap.SplitSnapApp(name)
+		if snapName == wantedName {
+			return true
+		}
+	}
+	return false
+}
+
 func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
@@ -484,6 +591,46 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	return chgInfo
 }
 
+func archivedChange2changeInfo(chg *changearchive.Change) *changeInfo {
+	chgInfo := &changeInfo{
+		ID:      chg.ID,
+		Kind:    chg.Kind,
+		Summary: chg.Summary,
+		Status:  chg.Status,
+		Ready:   true,
+		Err:     chg.Err,
+		Data:    chg.Data,
+
+		SpawnTime: chg.SpawnTime,
+	}
+	if !chg.ReadyTime.IsZero() {
+		readyTime := chg.ReadyTime
+		chgInfo.ReadyTime = &readyTime
+	}
+	chgInfo.Tasks = make([]*taskInfo, len(chg.Tasks))
+	for i, t := range chg.Tasks {
+		taskInfo := &taskInfo{
+			ID:      t.ID,
+			Kind:    t.Kind,
+			Summary: t.Summary,
+			Status:  t.Status,
+			Log:     t.Log,
+			Progress: taskInfoProgress{
+				Label: t.Progress.Label,
+				Done:  t.Progress.Done,
+				Total: t.Progress.Total,
+			},
+			SpawnTime: t.SpawnTime,
+		}
+		if !t.ReadyTime.IsZero() {
+			readyTime := t.ReadyTime
+			taskInfo.ReadyTime = &readyTime
+		}
+		chgInfo.Tasks[i] = taskInfo
+	}
+	return chgInfo
+}
+
 var snapstateSnapsAffectedByTask = snapstate.SnapsAffectedByTask
 
 // taskApiData returns a map similar to change data which is currently
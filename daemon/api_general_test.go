@@ -34,6 +34,7 @@ import (
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/dirs/dirstest"
@@ -41,12 +42,17 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/fdestate"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var _ = check.Suite(&generalSuite{})
@@ -506,6 +512,87 @@ func (s *generalSuite) TestSysInfoIsManaged(c *check.C) {
 	c.Check(rsp.Result.(map[string]any)["managed"], check.Equals, true)
 }
 
+func (s *generalSuite) TestSysInfoRefreshRollout(c *check.C) {
+	s.expectSystemInfoReadAccess()
+	d := s.daemon(c)
+
+	restore := testutil.Backup(&snapstate.DeviceSerial)
+	defer restore()
+	snapstate.DeviceSerial = func(st *state.State) (string, error) {
+		return "serial-1", nil
+	}
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.rollout.soak", "100h")
+	tr.Set("core", "refresh.rollout.hold-on-unhealthy", true)
+	tr.Commit()
+	st.Set("health", map[string]*healthstate.HealthState{
+		"foo": {Status: healthstate.ErrorStatus},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	refreshInfo := rsp.Result.(map[string]any)["refresh"].(client.RefreshInfo)
+	c.Check(refreshInfo.Rollout, check.DeepEquals, &client.RolloutInfo{
+		Bucket: 79,
+		Soak:   "100h0m0s",
+		Delay:  "79h0m0s",
+		Held:   []string{"foo"},
+	})
+}
+
+func (s *generalSuite) TestSysInfoMaintenanceWindows(c *check.C) {
+	s.expectSystemInfoReadAccess()
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "maintenance.windows", "mon1,00:00-00:01")
+	tr.Commit()
+	requested := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.Set("pending-reboot", &restart.PendingReboot{
+		RestartType: restart.RestartSystem,
+		BootID:      "boot-id",
+		RequestedAt: requested,
+	})
+	open, next, err := restart.InMaintenanceWindow(st, time.Now())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	expected := &client.MaintenanceWindowsInfo{
+		Windows: "mon1,00:00-00:01",
+		PendingReboot: &client.PendingRebootInfo{
+			RequestedAt: "2026-03-01T12:00:00Z",
+		},
+	}
+	if !open {
+		expected.Next = next.Truncate(time.Minute).Format(time.RFC3339)
+	}
+	c.Check(rsp.Result.(map[string]any)["maintenance-windows"], check.DeepEquals, expected)
+}
+
+func (s *generalSuite) TestSysInfoNoMaintenanceWindows(c *check.C) {
+	s.expectSystemInfoReadAccess()
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	_, ok := rsp.Result.(map[string]any)["maintenance-windows"]
+	c.Check(ok, check.Equals, false)
+}
+
 func (s *generalSuite) TestSysInfoWorksDegraded(c *check.C) {
 	s.expectSystemInfoReadAccess()
 	d := s.daemon(c)
@@ -997,6 +1084,80 @@ func (s *generalSuite) TestStateChangesForSnapNameWithApp(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 }
 
+func (s *generalSuite) TestStateChangesArchived(c *check.C) {
+	s.expectChangesReadAccess()
+	d := s.daemon(c)
+	spawnTime := time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC)
+	err := d.Overlord().ChangeArchive().Add(&changearchive.Change{
+		ID:        "1",
+		Kind:      "refresh-snap",
+		Summary:   "refresh...",
+		Status:    "Error",
+		Err:       "cannot perform the following tasks...",
+		SpawnTime: spawnTime,
+		ReadyTime: spawnTime.Add(time.Minute),
+		SnapNames: []string{"funky-snap-name"},
+		Tasks: []*changearchive.Task{{
+			ID:        "1",
+			Kind:      "link-snap",
+			Summary:   "1...",
+			Status:    "Error",
+			Log:       []string{"2016-04-21T01:02:03Z ERROR boom"},
+			Progress:  changearchive.Progress{Done: 1, Total: 1},
+			SpawnTime: spawnTime,
+		}},
+	}, &changearchive.Change{
+		ID:        "2",
+		Kind:      "install-snap",
+		Summary:   "install...",
+		Status:    "Done",
+		SpawnTime: spawnTime,
+		SnapNames: []string{"other-snap"},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=archived", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Result, check.FitsTypeOf, []*daemon.ChangeInfo(nil))
+	res := rsp.Result.([]*daemon.ChangeInfo)
+	c.Assert(res, check.HasLen, 2)
+
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, nil)
+	c.Assert(rec.Code, check.Equals, 200)
+	c.Check(rec.Body.String(), check.Matches, `.*{"id":"1","kind":"refresh-snap","summary":"refresh...","status":"Error","tasks":\[{"id":"1","kind":"link-snap","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR boom"\],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}\],"ready":true,"err":"cannot perform the following tasks...","spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:03:03Z"}.*`)
+
+	req, err = http.NewRequest("GET", "/v2/changes?select=archived&for=other-snap", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	res = rsp.Result.([]*daemon.ChangeInfo)
+	c.Assert(res, check.HasLen, 1)
+	c.Check(res[0].Kind, check.Equals, "install-snap")
+}
+
+func (s *generalSuite) TestStateChangeArchived(c *check.C) {
+	s.expectChangeReadAccess()
+	d := s.daemon(c)
+	err := d.Overlord().ChangeArchive().Add(&changearchive.Change{
+		ID:     "42",
+		Kind:   "refresh-snap",
+		Status: "Done",
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/changes/42", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Result, check.FitsTypeOf, &daemon.ChangeInfo{})
+	c.Check(rsp.Result.(*daemon.ChangeInfo).Kind, check.Equals, "refresh-snap")
+
+	req, err = http.NewRequest("GET", "/v2/changes/43", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 404)
+}
+
 func (s *generalSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
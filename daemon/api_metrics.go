@@ -0,0 +1,79 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var metricsCmd = &Command{
+	Path:       "/v2/metrics",
+	GET:        getMetrics,
+	ReadAccess: rootAccess{},
+}
+
+func getMetrics(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := validateFeatureFlag(st, features.Metrics); err != nil {
+		return err
+	}
+
+	return metricsResponse{stateMetrics(st)}
+}
+
+// stateMetrics returns a registry with metrics about the changes and tasks
+// currently in the state.
+//
+// The state must be locked by the caller.
+func stateMetrics(st *state.State) *metrics.Registry {
+	reg := metrics.NewRegistry()
+	changes := reg.NewGaugeVec("snapd_changes",
+		"Number of changes in the state, by kind and status.", "kind", "status")
+	tasks := reg.NewGaugeVec("snapd_tasks",
+		"Number of tasks in the state, by status.", "status")
+	for _, chg := range st.Changes() {
+		changes.Add(1, chg.Kind(), chg.Status().String())
+	}
+	for _, t := range st.Tasks() {
+		tasks.Add(1, t.Status().String())
+	}
+	return reg
+}
+
+// metricsResponse serves the metrics of the default registry and of the
+// given registries in the OpenMetrics text format.
+type metricsResponse []*metrics.Registry
+
+func (mr metricsResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	registries := append([]*metrics.Registry{metrics.DefaultRegistry}, mr...)
+	if err := metrics.WriteOpenMetrics(w, registries...); err != nil {
+		logger.Debugf("cannot write metrics: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/metrics"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = Suite(&metricsSuite{})
+
+type metricsSuite struct {
+	apiBaseSuite
+}
+
+func (s *metricsSuite) SetUpTest(c *C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectRootAccess()
+}
+
+func (s *metricsSuite) setFeatureFlag(c *C, st *state.State) {
+	_, confOption := features.Metrics.ConfigOption()
+
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", confOption, true), IsNil)
+	tr.Commit()
+}
+
+func (s *metricsSuite) TestGetMetricsFeatureFlagDisabled(c *C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/metrics", nil)
+	c.Assert(err, IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `feature flag "metrics" is disabled: set 'experimental.metrics' to true`)
+}
+
+func (s *metricsSuite) TestGetMetrics(c *C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	s.setFeatureFlag(c, st)
+
+	st.Lock()
+	chg := st.NewChange("install-snap", "...")
+	t := st.NewTask("download-snap", "...")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	chg = st.NewChange("remove-snap", "...")
+	chg.AddTask(st.NewTask("unlink-snap", "..."))
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/metrics", nil)
+	c.Assert(err, IsNil)
+
+	rsp := s.req(c, req, nil, actionIsUnexpected)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	c.Check(rec.Code, Equals, 200)
+	c.Check(rec.Header().Get("Content-Type"), Equals, metrics.ContentType)
+	body := rec.Body.String()
+	c.Check(body, testutil.Contains, `snapd_changes{kind="install-snap",status="Done"} 1
+snapd_changes{kind="remove-snap",status="Do"} 1
+`)
+	c.Check(body, testutil.Contains, `snapd_tasks{status="Do"} 1
+snapd_tasks{status="Done"} 1
+`)
+	// metrics of the default registry are included too
+	c.Check(body, testutil.Contains, "# TYPE snapd_state_lock_wait_seconds histogram\n")
+	c.Check(body, testutil.Contains, "# TYPE snapd_changes_completed counter\n")
+	c.Check(body, Matches, `(?s).*# EOF\n$`)
+}
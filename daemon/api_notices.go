@@ -34,6 +34,8 @@ var noticeReadInterfaces = map[state.NoticeType][]string{
 	state.ChangeUpdateNotice:                 {"snap-refresh-observe"},
 	state.RefreshInhibitNotice:               {"snap-refresh-observe"},
 	state.SnapRunInhibitNotice:               {"snap-refresh-observe"},
+	state.SnapHealthRollbackNotice:           {"snap-refresh-observe"},
+	state.PendingRebootNotice:                {"snap-refresh-observe"},
 	state.InterfacesRequestsPromptNotice:     {"snap-interfaces-requests-control"},
 	state.InterfacesRequestsRuleUpdateNotice: {"snap-interfaces-requests-control"},
 }
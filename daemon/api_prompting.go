@@ -25,11 +25,13 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/prompting"
 	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/requestaudit"
 	"github.com/snapcore/snapd/interfaces/prompting/requestprompts"
 	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -71,7 +73,7 @@ var (
 		Path:       "/v2/interfaces/requests/rules",
 		GET:        getRules,
 		POST:       postRules,
-		Actions:    []string{"add", "remove"},
+		Actions:    []string{"add", "remove", "import"},
 		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
 		// postRules can only operate on rules associated with the user making
 		// the API request, so there is no need for polkit authentication.
@@ -89,6 +91,22 @@ var (
 		// authentication.
 		WriteAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
 	}
+
+	// Admin rules are defined through files owned by root or by the gadget,
+	// and can only be read through the API.
+	requestsAdminRulesCmd = &Command{
+		Path:       "/v2/interfaces/requests/admin-rules",
+		GET:        getAdminRules,
+		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
+	}
+
+	// The audit log holds the decisions made for requests of every user, so
+	// it is only available to admins.
+	requestsAuditCmd = &Command{
+		Path:       "/v2/interfaces/requests/audit",
+		GET:        getAudit,
+		ReadAccess: rootAccess{},
+	}
 )
 
 var (
@@ -127,6 +145,24 @@ func getUserID(r *http.Request) (uint32, Response) {
 	return uint32(userIDInt), nil
 }
 
+// promptingClient identifies the client making the given API request for the
+// audit log, by its PID and, if it is a snap, its security tag.
+func promptingClient(r *http.Request) string {
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	client := fmt.Sprintf("pid=%d", ucred.Pid)
+	cgroupPath, err := cgroupProcessPathInTrackingCgroup(int(ucred.Pid))
+	if err != nil {
+		return client
+	}
+	if securityTag := cgroup.SecurityTagFromCgroupPath(cgroupPath); securityTag != nil {
+		client = fmt.Sprintf("%s %s", securityTag, client)
+	}
+	return client
+}
+
 // isClientActivity returns true if the request comes a prompting handler
 // service.
 func isClientActivity(c *Command, r *http.Request) bool {
@@ -356,9 +392,10 @@ type patchRuleContents struct {
 }
 
 type postRulesRequestBody struct {
-	Action         string               `json:"action"`
-	AddRule        *addRuleContents     `json:"rule,omitempty"`
-	RemoveSelector *removeRulesSelector `json:"selector,omitempty"`
+	Action         string                       `json:"action"`
+	AddRule        *addRuleContents             `json:"rule,omitempty"`
+	RemoveSelector *removeRulesSelector         `json:"selector,omitempty"`
+	ImportRules    []*requestrules.RuleContents `json:"rules,omitempty"`
 }
 
 type postRuleRequestBody struct {
@@ -535,8 +572,9 @@ func postPrompt(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 
 	clientActivity := isClientActivity(c, r)
+	client := promptingClient(r)
 
-	satisfiedPromptIDs, err := getInterfaceManager(c).InterfacesRequestsManager().HandleReply(userID, promptID, reply.Constraints, reply.Outcome, reply.Lifespan, reply.Duration, clientActivity)
+	satisfiedPromptIDs, err := getInterfaceManager(c).InterfacesRequestsManager().HandleReply(userID, promptID, reply.Constraints, reply.Outcome, reply.Lifespan, reply.Duration, clientActivity, client)
 	if err != nil {
 		return promptingError(err)
 	}
@@ -561,6 +599,14 @@ func getRules(c *Command, r *http.Request, user *auth.UserState) Response {
 	query := r.URL.Query()
 	snap := query.Get("snap")
 	iface := query.Get("interface")
+	export := false
+	if exportStr := query.Get("export"); exportStr != "" {
+		var err error
+		export, err = strconv.ParseBool(exportStr)
+		if err != nil {
+			return BadRequest(`invalid "export" parameter: %q`, exportStr)
+		}
+	}
 
 	rules, err := getInterfaceManager(c).InterfacesRequestsManager().Rules(userID, snap, iface)
 	if err != nil {
@@ -568,6 +614,14 @@ func getRules(c *Command, r *http.Request, user *auth.UserState) Response {
 		return promptingError(err)
 	}
 
+	if export {
+		exported, err := requestrules.ExportRules(rules)
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		return SyncResponse(exported)
+	}
+
 	if len(rules) == 0 {
 		rules = []*requestrules.Rule{}
 	}
@@ -575,6 +629,68 @@ func getRules(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(rules)
 }
 
+func getAdminRules(c *Command, r *http.Request, user *auth.UserState) Response {
+	if !getInterfaceManager(c).AppArmorPromptingRunning() {
+		return promptingNotRunningError()
+	}
+
+	query := r.URL.Query()
+	snap := query.Get("snap")
+	iface := query.Get("interface")
+
+	rules, err := getInterfaceManager(c).InterfacesRequestsManager().AdminRules(snap, iface)
+	if err != nil {
+		return promptingError(err)
+	}
+
+	if len(rules) == 0 {
+		rules = []*requestrules.AdminRule{}
+	}
+
+	return SyncResponse(rules)
+}
+
+func getAudit(c *Command, r *http.Request, user *auth.UserState) Response {
+	if !getInterfaceManager(c).AppArmorPromptingRunning() {
+		return promptingNotRunningError()
+	}
+
+	query := r.URL.Query()
+	filter := &requestaudit.Filter{
+		Snap:      query.Get("snap"),
+		Interface: query.Get("interface"),
+	}
+	if userIDStr := query.Get("user-id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			return BadRequest(`invalid "user-id" parameter: %q`, userIDStr)
+		}
+		uid := uint32(userID)
+		filter.User = &uid
+	}
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return BadRequest(`invalid "since" parameter: %q`, sinceStr)
+		}
+		filter.Since = since
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			return BadRequest(`invalid "limit" parameter: %q`, limitStr)
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := getInterfaceManager(c).InterfacesRequestsManager().AuditEntries(filter)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	return SyncResponse(entries)
+}
+
 func postRules(c *Command, r *http.Request, user *auth.UserState) Response {
 	userID, errorResp := getUserID(r)
 	if errorResp != nil {
@@ -613,8 +729,17 @@ func postRules(c *Command, r *http.Request, user *auth.UserState) Response {
 			return promptingError(err)
 		}
 		return SyncResponse(removedRules)
+	case "import":
+		if len(postBody.ImportRules) == 0 {
+			return BadRequest(`must include non-empty "rules" field in request body when action is "import"`)
+		}
+		importedRules, err := getInterfaceManager(c).InterfacesRequestsManager().ImportRules(userID, postBody.ImportRules)
+		if err != nil {
+			return promptingError(err)
+		}
+		return SyncResponse(importedRules)
 	default:
-		return BadRequest(`"action" field must be "create" or "remove"`)
+		return BadRequest(`"action" field must be "add", "remove", or "import"`)
 	}
 }
 
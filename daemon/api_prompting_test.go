@@ -34,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/prompting"
 	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
 	"github.com/snapcore/snapd/interfaces/prompting/patterns"
+	"github.com/snapcore/snapd/interfaces/prompting/requestaudit"
 	"github.com/snapcore/snapd/interfaces/prompting/requestprompts"
 	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/overlord/ifacestate/apparmorprompting"
@@ -49,6 +50,8 @@ type fakeInterfacesRequestsManager struct {
 	rules        []*requestrules.Rule
 	prompt       *requestprompts.Prompt
 	rule         *requestrules.Rule
+	adminRules   []*requestrules.AdminRule
+	auditEntries []*requestaudit.Entry
 	satisfiedIDs []prompting.IDType
 	err          error
 
@@ -67,6 +70,9 @@ type fakeInterfacesRequestsManager struct {
 	lifespan             prompting.LifespanType
 	duration             string
 	clientActivity       bool
+	client               string
+	importContents       []*requestrules.RuleContents
+	auditFilter          *requestaudit.Filter
 }
 
 func (m *fakeInterfacesRequestsManager) Ask(uid uint32, iface, snap string, pid int32, cgroup string, snapdShuttingDown <-chan struct{}) (prompting.OutcomeType, error) {
@@ -92,7 +98,7 @@ func (m *fakeInterfacesRequestsManager) PromptWithID(userID uint32, promptID pro
 	return m.prompt, m.err
 }
 
-func (m *fakeInterfacesRequestsManager) HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool) ([]prompting.IDType, error) {
+func (m *fakeInterfacesRequestsManager) HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool, client string) ([]prompting.IDType, error) {
 	m.userID = userID
 	m.id = promptID
 	m.replyConstraintsJSON = replyConstraintsJSON
@@ -100,6 +106,7 @@ func (m *fakeInterfacesRequestsManager) HandleReply(userID uint32, promptID prom
 	m.lifespan = lifespan
 	m.duration = duration
 	m.clientActivity = clientActivity
+	m.client = client
 	return m.satisfiedIDs, m.err
 }
 
@@ -144,6 +151,23 @@ func (m *fakeInterfacesRequestsManager) RemoveRule(userID uint32, ruleID prompti
 	return m.rule, m.err
 }
 
+func (m *fakeInterfacesRequestsManager) ImportRules(userID uint32, contents []*requestrules.RuleContents) ([]*requestrules.Rule, error) {
+	m.userID = userID
+	m.importContents = contents
+	return m.rules, m.err
+}
+
+func (m *fakeInterfacesRequestsManager) AdminRules(snap string, iface string) ([]*requestrules.AdminRule, error) {
+	m.snap = snap
+	m.iface = iface
+	return m.adminRules, m.err
+}
+
+func (m *fakeInterfacesRequestsManager) AuditEntries(filter *requestaudit.Filter) ([]*requestaudit.Entry, error) {
+	m.auditFilter = filter
+	return m.auditEntries, m.err
+}
+
 type promptingSuite struct {
 	apiBaseSuite
 
@@ -1029,6 +1053,12 @@ func (s *promptingSuite) TestPostPromptHappy(c *C) {
 
 	s.daemon(c)
 
+	restore := daemon.MockCgroupProcessPathInTrackingCgroup(func(pid int) (string, error) {
+		c.Check(pid, Equals, 100)
+		return "/user.slice/user-1000.slice/user@1000.service/app.slice/snap.prompting-client.daemon.service", nil
+	})
+	defer restore()
+
 	s.manager.satisfiedIDs = []prompting.IDType{
 		prompting.IDType(1234),
 		prompting.IDType(0),
@@ -1059,6 +1089,7 @@ func (s *promptingSuite) TestPostPromptHappy(c *C) {
 	c.Check(s.manager.lifespan, Equals, contents.Lifespan)
 	c.Check(s.manager.duration, Equals, contents.Duration)
 	c.Check(s.manager.clientActivity, Equals, true)
+	c.Check(s.manager.client, Equals, "snap.prompting-client.daemon pid=100")
 
 	// Check return value
 	satisfiedIDs, ok := rsp.Result.([]prompting.IDType)
@@ -1331,6 +1362,194 @@ func (s *promptingSuite) TestPostRulesRemoveHappy(c *C) {
 	}
 }
 
+func (s *promptingSuite) TestGetRulesExport(c *C) {
+	s.daemon(c)
+
+	s.manager.rules = []*requestrules.Rule{
+		{
+			ID:        prompting.IDType(0xabcd),
+			Timestamp: time.Now(),
+			User:      1234,
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: &prompting.RuleConstraints{
+				InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+					Pattern: mustParsePathPattern(c, "/foo/bar"),
+				},
+				Permissions: prompting.RulePermissionMap{
+					"write": &prompting.RulePermissionEntry{
+						Outcome:  prompting.OutcomeDeny,
+						Lifespan: prompting.LifespanForever,
+					},
+				},
+			},
+		},
+	}
+
+	rsp := s.makeSyncReq(c, "GET", "/v2/interfaces/requests/rules?snap=firefox&export=true", 1234, nil)
+	c.Check(s.manager.userID, Equals, uint32(1234))
+	c.Check(s.manager.snap, Equals, "firefox")
+
+	exported, ok := rsp.Result.([]*requestrules.RuleContents)
+	c.Assert(ok, Equals, true)
+	data, err := json.Marshal(exported)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `[{"snap":"firefox","interface":"home","constraints":{"path-pattern":"/foo/bar","permissions":{"write":{"outcome":"deny","lifespan":"forever"}}}}]`)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces/requests/rules?export=maybe", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1234;socket=;"
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `invalid "export" parameter: "maybe"`)
+}
+
+func (s *promptingSuite) TestPostRulesImport(c *C) {
+	s.expectWriteAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}})
+
+	s.daemon(c)
+
+	s.manager.rules = []*requestrules.Rule{
+		{
+			ID:        prompting.IDType(1234),
+			Timestamp: time.Now(),
+			User:      11235,
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: &prompting.RuleConstraints{
+				InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+					Pattern: mustParsePathPattern(c, "/home/test/**"),
+				},
+				Permissions: prompting.RulePermissionMap{
+					"read": &prompting.RulePermissionEntry{
+						Outcome:  prompting.OutcomeAllow,
+						Lifespan: prompting.LifespanForever,
+					},
+				},
+			},
+		},
+	}
+
+	contents := []*requestrules.RuleContents{{
+		Snap:      "firefox",
+		Interface: "home",
+		Constraints: prompting.ConstraintsJSON{
+			"path-pattern": json.RawMessage(`"/home/test/**"`),
+			"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+		},
+	}}
+	postBody := &daemon.PostRulesRequestBody{
+		Action:      "import",
+		ImportRules: contents,
+	}
+	marshalled, err := json.Marshal(postBody)
+	c.Assert(err, IsNil)
+
+	rsp := s.makeSyncReq(c, "POST", "/v2/interfaces/requests/rules", 11235, marshalled)
+	c.Check(s.manager.userID, Equals, uint32(11235))
+	c.Check(s.manager.importContents, DeepEquals, contents)
+	rules, ok := rsp.Result.([]*requestrules.Rule)
+	c.Check(ok, Equals, true)
+	c.Check(rules, DeepEquals, s.manager.rules)
+
+	// rules must be given
+	req, err := http.NewRequest("POST", "/v2/interfaces/requests/rules", bytes.NewReader([]byte(`{"action":"import","rules":[]}`)))
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=11235;socket=;"
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `must include non-empty "rules" field in request body when action is "import"`)
+}
+
+func (s *promptingSuite) TestGetAdminRules(c *C) {
+	s.daemon(c)
+
+	s.manager.adminRules = []*requestrules.AdminRule{
+		{
+			Interface: "home",
+			Constraints: &prompting.RuleConstraints{
+				InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+					Pattern: mustParsePathPattern(c, "/home/*/.ssh/**"),
+				},
+				Permissions: prompting.RulePermissionMap{
+					"read": &prompting.RulePermissionEntry{
+						Outcome:  prompting.OutcomeDeny,
+						Lifespan: prompting.LifespanForever,
+					},
+				},
+			},
+			Source: "admin:corp.json",
+		},
+	}
+
+	rsp := s.makeSyncReq(c, "GET", "/v2/interfaces/requests/admin-rules?snap=firefox&interface=home", 1234, nil)
+	c.Check(s.manager.snap, Equals, "firefox")
+	c.Check(s.manager.iface, Equals, "home")
+	rules, ok := rsp.Result.([]*requestrules.AdminRule)
+	c.Check(ok, Equals, true)
+	c.Check(rules, DeepEquals, s.manager.adminRules)
+
+	s.manager.adminRules = nil
+	rsp = s.makeSyncReq(c, "GET", "/v2/interfaces/requests/admin-rules", 1234, nil)
+	c.Check(rsp.Result, DeepEquals, []*requestrules.AdminRule{})
+}
+
+func (s *promptingSuite) TestGetAudit(c *C) {
+	s.expectReadAccess(daemon.RootAccess{})
+	s.daemon(c)
+
+	s.manager.auditEntries = []*requestaudit.Entry{
+		{
+			Timestamp:   time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+			User:        1000,
+			Snap:        "firefox",
+			Interface:   "home",
+			Path:        "/home/test/foo",
+			Permissions: []string{"read"},
+			Allowed:     []string{"read"},
+			DecidedBy:   requestaudit.DecidedByRules,
+			Rules:       []string{"0000000000000002"},
+		},
+	}
+
+	rsp := s.makeSyncReq(c, "GET", "/v2/interfaces/requests/audit?user-id=1000&snap=firefox&interface=home&since=2026-03-01T09:00:00Z&limit=10", 0, nil)
+	user := uint32(1000)
+	c.Check(s.manager.auditFilter, DeepEquals, &requestaudit.Filter{
+		User:      &user,
+		Snap:      "firefox",
+		Interface: "home",
+		Since:     time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		Limit:     10,
+	})
+	entries, ok := rsp.Result.([]*requestaudit.Entry)
+	c.Check(ok, Equals, true)
+	c.Check(entries, DeepEquals, s.manager.auditEntries)
+
+	s.makeSyncReq(c, "GET", "/v2/interfaces/requests/audit", 0, nil)
+	c.Check(s.manager.auditFilter, DeepEquals, &requestaudit.Filter{})
+}
+
+func (s *promptingSuite) TestGetAuditErrors(c *C) {
+	s.expectReadAccess(daemon.RootAccess{})
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query  string
+		errMsg string
+	}{
+		{"user-id=foo", `invalid "user-id" parameter: "foo"`},
+		{"since=yesterday", `invalid "since" parameter: "yesterday"`},
+		{"limit=-1", `invalid "limit" parameter: "-1"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/interfaces/requests/audit?"+t.query, nil)
+		c.Assert(err, IsNil)
+		req.RemoteAddr = "pid=100;uid=0;socket=;"
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, Equals, 400)
+		c.Check(rspe.Message, Equals, t.errMsg)
+	}
+}
+
 func (s *promptingSuite) TestGetRuleHappy(c *C) {
 	s.daemon(c)
 
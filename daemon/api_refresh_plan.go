@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// snapRefreshPlan builds the task sets of a refresh of the snaps of the
+// instruction and describes what they would do instead of running them.
+// Unlike an actual refresh, assertions are not refreshed first, as that
+// would update the tracking of validation sets, so the plan is based on the
+// assertions already known.
+func snapRefreshPlan(ctx context.Context, inst *snapInstruction, st *state.State) Response {
+	names := inst.Snaps
+	if len(names) == 0 {
+		all, err := snapstate.All(st)
+		if err != nil {
+			return InternalError("cannot get installed snaps: %v", err)
+		}
+		for name := range all {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	plan := &client.RefreshPlan{}
+	conflicting := make(map[string]bool)
+	addConflict := func(err error) bool {
+		var conflictErr *snapstate.ChangeConflictError
+		if !errors.As(err, &conflictErr) {
+			return false
+		}
+		if !conflicting[conflictErr.Snap] {
+			conflicting[conflictErr.Snap] = true
+			plan.Conflicts = append(plan.Conflicts, client.RefreshPlanConflict{
+				Snap:       conflictErr.Snap,
+				ChangeKind: conflictErr.ChangeKind,
+				ChangeID:   conflictErr.ChangeID,
+				Message:    conflictErr.Error(),
+			})
+		}
+		return true
+	}
+	for _, name := range names {
+		if err := snapstate.CheckChangeConflict(st, name, nil); err != nil && !addConflict(err) {
+			return InternalError("cannot check conflicts of snap %q: %v", name, err)
+		}
+	}
+
+	goal, opts := inst.updateManyGoal()
+	_, uts, err := snapstateUpdateWithGoal(ctx, st, goal, nil, opts)
+	if err != nil {
+		if addConflict(err) {
+			// the refresh cannot be done at all
+			return SyncResponse(plan)
+		}
+		return inst.errToResponse(err)
+	}
+
+	var tasks []*state.Task
+	for _, tss := range [][]*state.TaskSet{uts.Refresh, uts.PreDownload} {
+		for _, ts := range tss {
+			tasks = append(tasks, ts.Tasks()...)
+		}
+	}
+	defer st.DiscardTasks(tasks)
+
+	refreshPlan, err := snapstate.NewRefreshPlan(st, uts.Refresh, nil)
+	if err != nil {
+		return InternalError("cannot describe refresh: %v", err)
+	}
+	plan.DownloadSize = refreshPlan.DownloadSize
+	plan.RebootRequired = refreshPlan.RebootRequired
+	for _, ps := range refreshPlan.Snaps {
+		plan.Snaps = append(plan.Snaps, refreshPlanSnap(ps))
+	}
+	return SyncResponse(plan)
+}
+
+func refreshPlanSnap(ps *snapstate.RefreshPlanSnap) client.RefreshPlanSnap {
+	planSnap := client.RefreshPlanSnap{
+		Name:            ps.InstanceName,
+		Type:            string(ps.Type),
+		Channel:         ps.Channel,
+		CurrentRevision: ps.CurrentRevision,
+		Revision:        ps.Revision,
+		DownloadSize:    ps.DownloadSize,
+		AutoConnect:     ps.AutoConnect,
+		RebootRequired:  ps.RebootRequired,
+		Tasks:           ps.Tasks,
+	}
+	for _, comp := range ps.Components {
+		planSnap.Components = append(planSnap.Components, client.RefreshPlanComponent{
+			Name:            comp.Name,
+			CurrentRevision: comp.CurrentRevision,
+			Revision:        comp.Revision,
+			DownloadSize:    comp.DownloadSize,
+		})
+	}
+	for _, prereq := range ps.Prerequisites {
+		planSnap.Prerequisites = append(planSnap.Prerequisites, client.RefreshPlanPrerequisite{
+			Name:      prereq.Name,
+			Installed: prereq.Installed,
+			Content:   prereq.Content,
+		})
+	}
+	return planSnap
+}
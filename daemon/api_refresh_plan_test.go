@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&refreshPlanSuite{})
+
+type refreshPlanSuite struct {
+	apiBaseSuite
+}
+
+func (s *refreshPlanSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+
+	s.AddCleanup(daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error {
+		return nil
+	}))
+}
+
+func (s *refreshPlanSuite) mockInstalled(st *state.State, name string, typ snap.Type, rev snap.Revision) {
+	snapstate.Set(st, name, &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: name, SnapID: name + "-id", Revision: rev},
+		}),
+		Current:  rev,
+		SnapType: string(typ),
+	})
+}
+
+func (s *refreshPlanSuite) dryRunReq(c *check.C, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/v2/snaps", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *refreshPlanSuite) TestRefreshDryRun(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	s.mockModel(st, nil)
+	s.mockInstalled(st, "foo", snap.TypeApp, snap.R(1))
+	s.mockInstalled(st, "core22", snap.TypeBase, snap.R(5))
+	st.Unlock()
+
+	defer daemon.MockSnapstateUpdateWithGoal(func(_ context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		c.Check(g.(*storeUpdateGoalRecorder).names(), check.DeepEquals, []string{"foo"})
+		prereq := st.NewTask("prerequisites", `Ensure prerequisites for "foo" are available`)
+		prereq.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo:     &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(2)},
+			Type:         snap.TypeApp,
+			Base:         "core22",
+			Channel:      "latest/stable",
+			DownloadInfo: &snap.DownloadInfo{Size: 1024},
+			PrereqContentAttrs: map[string][]string{
+				"gtk-common-themes": {"gtk-3-themes"},
+			},
+		})
+		download := st.NewTask("download-snap", `Download snap "foo" (2) from channel "latest/stable"`)
+		download.Set("snap-setup-task", prereq.ID())
+		autoConnect := st.NewTask("auto-connect", `Automatically connect eligible plugs and slots of snap "foo"`)
+		autoConnect.Set("snap-setup-task", prereq.ID())
+		ts := state.NewTaskSet(prereq, download, autoConnect)
+		return []string{"foo"}, &snapstate.UpdateTaskSets{Refresh: []*state.TaskSet{ts}}, nil
+	})()
+
+	rsp := s.syncReq(c, s.dryRunReq(c, `{"action": "refresh", "snaps": ["foo"], "dry-run": true}`), nil, actionIsExpected)
+	c.Check(rsp.Result, check.DeepEquals, &client.RefreshPlan{
+		Snaps: []client.RefreshPlanSnap{{
+			Name:            "foo",
+			Type:            "app",
+			Channel:         "latest/stable",
+			CurrentRevision: snap.R(1),
+			Revision:        snap.R(2),
+			DownloadSize:    1024,
+			Prerequisites: []client.RefreshPlanPrerequisite{
+				{Name: "core22", Installed: true},
+				{Name: "gtk-common-themes", Content: []string{"gtk-3-themes"}},
+			},
+			AutoConnect: true,
+			Tasks: []string{
+				`Ensure prerequisites for "foo" are available`,
+				`Download snap "foo" (2) from channel "latest/stable"`,
+				`Automatically connect eligible plugs and slots of snap "foo"`,
+			},
+		}},
+		DownloadSize: 1024,
+	})
+
+	// nothing was left behind
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
+
+func (s *refreshPlanSuite) TestRefreshDryRunLeavesStateAlone(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	s.mockModel(st, nil)
+	s.mockInstalled(st, "foo", snap.TypeApp, snap.R(1))
+	s.mockInstalled(st, "bar", snap.TypeApp, snap.R(1))
+	st.Unlock()
+
+	// refreshing assertions could change the tracking of validation sets
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error {
+		c.Error("unexpected refresh of assertions")
+		return nil
+	})()
+	defer daemon.MockSnapstateUpdateWithGoal(func(_ context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		prereq := st.NewTask("prerequisites", `Ensure prerequisites for "foo" are available`)
+		prereq.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(2)},
+			Type:     snap.TypeApp,
+		})
+		preDownload := st.NewTask("pre-download-snap", `Pre-download snap "bar" (2)`)
+		return []string{"foo"}, &snapstate.UpdateTaskSets{
+			Refresh:     []*state.TaskSet{state.NewTaskSet(prereq)},
+			PreDownload: []*state.TaskSet{state.NewTaskSet(preDownload)},
+		}, nil
+	})()
+
+	rsp := s.syncReq(c, s.dryRunReq(c, `{"action": "refresh", "dry-run": true}`), nil, actionIsExpected)
+	plan, ok := rsp.Result.(*client.RefreshPlan)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(plan.Snaps, check.HasLen, 1)
+	c.Check(plan.Snaps[0].Name, check.Equals, "foo")
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
+
+func (s *refreshPlanSuite) TestRefreshDryRunConflicts(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	s.mockModel(st, nil)
+	s.mockInstalled(st, "foo", snap.TypeApp, snap.R(1))
+	s.mockInstalled(st, "bar", snap.TypeApp, snap.R(1))
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "bar", Revision: snap.R(2)},
+	})
+	chg.AddTask(t)
+	st.Unlock()
+
+	defer daemon.MockSnapstateUpdateWithGoal(func(_ context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		return nil, nil, snapstate.CheckChangeConflict(st, "bar", nil)
+	})()
+
+	rsp := s.syncReq(c, s.dryRunReq(c, `{"action": "refresh", "dry-run": true}`), nil, actionIsExpected)
+	c.Check(rsp.Result, check.DeepEquals, &client.RefreshPlan{
+		Conflicts: []client.RefreshPlanConflict{{
+			Snap:       "bar",
+			ChangeKind: "install",
+			ChangeID:   chg.ID(),
+			Message:    `snap "bar" has "install" change in progress`,
+		}},
+	})
+}
+
+func (s *refreshPlanSuite) TestDryRunUnsupported(c *check.C) {
+	s.daemon(c)
+
+	for _, tc := range []struct {
+		path, body, err string
+	}{
+		{"/v2/snaps", `{"action": "remove", "snaps": ["foo"], "dry-run": true}`, `dry-run can only be specified for the "refresh" action`},
+		{"/v2/snaps/foo", `{"action": "refresh", "dry-run": true}`, `dry-run is only supported for multi-snap refreshes`},
+		{"/v2/snaps", `{"action": "refresh", "validation-sets": ["foo/bar"], "dry-run": true}`, `dry-run cannot be used with validation sets`},
+	} {
+		req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, tc.err)
+	}
+}
@@ -199,6 +199,10 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	inst.Snaps = []string{vars["name"]}
 
+	if inst.DryRun {
+		return BadRequest("dry-run is only supported for multi-snap refreshes")
+	}
+
 	if len(inst.CompsRaw) > 0 {
 		// must be a string slice for /v2/snaps/<snap>
 		if err := inst.setCompsFromRawList(); err != nil {
@@ -298,10 +302,13 @@ type snapInstruction struct {
 	Snaps                  []string                         `json:"snaps"`
 	Users                  []string                         `json:"users"`
 	SnapshotOptions        map[string]*snap.SnapshotOptions `json:"snapshot-options"`
+	SnapshotEncrypt        string                           `json:"snapshot-encrypt,omitempty"`
+	SnapshotPassphrase     string                           `json:"snapshot-passphrase,omitempty"`
 	ValidationSets         []string                         `json:"validation-sets"`
 	QuotaGroupName         string                           `json:"quota-group"`
 	Time                   string                           `json:"time"`
 	HoldLevel              string                           `json:"hold-level"`
+	DryRun                 bool                             `json:"dry-run"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -404,6 +411,31 @@ func (inst *snapInstruction) validateSnapshotOptions() error {
 	return nil
 }
 
+func (inst *snapInstruction) validateSnapshotEncryption() error {
+	if inst.SnapshotEncrypt == "" {
+		if inst.SnapshotPassphrase != "" {
+			return fmt.Errorf("snapshot-passphrase can only be specified with snapshot-encrypt")
+		}
+		return nil
+	}
+	if inst.Action != snapshotCmdAction {
+		return fmt.Errorf("snapshot-encrypt can only be specified for snapshot action")
+	}
+	switch inst.SnapshotEncrypt {
+	case "passphrase":
+		if inst.SnapshotPassphrase == "" {
+			return fmt.Errorf("snapshot-encrypt %q requires a snapshot-passphrase", inst.SnapshotEncrypt)
+		}
+	case "device":
+		if inst.SnapshotPassphrase != "" {
+			return fmt.Errorf("snapshot-passphrase cannot be specified with snapshot-encrypt %q", inst.SnapshotEncrypt)
+		}
+	default:
+		return fmt.Errorf(`snapshot-encrypt must be "passphrase" or "device", not %q`, inst.SnapshotEncrypt)
+	}
+	return nil
+}
+
 func (inst *snapInstruction) validate() error {
 	if inst.CohortKey != "" {
 		if inst.Action != installCmdAction && inst.Action != refreshCmdAction && inst.Action != switchCmdAction {
@@ -468,6 +500,13 @@ func (inst *snapInstruction) validate() error {
 		return fmt.Errorf("the prefer flag can only be specified on install")
 	}
 
+	if inst.DryRun && inst.Action != refreshCmdAction {
+		return fmt.Errorf(`dry-run can only be specified for the "refresh" action`)
+	}
+	if inst.DryRun && len(inst.ValidationSets) > 0 {
+		return fmt.Errorf("dry-run cannot be used with validation sets")
+	}
+
 	if inst.Terminate && inst.Action != removeCmdAction {
 		return fmt.Errorf(`terminate can only be specified for the "remove" action`)
 	}
@@ -478,6 +517,9 @@ func (inst *snapInstruction) validate() error {
 	if err := inst.validateSnapshotOptions(); err != nil {
 		return err
 	}
+	if err := inst.validateSnapshotEncryption(); err != nil {
+		return err
+	}
 
 	if inst.Action == snapshotCmdAction {
 		inst.cleanSnapshotOptions()
@@ -886,6 +928,10 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
 
+	if inst.DryRun {
+		return snapRefreshPlan(r.Context(), &inst, st)
+	}
+
 	res, err := op(r.Context(), &inst, st)
 	if err != nil {
 		return inst.errToResponse(err)
@@ -1047,18 +1093,9 @@ func snapInstallMany(ctx context.Context, inst *snapInstruction, st *state.State
 	}, nil
 }
 
-func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	// we need refreshed snap-declarations to enforce refresh-control as best as
-	// we can, this also ensures that snap-declarations and their prerequisite
-	// assertions are updated regularly; update validation sets assertions only
-	// if refreshing all snaps (no snap names explicitly requested).
-	opts := &assertstate.RefreshAssertionsOptions{
-		IsRefreshOfAllSnaps: len(inst.Snaps) == 0,
-	}
-	if err := assertstateRefreshSnapAssertions(st, inst.userID, opts); err != nil {
-		return nil, err
-	}
-
+// updateManyGoal returns the goal and options to refresh the snaps of the
+// instruction, or all snaps if none were given.
+func (inst *snapInstruction) updateManyGoal() (snapstate.UpdateGoal, snapstate.Options) {
 	updates := make([]snapstate.StoreUpdate, 0, len(inst.Snaps))
 	for _, name := range inst.Snaps {
 		updates = append(updates, snapstate.StoreUpdate{
@@ -1078,10 +1115,23 @@ func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State)
 		flags.Transaction = client.TransactionPerSnap
 	}
 
-	goal := snapstateStoreUpdateGoal(updates...)
-	updated, uts, err := snapstateUpdateWithGoal(ctx, st, goal, nil, snapstate.Options{
-		Flags: flags,
-	})
+	return snapstateStoreUpdateGoal(updates...), snapstate.Options{Flags: flags}
+}
+
+func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
+	// we need refreshed snap-declarations to enforce refresh-control as best as
+	// we can, this also ensures that snap-declarations and their prerequisite
+	// assertions are updated regularly; update validation sets assertions only
+	// if refreshing all snaps (no snap names explicitly requested).
+	opts := &assertstate.RefreshAssertionsOptions{
+		IsRefreshOfAllSnaps: len(inst.Snaps) == 0,
+	}
+	if err := assertstateRefreshSnapAssertions(st, inst.userID, opts); err != nil {
+		return nil, err
+	}
+
+	goal, updateOpts := inst.updateManyGoal()
+	updated, uts, err := snapstateUpdateWithGoal(ctx, st, goal, nil, updateOpts)
 	if err != nil {
 		if opts.IsRefreshOfAllSnaps {
 			if err := assertstateRestoreValidationSetsTracking(st); err != nil && !errors.Is(err, state.ErrNoState) {
@@ -519,6 +519,28 @@ func (s *snapsSuite) TestPostSnapsOptionsUnsupportedActionError(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapsSnapshotEncryptionErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		post, err string
+	}{
+		{`{"action": "install", "snaps": ["foo"], "snapshot-encrypt": "device"}`, `snapshot-encrypt can only be specified for snapshot action`},
+		{`{"action": "snapshot", "snaps": ["foo"], "snapshot-passphrase": "x"}`, `snapshot-passphrase can only be specified with snapshot-encrypt`},
+		{`{"action": "snapshot", "snaps": ["foo"], "snapshot-encrypt": "passphrase"}`, `snapshot-encrypt "passphrase" requires a snapshot-passphrase`},
+		{`{"action": "snapshot", "snaps": ["foo"], "snapshot-encrypt": "device", "snapshot-passphrase": "x"}`, `snapshot-passphrase cannot be specified with snapshot-encrypt "device"`},
+		{`{"action": "snapshot", "snaps": ["foo"], "snapshot-encrypt": "tpm"}`, `snapshot-encrypt must be "passphrase" or "device", not "tpm"`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", strings.NewReader(t.post))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%s", t.post))
+		c.Check(rspe.Message, check.Equals, t.err, check.Commentf("%s", t.post))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapsOptionsOtherErrors(c *check.C) {
 	s.daemon(c)
 	const notListedErr = `cannot use snapshot-options for snap "xyzzy" that is not listed in snaps`
@@ -22,6 +22,7 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,6 +33,8 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/strutil"
@@ -53,14 +56,27 @@ var snapshotExportCmd = &Command{
 	ReadAccess: authenticatedAccess{},
 }
 
+var snapshotTargetCmd = &Command{
+	Path:        "/v2/snapshots/target",
+	GET:         getSnapshotTarget,
+	POST:        postSnapshotTarget,
+	Actions:     []string{"fetch"},
+	ReadAccess:  authenticatedAccess{},
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+}
+
 var (
-	snapshotList    = snapshotstate.List
-	snapshotCheck   = snapshotstate.Check
-	snapshotForget  = snapshotstate.Forget
-	snapshotRestore = snapshotstate.Restore
-	snapshotSave    = snapshotstate.Save
-	snapshotExport  = snapshotstate.Export
-	snapshotImport  = snapshotstate.Import
+	snapshotList          = snapshotstate.List
+	snapshotCheck         = snapshotstate.Check
+	snapshotForget        = snapshotstate.Forget
+	snapshotRestore       = snapshotstate.Restore
+	snapshotSave          = snapshotstate.Save
+	snapshotSaveEncrypted = snapshotstate.SaveEncrypted
+	snapshotUsePassphrase = snapshotstate.UsePassphrase
+	snapshotExport        = snapshotstate.Export
+	snapshotImport        = snapshotstate.Import
+	snapshotTarget        = snapshotstate.Target
+	snapshotFetch         = snapshotstate.Fetch
 )
 
 var (
@@ -97,6 +113,8 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	// Passphrase unlocks encrypted snapshots for check and restore
+	Passphrase string `json:"passphrase,omitempty"`
 }
 
 func (action snapshotAction) String() string {
@@ -143,6 +161,13 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	st.Lock()
 	defer st.Unlock()
 
+	if action.Passphrase != "" {
+		if action.Action != "check" && action.Action != "restore" {
+			return BadRequest(`snapshot %q operation cannot specify a passphrase`, action.Action)
+		}
+		snapshotUsePassphrase(st, action.SetID, action.Passphrase)
+	}
+
 	var changeKind string
 	switch action.Action {
 	case "check":
@@ -161,11 +186,14 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("unknown snapshot operation %q", action.Action)
 	}
 
-	switch err {
-	case nil:
+	var passphraseErr *snapshotstate.PassphraseRequiredError
+	switch {
+	case err == nil:
 		// woo
-	case client.ErrSnapshotSetNotFound, client.ErrSnapshotSnapsNotFound:
+	case err == client.ErrSnapshotSetNotFound, err == client.ErrSnapshotSnapsNotFound:
 		return NotFound("%v", err)
+	case errors.As(err, &passphraseErr):
+		return BadRequest("%v", err)
 	default:
 		return InternalError("%v", err)
 	}
@@ -229,8 +257,66 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	return SyncResponse(result)
 }
 
+func getSnapshotTarget(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	target, err := snapshotTarget(r.Context(), st)
+	switch {
+	case err == nil:
+		return SyncResponse(target)
+	case errors.Is(err, snapshotstate.ErrNoSnapshotTarget):
+		return NotFound("%v", err)
+	default:
+		return InternalError("cannot list snapshot target: %v", err)
+	}
+}
+
+type snapshotTargetAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+func postSnapshotTarget(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action snapshotTargetAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into snapshot target operation: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found after snapshot target operation")
+	}
+	if action.Action != "fetch" {
+		return BadRequest("unknown snapshot target operation %q", action.Action)
+	}
+	if action.Name == "" {
+		return BadRequest("snapshot target operation requires a snapshot name")
+	}
+
+	st := c.d.overlord.State()
+	setID, snapNames, err := snapshotFetch(r.Context(), st, action.Name)
+	switch {
+	case err == nil:
+		// woo
+	case errors.Is(err, snapshotstate.ErrNoSnapshotTarget), errors.Is(err, remote.ErrNotFound):
+		return NotFound("%v", err)
+	default:
+		return BadRequest("cannot fetch snapshot %q: %v", action.Name, err)
+	}
+
+	result := map[string]any{"set-id": setID, "snaps": snapNames}
+	return SyncResponse(result)
+}
+
 func snapshotMany(_ context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	var setID uint64
+	var snapshotted []string
+	var ts *state.TaskSet
+	var err error
+	if inst.SnapshotEncrypt != "" {
+		secret := &backend.Secret{KeyProtection: inst.SnapshotEncrypt, Passphrase: inst.SnapshotPassphrase}
+		setID, snapshotted, ts, err = snapshotSaveEncrypted(st, inst.Snaps, inst.Users, inst.SnapshotOptions, secret)
+	} else {
+		setID, snapshotted, ts, err = snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	}
 	if err != nil {
 		return nil, err
 	}
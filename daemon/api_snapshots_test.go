@@ -34,6 +34,8 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
@@ -116,6 +118,38 @@ func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
 	c.Check(err, check.ErrorMatches, `snap "foo" is not installed`)
 }
 
+func (s *snapshotSuite) TestSnapshotManyEncrypted(c *check.C) {
+	defer daemon.MockSnapshotSave(func(*state.State, []string, []string, map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		c.Fatal("unexpected unencrypted snapshot")
+		return 0, nil, nil, nil
+	})()
+	var secrets []backend.Secret
+	defer daemon.MockSnapshotSaveEncrypted(func(s *state.State, snaps, users []string,
+		options map[string]*snap.SnapshotOptions, secret *backend.Secret) (uint64, []string, *state.TaskSet, error) {
+		c.Check(snaps, check.DeepEquals, []string{"foo"})
+		secrets = append(secrets, *secret)
+		t := s.NewTask("fake-snapshot", "Snapshot")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	for _, body := range []string{
+		`{"action": "snapshot", "snaps": ["foo"], "snapshot-encrypt": "passphrase", "snapshot-passphrase": "s3kr1t"}`,
+		`{"action": "snapshot", "snaps": ["foo"], "snapshot-encrypt": "device"}`,
+	} {
+		inst := daemon.MustUnmarshalSnapInstruction(c, body)
+		st := s.d.Overlord().State()
+		st.Lock()
+		res, err := inst.DispatchForMany()(context.Background(), inst, st)
+		st.Unlock()
+		c.Assert(err, check.IsNil)
+		c.Check(res.Result, check.DeepEquals, map[string]any{"set-id": uint64(1)})
+	}
+	c.Check(secrets, check.DeepEquals, []backend.Secret{
+		{KeyProtection: "passphrase", Passphrase: "s3kr1t"},
+		{KeyProtection: "device"},
+	})
+}
+
 func (s *snapshotSuite) TestListSnapshots(c *check.C) {
 	s.expectOpenAccess()
 
@@ -236,6 +270,9 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 		}, {
 			body:  `{"set": 42, "action": "forget", "users": ["foo"]}`,
 			error: `snapshot "forget" operation cannot specify users`,
+		}, {
+			body:  `{"set": 42, "action": "forget", "passphrase": "s3kr1t"}`,
+			error: `snapshot "forget" operation cannot specify a passphrase`,
 		},
 	}
 
@@ -355,6 +392,50 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotPassphrase(c *check.C) {
+	var calls []string
+	defer daemon.MockSnapshotUsePassphrase(func(_ *state.State, setID uint64, passphrase string) {
+		calls = append(calls, fmt.Sprintf("use %d %s", setID, passphrase))
+	})()
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		calls = append(calls, "check")
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		calls = append(calls, "restore")
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	for _, action := range []string{"check", "restore"} {
+		body := fmt.Sprintf(`{"set": 42, "action": "%s", "passphrase": "s3kr1t"}`, action)
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		rsp := s.asyncReq(c, req, nil, actionIsExpected)
+		c.Check(rsp.Status, check.Equals, 202)
+	}
+	c.Check(calls, check.DeepEquals, []string{"use 42 s3kr1t", "check", "use 42 s3kr1t", "restore"})
+
+	// the passphrase is not part of the change summary
+	st := s.d.Overlord().State()
+	st.Lock()
+	for _, chg := range st.Changes() {
+		c.Check(chg.Summary(), check.Not(check.Matches), ".*s3kr1t.*")
+	}
+	st.Unlock()
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPassphraseRequired(c *check.C) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return nil, nil, &snapshotstate.PassphraseRequiredError{SetID: 42}
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"set": 42, "action": "restore"}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "snapshot set #42 is encrypted, a passphrase is required")
+}
+
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
@@ -465,3 +546,95 @@ func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	c.Check(rsp.Status, check.Equals, 200)
 	c.Check(dataRead, check.Equals, 10)
 }
+
+func (s *snapshotSuite) TestGetSnapshotTarget(c *check.C) {
+	target := &client.SnapshotTarget{
+		Type: "s3",
+		URL:  "https://s3.example.com/bucket",
+		Snapshots: []client.RemoteSnapshot{
+			{Name: "box_20260102T030405Z_7.snapshot", Host: "box", SetID: 7},
+		},
+	}
+	defer daemon.MockSnapshotTarget(func(context.Context, *state.State) (*client.SnapshotTarget, error) {
+		return target, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/target", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, target)
+}
+
+func (s *snapshotSuite) TestGetSnapshotTargetNotConfigured(c *check.C) {
+	defer daemon.MockSnapshotTarget(func(context.Context, *state.State) (*client.SnapshotTarget, error) {
+		return nil, snapshotstate.ErrNoSnapshotTarget
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/target", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "no snapshot target configured")
+}
+
+func (s *snapshotSuite) TestGetSnapshotTargetError(c *check.C) {
+	defer daemon.MockSnapshotTarget(func(context.Context, *state.State) (*client.SnapshotTarget, error) {
+		return nil, errors.New("boom")
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/target", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot list snapshot target: boom")
+}
+
+func (s *snapshotSuite) TestFetchSnapshot(c *check.C) {
+	var fetched string
+	defer daemon.MockSnapshotFetch(func(_ context.Context, _ *state.State, name string) (uint64, []string, error) {
+		fetched = name
+		return 3, []string{"foo"}, nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots/target", strings.NewReader(`{"action": "fetch", "name": "box_20260102T030405Z_7.snapshot"}`))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]any{"set-id": uint64(3), "snaps": []string{"foo"}})
+	c.Check(fetched, check.Equals, "box_20260102T030405Z_7.snapshot")
+}
+
+func (s *snapshotSuite) TestFetchSnapshotErrors(c *check.C) {
+	var fetchErr error
+	defer daemon.MockSnapshotFetch(func(context.Context, *state.State, string) (uint64, []string, error) {
+		return 0, nil, fetchErr
+	})()
+
+	for _, t := range []struct {
+		body    string
+		err     error
+		status  int
+		message string
+	}{
+		{`{"action": "fetch"`, nil, 400, `cannot decode request body into snapshot target operation: unexpected EOF`},
+		{`{"action": "fetch"}{}`, nil, 400, `extra content found after snapshot target operation`},
+		{`{"action": "push", "name": "x"}`, nil, 400, `unknown snapshot target operation "push"`},
+		{`{"action": "fetch"}`, nil, 400, `snapshot target operation requires a snapshot name`},
+		{`{"action": "fetch", "name": "x"}`, snapshotstate.ErrNoSnapshotTarget, 404, `no snapshot target configured`},
+		{`{"action": "fetch", "name": "x"}`, remote.ErrNotFound, 404, `snapshot not found on target`},
+		{`{"action": "fetch", "name": "x"}`, errors.New("boom"), 400, `cannot fetch snapshot "x": boom`},
+	} {
+		fetchErr = t.err
+		req, err := http.NewRequest("POST", "/v2/snapshots/target", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf("%s", t.body))
+		c.Check(rspe.Message, check.Equals, t.message, check.Commentf("%s", t.body))
+	}
+}
@@ -0,0 +1,300 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var updatePackCmd = &Command{
+	Path:        "/v2/update-pack",
+	POST:        postUpdatePack,
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+}
+
+// maxUpdatePackMetadataSize limits the size of the manifest and of the
+// assertions read into memory from an update pack.
+const maxUpdatePackMetadataSize = 64 * 1024 * 1024
+
+// updatePack is an update pack unpacked from a request.
+type updatePack struct {
+	manifest   *client.UpdatePackManifest
+	assertions []byte
+	// files maps the names of the snap and component files in the pack
+	// to the temporary files holding their content.
+	files map[string]string
+}
+
+// removeAllExcept removes the temporary files of the pack, except for
+// the given paths.
+func (p *updatePack) removeAllExcept(paths []string) {
+	for _, tmpPath := range p.files {
+		if strutil.ListContains(paths, tmpPath) {
+			continue
+		}
+		if err := os.Remove(tmpPath); err != nil {
+			logger.Noticef("cannot remove temporary file: %v", err)
+		}
+	}
+}
+
+func readUpdatePack(r io.Reader) (_ *updatePack, err error) {
+	pack := &updatePack{files: make(map[string]string)}
+	defer func() {
+		if err != nil {
+			pack.removeAllExcept(nil)
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected non-regular file %q", name)
+		}
+		switch {
+		case name == client.UpdatePackManifestName:
+			if pack.manifest != nil {
+				return nil, fmt.Errorf("duplicate %q", name)
+			}
+			dec := json.NewDecoder(io.LimitReader(tr, maxUpdatePackMetadataSize))
+			if err := dec.Decode(&pack.manifest); err != nil {
+				return nil, fmt.Errorf("cannot decode %q: %v", name, err)
+			}
+		case name == client.UpdatePackAssertionsName:
+			if hdr.Size > maxUpdatePackMetadataSize {
+				return nil, fmt.Errorf("%q is too large", name)
+			}
+			pack.assertions, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+		case path.Dir(name) == client.UpdatePackSnapsDir:
+			if _, ok := pack.files[name]; ok {
+				return nil, fmt.Errorf("duplicate %q", name)
+			}
+			tmpPath, err := writeToTempFile(tr)
+			if tmpPath != "" {
+				pack.files[name] = tmpPath
+			}
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected file %q", name)
+		}
+	}
+
+	if pack.manifest == nil {
+		return nil, fmt.Errorf("missing %q", client.UpdatePackManifestName)
+	}
+	if pack.manifest.Format != client.UpdatePackFormat {
+		return nil, fmt.Errorf("unsupported format %d", pack.manifest.Format)
+	}
+	return pack, nil
+}
+
+func postUpdatePack(c *Command, r *http.Request, user *auth.UserState) Response {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-tar" {
+		return BadRequest("unknown content type: %s", r.Header.Get("Content-Type"))
+	}
+
+	pack, err := readUpdatePack(r.Body)
+	if err != nil {
+		return BadRequest("cannot read update pack: %v", err)
+	}
+
+	// we are in charge of the temp files, until they're handed off to the change
+	var pathsToNotRemove []string
+	defer func() {
+		pack.removeAllExcept(pathsToNotRemove)
+	}()
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var userID int
+	if user != nil {
+		userID = user.ID
+	}
+
+	chg, paths, errRsp := applyUpdatePack(r.Context(), st, pack, userID)
+	if errRsp != nil {
+		return errRsp
+	}
+
+	ensureStateSoon(st)
+
+	pathsToNotRemove = paths
+	return AsyncResponse(nil, chg.ID())
+}
+
+// applyUpdatePack imports the assertions of the update pack and
+// creates a single change refreshing the installed snaps carried by
+// the pack, while keeping their tracked channels and cohorts. It
+// returns the change and the temporary files handed off to it.
+func applyUpdatePack(ctx context.Context, st *state.State, pack *updatePack, userID int) (*state.Change, []string, *apiError) {
+	batch := asserts.NewBatch(nil)
+	if _, err := batch.AddStream(bytes.NewReader(pack.assertions)); err != nil {
+		return nil, nil, BadRequest("cannot decode update pack assertions: %v", err)
+	}
+	if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		return nil, nil, BadRequest("cannot import update pack assertions: %v", err)
+	}
+
+	deviceCtx, err := snapstate.DevicePastSeeding(st, nil)
+	if err != nil {
+		return nil, nil, InternalError(err.Error())
+	}
+	model := deviceCtx.Model()
+	db := assertstate.DB(st)
+
+	var pathSnaps []snapstate.PathSnap
+	var snapNames, paths []string
+	for _, ps := range pack.manifest.Snaps {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, ps.Name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, nil, InternalError("cannot get state of snap %q: %v", ps.Name, err)
+		}
+		// packs are usually exported for a whole model, only the snaps
+		// that are actually installed get refreshed
+		if !snapst.IsInstalled() {
+			continue
+		}
+
+		snapPath, ok := pack.files[path.Clean(ps.File)]
+		if !ok {
+			return nil, nil, BadRequest("cannot find file %q of snap %q in update pack", ps.File, ps.Name)
+		}
+		si, err := snapasserts.DeriveSideInfo(snapPath, model, db)
+		if err != nil {
+			return nil, nil, BadRequest("cannot verify snap %q from update pack: %v", ps.Name, err)
+		}
+		if si.RealName != ps.Name {
+			return nil, nil, BadRequest("cannot use file %q for snap %q: assertions are for snap %q", ps.File, ps.Name, si.RealName)
+		}
+		if si.Revision == snapst.Current {
+			continue
+		}
+
+		info, err := unsafeReadSnapInfo(snapPath)
+		if err != nil {
+			return nil, nil, BadRequest("cannot read snap file %q: %v", ps.File, err)
+		}
+		info.SideInfo = *si
+
+		comps := make([]snapstate.PathComponent, 0, len(ps.Components))
+		for _, pc := range ps.Components {
+			compPath, ok := pack.files[path.Clean(pc.File)]
+			if !ok {
+				return nil, nil, BadRequest("cannot find file %q of component %q in update pack", pc.File, pc.Name)
+			}
+			csi, err := snapasserts.DeriveComponentSideInfo(pc.Name, compPath, info, model, db)
+			if err != nil {
+				return nil, nil, BadRequest("cannot verify component %q of snap %q from update pack: %v", pc.Name, ps.Name, err)
+			}
+			comps = append(comps, snapstate.PathComponent{
+				SideInfo: csi,
+				Path:     compPath,
+			})
+			paths = append(paths, compPath)
+		}
+
+		pathSnaps = append(pathSnaps, snapstate.PathSnap{
+			Path:     snapPath,
+			SideInfo: si,
+			// keep following the tracked channel and cohort, as a
+			// refresh from the store would
+			RevOpts: snapstate.RevisionOptions{
+				Channel:   snapst.TrackingChannel,
+				CohortKey: snapst.CohortKey,
+			},
+			Components: comps,
+		})
+		snapNames = append(snapNames, ps.Name)
+		paths = append(paths, snapPath)
+	}
+
+	if len(pathSnaps) == 0 {
+		return nil, nil, &apiError{
+			Status:  400,
+			Message: "update pack has no updates for installed snaps",
+			Kind:    client.ErrorKindSnapNoUpdateAvailable,
+		}
+	}
+
+	flags := snapstate.Flags{
+		RemoveSnapPath: true,
+		Transaction:    client.TransactionPerSnap,
+	}
+	_, uts, err := snapstateUpdateWithGoal(ctx, st, snapstatePathUpdateGoal(pathSnaps...), nil, snapstate.Options{
+		UserID: userID,
+		Flags:  flags,
+	})
+	if err != nil {
+		return nil, nil, errToResponse(err, snapNames, InternalError, "cannot refresh snaps from update pack: %v")
+	}
+
+	var msg string
+	if len(snapNames) == 1 {
+		msg = fmt.Sprintf(i18n.G("Refresh %q snap from update pack"), snapNames[0])
+	} else {
+		msg = fmt.Sprintf(i18n.G("Refresh snaps %s from update pack"), strutil.Quoted(snapNames))
+	}
+	chg := newChange(st, refreshSnapChangeKind, msg, uts.Refresh, snapNames)
+	chg.Set("api-data", map[string]any{"snap-names": snapNames})
+
+	return chg, paths, nil
+}
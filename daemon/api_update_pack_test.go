@@ -0,0 +1,379 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = check.Suite(&updatePackSuite{})
+
+type updatePackSuite struct {
+	apiBaseSuite
+}
+
+func (s *updatePackSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+
+}
+
+func (s *updatePackSuite) markSeeded(d *daemon.Daemon) {
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	st.Set("seeded", true)
+	model := s.Brands.Model("can0nical", "pc", map[string]any{
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "kernel",
+	})
+	snapstatetest.MockDeviceModel(model)
+}
+
+func (s *updatePackSuite) mockInstalled(c *check.C, name string, rev snap.Revision, channel, cohort string) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	si := &snap.SideInfo{RealName: name, SnapID: snaptest.AssertedSnapID(name), Revision: rev}
+	snapstate.Set(st, name, &snapstate.SnapState{
+		Active:          true,
+		Sequence:        snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+		Current:         rev,
+		TrackingChannel: channel,
+		CohortKey:       cohort,
+	})
+}
+
+func (s *updatePackSuite) snapAssertions(c *check.C, name, snapPath, rev string) []asserts.Assertion {
+	digest, size, err := asserts.SnapFileSHA3_384(snapPath)
+	c.Assert(err, check.IsNil)
+	snapID := snaptest.AssertedSnapID(name)
+
+	snapDecl, err := s.StoreSigning.Sign(asserts.SnapDeclarationType, map[string]any{
+		"series":       "16",
+		"snap-id":      snapID,
+		"snap-name":    name,
+		"publisher-id": s.StoreSigning.AuthorityID,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	snapRev, err := s.StoreSigning.Sign(asserts.SnapRevisionType, map[string]any{
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       snapID,
+		"snap-revision": rev,
+		"developer-id":  s.StoreSigning.AuthorityID,
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	return []asserts.Assertion{s.StoreSigning.StoreAccountKey(""), snapDecl, snapRev}
+}
+
+func (s *updatePackSuite) componentAssertions(c *check.C, snapName, compName, compPath, snapRev, compRev string) []asserts.Assertion {
+	digest, size, err := asserts.SnapFileSHA3_384(compPath)
+	c.Assert(err, check.IsNil)
+	snapID := snaptest.AssertedSnapID(snapName)
+
+	resRev, err := s.StoreSigning.Sign(asserts.SnapResourceRevisionType, map[string]any{
+		"snap-id":           snapID,
+		"resource-name":     compName,
+		"resource-sha3-384": digest,
+		"developer-id":      s.StoreSigning.AuthorityID,
+		"resource-revision": compRev,
+		"resource-size":     fmt.Sprintf("%d", size),
+		"timestamp":         time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	resPair, err := s.StoreSigning.Sign(asserts.SnapResourcePairType, map[string]any{
+		"snap-id":           snapID,
+		"resource-name":     compName,
+		"resource-revision": compRev,
+		"snap-revision":     snapRev,
+		"developer-id":      s.StoreSigning.AuthorityID,
+		"timestamp":         time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	return []asserts.Assertion{resRev, resPair}
+}
+
+type updatePackFile struct {
+	name string
+	path string
+}
+
+func makeUpdatePack(c *check.C, manifest *client.UpdatePackManifest, assertions []asserts.Assertion, files []updatePackFile) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	add := func(name string, data []byte) {
+		c.Assert(tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}), check.IsNil)
+		_, err := tw.Write(data)
+		c.Assert(err, check.IsNil)
+	}
+
+	if manifest != nil {
+		data, err := json.Marshal(manifest)
+		c.Assert(err, check.IsNil)
+		add(client.UpdatePackManifestName, data)
+	}
+
+	asBuf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(asBuf)
+	for _, a := range assertions {
+		c.Assert(enc.Encode(a), check.IsNil)
+	}
+	add(client.UpdatePackAssertionsName, asBuf.Bytes())
+
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		c.Assert(err, check.IsNil)
+		add(f.name, data)
+	}
+	c.Assert(tw.Close(), check.IsNil)
+	return buf
+}
+
+func (s *updatePackSuite) updatePackRequest(c *check.C, body *bytes.Buffer) *http.Request {
+	req, err := http.NewRequest("POST", "/v2/update-pack", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-tar")
+	return req
+}
+
+func (s *updatePackSuite) TestApplyUpdatePack(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+
+	var goal *pathUpdateGoalRecorder
+	var gotOpts snapstate.Options
+	s.AddCleanup(daemon.MockSnapstateUpdateWithGoal(func(ctx context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		goal = g.(*pathUpdateGoalRecorder)
+		gotOpts = opts
+		var tss []*state.TaskSet
+		var names []string
+		for _, sn := range goal.snaps {
+			ts := state.NewTaskSet(st.NewTask("fake-refresh-snap", fmt.Sprintf("Doing a fake refresh of %q", sn.SideInfo.RealName)))
+			tss = append(tss, ts)
+			names = append(names, sn.SideInfo.RealName)
+		}
+		return names, &snapstate.UpdateTaskSets{Refresh: tss}, nil
+	}))
+
+	// foo gets refreshed with its component, bar is already at the
+	// revision in the pack and baz is not installed
+	s.mockInstalled(c, "foo", snap.R(1), "latest/candidate", "some-cohort")
+	s.mockInstalled(c, "bar", snap.R(3), "latest/stable", "")
+
+	fooPath := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2\ncomponents:\n  comp:\n    type: standard\n", nil)
+	compPath := snaptest.MakeTestComponentWithFiles(c, "comp", "component: foo+comp\ntype: standard\nversion: 1\n", nil)
+	barPath := snaptest.MakeTestSnapWithFiles(c, "name: bar\nversion: 3\n", nil)
+	bazPath := snaptest.MakeTestSnapWithFiles(c, "name: baz\nversion: 1\n", nil)
+
+	var assertions []asserts.Assertion
+	assertions = append(assertions, s.snapAssertions(c, "foo", fooPath, "2")...)
+	assertions = append(assertions, s.componentAssertions(c, "foo", "comp", compPath, "2", "5")...)
+	assertions = append(assertions, s.snapAssertions(c, "bar", barPath, "3")...)
+	assertions = append(assertions, s.snapAssertions(c, "baz", bazPath, "1")...)
+
+	manifest := &client.UpdatePackManifest{
+		Format: client.UpdatePackFormat,
+		Snaps: []client.UpdatePackSnap{{
+			Name:     "foo",
+			File:     "snaps/foo_2.snap",
+			Revision: "2",
+			Components: []client.UpdatePackComponent{{
+				Name:     "comp",
+				File:     "snaps/foo+comp_5.comp",
+				Revision: "5",
+			}},
+		}, {
+			Name:     "bar",
+			File:     "snaps/bar_3.snap",
+			Revision: "3",
+		}, {
+			Name:     "baz",
+			File:     "snaps/baz_1.snap",
+			Revision: "1",
+		}},
+	}
+	body := makeUpdatePack(c, manifest, assertions, []updatePackFile{
+		{"snaps/foo_2.snap", fooPath},
+		{"snaps/foo+comp_5.comp", compPath},
+		{"snaps/bar_3.snap", barPath},
+		{"snaps/baz_1.snap", bazPath},
+	})
+
+	req := s.updatePackRequest(c, body)
+	s.asUserAuth(c, req)
+	rsp := s.asyncReq(c, req, s.authUser, actionIsUnexpected)
+
+	c.Assert(goal, check.NotNil)
+	c.Assert(goal.snaps, check.HasLen, 1)
+	ps := goal.snaps[0]
+	c.Check(ps.SideInfo, check.DeepEquals, &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   snaptest.AssertedSnapID("foo"),
+		Revision: snap.R(2),
+	})
+	c.Check(ps.RevOpts, check.DeepEquals, snapstate.RevisionOptions{
+		Channel:   "latest/candidate",
+		CohortKey: "some-cohort",
+	})
+	c.Check(ps.Path, testutil.FileEquals, testutil.FileContentRef(fooPath))
+	c.Assert(ps.Components, check.HasLen, 1)
+	c.Check(ps.Components[0].SideInfo, check.DeepEquals, snap.NewComponentSideInfo(naming.NewComponentRef("foo", "comp"), snap.R(5)))
+	c.Check(ps.Components[0].Path, testutil.FileEquals, testutil.FileContentRef(compPath))
+	c.Check(gotOpts.UserID, check.Equals, s.authUser.ID)
+	c.Check(gotOpts.Flags, check.DeepEquals, snapstate.Flags{RemoveSnapPath: true, Transaction: client.TransactionPerSnap})
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "refresh-snap")
+	c.Check(chg.Summary(), check.Equals, `Refresh "foo" snap from update pack`)
+	var data map[string][]string
+	c.Assert(chg.Get("api-data", &data), check.IsNil)
+	c.Check(data["snap-names"], check.DeepEquals, []string{"foo"})
+
+	// only the files handed off to the change are kept around
+	left, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(left, check.HasLen, 2)
+	c.Check(left, testutil.Contains, ps.Path)
+	c.Check(left, testutil.Contains, ps.Components[0].Path)
+}
+
+func (s *updatePackSuite) TestApplyUpdatePackNoUpdates(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+
+	s.AddCleanup(daemon.MockSnapstateUpdateWithGoal(func(ctx context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		c.Fatal("unexpected call")
+		return nil, nil, nil
+	}))
+
+	s.mockInstalled(c, "bar", snap.R(3), "latest/stable", "")
+	barPath := snaptest.MakeTestSnapWithFiles(c, "name: bar\nversion: 3\n", nil)
+	body := makeUpdatePack(c, &client.UpdatePackManifest{
+		Format: client.UpdatePackFormat,
+		Snaps:  []client.UpdatePackSnap{{Name: "bar", File: "snaps/bar_3.snap", Revision: "3"}},
+	}, s.snapAssertions(c, "bar", barPath, "3"), []updatePackFile{{"snaps/bar_3.snap", barPath}})
+
+	rspe := s.errorReq(c, s.updatePackRequest(c, body), nil, actionIsUnexpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNoUpdateAvailable)
+	c.Check(rspe.Message, check.Equals, "update pack has no updates for installed snaps")
+
+	// the assertions were imported nevertheless
+	st := d.Overlord().State()
+	st.Lock()
+	_, err := assertstate.DB(st).Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": snaptest.AssertedSnapID("bar"),
+	})
+	st.Unlock()
+	c.Check(err, check.IsNil)
+
+	left, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(left, check.HasLen, 0)
+}
+
+func (s *updatePackSuite) TestApplyUpdatePackUnverified(c *check.C) {
+	d := s.daemonWithFakeSnapManager(c)
+	s.markSeeded(d)
+
+	s.mockInstalled(c, "foo", snap.R(1), "latest/stable", "")
+	fooPath := snaptest.MakeTestSnapWithFiles(c, "name: foo\nversion: 2\n", nil)
+	body := makeUpdatePack(c, &client.UpdatePackManifest{
+		Format: client.UpdatePackFormat,
+		Snaps:  []client.UpdatePackSnap{{Name: "foo", File: "snaps/foo_2.snap", Revision: "2"}},
+	}, nil, []updatePackFile{{"snaps/foo_2.snap", fooPath}})
+
+	rspe := s.errorReq(c, s.updatePackRequest(c, body), nil, actionIsUnexpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot verify snap "foo" from update pack: .*`)
+
+	left, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	c.Check(left, check.HasLen, 0)
+}
+
+func (s *updatePackSuite) TestApplyUpdatePackBadPack(c *check.C) {
+	s.daemonWithFakeSnapManager(c)
+
+	for _, tc := range []struct {
+		manifest *client.UpdatePackManifest
+		files    []updatePackFile
+		err      string
+	}{{
+		err: `cannot read update pack: missing "manifest.json"`,
+	}, {
+		manifest: &client.UpdatePackManifest{Format: 42},
+		err:      `cannot read update pack: unsupported format 42`,
+	}, {
+		manifest: &client.UpdatePackManifest{Format: client.UpdatePackFormat},
+		files:    []updatePackFile{{"other/file", "/dev/null"}},
+		err:      `cannot read update pack: unexpected file "other/file"`,
+	}} {
+		body := makeUpdatePack(c, tc.manifest, nil, tc.files)
+		rspe := s.errorReq(c, s.updatePackRequest(c, body), nil, actionIsUnexpected)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, tc.err)
+	}
+
+	req, err := http.NewRequest("POST", "/v2/update-pack", bytes.NewBufferString("{}"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "unknown content type: application/json")
+}
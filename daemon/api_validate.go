@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
@@ -48,7 +49,7 @@ var (
 		Path:        "/v2/validation-sets/{account}/{name}",
 		GET:         getValidationSet,
 		POST:        applyValidationSet,
-		Actions:     []string{"forget", "apply"},
+		Actions:     []string{"forget", "apply", "cancel-transition"},
 		ReadAccess:  authenticatedAccess{},
 		WriteAccess: authenticatedAccess{},
 	}
@@ -62,6 +63,35 @@ type validationSetResult struct {
 	Sequence  int    `json:"sequence,omitempty"`
 	Valid     bool   `json:"valid"`
 	// TODO: attributes for Notes column
+
+	Transition *validationSetTransitionResult `json:"transition,omitempty"`
+}
+
+// validationSetTransitionResult describes a scheduled move of an enforced
+// validation set to a new sequence.
+type validationSetTransitionResult struct {
+	Sequence            int        `json:"sequence"`
+	At                  *time.Time `json:"at,omitempty"`
+	InMaintenanceWindow bool       `json:"in-maintenance-window,omitempty"`
+	PreDownloadChange   string     `json:"pre-download-change,omitempty"`
+	Change              string     `json:"change,omitempty"`
+}
+
+func transitionResult(tr *assertstate.ValidationSetTransition) *validationSetTransitionResult {
+	if tr == nil {
+		return nil
+	}
+	res := &validationSetTransitionResult{
+		Sequence:            tr.Sequence,
+		InMaintenanceWindow: tr.InMaintenanceWindow,
+		PreDownloadChange:   tr.PreDownloadChange,
+		Change:              tr.Change,
+	}
+	if !tr.At.IsZero() {
+		at := tr.At
+		res.At = &at
+	}
+	return res
 }
 
 func modeString(mode assertstate.ValidationSetMode) (string, error) {
@@ -111,6 +141,11 @@ func listValidationSets(c *Command, r *http.Request, _ *auth.UserState) Response
 		return InternalError(err.Error())
 	}
 
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	if err != nil {
+		return InternalError("accessing validation set transitions failed: %v", err)
+	}
+
 	results := make([]validationSetResult, len(names))
 	for i, vs := range names {
 		tr := validationSets[vs]
@@ -131,6 +166,8 @@ func listValidationSets(c *Command, r *http.Request, _ *auth.UserState) Response
 			Mode:      modeStr,
 			Sequence:  tr.Sequence(),
 			Valid:     validErr == nil,
+
+			Transition: transitionResult(transitions[vs]),
 		}
 	}
 
@@ -156,6 +193,11 @@ func validationSetResultFromTracking(st *state.State, tr *assertstate.Validation
 		return nil, err
 	}
 
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	if err != nil {
+		return nil, err
+	}
+
 	validErr := checkInstalledSnaps(sets, snaps, nil)
 	return &validationSetResult{
 		AccountID: tr.AccountID,
@@ -164,6 +206,8 @@ func validationSetResultFromTracking(st *state.State, tr *assertstate.Validation
 		Mode:      modeStr,
 		Sequence:  tr.Sequence(),
 		Valid:     validErr == nil,
+
+		Transition: transitionResult(transitions[assertstate.ValidationSetKey(tr.AccountID, tr.Name)]),
 	}, nil
 }
 
@@ -221,6 +265,11 @@ type validationSetApplyRequest struct {
 	Action   string `json:"action"`
 	Mode     string `json:"mode"`
 	Sequence int    `json:"sequence,omitempty"`
+
+	// At and InMaintenanceWindow schedule enforcing the given sequence
+	// instead of enforcing it right away.
+	At                  string `json:"at,omitempty"`
+	InMaintenanceWindow bool   `json:"in-maintenance-window,omitempty"`
 }
 
 func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -246,6 +295,18 @@ func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Respo
 	if req.Sequence < 0 {
 		return BadRequest("invalid sequence argument: %d", req.Sequence)
 	}
+	var at time.Time
+	if req.At != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, req.At)
+		if err != nil {
+			return BadRequest("invalid time argument %q: expected RFC3339 format", req.At)
+		}
+	}
+	scheduled := req.At != "" || req.InMaintenanceWindow
+	if scheduled && (req.Action != "apply" || req.Mode != "enforce") {
+		return BadRequest("only enforcing a validation set can be scheduled")
+	}
 
 	st := c.d.overlord.State()
 	st.Lock()
@@ -255,7 +316,12 @@ func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Respo
 	case "forget":
 		return forgetValidationSet(st, accountID, name, req.Sequence)
 	case "apply":
+		if scheduled {
+			return scheduleValidationSetTransition(st, accountID, name, req.Sequence, at, req.InMaintenanceWindow, user)
+		}
 		return updateValidationSet(st, accountID, name, req.Mode, req.Sequence, user)
+	case "cancel-transition":
+		return cancelValidationSetTransition(st, accountID, name)
 	default:
 		return BadRequest("unsupported action %q", req.Action)
 	}
@@ -264,6 +330,7 @@ func applyValidationSet(c *Command, r *http.Request, user *auth.UserState) Respo
 var assertstateMonitorValidationSet = assertstate.MonitorValidationSet
 var assertstateFetchAndApplyEnforcedValidationSet = assertstate.FetchAndApplyEnforcedValidationSet
 var assertstateTryEnforcedValidationSets = assertstate.TryEnforcedValidationSets
+var assertstateScheduleValidationSetTransition = assertstate.ScheduleValidationSetTransition
 
 // updateValidationSet handles snap validate --monitor and --enforce accountId/name[=sequence].
 func updateValidationSet(st *state.State, accountID, name string, reqMode string, sequence int, user *auth.UserState) Response {
@@ -298,6 +365,61 @@ func updateValidationSet(st *state.State, accountID, name string, reqMode string
 	return SyncResponse(*res)
 }
 
+// scheduleValidationSetTransition handles snap validate --enforce
+// accountId/name=sequence with --at or --in-maintenance-window.
+func scheduleValidationSetTransition(st *state.State, accountID, name string, sequence int, at time.Time, inMaintenanceWindow bool, user *auth.UserState) Response {
+	if sequence == 0 {
+		return BadRequest("cannot schedule enforcing validation set %v without a sequence", assertstate.ValidationSetKey(accountID, name))
+	}
+
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	var tr assertstate.ValidationSetTracking
+	err := assertstate.GetValidationSet(st, accountID, name, &tr)
+	if errors.Is(err, state.ErrNoState) {
+		return validationSetNotFound(accountID, name, 0)
+	}
+	if err != nil {
+		return InternalError("accessing validation sets failed: %v", err)
+	}
+
+	_, err = assertstateScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID:           accountID,
+		Name:                name,
+		Sequence:            sequence,
+		At:                  at,
+		InMaintenanceWindow: inMaintenanceWindow,
+		UserID:              userID,
+	})
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	ensureStateSoon(st)
+
+	res, err := validationSetResultFromTracking(st, &tr)
+	if err != nil {
+		return InternalError(err.Error())
+	}
+	return SyncResponse(*res)
+}
+
+// cancelValidationSetTransition cancels a scheduled transition of the
+// validation set.
+// The state needs to be locked by the caller.
+func cancelValidationSetTransition(st *state.State, accountID, name string) Response {
+	err := assertstate.CancelValidationSetTransition(st, accountID, name)
+	if errors.Is(err, state.ErrNoState) {
+		return NotFound("no transition scheduled for validation set %v", assertstate.ValidationSetKey(accountID, name))
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(nil)
+}
+
 // forgetValidationSet forgets the validation set.
 // The state needs to be locked by the caller.
 func forgetValidationSet(st *state.State, accountID, name string, sequence int) Response {
@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -962,3 +963,150 @@ func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeError(c *check
 	c.Assert(rspe.Status, check.Equals, 400)
 	c.Check(string(rspe.Message), check.Equals, "cannot enforce validation set: boom")
 }
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeScheduled(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	s.mockValidationSetsTracking(st)
+	assertstatetest.AddMany(st, s.dev1acct, s.acct1Key)
+	c.Assert(assertstate.Add(st, s.mockAssert(c, "foo", "9")), check.IsNil)
+	c.Assert(assertstate.Add(st, s.mockAssert(c, "baz", "2")), check.IsNil)
+	st.Unlock()
+
+	at := time.Date(2030, 11, 20, 2, 0, 0, 0, time.UTC)
+	var called int
+	restore := daemon.MockAssertstateScheduleValidationSetTransition(func(st *state.State, tr *assertstate.ValidationSetTransition) (*state.Change, error) {
+		called++
+		c.Check(tr, check.DeepEquals, &assertstate.ValidationSetTransition{
+			AccountID:           s.dev1acct.AccountID(),
+			Name:                "foo",
+			Sequence:            10,
+			At:                  at,
+			InMaintenanceWindow: true,
+		})
+		sched := *tr
+		sched.PreDownloadChange = "42"
+		st.Set("validation-set-transitions", map[string]*assertstate.ValidationSetTransition{
+			fmt.Sprintf("%s/foo", s.dev1acct.AccountID()): &sched,
+		})
+		return nil, nil
+	})
+	defer restore()
+
+	body := `{"action":"apply","mode":"enforce","sequence":10,"at":"2030-11-20T02:00:00Z","in-maintenance-window":true}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/foo", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(called, check.Equals, 1)
+	res := rsp.Result.(daemon.ValidationSetResult)
+	c.Check(res, check.DeepEquals, daemon.ValidationSetResult{
+		AccountID: s.dev1acct.AccountID(),
+		Name:      "foo",
+		PinnedAt:  9,
+		Mode:      "enforce",
+		Sequence:  9,
+		Valid:     false,
+		Transition: &daemon.ValidationSetTransitionResult{
+			Sequence:            10,
+			At:                  &at,
+			InMaintenanceWindow: true,
+			PreDownloadChange:   "42",
+		},
+	})
+
+	// the transition is also listed
+	req, err = http.NewRequest("GET", "/v2/validation-sets", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	list := rsp.Result.([]daemon.ValidationSetResult)
+	c.Assert(list, check.HasLen, 2)
+	c.Check(list[1].Name, check.Equals, "foo")
+	c.Check(list[1].Transition, check.DeepEquals, res.Transition)
+}
+
+func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeScheduledErrors(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	s.mockValidationSetsTracking(st)
+	st.Unlock()
+
+	restore := daemon.MockAssertstateScheduleValidationSetTransition(func(st *state.State, tr *assertstate.ValidationSetTransition) (*state.Change, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	for i, tc := range []struct {
+		name    string
+		body    string
+		message string
+		status  int
+	}{{
+		name:    "foo",
+		body:    `{"action":"apply","mode":"enforce","at":"tomorrow","sequence":10}`,
+		message: `invalid time argument "tomorrow": expected RFC3339 format`,
+		status:  400,
+	}, {
+		name:    "foo",
+		body:    `{"action":"apply","mode":"monitor","in-maintenance-window":true,"sequence":10}`,
+		message: `only enforcing a validation set can be scheduled`,
+		status:  400,
+	}, {
+		name:    "foo",
+		body:    `{"action":"apply","mode":"enforce","in-maintenance-window":true}`,
+		message: `cannot schedule enforcing validation set .*/foo without a sequence`,
+		status:  400,
+	}, {
+		name:    "bar",
+		body:    `{"action":"apply","mode":"enforce","in-maintenance-window":true,"sequence":10}`,
+		message: `validation set not found`,
+		status:  404,
+	}, {
+		name:    "foo",
+		body:    `{"action":"apply","mode":"enforce","in-maintenance-window":true,"sequence":10}`,
+		message: `boom`,
+		status:  400,
+	}} {
+		req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/%s", s.dev1acct.AccountID(), tc.name), strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, tc.status, check.Commentf("case #%d", i))
+		c.Check(rspe.Message, check.Matches, tc.message, check.Commentf("case #%d", i))
+	}
+}
+
+func (s *apiValidationSetsSuite) TestCancelValidationSetTransition(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	s.mockValidationSetsTracking(st)
+	st.Set("validation-set-transitions", map[string]*assertstate.ValidationSetTransition{
+		fmt.Sprintf("%s/foo", s.dev1acct.AccountID()): {
+			AccountID: s.dev1acct.AccountID(),
+			Name:      "foo",
+			Sequence:  10,
+			At:        time.Date(2030, 11, 20, 2, 0, 0, 0, time.UTC),
+		},
+	})
+	st.Unlock()
+
+	body := `{"action":"cancel-transition"}`
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/foo", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+
+	st.Lock()
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(transitions, check.HasLen, 0)
+
+	// and cancelling again fails
+	req, err = http.NewRequest("POST", fmt.Sprintf("/v2/validation-sets/%s/foo", s.dev1acct.AccountID()), strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Matches, `no transition scheduled for validation set .*/foo`)
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
@@ -40,6 +41,22 @@ func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]
 	}
 }
 
+func MockSnapshotSaveEncrypted(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions, *backend.Secret) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+	oldSave := snapshotSaveEncrypted
+	snapshotSaveEncrypted = newSave
+	return func() {
+		snapshotSaveEncrypted = oldSave
+	}
+}
+
+func MockSnapshotUsePassphrase(newUse func(*state.State, uint64, string)) (restore func()) {
+	oldUse := snapshotUsePassphrase
+	snapshotUsePassphrase = newUse
+	return func() {
+		snapshotUsePassphrase = oldUse
+	}
+}
+
 func MockSnapshotList(newList func(context.Context, *state.State, uint64, []string) ([]client.SnapshotSet, error)) (restore func()) {
 	oldList := snapshotList
 	snapshotList = newList
@@ -88,6 +105,22 @@ func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader)
 	}
 }
 
+func MockSnapshotTarget(newTarget func(context.Context, *state.State) (*client.SnapshotTarget, error)) (restore func()) {
+	oldTarget := snapshotTarget
+	snapshotTarget = newTarget
+	return func() {
+		snapshotTarget = oldTarget
+	}
+}
+
+func MockSnapshotFetch(newFetch func(context.Context, *state.State, string) (uint64, []string, error)) (restore func()) {
+	oldFetch := snapshotFetch
+	snapshotFetch = newFetch
+	return func() {
+		snapshotFetch = oldFetch
+	}
+}
+
 func MustUnmarshalSnapInstruction(c *check.C, jinst string) *snapInstruction {
 	var inst snapInstruction
 	if err := json.Unmarshal([]byte(jinst), &inst); err != nil {
@@ -26,7 +26,8 @@ import (
 )
 
 type (
-	ValidationSetResult = validationSetResult
+	ValidationSetResult           = validationSetResult
+	ValidationSetTransitionResult = validationSetTransitionResult
 )
 
 func MockCheckInstalledSnaps(f func(vsets *snapasserts.ValidationSets, snaps []*snapasserts.InstalledSnap, ignoreValidation map[string]bool) error) func() {
@@ -52,3 +53,11 @@ func MockAssertstateFetchEnforceValidationSet(f func(st *state.State, accountID,
 		assertstateFetchAndApplyEnforcedValidationSet = old
 	}
 }
+
+func MockAssertstateScheduleValidationSetTransition(f func(st *state.State, tr *assertstate.ValidationSetTransition) (*state.Change, error)) func() {
+	old := assertstateScheduleValidationSetTransition
+	assertstateScheduleValidationSetTransition = f
+	return func() {
+		assertstateScheduleValidationSetTransition = old
+	}
+}
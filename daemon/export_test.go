@@ -401,7 +401,7 @@ var (
 	MaxReadBuflen = maxReadBuflen
 )
 
-func MockConfdbstateGetTransaction(f func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error)) (restore func()) {
+func MockConfdbstateGetTransaction(f func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error)) (restore func()) {
 	return testutil.Mock(&confdbstateGetTransactionToSet, f)
 }
 
@@ -451,6 +451,22 @@ func ValidateFeatureFlag(st *state.State, feature features.SnapdFeature) *apiErr
 	return validateFeatureFlag(st, feature)
 }
 
+func MockConfdbstateGetHistoryViaView(f func(*state.State, *confdb.View, []string, map[string]any, int) ([]*confdbstate.ViewRevision, error)) (restore func()) {
+	return testutil.Mock(&confdbstateGetHistoryViaView, f)
+}
+
+func MockConfdbstateRollbackViaView(f func(*state.State, *confdb.View, int, int) (string, error)) (restore func()) {
+	return testutil.Mock(&confdbstateRollbackViaView, f)
+}
+
+func MockConfdbstateExportDatabag(f func(*state.State, string, string) (confdb.JSONDatabag, error)) (restore func()) {
+	return testutil.Mock(&confdbstateExportDatabag, f)
+}
+
+func MockConfdbstateImportDatabag(f func(*state.State, string, string, []byte, string) error) (restore func()) {
+	return testutil.Mock(&confdbstateImportDatabag, f)
+}
+
 func MockDeviceStateSignConfdbControl(f func(m *devicestate.DeviceManager, groups []any, revision int) (*asserts.ConfdbControl, error)) (restore func()) {
 	return testutil.Mock(&devicestateSignConfdbControl, f)
 }
@@ -66,8 +66,9 @@ var (
 	SnapBootstrapRunDir  string
 	SnapVoidDir          string
 
-	SnapInterfacesRequestsRunDir   string
-	SnapInterfacesRequestsStateDir string
+	SnapInterfacesRequestsRunDir        string
+	SnapInterfacesRequestsStateDir      string
+	SnapInterfacesRequestsAdminRulesDir string
 
 	SnapdMaintenanceFile string
 
@@ -88,6 +89,8 @@ var (
 	SnapStateLockFile string
 	SnapSystemKeyFile string
 
+	SnapChangesArchiveDir string
+
 	SnapRepairConfigFile string
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -606,6 +609,7 @@ func SetRootDir(rootdir string) {
 
 	SnapInterfacesRequestsRunDir = filepath.Join(SnapRunDir, "interfaces-requests")
 	SnapInterfacesRequestsStateDir = filepath.Join(rootdir, snappyDir, "interfaces-requests")
+	SnapInterfacesRequestsAdminRulesDir = filepath.Join(rootdir, "/etc/snapd/interfaces-requests/rules.d")
 
 	SnapdStoreSSLCertsDir = filepath.Join(rootdir, snappyDir, "ssl/store-certs")
 	SnapdPKIV1Dir = filepath.Join(rootdir, snappyDir, "pki", "v1")
@@ -624,6 +628,8 @@ func SetRootDir(rootdir string) {
 	SnapStateLockFile = SnapStateLockFileUnder(rootdir)
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
 
+	SnapChangesArchiveDir = filepath.Join(rootdir, snappyDir, "changes-archive")
+
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
 	SnapSectionsFile = filepath.Join(SnapCacheDir, "sections")
@@ -85,6 +85,12 @@ const (
 	SeedRefresh
 	// SnapDeltaFormat enables deltas that use the "snap delta" format
 	SnapDeltaFormat
+	// SnapshotDeduplication enables saving snapshots into a shared, chunk-deduplicated store.
+	SnapshotDeduplication
+	// StateJournal enables persisting the state as a journal of incremental changes instead of rewriting it whole.
+	StateJournal
+	// Metrics enables exposing metrics about the internals of snapd in the OpenMetrics format.
+	Metrics
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -141,6 +147,11 @@ var featureNames = map[SnapdFeature]string{
 	SeedRefresh: "seed-refresh",
 
 	SnapDeltaFormat: "snap-delta-format",
+
+	SnapshotDeduplication: "snapshot-deduplication",
+
+	StateJournal: "state-journal",
+	Metrics:      "metrics",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -164,6 +175,10 @@ var featuresExported = map[SnapdFeature]bool{
 	RefreshAppAwarenessUX: true,
 	Confdb:                true,
 	AppArmorPrompting:     true,
+
+	// StateJournal is read before the state is loaded, so it is checked
+	// through the exported file.
+	StateJournal: true,
 }
 
 var (
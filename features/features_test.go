@@ -69,6 +69,9 @@ func (*featureSuite) TestName(c *C) {
 	check(features.RemoteDeviceManagement, "remote-device-management")
 	check(features.SeedRefresh, "seed-refresh")
 	check(features.SnapDeltaFormat, "snap-delta-format")
+	check(features.SnapshotDeduplication, "snapshot-deduplication")
+	check(features.StateJournal, "state-journal")
+	check(features.Metrics, "metrics")
 
 	c.Check(tested, Equals, features.NumberOfFeatures())
 	c.Check(func() { _ = features.SnapdFeature(1000).String() }, PanicMatches, "unknown feature flag code 1000")
@@ -114,6 +117,9 @@ func (*featureSuite) TestIsExported(c *C) {
 	check(features.RemoteDeviceManagement, false)
 	check(features.SeedRefresh, false)
 	check(features.SnapDeltaFormat, false)
+	check(features.SnapshotDeduplication, false)
+	check(features.StateJournal, true)
+	check(features.Metrics, false)
 
 	c.Check(tested, Equals, features.NumberOfFeatures())
 }
@@ -244,6 +250,9 @@ func (*featureSuite) TestIsEnabledWhenUnset(c *C) {
 	check(features.RemoteDeviceManagement, false)
 	check(features.SeedRefresh, false)
 	check(features.SnapDeltaFormat, false)
+	check(features.SnapshotDeduplication, false)
+	check(features.StateJournal, false)
+	check(features.Metrics, false)
 
 	c.Check(tested, Equals, features.NumberOfFeatures())
 }
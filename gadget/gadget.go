@@ -136,6 +136,9 @@ type Info struct {
 	// Default configuration for snaps (snap-id => key => value).
 	Defaults map[string]map[string]any `yaml:"defaults,omitempty"`
 
+	// Initial confdb databags (account-id/confdb-schema => key => value).
+	Confdb map[string]map[string]any `yaml:"confdb,omitempty"`
+
 	Connections []Connection `yaml:"connections"`
 
 	KernelCmdline KernelCmdline `yaml:"kernel-cmdline"`
@@ -1027,6 +1030,11 @@ func systemOrSnapID(s string) bool {
 	return true
 }
 
+func accountSlashSchema(s string) bool {
+	account, schema, ok := strings.Cut(s, "/")
+	return ok && account != "" && schema != "" && !strings.Contains(schema, "/")
+}
+
 // Model carries characteristics about the model that are relevant to gadget.
 // Note *asserts.Model implements this, and that's the expected use case.
 type Model interface {
@@ -1192,6 +1200,17 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		gi.Defaults[k] = dflt.(map[string]any)
 	}
 
+	for k, v := range gi.Confdb {
+		if !accountSlashSchema(k) {
+			return nil, fmt.Errorf(`confdb stanza not keyed by "<account-id>/<confdb-schema>": %s`, k)
+		}
+		databag, err := metautil.NormalizeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("confdb value %q of %q: %v", v, k, err)
+		}
+		gi.Confdb[k] = databag.(map[string]any)
+	}
+
 	for i, gconn := range gi.Connections {
 		if gconn.Plug.Empty() {
 			return nil, errors.New("gadget connection plug cannot be empty")
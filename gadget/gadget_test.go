@@ -785,6 +785,42 @@ func (s *gadgetYamlTestSuite) TestReadGadgetDefaultsMultiline(c *C) {
 	})
 }
 
+var mockClassicGadgetConfdbYaml = []byte(`
+confdb:
+  my-brand/network:
+    wifi:
+      ssid: my-ssid
+      hidden: false
+    retries: 3
+`)
+
+func (s *gadgetYamlTestSuite) TestReadGadgetConfdb(c *C) {
+	err := os.WriteFile(s.gadgetYamlPath, mockClassicGadgetConfdbYaml, 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := gadget.ReadInfo(s.dir, &gadgettest.ModelCharacteristics{IsClassic: true})
+	c.Assert(err, IsNil)
+	c.Assert(ginfo, DeepEquals, &gadget.Info{
+		Confdb: map[string]map[string]any{
+			"my-brand/network": {
+				"wifi":    map[string]any{"ssid": "my-ssid", "hidden": false},
+				"retries": int64(3),
+			},
+		},
+	})
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetConfdbBadKey(c *C) {
+	for _, key := range []string{"my-brand", "my-brand/", "/network", "my-brand/network/extra"} {
+		yaml := fmt.Sprintf("confdb:\n  %s:\n    foo: bar\n", key)
+		err := os.WriteFile(s.gadgetYamlPath, []byte(yaml), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = gadget.ReadInfo(s.dir, &gadgettest.ModelCharacteristics{IsClassic: true})
+		c.Check(err, ErrorMatches, fmt.Sprintf(`confdb stanza not keyed by "<account-id>/<confdb-schema>": %s`, key))
+	}
+}
+
 func asOffsetPtr(offs quantity.Offset) *quantity.Offset {
 	goff := offs
 	return &goff
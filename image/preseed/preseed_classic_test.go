@@ -406,6 +406,8 @@ func (s *preseedSuite) TestReset(c *C) {
 			symlinkTarget string
 		}{
 			{dirs.SnapStateFile, ""},
+			{filepath.Join(filepath.Dir(dirs.SnapStateFile), "state.journal"), ""},
+			{filepath.Join(dirs.SnapChangesArchiveDir, "changes.log"), ""},
 			{dirs.SnapSystemKeyFile, ""},
 			{filepath.Join(dirs.SnapDesktopFilesDir, "foo.desktop"), ""},
 			{filepath.Join(dirs.SnapDesktopIconsDir, "foo.png"), ""},
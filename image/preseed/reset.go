@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/cmd/snaplock/runinhibit"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 )
 
@@ -97,6 +98,7 @@ var ResetPreseededChroot = func(preseedChroot string) error {
 	// globs that yield individual files
 	globs := []string{
 		dirs.SnapStateFile,
+		state.JournalPath(dirs.SnapStateFile),
 		dirs.SnapSystemKeyFile,
 		filepath.Join(dirs.SnapBlobDir, "*.snap"),
 		filepath.Join(dirs.SnapUdevRulesDir, "*-snap.*.rules"),
@@ -115,6 +117,7 @@ var ResetPreseededChroot = func(preseedChroot string) error {
 		filepath.Join(dirs.SnapUserServicesDir, "sockets.target.wants", "snap.*.socket"),
 		filepath.Join(dirs.SnapUserServicesDir, "timers.target.wants", "snap.*.timer"),
 		filepath.Join(runinhibit.InhibitDir, "*.lock"),
+		filepath.Join(dirs.SnapChangesArchiveDir, "changes.log*"),
 	}
 
 	for _, gl := range globs {
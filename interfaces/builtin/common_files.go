@@ -36,6 +36,8 @@ type commonFilesInterface struct {
 
 	apparmorHeader    string
 	extraPathValidate func(string) error
+	// promptable is set when the access to the paths can be prompted for
+	promptable bool
 }
 
 // filesAAPerm can either be files{Read,Write} and converted to a string
@@ -76,13 +78,17 @@ func formatPath(ip any) (string, error) {
 	return fmt.Sprintf("%s%q", prefix, p), nil
 }
 
-func allowPathAccess(buf *bytes.Buffer, perm filesAAPerm, paths []any) error {
+func allowPathAccess(buf *bytes.Buffer, perm filesAAPerm, paths []any, promptable bool) error {
+	prompt := ""
+	if promptable {
+		prompt = "###PROMPT### "
+	}
 	for _, rawPath := range paths {
 		p, err := formatPath(rawPath)
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(buf, "%s %s,\n", p, perm)
+		fmt.Fprintf(buf, "%s%s %s,\n", prompt, p, perm)
 	}
 	return nil
 }
@@ -162,10 +168,10 @@ func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specific
 
 	errPrefix := fmt.Sprintf(`cannot connect plug %s: `, plug.Name())
 	buf := bytes.NewBufferString(iface.apparmorHeader)
-	if err := allowPathAccess(buf, filesRead, reads); err != nil {
+	if err := allowPathAccess(buf, filesRead, reads, iface.promptable); err != nil {
 		return fmt.Errorf("%s%v", errPrefix, err)
 	}
-	if err := allowPathAccess(buf, filesWrite, writes); err != nil {
+	if err := allowPathAccess(buf, filesWrite, writes, iface.promptable); err != nil {
 		return fmt.Errorf("%s%v", errPrefix, err)
 	}
 	spec.AddSnippet(buf.String())
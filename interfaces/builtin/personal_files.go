@@ -22,6 +22,7 @@ package builtin
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/snapcore/snapd/interfaces"
@@ -78,10 +79,26 @@ func init() {
 			},
 			apparmorHeader:    personalFilesConnectedPlugAppArmor,
 			extraPathValidate: validateSinglePathHome,
+			promptable:        true,
 		},
 	})
 }
 
+// personalFilesPromptPathRegexp matches the paths of hidden files and
+// directories directly in a home directory, which are not covered by the
+// rules of the home interface.
+var personalFilesPromptPathRegexp = regexp.MustCompile(`^(/home/[^/]+|/root)/\.[^/]`)
+
+// DetectPersonalFilesFromPath returns true if the given path likely
+// corresponds to an AppArmor rule with the prompt prefix from the
+// personal-files interface.
+//
+// XXX: this is only necessary until metadata tags are fully supported by the
+// AppArmor parser and kernel. Then, this function should be removed.
+func DetectPersonalFilesFromPath(path string) bool {
+	return personalFilesPromptPathRegexp.MatchString(path)
+}
+
 // potentiallyMissingDirs returns an ensure directory specification that contains the information
 // required to create potentially missing directories for the given path. Potentially missing
 // directories are those that are implicitly required in order to enable the user to create
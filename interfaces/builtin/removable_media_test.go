@@ -79,9 +79,35 @@ func (s *RemovableMediaInterfaceSuite) TestUsedSecuritySystems(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(apparmorSpec.SecurityTags(), DeepEquals, []string{"snap.client-snap.other"})
 	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.other"), testutil.Contains, "/{,run/}media/*/ r")
-	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.other"), testutil.Contains, "/mnt/** mrwklix,")
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.other"), testutil.Contains, "###PROMPT### /mnt/** mrwklix,")
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.other"), testutil.Contains, "###PROMPT### /{,run/}media/*/** mrwklix,")
 }
 
 func (s *RemovableMediaInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *RemovableMediaInterfaceSuite) TestDetectRemovableMediaFromPath(c *C) {
+	for _, path := range []string{
+		"/media/ubuntu/usb",
+		"/media/ubuntu/usb/foo/bar.txt",
+		"/run/media/ubuntu/SD card/photo.jpg",
+		"/mnt/backup",
+		"/mnt/backup/foo",
+	} {
+		c.Check(builtin.DetectRemovableMediaFromPath(path), Equals, true, Commentf("%q should be detected as removable-media path", path))
+	}
+
+	for _, path := range []string{
+		"/media/",
+		"/media/ubuntu",
+		"/media/ubuntu/",
+		"/run/media/ubuntu",
+		"/mnt/",
+		"/mnt",
+		"/home/ubuntu/media/foo/bar",
+		"/dev/video0",
+	} {
+		c.Check(builtin.DetectRemovableMediaFromPath(path), Equals, false, Commentf("%q should not be detected as removable-media path", path))
+	}
+}
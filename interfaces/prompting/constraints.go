@@ -73,7 +73,7 @@ type InterfaceSpecificConstraints interface {
 func parseInterfaceSpecificConstraints(iface string, constraintsJSON ConstraintsJSON, isPatch bool) (InterfaceSpecificConstraints, error) {
 	var interfaceSpecific InterfaceSpecificConstraints
 	switch iface {
-	case "home":
+	case "home", "removable-media", "personal-files":
 		interfaceSpecific = &InterfaceSpecificConstraintsHome{}
 	case "camera", "audio-record":
 		interfaceSpecific = &InterfaceSpecificConstraintsEmpty{}
@@ -92,6 +92,9 @@ func parseInterfaceSpecificConstraints(iface string, constraintsJSON Constraints
 	return interfaceSpecific, nil
 }
 
+// InterfaceSpecificConstraintsHome hold the path pattern of constraints for
+// the home interface, and for the other interfaces which prompt for access to
+// files, such as removable-media and personal-files.
 type InterfaceSpecificConstraintsHome struct {
 	Pattern *patterns.PathPattern
 }
@@ -872,9 +875,11 @@ var (
 	// List of permissions available for each interface. This also defines the
 	// order in which the permissions should be presented.
 	interfacePermissionsAvailable = map[string][]string{
-		"home":         {"read", "write", "execute"},
-		"camera":       {"access"},
-		"audio-record": {"access"},
+		"home":            {"read", "write", "execute"},
+		"camera":          {"access"},
+		"audio-record":    {"access"},
+		"removable-media": {"read", "write", "execute"},
+		"personal-files":  {"read", "write"},
 	}
 
 	// A mapping from interfaces which support AppArmor file permissions to
@@ -892,6 +897,16 @@ var (
 		"camera": {
 			"access": notify.AA_MAY_READ | notify.AA_MAY_GETATTR | notify.AA_MAY_WRITE | notify.AA_MAY_APPEND,
 		},
+		"removable-media": {
+			"read":    notify.AA_MAY_READ | notify.AA_MAY_GETATTR,
+			"write":   notify.AA_MAY_WRITE | notify.AA_MAY_APPEND | notify.AA_MAY_CREATE | notify.AA_MAY_DELETE | notify.AA_MAY_RENAME | notify.AA_MAY_SETATTR | notify.AA_MAY_CHMOD | notify.AA_MAY_LOCK | notify.AA_MAY_LINK,
+			"execute": notify.AA_MAY_EXEC | notify.AA_EXEC_MMAP,
+		},
+		// personal-files only grants read and write access, never execute
+		"personal-files": {
+			"read":  notify.AA_MAY_READ | notify.AA_MAY_GETATTR,
+			"write": notify.AA_MAY_WRITE | notify.AA_MAY_APPEND | notify.AA_MAY_CREATE | notify.AA_MAY_DELETE | notify.AA_MAY_RENAME | notify.AA_MAY_SETATTR | notify.AA_MAY_CHMOD | notify.AA_MAY_LOCK | notify.AA_MAY_LINK,
+		},
 	}
 
 	// Some interfaces do not define AppArmor rules, and thus requests for that
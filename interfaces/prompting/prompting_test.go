@@ -247,6 +247,31 @@ func (s *promptingSuite) TestNewRequestFromListenerInterfaceSelection(c *C) {
 			},
 			"camera",
 		},
+		{
+			"/media/test/usb/foo",
+			func(tag string) (string, bool) {
+				return "", false
+			},
+			"removable-media",
+		},
+		{
+			"/home/test/.config/foo",
+			func(tag string) (string, bool) {
+				return "", false
+			},
+			"personal-files",
+		},
+		{
+			"/home/test/.config/foo",
+			func(tag string) (string, bool) {
+				switch tag {
+				case "tag1", "tag4":
+					return "home", true
+				}
+				return "", false
+			},
+			"home",
+		},
 	} {
 		restore := prompting.MockApparmorInterfaceForMetadataTag(testCase.ifaceForTag)
 		defer restore()
@@ -0,0 +1,294 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package requestaudit provides an append-only audit log of the decisions
+// made for requests from snaps when AppArmor prompting is enabled.
+package requestaudit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+var (
+	// maxLogSize is the size above which the audit log is rotated.
+	maxLogSize int64 = 4 * 1024 * 1024
+	// maxRotatedLogs is the number of rotated audit logs which are kept in
+	// addition to the current one.
+	maxRotatedLogs = 3
+
+	timeNow = time.Now
+)
+
+// DecisionSource identifies what decided the outcome of a request.
+type DecisionSource string
+
+const (
+	// DecidedByRules means that existing rules, of the user or admin
+	// rules, decided the outcome of the request.
+	DecidedByRules DecisionSource = "rules"
+	// DecidedByPrompt means that a prompt was created for the permissions
+	// which were not decided by existing rules.
+	DecidedByPrompt DecisionSource = "prompt"
+	// DecidedByReply means that a prompting client replied to the prompt for
+	// the request.
+	DecidedByReply DecisionSource = "reply"
+	// DecidedByNewRule means that a rule added after the prompt was created
+	// for the request decided the outcome of the prompt.
+	DecidedByNewRule DecisionSource = "new-rule"
+	// DecidedByPolicy means that the request was denied without considering
+	// any rules, for example because it came from the root user, or because
+	// an error occurred while handling it.
+	DecidedByPolicy DecisionSource = "policy"
+)
+
+// Entry records the decision made for a request.
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      uint32    `json:"user"`
+	Snap      string    `json:"snap"`
+	PID       int32     `json:"pid,omitempty"`
+	Interface string    `json:"interface"`
+	Path      string    `json:"path,omitempty"`
+	// Permissions are the permissions which were requested.
+	Permissions []string `json:"permissions"`
+	// Allowed and Denied are the permissions which were allowed and denied.
+	Allowed []string `json:"allowed,omitempty"`
+	Denied  []string `json:"denied,omitempty"`
+	// Outstanding are the permissions for which a prompt was created.
+	Outstanding []string       `json:"outstanding,omitempty"`
+	DecidedBy   DecisionSource `json:"decided-by"`
+	// PromptID is the ID of the prompt for the request, if any.
+	PromptID string `json:"prompt-id,omitempty"`
+	// Rules are the IDs of the rules of the user, or the sources of the admin
+	// rules, which decided the outcome, if any.
+	Rules []string `json:"rules,omitempty"`
+	// Client identifies the prompting client which replied to the prompt.
+	Client string `json:"client,omitempty"`
+	// Reason explains decisions which were not made by rules or replies.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Filter selects audit log entries.
+type Filter struct {
+	// User, if not nil, selects only entries for the given user.
+	User      *uint32
+	Snap      string
+	Interface string
+	// Since, if not zero, selects only entries recorded after the given time.
+	Since time.Time
+	// Limit, if positive, selects only the given number of most recent
+	// entries.
+	Limit int
+}
+
+func (f *Filter) matches(entry *Entry) bool {
+	if f == nil {
+		return true
+	}
+	if f.User != nil && *f.User != entry.User {
+		return false
+	}
+	if f.Snap != "" && f.Snap != entry.Snap {
+		return false
+	}
+	if f.Interface != "" && f.Interface != entry.Interface {
+		return false
+	}
+	if !f.Since.IsZero() && !entry.Timestamp.After(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit log, which is rotated once it grows beyond a
+// fixed size, keeping a fixed number of rotated logs.
+type Log struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	size  int64
+}
+
+// Open opens the audit log in the interfaces requests state directory,
+// creating it if it does not exist.
+func Open() (*Log, error) {
+	if err := os.MkdirAll(dirs.SnapInterfacesRequestsStateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create interfaces requests state directory: %w", err)
+	}
+	l := &Log{
+		path: filepath.Join(dirs.SnapInterfacesRequestsStateDir, "audit.log"),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+	l.file = f
+	l.size = fi.Size()
+	return nil
+}
+
+func (l *Log) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// rotate moves the current log to the first rotated log, shifting the
+// existing rotated logs and discarding the oldest, and opens a new log.
+//
+// The caller must ensure that the log lock is held.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		logger.Noticef("cannot close audit log before rotating it: %v", err)
+	}
+	l.file = nil
+	var rotateErr error
+	for n := maxRotatedLogs - 1; n >= 1 && rotateErr == nil; n-- {
+		err := os.Rename(l.rotatedPath(n), l.rotatedPath(n+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			rotateErr = err
+		}
+	}
+	if rotateErr == nil {
+		rotateErr = os.Rename(l.path, l.rotatedPath(1))
+	}
+	// Reopen the log even if rotating failed, so that entries are still
+	// recorded, if only in an oversized log.
+	if err := l.open(); err != nil {
+		return err
+	}
+	if rotateErr != nil {
+		logger.Noticef("cannot rotate audit log: %v", rotateErr)
+	}
+	return nil
+}
+
+// Record appends the given entry to the audit log. If the entry has no
+// timestamp, it is set to the current time.
+func (l *Log) Record(entry *Entry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit log entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("cannot record audit log entry: audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(data)) > maxLogSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("cannot record audit log entry: %w", err)
+	}
+	return nil
+}
+
+// Entries returns the entries of the audit log, including those in rotated
+// logs, which match the given filter, from oldest to newest.
+//
+// Lines which cannot be decoded, for example since snapd was interrupted while
+// writing them, are skipped.
+func (l *Log) Entries(filter *Filter) ([]*Entry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	paths := make([]string, 0, maxRotatedLogs+1)
+	for n := maxRotatedLogs; n >= 1; n-- {
+		paths = append(paths, l.rotatedPath(n))
+	}
+	paths = append(paths, l.path)
+
+	entries := make([]*Entry, 0)
+	for _, path := range paths {
+		err := readEntries(path, func(entry *Entry) {
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+			}
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("cannot read audit log: %w", err)
+		}
+	}
+	if filter != nil && filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+func readEntries(path string, f func(entry *Entry)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(maxLogSize))
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		f(&entry)
+	}
+	return scanner.Err()
+}
+
+// Close closes the audit log. Entries can still be read after it has been
+// closed, but no more entries can be recorded.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestaudit_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting/requestaudit"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type requestauditSuite struct {
+	testutil.BaseTest
+
+	now time.Time
+}
+
+var _ = Suite(&requestauditSuite{})
+
+func (s *requestauditSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.now = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(requestaudit.MockTimeNow(func() time.Time {
+		s.now = s.now.Add(time.Second)
+		return s.now
+	}))
+}
+
+func (s *requestauditSuite) TestRecordEntries(c *C) {
+	l, err := requestaudit.Open()
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	entries, err := l.Entries(nil)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+
+	c.Assert(l.Record(&requestaudit.Entry{
+		User:        1000,
+		Snap:        "firefox",
+		Interface:   "home",
+		Path:        "/home/test/foo",
+		Permissions: []string{"read", "write"},
+		Allowed:     []string{"read"},
+		Denied:      []string{"write"},
+		DecidedBy:   requestaudit.DecidedByRules,
+		Rules:       []string{"0000000000000002", "admin:corp.json"},
+	}), IsNil)
+	c.Assert(l.Record(&requestaudit.Entry{
+		User:        1001,
+		Snap:        "thunderbird",
+		Interface:   "home",
+		Path:        "/home/other/bar",
+		Permissions: []string{"read"},
+		Allowed:     []string{"read"},
+		DecidedBy:   requestaudit.DecidedByReply,
+		PromptID:    "0000000000000003",
+		Client:      "pid=1234",
+	}), IsNil)
+
+	data, err := os.ReadFile(filepath.Join(dirs.SnapInterfacesRequestsStateDir, "audit.log"))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"timestamp":"2026-03-01T10:00:01Z","user":1000,"snap":"firefox","interface":"home","path":"/home/test/foo","permissions":["read","write"],"allowed":["read"],"denied":["write"],"decided-by":"rules","rules":["0000000000000002","admin:corp.json"]}
+{"timestamp":"2026-03-01T10:00:02Z","user":1001,"snap":"thunderbird","interface":"home","path":"/home/other/bar","permissions":["read"],"allowed":["read"],"decided-by":"reply","prompt-id":"0000000000000003","client":"pid=1234"}
+`)
+
+	entries, err = l.Entries(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Snap, Equals, "firefox")
+	c.Check(entries[1].Client, Equals, "pid=1234")
+
+	user := uint32(1001)
+	entries, err = l.Entries(&requestaudit.Filter{User: &user})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Snap, Equals, "thunderbird")
+
+	entries, err = l.Entries(&requestaudit.Filter{Snap: "firefox", Interface: "home"})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].User, Equals, uint32(1000))
+
+	entries, err = l.Entries(&requestaudit.Filter{Since: time.Date(2026, 3, 1, 10, 0, 1, 0, time.UTC)})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].User, Equals, uint32(1001))
+
+	entries, err = l.Entries(&requestaudit.Filter{Limit: 1})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].User, Equals, uint32(1001))
+
+	// entries survive reopening the log
+	c.Assert(l.Close(), IsNil)
+	c.Check(l.Record(&requestaudit.Entry{Snap: "firefox"}), ErrorMatches, "cannot record audit log entry: audit log is closed")
+	l, err = requestaudit.Open()
+	c.Assert(err, IsNil)
+	entries, err = l.Entries(nil)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 2)
+}
+
+func (s *requestauditSuite) TestRotate(c *C) {
+	s.AddCleanup(requestaudit.MockMaxRotatedLogs(2))
+	// each entry is 120 bytes, so only two fit in each log
+	s.AddCleanup(requestaudit.MockMaxLogSize(250))
+
+	l, err := requestaudit.Open()
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	for i := 0; i < 7; i++ {
+		c.Assert(l.Record(&requestaudit.Entry{
+			User:      uint32(i),
+			Snap:      "firefox",
+			Interface: "camera",
+			DecidedBy: requestaudit.DecidedByRules,
+		}), IsNil)
+	}
+
+	logPath := filepath.Join(dirs.SnapInterfacesRequestsStateDir, "audit.log")
+	c.Check(logPath, testutil.FilePresent)
+	c.Check(logPath+".1", testutil.FilePresent)
+	c.Check(logPath+".2", testutil.FilePresent)
+	c.Check(logPath+".3", testutil.FileAbsent)
+
+	// the oldest log was discarded, and the rest are returned in order
+	entries, err := l.Entries(nil)
+	c.Assert(err, IsNil)
+	var users []uint32
+	for _, entry := range entries {
+		users = append(users, entry.User)
+	}
+	c.Check(users, DeepEquals, []uint32{2, 3, 4, 5, 6})
+}
+
+func (s *requestauditSuite) TestEntriesSkipsCorruptLines(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsStateDir, 0o755), IsNil)
+	logPath := filepath.Join(dirs.SnapInterfacesRequestsStateDir, "audit.log")
+	c.Assert(os.WriteFile(logPath, []byte(`{"user":1000,"snap":"firefox","interface":"home","decided-by":"rules"}
+{"user":1000,"snap":"fir
+`), 0o600), IsNil)
+
+	l, err := requestaudit.Open()
+	c.Assert(err, IsNil)
+	defer l.Close()
+	c.Assert(l.Record(&requestaudit.Entry{User: 1001, Snap: "firefox", Interface: "home"}), IsNil)
+
+	entries, err := l.Entries(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].User, Equals, uint32(1000))
+	c.Check(entries[1].User, Equals, uint32(1001))
+}
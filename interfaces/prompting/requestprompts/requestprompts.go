@@ -200,7 +200,9 @@ type promptConstraints struct {
 }
 
 // promptConstraintsJSONHome defines the marshalled json structure of
-// promptConstraints for the home interface.
+// promptConstraints for the home interface, and for the other interfaces
+// which prompt for access to files, such as removable-media and
+// personal-files.
 type promptConstraintsJSONHome struct {
 	Path                 string   `json:"path"`
 	RequestedPermissions []string `json:"requested-permissions"`
@@ -223,7 +225,7 @@ func (pc *promptConstraints) MarshalJSON() ([]byte, error) {
 // corresponding to the given interface.
 func (pc *promptConstraints) marshalForInterface(iface string) ([]byte, error) {
 	switch iface {
-	case "home":
+	case "home", "removable-media", "personal-files":
 		constraintsJSON := &promptConstraintsJSONHome{
 			Path:                 pc.path,
 			RequestedPermissions: pc.outstandingPermissions,
@@ -456,6 +458,7 @@ func (udb *userPromptDB) timeoutCallback(pdb *PromptDB, user uint32) {
 	for _, p := range expiredPrompts {
 		pdb.notifyPrompt(user, p.ID, data)
 		p.sendReply(prompting.OutcomeDeny) // ignore any error, should not occur
+		pdb.notifyUnanswered(user, p, "expired")
 	}
 }
 
@@ -486,6 +489,10 @@ type PromptDB struct {
 	// notifyPrompt is a closure which will be called to record a notice when a
 	// prompt is added, merged, modified, or resolved.
 	notifyPrompt func(userID uint32, promptID prompting.IDType, data map[string]string) error
+	// notifyUnanswered is a closure which will be called when a prompt is
+	// resolved without a reply, because it expired or because the prompt DB
+	// was closed.
+	notifyUnanswered func(userID uint32, prompt *Prompt, reason string)
 	// The filepath at which the request map is stored on disk.
 	requestMapFilepath string
 	// requestMap is the mapping from request key to prompt ID/user ID which
@@ -512,7 +519,15 @@ type PromptDB struct {
 // merged, modified, or resolved. In order to guarantee the order of notices,
 // notifyPrompt is called with the prompt DB lock held, so it should not block
 // for a substantial amount of time (such as to lock and modify snapd state).
-func New(notifyPrompt func(userID uint32, promptID prompting.IDType, data map[string]string) error) (*PromptDB, error) {
+//
+// The given notifyUnanswered closure will be called for each prompt which is
+// resolved without a reply from a prompting client. The reason is "expired"
+// if the prompt timed out and its requests were denied, or "cancelled" if the
+// prompt DB was closed while the prompt was outstanding. In the latter case,
+// no reply is sent and the requests are expected to be re-received once snapd
+// restarts. Like notifyPrompt, notifyUnanswered may be called with the prompt
+// DB lock held, so it should not block for a substantial amount of time.
+func New(notifyPrompt func(userID uint32, promptID prompting.IDType, data map[string]string) error, notifyUnanswered func(userID uint32, prompt *Prompt, reason string)) (*PromptDB, error) {
 	legacyMaxIDFilepath := filepath.Join(dirs.SnapRunDir, "request-prompt-max-id")
 	maxIDFilepath := filepath.Join(dirs.SnapInterfacesRequestsRunDir, "request-prompt-max-id")
 	if err := os.MkdirAll(dirs.SnapInterfacesRequestsRunDir, 0o755); err != nil {
@@ -533,9 +548,10 @@ func New(notifyPrompt func(userID uint32, promptID prompting.IDType, data map[st
 	}
 
 	pdb := PromptDB{
-		perUser:      make(map[uint32]*userPromptDB),
-		notifyPrompt: notifyPrompt,
-		maxIDMmap:    maxIDMmap,
+		perUser:          make(map[uint32]*userPromptDB),
+		notifyPrompt:     notifyPrompt,
+		notifyUnanswered: notifyUnanswered,
+		maxIDMmap:        maxIDMmap,
 
 		requestMapFilepath: filepath.Join(dirs.SnapInterfacesRequestsRunDir, "request-key-mapping.json"),
 
@@ -1174,8 +1190,11 @@ func (pdb *PromptDB) Close() error {
 
 	// Stop all timers
 	pdb.readyTimer.Stop()
-	for _, userEntry := range pdb.perUser {
+	for user, userEntry := range pdb.perUser {
 		userEntry.expirationTimer.Stop()
+		for _, prompt := range userEntry.prompts {
+			pdb.notifyUnanswered(user, prompt, "cancelled")
+		}
 	}
 
 	// Clear all outstanding prompts
@@ -52,10 +52,18 @@ type noticeInfo struct {
 	data     map[string]string
 }
 
+type unansweredInfo struct {
+	promptID prompting.IDType
+	reason   string
+}
+
 type requestpromptsSuite struct {
 	defaultNotifyPrompt func(userID uint32, promptID prompting.IDType, data map[string]string) error
-	defaultUser         uint32
-	promptNotices       []*noticeInfo
+	// unanswered holds the prompts resolved without a reply
+	unanswered              []*unansweredInfo
+	defaultNotifyUnanswered func(userID uint32, prompt *requestprompts.Prompt, reason string)
+	defaultUser             uint32
+	promptNotices           []*noticeInfo
 
 	tmpdir             string
 	legacyMaxIDPath    string
@@ -89,6 +97,14 @@ func (s *requestpromptsSuite) SetUpTest(c *C) {
 		return nil
 	}
 	s.promptNotices = make([]*noticeInfo, 0)
+	s.defaultNotifyUnanswered = func(userID uint32, prompt *requestprompts.Prompt, reason string) {
+		c.Check(userID, Equals, s.defaultUser)
+		s.unanswered = append(s.unanswered, &unansweredInfo{
+			promptID: prompt.ID,
+			reason:   reason,
+		})
+	}
+	s.unanswered = nil
 	s.tmpdir = c.MkDir()
 	dirs.SetRootDir(s.tmpdir)
 	s.legacyMaxIDPath = filepath.Join(dirs.SnapRunDir, "request-prompt-max-id")
@@ -101,7 +117,7 @@ func (s *requestpromptsSuite) TestNew(c *C) {
 		c.Fatalf("unexpected notice with userID %d and ID %016X", userID, promptID)
 		return nil
 	}
-	pdb, err := requestprompts.New(notifyPrompt)
+	pdb, err := requestprompts.New(notifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 	c.Check(pdb.PerUser(), HasLen, 0)
@@ -145,7 +161,7 @@ func (s *requestpromptsSuite) TestNewValidMaxID(c *C) {
 		var initialData [8]byte
 		*(*uint64)(unsafe.Pointer(&initialData[0])) = testCase.initial
 		c.Assert(osutil.AtomicWriteFile(s.maxIDPath, initialData[:], 0o600, 0), IsNil)
-		pdb, err := requestprompts.New(notifyPrompt)
+		pdb, err := requestprompts.New(notifyPrompt, s.defaultNotifyUnanswered)
 		c.Assert(err, IsNil)
 		defer pdb.Close()
 		s.checkWrittenMaxID(c, testCase.initial)
@@ -163,7 +179,7 @@ func (s *requestpromptsSuite) TestNewInvalidMaxID(c *C) {
 	}
 
 	// First try with no existing max ID file
-	pdb, err := requestprompts.New(notifyPrompt)
+	pdb, err := requestprompts.New(notifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 	s.checkWrittenMaxID(c, 0)
@@ -181,7 +197,7 @@ func (s *requestpromptsSuite) TestNewInvalidMaxID(c *C) {
 		[]byte("123456789"),
 	} {
 		c.Assert(osutil.AtomicWriteFile(s.maxIDPath, initial, 0o600, 0), IsNil)
-		pdb, err := requestprompts.New(notifyPrompt)
+		pdb, err := requestprompts.New(notifyPrompt, s.defaultNotifyUnanswered)
 		c.Assert(err, IsNil)
 		defer pdb.Close()
 		s.checkWrittenMaxID(c, 0)
@@ -200,7 +216,7 @@ func (s *requestpromptsSuite) TestNewNextIDUniqueIDs(c *C) {
 	*(*uint64)(unsafe.Pointer(&initialData[0])) = initialMaxID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, initialData[:], 0600, 0), IsNil)
 
-	pdb1, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb1, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb1.Close()
 	expectedID := initialMaxID + 1
@@ -210,7 +226,7 @@ func (s *requestpromptsSuite) TestNewNextIDUniqueIDs(c *C) {
 	s.checkWrittenMaxID(c, expectedID)
 
 	// New prompt DB should start where existing one left off
-	pdb2, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb2, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb2.Close()
 	expectedID++
@@ -265,7 +281,7 @@ func (s *requestpromptsSuite) TestNewNextIDCompatibility(c *C) {
 	*(*uint64)(unsafe.Pointer(&initialData[0])) = initialMaxID
 	c.Assert(osutil.AtomicWriteFile(s.legacyMaxIDPath, initialData[:], 0600, 0), IsNil)
 
-	pdb1, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb1, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb1.Close()
 	expectedID := initialMaxID + 1
@@ -283,7 +299,7 @@ func (s *requestpromptsSuite) TestNewNextIDCompatibility(c *C) {
 }
 
 func (s *requestpromptsSuite) TestNewHandleReadying(c *C) {
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -340,7 +356,7 @@ func (s *requestpromptsSuite) TestNewPendingHandleReadying(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = expectedID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -414,7 +430,7 @@ func (s *requestpromptsSuite) TestNewPendingReadyTimeout(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = expectedID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -462,7 +478,7 @@ func (s *requestpromptsSuite) TestAddOrMergeNonMerges(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -752,7 +768,7 @@ func (s *requestpromptsSuite) TestAddOrMergeMerges(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -860,7 +876,7 @@ func (s *requestpromptsSuite) TestAddOrMergeDuplicateRequests(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -959,7 +975,7 @@ func (s *requestpromptsSuite) TestAddOrMergeInvalidMapping(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = expectedID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1119,7 +1135,7 @@ func (s *requestpromptsSuite) TestAddOrMergeTooMany(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1202,7 +1218,7 @@ func (s *requestpromptsSuite) TestPromptWithIDErrors(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1248,7 +1264,7 @@ func (s *requestpromptsSuite) TestReply(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1350,7 +1366,7 @@ func (s *requestpromptsSuite) TestReplyErrors(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1406,7 +1422,7 @@ func (s *requestpromptsSuite) TestHandleNewRule(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1549,7 +1565,7 @@ func (s *requestpromptsSuite) TestHandleNewRuleNonMatches(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1688,7 +1704,7 @@ func (s *requestpromptsSuite) TestClose(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 
 	metadata := &prompting.Metadata{
@@ -1726,12 +1742,21 @@ func (s *requestpromptsSuite) TestClose(c *C) {
 	// One notice for each prompt when created
 	s.checkNewNoticesSimple(c, expectedPromptIDs, nil)
 
+	c.Check(s.unanswered, HasLen, 0)
+
 	pdb.Close()
 
 	// No notices should be recorded when snapd is restarting, so that we can
 	// pick back up where we left off
 	s.checkNewNoticesUnorderedSimple(c, nil, nil)
 
+	// But the prompts are reported as cancelled
+	c.Assert(s.unanswered, HasLen, len(expectedPromptIDs))
+	for i, info := range s.unanswered {
+		c.Check(info.promptID, Equals, expectedPromptIDs[i])
+		c.Check(info.reason, Equals, "cancelled")
+	}
+
 	// ID map still on disk
 	s.checkWrittenRequestMap(c, expectedMap)
 
@@ -1776,7 +1801,7 @@ func (s *requestpromptsSuite) TestCloseBeforeReady(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = expectedID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 
 	select {
@@ -1813,7 +1838,7 @@ func (s *requestpromptsSuite) TestCloseBeforeReady(c *C) {
 }
 
 func (s *requestpromptsSuite) TestCloseThenOperate(c *C) {
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 
 	err = pdb.Close()
@@ -1880,7 +1905,7 @@ func (s *requestpromptsSuite) TestRequestMappingAcrossRestarts(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = uint64(2)
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -1997,7 +2022,7 @@ func (s *requestpromptsSuite) TestHandleReadying(c *C) {
 	*(*uint64)(unsafe.Pointer(&maxIDData)) = expectedID
 	c.Assert(osutil.AtomicWriteFile(s.maxIDPath, maxIDData[:], 0o600, 0), IsNil)
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -2177,7 +2202,7 @@ func (s *requestpromptsSuite) TestPromptMarshalJSON(c *C) {
 	})
 	defer restore()
 
-	pdb, err := requestprompts.New(s.defaultNotifyPrompt)
+	pdb, err := requestprompts.New(s.defaultNotifyPrompt, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -2233,6 +2258,32 @@ func (s *requestpromptsSuite) TestPromptMarshalJSON(c *C) {
 			outstandingPerms: []string{"access"},
 			expected:         `{"id":"0000000000000003","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"protonmail-bridge","pid":1248,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"audio-record","constraints":{"requested-permissions":["access"],"available-permissions":["access"]}}`,
 		},
+		{
+			metadata: &prompting.Metadata{
+				User:      s.defaultUser,
+				Snap:      "vlc",
+				PID:       1357,
+				Cgroup:    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope",
+				Interface: "removable-media",
+			},
+			path:             "/media/test/usb/movie.mkv",
+			requestedPerms:   []string{"read"},
+			outstandingPerms: []string{"read"},
+			expected:         `{"id":"0000000000000004","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"vlc","pid":1357,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"removable-media","constraints":{"path":"/media/test/usb/movie.mkv","requested-permissions":["read"],"available-permissions":["read","write","execute"]}}`,
+		},
+		{
+			metadata: &prompting.Metadata{
+				User:      s.defaultUser,
+				Snap:      "code",
+				PID:       2468,
+				Cgroup:    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope",
+				Interface: "personal-files",
+			},
+			path:             "/home/test/.gitconfig",
+			requestedPerms:   []string{"read", "write"},
+			outstandingPerms: []string{"write"},
+			expected:         `{"id":"0000000000000005","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"code","pid":2468,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"personal-files","constraints":{"path":"/home/test/.gitconfig","requested-permissions":["write"],"available-permissions":["read","write"]}}`,
+		},
 	} {
 		fakeRequest := &prompting.Request{Key: fmt.Sprintf("fake:%d", reqCount)}
 		reqCount++
@@ -2283,7 +2334,7 @@ func (s *requestpromptsSuite) TestPromptExpiration(c *C) {
 			data:     data,
 		}
 		return nil
-	})
+	}, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -2407,6 +2458,69 @@ func (s *requestpromptsSuite) TestPromptExpiration(c *C) {
 	s.checkWrittenRequestMap(c, expectedMap)
 }
 
+func (s *requestpromptsSuite) TestPromptExpirationNotifiesUnanswered(c *C) {
+	var timer *testtime.TestTimer
+	restore := requestprompts.MockTimeAfterFunc(func(d time.Duration, f func()) timeutil.Timer {
+		// Ready immediately so only timer is for prompt expiration
+		if timer != nil {
+			c.Fatalf("created more than one timer")
+		}
+		timer = testtime.AfterFunc(d, f)
+		return timer
+	})
+	defer restore()
+
+	metadata := &prompting.Metadata{
+		User:      s.defaultUser,
+		Snap:      "firefox",
+		PID:       1234,
+		Cgroup:    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope",
+		Interface: "home",
+	}
+	requestedPermissions := []string{"read", "write", "execute"}
+	outstandingPermissions := []string{"write", "execute"}
+
+	noticeChan := make(chan noticeInfo, 1)
+	unansweredChan := make(chan unansweredInfo, 1)
+	pdb, err := requestprompts.New(func(userID uint32, promptID prompting.IDType, data map[string]string) error {
+		c.Assert(userID, Equals, s.defaultUser)
+		noticeChan <- noticeInfo{
+			promptID: promptID,
+			data:     data,
+		}
+		return nil
+	}, func(userID uint32, prompt *requestprompts.Prompt, reason string) {
+		c.Check(userID, Equals, s.defaultUser)
+		c.Check(prompt.Snap, Equals, "firefox")
+		c.Check(prompt.Constraints.OutstandingPermissions(), DeepEquals, outstandingPermissions)
+		unansweredChan <- unansweredInfo{
+			promptID: prompt.ID,
+			reason:   reason,
+		}
+	})
+	c.Assert(err, IsNil)
+	defer pdb.Close()
+
+	req, replyChan := newRequestWithReplyChan("fake:123")
+	prompt, merged, err := pdb.AddOrMerge(metadata, "/home/test/foo", requestedPermissions, outstandingPermissions, req)
+	c.Assert(err, IsNil)
+	c.Assert(merged, Equals, false)
+	checkCurrentNotices(c, noticeChan, prompt.ID, nil)
+
+	timer.Elapse(requestprompts.InitialTimeout)
+	checkCurrentNotices(c, noticeChan, prompt.ID, map[string]string{"resolved": "expired"})
+	allowedPerms := waitForReply(c, replyChan)
+	c.Check(allowedPerms, DeepEquals, []string{"read"})
+
+	select {
+	case info := <-unansweredChan:
+		c.Check(info.promptID, Equals, prompt.ID)
+		c.Check(info.reason, Equals, "expired")
+	case <-time.After(10 * time.Second):
+		c.Fatalf("expired prompt was not reported as unanswered")
+	}
+}
+
 func (s *requestpromptsSuite) TestPromptExpirationRace(c *C) {
 	callbackSignaller := make(chan bool, 0)
 	var timer *testtime.TestTimer
@@ -2445,7 +2559,7 @@ func (s *requestpromptsSuite) TestPromptExpirationRace(c *C) {
 			data:     data,
 		}
 		return nil
-	})
+	}, s.defaultNotifyUnanswered)
 	c.Assert(err, IsNil)
 	defer pdb.Close()
 
@@ -0,0 +1,294 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/patterns"
+	"github.com/snapcore/snapd/logger"
+)
+
+// RuleContents holds the portable contents of a rule, without anything
+// specific to the user or system on which the rule was created. It is the
+// form in which rules are exported and imported, and in which admin rules are
+// defined.
+type RuleContents struct {
+	// Snap may only be empty for admin rules, in which case the rule applies
+	// to every snap.
+	Snap        string                    `json:"snap,omitempty"`
+	Interface   string                    `json:"interface"`
+	Constraints prompting.ConstraintsJSON `json:"constraints"`
+}
+
+// RulesFile is the format of the files holding exported rules or admin rules.
+type RulesFile struct {
+	Rules []*RuleContents `json:"rules"`
+}
+
+// ExportRules returns the contents of the given rules in a form which can be
+// imported again, on this or on another system.
+//
+// Only permissions with lifespan "forever" are exported, since permissions
+// with other lifespans are tied to the time or user session in which they were
+// created. Rules without any such permission are omitted.
+func ExportRules(rules []*Rule) ([]*RuleContents, error) {
+	exported := make([]*RuleContents, 0, len(rules))
+	for _, rule := range rules {
+		permissions := make(prompting.RulePermissionMap)
+		for perm, entry := range rule.Constraints.Permissions {
+			if entry.Lifespan == prompting.LifespanForever {
+				permissions[perm] = entry
+			}
+		}
+		if len(permissions) == 0 {
+			continue
+		}
+		constraints := &prompting.RuleConstraints{
+			InterfaceSpecific: rule.Constraints.InterfaceSpecific,
+			Permissions:       permissions,
+		}
+		data, err := json.Marshal(constraints)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export rule %s: %w", rule.ID, err)
+		}
+		var constraintsJSON prompting.ConstraintsJSON
+		if err := json.Unmarshal(data, &constraintsJSON); err != nil {
+			return nil, fmt.Errorf("cannot export rule %s: %w", rule.ID, err)
+		}
+		exported = append(exported, &RuleContents{
+			Snap:        rule.Snap,
+			Interface:   rule.Interface,
+			Constraints: constraintsJSON,
+		})
+	}
+	return exported, nil
+}
+
+// AdminRule is a rule defined by the administrator of the system, or shipped
+// by the gadget snap, which applies to every user.
+//
+// Admin rules take precedence over the rules of each user: if any admin rule
+// matches a requested permission, its outcome applies and the rules of the
+// user are not considered for that permission. Among the matching admin rules,
+// the one with the highest precedence path pattern applies, and if allow and
+// deny rules have equally specific path patterns, deny wins.
+//
+// Admin rules are read when the rule database is set up and cannot be changed
+// through the API.
+type AdminRule struct {
+	// Snap is empty if the rule applies to every snap.
+	Snap        string                     `json:"snap,omitempty"`
+	Interface   string                     `json:"interface"`
+	Constraints *prompting.RuleConstraints `json:"constraints"`
+	// Source identifies where the rule was defined, either "admin:<file>"
+	// for rules in the admin rules directory, or "gadget".
+	Source string `json:"source"`
+}
+
+func newAdminRule(contents *RuleContents, source string) (*AdminRule, error) {
+	constraints, err := prompting.UnmarshalConstraints(contents.Interface, contents.Constraints)
+	if err != nil {
+		return nil, err
+	}
+	ruleConstraints, err := constraints.ToRuleConstraints(contents.Interface, prompting.At{Time: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	for perm, entry := range ruleConstraints.Permissions {
+		if entry.Lifespan != prompting.LifespanForever {
+			return nil, fmt.Errorf("permission %q must have lifespan %q, not %q", perm, prompting.LifespanForever, entry.Lifespan)
+		}
+	}
+	return &AdminRule{
+		Snap:        contents.Snap,
+		Interface:   contents.Interface,
+		Constraints: ruleConstraints,
+		Source:      source,
+	}, nil
+}
+
+// readAdminRules reads the admin rules from the given rules file and labels
+// them with the given source.
+func readAdminRules(path string, source string) ([]*AdminRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rulesFile RulesFile
+	if err := json.NewDecoder(f).Decode(&rulesFile); err != nil {
+		return nil, fmt.Errorf("cannot decode admin rules file %s: %w", path, err)
+	}
+	rules := make([]*AdminRule, 0, len(rulesFile.Rules))
+	for i, contents := range rulesFile.Rules {
+		rule, err := newAdminRule(contents, source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d in admin rules file %s: %w", i, path, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadAdminRules replaces the admin rules of the rule database with the rules
+// defined in the *.json files of the admin rules directory, in lexical order,
+// followed by those defined in the given gadget rules file, if any.
+//
+// A file which cannot be read or which holds an invalid rule is skipped as a
+// whole, and the error is logged, so that it cannot prevent the rules from the
+// other files from applying.
+func (rdb *RuleDB) LoadAdminRules(gadgetRulesFile string) {
+	var adminRules []*AdminRule
+
+	files, err := filepath.Glob(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "*.json"))
+	if err != nil {
+		// Only possible error is ErrBadPattern
+		logger.Noticef("internal error: cannot list admin rules files: %v", err)
+	}
+	for _, path := range files {
+		rules, err := readAdminRules(path, "admin:"+filepath.Base(path))
+		if err != nil {
+			logger.Noticef("cannot load admin rules: %v", err)
+			continue
+		}
+		adminRules = append(adminRules, rules...)
+	}
+
+	if gadgetRulesFile != "" {
+		rules, err := readAdminRules(gadgetRulesFile, "gadget")
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// the gadget does not define any rules
+		case err != nil:
+			logger.Noticef("cannot load admin rules from gadget: %v", err)
+		default:
+			adminRules = append(adminRules, rules...)
+		}
+	}
+
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+	rdb.adminRules = adminRules
+}
+
+// AdminRules returns the admin rules which apply to the given snap and
+// interface. If snap or iface are empty, the admin rules are not filtered by
+// them.
+func (rdb *RuleDB) AdminRules(snap string, iface string) []*AdminRule {
+	rdb.mutex.RLock()
+	defer rdb.mutex.RUnlock()
+	rules := make([]*AdminRule, 0)
+	for _, rule := range rdb.adminRules {
+		if snap != "" && rule.Snap != "" && rule.Snap != snap {
+			continue
+		}
+		if iface != "" && rule.Interface != iface {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isPathPermAllowedByAdminRules checks whether the given path with the given
+// permission is allowed or denied by the admin rules for the given snap and
+// interface.
+//
+// If no admin rule applies, returns prompting_errors.ErrNoMatchingRule.
+func (rdb *RuleDB) isPathPermAllowedByAdminRules(snap string, iface string, path string, permission string) (bool, error) {
+	rdb.mutex.RLock()
+	defer rdb.mutex.RUnlock()
+	_, outcome, err := rdb.matchingAdminRule(snap, iface, path, permission)
+	if err != nil {
+		return false, err
+	}
+	return outcome.AsBool()
+}
+
+// matchingAdminRule returns the admin rule which decides the outcome of the
+// given permission for the given path, along with that outcome.
+//
+// If no admin rule applies, returns prompting_errors.ErrNoMatchingRule.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) matchingAdminRule(snap string, iface string, path string, permission string) (*AdminRule, prompting.OutcomeType, error) {
+	type adminMatch struct {
+		rule    *AdminRule
+		outcome prompting.OutcomeType
+	}
+	matches := make(map[string]adminMatch)
+	var matchingVariants []patterns.PatternVariant
+	var matchErr error
+	for _, rule := range rdb.adminRules {
+		if rule.Interface != iface || (rule.Snap != "" && rule.Snap != snap) {
+			continue
+		}
+		entry, exists := rule.Constraints.Permissions[permission]
+		if !exists {
+			continue
+		}
+		rule.Constraints.PathPattern().RenderAllVariants(func(index int, variant patterns.PatternVariant) {
+			if matchErr != nil {
+				return
+			}
+			variantStr := variant.String()
+			matched, err := patterns.PathPatternMatches(variantStr, path)
+			if err != nil {
+				matchErr = err
+				return
+			}
+			if !matched {
+				return
+			}
+			existing, exists := matches[variantStr]
+			if !exists {
+				matchingVariants = append(matchingVariants, variant)
+			}
+			if !exists || existing.outcome == prompting.OutcomeAllow {
+				// deny wins over allow for identical variants
+				matches[variantStr] = adminMatch{rule: rule, outcome: entry.Outcome}
+			}
+		})
+		if matchErr != nil {
+			// Only possible error is ErrBadPattern, which should not occur
+			return nil, "", fmt.Errorf("internal error: while matching path pattern: %w", matchErr)
+		}
+	}
+	if len(matchingVariants) == 0 {
+		return nil, "", prompting_errors.ErrNoMatchingRule
+	}
+	highestPrecedenceVariant, err := patterns.HighestPrecedencePattern(matchingVariants, path)
+	if err != nil {
+		return nil, "", err
+	}
+	match := matches[highestPrecedenceVariant.String()]
+	return match.rule, match.outcome, nil
+}
@@ -0,0 +1,282 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
+)
+
+func mustRuleContents(c *C, snap, iface, contentsJSON string) *requestrules.RuleContents {
+	var constraints prompting.ConstraintsJSON
+	c.Assert(json.Unmarshal([]byte(contentsJSON), &constraints), IsNil)
+	return &requestrules.RuleContents{
+		Snap:        snap,
+		Interface:   iface,
+		Constraints: constraints,
+	}
+}
+
+func (s *requestrulesSuite) TestExportImportRules(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	constraints := &prompting.Constraints{
+		InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+			Pattern: mustParsePathPattern(c, "/home/test/Documents/**"),
+		},
+		Permissions: prompting.PermissionMap{
+			"read":  &prompting.PermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanForever},
+			"write": &prompting.PermissionEntry{Outcome: prompting.OutcomeDeny, Lifespan: prompting.LifespanTimespan, Duration: "10m"},
+		},
+	}
+	_, err = rdb.AddRule(s.defaultUser, "firefox", "home", constraints)
+	c.Assert(err, IsNil)
+	constraints = &prompting.Constraints{
+		InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+			Pattern: mustParsePathPattern(c, "/home/test/Downloads/**"),
+		},
+		Permissions: prompting.PermissionMap{
+			"write": &prompting.PermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanTimespan, Duration: "10m"},
+		},
+	}
+	_, err = rdb.AddRule(s.defaultUser, "firefox", "home", constraints)
+	c.Assert(err, IsNil)
+
+	// only permissions with lifespan forever are exported
+	exported, err := requestrules.ExportRules(rdb.Rules(s.defaultUser))
+	c.Assert(err, IsNil)
+	c.Assert(exported, HasLen, 1)
+	data, err := json.Marshal(exported)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `[{"snap":"firefox","interface":"home","constraints":{"path-pattern":"/home/test/Documents/**","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}}]`)
+
+	// exported rules can be imported for another user, and imported rules
+	// with identical path patterns are merged
+	otherUser := s.defaultUser + 1
+	s.ruleNotices = nil
+	toImport := append(exported, mustRuleContents(c, "firefox", "home", `{"path-pattern":"/home/test/Documents/**","permissions":{"write":{"outcome":"deny","lifespan":"forever"}}}`))
+	imported, err := rdb.ImportRules(otherUser, toImport)
+	c.Assert(err, IsNil)
+	c.Assert(imported, HasLen, 1)
+	c.Check(imported[0].User, Equals, otherUser)
+	c.Check(imported[0].Constraints.Permissions, DeepEquals, prompting.RulePermissionMap{
+		"read":  &prompting.RulePermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanForever},
+		"write": &prompting.RulePermissionEntry{Outcome: prompting.OutcomeDeny, Lifespan: prompting.LifespanForever},
+	})
+	c.Check(rdb.Rules(otherUser), DeepEquals, imported)
+	c.Check(s.ruleNotices, DeepEquals, []*noticeInfo{{userID: otherUser, ruleID: imported[0].ID}})
+
+	// the imported rules were saved
+	c.Assert(rdb.Close(), IsNil)
+	rdb, err = requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	c.Check(rdb.Rules(otherUser), HasLen, 1)
+}
+
+func (s *requestrulesSuite) TestImportRulesErrors(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	existing := mustRuleContents(c, "firefox", "home", `{"path-pattern":"/home/test/foo","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}`)
+	imported, err := rdb.ImportRules(s.defaultUser, []*requestrules.RuleContents{existing})
+	c.Assert(err, IsNil)
+	c.Assert(imported, HasLen, 1)
+	rulesJSON, err := json.Marshal(rdb.Rules(s.defaultUser))
+	c.Assert(err, IsNil)
+
+	valid := mustRuleContents(c, "firefox", "home", `{"path-pattern":"/home/test/bar","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}`)
+	for _, t := range []struct {
+		contents *requestrules.RuleContents
+		err      string
+	}{
+		{
+			mustRuleContents(c, "", "home", `{"path-pattern":"/home/test/foo","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}`),
+			"cannot import rule 1: snap must be specified",
+		},
+		{
+			mustRuleContents(c, "firefox", "foo", `{"permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}`),
+			`cannot import rule 1: invalid interface: "foo"`,
+		},
+		{
+			mustRuleContents(c, "firefox", "home", `{"path-pattern":"/home/test/baz","permissions":{"read":{"outcome":"allow","lifespan":"single"}}}`),
+			`cannot import rule 1: cannot create rule with lifespan "single"`,
+		},
+		{
+			mustRuleContents(c, "firefox", "home", `{"path-pattern":"/home/test/{foo,qux}","permissions":{"read":{"outcome":"deny","lifespan":"forever"}}}`),
+			`cannot import rule 1: a rule with conflicting path pattern and permission already exists in the rule database`,
+		},
+	} {
+		s.ruleNotices = nil
+		_, err := rdb.ImportRules(s.defaultUser, []*requestrules.RuleContents{valid, t.contents})
+		c.Check(err, ErrorMatches, t.err)
+		// nothing was imported
+		data, err := json.Marshal(rdb.Rules(s.defaultUser))
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, string(rulesJSON))
+		c.Check(s.ruleNotices, HasLen, 0)
+	}
+}
+
+func (s *requestrulesSuite) TestLoadAdminRules(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsAdminRulesDir, 0o755), IsNil)
+	err := os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "10-corp.json"), []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/*/.ssh/**","permissions":{"read":{"outcome":"deny","lifespan":"forever"},"write":{"outcome":"deny","lifespan":"forever"}}}},
+{"snap":"firefox","interface":"home","constraints":{"path-pattern":"/home/*/.ssh/known_hosts","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+	// a file with any invalid rule is skipped as a whole
+	err = os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "20-bad.json"), []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/*/Music/**","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}},
+{"interface":"home","constraints":{"path-pattern":"/home/*/Videos/**","permissions":{"read":{"outcome":"allow","lifespan":"timespan","duration":"1h"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+	// files without the json suffix are ignored
+	err = os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "README"), []byte(`not json`), 0o644)
+	c.Assert(err, IsNil)
+	gadgetRulesFile := filepath.Join(c.MkDir(), "prompting-rules.json")
+	err = os.WriteFile(gadgetRulesFile, []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/*/.ssh/config","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}},
+{"interface":"camera","constraints":{"permissions":{"access":{"outcome":"allow","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	c.Check(rdb.AdminRules("", ""), HasLen, 0)
+	rdb.LoadAdminRules(gadgetRulesFile)
+
+	adminRules := rdb.AdminRules("", "")
+	c.Assert(adminRules, HasLen, 4)
+	var sources []string
+	for _, rule := range adminRules {
+		sources = append(sources, rule.Source)
+	}
+	c.Check(sources, DeepEquals, []string{"admin:10-corp.json", "admin:10-corp.json", "gadget", "gadget"})
+	c.Check(rdb.AdminRules("thunderbird", "home"), HasLen, 2)
+	c.Check(rdb.AdminRules("firefox", "home"), HasLen, 3)
+	c.Check(rdb.AdminRules("", "camera"), HasLen, 1)
+	data, err := json.Marshal(adminRules[1])
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"snap":"firefox","interface":"home","constraints":{"path-pattern":"/home/*/.ssh/known_hosts","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}},"source":"admin:10-corp.json"}`)
+
+	// admin rules take precedence over the rules of the user
+	constraints := &prompting.Constraints{
+		InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+			Pattern: mustParsePathPattern(c, "/home/test/**"),
+		},
+		Permissions: prompting.PermissionMap{
+			"read":  &prompting.PermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanForever},
+			"write": &prompting.PermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanForever},
+		},
+	}
+	_, err = rdb.AddRule(s.defaultUser, "thunderbird", "home", constraints)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		snap        string
+		path        string
+		allowed     []string
+		denied      bool
+		outstanding []string
+	}{
+		// denied by admin rule despite the rule of the user
+		{"thunderbird", "/home/test/.ssh/id_rsa", []string{}, true, []string{}},
+		// no admin rule matches
+		{"thunderbird", "/home/test/Documents/foo", []string{"read", "write"}, false, []string{}},
+		{"firefox", "/home/test/Documents/foo", []string{}, false, []string{"read", "write"}},
+		// more specific admin rule for the snap
+		{"firefox", "/home/test/.ssh/known_hosts", []string{"read"}, true, []string{}},
+		{"thunderbird", "/home/test/.ssh/known_hosts", []string{}, true, []string{}},
+		// more specific admin rule from the gadget for read only
+		{"firefox", "/home/test/.ssh/config", []string{"read"}, true, []string{}},
+	} {
+		allowed, denied, outstanding, err := rdb.IsRequestAllowed(s.defaultUser, t.snap, "home", t.path, []string{"read", "write"})
+		c.Assert(err, IsNil)
+		c.Check(allowed, DeepEquals, t.allowed, Commentf("%s %s", t.snap, t.path))
+		c.Check(denied, Equals, t.denied, Commentf("%s %s", t.snap, t.path))
+		c.Check(outstanding, DeepEquals, t.outstanding, Commentf("%s %s", t.snap, t.path))
+	}
+
+	// rules without a specific path pattern apply to every request
+	allowed, denied, _, err := rdb.IsRequestAllowed(s.defaultUser, "firefox", "camera", "/dev/video0", []string{"access"})
+	c.Assert(err, IsNil)
+	c.Check(allowed, DeepEquals, []string{"access"})
+	c.Check(denied, Equals, false)
+}
+
+func (s *requestrulesSuite) TestAdminRulesDenyWins(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsAdminRulesDir, 0o755), IsNil)
+	err := os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "rules.json"), []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/*/shared/**","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}},
+{"snap":"firefox","interface":"home","constraints":{"path-pattern":"/home/*/shared/**","permissions":{"read":{"outcome":"deny","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	rdb.LoadAdminRules("")
+
+	allowed, denied, _, err := rdb.IsRequestAllowed(s.defaultUser, "firefox", "home", "/home/test/shared/foo", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(allowed, HasLen, 0)
+	c.Check(denied, Equals, true)
+	allowed, denied, _, err = rdb.IsRequestAllowed(s.defaultUser, "thunderbird", "home", "/home/test/shared/foo", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(allowed, DeepEquals, []string{"read"})
+	c.Check(denied, Equals, false)
+}
+
+func (s *requestrulesSuite) TestMatchingRules(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsAdminRulesDir, 0o755), IsNil)
+	err := os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "corp.json"), []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/*/.ssh/**","permissions":{"write":{"outcome":"deny","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	rdb.LoadAdminRules("")
+
+	constraints := &prompting.Constraints{
+		InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+			Pattern: mustParsePathPattern(c, "/home/test/**"),
+		},
+		Permissions: prompting.PermissionMap{
+			"read": &prompting.PermissionEntry{Outcome: prompting.OutcomeAllow, Lifespan: prompting.LifespanForever},
+		},
+	}
+	rule, err := rdb.AddRule(s.defaultUser, "firefox", "home", constraints)
+	c.Assert(err, IsNil)
+
+	matching := rdb.MatchingRules(s.defaultUser, "firefox", "home", "/home/test/.ssh/config", []string{"read", "write", "execute"})
+	c.Check(matching, DeepEquals, []string{rule.ID.String(), "admin:corp.json"})
+	matching = rdb.MatchingRules(s.defaultUser, "firefox", "home", "/home/test/foo", []string{"read", "write"})
+	c.Check(matching, DeepEquals, []string{rule.ID.String()})
+	matching = rdb.MatchingRules(s.defaultUser, "thunderbird", "home", "/home/test/foo", []string{"read"})
+	c.Check(matching, HasLen, 0)
+}
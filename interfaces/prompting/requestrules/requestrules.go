@@ -28,6 +28,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -175,6 +176,10 @@ type RuleDB struct {
 	// is matched by existing rules, and which of those rules has precedence.
 	perUser map[uint32]*userDB
 
+	// adminRules apply to every user and take precedence over the rules of
+	// each user, see AdminRule.
+	adminRules []*AdminRule
+
 	dbPath string
 	// notifyRule is a closure which will be called to record a notice when a
 	// rule is added, patched, or removed.
@@ -1081,9 +1086,84 @@ func (rdb *RuleDB) makeNewRule(user uint32, snap string, iface string, constrain
 	return &newRule, nil
 }
 
+// ImportRules creates rules with the given contents for the given user and
+// adds them to the rule database, merging them with existing rules where
+// possible, just as AddRule does for a single rule.
+//
+// Rules are imported atomically: if any of the given rules is invalid or
+// conflicts with an existing or another imported rule, returns an error and
+// the database is left unchanged. Otherwise, returns the added or merged
+// rules, and saves the database to disk.
+func (rdb *RuleDB) ImportRules(user uint32, contents []*RuleContents) ([]*Rule, error) {
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+
+	if rdb.maxIDMmap.IsClosed() {
+		return nil, prompting_errors.ErrPromptingClosed
+	}
+
+	currSession, err := readOrAssignUserSessionID(rdb, user)
+	if err != nil && !errors.Is(err, errNoUserSession) {
+		return nil, err
+	}
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: currSession,
+	}
+
+	// Validate every rule before touching the database
+	newRules := make([]*Rule, 0, len(contents))
+	for i, ruleContents := range contents {
+		if ruleContents.Snap == "" {
+			return nil, fmt.Errorf("cannot import rule %d: snap must be specified", i)
+		}
+		constraints, err := prompting.UnmarshalConstraints(ruleContents.Interface, ruleContents.Constraints)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import rule %d: %w", i, err)
+		}
+		newRule, err := rdb.makeNewRule(user, ruleContents.Snap, ruleContents.Interface, constraints, at)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import rule %d: %w", i, err)
+		}
+		newRules = append(newRules, newRule)
+	}
+
+	// Several imported rules may be merged into the same rule, so keep track
+	// of the resulting rules by ID.
+	var importedRules []*Rule
+	importedIndex := make(map[prompting.IDType]int)
+	const save = false
+	for i, newRule := range newRules {
+		importedRule, _, err := rdb.addOrMergeRule(newRule, at, save)
+		if err != nil {
+			// Nothing was saved yet, so roll back every rule imported so far
+			// by reloading the database from disk.
+			return nil, strutil.JoinErrors(fmt.Errorf("cannot import rule %d: %w", i, err), rdb.load())
+		}
+		if index, exists := importedIndex[importedRule.ID]; exists {
+			importedRules[index] = importedRule
+			continue
+		}
+		importedIndex[importedRule.ID] = len(importedRules)
+		importedRules = append(importedRules, importedRule)
+	}
+
+	if err := rdb.save(); err != nil {
+		return nil, strutil.JoinErrors(err, rdb.load())
+	}
+
+	for _, rule := range importedRules {
+		rdb.notifyRule(user, rule.ID, nil)
+	}
+	return importedRules, nil
+}
+
 // IsRequestAllowed checks whether a request with the given parameters is
 // allowed or denied by existing rules.
 //
+// Admin rules are checked first, and if any admin rule matches a permission,
+// the rules of the user are not considered for that permission.
+//
 // If any of the given permissions are allowed, they are returned as
 // allowedPerms. If any permissions are denied, then returns anyDenied as true.
 // If any of the given permissions were not matched by an existing rule, then
@@ -1101,7 +1181,11 @@ func (rdb *RuleDB) IsRequestAllowed(user uint32, snap string, iface string, path
 	}
 	var errs []error
 	for _, perm := range permissions {
-		allowed, err := isPathPermAllowed(rdb, user, snap, iface, path, perm, at)
+		// Admin rules take precedence over the rules of the user
+		allowed, err := rdb.isPathPermAllowedByAdminRules(snap, iface, path, perm)
+		if errors.Is(err, prompting_errors.ErrNoMatchingRule) {
+			allowed, err = isPathPermAllowed(rdb, user, snap, iface, path, perm, at)
+		}
 		switch {
 		case err == nil:
 			if allowed {
@@ -1129,9 +1213,24 @@ var isPathPermAllowed = (*RuleDB).isPathPermAllowed
 func (rdb *RuleDB) isPathPermAllowed(user uint32, snap string, iface string, path string, permission string, at prompting.At) (bool, error) {
 	rdb.mutex.RLock()
 	defer rdb.mutex.RUnlock()
+	matchingEntry, err := rdb.matchingVariantEntry(user, snap, iface, path, permission, at)
+	if err != nil {
+		return false, err
+	}
+	return matchingEntry.Outcome.AsBool()
+}
+
+// matchingVariantEntry returns the variant entry with the highest precedence
+// pattern variant which matches the given path for the given permission, out
+// of the unexpired rules for the given user, snap, and interface.
+//
+// If no rule applies, returns prompting_errors.ErrNoMatchingRule.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) matchingVariantEntry(user uint32, snap string, iface string, path string, permission string, at prompting.At) (*variantEntry, error) {
 	permissionMap := rdb.permissionDBForUserSnapInterfacePermission(user, snap, iface, permission)
 	if permissionMap == nil {
-		return false, prompting_errors.ErrNoMatchingRule
+		return nil, prompting_errors.ErrNoMatchingRule
 	}
 	variantMap := permissionMap.VariantEntries
 	var matchingVariants []patterns.PatternVariant
@@ -1146,21 +1245,70 @@ func (rdb *RuleDB) isPathPermAllowed(user uint32, snap string, iface string, pat
 		matched, err := patterns.PathPatternMatches(variantStr, path)
 		if err != nil {
 			// Only possible error is ErrBadPattern, which should not occur
-			return false, fmt.Errorf("internal error: while matching path pattern: %w", err)
+			return nil, fmt.Errorf("internal error: while matching path pattern: %w", err)
 		}
 		if matched {
 			matchingVariants = append(matchingVariants, variantEntry.Variant)
 		}
 	}
 	if len(matchingVariants) == 0 {
-		return false, prompting_errors.ErrNoMatchingRule
+		return nil, prompting_errors.ErrNoMatchingRule
 	}
 	highestPrecedenceVariant, err := patterns.HighestPrecedencePattern(matchingVariants, path)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	matchingEntry := variantMap[highestPrecedenceVariant.String()]
-	return matchingEntry.Outcome.AsBool()
+	return &matchingEntry, nil
+}
+
+// MatchingRules returns the rules which decide the outcome of the given
+// permissions for the given path, as IDs of rules of the given user, or as
+// sources of admin rules, without duplicates. Permissions for which no rule
+// applies are ignored.
+//
+// This is intended to record which rules were responsible for the outcome
+// returned by IsRequestAllowed.
+func (rdb *RuleDB) MatchingRules(user uint32, snap string, iface string, path string, permissions []string) []string {
+	currSession, err := readOrAssignUserSessionID(rdb, user)
+	if err != nil && !errors.Is(err, errNoUserSession) {
+		logger.Debugf("cannot read session ID of user %d: %v", user, err)
+	}
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: currSession,
+	}
+
+	rdb.mutex.RLock()
+	defer rdb.mutex.RUnlock()
+	var matching []string
+	for _, perm := range permissions {
+		if adminRule, _, err := rdb.matchingAdminRule(snap, iface, path, perm); err == nil {
+			if !strutil.ListContains(matching, adminRule.Source) {
+				matching = append(matching, adminRule.Source)
+			}
+			continue
+		}
+		entry, err := rdb.matchingVariantEntry(user, snap, iface, path, perm, at)
+		if err != nil {
+			continue
+		}
+		// Several rules may have an identical pattern variant, but they
+		// must then have the same outcome, so list all which are unexpired.
+		ids := make([]string, 0, len(entry.RuleEntries))
+		for id, ruleEntry := range entry.RuleEntries {
+			if !ruleEntry.Expired(at) {
+				ids = append(ids, id.String())
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if !strutil.ListContains(matching, id) {
+				matching = append(matching, id)
+			}
+		}
+	}
+	return matching
 }
 
 // RuleWithID returns the rule with the given ID.
@@ -0,0 +1,323 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package metrics provides counters, gauges and histograms about the
+// internals of snapd, which can be exposed in the OpenMetrics text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the media type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// enabled is whether metrics are collected, as set by SetEnabled.
+var enabled int32
+
+// SetEnabled sets whether metrics are collected, which they are not by
+// default so that measuring costs nothing when metrics are not exposed.
+func SetEnabled(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&enabled, v)
+}
+
+// Enabled returns whether metrics are collected. It is cheap enough to be
+// checked on hot paths before measuring anything.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// DefaultDurationBuckets are the default upper bounds, in seconds, of the
+// buckets of histograms of durations.
+var DefaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+
+// series holds the values of a metric for one set of label values.
+type series struct {
+	labelValues []string
+	// value is the value of a counter or gauge, or the sum of the
+	// observations of a histogram.
+	value float64
+	// buckets holds the cumulative counts of the observations of a
+	// histogram in each bucket, and count their total count.
+	buckets []uint64
+	count   uint64
+}
+
+// family is a metric with all of its series.
+type family struct {
+	name       string
+	help       string
+	typ        metricType
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("internal error: metric %s has %d labels, got %d values", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	s := f.series[key]
+	if s == nil {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.typ == histogramType {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Registry holds a set of metrics.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// DefaultRegistry holds the metrics created by the package level
+// constructors.
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64, labelNames []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("internal error: metric %s registered twice", name))
+	}
+	r.names[name] = true
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// CounterVec is a counter, which can only increase, with a value for each
+// combination of values of its labels.
+type CounterVec struct {
+	f *family
+}
+
+// NewCounterVec returns a new counter with the given labels in the
+// registry. By convention, the name of counters does not end with "_total",
+// which is added when exposing them.
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{f: r.register(name, help, counterType, nil, labelNames)}
+}
+
+// NewCounterVec returns a new counter in the default registry.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// Add adds the given non-negative value to the counter for the given label
+// values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("internal error: cannot decrease counter %s", c.f.name))
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Inc increments the counter for the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec is a gauge, which can go up and down, with a value for each
+// combination of values of its labels.
+type GaugeVec struct {
+	f *family
+}
+
+// NewGaugeVec returns a new gauge with the given labels in the registry.
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{f: r.register(name, help, gaugeType, nil, labelNames)}
+}
+
+// NewGaugeVec returns a new gauge in the default registry.
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// Set sets the gauge for the given label values to the given value.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+// Add adds the given value, which may be negative, to the gauge for the
+// given label values.
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value += v
+}
+
+// HistogramVec is a histogram, which counts observations in buckets, with
+// a distribution for each combination of values of its labels.
+type HistogramVec struct {
+	f *family
+}
+
+// NewHistogramVec returns a new histogram with the given bucket upper bounds
+// and labels in the registry.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("internal error: buckets of histogram %s are not sorted", name))
+	}
+	return &HistogramVec{f: r.register(name, help, histogramType, buckets, labelNames)}
+}
+
+// NewHistogramVec returns a new histogram in the default registry.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+// Observe adds the given observation to the histogram for the given label
+// values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	for i, bound := range h.f.buckets {
+		if v <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelValueEscaper.Replace(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, strings.ReplaceAll(f.help, "\n", " "))
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		switch f.typ {
+		case counterType:
+			fmt.Fprintf(w, "%s_total%s %s\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatFloat(s.value))
+		case gaugeType:
+			fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatFloat(s.value))
+		case histogramType:
+			for i, bound := range f.buckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", formatFloat(bound)), s.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_count%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatFloat(s.value))
+		}
+	}
+}
+
+// WriteOpenMetrics writes the metrics of the given registries to w in the
+// OpenMetrics text format.
+func WriteOpenMetrics(w io.Writer, registries ...*Registry) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range registries {
+		r.mu.Lock()
+		families := append([]*family(nil), r.families...)
+		r.mu.Unlock()
+		for _, f := range families {
+			f.write(bw)
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/metrics"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type metricsSuite struct{}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) TestWriteOpenMetrics(c *C) {
+	r := metrics.NewRegistry()
+	counter := r.NewCounterVec("test_retries", "Number of retries.", "kind")
+	gauge := r.NewGaugeVec("test_changes", "Number of changes.")
+	histogram := r.NewHistogramVec("test_duration_seconds", "Duration of things.", []float64{0.1, 1})
+
+	counter.Inc("b")
+	counter.Add(2, "a")
+	counter.Inc("a")
+	gauge.Set(5)
+	gauge.Add(-2)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+
+	var buf bytes.Buffer
+	c.Assert(metrics.WriteOpenMetrics(&buf, r), IsNil)
+	c.Check(buf.String(), Equals, `# TYPE test_retries counter
+# HELP test_retries Number of retries.
+test_retries_total{kind="a"} 3
+test_retries_total{kind="b"} 1
+# TYPE test_changes gauge
+# HELP test_changes Number of changes.
+test_changes 3
+# TYPE test_duration_seconds histogram
+# HELP test_duration_seconds Duration of things.
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_count 3
+test_duration_seconds_sum 2.55
+# EOF
+`)
+}
+
+func (s *metricsSuite) TestWriteOpenMetricsMultipleRegistries(c *C) {
+	r1 := metrics.NewRegistry()
+	r1.NewGaugeVec("test_one", "One.")
+	r2 := metrics.NewRegistry()
+	h := r2.NewHistogramVec("test_two_seconds", "Two.", []float64{1}, "manager")
+	h.Observe(3, `some "odd"\manager`)
+
+	var buf bytes.Buffer
+	c.Assert(metrics.WriteOpenMetrics(&buf, r1, r2), IsNil)
+	c.Check(buf.String(), Equals, `# TYPE test_one gauge
+# HELP test_one One.
+# TYPE test_two_seconds histogram
+# HELP test_two_seconds Two.
+test_two_seconds_bucket{manager="some \"odd\"\\manager",le="1"} 0
+test_two_seconds_bucket{manager="some \"odd\"\\manager",le="+Inf"} 1
+test_two_seconds_count{manager="some \"odd\"\\manager"} 1
+test_two_seconds_sum{manager="some \"odd\"\\manager"} 3
+# EOF
+`)
+}
+
+func (s *metricsSuite) TestMisuse(c *C) {
+	r := metrics.NewRegistry()
+	counter := r.NewCounterVec("test_counter", "Counter.", "kind")
+	c.Check(func() { r.NewGaugeVec("test_counter", "Again.") }, PanicMatches, "internal error: metric test_counter registered twice")
+	c.Check(func() { counter.Inc() }, PanicMatches, "internal error: metric test_counter has 1 labels, got 0 values")
+	c.Check(func() { counter.Add(-1, "foo") }, PanicMatches, "internal error: cannot decrease counter test_counter")
+	c.Check(func() { r.NewHistogramVec("test_histogram", "Histogram.", []float64{2, 1}) }, PanicMatches, "internal error: buckets of histogram test_histogram are not sorted")
+}
+
+func (s *metricsSuite) TestEnabled(c *C) {
+	c.Check(metrics.Enabled(), Equals, false)
+	metrics.SetEnabled(true)
+	defer metrics.SetEnabled(false)
+	c.Check(metrics.Enabled(), Equals, true)
+	metrics.SetEnabled(false)
+	c.Check(metrics.Enabled(), Equals, false)
+}
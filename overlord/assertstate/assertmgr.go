@@ -36,7 +36,9 @@ import (
 // system states. It manipulates the observed system state to ensure
 // nothing in it violates existing assertions, or misses required
 // ones.
-type AssertManager struct{}
+type AssertManager struct {
+	state *state.State
+}
 
 // Manager returns a new assertion manager.
 func Manager(s *state.State, runner *state.TaskRunner) (*AssertManager, error) {
@@ -54,12 +56,15 @@ func Manager(s *state.State, runner *state.TaskRunner) (*AssertManager, error) {
 	ReplaceDB(s, db)
 	s.Unlock()
 
-	return &AssertManager{}, nil
+	return &AssertManager{state: s}, nil
 }
 
 // Ensure implements StateManager.Ensure.
 func (m *AssertManager) Ensure() error {
-	return nil
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	return ensureValidationSetTransitions(m.state)
 }
 
 type cachedDBKey struct{}
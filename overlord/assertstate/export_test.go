@@ -19,6 +19,17 @@
 
 package assertstate
 
+import (
+	"context"
+	"time"
+
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
 // expose for testing
 var (
 	DoFetch                                   = doFetch
@@ -43,3 +54,19 @@ func MockMaxValidationSetsHistorySize(n int) (restore func()) {
 		maxValidationSetsHistorySize = oldMaxValidationSetsHistorySize
 	}
 }
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
+
+func MockRestartInMaintenanceWindow(f func(st *state.State, t time.Time) (bool, time.Time, error)) (restore func()) {
+	return testutil.Mock(&restartInMaintenanceWindow, f)
+}
+
+func MockSnapstateDownload(f func(ctx context.Context, st *state.State, name string, components []string, downloadDir string, revOpts snapstate.RevisionOptions, opts snapstate.Options) (*state.TaskSet, *snap.Info, error)) (restore func()) {
+	return testutil.Mock(&snapstateDownload, f)
+}
+
+func MockSnapstateResolveValidationSetsEnforcementError(f func(ctx context.Context, st *state.State, valErr *snapasserts.ValidationSetsValidationError, pinnedSeqs map[string]int, userID int) ([]*state.TaskSet, []string, error)) (restore func()) {
+	return testutil.Mock(&snapstateResolveValidationSetsEnforcementError, f)
+}
@@ -148,6 +148,11 @@ func ForgetValidationSet(st *state.State, accountID, name string, opts ForgetVal
 		}
 	}
 
+	// a scheduled transition has nothing left to move
+	if err := CancelValidationSetTransition(st, accountID, name); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
 	delete(vsmap, ValidationSetKey(accountID, name))
 	st.Set("validation-sets", vsmap)
 	return addCurrentTrackingToValidationSetsHistory(st)
@@ -0,0 +1,493 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	timeNow = time.Now
+
+	restartInMaintenanceWindow                     = restart.InMaintenanceWindow
+	snapstateDownload                              = snapstate.Download
+	snapstateResolveValidationSetsEnforcementError = snapstate.ResolveValidationSetsEnforcementError
+
+	// delay before retrying a transition that could not be started
+	validationSetTransitionRetryDelay = 10 * time.Minute
+)
+
+var (
+	preDownloadValidationSetChangeKind = swfeats.RegisterChangeKind("pre-download-validation-set")
+	refreshSnapChangeKind              = swfeats.RegisterChangeKind("refresh-snap")
+)
+
+// ValidationSetTransition describes a scheduled move of an enforced
+// validation set to a newer sequence.
+type ValidationSetTransition struct {
+	AccountID string `json:"account-id"`
+	Name      string `json:"name"`
+	// Sequence is the sequence the validation set will be pinned at.
+	Sequence int `json:"sequence"`
+	// At is the earliest time at which the transition is performed, the
+	// zero time means as soon as possible.
+	At time.Time `json:"at,omitempty"`
+	// InMaintenanceWindow restricts the transition to the configured
+	// maintenance windows.
+	InMaintenanceWindow bool `json:"in-maintenance-window,omitempty"`
+	UserID              int  `json:"user-id,omitempty"`
+
+	// PreDownloadChange is the change downloading the snap revisions
+	// required by the new sequence ahead of time.
+	PreDownloadChange string `json:"pre-download-change,omitempty"`
+	// Change is the change performing the transition once it started.
+	Change string `json:"change,omitempty"`
+	// NextAttempt is set when starting the transition, or its change,
+	// failed and it needs to be retried.
+	NextAttempt time.Time `json:"next-attempt,omitempty"`
+}
+
+func (tr *ValidationSetTransition) key() string {
+	return ValidationSetKey(tr.AccountID, tr.Name)
+}
+
+func (tr *ValidationSetTransition) downloadDir() string {
+	return filepath.Join(filepath.Dir(dirs.SnapDownloadCacheDir), "validation-sets",
+		fmt.Sprintf("%s_%s_%d", tr.AccountID, tr.Name, tr.Sequence))
+}
+
+// ValidationSetTransitions returns the scheduled validation set
+// transitions keyed by account-id/name.
+func ValidationSetTransitions(st *state.State) (map[string]*ValidationSetTransition, error) {
+	var transitions map[string]*ValidationSetTransition
+	if err := st.Get("validation-set-transitions", &transitions); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if transitions == nil {
+		transitions = make(map[string]*ValidationSetTransition)
+	}
+	return transitions, nil
+}
+
+func setValidationSetTransitions(st *state.State, transitions map[string]*ValidationSetTransition) {
+	if len(transitions) == 0 {
+		st.Set("validation-set-transitions", nil)
+		return
+	}
+	st.Set("validation-set-transitions", transitions)
+}
+
+// ScheduleValidationSetTransition schedules moving the given enforced
+// validation set to tr.Sequence at tr.At or, if requested, within a
+// maintenance window. The validation set assertion for the new sequence is
+// fetched right away and the snap revisions it requires are pre-downloaded
+// by the returned change, which is nil if there is nothing to download. The
+// transition itself is performed by the assertion manager and results in a
+// single change refreshing the affected snaps and enforcing the new sequence.
+func ScheduleValidationSetTransition(st *state.State, tr *ValidationSetTransition) (*state.Change, error) {
+	key := tr.key()
+
+	var current ValidationSetTracking
+	if err := GetValidationSet(st, tr.AccountID, tr.Name, &current); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, fmt.Errorf("cannot schedule transition of validation set %v: validation set is not tracked", key)
+		}
+		return nil, err
+	}
+	if current.Mode != Enforce {
+		return nil, fmt.Errorf("cannot schedule transition of validation set %v: validation set is not enforced", key)
+	}
+	if tr.Sequence <= current.Sequence() {
+		return nil, fmt.Errorf("cannot schedule transition of validation set %v to sequence %d: sequence %d is already enforced", key, tr.Sequence, current.Sequence())
+	}
+	if _, err := validateSequenceAgainstModel(st, tr.AccountID, tr.Name, tr.Sequence); err != nil {
+		return nil, fmt.Errorf("cannot schedule transition of validation set %v to sequence %d: %v", key, tr.Sequence, err)
+	}
+
+	transitions, err := ValidationSetTransitions(st)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := transitions[key]; ok {
+		return nil, fmt.Errorf("cannot schedule transition of validation set %v: a transition is already scheduled", key)
+	}
+
+	vs, err := validationSetAssertionForTransition(st, tr.AccountID, tr.Name, tr.Sequence, tr.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sched := *tr
+	sched.PreDownloadChange = ""
+	sched.Change = ""
+	sched.NextAttempt = time.Time{}
+
+	chg, err := preDownloadForTransition(st, &sched, vs)
+	if err != nil {
+		return nil, err
+	}
+	if chg != nil {
+		sched.PreDownloadChange = chg.ID()
+	}
+
+	transitions[key] = &sched
+	setValidationSetTransitions(st, transitions)
+
+	if !sched.At.IsZero() {
+		st.EnsureBefore(sched.At.Sub(timeNow()))
+	}
+
+	return chg, nil
+}
+
+// CancelValidationSetTransition cancels the transition scheduled for the
+// given validation set. A transition that already started can no longer be
+// cancelled, the change performing it can be aborted instead.
+func CancelValidationSetTransition(st *state.State, accountID, name string) error {
+	key := ValidationSetKey(accountID, name)
+	transitions, err := ValidationSetTransitions(st)
+	if err != nil {
+		return err
+	}
+	tr, ok := transitions[key]
+	if !ok {
+		return state.ErrNoState
+	}
+	if tr.Change != "" {
+		if chg := st.Change(tr.Change); chg != nil && !chg.IsReady() {
+			return fmt.Errorf("cannot cancel transition of validation set %v: change %s is in progress", key, chg.ID())
+		}
+	}
+	dropValidationSetTransition(st, transitions, tr)
+	setValidationSetTransitions(st, transitions)
+	return nil
+}
+
+func dropValidationSetTransition(st *state.State, transitions map[string]*ValidationSetTransition, tr *ValidationSetTransition) {
+	if tr.PreDownloadChange != "" {
+		if chg := st.Change(tr.PreDownloadChange); chg != nil && !chg.IsReady() {
+			chg.Abort()
+		}
+	}
+	if err := os.RemoveAll(tr.downloadDir()); err != nil {
+		logger.Noticef("cannot remove pre-downloaded snaps for validation set %v: %v", tr.key(), err)
+	}
+	delete(transitions, tr.key())
+}
+
+// validationSetAssertionForTransition fetches the validation set assertion
+// with the given sequence and checks it is not in conflict with the other
+// enforced validation sets. Unlike validationSetAssertionForEnforce installed
+// snaps are not checked, those are taken care of by the transition.
+func validationSetAssertionForTransition(st *state.State, accountID, name string, sequence int, userID int) (*asserts.ValidationSet, error) {
+	deviceCtx, err := snapstate.DevicePastSeeding(st, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	db := cachedDB(st)
+	headers := map[string]string{
+		"series":     release.Series,
+		"account-id": accountID,
+		"name":       name,
+		"sequence":   fmt.Sprintf("%d", sequence),
+	}
+
+	checkForConflicts := func(vs *asserts.ValidationSet) error {
+		valsets, err := TrackedEnforcedValidationSets(st, vs)
+		if err != nil {
+			return err
+		}
+		return valsets.Conflict()
+	}
+
+	vs, err := getSpecificSequenceOrLatest(db, headers)
+	if err == nil {
+		if err := checkForConflicts(vs); err != nil {
+			return nil, err
+		}
+		return vs, nil
+	}
+	if !errors.Is(err, &asserts.NotFoundError{}) {
+		return nil, err
+	}
+
+	pool := asserts.NewPool(db, maxGroups)
+	atSeq := &asserts.AtSequence{
+		Type:        asserts.ValidationSetType,
+		SequenceKey: []string{release.Series, accountID, name},
+		Sequence:    sequence,
+		Revision:    asserts.RevisionNotKnown,
+		Pinned:      true,
+	}
+	if err := pool.AddUnresolvedSequence(atSeq, atSeq.Unique()); err != nil {
+		return nil, err
+	}
+
+	checkBeforeCommit := func(db *asserts.Database, bs asserts.Backstore) error {
+		tmpDb := db.WithStackedBackstore(bs)
+		vs, err = getSpecificSequenceOrLatest(tmpDb, headers)
+		if err != nil {
+			return fmt.Errorf("internal error: cannot find validation set assertion: %v", err)
+		}
+		return checkForConflicts(vs)
+	}
+
+	opts := &RefreshAssertionsOptions{IsAutoRefresh: false}
+	if err := resolvePoolNoFallback(st, pool, checkBeforeCommit, userID, deviceCtx, opts); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// preDownloadForTransition creates a change downloading the snap revisions
+// needed to satisfy the given validation set into the transition download
+// directory. Keeping the downloaded files around protects their copies in
+// the download cache, from where they are picked up by the refresh
+// performing the transition.
+func preDownloadForTransition(st *state.State, tr *ValidationSetTransition, vs *asserts.ValidationSet) (*state.Change, error) {
+	valsets, err := TrackedEnforcedValidationSets(st, vs)
+	if err != nil {
+		return nil, err
+	}
+	snaps, ignoreValidation, err := snapstate.InstalledSnaps(st)
+	if err != nil {
+		return nil, err
+	}
+
+	err = valsets.CheckInstalledSnaps(snaps, ignoreValidation)
+	if err == nil {
+		return nil, nil
+	}
+	verr, ok := err.(*snapasserts.ValidationSetsValidationError)
+	if !ok {
+		return nil, err
+	}
+	if len(verr.InvalidSnaps) != 0 {
+		invalid := make([]string, 0, len(verr.InvalidSnaps))
+		for name := range verr.InvalidSnaps {
+			invalid = append(invalid, name)
+		}
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("cannot schedule transition of validation set %v to sequence %d: snaps %s would need to be removed", tr.key(), tr.Sequence, strutil.Quoted(invalid))
+	}
+
+	required := make(map[string]snap.Revision, len(verr.WrongRevisionSnaps)+len(verr.MissingSnaps))
+	for _, byName := range []map[string]map[snap.Revision][]string{verr.WrongRevisionSnaps, verr.MissingSnaps} {
+		for name, revs := range byName {
+			for rev := range revs {
+				required[name] = rev
+			}
+		}
+	}
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tss []*state.TaskSet
+	for _, name := range names {
+		revOpts := snapstate.RevisionOptions{
+			Revision:       required[name],
+			ValidationSets: valsets,
+		}
+		ts, _, err := snapstateDownload(context.TODO(), st, name, nil, tr.downloadDir(), revOpts, snapstate.Options{UserID: tr.UserID})
+		if err != nil {
+			return nil, fmt.Errorf("cannot pre-download snap %q for validation set %v: %v", name, tr.key(), err)
+		}
+		tss = append(tss, ts)
+	}
+
+	if err := os.MkdirAll(tr.downloadDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("Pre-download snaps %s for validation set %s", strutil.Quoted(names), tr.transitionString())
+	chg := st.NewChange(preDownloadValidationSetChangeKind, summary)
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	return chg, nil
+}
+
+func (tr *ValidationSetTransition) transitionString() string {
+	return fmt.Sprintf("%s=%d", tr.key(), tr.Sequence)
+}
+
+// startValidationSetTransition enforces the new sequence of the validation
+// set. If snaps need to be refreshed or installed first a change doing so
+// atomically is returned, otherwise the new sequence is enforced right away
+// and the returned change is nil.
+func startValidationSetTransition(st *state.State, tr *ValidationSetTransition) (*state.Change, error) {
+	snaps, ignoreValidation, err := snapstate.InstalledSnaps(st)
+	if err != nil {
+		return nil, err
+	}
+
+	err = TryEnforcedValidationSets(st, []string{tr.transitionString()}, tr.UserID, snaps, ignoreValidation)
+	if err == nil {
+		return nil, nil
+	}
+	verr, ok := err.(*snapasserts.ValidationSetsValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	// keep other validation sets considered by the error pinned as they are
+	pinnedSeqs := map[string]int{tr.key(): tr.Sequence}
+	tracked, err := ValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	for key := range verr.Sets {
+		if vstr, ok := tracked[key]; ok && vstr.PinnedAt != 0 && key != tr.key() {
+			pinnedSeqs[key] = vstr.PinnedAt
+		}
+	}
+
+	tss, affected, err := snapstateResolveValidationSetsEnforcementError(context.TODO(), st, verr, pinnedSeqs, tr.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("Move validation set %s to sequence %d", tr.key(), tr.Sequence)
+	if len(affected) != 0 {
+		summary = fmt.Sprintf("%s for snaps %s", summary, strutil.Quoted(affected))
+	}
+	chg := st.NewChange(refreshSnapChangeKind, summary)
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	chg.Set("api-data", map[string]any{"snap-names": affected})
+	return chg, nil
+}
+
+// ensureValidationSetTransitions starts the scheduled transitions that are
+// due and forgets about those that completed.
+func ensureValidationSetTransitions(st *state.State) error {
+	transitions, err := ValidationSetTransitions(st)
+	if err != nil {
+		return err
+	}
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(transitions))
+	for key := range transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	now := timeNow()
+	var next time.Time
+	wakeUpAt := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	for _, key := range keys {
+		tr := transitions[key]
+
+		if tr.Change != "" {
+			chg := st.Change(tr.Change)
+			if chg != nil && !chg.IsReady() {
+				continue
+			}
+			if chg != nil && chg.Status() != state.DoneStatus {
+				// keep the transition to try again later
+				st.Warnf("cannot move validation set %v to sequence %d: change %s did not complete", key, tr.Sequence, chg.ID())
+				tr.Change = ""
+				tr.NextAttempt = now.Add(validationSetTransitionRetryDelay)
+				wakeUpAt(tr.NextAttempt)
+				continue
+			}
+			dropValidationSetTransition(st, transitions, tr)
+			continue
+		}
+
+		due := tr.At
+		if tr.NextAttempt.After(due) {
+			due = tr.NextAttempt
+		}
+		if now.Before(due) {
+			wakeUpAt(due)
+			continue
+		}
+		if tr.InMaintenanceWindow {
+			open, nextWindow, err := restartInMaintenanceWindow(st, now)
+			if err != nil {
+				return err
+			}
+			if !open {
+				wakeUpAt(nextWindow)
+				continue
+			}
+		}
+		// let the pre-download finish first, if it failed the refresh
+		// will download what is missing
+		if tr.PreDownloadChange != "" {
+			if chg := st.Change(tr.PreDownloadChange); chg != nil && !chg.IsReady() {
+				continue
+			}
+		}
+
+		chg, err := startValidationSetTransition(st, tr)
+		if err != nil {
+			st.Warnf("cannot move validation set %v to sequence %d: %v", key, tr.Sequence, err)
+			tr.NextAttempt = now.Add(validationSetTransitionRetryDelay)
+			wakeUpAt(tr.NextAttempt)
+			continue
+		}
+		if chg == nil {
+			logger.Noticef("moved validation set %v to sequence %d", key, tr.Sequence)
+			dropValidationSetTransition(st, transitions, tr)
+			continue
+		}
+		tr.Change = chg.ID()
+		st.EnsureBefore(0)
+	}
+
+	setValidationSetTransitions(st, transitions)
+	if !next.IsZero() {
+		st.EnsureBefore(next.Sub(now))
+	}
+	return nil
+}
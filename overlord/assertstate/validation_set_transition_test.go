@@ -0,0 +1,522 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type downloadCall struct {
+	name        string
+	downloadDir string
+	revision    snap.Revision
+}
+
+// setupTransition enforces sequence 1 of validation set bar, which requires
+// foo at revision 1, and makes sequence 2, requiring foo at the given
+// revision, available in the store.
+func (s *assertMgrSuite) setupTransition(c *C, nextFooRevision string) {
+	st := s.state
+
+	storeAs := s.setupModelAndStore(c)
+	c.Assert(s.storeSigning.Add(storeAs), IsNil)
+	c.Assert(assertstate.Add(st, s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(assertstate.Add(st, s.dev1Acct), IsNil)
+	c.Assert(assertstate.Add(st, s.dev1AcctKey), IsNil)
+
+	vsetAs1 := s.validationSetAssert(c, "bar", "1", "1", "required", "1")
+	c.Assert(assertstate.Add(st, vsetAs1), IsNil)
+	assertstate.UpdateValidationSet(st, &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Mode:      assertstate.Enforce,
+		PinnedAt:  1,
+		Current:   1,
+	})
+
+	vsetAs2 := s.validationSetAssert(c, "bar", "2", "1", "required", nextFooRevision)
+	c.Assert(s.storeSigning.Add(vsetAs2), IsNil)
+
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "foo", SnapID: "qOqKhntON3vR7kwEbVPsILm7bUViPDzz", Revision: snap.R(1)},
+		}),
+		Current: snap.R(1),
+	})
+}
+
+func (s *assertMgrSuite) mockTransitionDownload(c *C) *[]downloadCall {
+	var calls []downloadCall
+	s.AddCleanup(assertstate.MockSnapstateDownload(func(ctx context.Context, st *state.State, name string, components []string, downloadDir string, revOpts snapstate.RevisionOptions, opts snapstate.Options) (*state.TaskSet, *snap.Info, error) {
+		c.Check(components, IsNil)
+		c.Check(revOpts.ValidationSets, NotNil)
+		calls = append(calls, downloadCall{name: name, downloadDir: downloadDir, revision: revOpts.Revision})
+		t := st.NewTask("fake-download", fmt.Sprintf("download %s", name))
+		return state.NewTaskSet(t), nil, nil
+	}))
+	return &calls
+}
+
+func (s *assertMgrSuite) ensureUnlocked(c *C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.mgr.Ensure(), IsNil)
+}
+
+func (s *assertMgrSuite) TestScheduleValidationSetTransition(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	calls := s.mockTransitionDownload(c)
+
+	at := time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC)
+	chg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+		At:        at,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "pre-download-validation-set")
+	c.Check(chg.Summary(), Equals, fmt.Sprintf(`Pre-download snaps "foo" for validation set %s/bar=2`, s.dev1Acct.AccountID()))
+	c.Check(chg.Tasks(), HasLen, 1)
+
+	downloadDir := filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/validation-sets", fmt.Sprintf("%s_bar_2", s.dev1Acct.AccountID()))
+	c.Check(*calls, DeepEquals, []downloadCall{{name: "foo", downloadDir: downloadDir, revision: snap.R(3)}})
+	c.Check(downloadDir, testutil.FilePresent)
+
+	// the new sequence was fetched
+	_, err = assertstate.DB(st).Find(asserts.ValidationSetType, map[string]string{
+		"series":     "16",
+		"account-id": s.dev1Acct.AccountID(),
+		"name":       "bar",
+		"sequence":   "2",
+	})
+	c.Assert(err, IsNil)
+
+	// but tracking is unchanged
+	var tr assertstate.ValidationSetTracking
+	c.Assert(assertstate.GetValidationSet(st, s.dev1Acct.AccountID(), "bar", &tr), IsNil)
+	c.Check(tr.Sequence(), Equals, 1)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, DeepEquals, map[string]*assertstate.ValidationSetTransition{
+		fmt.Sprintf("%s/bar", s.dev1Acct.AccountID()): {
+			AccountID:         s.dev1Acct.AccountID(),
+			Name:              "bar",
+			Sequence:          2,
+			At:                at,
+			PreDownloadChange: chg.ID(),
+		},
+	})
+}
+
+func (s *assertMgrSuite) TestScheduleValidationSetTransitionNothingToDownload(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "1")
+	calls := s.mockTransitionDownload(c)
+
+	chg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+	c.Check(chg, IsNil)
+	c.Check(*calls, HasLen, 0)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, HasLen, 1)
+}
+
+func (s *assertMgrSuite) TestScheduleValidationSetTransitionErrors(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	schedule := func(name string, seq int) error {
+		_, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+			AccountID: s.dev1Acct.AccountID(),
+			Name:      name,
+			Sequence:  seq,
+		})
+		return err
+	}
+
+	c.Check(schedule("other", 2), ErrorMatches, `cannot schedule transition of validation set .*/other: validation set is not tracked`)
+	c.Check(schedule("bar", 1), ErrorMatches, `cannot schedule transition of validation set .*/bar to sequence 1: sequence 1 is already enforced`)
+
+	c.Assert(schedule("bar", 2), IsNil)
+	c.Check(schedule("bar", 2), ErrorMatches, `cannot schedule transition of validation set .*/bar: a transition is already scheduled`)
+
+	assertstate.UpdateValidationSet(st, &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "baz",
+		Mode:      assertstate.Monitor,
+		Current:   1,
+	})
+	c.Check(schedule("baz", 2), ErrorMatches, `cannot schedule transition of validation set .*/baz: validation set is not enforced`)
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionNotDue(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	s.AddCleanup(assertstate.MockTimeNow(func() time.Time { return now }))
+	s.AddCleanup(assertstate.MockSnapstateResolveValidationSetsEnforcementError(func(ctx context.Context, st *state.State, valErr *snapasserts.ValidationSetsValidationError, pinnedSeqs map[string]int, userID int) ([]*state.TaskSet, []string, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil, nil
+	}))
+
+	_, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+		At:        now.Add(24 * time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	s.ensureUnlocked(c)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions[fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())].Change, Equals, "")
+	c.Check(st.Changes(), HasLen, 1)
+}
+
+func (s *assertMgrSuite) testEnsureValidationSetTransitionRefresh(c *C, inMaintenanceWindow bool) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	s.AddCleanup(assertstate.MockTimeNow(func() time.Time { return now }))
+
+	windowOpen := false
+	s.AddCleanup(assertstate.MockRestartInMaintenanceWindow(func(st *state.State, t time.Time) (bool, time.Time, error) {
+		c.Check(inMaintenanceWindow, Equals, true)
+		return windowOpen, t.Add(time.Hour), nil
+	}))
+
+	var resolveCalls int
+	s.AddCleanup(assertstate.MockSnapstateResolveValidationSetsEnforcementError(func(ctx context.Context, st *state.State, valErr *snapasserts.ValidationSetsValidationError, pinnedSeqs map[string]int, userID int) ([]*state.TaskSet, []string, error) {
+		resolveCalls++
+		c.Check(valErr.WrongRevisionSnaps, DeepEquals, map[string]map[snap.Revision][]string{
+			"foo": {snap.R(3): []string{fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())}},
+		})
+		c.Check(pinnedSeqs, DeepEquals, map[string]int{fmt.Sprintf("%s/bar", s.dev1Acct.AccountID()): 2})
+		c.Check(userID, Equals, 0)
+		t := st.NewTask("fake-refresh", "refresh foo")
+		return []*state.TaskSet{state.NewTaskSet(t)}, []string{"foo"}, nil
+	}))
+
+	preChg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID:           s.dev1Acct.AccountID(),
+		Name:                "bar",
+		Sequence:            2,
+		At:                  now.Add(-time.Minute),
+		InMaintenanceWindow: inMaintenanceWindow,
+	})
+	c.Assert(err, IsNil)
+	key := fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())
+	downloadDir := filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/validation-sets", fmt.Sprintf("%s_bar_2", s.dev1Acct.AccountID()))
+
+	// the pre-download is still running
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 0)
+
+	for _, t := range preChg.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+
+	if inMaintenanceWindow {
+		// outside of the maintenance window nothing happens
+		s.ensureUnlocked(c)
+		c.Check(resolveCalls, Equals, 0)
+		windowOpen = true
+	}
+
+	s.ensureUnlocked(c)
+	c.Assert(resolveCalls, Equals, 1)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	chg := st.Change(transitions[key].Change)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "refresh-snap")
+	c.Check(chg.Summary(), Equals, fmt.Sprintf(`Move validation set %s to sequence 2 for snaps "foo"`, key))
+
+	// nothing is started again while the change is in progress
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 1)
+	c.Check(downloadDir, testutil.FilePresent)
+
+	for _, t := range chg.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	s.ensureUnlocked(c)
+
+	transitions, err = assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, HasLen, 0)
+	c.Check(downloadDir, testutil.FileAbsent)
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionRefresh(c *C) {
+	s.testEnsureValidationSetTransitionRefresh(c, false)
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionRefreshInMaintenanceWindow(c *C) {
+	s.testEnsureValidationSetTransitionRefresh(c, true)
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionNoRefreshNeeded(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "1")
+	s.mockTransitionDownload(c)
+
+	_, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+
+	s.ensureUnlocked(c)
+
+	var tr assertstate.ValidationSetTracking
+	c.Assert(assertstate.GetValidationSet(st, s.dev1Acct.AccountID(), "bar", &tr), IsNil)
+	c.Check(tr, DeepEquals, assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Mode:      assertstate.Enforce,
+		PinnedAt:  2,
+		Current:   2,
+	})
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, HasLen, 0)
+	c.Check(st.Changes(), HasLen, 0)
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionRetry(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	s.AddCleanup(assertstate.MockTimeNow(func() time.Time { return now }))
+
+	var resolveCalls int
+	s.AddCleanup(assertstate.MockSnapstateResolveValidationSetsEnforcementError(func(ctx context.Context, st *state.State, valErr *snapasserts.ValidationSetsValidationError, pinnedSeqs map[string]int, userID int) ([]*state.TaskSet, []string, error) {
+		resolveCalls++
+		return nil, nil, fmt.Errorf("boom")
+	}))
+
+	preChg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+	for _, t := range preChg.Tasks() {
+		t.SetStatus(state.ErrorStatus)
+	}
+
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 1)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	tr := transitions[fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())]
+	c.Assert(tr, NotNil)
+	c.Check(tr.NextAttempt.Equal(now.Add(10*time.Minute)), Equals, true)
+
+	// not retried too early
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 1)
+
+	now = now.Add(10 * time.Minute)
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 2)
+
+	warnings := st.AllWarnings()
+	c.Assert(warnings, HasLen, 1)
+	c.Check(warnings[0].String(), Equals, fmt.Sprintf("cannot move validation set %s/bar to sequence 2: boom", s.dev1Acct.AccountID()))
+}
+
+func (s *assertMgrSuite) TestEnsureValidationSetTransitionChangeFailed(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	now := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	s.AddCleanup(assertstate.MockTimeNow(func() time.Time { return now }))
+
+	var resolveCalls int
+	s.AddCleanup(assertstate.MockSnapstateResolveValidationSetsEnforcementError(func(ctx context.Context, st *state.State, valErr *snapasserts.ValidationSetsValidationError, pinnedSeqs map[string]int, userID int) ([]*state.TaskSet, []string, error) {
+		resolveCalls++
+		t := st.NewTask("fake-refresh", "refresh foo")
+		return []*state.TaskSet{state.NewTaskSet(t)}, []string{"foo"}, nil
+	}))
+
+	preChg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+	for _, t := range preChg.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	key := fmt.Sprintf("%s/bar", s.dev1Acct.AccountID())
+
+	s.ensureUnlocked(c)
+	c.Assert(resolveCalls, Equals, 1)
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	chg := st.Change(transitions[key].Change)
+	c.Assert(chg, NotNil)
+
+	for _, t := range chg.Tasks() {
+		t.SetStatus(state.ErrorStatus)
+	}
+	s.ensureUnlocked(c)
+
+	// the transition is kept to be retried, with a warning
+	transitions, err = assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	tr := transitions[key]
+	c.Assert(tr, NotNil)
+	c.Check(tr.Change, Equals, "")
+	c.Check(tr.NextAttempt.Equal(now.Add(10*time.Minute)), Equals, true)
+	warnings := st.AllWarnings()
+	c.Assert(warnings, HasLen, 1)
+	c.Check(warnings[0].String(), Equals, fmt.Sprintf("cannot move validation set %s to sequence 2: change %s did not complete", key, chg.ID()))
+
+	// not retried too early
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 1)
+
+	now = now.Add(10 * time.Minute)
+	s.ensureUnlocked(c)
+	c.Check(resolveCalls, Equals, 2)
+	transitions, err = assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions[key].Change, Not(Equals), "")
+	c.Check(transitions[key].Change, Not(Equals), chg.ID())
+}
+
+func (s *assertMgrSuite) TestCancelValidationSetTransition(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	err := assertstate.CancelValidationSetTransition(st, s.dev1Acct.AccountID(), "bar")
+	c.Check(err, testutil.ErrorIs, state.ErrNoState)
+
+	preChg, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+	downloadDir := filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/validation-sets", fmt.Sprintf("%s_bar_2", s.dev1Acct.AccountID()))
+	c.Assert(os.WriteFile(filepath.Join(downloadDir, "foo_3.snap"), nil, 0644), IsNil)
+
+	c.Assert(assertstate.CancelValidationSetTransition(st, s.dev1Acct.AccountID(), "bar"), IsNil)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, HasLen, 0)
+	c.Check(preChg.Status(), Equals, state.HoldStatus)
+	c.Check(downloadDir, testutil.FileAbsent)
+}
+
+func (s *assertMgrSuite) TestForgetValidationSetCancelsTransition(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setupTransition(c, "3")
+	s.mockTransitionDownload(c)
+
+	_, err := assertstate.ScheduleValidationSetTransition(st, &assertstate.ValidationSetTransition{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(assertstate.ForgetValidationSet(st, s.dev1Acct.AccountID(), "bar", assertstate.ForgetValidationSetOpts{}), IsNil)
+
+	transitions, err := assertstate.ValidationSetTransitions(st)
+	c.Assert(err, IsNil)
+	c.Check(transitions, HasLen, 0)
+}
@@ -20,9 +20,12 @@
 package overlord
 
 import (
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
@@ -37,3 +40,92 @@ func (osb *overlordStateBackend) Checkpoint(data []byte) error {
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
+
+// journalCompactionMinSize is the size the journal needs to reach before it
+// is compacted, unless the base state file is larger.
+var journalCompactionMinSize int64 = 1024 * 1024
+
+// journalStateBackend persists the state as the base state file, which is
+// only rewritten when compacting, and a journal next to it with the deltas
+// applied to it since.
+type journalStateBackend struct {
+	path         string
+	journalPath  string
+	ensureBefore func(d time.Duration)
+
+	journal *os.File
+	// journalEnd is the size of the journal file, deltaSize the size of
+	// its deltas.
+	journalEnd int64
+	deltaSize  int64
+	baseSize   int64
+}
+
+func newJournalStateBackend(path string, ensureBefore func(d time.Duration)) *journalStateBackend {
+	return &journalStateBackend{
+		path:         path,
+		journalPath:  state.JournalPath(path),
+		ensureBefore: ensureBefore,
+	}
+}
+
+func (jsb *journalStateBackend) Checkpoint(data []byte) error {
+	if err := osutil.AtomicWriteFile(jsb.path, data, 0600, 0); err != nil {
+		return err
+	}
+	jsb.baseSize = int64(len(data))
+
+	// start a new journal on top of the new base, any previous one does
+	// not apply to it anymore
+	if jsb.journal != nil {
+		jsb.journal.Close()
+		jsb.journal = nil
+	}
+	header := state.JournalHeader(data)
+	if err := osutil.AtomicWriteFile(jsb.journalPath, header, 0600, 0); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(jsb.journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	jsb.journal = f
+	jsb.journalEnd = int64(len(header))
+	jsb.deltaSize = 0
+	return nil
+}
+
+func (jsb *journalStateBackend) AppendDelta(delta []byte) error {
+	if jsb.journal == nil {
+		return fmt.Errorf("internal error: cannot append to the state journal before checkpointing")
+	}
+	n, err := jsb.journal.Write(append(delta, '\n'))
+	if err == nil {
+		err = jsb.journal.Sync()
+	}
+	if err != nil {
+		// drop what was written of the delta, so that it can be retried
+		if n > 0 {
+			jsb.journal.Truncate(jsb.journalEnd)
+		}
+		return err
+	}
+	jsb.journalEnd += int64(n)
+	jsb.deltaSize += int64(n)
+	return nil
+}
+
+func (jsb *journalStateBackend) NeedsCompaction() bool {
+	if jsb.journal == nil {
+		return true
+	}
+	threshold := jsb.baseSize
+	if threshold < journalCompactionMinSize {
+		threshold = journalCompactionMinSize
+	}
+	return jsb.deltaSize >= threshold
+}
+
+func (jsb *journalStateBackend) EnsureBefore(d time.Duration) {
+	jsb.ensureBefore(d)
+}
@@ -0,0 +1,429 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package changearchive keeps the history of changes pruned from the state,
+// with their tasks, logs and errors, in a bounded on-disk archive.
+package changearchive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	// maxArchiveSize is the size above which the archive is rotated.
+	maxArchiveSize int64 = 8 * 1024 * 1024
+	// maxRotatedArchives is the number of rotated archives which are kept
+	// in addition to the current one.
+	maxRotatedArchives = 3
+
+	timeNow = time.Now
+)
+
+// Progress is the progress of an archived task when it was pruned.
+type Progress struct {
+	Label string `json:"label"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// Task is an archived task.
+type Task struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Summary  string   `json:"summary"`
+	Status   string   `json:"status"`
+	Log      []string `json:"log,omitempty"`
+	Progress Progress `json:"progress"`
+
+	SpawnTime time.Time `json:"spawn-time,omitzero"`
+	ReadyTime time.Time `json:"ready-time,omitzero"`
+
+	DoingTime   time.Duration `json:"doing-time,omitempty"`
+	UndoingTime time.Duration `json:"undoing-time,omitempty"`
+}
+
+// Change is an archived change.
+type Change struct {
+	ID      string  `json:"id"`
+	Kind    string  `json:"kind"`
+	Summary string  `json:"summary"`
+	Status  string  `json:"status"`
+	Err     string  `json:"err,omitempty"`
+	Tasks   []*Task `json:"tasks,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time,omitzero"`
+	ReadyTime time.Time `json:"ready-time,omitzero"`
+	// ArchiveTime is when the change was pruned from the state and
+	// archived.
+	ArchiveTime time.Time `json:"archive-time"`
+
+	// SnapNames are the snaps affected by the change, if known.
+	SnapNames []string `json:"snap-names,omitempty"`
+	// Data is the data of the change exposed through the API.
+	Data map[string]*json.RawMessage `json:"data,omitempty"`
+}
+
+// FromChange returns the archived form of the given change.
+//
+// The state must be locked by the caller.
+func FromChange(chg *state.Change) *Change {
+	archived := &Change{
+		ID:        chg.ID(),
+		Kind:      chg.Kind(),
+		Summary:   chg.Summary(),
+		Status:    chg.Status().String(),
+		SpawnTime: chg.SpawnTime(),
+		ReadyTime: chg.ReadyTime(),
+	}
+	if err := chg.Err(); err != nil {
+		archived.Err = err.Error()
+	}
+	// errors are ignored, the entries are optional
+	chg.Get("snap-names", &archived.SnapNames)
+	chg.Get("api-data", &archived.Data)
+
+	for _, t := range chg.Tasks() {
+		label, done, total := t.Progress()
+		archived.Tasks = append(archived.Tasks, &Task{
+			ID:      t.ID(),
+			Kind:    t.Kind(),
+			Summary: t.Summary(),
+			Status:  t.Status().String(),
+			Log:     t.Log(),
+			Progress: Progress{
+				Label: label,
+				Done:  done,
+				Total: total,
+			},
+			SpawnTime:   t.SpawnTime(),
+			ReadyTime:   t.ReadyTime(),
+			DoingTime:   t.DoingTime(),
+			UndoingTime: t.UndoingTime(),
+		})
+	}
+	return archived
+}
+
+// Archive is an append-only archive of changes, which is rotated once it
+// grows beyond a fixed size, keeping a fixed number of rotated archives.
+type Archive struct {
+	mutex sync.Mutex
+	path  string
+
+	// index maps the IDs of the archived changes to where they are in the
+	// archive, it is built on the first lookup of a change.
+	index map[string]indexEntry
+	// generation is the number of times the current archive was rotated
+	// since the index was built.
+	generation int
+}
+
+// indexEntry is the location of an archived change.
+type indexEntry struct {
+	// generation is the generation of the archive holding the change.
+	generation int
+	offset     int64
+}
+
+// New returns the archive kept in the given directory, which is created
+// when the first change is added.
+func New(dir string) *Archive {
+	return &Archive{
+		path: filepath.Join(dir, "changes.log"),
+	}
+}
+
+func (a *Archive) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", a.path, n)
+}
+
+// rotate moves the current archive to the first rotated archive, shifting
+// the existing rotated archives and discarding the oldest.
+//
+// The caller must ensure that the archive lock is held.
+func (a *Archive) rotate() error {
+	for n := maxRotatedArchives - 1; n >= 1; n-- {
+		err := os.Rename(a.rotatedPath(n), a.rotatedPath(n+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(a.path, a.rotatedPath(1))
+}
+
+// Add appends the given changes to the archive. Changes without an archive
+// time get the current time.
+func (a *Archive) Add(chgs ...*Change) error {
+	if len(chgs) == 0 {
+		return nil
+	}
+	var data []byte
+	// starts holds where each change starts in data
+	starts := make([]int64, 0, len(chgs))
+	now := timeNow()
+	for _, chg := range chgs {
+		if chg.ArchiveTime.IsZero() {
+			chg.ArchiveTime = now
+		}
+		line, err := json.Marshal(chg)
+		if err != nil {
+			return fmt.Errorf("cannot marshal archived change: %w", err)
+		}
+		starts = append(starts, int64(len(data)))
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return fmt.Errorf("cannot create changes archive directory: %w", err)
+	}
+	if fi, err := os.Stat(a.path); err == nil && fi.Size() > 0 && fi.Size()+int64(len(data)) > maxArchiveSize {
+		if err := a.rotate(); err != nil {
+			// keep archiving, if only in an oversized archive
+			logger.Noticef("cannot rotate changes archive: %v", err)
+			// the archives might have been partially shifted
+			a.index = nil
+		} else {
+			a.rotated()
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open changes archive: %w", err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("cannot archive changes: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		// what was written, if anything, cannot be read back
+		a.index = nil
+		return fmt.Errorf("cannot archive changes: %w", err)
+	}
+	if a.index != nil {
+		for i, chg := range chgs {
+			a.index[chg.ID] = indexEntry{generation: a.generation, offset: offset + starts[i]}
+		}
+	}
+	return nil
+}
+
+// rotated updates the index after the archive was rotated, dropping the
+// changes which were in the discarded archive.
+//
+// The caller must ensure that the archive lock is held.
+func (a *Archive) rotated() {
+	if a.index == nil {
+		return
+	}
+	a.generation++
+	for id, entry := range a.index {
+		if a.generation-entry.generation > maxRotatedArchives {
+			delete(a.index, id)
+		}
+	}
+}
+
+// archivePath returns the path of the archive of the given generation.
+//
+// The caller must ensure that the archive lock is held.
+func (a *Archive) archivePath(generation int) string {
+	if n := a.generation - generation; n > 0 {
+		return a.rotatedPath(n)
+	}
+	return a.path
+}
+
+// buildIndex indexes the changes in the archive, including those in rotated
+// archives.
+//
+// The caller must ensure that the archive lock is held.
+func (a *Archive) buildIndex() error {
+	index := make(map[string]indexEntry)
+	a.generation = 0
+	// later archives override earlier ones, so that the most recently
+	// archived change wins, should an ID ever be reused
+	for n := maxRotatedArchives; n >= 0; n-- {
+		generation := -n
+		err := scanChanges(a.archivePath(generation), func(id string, offset int64) {
+			index[id] = indexEntry{generation: generation, offset: offset}
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cannot read changes archive: %w", err)
+		}
+	}
+	a.index = index
+	return nil
+}
+
+// Changes returns the archived changes, including those in rotated archives,
+// from the oldest archived to the most recently archived.
+//
+// Lines which cannot be decoded, for example since snapd was interrupted while
+// writing them, are skipped.
+func (a *Archive) Changes() ([]*Change, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	paths := make([]string, 0, maxRotatedArchives+1)
+	for n := maxRotatedArchives; n >= 1; n-- {
+		paths = append(paths, a.rotatedPath(n))
+	}
+	paths = append(paths, a.path)
+
+	chgs := make([]*Change, 0)
+	for _, path := range paths {
+		err := readChanges(path, func(chg *Change) {
+			chgs = append(chgs, chg)
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("cannot read changes archive: %w", err)
+		}
+	}
+	return chgs, nil
+}
+
+// Change returns the archived change with the given ID, or nil if there is
+// none.
+func (a *Archive) Change(id string) (*Change, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	rebuilt := false
+	if a.index == nil {
+		if err := a.buildIndex(); err != nil {
+			return nil, err
+		}
+		rebuilt = true
+	}
+	for {
+		entry, ok := a.index[id]
+		if !ok {
+			return nil, nil
+		}
+		chg, err := readChangeAt(a.archivePath(entry.generation), entry.offset)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("cannot read changes archive: %w", err)
+		}
+		if chg != nil && chg.ID == id {
+			return chg, nil
+		}
+		if rebuilt {
+			return nil, nil
+		}
+		// the archive was changed behind our back
+		if err := a.buildIndex(); err != nil {
+			return nil, err
+		}
+		rebuilt = true
+	}
+}
+
+// readChangeAt returns the change archived at the given offset of the
+// archive with the given path, or nil if it cannot be decoded.
+func readChangeAt(path string, offset int64) (*Change, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var chg Change
+	if json.Unmarshal(line, &chg) != nil {
+		return nil, nil
+	}
+	return &chg, nil
+}
+
+// scanChanges calls f with the ID and offset of each change in the archive
+// with the given path, without decoding the changes.
+func scanChanges(path string, f func(id string, offset int64)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var chg struct {
+				ID string `json:"id"`
+			}
+			if json.Unmarshal(line, &chg) == nil {
+				f(chg.ID, offset)
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readChanges(path string, f func(chg *Change)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// archived changes can have many tasks with long logs, so lines are not
+	// limited in size as with a bufio.Scanner
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var chg Change
+			if json.Unmarshal(line, &chg) == nil {
+				f(&chg)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
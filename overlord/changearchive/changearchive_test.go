@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changearchive_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/changearchive"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type archiveSuite struct {
+	testutil.BaseTest
+	dir string
+}
+
+var _ = Suite(&archiveSuite{})
+
+func (s *archiveSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.dir = filepath.Join(c.MkDir(), "changes-archive")
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(changearchive.MockTimeNow(func() time.Time { return now }))
+}
+
+func (s *archiveSuite) TestFromChange(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("refresh-snap", "Refresh snap \"foo\"")
+	chg.Set("snap-names", []string{"foo"})
+	chg.Set("api-data", map[string]any{"snap-names": []string{"foo"}})
+	t1 := st.NewTask("download-snap", "Download snap")
+	t1.SetProgress("foo", 2, 4)
+	t1.SetStatus(state.DoneStatus)
+	t2 := st.NewTask("link-snap", "Link snap")
+	t2.Errorf("boom")
+	t2.SetStatus(state.ErrorStatus)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+
+	archived := changearchive.FromChange(chg)
+	c.Check(archived.ID, Equals, chg.ID())
+	c.Check(archived.Kind, Equals, "refresh-snap")
+	c.Check(archived.Summary, Equals, `Refresh snap "foo"`)
+	c.Check(archived.Status, Equals, "Error")
+	c.Check(archived.Err, Matches, `cannot perform the following tasks:\n- Link snap \(boom\)`)
+	c.Check(archived.SnapNames, DeepEquals, []string{"foo"})
+	c.Check(archived.Data, HasLen, 1)
+	c.Check(archived.SpawnTime, Equals, chg.SpawnTime())
+	c.Assert(archived.Tasks, HasLen, 2)
+	c.Check(archived.Tasks[0].Kind, Equals, "download-snap")
+	c.Check(archived.Tasks[0].Status, Equals, "Done")
+	c.Check(archived.Tasks[0].Progress, Equals, changearchive.Progress{Label: "foo", Done: 2, Total: 4})
+	c.Check(archived.Tasks[1].Status, Equals, "Error")
+	c.Assert(archived.Tasks[1].Log, HasLen, 1)
+	c.Check(archived.Tasks[1].Log[0], Matches, `.* ERROR boom`)
+}
+
+func (s *archiveSuite) TestAddAndChanges(c *C) {
+	a := changearchive.New(s.dir)
+
+	chgs, err := a.Changes()
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 0)
+
+	c.Assert(a.Add(&changearchive.Change{ID: "1", Kind: "install-snap"}), IsNil)
+	c.Assert(a.Add(&changearchive.Change{ID: "2", Kind: "remove-snap"}, &changearchive.Change{ID: "3", Kind: "refresh-snap"}), IsNil)
+	c.Assert(a.Add(), IsNil)
+
+	chgs, err = a.Changes()
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 3)
+	for i, chg := range chgs {
+		c.Check(chg.ID, Equals, fmt.Sprint(i+1))
+		c.Check(chg.ArchiveTime.Equal(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+	}
+
+	chg, err := a.Change("2")
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind, Equals, "remove-snap")
+	chg, err = a.Change("42")
+	c.Assert(err, IsNil)
+	c.Check(chg, IsNil)
+
+	c.Check(filepath.Join(s.dir, "changes.log"), testutil.FileContains, `"id":"3"`)
+	fi, err := os.Stat(filepath.Join(s.dir, "changes.log"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0o600))
+}
+
+func (s *archiveSuite) TestRotate(c *C) {
+	s.AddCleanup(changearchive.MockMaxArchiveSize(200))
+	s.AddCleanup(changearchive.MockMaxRotatedArchives(2))
+	a := changearchive.New(s.dir)
+
+	for i := 1; i <= 8; i++ {
+		c.Assert(a.Add(&changearchive.Change{ID: fmt.Sprint(i), Kind: "install-snap"}), IsNil)
+	}
+
+	// each change takes about 100 bytes, so each archive holds two and only
+	// the changes in the current and two rotated archives are kept
+	c.Check(filepath.Join(s.dir, "changes.log.3"), testutil.FileAbsent)
+	chgs, err := a.Changes()
+	c.Assert(err, IsNil)
+	var ids []string
+	for _, chg := range chgs {
+		ids = append(ids, chg.ID)
+	}
+	c.Check(ids, DeepEquals, []string{"3", "4", "5", "6", "7", "8"})
+}
+
+func (s *archiveSuite) TestChangeIndexAcrossRotations(c *C) {
+	s.AddCleanup(changearchive.MockMaxArchiveSize(200))
+	s.AddCleanup(changearchive.MockMaxRotatedArchives(2))
+	a := changearchive.New(s.dir)
+
+	for i := 1; i <= 4; i++ {
+		c.Assert(a.Add(&changearchive.Change{ID: fmt.Sprint(i), Kind: "install-snap"}), IsNil)
+	}
+	// the index is built from the archives on the first lookup
+	chg, err := a.Change("1")
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(chg.ID, Equals, "1")
+
+	// and kept up to date with the changes added and rotated away since
+	for i := 5; i <= 8; i++ {
+		c.Assert(a.Add(&changearchive.Change{ID: fmt.Sprint(i), Kind: "remove-snap"}), IsNil)
+	}
+	for _, id := range []string{"1", "2"} {
+		chg, err := a.Change(id)
+		c.Assert(err, IsNil)
+		c.Check(chg, IsNil, Commentf("change %s", id))
+	}
+	for i := 3; i <= 8; i++ {
+		chg, err := a.Change(fmt.Sprint(i))
+		c.Assert(err, IsNil)
+		c.Assert(chg, NotNil, Commentf("change %d", i))
+		c.Check(chg.ID, Equals, fmt.Sprint(i))
+	}
+
+	// the same as a fresh index
+	a = changearchive.New(s.dir)
+	for i := 3; i <= 8; i++ {
+		chg, err := a.Change(fmt.Sprint(i))
+		c.Assert(err, IsNil)
+		c.Assert(chg, NotNil, Commentf("change %d", i))
+		c.Check(chg.ID, Equals, fmt.Sprint(i))
+	}
+}
+
+func (s *archiveSuite) TestChangeIndexOutOfDate(c *C) {
+	a := changearchive.New(s.dir)
+	c.Assert(a.Add(&changearchive.Change{ID: "1"}, &changearchive.Change{ID: "2"}), IsNil)
+	chg, err := a.Change("2")
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+
+	// the archive is rewritten behind the archive's back
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "changes.log"), []byte(`{"id":"2","kind":"other"}`+"\n"), 0o600), IsNil)
+	chg, err = a.Change("2")
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind, Equals, "other")
+	chg, err = a.Change("1")
+	c.Assert(err, IsNil)
+	c.Check(chg, IsNil)
+}
+
+func (s *archiveSuite) TestChangesSkipsCorruptLines(c *C) {
+	a := changearchive.New(s.dir)
+	c.Assert(a.Add(&changearchive.Change{ID: "1"}), IsNil)
+
+	f, err := os.OpenFile(filepath.Join(s.dir, "changes.log"), os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString("garbage\n{\"id\":\"2\",")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	chgs, err := a.Changes()
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].ID, Equals, "1")
+}
+
+func (s *archiveSuite) TestAddError(c *C) {
+	// the archive directory cannot be created
+	parent := filepath.Dir(s.dir)
+	c.Assert(os.MkdirAll(parent, 0o755), IsNil)
+	c.Assert(os.WriteFile(s.dir, nil, 0o644), IsNil)
+
+	a := changearchive.New(s.dir)
+	err := a.Add(&changearchive.Change{ID: "1"})
+	c.Check(err, ErrorMatches, "cannot create changes archive directory: .*")
+}
@@ -99,7 +99,16 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 	}
 	schema := confdbAssert.Schema().DatabagSchema
 
-	return tx.Commit(st, schema)
+	if err := tx.Commit(st, schema); err != nil {
+		return err
+	}
+
+	var origin commitOrigin
+	if err := t.Get("confdb-origin", &origin); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	return recordCommittedDatabag(st, tx.ConfdbAccount, tx.ConfdbName, tx.previous, tx.pristine, origin)
 }
 
 func (m *ConfdbManager) clearOngoingTransaction(t *state.Task, _ *tomb.Tomb) error {
@@ -19,6 +19,7 @@
 package confdbstate
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -182,10 +183,13 @@ var writeDatabag = func(st *state.State, databag confdb.JSONDatabag, account, db
 	err := st.Get("confdb-databags", &databags)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
-	} else if errors.Is(err, &state.NoStateError{}) || databags[account] == nil || databags[account][dbSchemaName] == nil {
-		databags = map[string]map[string]confdb.JSONDatabag{
-			account: {dbSchemaName: confdb.NewJSONDatabag()},
-		}
+	}
+
+	if databags == nil {
+		databags = make(map[string]map[string]confdb.JSONDatabag, 1)
+	}
+	if databags[account] == nil {
+		databags[account] = make(map[string]confdb.JSONDatabag, 1)
 	}
 
 	databags[account][dbSchemaName] = databag
@@ -193,6 +197,99 @@ var writeDatabag = func(st *state.State, databag confdb.JSONDatabag, account, db
 	return nil
 }
 
+// InvalidDatabagError is returned when data to be imported into a confdb
+// databag cannot be decoded or doesn't match the confdb schema.
+type InvalidDatabagError struct {
+	account    string
+	schemaName string
+	err        error
+}
+
+func (e *InvalidDatabagError) Is(err error) bool {
+	_, ok := err.(*InvalidDatabagError)
+	return ok
+}
+
+func (e *InvalidDatabagError) Error() string {
+	return fmt.Sprintf(i18n.G("cannot import confdb %s/%s: %v"), e.account, e.schemaName, e.err)
+}
+
+func (e *InvalidDatabagError) Unwrap() error {
+	return e.err
+}
+
+// ExportDatabag returns a copy of the databag of the given confdb schema.
+// Returns asserts.NotFoundError if the confdb-schema assertion isn't known.
+// The state must be locked by the caller.
+func ExportDatabag(st *state.State, account, schemaName string) (confdb.JSONDatabag, error) {
+	if _, err := assertstateConfdbSchema(st, account, schemaName); err != nil {
+		return nil, err
+	}
+
+	databag, err := readDatabag(st, account, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	return databag.Copy(), nil
+}
+
+// ImportDatabag replaces the databag of the given confdb schema with the JSON
+// object in data, after validating it against the confdb schema. No hooks are
+// run, so this is meant for seeding and restoring backups rather than for
+// regular writes. The new databag is recorded in the history as coming from
+// callingSnap, if any. Returns asserts.NotFoundError if the confdb-schema
+// assertion isn't known and InvalidDatabagError if the data isn't valid.
+// The state must be locked by the caller.
+func ImportDatabag(st *state.State, account, schemaName string, data []byte, callingSnap string) error {
+	confdbSchemaAs, err := assertstateConfdbSchema(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+
+	txs, _, err := getOngoingTxs(st, account, schemaName)
+	if err != nil {
+		return fmt.Errorf("cannot import confdb %s/%s: cannot check ongoing transactions: %v", account, schemaName, err)
+	}
+
+	if txs != nil && !txs.CanStartWriteTx() {
+		return fmt.Errorf("cannot import confdb %s/%s: ongoing transaction", account, schemaName)
+	}
+
+	var databag confdb.JSONDatabag
+	if err := json.Unmarshal(data, &databag); err != nil {
+		return &InvalidDatabagError{account: account, schemaName: schemaName, err: fmt.Errorf("cannot decode databag: %v", err)}
+	}
+	if databag == nil {
+		return &InvalidDatabagError{account: account, schemaName: schemaName, err: errors.New("databag must be a JSON object")}
+	}
+
+	// re-encode the data so the stored values are compact
+	data, err = databag.Data()
+	if err != nil {
+		return err
+	}
+
+	if err := confdbSchemaAs.Schema().DatabagSchema.Validate(data); err != nil {
+		return &InvalidDatabagError{account: account, schemaName: schemaName, err: err}
+	}
+
+	databag = confdb.NewJSONDatabag()
+	if err := json.Unmarshal(data, &databag); err != nil {
+		return err
+	}
+
+	previous, err := readDatabag(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+
+	if err := writeDatabag(st, databag.Copy(), account, schemaName); err != nil {
+		return err
+	}
+
+	return recordCommittedDatabag(st, account, schemaName, previous, databag, commitOrigin{Snap: callingSnap})
+}
+
 type CommitTxFunc func() (changeID string, waitChan <-chan struct{}, err error)
 
 // GetTransactionToSet gets a transaction to change the confdb through the view.
@@ -201,8 +298,9 @@ type CommitTxFunc func() (changeID string, waitChan <-chan struct{}, err error)
 // modifications are made to commit them. It will return a changeID and a channel,
 // allowing the caller to block until commit. If a transaction was already ongoing,
 // CommitTxFunc simply returns that without blocking (changes to it will be
-// saved on ctx.Done()).
-func GetTransactionToSet(ctx *hookstate.Context, st *state.State, view *confdb.View) (*Transaction, CommitTxFunc, error) {
+// saved on ctx.Done()). The userID is recorded in the confdb's history as the
+// user that requested the change.
+func GetTransactionToSet(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*Transaction, CommitTxFunc, error) {
 	account, schemaName := view.Schema().Account, view.Schema().Name
 
 	// check if we're already running in the context of a committing transaction
@@ -261,7 +359,7 @@ func GetTransactionToSet(ctx *hookstate.Context, st *state.State, view *confdb.V
 			callingSnap = ctx.InstanceName()
 		}
 
-		ts, err := createChangeConfdbTasks(st, tx, view, callingSnap)
+		ts, err := createChangeConfdbTasks(st, tx, view, callingSnap, userID)
 		if err != nil {
 			return "", nil, err
 		}
@@ -311,7 +409,7 @@ const (
 	clearTxEdge = state.TaskSetEdge("clear-tx-edge")
 )
 
-func createChangeConfdbTasks(st *state.State, tx *Transaction, view *confdb.View, callingSnap string) (*state.TaskSet, error) {
+func createChangeConfdbTasks(st *state.State, tx *Transaction, view *confdb.View, callingSnap string, userID int) (*state.TaskSet, error) {
 	custodians, custodianPlugs, err := getCustodianPlugsForView(st, view)
 	if err != nil {
 		return nil, err
@@ -392,6 +490,7 @@ func createChangeConfdbTasks(st *state.State, tx *Transaction, view *confdb.View
 	// commit after custodians save ephemeral data
 	commitTask := st.NewTask("commit-confdb-tx", fmt.Sprintf("Commit changes to confdb (%s)", view.ID()))
 	commitTask.Set("confdb-transaction", tx)
+	commitTask.Set("confdb-origin", commitOrigin{View: view.ID(), Snap: callingSnap, UserID: &userID})
 	// link all previous tasks to the commit task that carries the transaction
 	for _, t := range ts.Tasks() {
 		t.Set("tx-task", commitTask.ID())
@@ -557,7 +557,7 @@ func (s *confdbTestSuite) TestConfdbTasksUserSetWithCustodianInstalled(c *C) {
 	chg := s.state.NewChange("modify-confdb", "")
 
 	// a user (not a snap) changes a confdb
-	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "")
+	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "", 0)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -614,7 +614,7 @@ func (s *confdbTestSuite) TestConfdbTasksCustodianSnapSet(c *C) {
 	chg := s.state.NewChange("set-confdb", "")
 
 	// a user (not a snap) changes a confdb
-	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "custodian-snap")
+	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "custodian-snap", 0)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -657,7 +657,7 @@ func (s *confdbTestSuite) TestConfdbTasksObserverSnapSetWithCustodianInstalled(c
 	chg := s.state.NewChange("modify-confdb", "")
 
 	// a non-custodian snap modifies a confdb
-	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "test-snap-1")
+	ts, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "test-snap-1", 0)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -727,7 +727,7 @@ func (s *confdbTestSuite) testConfdbTasksNoCustodian(c *C) {
 	view := s.dbSchema.View("setup-wifi")
 
 	// a non-custodian snap modifies a confdb
-	_, err = confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "test-snap-1")
+	_, err = confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "test-snap-1", 0)
 	c.Assert(err, ErrorMatches, fmt.Sprintf("cannot commit changes to confdb made through view %s/network/%s: no custodian snap installed", s.devAccID, view.Name))
 }
 
@@ -968,7 +968,7 @@ func (s *confdbTestSuite) TestGetTransactionFromUserCreatesNewChange(c *C) {
 
 	view := s.dbSchema.View("setup-wifi")
 
-	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(nil, s.state, view)
+	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(nil, s.state, view, 1000)
 	c.Assert(err, IsNil)
 	c.Assert(tx, NotNil)
 	c.Assert(commitTxFunc, NotNil)
@@ -995,6 +995,15 @@ func (s *confdbTestSuite) TestGetTransactionFromUserCreatesNewChange(c *C) {
 	c.Assert(changeID, Equals, chg.ID())
 
 	s.checkSetConfdbChange(c, chg, hooks)
+
+	// the requesting user is recorded in the history
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 1)
+	c.Check(revs[0].View, Equals, view.ID())
+	c.Check(revs[0].Snap, Equals, "")
+	c.Assert(revs[0].UserID, NotNil)
+	c.Check(*revs[0].UserID, Equals, 1000)
 }
 
 func (s *confdbTestSuite) TestGetTransactionFromSnapCreatesNewChange(c *C) {
@@ -1263,7 +1272,7 @@ func (s *confdbTestSuite) TestGetDifferentTransactionThanOngoing(c *C) {
 	c.Assert(err, IsNil)
 
 	ctx.Lock()
-	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(ctx, s.state, confdb.View("foo"))
+	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(ctx, s.state, confdb.View("foo"), 0)
 	ctx.Unlock()
 	c.Assert(err, ErrorMatches, fmt.Sprintf(`cannot access confdb through view foo/bar/foo: ongoing transaction for %s/network`, s.devAccID))
 	c.Assert(tx, IsNil)
@@ -1898,7 +1907,7 @@ func (s *confdbTestSuite) TestConcurrentAccessWithOngoingWrite(c *C) {
 	c.Assert(err, ErrorMatches, fmt.Sprintf("cannot access confdb view %s/network/setup-wifi: ongoing write transaction", s.devAccID))
 
 	// writing (used both from snap or API)
-	_, _, err = confdbstate.GetTransactionToSet(nil, s.state, view)
+	_, _, err = confdbstate.GetTransactionToSet(nil, s.state, view, 0)
 	c.Assert(err, ErrorMatches, fmt.Sprintf("cannot write confdb through view %s/network/setup-wifi: ongoing transaction", s.devAccID))
 }
 
@@ -1921,7 +1930,7 @@ func (s *confdbTestSuite) TestConcurrentAccessWithOngoingRead(c *C) {
 
 	view := s.dbSchema.View("setup-wifi")
 	// writing (used both from snap or API) conflicts
-	_, _, err = confdbstate.GetTransactionToSet(ctx, s.state, view)
+	_, _, err = confdbstate.GetTransactionToSet(ctx, s.state, view, 0)
 	c.Assert(err, ErrorMatches, fmt.Sprintf("cannot write confdb through view %s/network/setup-wifi: ongoing transaction", s.devAccID))
 
 	// we can read from the API and the snap concurrently with other reads
@@ -1940,7 +1949,7 @@ func (s *confdbTestSuite) TestWriteAffectingEphemeralMustDefineSaveViewHook(c *C
 	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": hooks}, nil)
 
 	view := s.dbSchema.View("setup-wifi")
-	tx, commitTx, err := confdbstate.GetTransactionToSet(nil, s.state, view)
+	tx, commitTx, err := confdbstate.GetTransactionToSet(nil, s.state, view, 0)
 	c.Assert(err, IsNil)
 	err = tx.Set(parsePath(c, "wifi.eph"), "foo")
 	c.Assert(err, IsNil)
@@ -2014,7 +2023,7 @@ func (s *confdbTestSuite) TestBadPathHookChecks(c *C) {
 	_, err = confdbstate.LoadConfdbAsync(s.state, view, []string{"foo"}, nil, 0)
 	c.Assert(err, ErrorMatches, fmt.Sprintf(`cannot get "foo" through %s/network/setup-wifi: no matching rule`, s.devAccID))
 
-	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(nil, s.state, view)
+	tx, commitTxFunc, err := confdbstate.GetTransactionToSet(nil, s.state, view, 0)
 	c.Assert(err, IsNil)
 	// this shouldn't happen unless there's a mismatch between views and schemas but check we're robust
 	c.Assert(tx.Set(parsePath(c, "foo"), "bar"), IsNil)
@@ -2086,3 +2095,134 @@ func (s *confdbTestSuite) TestGetTransactionWithSecretVisibility(c *C) {
 	c.Assert(log, HasLen, 1)
 	c.Assert(log[0], Matches, fmt.Sprintf(`.*cannot get "private" through %s/network/setup-wifi: unauthorized access`, s.devAccID))
 }
+
+func (s *confdbTestSuite) TestWriteDatabagKeepsOtherSchemas(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "foo"), "bar"), IsNil)
+	c.Assert(confdbstate.WriteDatabag(s.state, bag, s.devAccID, "network"), IsNil)
+	c.Assert(confdbstate.WriteDatabag(s.state, bag, s.devAccID, "other"), IsNil)
+	c.Assert(confdbstate.WriteDatabag(s.state, bag, "other-account", "network"), IsNil)
+
+	var databags map[string]map[string]confdb.JSONDatabag
+	c.Assert(s.state.Get("confdb-databags", &databags), IsNil)
+	c.Check(databags, HasLen, 2)
+	c.Check(databags[s.devAccID], HasLen, 2)
+	c.Check(databags["other-account"], HasLen, 1)
+}
+
+func (s *confdbTestSuite) TestExportDatabag(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "wifi.ssid"), "foo"), IsNil)
+	c.Assert(bag.Set(parsePath(c, "private.key"), "secret"), IsNil)
+	s.state.Set("confdb-databags", map[string]map[string]confdb.JSONDatabag{s.devAccID: {"network": bag}})
+
+	exported, err := confdbstate.ExportDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, exported), Equals, `{"private":{"key":"secret"},"wifi":{"ssid":"foo"}}`)
+
+	// the exported databag is a copy
+	c.Assert(exported.Set(parsePath(c, "wifi.ssid"), "bar"), IsNil)
+	c.Check(s.databagData(c, bag), Equals, `{"private":{"key":"secret"},"wifi":{"ssid":"foo"}}`)
+}
+
+func (s *confdbTestSuite) TestExportDatabagEmpty(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	exported, err := confdbstate.ExportDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, exported), Equals, `{}`)
+}
+
+func (s *confdbTestSuite) TestExportDatabagUnknownSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := confdbstate.ExportDatabag(s.state, s.devAccID, "foo")
+	c.Assert(err, testutil.ErrorIs, &asserts.NotFoundError{})
+}
+
+func (s *confdbTestSuite) TestImportDatabag(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "wifi.ssid"), "foo"), IsNil)
+	s.state.Set("confdb-databags", map[string]map[string]confdb.JSONDatabag{s.devAccID: {"network": bag}})
+
+	data := []byte(`{
+  "wifi": {"ssid": "bar", "ssids": ["bar", "baz"]},
+  "private": {"key": "secret"}
+}`)
+	err := confdbstate.ImportDatabag(s.state, s.devAccID, "network", data, "test-snap")
+	c.Assert(err, IsNil)
+
+	bag, err = confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, bag), Equals, `{"private":{"key":"secret"},"wifi":{"ssid":"bar","ssids":["bar","baz"]}}`)
+
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 2)
+	// the data that predates the import is kept in the history
+	c.Check(revs[0].Snap, Equals, "")
+	c.Check(s.databagData(c, revs[0].Databag), Equals, `{"wifi":{"ssid":"foo"}}`)
+	c.Check(revs[1].Snap, Equals, "test-snap")
+	c.Check(revs[1].View, Equals, "")
+	c.Check(s.databagData(c, revs[1].Databag), Equals, `{"private":{"key":"secret"},"wifi":{"ssid":"bar","ssids":["bar","baz"]}}`)
+
+	// importing an empty object clears the databag
+	err = confdbstate.ImportDatabag(s.state, s.devAccID, "network", []byte(`{}`), "")
+	c.Assert(err, IsNil)
+
+	bag, err = confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, bag), Equals, `{}`)
+}
+
+func (s *confdbTestSuite) TestImportDatabagInvalid(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, tc := range []struct {
+		data string
+		err  string
+	}{
+		{data: `{`, err: `cannot import confdb [a-zA-Z0-9]+/network: cannot decode databag: .*`},
+		{data: `["foo"]`, err: `cannot import confdb [a-zA-Z0-9]+/network: cannot decode databag: .*`},
+		{data: `null`, err: `cannot import confdb [a-zA-Z0-9]+/network: databag must be a JSON object`},
+		{data: `{"wifi": {"ssid": 1}}`, err: `cannot import confdb [a-zA-Z0-9]+/network: .*`},
+	} {
+		err := confdbstate.ImportDatabag(s.state, s.devAccID, "network", []byte(tc.data), "")
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s", tc.data))
+		c.Check(err, testutil.ErrorIs, &confdbstate.InvalidDatabagError{})
+	}
+
+	var databags map[string]map[string]confdb.JSONDatabag
+	err := s.state.Get("confdb-databags", &databags)
+	c.Check(err, testutil.ErrorIs, &state.NoStateError{})
+}
+
+func (s *confdbTestSuite) TestImportDatabagUnknownSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := confdbstate.ImportDatabag(s.state, s.devAccID, "foo", []byte(`{}`), "")
+	c.Assert(err, testutil.ErrorIs, &asserts.NotFoundError{})
+}
+
+func (s *confdbTestSuite) TestImportDatabagOngoingTransaction(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(confdbstate.SetWriteTransaction(s.state, s.devAccID, "network", "10"), IsNil)
+
+	err := confdbstate.ImportDatabag(s.state, s.devAccID, "network", []byte(`{}`), "")
+	c.Assert(err, ErrorMatches, `cannot import confdb [a-zA-Z0-9]+/network: ongoing transaction`)
+}
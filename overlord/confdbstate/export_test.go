@@ -85,3 +85,19 @@ func MockTransactionTimeout(dur time.Duration) func() {
 		transactionTimeout = old
 	}
 }
+
+func MockDatabagHistoryLimit(limit int) func() {
+	old := databagHistoryLimit
+	databagHistoryLimit = limit
+	return func() {
+		databagHistoryLimit = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) func() {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -0,0 +1,354 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+)
+
+var rollbackConfdbChangeKind = swfeats.RegisterChangeKind("rollback-confdb")
+
+// databagHistoryLimit is the maximum number of databag revisions kept for
+// each confdb schema.
+var databagHistoryLimit = 10
+
+// DatabagRevision is a snapshot of a confdb databag, taken when a transaction
+// modifying it was committed.
+type DatabagRevision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// View is the ID of the view through which the databag was modified.
+	View string `json:"view,omitempty"`
+	// Snap is the snap that modified the databag. It's empty if the change
+	// came through the API.
+	Snap string `json:"snap,omitempty"`
+	// UserID is the uid of the user that requested the change. It's unset
+	// for changes that can't be attributed to a user.
+	UserID *int `json:"user-id,omitempty"`
+	// RollbackOf is the revision restored by this revision, if any.
+	RollbackOf int `json:"rollback-of,omitempty"`
+
+	Databag confdb.JSONDatabag `json:"databag"`
+}
+
+// commitOrigin describes who made the changes in a transaction, for
+// recording them in the databag's history.
+type commitOrigin struct {
+	View       string `json:"view,omitempty"`
+	Snap       string `json:"snap,omitempty"`
+	UserID     *int   `json:"user-id,omitempty"`
+	RollbackOf int    `json:"rollback-of,omitempty"`
+}
+
+func readDatabagHistories(st *state.State) (map[string]map[string][]*DatabagRevision, error) {
+	var histories map[string]map[string][]*DatabagRevision
+	if err := st.Get("confdb-history", &histories); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return histories, nil
+}
+
+// DatabagHistory returns the recorded revisions of the databag of the given
+// confdb schema, from oldest to newest.
+func DatabagHistory(st *state.State, account, schemaName string) ([]*DatabagRevision, error) {
+	histories, err := readDatabagHistories(st)
+	if err != nil {
+		return nil, err
+	}
+	return histories[account][schemaName], nil
+}
+
+// recordDatabagRevision adds the databag to the confdb schema's history,
+// dropping the oldest revisions beyond the history limit. Nothing is recorded
+// if the databag is the same as the latest revision.
+func recordDatabagRevision(st *state.State, account, schemaName string, databag confdb.JSONDatabag, origin commitOrigin) error {
+	histories, err := readDatabagHistories(st)
+	if err != nil {
+		return err
+	}
+
+	data, err := databag.Data()
+	if err != nil {
+		return err
+	}
+
+	revs := histories[account][schemaName]
+	rev := 1
+	if len(revs) > 0 {
+		latest := revs[len(revs)-1]
+		latestData, err := latest.Databag.Data()
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(data, latestData) {
+			return nil
+		}
+		rev = latest.Revision + 1
+	}
+
+	revs = append(revs, &DatabagRevision{
+		Revision:   rev,
+		Time:       timeNow(),
+		View:       origin.View,
+		Snap:       origin.Snap,
+		UserID:     origin.UserID,
+		RollbackOf: origin.RollbackOf,
+		Databag:    databag.Copy(),
+	})
+	if len(revs) > databagHistoryLimit {
+		revs = revs[len(revs)-databagHistoryLimit:]
+	}
+
+	if histories == nil {
+		histories = make(map[string]map[string][]*DatabagRevision)
+	}
+	if histories[account] == nil {
+		histories[account] = make(map[string][]*DatabagRevision)
+	}
+	histories[account][schemaName] = revs
+	st.Set("confdb-history", histories)
+	return nil
+}
+
+var timeNow = time.Now
+
+// recordCommittedDatabag records the databag that replaced the previous one
+// in the confdb schema's history.
+func recordCommittedDatabag(st *state.State, account, schemaName string, previous, databag confdb.JSONDatabag, origin commitOrigin) error {
+	revs, err := DatabagHistory(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+
+	// data written before history was kept can't be attributed to anyone but
+	// keep it so this first change can be rolled back
+	if len(revs) == 0 && len(previous) > 0 {
+		if err := recordDatabagRevision(st, account, schemaName, previous, commitOrigin{}); err != nil {
+			return err
+		}
+	}
+
+	return recordDatabagRevision(st, account, schemaName, databag, origin)
+}
+
+// ViewRevision holds the values visible through a view at a given revision of
+// the confdb databag.
+type ViewRevision struct {
+	Revision   int       `json:"revision"`
+	Time       time.Time `json:"time"`
+	View       string    `json:"view,omitempty"`
+	Snap       string    `json:"snap,omitempty"`
+	UserID     *int      `json:"user-id,omitempty"`
+	RollbackOf int       `json:"rollback-of,omitempty"`
+	Values     any       `json:"values,omitempty"`
+}
+
+// GetHistoryViaView returns the values visible through the view in each
+// revision of the confdb databag's history, from oldest to newest. Revisions
+// in which the view had no data for the requests are returned without values.
+func GetHistoryViaView(st *state.State, view *confdb.View, requests []string, constraints map[string]any, userID int) ([]*ViewRevision, error) {
+	account, schemaName := view.Schema().Account, view.Schema().Name
+	if err := view.CheckAllConstraintsAreUsed(requests, constraints); err != nil {
+		return nil, err
+	}
+
+	revs, err := DatabagHistory(st, account, schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*ViewRevision, 0, len(revs))
+	for _, rev := range revs {
+		values, err := GetViaView(rev.Databag, view, requests, constraints, userID)
+		if err != nil && !errors.Is(err, &confdb.NoDataError{}) {
+			return nil, err
+		}
+
+		history = append(history, &ViewRevision{
+			Revision:   rev.Revision,
+			Time:       rev.Time,
+			View:       rev.View,
+			Snap:       rev.Snap,
+			UserID:     rev.UserID,
+			RollbackOf: rev.RollbackOf,
+			Values:     values,
+		})
+	}
+	return history, nil
+}
+
+// RevisionNotFoundError is returned when a confdb databag revision isn't in
+// the confdb schema's history.
+type RevisionNotFoundError struct {
+	account    string
+	schemaName string
+	revision   int
+}
+
+func (e *RevisionNotFoundError) Is(err error) bool {
+	_, ok := err.(*RevisionNotFoundError)
+	return ok
+}
+
+func (e *RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("cannot rollback confdb %s/%s: revision %d not found in history", e.account, e.schemaName, e.revision)
+}
+
+// RollbackViaView schedules a change that restores the values the view can
+// write to the ones they had at the given revision of the databag's history.
+// Data that can't be written through the view is left alone. The change runs
+// the same custodian hooks as a write through the view would. The userID is
+// recorded as the user that requested the rollback. The state must be locked
+// by the caller.
+func RollbackViaView(st *state.State, view *confdb.View, revision int, userID int) (changeID string, err error) {
+	account, schemaName := view.Schema().Account, view.Schema().Name
+
+	txs, _, err := getOngoingTxs(st, account, schemaName)
+	if err != nil {
+		return "", fmt.Errorf("cannot access confdb view %s: cannot check ongoing transactions: %v", view.ID(), err)
+	}
+
+	if txs != nil && !txs.CanStartWriteTx() {
+		return "", fmt.Errorf("cannot rollback confdb through view %s: ongoing transaction", view.ID())
+	}
+
+	revs, err := DatabagHistory(st, account, schemaName)
+	if err != nil {
+		return "", err
+	}
+
+	var target *DatabagRevision
+	for _, rev := range revs {
+		if rev.Revision == revision {
+			target = rev
+			break
+		}
+	}
+	if target == nil {
+		return "", &RevisionNotFoundError{account: account, schemaName: schemaName, revision: revision}
+	}
+
+	tx, err := NewTransaction(st, account, schemaName)
+	if err != nil {
+		return "", fmt.Errorf("cannot rollback confdb through view %s: cannot create transaction: %v", view.ID(), err)
+	}
+
+	if err := restoreViaView(tx, view, target.Databag); err != nil {
+		return "", fmt.Errorf("cannot rollback confdb through view %s: %v", view.ID(), err)
+	}
+
+	ts, err := createChangeConfdbTasks(st, tx, view, "", userID)
+	if err != nil {
+		return "", err
+	}
+
+	commitTask, err := ts.Edge(commitEdge)
+	if err != nil {
+		return "", err
+	}
+
+	var origin commitOrigin
+	if err := commitTask.Get("confdb-origin", &origin); err != nil {
+		return "", err
+	}
+	origin.RollbackOf = revision
+	commitTask.Set("confdb-origin", origin)
+
+	chg := st.NewChange(rollbackConfdbChangeKind, fmt.Sprintf("Rollback confdb %s/%s to revision %d", account, schemaName, revision))
+	chg.AddAll(ts)
+
+	if err := setWriteTransaction(st, account, schemaName, commitTask.ID()); err != nil {
+		return "", err
+	}
+
+	ensureNow(st)
+	return chg.ID(), nil
+}
+
+// restoreViaView writes changes through the view into the transaction that
+// restore the values visible through the view to the ones in the databag.
+// Values that the view can't write are left as they are.
+func restoreViaView(tx *Transaction, view *confdb.View, databag confdb.JSONDatabag) error {
+	target, err := viewValues(databag, view)
+	if err != nil {
+		return err
+	}
+	current, err := viewValues(tx, view)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(current)+len(target))
+	for key := range current {
+		if _, ok := target[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	for key, value := range target {
+		if reflect.DeepEqual(current[key], value) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	// apply the changes in a deterministic order
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := target[key]
+		if ok {
+			err = view.Set(tx, key, value)
+		} else {
+			err = view.Unset(tx, key)
+		}
+		if err != nil && !errors.Is(err, &confdb.NoMatchError{}) {
+			return err
+		}
+	}
+	return nil
+}
+
+// viewValues returns all the values visible through the view, keyed by their
+// top-level request.
+func viewValues(bag confdb.Databag, view *confdb.View) (map[string]any, error) {
+	// the values are restored regardless of their visibility, it's up to
+	// the view's write rules to decide what can be changed
+	value, err := view.Get(bag, "", nil, 0)
+	if err != nil {
+		if errors.Is(err, &confdb.NoDataError{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	values, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("internal error: unexpected values of type %T read through view %s", value, view.ID())
+	}
+	return values, nil
+}
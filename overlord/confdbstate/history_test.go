@@ -0,0 +1,305 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// commitWithOrigin commits the given values into the network databag through
+// a commit-confdb-tx task carrying the given origin.
+func (s *confdbTestSuite) commitWithOrigin(c *C, values map[string]any, origin map[string]any) {
+	chg := s.state.NewChange("test", "")
+	t := s.state.NewTask("commit-confdb-tx", "")
+	chg.AddTask(t)
+
+	tx, err := confdbstate.NewTransaction(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	for path, value := range values {
+		if value == nil {
+			err = tx.Unset(parsePath(c, path))
+		} else {
+			err = tx.Set(parsePath(c, path), value)
+		}
+		c.Assert(err, IsNil)
+	}
+	setTransaction(t, tx)
+	if origin != nil {
+		t.Set("confdb-origin", origin)
+	}
+
+	s.state.Unlock()
+	err = s.o.Settle(testutil.HostScaledTimeout(5 * time.Second))
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(t.Status(), Equals, state.DoneStatus, Commentf(strings.Join(t.Log(), "\n")))
+}
+
+func (s *confdbTestSuite) databagData(c *C, bag confdb.JSONDatabag) string {
+	data, err := bag.Data()
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (s *confdbTestSuite) TestCommitRecordsHistory(c *C) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := confdbstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	view := s.devAccID + "/network/setup-wifi"
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, map[string]any{"view": view})
+	now = now.Add(time.Hour)
+	s.commitWithOrigin(c, map[string]any{"wifi.psk": "secret"}, map[string]any{"view": view, "snap": "test-snap", "user-id": 1000})
+	// commits that don't change the databag aren't recorded
+	s.commitWithOrigin(c, map[string]any{"wifi.psk": "secret"}, map[string]any{"view": view})
+
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 2)
+
+	c.Check(revs[0].Revision, Equals, 1)
+	c.Check(revs[0].Time.Equal(now.Add(-time.Hour)), Equals, true)
+	c.Check(revs[0].View, Equals, view)
+	c.Check(revs[0].Snap, Equals, "")
+	c.Check(revs[0].UserID, IsNil)
+	c.Check(revs[0].RollbackOf, Equals, 0)
+	c.Check(s.databagData(c, revs[0].Databag), Equals, `{"wifi":{"ssid":"foo"}}`)
+
+	c.Check(revs[1].Revision, Equals, 2)
+	c.Check(revs[1].Time.Equal(now), Equals, true)
+	c.Check(revs[1].View, Equals, view)
+	c.Check(revs[1].Snap, Equals, "test-snap")
+	c.Assert(revs[1].UserID, NotNil)
+	c.Check(*revs[1].UserID, Equals, 1000)
+	c.Check(s.databagData(c, revs[1].Databag), Equals, `{"wifi":{"psk":"secret","ssid":"foo"}}`)
+
+	// other schemas have no history
+	revs, err = confdbstate.DatabagHistory(s.state, s.devAccID, "other")
+	c.Assert(err, IsNil)
+	c.Check(revs, HasLen, 0)
+}
+
+func (s *confdbTestSuite) TestCommitRecordsHistoryLimit(c *C) {
+	restore := confdbstate.MockDatabagHistoryLimit(2)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, ssid := range []string{"foo", "bar", "baz"} {
+		s.commitWithOrigin(c, map[string]any{"wifi.ssid": ssid}, nil)
+	}
+
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 2)
+	c.Check(revs[0].Revision, Equals, 2)
+	c.Check(s.databagData(c, revs[0].Databag), Equals, `{"wifi":{"ssid":"bar"}}`)
+	c.Check(revs[1].Revision, Equals, 3)
+	c.Check(s.databagData(c, revs[1].Databag), Equals, `{"wifi":{"ssid":"baz"}}`)
+}
+
+func (s *confdbTestSuite) TestCommitRecordsPreexistingData(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "wifi.ssid"), "foo"), IsNil)
+	s.state.Set("confdb-databags", map[string]map[string]confdb.JSONDatabag{s.devAccID: {"network": bag}})
+
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "bar"}, map[string]any{"snap": "test-snap", "user-id": 0})
+
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 2)
+	// the data that predates the history has no origin
+	c.Check(revs[0].Revision, Equals, 1)
+	c.Check(revs[0].Snap, Equals, "")
+	c.Check(revs[0].UserID, IsNil)
+	c.Check(s.databagData(c, revs[0].Databag), Equals, `{"wifi":{"ssid":"foo"}}`)
+	c.Check(revs[1].Revision, Equals, 2)
+	c.Check(revs[1].Snap, Equals, "test-snap")
+	c.Assert(revs[1].UserID, NotNil)
+	c.Check(*revs[1].UserID, Equals, 0)
+	c.Check(s.databagData(c, revs[1].Databag), Equals, `{"wifi":{"ssid":"bar"}}`)
+}
+
+func (s *confdbTestSuite) TestGetHistoryViaView(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.commitWithOrigin(c, map[string]any{"private.foo": "a"}, nil)
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, map[string]any{"snap": "test-snap", "user-id": 1000})
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "bar"}, nil)
+
+	view := s.dbSchema.View("setup-wifi")
+	history, err := confdbstate.GetHistoryViaView(s.state, view, []string{"ssid"}, nil, 0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 3)
+
+	c.Check(history[0].Revision, Equals, 1)
+	// the view had no data at this revision
+	c.Check(history[0].Values, IsNil)
+	c.Check(history[1].Revision, Equals, 2)
+	c.Check(history[1].Snap, Equals, "test-snap")
+	c.Assert(history[1].UserID, NotNil)
+	c.Check(*history[1].UserID, Equals, 1000)
+	c.Check(history[1].Values, DeepEquals, map[string]any{"ssid": "foo"})
+	c.Check(history[2].Revision, Equals, 3)
+	c.Check(history[2].Values, DeepEquals, map[string]any{"ssid": "bar"})
+
+	_, err = confdbstate.GetHistoryViaView(s.state, view, []string{"ssid"}, map[string]any{"bla": "foo"}, 0)
+	c.Assert(err, FitsTypeOf, &confdb.UnmatchedConstraintsError{})
+}
+
+func (s *confdbTestSuite) TestRollbackViaView(c *C) {
+	hooks, restore := s.mockConfdbHooks()
+	defer restore()
+
+	restore = confdbstate.MockEnsureNow(func(*state.State) {
+		s.checkOngoingWriteConfdbTx(c, s.devAccID, "network")
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	custodians := map[string]confdbHooks{"custodian-snap": allHooks}
+	s.setupConfdbScenario(c, custodians, nil)
+
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, nil)
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "bar", "private.foo": "a"}, nil)
+
+	view := s.dbSchema.View("setup-wifi")
+	chgID, err := confdbstate.RollbackViaView(s.state, view, 1, 1000)
+	c.Assert(err, IsNil)
+
+	chg := s.state.Change(chgID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "rollback-confdb")
+	c.Check(chg.Summary(), Equals, "Rollback confdb "+s.devAccID+"/network to revision 1")
+
+	s.state.Unlock()
+	err = s.o.Settle(testutil.HostScaledTimeout(5 * time.Second))
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+	// the custodian got to check, save and observe the rolled back data
+	c.Check(*hooks, DeepEquals, []string{"change-view-setup", "save-view-setup", "observe-view-setup"})
+
+	bag, err := confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, bag), Equals, `{"wifi":{"ssid":"foo"}}`)
+
+	revs, err := confdbstate.DatabagHistory(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 3)
+	c.Check(revs[2].Revision, Equals, 3)
+	c.Check(revs[2].RollbackOf, Equals, 1)
+	c.Check(revs[2].View, Equals, view.ID())
+	c.Assert(revs[2].UserID, NotNil)
+	c.Check(*revs[2].UserID, Equals, 1000)
+	c.Check(s.databagData(c, revs[2].Databag), Equals, `{"wifi":{"ssid":"foo"}}`)
+
+	// no ongoing transaction is left behind
+	var ongoing map[string]*confdbstate.ConfdbTransactions
+	err = s.state.Get("confdb-ongoing-txs", &ongoing)
+	c.Assert(err, testutil.ErrorIs, &state.NoStateError{})
+}
+
+func (s *confdbTestSuite) TestRollbackViaViewOnlyWritesThroughView(c *C) {
+	_, restore := s.mockConfdbHooks()
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	custodians := map[string]confdbHooks{"custodian-snap": allHooks}
+	s.setupConfdbScenario(c, custodians, nil)
+
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo", "wifi.status": "up", "wifi.psk": "one"}, nil)
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "bar", "wifi.status": "down", "wifi.psk": "two", "private.foo": "a"}, nil)
+
+	view := s.dbSchema.View("setup-wifi")
+	chgID, err := confdbstate.RollbackViaView(s.state, view, 1, 0)
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	err = s.o.Settle(testutil.HostScaledTimeout(5 * time.Second))
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	chg := s.state.Change(chgID)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	// the status can only be read and the password only be written through
+	// the view so they aren't rolled back
+	bag, err := confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(s.databagData(c, bag), Equals, `{"wifi":{"psk":"two","ssid":"foo","status":"down"}}`)
+}
+
+func (s *confdbTestSuite) TestRollbackViaViewUnknownRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, nil)
+
+	view := s.dbSchema.View("setup-wifi")
+	_, err := confdbstate.RollbackViaView(s.state, view, 2, 0)
+	c.Assert(err, ErrorMatches, `cannot rollback confdb [a-zA-Z0-9]+/network: revision 2 not found in history`)
+	c.Check(err, testutil.ErrorIs, &confdbstate.RevisionNotFoundError{})
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *confdbTestSuite) TestRollbackViaViewOngoingTransaction(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, nil)
+	c.Assert(confdbstate.SetWriteTransaction(s.state, s.devAccID, "network", "10"), IsNil)
+
+	view := s.dbSchema.View("setup-wifi")
+	_, err := confdbstate.RollbackViaView(s.state, view, 1, 0)
+	c.Assert(err, ErrorMatches, `cannot rollback confdb through view [a-zA-Z0-9]+/network/setup-wifi: ongoing transaction`)
+}
+
+func (s *confdbTestSuite) TestRollbackViaViewNoCustodian(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, nil, []string{"test-snap"})
+	s.commitWithOrigin(c, map[string]any{"wifi.ssid": "foo"}, nil)
+
+	view := s.dbSchema.View("setup-wifi")
+	_, err := confdbstate.RollbackViaView(s.state, view, 1, 0)
+	c.Assert(err, ErrorMatches, `cannot commit changes to confdb made through view .*: no custodian snap installed`)
+}
@@ -107,3 +107,7 @@ func MockEnvPath(newEnvPath string) func() {
 	envFilePath = newEnvPath
 	return func() { envFilePath = oldEnvPath }
 }
+
+func MockMetricsSetEnabled(f func(bool)) func() {
+	return testutil.Mock(&metricsSetEnabled, f)
+}
@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/restart"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.maintenance.windows"] = true
+}
+
+func validateMaintenanceWindows(tr RunTransaction) error {
+	windows, err := coreCfg(tr, "maintenance.windows")
+	if err != nil {
+		return err
+	}
+	if windows == "" {
+		return nil
+	}
+	if _, err := restart.ParseMaintenanceWindows(windows); err != nil {
+		return fmt.Errorf("cannot parse maintenance.windows: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type maintenanceSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&maintenanceSuite{})
+
+func (s *maintenanceSuite) TestConfigureMaintenanceWindows(c *C) {
+	for _, tc := range []struct {
+		windows string
+		err     string
+	}{
+		{windows: "mon-fri,02:00-04:00"},
+		{windows: "sat,sun"},
+		{windows: "22:00-02:00"},
+		{windows: "mon,02:00-04:00,,sat,10:00-12:00"},
+		{windows: ""},
+		{windows: "10:00", err: `cannot parse maintenance.windows: maintenance window "10:00" is not a time range`},
+		{windows: "02:00~04:00", err: `cannot parse maintenance.windows: maintenance window "02:00~04:00" cannot be spread or split`},
+		{windows: "02:00-04:00/2", err: `cannot parse maintenance.windows: maintenance window "02:00-04:00/2" cannot be spread or split`},
+		{windows: "whenever", err: `cannot parse maintenance.windows: cannot parse "whenever": .*`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"maintenance.windows": tc.windows,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.windows))
+		} else {
+			c.Check(err, IsNil, Commentf("%q", tc.windows))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type metricsSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/"), 0755)
+	c.Assert(err, IsNil)
+	err = os.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *metricsSuite) TestConfigureExperimentalMetrics(c *C) {
+	var calls []bool
+	restore := configcore.MockMetricsSetEnabled(func(on bool) {
+		calls = append(calls, on)
+	})
+	defer restore()
+
+	for _, val := range []any{true, false} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: map[string]any{"experimental.metrics": val},
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Check(calls, DeepEquals, []bool{true, false})
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -43,6 +44,12 @@ func init() {
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
 	supportedConfigurations["core.refresh.max-inhibition-days"] = true
+	supportedConfigurations["core.refresh.rollout.soak"] = true
+	supportedConfigurations["core.refresh.rollout.hold-on-unhealthy"] = true
+	supportedConfigurations["core.refresh.health-check.rollback"] = true
+	supportedConfigurations["core.refresh.health-check.rollback-snaps"] = true
+	supportedConfigurations["core.refresh.health-check.grace-period"] = true
+	supportedConfigurations["core.refresh.health-check.timeout"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr RunTransaction, optName string) error {
@@ -163,3 +170,53 @@ func validateRefreshRateLimit(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateRefreshRollout(tr RunTransaction) error {
+	soak, err := coreCfg(tr, "refresh.rollout.soak")
+	if err != nil {
+		return err
+	}
+	if soak != "" {
+		d, err := time.ParseDuration(soak)
+		if err != nil {
+			return fmt.Errorf("refresh.rollout.soak cannot be parsed: %v", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("refresh.rollout.soak cannot be negative, not %q", soak)
+		}
+	}
+	return validateBoolFlag(tr, "refresh.rollout.hold-on-unhealthy")
+}
+
+func validateRefreshHealthCheck(tr RunTransaction) error {
+	if err := validateBoolFlag(tr, "refresh.health-check.rollback"); err != nil {
+		return err
+	}
+	snapsStr, err := coreCfg(tr, "refresh.health-check.rollback-snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := naming.ValidateInstance(name); err != nil {
+			return fmt.Errorf("invalid refresh.health-check.rollback-snaps: %v", err)
+		}
+	}
+	for _, key := range []string{"grace-period", "timeout"} {
+		option := "refresh.health-check." + key
+		valueStr, err := coreCfg(tr, option)
+		if err != nil {
+			return err
+		}
+		if valueStr == "" {
+			continue
+		}
+		d, err := time.ParseDuration(valueStr)
+		if err != nil {
+			return fmt.Errorf("%s cannot be parsed: %v", option, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("%s cannot be negative, not %q", option, valueStr)
+		}
+	}
+	return nil
+}
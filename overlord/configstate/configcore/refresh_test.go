@@ -252,3 +252,66 @@ func (s *refreshSuite) TestConfigureRefreshMaxInhibitionDays(c *C) {
 		}
 	}
 }
+
+func (s *refreshSuite) TestConfigureRefreshRollout(c *C) {
+	data := []struct {
+		soak, hold any
+		err        string
+	}{
+		{soak: "zzz", err: `refresh\.rollout\.soak cannot be parsed: .*`},
+		{soak: "-1h", err: `refresh\.rollout\.soak cannot be negative, not "-1h"`},
+		{hold: "maybe", err: `refresh\.rollout\.hold-on-unhealthy can only be set to 'true' or 'false'`},
+		// happy cases
+		{},
+		{soak: ""},
+		{soak: "72h", hold: true},
+		{soak: "30m", hold: "false"},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.rollout.soak":              tc.soak,
+				"refresh.rollout.hold-on-unhealthy": tc.hold,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err)
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
+
+func (s *refreshSuite) TestConfigureRefreshHealthCheck(c *C) {
+	data := []struct {
+		key string
+		val any
+		err string
+	}{
+		{key: "rollback", val: "maybe", err: `refresh\.health-check\.rollback can only be set to 'true' or 'false'`},
+		{key: "rollback-snaps", val: "foo,-bar", err: `invalid refresh\.health-check\.rollback-snaps: invalid snap name: "-bar"`},
+		{key: "grace-period", val: "zzz", err: `refresh\.health-check\.grace-period cannot be parsed: .*`},
+		{key: "timeout", val: "-5m", err: `refresh\.health-check\.timeout cannot be negative, not "-5m"`},
+		// happy cases
+		{key: "rollback", val: true},
+		{key: "rollback", val: "false"},
+		{key: "rollback-snaps", val: "foo,bar_instance"},
+		{key: "grace-period", val: "30s"},
+		{key: "timeout", val: "10m"},
+		{key: "timeout", val: ""},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.health-check." + tc.key: tc.val,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%s=%v", tc.key, tc.val))
+		} else {
+			c.Check(err, IsNil, Commentf("%s=%v", tc.key, tc.val))
+		}
+	}
+}
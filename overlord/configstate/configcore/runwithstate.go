@@ -59,7 +59,15 @@ func init() {
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshRollout, nil, validateOnly)
+	addWithStateHandler(validateRefreshHealthCheck, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsTarget, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsSchedule, nil, validateOnly)
+	addWithStateHandler(validateStoreLANSharing, nil, validateOnly)
+	addWithStateHandler(validateStoreMirror, nil, validateOnly)
+	addWithStateHandler(validateStoreBandwidth, nil, validateOnly)
+	addWithStateHandler(validateMaintenanceWindows, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
@@ -76,6 +84,9 @@ func init() {
 	// experimental.apparmor-prompting
 	addWithStateHandler(nil, doExperimentalApparmorPromptingDaemonRestart, nil)
 
+	// experimental.metrics
+	addWithStateHandler(nil, handleExperimentalMetrics, nil)
+
 	// interface.*.allow-auto-connection
 	addWithStateHandler(validateAllowAutoConnectionValue, nil, &flags{validatedOnlyStateConfig: true})
 }
@@ -22,12 +22,25 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	for _, key := range []string{"type", "url", "region", "access-key", "secret-key", "identity", "keep", "max-age"} {
+		supportedConfigurations["core.snapshots.target."+key] = true
+	}
+	supportedConfigurations["core.snapshots.schedule"] = true
+	for _, key := range []string{"snaps", "keep-last", "keep-daily", "keep-weekly"} {
+		supportedConfigurations["core.snapshots.scheduled."+key] = true
+	}
 }
 
 func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
@@ -46,3 +59,88 @@ func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateSnapshotsTarget(tr RunTransaction) error {
+	var cfg remote.Config
+	for key, value := range map[string]*string{
+		"type":       &cfg.Type,
+		"url":        &cfg.URL,
+		"region":     &cfg.Region,
+		"access-key": &cfg.AccessKey,
+		"secret-key": &cfg.SecretKey,
+		"identity":   &cfg.Identity,
+	} {
+		v, err := coreCfg(tr, "snapshots.target."+key)
+		if err != nil {
+			return err
+		}
+		*value = v
+	}
+	if cfg.Type != "" {
+		if err := remote.Validate(&cfg); err != nil {
+			return fmt.Errorf("invalid snapshots.target: %v", err)
+		}
+	}
+
+	keepStr, err := coreCfg(tr, "snapshots.target.keep")
+	if err != nil {
+		return err
+	}
+	if keepStr != "" {
+		keep, err := strconv.Atoi(keepStr)
+		if err != nil || keep < 0 {
+			return fmt.Errorf("snapshots.target.keep must be a non-negative number, got %q", keepStr)
+		}
+	}
+
+	maxAgeStr, err := coreCfg(tr, "snapshots.target.max-age")
+	if err != nil {
+		return err
+	}
+	if maxAgeStr != "" {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil {
+			return fmt.Errorf("snapshots.target.max-age cannot be parsed: %v", err)
+		}
+		if maxAge < time.Hour*24 {
+			return fmt.Errorf("snapshots.target.max-age must be a value greater than 24 hours")
+		}
+	}
+	return nil
+}
+
+func validateSnapshotsSchedule(tr RunTransaction) error {
+	scheduleStr, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != "" {
+		if _, err := timeutil.ParseSchedule(scheduleStr); err != nil {
+			return fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.scheduled.snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := naming.ValidateInstance(name); err != nil {
+			return fmt.Errorf("invalid snapshots.scheduled.snaps: %v", err)
+		}
+	}
+
+	for _, key := range []string{"keep-last", "keep-daily", "keep-weekly"} {
+		valueStr, err := coreCfg(tr, "snapshots.scheduled."+key)
+		if err != nil {
+			return err
+		}
+		if valueStr == "" {
+			continue
+		}
+		if value, err := strconv.Atoi(valueStr); err != nil || value < 0 {
+			return fmt.Errorf("snapshots.scheduled.%s must be a non-negative number, got %q", key, valueStr)
+		}
+	}
+	return nil
+}
@@ -71,3 +71,83 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureSnapshotsTargetHappy(c *C) {
+	for _, conf := range []map[string]any{
+		{
+			"snapshots.target.type":       "s3",
+			"snapshots.target.url":        "https://s3.example.com/bucket/prefix",
+			"snapshots.target.region":     "eu-west-1",
+			"snapshots.target.access-key": "key",
+			"snapshots.target.secret-key": "secret",
+			"snapshots.target.keep":       "5",
+		},
+		{
+			"snapshots.target.type":     "ssh",
+			"snapshots.target.url":      "ssh://backup@host/srv/snapshots",
+			"snapshots.target.identity": "/root/.ssh/id_ed25519",
+			"snapshots.target.max-age":  "720h",
+		},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  conf,
+		})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsTargetInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]any
+		err  string
+	}{
+		{map[string]any{"snapshots.target.type": "ftp"}, `invalid snapshots.target: unsupported snapshot target type "ftp"`},
+		{map[string]any{"snapshots.target.type": "s3", "snapshots.target.url": "https://host/bucket"}, `invalid snapshots.target: S3 snapshot target requires an access key and a secret key`},
+		{map[string]any{"snapshots.target.type": "ssh", "snapshots.target.url": "https://host/dir"}, `invalid snapshots.target: invalid ssh snapshot target URL "https://host/dir": scheme must be ssh`},
+		{map[string]any{"snapshots.target.keep": "-1"}, `snapshots.target.keep must be a non-negative number, got "-1"`},
+		{map[string]any{"snapshots.target.keep": "many"}, `snapshots.target.keep must be a non-negative number, got "many"`},
+		{map[string]any{"snapshots.target.max-age": "forever"}, `snapshots.target.max-age cannot be parsed:.*`},
+		{map[string]any{"snapshots.target.max-age": "1h"}, `snapshots.target.max-age must be a value greater than 24 hours`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsScheduleHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"snapshots.schedule":             "mon,02:00-04:00",
+			"snapshots.scheduled.snaps":      "foo,bar_instance",
+			"snapshots.scheduled.keep-last":  "3",
+			"snapshots.scheduled.keep-daily": "7",
+			// 0 disables weekly retention
+			"snapshots.scheduled.keep-weekly": "0",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsScheduleInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]any
+		err  string
+	}{
+		{map[string]any{"snapshots.schedule": "whenever"}, `snapshots.schedule cannot be parsed: .*`},
+		{map[string]any{"snapshots.scheduled.snaps": "foo,-bar"}, `invalid snapshots.scheduled.snaps: invalid snap name: "-bar"`},
+		{map[string]any{"snapshots.scheduled.keep-last": "-1"}, `snapshots.scheduled.keep-last must be a non-negative number, got "-1"`},
+		{map[string]any{"snapshots.scheduled.keep-daily": "all"}, `snapshots.scheduled.keep-daily must be a non-negative number, got "all"`},
+		{map[string]any{"snapshots.scheduled.keep-weekly": "1.5"}, `snapshots.scheduled.keep-weekly must be a non-negative number, got "1.5"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+	}
+}
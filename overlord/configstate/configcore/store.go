@@ -22,16 +22,32 @@ package configcore
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/lanshare"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/sysconfig"
 )
 
 func init() {
 	supportedConfigurations["core.store.access"] = true
+	supportedConfigurations["core.store.lan-sharing"] = true
+	supportedConfigurations["core.store.lan-peers"] = true
+	supportedConfigurations["core.store.lan-interfaces"] = true
+	supportedConfigurations["core.store.mirror"] = true
+	supportedConfigurations["core.store.mirror-port"] = true
+	supportedConfigurations["core.store.mirror-address"] = true
+	supportedConfigurations["core.store.mirror-device-auth"] = true
+	supportedConfigurations["core.store.bandwidth.schedule"] = true
+	supportedConfigurations["core.store.bandwidth.monthly-budget"] = true
 }
 
 func validateStoreAccess(cfg ConfGetter) error {
@@ -48,6 +64,93 @@ func validateStoreAccess(cfg ConfGetter) error {
 	}
 }
 
+func validateStoreLANSharing(tr RunTransaction) error {
+	if err := validateBoolFlag(tr, "store.lan-sharing"); err != nil {
+		return err
+	}
+	interfaces, err := coreCfg(tr, "store.lan-interfaces")
+	if err != nil {
+		return err
+	}
+	if interfaces != "" {
+		for _, iface := range strings.Split(interfaces, ",") {
+			if !validNetworkInterfaceName(strings.TrimSpace(iface)) {
+				return fmt.Errorf("cannot set store.lan-interfaces: invalid network interface name %q", strings.TrimSpace(iface))
+			}
+		}
+	}
+	peers, err := coreCfg(tr, "store.lan-peers")
+	if err != nil {
+		return err
+	}
+	if peers == "" {
+		return nil
+	}
+	for _, peer := range strings.Split(peers, ",") {
+		if _, err := lanshare.PeerAddress(strings.TrimSpace(peer)); err != nil {
+			return fmt.Errorf("cannot set store.lan-peers: %v", err)
+		}
+	}
+	return nil
+}
+
+// validNetworkInterfaceName returns whether the given name can be the name
+// of a network interface, which are limited to 15 bytes by the kernel.
+func validNetworkInterfaceName(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 15 {
+		return false
+	}
+	return !strings.ContainsAny(name, "/: \t\n")
+}
+
+func validateStoreMirror(tr RunTransaction) error {
+	if err := validateBoolFlag(tr, "store.mirror"); err != nil {
+		return err
+	}
+	if err := validateBoolFlag(tr, "store.mirror-device-auth"); err != nil {
+		return err
+	}
+	address, err := coreCfg(tr, "store.mirror-address")
+	if err != nil {
+		return err
+	}
+	if address != "" && net.ParseIP(address) == nil {
+		return fmt.Errorf("cannot set store.mirror-address: invalid IP address %q", address)
+	}
+	port, err := coreCfg(tr, "store.mirror-port")
+	if err != nil {
+		return err
+	}
+	if port == "" {
+		return nil
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return fmt.Errorf("cannot set store.mirror-port: invalid port %q", port)
+	}
+	return nil
+}
+
+func validateStoreBandwidth(tr RunTransaction) error {
+	schedule, err := coreCfg(tr, "store.bandwidth.schedule")
+	if err != nil {
+		return err
+	}
+	if _, err := store.ParseBandwidthSchedule(schedule); err != nil {
+		return fmt.Errorf("cannot set store.bandwidth.schedule: %v", err)
+	}
+	budget, err := coreCfg(tr, "store.bandwidth.monthly-budget")
+	if err != nil {
+		return err
+	}
+	if budget == "" {
+		return nil
+	}
+	if _, err := strutil.ParseByteSize(budget); err != nil {
+		return fmt.Errorf("cannot set store.bandwidth.monthly-budget: %v", err)
+	}
+	return nil
+}
+
 // repairConfig is a set of configuration data that is consumed by the
 // snap-repair command. This struct is duplicated in cmd/snap-repair.
 type repairConfig struct {
@@ -95,3 +95,98 @@ func (s *storeSuite) TestFilesystemOnlyApply(c *C) {
 
 	c.Check(repairConfig.StoreOffline, Equals, true)
 }
+
+func (s *storeSuite) TestStoreLANSharingHappy(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.lan-sharing":    true,
+			"store.lan-peers":      "10.0.0.1, device-2.local:8000,[fe80::1]:8000",
+			"store.lan-interfaces": "eth0, wlp2s0",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *storeSuite) TestStoreLANSharingUnhappy(c *C) {
+	for _, t := range []struct {
+		changes map[string]any
+		err     string
+	}{
+		{map[string]any{"store.lan-sharing": "yes"}, "store.lan-sharing can only be set to 'true' or 'false'"},
+		{map[string]any{"store.lan-peers": "10.0.0.1,,10.0.0.2"}, "cannot set store.lan-peers: empty peer address"},
+		{map[string]any{"store.lan-peers": "10.0.0.1:http"}, `cannot set store.lan-peers: invalid peer address "10.0.0.1:http": invalid port`},
+		{map[string]any{"store.lan-interfaces": "eth0,"}, `cannot set store.lan-interfaces: invalid network interface name ""`},
+		{map[string]any{"store.lan-interfaces": "../eth0"}, `cannot set store.lan-interfaces: invalid network interface name "../eth0"`},
+		{map[string]any{"store.lan-interfaces": "a-very-long-interface"}, `cannot set store.lan-interfaces: invalid network interface name "a-very-long-interface"`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: t.changes,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.changes))
+	}
+}
+
+func (s *storeSuite) TestStoreMirrorHappy(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.mirror":             true,
+			"store.mirror-port":        8080,
+			"store.mirror-address":     "192.168.1.10",
+			"store.mirror-device-auth": false,
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *storeSuite) TestStoreMirrorUnhappy(c *C) {
+	for _, t := range []struct {
+		changes map[string]any
+		err     string
+	}{
+		{map[string]any{"store.mirror": "yes"}, "store.mirror can only be set to 'true' or 'false'"},
+		{map[string]any{"store.mirror-port": 0}, `cannot set store.mirror-port: invalid port "0"`},
+		{map[string]any{"store.mirror-port": 65536}, `cannot set store.mirror-port: invalid port "65536"`},
+		{map[string]any{"store.mirror-port": "http"}, `cannot set store.mirror-port: invalid port "http"`},
+		{map[string]any{"store.mirror-address": "device-1.local"}, `cannot set store.mirror-address: invalid IP address "device-1.local"`},
+		{map[string]any{"store.mirror-device-auth": "yes"}, "store.mirror-device-auth can only be set to 'true' or 'false'"},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: t.changes,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.changes))
+	}
+}
+
+func (s *storeSuite) TestStoreBandwidthHappy(c *C) {
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.bandwidth.schedule":       "09:00-17:00=256kB,17:00-22:00=unlimited,22:00-06:00=pause",
+			"store.bandwidth.monthly-budget": "10GB",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *storeSuite) TestStoreBandwidthUnhappy(c *C) {
+	for _, t := range []struct {
+		changes map[string]any
+		err     string
+	}{
+		{map[string]any{"store.bandwidth.schedule": "09:00-17:00"}, `cannot set store.bandwidth.schedule: cannot parse bandwidth window "09:00-17:00": missing policy`},
+		{map[string]any{"store.bandwidth.schedule": "9-17=1MB"}, `cannot set store.bandwidth.schedule: cannot parse bandwidth window "9-17=1MB": .*`},
+		{map[string]any{"store.bandwidth.schedule": "09:00-17:00=fast"}, `cannot set store.bandwidth.schedule: cannot parse bandwidth window "09:00-17:00=fast": .*`},
+		{map[string]any{"store.bandwidth.schedule": "09:00-17:00=1MB,16:00-18:00=pause"}, `cannot set store.bandwidth.schedule: bandwidth windows .* overlap`},
+		{map[string]any{"store.bandwidth.monthly-budget": "lots"}, `cannot set store.bandwidth.monthly-budget: cannot parse "lots": .*`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: t.changes,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.changes))
+	}
+}
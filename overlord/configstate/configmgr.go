@@ -24,6 +24,8 @@ import (
 	"regexp"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -55,6 +57,13 @@ func Init(st *state.State, hookManager *hookstate.HookManager) error {
 	}
 	dirs.SetSnapHomeDirs(homedirs)
 
+	// Collect metrics only if they are exposed
+	collectMetrics, err := features.Flag(tr, features.Metrics)
+	if err != nil {
+		return err
+	}
+	metrics.SetEnabled(collectMetrics)
+
 	// Default configuration is handled via the "default-configure" hook
 	hookManager.Register(regexp.MustCompile("^default-configure$"), newDefaultConfigureHandler)
 
@@ -0,0 +1,274 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicemgmtstate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	confdbstateGetView             = confdbstate.GetView
+	confdbstateLoadConfdbAsync     = confdbstate.LoadConfdbAsync
+	confdbstateGetTransactionToSet = confdbstate.GetTransactionToSet
+	confdbstateSetViaView          = confdbstate.SetViaView
+
+	confdbControlForDevice = confdbControlForDeviceImpl
+)
+
+const (
+	getConfdbChangeKind = "get-confdb"
+	setConfdbChangeKind = "set-confdb"
+)
+
+type confdbMessageBody struct {
+	Action      string         `json:"action"`
+	Account     string         `json:"account"`
+	View        string         `json:"view"`
+	Keys        []string       `json:"keys,omitempty"`
+	Constraints map[string]any `json:"constraints,omitempty"`
+	Values      map[string]any `json:"values,omitempty"`
+
+	schemaName string
+	viewName   string
+}
+
+// viewID returns the view identifier in the format used by confdb-control
+// assertions, i.e. account/confdb-schema/view.
+func (b *confdbMessageBody) viewID() string {
+	return fmt.Sprintf("%s/%s/%s", b.Account, b.schemaName, b.viewName)
+}
+
+// confdbHandler handles messages reading or writing confdb through a view.
+// Bodies have the form:
+//
+//	{"action": "get", "account": "my-brand", "view": "network/access-wifi", "keys": ["ssid"]}
+//	{"action": "set", "account": "my-brand", "view": "network/access-wifi", "values": {"ssid": "foo"}}
+//
+// Writes are committed by a change running the custodian hooks of the view,
+// reads by a change running its load and query hooks, if any.
+type confdbHandler struct{}
+
+func (h *confdbHandler) parse(msg *RequestMessage) (*confdbMessageBody, error) {
+	var body confdbMessageBody
+	if err := decodeBody(msg, &body); err != nil {
+		return nil, err
+	}
+
+	if body.Account == "" {
+		return nil, errors.New("cannot access confdb: account not specified")
+	}
+
+	parts := strings.Split(body.View, "/")
+	if len(parts) != 2 || !confdb.ValidConfdbName.MatchString(parts[0]) || !confdb.ValidViewName.MatchString(parts[1]) {
+		return nil, fmt.Errorf(`cannot access confdb: view %q must be in the format confdb-schema/view`, body.View)
+	}
+	body.schemaName, body.viewName = parts[0], parts[1]
+
+	switch body.Action {
+	case "get":
+		if len(body.Values) != 0 {
+			return nil, errors.New("cannot get confdb: values cannot be specified")
+		}
+		for k, v := range body.Constraints {
+			switch v.(type) {
+			case nil, []any, map[string]any:
+				return nil, fmt.Errorf("cannot get confdb: constraint %q must be a non-null scalar", k)
+			}
+		}
+	case "set":
+		if len(body.Values) == 0 {
+			return nil, errors.New("cannot set confdb: no values specified")
+		}
+		if len(body.Keys) != 0 || len(body.Constraints) != 0 {
+			return nil, errors.New("cannot set confdb: keys and constraints cannot be specified")
+		}
+	default:
+		return nil, fmt.Errorf("cannot access confdb: unknown action %q", body.Action)
+	}
+
+	return &body, nil
+}
+
+// checkAuthority verifies that the sender may access the view. Besides the
+// device authorities, operators to whom the view was delegated through the
+// device's confdb-control assertion may access it.
+// Caller must hold state lock.
+func (h *confdbHandler) checkAuthority(st *state.State, msg *RequestMessage, body *confdbMessageBody) error {
+	serial, err := checkAddressedToDevice(st, msg)
+	if err != nil {
+		return err
+	}
+
+	ok, err := isDeviceAuthority(st, serial, msg.AccountID)
+	if err != nil || ok {
+		return err
+	}
+
+	cc, err := confdbControlForDevice(st, serial)
+	if err != nil {
+		if errors.Is(err, &asserts.NotFoundError{}) {
+			return &UnauthorizedError{Reason: fmt.Sprintf("view %s is not delegated to account %q", body.viewID(), msg.AccountID)}
+		}
+		return err
+	}
+
+	// messages signed by the operator itself are authenticated by the
+	// operator's key, otherwise the store signed them on its behalf; only
+	// the verified signer is considered, never the headers of the message
+	var authMeth string
+	switch msg.SignedBy {
+	case "":
+		return fmt.Errorf("internal error: signature of message %s was not verified", msg.ID())
+	case msg.AccountID:
+		authMeth = "operator-key"
+	default:
+		authMeth = "store"
+	}
+
+	ctrl := cc.Control()
+	delegated, err := ctrl.IsDelegated(msg.AccountID, body.viewID(), []string{authMeth})
+	if err != nil {
+		return err
+	}
+	if !delegated {
+		return &UnauthorizedError{Reason: fmt.Sprintf("view %s is not delegated to account %q", body.viewID(), msg.AccountID)}
+	}
+
+	return nil
+}
+
+func confdbControlForDeviceImpl(st *state.State, serial *asserts.Serial) (*asserts.ConfdbControl, error) {
+	a, err := assertstate.DB(st).Find(asserts.ConfdbControlType, map[string]string{
+		"brand-id": serial.BrandID(),
+		"model":    serial.Model(),
+		"serial":   serial.Serial(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cc := a.(*asserts.ConfdbControl)
+	if cc.SignKeyID() != serial.DeviceKey().ID() {
+		return nil, errors.New("confdb-control's signing key doesn't match the device key")
+	}
+
+	return cc, nil
+}
+
+// Validate implements MessageHandler.
+func (h *confdbHandler) Validate(st *state.State, msg *RequestMessage) error {
+	body, err := h.parse(msg)
+	if err != nil {
+		return err
+	}
+
+	tr := config.NewTransaction(st)
+	enabled, err := features.Flag(tr, features.Confdb)
+	if err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("cannot access confdb: feature flag %q is disabled", features.Confdb)
+	}
+
+	if err := h.checkAuthority(st, msg, body); err != nil {
+		return err
+	}
+
+	view, err := confdbstateGetView(st, body.Account, body.schemaName, body.viewName)
+	if err != nil {
+		return err
+	}
+
+	if body.Action == "get" {
+		return view.CheckAllConstraintsAreUsed(body.Keys, body.Constraints)
+	}
+
+	// check the values can be written through the view without committing them
+	tx, err := confdbstate.NewTransaction(st, body.Account, body.schemaName)
+	if err != nil {
+		return err
+	}
+	return confdbstateSetViaView(tx, view, body.Values)
+}
+
+// Apply implements MessageHandler.
+func (h *confdbHandler) Apply(st *state.State, msg *RequestMessage) (string, error) {
+	body, err := h.parse(msg)
+	if err != nil {
+		return "", err
+	}
+
+	view, err := confdbstateGetView(st, body.Account, body.schemaName, body.viewName)
+	if err != nil {
+		return "", err
+	}
+
+	// remote requests are served with root privileges
+	const userID = 0
+	if body.Action == "get" {
+		return confdbstateLoadConfdbAsync(st, view, body.Keys, body.Constraints, userID)
+	}
+
+	tx, commitTxFunc, err := confdbstateGetTransactionToSet(nil, st, view, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := confdbstateSetViaView(tx, view, body.Values); err != nil {
+		return "", err
+	}
+
+	changeID, _, err := commitTxFunc()
+	return changeID, err
+}
+
+// BuildResponse implements MessageHandler. Responses to reads carry the
+// values read through the view.
+func (h *confdbHandler) BuildResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	body, status := changeResponse(chg)
+	if status != asserts.MessageStatusSuccess || chg.Kind() != getConfdbChangeKind {
+		return body, status
+	}
+
+	var apiData map[string]any
+	if err := chg.Get("api-data", &apiData); err != nil && !errors.Is(err, state.ErrNoState) {
+		body["message"] = fmt.Sprintf("cannot read confdb values: %v", err)
+		return body, asserts.MessageStatusError
+	}
+
+	if errData, ok := apiData["error"].(map[string]any); ok {
+		body["message"] = errData["message"]
+		return body, asserts.MessageStatusError
+	}
+	body["values"] = apiData["values"]
+
+	return body, asserts.MessageStatusSuccess
+}
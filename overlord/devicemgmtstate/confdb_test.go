@@ -0,0 +1,308 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicemgmtstate_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicemgmtstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *handlersSuite) mockConfdb(c *C) *confdb.Schema {
+	_, confOption := features.Confdb.ConfigOption()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", confOption, true), IsNil)
+	tr.Commit()
+
+	views := map[string]any{
+		"wifi-setup": map[string]any{
+			"rules": []any{
+				map[string]any{"request": "ssid", "storage": "wifi.ssid"},
+				map[string]any{"request": "ssids", "storage": "wifi.ssids"},
+			},
+		},
+	}
+	schema, err := confdb.NewSchema("my-brand", "network", views, confdb.NewJSONSchema())
+	c.Assert(err, IsNil)
+
+	s.AddCleanup(devicemgmtstate.MockConfdbstateGetView(func(st *state.State, account, schemaName, viewName string) (*confdb.View, error) {
+		c.Check(account, Equals, "my-brand")
+		c.Check(schemaName, Equals, "network")
+		view := schema.View(viewName)
+		if view == nil {
+			return nil, errors.New("no such view")
+		}
+		return view, nil
+	}))
+
+	return schema
+}
+
+func (s *handlersSuite) mockConfdbControl(c *C, groups ...any) {
+	s.AddCleanup(devicemgmtstate.MockConfdbControlForDevice(func(st *state.State, serial *asserts.Serial) (*asserts.ConfdbControl, error) {
+		if len(groups) == 0 {
+			return nil, &asserts.NotFoundError{Type: asserts.ConfdbControlType}
+		}
+		a, err := asserts.SignWithoutAuthority(asserts.ConfdbControlType, map[string]any{
+			"brand-id": "my-brand",
+			"model":    "my-model",
+			"serial":   "serial-1",
+			"groups":   groups,
+		}, nil, s.devKey)
+		c.Assert(err, IsNil)
+		return a.(*asserts.ConfdbControl), nil
+	}))
+}
+
+func (s *handlersSuite) TestConfdbValidateErrors(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+
+	for body, expectedErr := range map[string]string{
+		`{"action": "get", "view": "network/wifi-setup"}`:                                                                     `cannot access confdb: account not specified`,
+		`{"action": "get", "account": "my-brand", "view": "network"}`:                                                         `cannot access confdb: view "network" must be in the format confdb-schema/view`,
+		`{"action": "get", "account": "my-brand", "view": "network/Wifi"}`:                                                    `cannot access confdb: view "network/Wifi" must be in the format confdb-schema/view`,
+		`{"action": "delete", "account": "my-brand", "view": "network/wifi-setup"}`:                                           `cannot access confdb: unknown action "delete"`,
+		`{"action": "get", "account": "my-brand", "view": "network/wifi-setup", "values": {"a": 1}}`:                          `cannot get confdb: values cannot be specified`,
+		`{"action": "get", "account": "my-brand", "view": "network/wifi-setup", "constraints": {"a": null}}`:                  `cannot get confdb: constraint "a" must be a non-null scalar`,
+		`{"action": "set", "account": "my-brand", "view": "network/wifi-setup"}`:                                              `cannot set confdb: no values specified`,
+		`{"action": "set", "account": "my-brand", "view": "network/wifi-setup", "keys": ["ssid"], "values": {"ssid": "foo"}}`: `cannot set confdb: keys and constraints cannot be specified`,
+		`{"action": "get", "account": "my-brand", "view": "network/other"}`:                                                   `no such view`,
+		`{"action": "set", "account": "my-brand", "view": "network/wifi-setup", "values": {"password": "foo"}}`:               `cannot set "password" through my-brand/network/wifi-setup: no matching rule`,
+		`{"action": "get", "account": "my-brand", "view": "network/wifi-setup", "constraints": {"a": "b"}}`:                   `cannot get my-brand/network/wifi-setup: no placeholder for constraint "a"`,
+	} {
+		err := s.handlers["confdb"].Validate(s.st, s.message("confdb", body))
+		c.Check(err, ErrorMatches, expectedErr, Commentf(body))
+	}
+}
+
+func (s *handlersSuite) TestConfdbValidateFeatureDisabled(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup"}`)
+	err := s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `cannot access confdb: feature flag "confdb" is disabled`)
+}
+
+func (s *handlersSuite) TestConfdbValidateOK(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup", "keys": ["ssid"]}`)
+	c.Check(s.handlers["confdb"].Validate(s.st, msg), IsNil)
+
+	msg = s.message("confdb", `{"action": "set", "account": "my-brand", "view": "network/wifi-setup", "values": {"ssid": "foo"}}`)
+	c.Check(s.handlers["confdb"].Validate(s.st, msg), IsNil)
+
+	// validation does not change the confdb
+	tx, err := confdbstate.NewTransaction(s.st, "my-brand", "network")
+	c.Assert(err, IsNil)
+	c.Check(tx.AlteredPaths(), HasLen, 0)
+	var databags map[string]any
+	c.Check(s.st.Get("confdb-databags", &databags), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *handlersSuite) TestConfdbValidateDelegatedOperator(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+	s.mockConfdbControl(c, map[string]any{
+		"operators":       []any{"operator"},
+		"authentications": []any{"store"},
+		"views":           []any{"my-brand/network/wifi-setup"},
+	})
+
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup"}`)
+	msg.AccountID = "operator"
+	msg.SignedBy = "canonical"
+	c.Check(s.handlers["confdb"].Validate(s.st, msg), IsNil)
+
+	// only messages signed by the store on behalf of the operator are accepted
+	msg.AuthorityID = "operator"
+	msg.SignedBy = "operator"
+	err := s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `unauthorized: view my-brand/network/wifi-setup is not delegated to account "operator"`)
+	var unauthorized *devicemgmtstate.UnauthorizedError
+	c.Check(errors.As(err, &unauthorized), Equals, true)
+
+	msg.AccountID = "other"
+	msg.AuthorityID = "my-brand"
+	msg.SignedBy = "canonical"
+	err = s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `unauthorized: view my-brand/network/wifi-setup is not delegated to account "other"`)
+}
+
+func (s *handlersSuite) TestConfdbValidateAuthenticationFromSigner(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+	s.mockConfdbControl(c, map[string]any{
+		"operators":       []any{"operator"},
+		"authentications": []any{"operator-key"},
+		"views":           []any{"my-brand/network/wifi-setup"},
+	})
+
+	// claims to be signed by the operator but the store signed it
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup"}`)
+	msg.AccountID = "operator"
+	msg.AuthorityID = "operator"
+	msg.SignedBy = "canonical"
+	err := s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `unauthorized: view my-brand/network/wifi-setup is not delegated to account "operator"`)
+
+	// messages whose signature was not verified are never accepted
+	msg.SignedBy = ""
+	err = s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `internal error: signature of message someId was not verified`)
+
+	msg.SignedBy = "operator"
+	c.Check(s.handlers["confdb"].Validate(s.st, msg), IsNil)
+}
+
+func (s *handlersSuite) TestConfdbValidateNoConfdbControl(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+	s.mockConfdbControl(c)
+
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup"}`)
+	msg.AccountID = "operator"
+	err := s.handlers["confdb"].Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `unauthorized: view my-brand/network/wifi-setup is not delegated to account "operator"`)
+}
+
+func (s *handlersSuite) TestConfdbApplyGet(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+
+	var chg *state.Change
+	s.AddCleanup(devicemgmtstate.MockConfdbstateLoadConfdbAsync(func(st *state.State, view *confdb.View, requests []string, constraints map[string]any, userID int) (string, error) {
+		c.Check(view.ID(), Equals, "my-brand/network/wifi-setup")
+		c.Check(requests, DeepEquals, []string{"ssid"})
+		c.Check(constraints, DeepEquals, map[string]any{"foo": "bar"})
+		c.Check(userID, Equals, 0)
+
+		chg = st.NewChange("get-confdb", "...")
+		chg.Set("api-data", map[string]any{"values": map[string]any{"ssid": "my-wifi"}})
+		chg.SetStatus(state.DoneStatus)
+		return chg.ID(), nil
+	}))
+
+	msg := s.message("confdb", `{"action": "get", "account": "my-brand", "view": "network/wifi-setup", "keys": ["ssid"], "constraints": {"foo": "bar"}}`)
+	changeID, err := s.handlers["confdb"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+	c.Assert(chg, NotNil)
+	c.Check(changeID, Equals, chg.ID())
+
+	body, status := s.handlers["confdb"].BuildResponse(chg)
+	c.Check(status, Equals, asserts.MessageStatusSuccess)
+	c.Check(body, DeepEquals, map[string]any{
+		"change-id": chg.ID(),
+		"kind":      "get-confdb",
+		"status":    "Done",
+		"values":    map[string]any{"ssid": "my-wifi"},
+	})
+}
+
+func (s *handlersSuite) TestConfdbBuildResponseNoData(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg := s.st.NewChange("get-confdb", "...")
+	chg.Set("api-data", map[string]any{
+		"error": map[string]any{
+			"message": `cannot get "ssid" through my-brand/network/wifi-setup: no data`,
+			"kind":    "option-not-found",
+		},
+	})
+	chg.SetStatus(state.DoneStatus)
+
+	body, status := s.handlers["confdb"].BuildResponse(chg)
+	c.Check(status, Equals, asserts.MessageStatusError)
+	c.Check(body["message"], Equals, `cannot get "ssid" through my-brand/network/wifi-setup: no data`)
+	c.Check(body["values"], IsNil)
+}
+
+func (s *handlersSuite) TestConfdbApplySet(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+
+	tx, err := confdbstate.NewTransaction(s.st, "my-brand", "network")
+	c.Assert(err, IsNil)
+
+	var committed bool
+	s.AddCleanup(devicemgmtstate.MockConfdbstateGetTransactionToSet(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		c.Check(ctx, IsNil)
+		c.Check(view.ID(), Equals, "my-brand/network/wifi-setup")
+		return tx, func() (string, <-chan struct{}, error) {
+			committed = true
+			return "42", nil, nil
+		}, nil
+	}))
+
+	msg := s.message("confdb", `{"action": "set", "account": "my-brand", "view": "network/wifi-setup", "values": {"ssid": "my-wifi", "ssids": null}}`)
+	changeID, err := s.handlers["confdb"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+	c.Check(changeID, Equals, "42")
+	c.Check(committed, Equals, true)
+
+	path, err := confdb.ParsePathIntoAccessors("wifi.ssid", confdb.ParseOptions{})
+	c.Assert(err, IsNil)
+	val, err := tx.Get(path, nil)
+	c.Assert(err, IsNil)
+	c.Check(val, Equals, "my-wifi")
+}
+
+func (s *handlersSuite) TestConfdbApplySetError(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockConfdb(c)
+
+	s.AddCleanup(devicemgmtstate.MockConfdbstateGetTransactionToSet(func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		return nil, nil, errors.New("cannot write confdb through view my-brand/network/wifi-setup: ongoing transaction")
+	}))
+
+	msg := s.message("confdb", `{"action": "set", "account": "my-brand", "view": "network/wifi-setup", "values": {"ssid": "my-wifi"}}`)
+	_, err := s.handlers["confdb"].Apply(s.st, msg)
+	c.Assert(err, ErrorMatches, "cannot write confdb through view my-brand/network/wifi-setup: ongoing transaction")
+}
@@ -25,13 +25,16 @@
 package devicemgmtstate
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -45,6 +48,9 @@ const (
 
 	defaultExchangeLimit    = 10
 	defaultExchangeInterval = 6 * time.Hour
+
+	// how often to check whether the change applying a message completed
+	defaultResponseRetryInterval = time.Minute
 )
 
 var (
@@ -85,7 +91,22 @@ type RequestMessage struct {
 	ValidUntil  time.Time `json:"valid-until"`
 	Body        string    `json:"body"`
 
+	// Assertion is the encoded request-message assertion, its signature
+	// is verified before the message is processed.
+	Assertion string `json:"assertion"`
+	// SignedBy is the account whose key signed the message, it is only
+	// set once the signature was verified.
+	SignedBy string `json:"signed-by,omitempty"`
+
 	ReceiveTime time.Time `json:"receive-time"`
+
+	// ChangeID is the change created by the subsystem handler to apply
+	// the message.
+	ChangeID string `json:"change-id,omitempty"`
+	// Error and ErrorStatus are set when the message could not be
+	// validated or applied, the response is then built from them.
+	Error       string                `json:"error,omitempty"`
+	ErrorStatus asserts.MessageStatus `json:"error-status,omitempty"`
 }
 
 // ID returns the full message identifier `BaseID[-SeqNum]`.
@@ -166,6 +187,10 @@ func Manager(state *state.State, runner *state.TaskRunner, signer ResponseMessag
 	runner.AddHandler("apply-mgmt-message", m.doApplyMessage, nil)
 	runner.AddHandler("queue-mgmt-response", m.doQueueResponse, nil)
 
+	for kind, handler := range builtinHandlers() {
+		m.handlers[kind] = handler
+	}
+
 	return m
 }
 
@@ -301,29 +326,342 @@ func (m *DeviceMgmtManager) doExchangeMessages(t *state.Task, tomb *tomb.Tomb) e
 
 // doDispatchMessages selects pending requests for processing and queues tasks for them.
 func (m *DeviceMgmtManager) doDispatchMessages(t *state.Task, _ *tomb.Tomb) error {
-	// TODO: implement this task, no-op for now.
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	ms, err := m.getState()
+	if err != nil {
+		return err
+	}
+
+	msgs := make([]*RequestMessage, 0, len(ms.PendingRequests))
+	for _, msg := range ms.PendingRequests {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].ReceiveTime.Equal(msgs[j].ReceiveTime) {
+			return msgs[i].ReceiveTime.Before(msgs[j].ReceiveTime)
+		}
+		return msgs[i].ID() < msgs[j].ID()
+	})
+
+	chg := t.Change()
+	for _, msg := range msgs {
+		validate := m.state.NewTask("validate-mgmt-message", fmt.Sprintf("Validate message %s", msg.ID()))
+		validate.Set("message-id", msg.ID())
+		validate.WaitFor(t)
+		chg.AddTask(validate)
+
+		apply := m.state.NewTask("apply-mgmt-message", fmt.Sprintf("Apply message %s", msg.ID()))
+		apply.Set("message-id", msg.ID())
+		apply.WaitFor(validate)
+		chg.AddTask(apply)
+
+		queue := m.state.NewTask("queue-mgmt-response", fmt.Sprintf("Queue response to message %s", msg.ID()))
+		queue.Set("message-id", msg.ID())
+		queue.WaitFor(apply)
+		chg.AddTask(queue)
+	}
+
 	return nil
 }
 
+// pendingMessage returns the pending request the task operates on, or nil if
+// it was already responded to.
+// Caller must hold state lock.
+func (m *DeviceMgmtManager) pendingMessage(t *state.Task) (*deviceMgmtState, *RequestMessage, error) {
+	var id string
+	if err := t.Get("message-id", &id); err != nil {
+		return nil, nil, err
+	}
+
+	ms, err := m.getState()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ms, ms.PendingRequests[id], nil
+}
+
 // doValidateMessage performs snapd-level and subsystem-level validation on a message.
 func (m *DeviceMgmtManager) doValidateMessage(t *state.Task, _ *tomb.Tomb) error {
-	// TODO: implement this task, no-op for now.
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	ms, msg, err := m.pendingMessage(t)
+	if err != nil {
+		return err
+	}
+	if msg == nil || msg.ChangeID != "" || msg.Error != "" {
+		return nil
+	}
+
+	now := timeNow()
+	if now.Before(msg.ValidSince) {
+		return &state.Retry{After: msg.ValidSince.Sub(now)}
+	}
+
+	err = m.validateMessage(msg, now)
+	if err != nil {
+		msg.Error = err.Error()
+		msg.ErrorStatus = asserts.MessageStatusRejected
+		var unauthorized *UnauthorizedError
+		if errors.As(err, &unauthorized) {
+			msg.ErrorStatus = asserts.MessageStatusUnauthorized
+		}
+	}
+	m.setState(ms)
+
+	return nil
+}
+
+func (m *DeviceMgmtManager) validateMessage(msg *RequestMessage, now time.Time) error {
+	if !now.Before(msg.ValidUntil) {
+		return fmt.Errorf("message expired at %s", msg.ValidUntil.Format(time.RFC3339))
+	}
+
+	if err := checkSignature(m.state, msg); err != nil {
+		return err
+	}
+
+	handler, ok := m.handlers[msg.Kind]
+	if !ok {
+		return fmt.Errorf("unsupported message kind %q", msg.Kind)
+	}
+
+	return handler.Validate(m.state, msg)
+}
+
+// checkSignature verifies the signature of the request-message against the
+// assertion database and checks that it was signed either by the account
+// itself or by the store on its behalf. Handlers can then rely on the
+// account-id of the message.
+// Caller must hold state lock.
+func checkSignature(st *state.State, msg *RequestMessage) error {
+	a, err := asserts.Decode([]byte(msg.Assertion))
+	if err != nil {
+		return fmt.Errorf("cannot decode request-message: %v", err)
+	}
+	reqAs, ok := a.(*asserts.RequestMessage)
+	if !ok || reqAs.AccountID() != msg.AccountID || reqAs.AuthorityID() != msg.AuthorityID || reqAs.ID() != msg.BaseID || reqAs.SeqNum() != msg.SeqNum {
+		return fmt.Errorf("internal error: request-message does not match message %s", msg.ID())
+	}
+
+	db := assertstate.DB(st)
+	if err := db.Check(reqAs); err != nil {
+		return &UnauthorizedError{Reason: fmt.Sprintf("cannot verify signature: %v", err)}
+	}
+
+	if reqAs.AuthorityID() != reqAs.AccountID() && !db.IsTrustedAccount(reqAs.AuthorityID()) {
+		return &UnauthorizedError{Reason: fmt.Sprintf("message for account %q cannot be signed by %q", reqAs.AccountID(), reqAs.AuthorityID())}
+	}
+	msg.SignedBy = reqAs.AuthorityID()
+
 	return nil
 }
 
 // doApplyMessage dispatches the message to its subsystem handler for processing.
 func (m *DeviceMgmtManager) doApplyMessage(t *state.Task, _ *tomb.Tomb) error {
-	// TODO: implement this task, no-op for now.
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	ms, msg, err := m.pendingMessage(t)
+	if err != nil {
+		return err
+	}
+	if msg == nil || msg.ChangeID != "" || msg.Error != "" {
+		return nil
+	}
+
+	handler, ok := m.handlers[msg.Kind]
+	if !ok {
+		return fmt.Errorf("internal error: no handler for message kind %q", msg.Kind)
+	}
+
+	changeID, err := handler.Apply(m.state, msg)
+	if err != nil {
+		msg.Error = err.Error()
+		msg.ErrorStatus = asserts.MessageStatusError
+	} else {
+		msg.ChangeID = changeID
+		m.state.EnsureBefore(0)
+	}
+	m.setState(ms)
+
 	return nil
 }
 
 // doQueueResponse builds a response, signs it, and queues it for transmission on the next exchange.
 // Retries until subsystem change completes.
 func (m *DeviceMgmtManager) doQueueResponse(t *state.Task, _ *tomb.Tomb) error {
-	// TODO: implement this task, no-op for now.
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	ms, msg, err := m.pendingMessage(t)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return nil
+	}
+
+	var body map[string]any
+	var status asserts.MessageStatus
+	switch {
+	case msg.Error != "":
+		body = map[string]any{"message": msg.Error}
+		status = msg.ErrorStatus
+	default:
+		chg := m.state.Change(msg.ChangeID)
+		if chg == nil {
+			body = map[string]any{"message": fmt.Sprintf("change %s not found", msg.ChangeID)}
+			status = asserts.MessageStatusError
+			break
+		}
+		if !chg.IsReady() {
+			return &state.Retry{After: defaultResponseRetryInterval}
+		}
+		body, status = m.handlers[msg.Kind].BuildResponse(chg)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	if m.signer == nil {
+		return fmt.Errorf("internal error: cannot sign response-message without a signer")
+	}
+	resp, err := m.signer.SignResponseMessage(msg.AccountID, msg.ID(), status, data)
+	if err != nil {
+		return err
+	}
+
+	ms.ReadyResponses[msg.ID()] = store.Message{
+		Format: "assertion",
+		Data:   string(asserts.Encode(resp)),
+	}
+	delete(ms.PendingRequests, msg.ID())
+	m.setState(ms)
+
 	return nil
 }
 
+// InjectRequestMessage queues an encoded request-message assertion as if it
+// had been received from the store and schedules a change processing it.
+// This allows exercising remote management without access to the store
+// messaging service.
+// Caller must hold state lock.
+func (m *DeviceMgmtManager) InjectRequestMessage(encoded []byte) (*RequestMessage, *state.Change, error) {
+	msg, err := parseRequestMessage(store.Message{Format: "assertion", Data: string(encoded)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot inject request-message: %v", err)
+	}
+
+	for _, chg := range m.state.Changes() {
+		if chg.Kind() == deviceMgmtExchangeChangeKind && !chg.Status().Ready() {
+			return nil, nil, fmt.Errorf("cannot inject request-message: device management change %s in progress", chg.ID())
+		}
+	}
+
+	ms, err := m.getState()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := ms.PendingRequests[msg.ID()]; ok {
+		return nil, nil, fmt.Errorf("cannot inject request-message: message %s is already pending", msg.ID())
+	}
+	ms.PendingRequests[msg.ID()] = msg
+	m.setState(ms)
+
+	chg := m.state.NewChange(deviceMgmtExchangeChangeKind, "Process injected device management message")
+	dispatch := m.state.NewTask("dispatch-mgmt-messages", "Dispatch message(s) to subsystems")
+	chg.AddTask(dispatch)
+
+	return msg, chg, nil
+}
+
+// PendingInfo describes a request-message still being processed.
+type PendingInfo struct {
+	MessageID   string                `json:"message-id"`
+	AccountID   string                `json:"account-id"`
+	Kind        string                `json:"kind"`
+	ReceiveTime time.Time             `json:"receive-time"`
+	ChangeID    string                `json:"change-id,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	ErrorStatus asserts.MessageStatus `json:"error-status,omitempty"`
+}
+
+// ResponseInfo describes a response-message queued for the next exchange.
+type ResponseInfo struct {
+	MessageID string                `json:"message-id"`
+	Status    asserts.MessageStatus `json:"status"`
+}
+
+// MessagesInfo describes the messages handled by the device management
+// manager. The bodies of the messages are not included as they can carry
+// secrets, like the values read or written by confdb messages.
+type MessagesInfo struct {
+	LastExchangeTime  time.Time `json:"last-exchange-time,omitempty"`
+	LastReceivedToken string    `json:"last-received-token,omitempty"`
+
+	// Pending are the request-messages still being processed.
+	Pending []*PendingInfo `json:"pending"`
+	// Responses are the responses to processed request-messages waiting to
+	// be sent in the next exchange.
+	Responses []*ResponseInfo `json:"responses"`
+}
+
+// MessagesInfo returns the pending request-messages and queued
+// response-messages, sorted by message ID.
+// Caller must hold state lock.
+func (m *DeviceMgmtManager) MessagesInfo() (*MessagesInfo, error) {
+	ms, err := m.getState()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MessagesInfo{
+		LastExchangeTime:  ms.LastExchangeTime,
+		LastReceivedToken: ms.LastReceivedToken,
+		Pending:           make([]*PendingInfo, 0, len(ms.PendingRequests)),
+		Responses:         make([]*ResponseInfo, 0, len(ms.ReadyResponses)),
+	}
+
+	for _, msg := range ms.PendingRequests {
+		info.Pending = append(info.Pending, &PendingInfo{
+			MessageID:   msg.ID(),
+			AccountID:   msg.AccountID,
+			Kind:        msg.Kind,
+			ReceiveTime: msg.ReceiveTime,
+			ChangeID:    msg.ChangeID,
+			Error:       msg.Error,
+			ErrorStatus: msg.ErrorStatus,
+		})
+	}
+	sort.Slice(info.Pending, func(i, j int) bool { return info.Pending[i].MessageID < info.Pending[j].MessageID })
+
+	for id, msg := range ms.ReadyResponses {
+		a, err := asserts.Decode([]byte(msg.Data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode response to message %s: %v", id, err)
+		}
+		resp, ok := a.(*asserts.ResponseMessage)
+		if !ok {
+			return nil, fmt.Errorf("cannot decode response to message %s: unexpected %q assertion", id, a.Type().Name)
+		}
+
+		info.Responses = append(info.Responses, &ResponseInfo{
+			MessageID: id,
+			Status:    resp.Status(),
+		})
+	}
+	sort.Slice(info.Responses, func(i, j int) bool { return info.Responses[i].MessageID < info.Responses[j].MessageID })
+
+	return info, nil
+}
+
 // parseRequestMessage decodes a store message body into a RequestMessage.
 func parseRequestMessage(msg store.Message) (*RequestMessage, error) {
 	if msg.Format != "assertion" {
@@ -356,6 +694,7 @@ func parseRequestMessage(msg store.Message) (*RequestMessage, error) {
 		ValidSince:  reqAs.ValidSince(),
 		ValidUntil:  reqAs.ValidUntil(),
 		Body:        string(reqAs.Body()),
+		Assertion:   msg.Data,
 		ReceiveTime: timeNow(),
 	}, nil
 }
@@ -22,6 +22,7 @@ package devicemgmtstate_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicemgmtstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -71,6 +73,7 @@ type deviceMgmtMgrSuite struct {
 	st         *state.State
 	o          *overlord.Overlord
 	storeStack *assertstest.StoreStack
+	accounts   *assertstest.SigningAccounts
 	mgr        *devicemgmtstate.DeviceMgmtManager
 	logbuf     *bytes.Buffer
 }
@@ -89,7 +92,21 @@ func (s *deviceMgmtMgrSuite) SetUpTest(c *C) {
 	s.st.Lock()
 	defer s.st.Unlock()
 
-	s.storeStack = assertstest.NewStoreStack("my-brand", nil)
+	s.storeStack = assertstest.NewStoreStack("canonical", nil)
+	s.accounts = assertstest.NewSigningAccounts(s.storeStack)
+	brandKey, _ := assertstest.GenerateKey(752)
+	s.accounts.Register("my-brand", brandKey, nil)
+	otherKey, _ := assertstest.GenerateKey(752)
+	s.accounts.Register("other-brand", otherKey, nil)
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeStack.Trusted,
+	})
+	c.Assert(err, IsNil)
+	assertstest.AddMany(db, s.storeStack.StoreAccountKey(""))
+	assertstest.AddMany(db, s.accounts.AccountsAndKeys("my-brand", "other-brand")...)
+	assertstate.ReplaceDB(s.st, db)
 
 	runner := s.o.TaskRunner()
 	s.o.AddManager(runner)
@@ -97,7 +114,7 @@ func (s *deviceMgmtMgrSuite) SetUpTest(c *C) {
 	s.mgr = devicemgmtstate.Manager(s.st, runner, nil)
 	s.o.AddManager(s.mgr)
 
-	err := s.o.StartUp()
+	err = s.o.StartUp()
 	c.Assert(err, IsNil)
 
 	var restoreLogger func()
@@ -292,7 +309,7 @@ func (s *deviceMgmtMgrSuite) TestDoExchangeMessagesFetchOK(c *C) {
 		tomorrow := oneHourAgo.Add(24 * time.Hour)
 
 		body := []byte(`{"action": "get", "account": "my-brand", "view": "network/access-wifi"}`)
-		as, err := s.storeStack.Sign(
+		as, err := s.accounts.Signing("my-brand").Sign(
 			asserts.RequestMessageType,
 			map[string]any{
 				"authority-id": "my-brand",
@@ -508,3 +525,640 @@ func (s *deviceMgmtMgrSuite) TestParseRequestMessageInvalid(c *C) {
 		c.Check(msg, IsNil, cmt)
 	}
 }
+
+type mockHandler struct {
+	validateErr error
+	applyErr    error
+	changeID    string
+
+	validated []string
+	applied   []string
+}
+
+func (h *mockHandler) Validate(st *state.State, msg *devicemgmtstate.RequestMessage) error {
+	h.validated = append(h.validated, msg.ID())
+	return h.validateErr
+}
+
+func (h *mockHandler) Apply(st *state.State, msg *devicemgmtstate.RequestMessage) (string, error) {
+	h.applied = append(h.applied, msg.ID())
+	return h.changeID, h.applyErr
+}
+
+func (h *mockHandler) BuildResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	return map[string]any{"change-id": chg.ID()}, asserts.MessageStatusSuccess
+}
+
+type signCall struct {
+	accountID string
+	messageID string
+	status    asserts.MessageStatus
+	body      string
+}
+
+type mockSigner struct {
+	key   asserts.PrivateKey
+	calls []signCall
+}
+
+func (s *mockSigner) SignResponseMessage(accountID, messageID string, status asserts.MessageStatus, body []byte) (*asserts.ResponseMessage, error) {
+	s.calls = append(s.calls, signCall{accountID, messageID, status, string(body)})
+
+	a, err := asserts.SignWithoutAuthority(asserts.ResponseMessageType, map[string]any{
+		"account-id": accountID,
+		"message-id": messageID,
+		"device":     "serial-1.my-model.my-brand",
+		"status":     string(status),
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+	}, body, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.(*asserts.ResponseMessage), nil
+}
+
+func (s *deviceMgmtMgrSuite) mockSigner() *mockSigner {
+	key, _ := assertstest.GenerateKey(752)
+	signer := &mockSigner{key: key}
+	s.mgr.MockSigner(signer)
+	return signer
+}
+
+func (s *deviceMgmtMgrSuite) addPendingRequest(c *C, id string, received time.Time) *devicemgmtstate.RequestMessage {
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+
+	msg := &devicemgmtstate.RequestMessage{
+		AccountID:   "my-brand",
+		AuthorityID: "my-brand",
+		BaseID:      id,
+		Kind:        "test-kind",
+		Devices:     []string{"serial-1.my-model.my-brand"},
+		ValidSince:  time.Now().Add(-time.Hour),
+		ValidUntil:  time.Now().Add(time.Hour),
+		Body:        "{}",
+		Assertion:   string(s.signRequestMessage(c, id, "test-kind", "{}")),
+		ReceiveTime: received,
+	}
+	ms.PendingRequests[id] = msg
+	s.mgr.SetState(ms)
+
+	return msg
+}
+
+func (s *deviceMgmtMgrSuite) signRequestMessage(c *C, id, kind, body string) []byte {
+	return s.signRequestMessageWith(c, s.accounts.Signing("my-brand"), map[string]any{
+		"message-id":   id,
+		"message-kind": kind,
+	}, body)
+}
+
+func (s *deviceMgmtMgrSuite) signRequestMessageWith(c *C, signDB assertstest.SignerDB, extra map[string]any, body string) []byte {
+	oneHourAgo := time.Now().Add(-1 * time.Hour)
+	tomorrow := oneHourAgo.Add(24 * time.Hour)
+
+	headers := map[string]any{
+		"authority-id": "my-brand",
+		"account-id":   "my-brand",
+		"message-id":   "someId",
+		"message-kind": "test-kind",
+		"devices":      []any{"serial-1.my-model.my-brand"},
+		"valid-since":  oneHourAgo.UTC().Format(time.RFC3339),
+		"valid-until":  tomorrow.UTC().Format(time.RFC3339),
+		"timestamp":    oneHourAgo.UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+
+	as, err := signDB.Sign(asserts.RequestMessageType, headers, []byte(body), "")
+	c.Assert(err, IsNil)
+
+	return asserts.Encode(as)
+}
+
+func (s *deviceMgmtMgrSuite) messageTask(id, kind string) *state.Task {
+	chg := s.st.NewChange("device-management-exchange", "test")
+	t := s.st.NewTask(kind, "test "+kind+" task")
+	t.Set("message-id", id)
+	chg.AddTask(t)
+	return t
+}
+
+func (s *deviceMgmtMgrSuite) pendingRequest(c *C, id string) *devicemgmtstate.RequestMessage {
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	return ms.PendingRequests[id]
+}
+
+func (s *deviceMgmtMgrSuite) TestDoDispatchMessages(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	now := time.Now()
+	s.addPendingRequest(c, "later", now)
+	s.addPendingRequest(c, "earlier", now.Add(-time.Minute))
+
+	chg := s.st.NewChange("device-management-exchange", "test")
+	dispatch := s.st.NewTask("dispatch-mgmt-messages", "test dispatch-mgmt-messages task")
+	chg.AddTask(dispatch)
+
+	s.st.Unlock()
+	err := s.mgr.DoDispatchMessages(dispatch, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 7)
+
+	var prev *state.Task = dispatch
+	i := 1
+	for _, id := range []string{"earlier", "later"} {
+		for _, kind := range []string{"validate-mgmt-message", "apply-mgmt-message", "queue-mgmt-response"} {
+			t := tasks[i]
+			c.Check(t.Kind(), Equals, kind)
+			var msgID string
+			c.Assert(t.Get("message-id", &msgID), IsNil)
+			c.Check(msgID, Equals, id)
+			if kind == "validate-mgmt-message" {
+				c.Check(t.WaitTasks(), DeepEquals, []*state.Task{dispatch})
+			} else {
+				c.Check(t.WaitTasks(), DeepEquals, []*state.Task{prev})
+			}
+			prev = t
+			i++
+		}
+	}
+}
+
+func (s *deviceMgmtMgrSuite) TestDoValidateMessageOK(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handler := &mockHandler{}
+	s.mgr.MockHandler("test-kind", handler)
+	s.addPendingRequest(c, "someId", time.Now())
+
+	t := s.messageTask("someId", "validate-mgmt-message")
+
+	s.st.Unlock()
+	err := s.mgr.DoValidateMessage(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(handler.validated, DeepEquals, []string{"someId"})
+	msg := s.pendingRequest(c, "someId")
+	c.Check(msg.Error, Equals, "")
+	c.Check(msg.ErrorStatus, Equals, asserts.MessageStatus(""))
+}
+
+func (s *deviceMgmtMgrSuite) TestDoValidateMessageErrors(c *C) {
+	type test struct {
+		kind        string
+		validUntil  time.Time
+		validateErr error
+		status      asserts.MessageStatus
+		err         string
+	}
+
+	tests := []test{
+		{
+			kind:       "test-kind",
+			validUntil: time.Now().Add(-time.Minute),
+			status:     asserts.MessageStatusRejected,
+			err:        "message expired at .*",
+		},
+		{
+			kind:       "other-kind",
+			validUntil: time.Now().Add(time.Hour),
+			status:     asserts.MessageStatusRejected,
+			err:        `unsupported message kind "other-kind"`,
+		},
+		{
+			kind:        "test-kind",
+			validUntil:  time.Now().Add(time.Hour),
+			validateErr: errors.New("invalid body"),
+			status:      asserts.MessageStatusRejected,
+			err:         "invalid body",
+		},
+		{
+			kind:        "test-kind",
+			validUntil:  time.Now().Add(time.Hour),
+			validateErr: &devicemgmtstate.UnauthorizedError{Reason: "not for this device"},
+			status:      asserts.MessageStatusUnauthorized,
+			err:         "unauthorized: not for this device",
+		},
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for i, tc := range tests {
+		cmt := Commentf("test %d", i)
+
+		s.mgr.MockHandler("test-kind", &mockHandler{validateErr: tc.validateErr})
+		id := fmt.Sprintf("someId%d", i)
+		msg := s.addPendingRequest(c, id, time.Now())
+		msg.Kind = tc.kind
+		msg.ValidUntil = tc.validUntil
+		ms, err := s.mgr.GetState()
+		c.Assert(err, IsNil)
+		ms.PendingRequests[id] = msg
+		s.mgr.SetState(ms)
+
+		t := s.messageTask(id, "validate-mgmt-message")
+
+		s.st.Unlock()
+		err = s.mgr.DoValidateMessage(t, &tomb.Tomb{})
+		s.st.Lock()
+		c.Assert(err, IsNil, cmt)
+
+		msg = s.pendingRequest(c, id)
+		c.Check(msg.ErrorStatus, Equals, tc.status, cmt)
+		c.Check(msg.Error, Matches, tc.err, cmt)
+	}
+}
+
+func (s *deviceMgmtMgrSuite) TestDoValidateMessageNotYetValid(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handler := &mockHandler{}
+	s.mgr.MockHandler("test-kind", handler)
+	msg := s.addPendingRequest(c, "someId", time.Now())
+
+	now := msg.ValidSince.Add(-time.Minute)
+	restore := devicemgmtstate.MockTimeNow(now)
+	defer restore()
+
+	t := s.messageTask("someId", "validate-mgmt-message")
+
+	s.st.Unlock()
+	err := s.mgr.DoValidateMessage(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, FitsTypeOf, &state.Retry{})
+	c.Check(err.(*state.Retry).After, Equals, time.Minute)
+	c.Check(handler.validated, HasLen, 0)
+}
+
+func (s *deviceMgmtMgrSuite) TestDoValidateMessageSignature(c *C) {
+	unknownKey, _ := assertstest.GenerateKey(752)
+	signed := s.signRequestMessage(c, "someId", "test-kind", `{"a":1}`)
+	sigSep := bytes.LastIndex(signed, []byte("\n\n"))
+	unsigned := append(append([]byte(nil), signed[:sigSep+2]...), "AXNpZw=="...)
+	tampered := bytes.Replace(signed, []byte(`{"a":1}`), []byte(`{"a":2}`), 1)
+
+	type test struct {
+		assertion []byte
+		signedBy  string
+		status    asserts.MessageStatus
+		err       string
+	}
+
+	tests := []test{
+		{
+			// signed by the account itself
+			assertion: s.signRequestMessage(c, "someId", "test-kind", "{}"),
+			signedBy:  "my-brand",
+		},
+		{
+			// signed by the store on behalf of the account
+			assertion: s.signRequestMessageWith(c, s.storeStack, map[string]any{"authority-id": "canonical"}, "{}"),
+			signedBy:  "canonical",
+		},
+		{
+			// not properly signed
+			assertion: unsigned,
+			status:    asserts.MessageStatusUnauthorized,
+			err:       `unauthorized: cannot verify signature: cannot decode signature: .*`,
+		},
+		{
+			// modified after signing
+			assertion: tampered,
+			status:    asserts.MessageStatusUnauthorized,
+			err:       `unauthorized: cannot verify signature: failed signature verification: .*`,
+		},
+		{
+			// signed with a key not registered for the account
+			assertion: s.signRequestMessageWith(c, assertstest.NewSigningDB("my-brand", unknownKey), nil, "{}"),
+			status:    asserts.MessageStatusUnauthorized,
+			err:       `unauthorized: cannot verify signature: no matching public key .* for signature by "my-brand"`,
+		},
+		{
+			// signed by another account
+			assertion: s.signRequestMessageWith(c, s.accounts.Signing("other-brand"), map[string]any{"authority-id": "other-brand"}, "{}"),
+			status:    asserts.MessageStatusUnauthorized,
+			err:       `unauthorized: message for account "my-brand" cannot be signed by "other-brand"`,
+		},
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for i, tc := range tests {
+		cmt := Commentf("test %d", i)
+
+		handler := &mockHandler{}
+		s.mgr.MockHandler("test-kind", handler)
+
+		msg, err := devicemgmtstate.ParseRequestMessage(store.Message{Format: "assertion", Data: string(tc.assertion)})
+		c.Assert(err, IsNil, cmt)
+		ms, err := s.mgr.GetState()
+		c.Assert(err, IsNil)
+		ms.PendingRequests[msg.ID()] = msg
+		s.mgr.SetState(ms)
+
+		t := s.messageTask(msg.ID(), "validate-mgmt-message")
+
+		s.st.Unlock()
+		err = s.mgr.DoValidateMessage(t, &tomb.Tomb{})
+		s.st.Lock()
+		c.Assert(err, IsNil, cmt)
+
+		msg = s.pendingRequest(c, msg.ID())
+		c.Check(msg.ErrorStatus, Equals, tc.status, cmt)
+		c.Check(msg.Error, Matches, tc.err, cmt)
+		c.Check(msg.SignedBy, Equals, tc.signedBy, cmt)
+		if tc.err == "" {
+			c.Check(handler.validated, DeepEquals, []string{"someId"}, cmt)
+		} else {
+			// the handler never sees messages that cannot be verified
+			c.Check(handler.validated, HasLen, 0, cmt)
+		}
+
+		delete(ms.PendingRequests, msg.ID())
+		s.mgr.SetState(ms)
+	}
+}
+
+func (s *deviceMgmtMgrSuite) TestDoApplyMessageOK(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handler := &mockHandler{changeID: "42"}
+	s.mgr.MockHandler("test-kind", handler)
+	s.addPendingRequest(c, "someId", time.Now())
+
+	t := s.messageTask("someId", "apply-mgmt-message")
+
+	s.st.Unlock()
+	err := s.mgr.DoApplyMessage(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(handler.applied, DeepEquals, []string{"someId"})
+	msg := s.pendingRequest(c, "someId")
+	c.Check(msg.ChangeID, Equals, "42")
+	c.Check(msg.Error, Equals, "")
+}
+
+func (s *deviceMgmtMgrSuite) TestDoApplyMessageError(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handler := &mockHandler{applyErr: errors.New("snap not installed")}
+	s.mgr.MockHandler("test-kind", handler)
+	s.addPendingRequest(c, "someId", time.Now())
+
+	t := s.messageTask("someId", "apply-mgmt-message")
+
+	s.st.Unlock()
+	err := s.mgr.DoApplyMessage(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	msg := s.pendingRequest(c, "someId")
+	c.Check(msg.ChangeID, Equals, "")
+	c.Check(msg.Error, Equals, "snap not installed")
+	c.Check(msg.ErrorStatus, Equals, asserts.MessageStatusError)
+}
+
+func (s *deviceMgmtMgrSuite) TestDoApplyMessageSkipsFailedValidation(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	handler := &mockHandler{changeID: "42"}
+	s.mgr.MockHandler("test-kind", handler)
+	msg := s.addPendingRequest(c, "someId", time.Now())
+	msg.Error = "invalid body"
+	msg.ErrorStatus = asserts.MessageStatusRejected
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	ms.PendingRequests["someId"] = msg
+	s.mgr.SetState(ms)
+
+	t := s.messageTask("someId", "apply-mgmt-message")
+
+	s.st.Unlock()
+	err = s.mgr.DoApplyMessage(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(handler.applied, HasLen, 0)
+}
+
+func (s *deviceMgmtMgrSuite) TestDoQueueResponseChangeDone(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	signer := s.mockSigner()
+	s.mgr.MockHandler("test-kind", &mockHandler{})
+
+	chg := s.st.NewChange("install-snap", "test")
+	task := s.st.NewTask("foo", "test")
+	chg.AddTask(task)
+
+	msg := s.addPendingRequest(c, "someId", time.Now())
+	msg.ChangeID = chg.ID()
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	ms.PendingRequests["someId"] = msg
+	s.mgr.SetState(ms)
+
+	t := s.messageTask("someId", "queue-mgmt-response")
+
+	s.st.Unlock()
+	err = s.mgr.DoQueueResponse(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, FitsTypeOf, &state.Retry{})
+	c.Check(signer.calls, HasLen, 0)
+
+	task.SetStatus(state.DoneStatus)
+
+	s.st.Unlock()
+	err = s.mgr.DoQueueResponse(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(signer.calls, DeepEquals, []signCall{{
+		accountID: "my-brand",
+		messageID: "someId",
+		status:    asserts.MessageStatusSuccess,
+		body:      fmt.Sprintf(`{"change-id":"%s"}`, chg.ID()),
+	}})
+
+	ms, err = s.mgr.GetState()
+	c.Assert(err, IsNil)
+	c.Check(ms.PendingRequests, HasLen, 0)
+	c.Assert(ms.ReadyResponses, HasLen, 1)
+
+	resp := ms.ReadyResponses["someId"]
+	c.Check(resp.Format, Equals, "assertion")
+	a, err := asserts.Decode([]byte(resp.Data))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.ResponseMessageType)
+	c.Check(a.HeaderString("message-id"), Equals, "someId")
+	c.Check(a.HeaderString("status"), Equals, "success")
+}
+
+func (s *deviceMgmtMgrSuite) TestDoQueueResponseError(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	signer := s.mockSigner()
+	s.mgr.MockHandler("test-kind", &mockHandler{})
+
+	msg := s.addPendingRequest(c, "someId", time.Now())
+	msg.Error = "unauthorized: not for this device"
+	msg.ErrorStatus = asserts.MessageStatusUnauthorized
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	ms.PendingRequests["someId"] = msg
+	s.mgr.SetState(ms)
+
+	t := s.messageTask("someId", "queue-mgmt-response")
+
+	s.st.Unlock()
+	err = s.mgr.DoQueueResponse(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(signer.calls, DeepEquals, []signCall{{
+		accountID: "my-brand",
+		messageID: "someId",
+		status:    asserts.MessageStatusUnauthorized,
+		body:      `{"message":"unauthorized: not for this device"}`,
+	}})
+
+	ms, err = s.mgr.GetState()
+	c.Assert(err, IsNil)
+	c.Check(ms.PendingRequests, HasLen, 0)
+	c.Check(ms.ReadyResponses, HasLen, 1)
+}
+
+func (s *deviceMgmtMgrSuite) TestDoQueueResponseNoSigner(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	msg := s.addPendingRequest(c, "someId", time.Now())
+	msg.Error = "invalid body"
+	msg.ErrorStatus = asserts.MessageStatusRejected
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	ms.PendingRequests["someId"] = msg
+	s.mgr.SetState(ms)
+
+	t := s.messageTask("someId", "queue-mgmt-response")
+
+	s.st.Unlock()
+	err = s.mgr.DoQueueResponse(t, &tomb.Tomb{})
+	s.st.Lock()
+	c.Assert(err, ErrorMatches, "internal error: cannot sign response-message without a signer")
+
+	ms, err = s.mgr.GetState()
+	c.Assert(err, IsNil)
+	c.Check(ms.PendingRequests, HasLen, 1)
+}
+
+func (s *deviceMgmtMgrSuite) TestInjectRequestMessage(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	encoded := s.signRequestMessage(c, "someId", "test-kind", `{"foo": "bar"}`)
+	msg, chg, err := s.mgr.InjectRequestMessage(encoded)
+	c.Assert(err, IsNil)
+	c.Check(msg.ID(), Equals, "someId")
+	c.Check(msg.Kind, Equals, "test-kind")
+	c.Check(msg.Body, Equals, `{"foo": "bar"}`)
+
+	c.Check(chg.Kind(), Equals, "device-management-exchange")
+	c.Check(chg.Summary(), Equals, "Process injected device management message")
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "dispatch-mgmt-messages")
+
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	c.Assert(ms.PendingRequests, HasLen, 1)
+	c.Check(ms.PendingRequests["someId"].ID(), Equals, "someId")
+	c.Check(ms.PendingRequests["someId"].Kind, Equals, "test-kind")
+
+	// the same message cannot be injected twice
+	chg.SetStatus(state.DoneStatus)
+	_, _, err = s.mgr.InjectRequestMessage(encoded)
+	c.Check(err, ErrorMatches, "cannot inject request-message: message someId is already pending")
+}
+
+func (s *deviceMgmtMgrSuite) TestInjectRequestMessageErrors(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	_, _, err := s.mgr.InjectRequestMessage([]byte("not-an-assertion"))
+	c.Check(err, ErrorMatches, "cannot inject request-message: cannot decode assertion: .*")
+
+	chg := s.st.NewChange("device-management-exchange", "test")
+	chg.AddTask(s.st.NewTask("exchange-mgmt-messages", "test"))
+
+	encoded := s.signRequestMessage(c, "someId", "test-kind", `{}`)
+	_, _, err = s.mgr.InjectRequestMessage(encoded)
+	c.Check(err, ErrorMatches, fmt.Sprintf("cannot inject request-message: device management change %s in progress", chg.ID()))
+
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	c.Check(ms.PendingRequests, HasLen, 0)
+}
+
+func (s *deviceMgmtMgrSuite) TestMessagesInfo(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	signer := s.mockSigner()
+	resp, err := signer.SignResponseMessage("my-brand", "otherId", asserts.MessageStatusRejected, []byte(`{"message":"invalid body"}`))
+	c.Assert(err, IsNil)
+
+	lastExchange := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	received := time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC)
+	msgB := s.addPendingRequest(c, "msgB", received)
+	msgA := s.addPendingRequest(c, "msgA", received)
+	ms, err := s.mgr.GetState()
+	c.Assert(err, IsNil)
+	ms.LastExchangeTime = lastExchange
+	ms.LastReceivedToken = "token-123"
+	ms.ReadyResponses["otherId"] = store.Message{
+		Format: "assertion",
+		Data:   string(asserts.Encode(resp)),
+	}
+	s.mgr.SetState(ms)
+
+	info, err := s.mgr.MessagesInfo()
+	c.Assert(err, IsNil)
+	c.Check(info.LastExchangeTime.Equal(lastExchange), Equals, true)
+	c.Check(info.LastReceivedToken, Equals, "token-123")
+	c.Check(info.Pending, DeepEquals, []*devicemgmtstate.PendingInfo{{
+		MessageID:   msgA.ID(),
+		AccountID:   "my-brand",
+		Kind:        "test-kind",
+		ReceiveTime: received,
+	}, {
+		MessageID:   msgB.ID(),
+		AccountID:   "my-brand",
+		Kind:        "test-kind",
+		ReceiveTime: received,
+	}})
+	// the bodies of the messages are never exposed
+	c.Check(info.Responses, DeepEquals, []*devicemgmtstate.ResponseInfo{{
+		MessageID: "otherId",
+		Status:    asserts.MessageStatusRejected,
+	}})
+}
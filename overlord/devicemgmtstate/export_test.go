@@ -20,9 +20,18 @@
 package devicemgmtstate
 
 import (
+	"context"
 	"time"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/osutil/user"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/testutil"
 	"gopkg.in/tomb.v2"
@@ -33,6 +42,8 @@ var (
 
 	DefaultExchangeLimit    = defaultExchangeLimit
 	DefaultExchangeInterval = defaultExchangeInterval
+
+	BuiltinHandlers = builtinHandlers
 )
 
 type DeviceMgmtState deviceMgmtState
@@ -89,3 +100,51 @@ func MockTimeNow(t time.Time) func() {
 
 	return testutil.Mock(&timeNow, f)
 }
+
+func MockDevicestateSerial(f func(st *state.State) (*asserts.Serial, error)) func() {
+	return testutil.Mock(&devicestateSerial, f)
+}
+
+func MockSnapstateInstallWithGoal(f func(ctx context.Context, st *state.State, goal snapstate.InstallGoal, opts snapstate.Options) ([]*snap.Info, []*state.TaskSet, error)) func() {
+	return testutil.Mock(&snapstateInstallWithGoal, f)
+}
+
+func MockSnapstateUpdateWithGoal(f func(ctx context.Context, st *state.State, goal snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error)) func() {
+	return testutil.Mock(&snapstateUpdateWithGoal, f)
+}
+
+func MockSnapstateRemoveMany(f func(st *state.State, names []string, flags *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error)) func() {
+	return testutil.Mock(&snapstateRemoveMany, f)
+}
+
+func MockSnapstateRevert(f func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error)) func() {
+	return testutil.Mock(&snapstateRevert, f)
+}
+
+func MockSnapstateRevertToRevision(f func(st *state.State, name string, rev snap.Revision, flags snapstate.Flags, fromChange string) (*state.TaskSet, error)) func() {
+	return testutil.Mock(&snapstateRevertToRevision, f)
+}
+
+func MockServicestateControl(f func(st *state.State, appInfos []*snap.AppInfo, inst *servicestate.Instruction, cu *user.User, flags *servicestate.Flags, context *hookstate.Context) ([]*state.TaskSet, error)) func() {
+	return testutil.Mock(&servicestateControl, f)
+}
+
+func MockConfigstateConfigureInstalled(f func(st *state.State, snapName string, patch map[string]any, flags int) (*state.TaskSet, error)) func() {
+	return testutil.Mock(&configstateConfigureInstalled, f)
+}
+
+func MockConfdbstateGetView(f func(st *state.State, account, schemaName, viewName string) (*confdb.View, error)) func() {
+	return testutil.Mock(&confdbstateGetView, f)
+}
+
+func MockConfdbstateLoadConfdbAsync(f func(st *state.State, view *confdb.View, requests []string, constraints map[string]any, userID int) (string, error)) func() {
+	return testutil.Mock(&confdbstateLoadConfdbAsync, f)
+}
+
+func MockConfdbstateGetTransactionToSet(f func(ctx *hookstate.Context, st *state.State, view *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error)) func() {
+	return testutil.Mock(&confdbstateGetTransactionToSet, f)
+}
+
+func MockConfdbControlForDevice(f func(st *state.State, serial *asserts.Serial) (*asserts.ConfdbControl, error)) func() {
+	return testutil.Mock(&confdbControlForDevice, f)
+}
@@ -0,0 +1,599 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicemgmtstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	devicestateSerial = devicestate.Serial
+
+	snapstateInstallWithGoal  = snapstate.InstallWithGoal
+	snapstateUpdateWithGoal   = snapstate.UpdateWithGoal
+	snapstateRemoveMany       = snapstate.RemoveMany
+	snapstateRevert           = snapstate.Revert
+	snapstateRevertToRevision = snapstate.RevertToRevision
+
+	servicestateControl = servicestate.Control
+
+	configstateConfigureInstalled = configstate.ConfigureInstalled
+)
+
+var (
+	installChangeKind   = swfeats.RegisterChangeKind("install-snap")
+	refreshChangeKind   = swfeats.RegisterChangeKind("refresh-snap")
+	removeChangeKind    = swfeats.RegisterChangeKind("remove-snap")
+	revertChangeKind    = swfeats.RegisterChangeKind("revert-snap")
+	serviceChangeKind   = swfeats.RegisterChangeKind("service-control")
+	configureChangeKind = swfeats.RegisterChangeKind("configure-snap")
+)
+
+// UnauthorizedError is returned by message handlers when the sender of a
+// request-message is not allowed to manage the device. Messages failing
+// validation with it are answered with an "unauthorized" status instead
+// of "rejected".
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("unauthorized: %s", e.Reason)
+}
+
+// builtinHandlers returns the message handlers snapd provides for managing
+// snaps, services and snap configuration, keyed by message kind.
+func builtinHandlers() map[string]MessageHandler {
+	return map[string]MessageHandler{
+		"snap-install":    &snapHandler{action: "install"},
+		"snap-refresh":    &snapHandler{action: "refresh"},
+		"snap-remove":     &snapHandler{action: "remove"},
+		"snap-revert":     &snapHandler{action: "revert"},
+		"service-start":   &serviceHandler{action: "start"},
+		"service-stop":    &serviceHandler{action: "stop"},
+		"service-restart": &serviceHandler{action: "restart"},
+		"snap-set":        &configHandler{},
+		"confdb":          &confdbHandler{},
+	}
+}
+
+// checkDeviceAuthority verifies that the message is addressed to this device
+// and that it was sent on behalf of the brand of its model or of the
+// authority that signed its serial.
+// Caller must hold state lock.
+func checkDeviceAuthority(st *state.State, msg *RequestMessage) error {
+	serial, err := checkAddressedToDevice(st, msg)
+	if err != nil {
+		return err
+	}
+
+	ok, err := isDeviceAuthority(st, serial, msg.AccountID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &UnauthorizedError{Reason: fmt.Sprintf("account %q cannot manage device %s", msg.AccountID, serial.DeviceID())}
+	}
+
+	return nil
+}
+
+// checkAddressedToDevice verifies that the message is addressed to this
+// device and returns the device serial.
+// Caller must hold state lock.
+func checkAddressedToDevice(st *state.State, msg *RequestMessage) (*asserts.Serial, error) {
+	serial, err := devicestateSerial(st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check message authority: %v", err)
+	}
+
+	deviceID := serial.DeviceID().String()
+	if !strutil.ListContains(msg.Devices, deviceID) {
+		return nil, &UnauthorizedError{Reason: fmt.Sprintf("message is not addressed to device %s", deviceID)}
+	}
+
+	return serial, nil
+}
+
+// isDeviceAuthority returns whether the account is the brand of the device
+// model or the authority that signed the device serial.
+// Caller must hold state lock.
+func isDeviceAuthority(st *state.State, serial *asserts.Serial, accountID string) (bool, error) {
+	deviceCtx, err := snapstate.DevicePastSeeding(st, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return accountID == deviceCtx.Model().BrandID() || accountID == serial.AuthorityID(), nil
+}
+
+// decodeBody strictly decodes the JSON body of a request-message.
+func decodeBody(msg *RequestMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewBufferString(msg.Body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("cannot decode %s message body: %v", msg.Kind, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("cannot decode %s message body: spurious content after body", msg.Kind)
+	}
+
+	return nil
+}
+
+// changeResponse builds a response body from a ready change created by one
+// of the built-in handlers.
+func changeResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	body := map[string]any{
+		"change-id": chg.ID(),
+		"kind":      chg.Kind(),
+		"status":    chg.Status().String(),
+	}
+
+	var snapNames []string
+	if err := chg.Get("snap-names", &snapNames); err == nil && len(snapNames) > 0 {
+		body["snap-names"] = snapNames
+	}
+
+	if chg.Status() == state.DoneStatus {
+		return body, asserts.MessageStatusSuccess
+	}
+
+	if err := chg.Err(); err != nil {
+		body["message"] = err.Error()
+	}
+
+	return body, asserts.MessageStatusError
+}
+
+func newChange(st *state.State, kind, summary string, tss []*state.TaskSet, snapNames []string) *state.Change {
+	chg := st.NewChange(kind, summary)
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	if snapNames != nil {
+		chg.Set("snap-names", snapNames)
+	}
+
+	return chg
+}
+
+func changeSummary(verb string, names []string) string {
+	if len(names) == 1 {
+		return fmt.Sprintf("%s %q snap", verb, names[0])
+	}
+
+	return fmt.Sprintf("%s snaps %s", verb, strutil.Quoted(names))
+}
+
+type snapRequest struct {
+	Name     string `json:"name"`
+	Channel  string `json:"channel,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+type snapMessageBody struct {
+	Snaps []snapRequest `json:"snaps"`
+}
+
+// snapHandler handles messages installing, refreshing, removing or
+// reverting snaps. Bodies have the form:
+//
+//	{"snaps": [{"name": "foo", "channel": "latest/stable", "revision": "10"}]}
+type snapHandler struct {
+	action string
+}
+
+func (h *snapHandler) parse(msg *RequestMessage) ([]snapRequest, error) {
+	var body snapMessageBody
+	if err := decodeBody(msg, &body); err != nil {
+		return nil, err
+	}
+
+	if len(body.Snaps) == 0 {
+		return nil, fmt.Errorf("cannot %s snaps: no snaps specified", h.action)
+	}
+
+	seen := make(map[string]bool, len(body.Snaps))
+	for i, req := range body.Snaps {
+		if err := snap.ValidateInstanceName(req.Name); err != nil {
+			return nil, err
+		}
+		if seen[req.Name] {
+			return nil, fmt.Errorf("cannot %s snaps: snap %q specified more than once", h.action, req.Name)
+		}
+		seen[req.Name] = true
+
+		if req.Channel != "" {
+			if h.action != "install" && h.action != "refresh" {
+				return nil, fmt.Errorf("cannot %s snap %q: channel cannot be specified", h.action, req.Name)
+			}
+			ch, err := channel.Full(req.Channel)
+			if err != nil {
+				return nil, fmt.Errorf("cannot %s snap %q: %v", h.action, req.Name, err)
+			}
+			body.Snaps[i].Channel = ch
+		}
+
+		if req.Revision != "" {
+			if h.action == "remove" {
+				return nil, fmt.Errorf("cannot %s snap %q: revision cannot be specified", h.action, req.Name)
+			}
+			rev, err := snap.ParseRevision(req.Revision)
+			if err != nil {
+				return nil, fmt.Errorf("cannot %s snap %q: %v", h.action, req.Name, err)
+			}
+			if h.action != "revert" && !rev.Store() {
+				return nil, fmt.Errorf("cannot %s snap %q: revision %s is not a store revision", h.action, req.Name, rev)
+			}
+		}
+	}
+
+	return body.Snaps, nil
+}
+
+// Validate implements MessageHandler.
+func (h *snapHandler) Validate(st *state.State, msg *RequestMessage) error {
+	if err := checkDeviceAuthority(st, msg); err != nil {
+		return err
+	}
+
+	_, err := h.parse(msg)
+	return err
+}
+
+// Apply implements MessageHandler.
+func (h *snapHandler) Apply(st *state.State, msg *RequestMessage) (string, error) {
+	reqs, err := h.parse(msg)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		names = append(names, req.Name)
+	}
+
+	var kind, summary string
+	var tss []*state.TaskSet
+	switch h.action {
+	case "install":
+		kind, summary = installChangeKind, changeSummary("Install", names)
+		tss, err = h.install(st, reqs)
+	case "refresh":
+		kind, summary = refreshChangeKind, changeSummary("Refresh", names)
+		names, tss, err = h.refresh(st, reqs)
+	case "remove":
+		kind, summary = removeChangeKind, changeSummary("Remove", names)
+		names, tss, err = snapstateRemoveMany(st, names, nil)
+	case "revert":
+		kind, summary = revertChangeKind, changeSummary("Revert", names)
+		tss, err = h.revert(st, reqs)
+	default:
+		return "", fmt.Errorf("internal error: unknown snap action %q", h.action)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	chg := newChange(st, kind, summary, tss, names)
+	return chg.ID(), nil
+}
+
+func (h *snapHandler) install(st *state.State, reqs []snapRequest) ([]*state.TaskSet, error) {
+	snaps := make([]snapstate.StoreSnap, 0, len(reqs))
+	for _, req := range reqs {
+		rev, err := snap.ParseRevision(optionalRevision(req.Revision))
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snapstate.StoreSnap{
+			InstanceName: req.Name,
+			RevOpts: snapstate.RevisionOptions{
+				Channel:  req.Channel,
+				Revision: rev,
+			},
+		})
+	}
+
+	_, tss, err := snapstateInstallWithGoal(context.TODO(), st, snapstate.StoreInstallGoal(snaps...), snapstate.Options{})
+	return tss, err
+}
+
+func (h *snapHandler) refresh(st *state.State, reqs []snapRequest) ([]string, []*state.TaskSet, error) {
+	updates := make([]snapstate.StoreUpdate, 0, len(reqs))
+	for _, req := range reqs {
+		rev, err := snap.ParseRevision(optionalRevision(req.Revision))
+		if err != nil {
+			return nil, nil, err
+		}
+		updates = append(updates, snapstate.StoreUpdate{
+			InstanceName: req.Name,
+			RevOpts: snapstate.RevisionOptions{
+				Channel:  req.Channel,
+				Revision: rev,
+			},
+		})
+	}
+
+	updated, uts, err := snapstateUpdateWithGoal(context.TODO(), st, snapstate.StoreUpdateGoal(updates...), nil, snapstate.Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(updated) == 0 {
+		return nil, nil, fmt.Errorf("no updates available")
+	}
+
+	return updated, uts.Refresh, nil
+}
+
+func (h *snapHandler) revert(st *state.State, reqs []snapRequest) ([]*state.TaskSet, error) {
+	tss := make([]*state.TaskSet, 0, len(reqs))
+	for _, req := range reqs {
+		var ts *state.TaskSet
+		var err error
+		if req.Revision == "" {
+			ts, err = snapstateRevert(st, req.Name, snapstate.Flags{}, "")
+		} else {
+			var rev snap.Revision
+			rev, err = snap.ParseRevision(req.Revision)
+			if err != nil {
+				return nil, err
+			}
+			ts, err = snapstateRevertToRevision(st, req.Name, rev, snapstate.Flags{}, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		tss = append(tss, ts)
+	}
+
+	return tss, nil
+}
+
+// optionalRevision maps an unset revision to the unset revision string
+// understood by snap.ParseRevision.
+func optionalRevision(rev string) string {
+	if rev == "" {
+		return "unset"
+	}
+
+	return rev
+}
+
+// BuildResponse implements MessageHandler.
+func (h *snapHandler) BuildResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	return changeResponse(chg)
+}
+
+type serviceMessageBody struct {
+	Services []string `json:"services"`
+	Enable   bool     `json:"enable,omitempty"`
+	Disable  bool     `json:"disable,omitempty"`
+	Reload   bool     `json:"reload,omitempty"`
+}
+
+// serviceHandler handles messages starting, stopping or restarting system
+// services of snaps. Services are given either as "snap" for all services
+// of a snap or as "snap.app". Bodies have the form:
+//
+//	{"services": ["foo", "bar.svc"], "enable": true}
+type serviceHandler struct {
+	action string
+}
+
+func (h *serviceHandler) parse(msg *RequestMessage) (*serviceMessageBody, error) {
+	var body serviceMessageBody
+	if err := decodeBody(msg, &body); err != nil {
+		return nil, err
+	}
+
+	if len(body.Services) == 0 {
+		return nil, fmt.Errorf("cannot %s services: no services specified", h.action)
+	}
+
+	for _, name := range body.Services {
+		snapName, _ := splitServiceName(name)
+		if err := snap.ValidateInstanceName(snapName); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case body.Enable && h.action != "start":
+		return nil, fmt.Errorf("cannot %s services: enable is only supported when starting services", h.action)
+	case body.Disable && h.action != "stop":
+		return nil, fmt.Errorf("cannot %s services: disable is only supported when stopping services", h.action)
+	case body.Reload && h.action != "restart":
+		return nil, fmt.Errorf("cannot %s services: reload is only supported when restarting services", h.action)
+	}
+
+	return &body, nil
+}
+
+// Validate implements MessageHandler.
+func (h *serviceHandler) Validate(st *state.State, msg *RequestMessage) error {
+	if err := checkDeviceAuthority(st, msg); err != nil {
+		return err
+	}
+
+	_, err := h.parse(msg)
+	return err
+}
+
+// Apply implements MessageHandler.
+func (h *serviceHandler) Apply(st *state.State, msg *RequestMessage) (string, error) {
+	body, err := h.parse(msg)
+	if err != nil {
+		return "", err
+	}
+
+	appInfos, err := serviceAppInfos(st, body.Services)
+	if err != nil {
+		return "", err
+	}
+
+	inst := &servicestate.Instruction{
+		Action:         h.action,
+		Names:          body.Services,
+		Scope:          client.ScopeSelector{"system"},
+		StartOptions:   client.StartOptions{Enable: body.Enable},
+		StopOptions:    client.StopOptions{Disable: body.Disable},
+		RestartOptions: client.RestartOptions{Reload: body.Reload},
+	}
+	tss, err := servicestateControl(st, appInfos, inst, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	snapNames := make([]string, 0, len(appInfos))
+	for _, app := range appInfos {
+		snapNames = append(snapNames, app.Snap.InstanceName())
+	}
+	snapNames = strutil.Deduplicate(snapNames)
+	sort.Strings(snapNames)
+
+	chg := newChange(st, serviceChangeKind, "Running service command", tss, snapNames)
+	return chg.ID(), nil
+}
+
+// BuildResponse implements MessageHandler.
+func (h *serviceHandler) BuildResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	return changeResponse(chg)
+}
+
+func splitServiceName(name string) (snapName, appName string) {
+	if idx := strings.IndexByte(name, '.'); idx > -1 {
+		return name[:idx], name[idx+1:]
+	}
+
+	return name, ""
+}
+
+// serviceAppInfos resolves the requested services to the service apps of
+// the currently installed snaps.
+// Caller must hold state lock.
+func serviceAppInfos(st *state.State, names []string) ([]*snap.AppInfo, error) {
+	var appInfos []*snap.AppInfo
+	seen := make(map[string]bool)
+	for _, name := range names {
+		snapName, appName := splitServiceName(name)
+		info, err := snapstate.CurrentInfo(st, snapName)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, app := range info.Services() {
+			if appName != "" && app.Name != appName {
+				continue
+			}
+			found = true
+			if key := snapName + "." + app.Name; !seen[key] {
+				seen[key] = true
+				appInfos = append(appInfos, app)
+			}
+		}
+		if !found {
+			if appName == "" {
+				return nil, fmt.Errorf("snap %q has no services", snapName)
+			}
+			return nil, fmt.Errorf("snap %q has no service %q", snapName, appName)
+		}
+	}
+
+	return appInfos, nil
+}
+
+type configMessageBody struct {
+	Snap   string         `json:"snap"`
+	Config map[string]any `json:"config"`
+}
+
+// configHandler handles messages setting the configuration of a snap, like
+// "snap set" does. Bodies have the form:
+//
+//	{"snap": "foo", "config": {"key": "value", "other.key": null}}
+type configHandler struct{}
+
+func (h *configHandler) parse(msg *RequestMessage) (*configMessageBody, error) {
+	var body configMessageBody
+	if err := decodeBody(msg, &body); err != nil {
+		return nil, err
+	}
+
+	if err := snap.ValidateInstanceName(body.Snap); err != nil {
+		return nil, err
+	}
+	if len(body.Config) == 0 {
+		return nil, fmt.Errorf("cannot set configuration of snap %q: no configuration specified", body.Snap)
+	}
+
+	return &body, nil
+}
+
+// Validate implements MessageHandler.
+func (h *configHandler) Validate(st *state.State, msg *RequestMessage) error {
+	if err := checkDeviceAuthority(st, msg); err != nil {
+		return err
+	}
+
+	_, err := h.parse(msg)
+	return err
+}
+
+// Apply implements MessageHandler.
+func (h *configHandler) Apply(st *state.State, msg *RequestMessage) (string, error) {
+	body, err := h.parse(msg)
+	if err != nil {
+		return "", err
+	}
+
+	ts, err := configstateConfigureInstalled(st, body.Snap, body.Config, 0)
+	if err != nil {
+		return "", err
+	}
+
+	summary := fmt.Sprintf("Change configuration of %q snap", body.Snap)
+	chg := newChange(st, configureChangeKind, summary, []*state.TaskSet{ts}, []string{body.Snap})
+	return chg.ID(), nil
+}
+
+// BuildResponse implements MessageHandler.
+func (h *configHandler) BuildResponse(chg *state.Change) (map[string]any, asserts.MessageStatus) {
+	return changeResponse(chg)
+}
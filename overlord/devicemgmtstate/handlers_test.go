@@ -0,0 +1,475 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicemgmtstate_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil/user"
+	"github.com/snapcore/snapd/overlord/devicemgmtstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type handlersSuite struct {
+	testutil.BaseTest
+
+	st       *state.State
+	handlers map[string]devicemgmtstate.MessageHandler
+	serial   *asserts.Serial
+	devKey   asserts.PrivateKey
+}
+
+var _ = Suite(&handlersSuite{})
+
+func (s *handlersSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.st = state.New(nil)
+	s.handlers = devicemgmtstate.BuiltinHandlers()
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	model := assertstest.FakeAssertion(map[string]any{
+		"type":         "model",
+		"authority-id": "my-brand",
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "krnl",
+	})
+	deviceCtx := &snapstatetest.TrivialDeviceContext{DeviceModel: model.(*asserts.Model)}
+	s.AddCleanup(snapstatetest.MockDeviceContext(deviceCtx))
+	s.st.Set("seeded", true)
+
+	storeStack := assertstest.NewStoreStack("canonical", nil)
+	s.devKey, _ = assertstest.GenerateKey(752)
+	encDevKey, err := asserts.EncodePublicKey(s.devKey.PublicKey())
+	c.Assert(err, IsNil)
+	serial, err := storeStack.Sign(asserts.SerialType, map[string]any{
+		"brand-id":            "my-brand",
+		"model":               "my-model",
+		"serial":              "serial-1",
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": s.devKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.serial = serial.(*asserts.Serial)
+
+	s.AddCleanup(devicemgmtstate.MockDevicestateSerial(func(st *state.State) (*asserts.Serial, error) {
+		return s.serial, nil
+	}))
+}
+
+func (s *handlersSuite) message(kind, body string) *devicemgmtstate.RequestMessage {
+	return &devicemgmtstate.RequestMessage{
+		AccountID:   "my-brand",
+		AuthorityID: "my-brand",
+		SignedBy:    "my-brand",
+		BaseID:      "someId",
+		Kind:        kind,
+		Devices:     []string{"serial-1.my-model.my-brand"},
+		ValidSince:  time.Now().Add(-time.Hour),
+		ValidUntil:  time.Now().Add(time.Hour),
+		Body:        body,
+	}
+}
+
+func (s *handlersSuite) TestBuiltinHandlerKinds(c *C) {
+	kinds := make([]string, 0, len(s.handlers))
+	for kind := range s.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	c.Check(kinds, testutil.DeepUnsortedMatches, []string{
+		"snap-install", "snap-refresh", "snap-remove", "snap-revert",
+		"service-start", "service-stop", "service-restart",
+		"snap-set",
+		"confdb",
+	})
+}
+
+func (s *handlersSuite) TestValidateAuthority(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	body := `{"snaps": [{"name": "foo"}]}`
+	handler := s.handlers["snap-install"]
+
+	msg := s.message("snap-install", body)
+	c.Check(handler.Validate(s.st, msg), IsNil)
+
+	// the authority of the serial may also manage the device
+	msg.AccountID = "canonical"
+	c.Check(handler.Validate(s.st, msg), IsNil)
+
+	msg.AccountID = "other-brand"
+	err := handler.Validate(s.st, msg)
+	c.Check(err, ErrorMatches, `unauthorized: account "other-brand" cannot manage device serial-1.my-model.my-brand`)
+	var unauthorized *devicemgmtstate.UnauthorizedError
+	c.Check(errors.As(err, &unauthorized), Equals, true)
+
+	msg = s.message("snap-install", body)
+	msg.Devices = []string{"serial-2.my-model.my-brand"}
+	err = handler.Validate(s.st, msg)
+	c.Check(err, ErrorMatches, "unauthorized: message is not addressed to device serial-1.my-model.my-brand")
+	c.Check(errors.As(err, &unauthorized), Equals, true)
+
+	s.AddCleanup(devicemgmtstate.MockDevicestateSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	}))
+	err = handler.Validate(s.st, s.message("snap-install", body))
+	c.Check(err, ErrorMatches, "cannot check message authority: no state entry for key")
+	c.Check(errors.As(err, &unauthorized), Equals, false)
+}
+
+func (s *handlersSuite) TestSnapValidateErrors(c *C) {
+	type test struct {
+		kind string
+		body string
+		err  string
+	}
+
+	tests := []test{
+		{"snap-install", `not json`, `cannot decode snap-install message body: invalid character .*`},
+		{"snap-install", `{"snaps": [{"name": "foo"}]} {}`, `cannot decode snap-install message body: spurious content after body`},
+		{"snap-install", `{"snaps": [{"name": "foo", "classic": true}]}`, `cannot decode snap-install message body: json: unknown field "classic"`},
+		{"snap-install", `{"snaps": []}`, `cannot install snaps: no snaps specified`},
+		{"snap-install", `{"snaps": [{"name": "Foo"}]}`, `invalid snap name: "Foo"`},
+		{"snap-refresh", `{"snaps": [{"name": "foo"}, {"name": "foo"}]}`, `cannot refresh snaps: snap "foo" specified more than once`},
+		{"snap-install", `{"snaps": [{"name": "foo", "channel": "a/b/c/d"}]}`, `cannot install snap "foo": invalid channel`},
+		{"snap-refresh", `{"snaps": [{"name": "foo", "revision": "latest"}]}`, `cannot refresh snap "foo": invalid snap revision: "latest"`},
+		{"snap-refresh", `{"snaps": [{"name": "foo", "revision": "x1"}]}`, `cannot refresh snap "foo": revision x1 is not a store revision`},
+		{"snap-remove", `{"snaps": [{"name": "foo", "channel": "stable"}]}`, `cannot remove snap "foo": channel cannot be specified`},
+		{"snap-revert", `{"snaps": [{"name": "foo", "channel": "stable"}]}`, `cannot revert snap "foo": channel cannot be specified`},
+		{"snap-remove", `{"snaps": [{"name": "foo", "revision": "1"}]}`, `cannot remove snap "foo": revision cannot be specified`},
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, tc := range tests {
+		err := s.handlers[tc.kind].Validate(s.st, s.message(tc.kind, tc.body))
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s: %s", tc.kind, tc.body))
+	}
+}
+
+func (s *handlersSuite) TestSnapInstallApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var called int
+	s.AddCleanup(devicemgmtstate.MockSnapstateInstallWithGoal(func(ctx context.Context, st *state.State, goal snapstate.InstallGoal, opts snapstate.Options) ([]*snap.Info, []*state.TaskSet, error) {
+		called++
+		return nil, []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-install", "..."))}, nil
+	}))
+
+	msg := s.message("snap-install", `{"snaps": [{"name": "foo", "channel": "beta"}, {"name": "bar", "revision": "7"}]}`)
+	changeID, err := s.handlers["snap-install"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+	c.Check(called, Equals, 1)
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "install-snap")
+	c.Check(chg.Summary(), Equals, `Install snaps "foo", "bar"`)
+	c.Check(chg.Tasks(), HasLen, 1)
+
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), IsNil)
+	c.Check(snapNames, DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *handlersSuite) TestSnapRefreshApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.AddCleanup(devicemgmtstate.MockSnapstateUpdateWithGoal(func(ctx context.Context, st *state.State, goal snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		ts := state.NewTaskSet(st.NewTask("fake-refresh", "..."))
+		return []string{"foo"}, &snapstate.UpdateTaskSets{Refresh: []*state.TaskSet{ts}}, nil
+	}))
+
+	msg := s.message("snap-refresh", `{"snaps": [{"name": "foo", "channel": "edge"}]}`)
+	changeID, err := s.handlers["snap-refresh"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "refresh-snap")
+	c.Check(chg.Summary(), Equals, `Refresh "foo" snap`)
+	c.Check(chg.Tasks(), HasLen, 1)
+}
+
+func (s *handlersSuite) TestSnapRefreshApplyNoUpdates(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.AddCleanup(devicemgmtstate.MockSnapstateUpdateWithGoal(func(ctx context.Context, st *state.State, goal snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		return nil, &snapstate.UpdateTaskSets{}, nil
+	}))
+
+	msg := s.message("snap-refresh", `{"snaps": [{"name": "foo"}]}`)
+	_, err := s.handlers["snap-refresh"].Apply(s.st, msg)
+	c.Assert(err, ErrorMatches, "no updates available")
+	c.Check(s.st.Changes(), HasLen, 0)
+}
+
+func (s *handlersSuite) TestSnapRemoveApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.AddCleanup(devicemgmtstate.MockSnapstateRemoveMany(func(st *state.State, names []string, flags *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, DeepEquals, []string{"foo"})
+		return names, []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-remove", "..."))}, nil
+	}))
+
+	msg := s.message("snap-remove", `{"snaps": [{"name": "foo"}]}`)
+	changeID, err := s.handlers["snap-remove"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "remove-snap")
+	c.Check(chg.Summary(), Equals, `Remove "foo" snap`)
+}
+
+func (s *handlersSuite) TestSnapRevertApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var reverted []string
+	s.AddCleanup(devicemgmtstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		reverted = append(reverted, name)
+		return state.NewTaskSet(st.NewTask("fake-revert", "...")), nil
+	}))
+	s.AddCleanup(devicemgmtstate.MockSnapstateRevertToRevision(func(st *state.State, name string, rev snap.Revision, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		reverted = append(reverted, name+"@"+rev.String())
+		return state.NewTaskSet(st.NewTask("fake-revert", "...")), nil
+	}))
+
+	msg := s.message("snap-revert", `{"snaps": [{"name": "foo"}, {"name": "bar", "revision": "3"}]}`)
+	changeID, err := s.handlers["snap-revert"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+	c.Check(reverted, DeepEquals, []string{"foo", "bar@3"})
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "revert-snap")
+	c.Check(chg.Tasks(), HasLen, 2)
+}
+
+func (s *handlersSuite) TestSnapApplyError(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.AddCleanup(devicemgmtstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		return nil, errors.New("no revision to revert to")
+	}))
+
+	msg := s.message("snap-revert", `{"snaps": [{"name": "foo"}]}`)
+	_, err := s.handlers["snap-revert"].Apply(s.st, msg)
+	c.Assert(err, ErrorMatches, "no revision to revert to")
+	c.Check(s.st.Changes(), HasLen, 0)
+}
+
+const servicesSnapYaml = `name: foo
+version: 1
+apps:
+  svc1:
+    daemon: simple
+  svc2:
+    daemon: simple
+  cmd:
+    command: bin/cmd
+`
+
+func (s *handlersSuite) mockServicesSnap(c *C) {
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(1)}
+	snaptest.MockSnap(c, servicesSnapYaml, si)
+	snapstate.Set(s.st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+func (s *handlersSuite) TestServiceApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockServicesSnap(c)
+
+	var apps []string
+	var instruction *servicestate.Instruction
+	s.AddCleanup(devicemgmtstate.MockServicestateControl(func(st *state.State, appInfos []*snap.AppInfo, inst *servicestate.Instruction, cu *user.User, flags *servicestate.Flags, context *hookstate.Context) ([]*state.TaskSet, error) {
+		for _, app := range appInfos {
+			apps = append(apps, app.Name)
+		}
+		instruction = inst
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("service-control", "..."))}, nil
+	}))
+
+	msg := s.message("service-start", `{"services": ["foo.svc2", "foo"], "enable": true}`)
+	changeID, err := s.handlers["service-start"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+
+	c.Check(apps, DeepEquals, []string{"svc2", "svc1"})
+	c.Check(instruction.Action, Equals, "start")
+	c.Check(instruction.Names, DeepEquals, []string{"foo.svc2", "foo"})
+	c.Check(instruction.Scope, DeepEquals, client.ScopeSelector{"system"})
+	c.Check(instruction.Enable, Equals, true)
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "service-control")
+	c.Check(chg.Summary(), Equals, "Running service command")
+
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), IsNil)
+	c.Check(snapNames, DeepEquals, []string{"foo"})
+}
+
+func (s *handlersSuite) TestServiceApplyUnknownService(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockServicesSnap(c)
+
+	msg := s.message("service-stop", `{"services": ["foo.cmd"]}`)
+	_, err := s.handlers["service-stop"].Apply(s.st, msg)
+	c.Check(err, ErrorMatches, `snap "foo" has no service "cmd"`)
+
+	msg = s.message("service-stop", `{"services": ["bar"]}`)
+	_, err = s.handlers["service-stop"].Apply(s.st, msg)
+	c.Check(err, ErrorMatches, `snap "bar" is not installed`)
+}
+
+func (s *handlersSuite) TestServiceValidateErrors(c *C) {
+	type test struct {
+		kind string
+		body string
+		err  string
+	}
+
+	tests := []test{
+		{"service-start", `{"services": []}`, `cannot start services: no services specified`},
+		{"service-start", `{"services": ["Foo.svc"]}`, `invalid snap name: "Foo"`},
+		{"service-start", `{"services": ["foo"], "disable": true}`, `cannot start services: disable is only supported when stopping services`},
+		{"service-stop", `{"services": ["foo"], "enable": true}`, `cannot stop services: enable is only supported when starting services`},
+		{"service-stop", `{"services": ["foo"], "reload": true}`, `cannot stop services: reload is only supported when restarting services`},
+		{"service-restart", `{"services": ["foo"], "users": "all"}`, `cannot decode service-restart message body: json: unknown field "users"`},
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, tc := range tests {
+		err := s.handlers[tc.kind].Validate(s.st, s.message(tc.kind, tc.body))
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s: %s", tc.kind, tc.body))
+	}
+}
+
+func (s *handlersSuite) TestConfigApply(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.AddCleanup(devicemgmtstate.MockConfigstateConfigureInstalled(func(st *state.State, snapName string, patch map[string]any, flags int) (*state.TaskSet, error) {
+		c.Check(snapName, Equals, "foo")
+		c.Check(patch, DeepEquals, map[string]any{"key": "value", "other.key": nil})
+		return state.NewTaskSet(st.NewTask("run-hook", "...")), nil
+	}))
+
+	msg := s.message("snap-set", `{"snap": "foo", "config": {"key": "value", "other.key": null}}`)
+	c.Assert(s.handlers["snap-set"].Validate(s.st, msg), IsNil)
+	changeID, err := s.handlers["snap-set"].Apply(s.st, msg)
+	c.Assert(err, IsNil)
+
+	chg := s.st.Change(changeID)
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "configure-snap")
+	c.Check(chg.Summary(), Equals, `Change configuration of "foo" snap`)
+}
+
+func (s *handlersSuite) TestConfigValidateErrors(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for body, expectedErr := range map[string]string{
+		`{"snap": "", "config": {"key": "value"}}`: `invalid snap name: ""`,
+		`{"snap": "foo"}`:                          `cannot set configuration of snap "foo": no configuration specified`,
+		`{"snap": "foo", "conf": {}}`:              `cannot decode snap-set message body: json: unknown field "conf"`,
+	} {
+		err := s.handlers["snap-set"].Validate(s.st, s.message("snap-set", body))
+		c.Check(err, ErrorMatches, expectedErr, Commentf(body))
+	}
+}
+
+func (s *handlersSuite) TestBuildResponse(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	chg := s.st.NewChange("install-snap", "Install \"foo\" snap")
+	chg.Set("snap-names", []string{"foo"})
+	t := s.st.NewTask("foo", "...")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+
+	body, status := s.handlers["snap-install"].BuildResponse(chg)
+	c.Check(status, Equals, asserts.MessageStatusSuccess)
+	c.Check(body, DeepEquals, map[string]any{
+		"change-id":  chg.ID(),
+		"kind":       "install-snap",
+		"status":     "Done",
+		"snap-names": []string{"foo"},
+	})
+
+	chg = s.st.NewChange("service-control", "Running service command")
+	t = s.st.NewTask("foo", "...")
+	chg.AddTask(t)
+	t.Errorf("service failed to start")
+	t.SetStatus(state.ErrorStatus)
+
+	body, status = s.handlers["service-start"].BuildResponse(chg)
+	c.Check(status, Equals, asserts.MessageStatusError)
+	c.Check(body["status"], Equals, "Error")
+	c.Check(body["message"], Matches, `(?s)cannot perform the following tasks:.*service failed to start.*`)
+}
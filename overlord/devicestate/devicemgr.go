@@ -257,6 +257,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	// Mark-preseeded touches and records the system-key, ensure that it does
 	// not run in parallel with other tasks touching the system-key
 	runner.AddHandler("mark-preseeded", m.doMarkPreseeded, nil)
+	runner.AddHandler("seed-confdb", m.doSeedConfdb, nil)
 	runner.AddHandler("mark-seeded", m.doMarkSeeded, nil)
 	runner.AddHandler("setup-ubuntu-save", m.doSetupUbuntuSave, nil)
 	runner.AddHandler("setup-run-system", m.doSetupRunSystem, nil)
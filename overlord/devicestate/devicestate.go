@@ -70,6 +70,8 @@ var (
 	snapstatePathUpdateGoal       = snapstate.PathUpdateGoal
 	snapstateInstallComponents    = snapstate.InstallComponents
 	snapstateInstallComponentPath = snapstate.InstallComponentPath
+
+	snapstateCheckMaintenanceWindow = snapstate.CheckMaintenanceWindow
 )
 
 var (
@@ -298,6 +300,17 @@ func delayedCrossMgrInit() {
 	snapstate.DeviceCtx = DeviceCtx
 	snapstate.RemodelingChange = RemodelingChange
 	snapstate.SeedRefreshTasks = SeedRefreshTasks
+	snapstate.DeviceSerial = deviceSerial
+}
+
+// deviceSerial returns the serial of the device, for the refresh rollout
+// policy.
+func deviceSerial(st *state.State) (string, error) {
+	serial, err := Serial(st)
+	if err != nil {
+		return "", err
+	}
+	return serial.Serial(), nil
 }
 
 // proxyStore returns the store assertion for the proxy store if one is set.
@@ -1584,6 +1597,12 @@ func Remodel(st *state.State, new *asserts.Model, opts RemodelOptions) (*state.C
 		return nil, fmt.Errorf("cannot remodel from core to bases yet")
 	}
 
+	if remodelRequiresReboot(current, new) {
+		if err := snapstateCheckMaintenanceWindow(st); err != nil {
+			return nil, fmt.Errorf("cannot remodel: %w", err)
+		}
+	}
+
 	// Do we do this only for the more complicated cases (anything
 	// more than adding required-snaps really)?
 	if err := snapstate.CheckChangeConflictRunExclusively(st, "remodel"); err != nil {
@@ -1676,6 +1695,23 @@ func Remodel(st *state.State, new *asserts.Model, opts RemodelOptions) (*state.C
 	return chg, nil
 }
 
+// remodelRequiresReboot returns whether remodeling from the current to the
+// new model requires a reboot of the device, which is the case for UC20+
+// models, as the new recovery system is tried, and for changes of the snaps
+// taking part in the boot.
+func remodelRequiresReboot(current, new *asserts.Model) bool {
+	if current.Classic() {
+		// reboots are left to the user on classic systems
+		return false
+	}
+	if new.Grade() != asserts.ModelGradeUnset {
+		return true
+	}
+	return current.Kernel() != new.Kernel() || current.KernelTrack() != new.KernelTrack() ||
+		current.Gadget() != new.Gadget() || current.GadgetTrack() != new.GadgetTrack() ||
+		current.Base() != new.Base()
+}
+
 // RemodelingChange returns a remodeling change in progress, if there is one
 func RemodelingChange(st *state.State) *state.Change {
 	for _, chg := range st.Changes() {
@@ -317,6 +317,86 @@ func (s *deviceMgrRemodelSuite) TestRemodelUnhappy(c *C) {
 	}
 }
 
+func (s *deviceMgrRemodelSuite) TestRemodelOutsideMaintenanceWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+
+	checks := 0
+	defer devicestate.MockSnapstateCheckMaintenanceWindow(func(st *state.State) error {
+		checks++
+		return fmt.Errorf("%w at 2026-10-15T02:00:00Z", snapstate.ErrOutsideMaintenanceWindow)
+	})()
+
+	// set a model assertion
+	cur := map[string]any{
+		"brand":        "canonical",
+		"model":        "pc-model",
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	}
+	s.makeModelAssertionInState(c, cur["brand"].(string), cur["model"].(string), map[string]any{
+		"architecture": cur["architecture"],
+		"kernel":       cur["kernel"],
+		"gadget":       cur["gadget"],
+	})
+	s.makeSerialAssertionInState(c, cur["brand"].(string), cur["model"].(string), "orig-serial")
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand:  cur["brand"].(string),
+		Model:  cur["model"].(string),
+		Serial: "orig-serial",
+	})
+
+	for _, t := range []map[string]any{
+		{"kernel": "other-kernel"},
+		{"kernel": "pc-kernel=18"},
+		{"gadget": "other-gadget"},
+	} {
+		mergeMockModelHeaders(cur, t)
+		new := s.brands.Model(t["brand"].(string), t["model"].(string), t)
+		chg, err := devicestate.Remodel(s.state, new, devicestate.RemodelOptions{})
+		c.Check(chg, IsNil)
+		c.Check(err, ErrorMatches, "cannot remodel: operations requiring a reboot are deferred until the next maintenance window at 2026-10-15T02:00:00Z")
+	}
+	c.Check(checks, Equals, 3)
+}
+
+func (s *deviceMgrRemodelSuite) TestRemodelRequiresReboot(c *C) {
+	uc16 := map[string]any{
+		"brand":        "canonical",
+		"model":        "pc-model",
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	}
+	classic := map[string]any{
+		"brand":        "canonical",
+		"model":        "pc-model",
+		"architecture": "amd64",
+		"classic":      "true",
+		"gadget":       "pc",
+	}
+	for _, t := range []struct {
+		current  map[string]any
+		new      map[string]any
+		required bool
+	}{
+		{uc16, map[string]any{"required-snaps": []any{"foo"}}, false},
+		{uc16, map[string]any{"kernel": "other-kernel"}, true},
+		{uc16, map[string]any{"kernel": "pc-kernel=18"}, true},
+		{uc16, map[string]any{"gadget": "pc=18"}, true},
+		{uc16, map[string]any{"base": "core18"}, true},
+		{classic, map[string]any{"gadget": "other-gadget"}, false},
+		{mockCore20ModelHeaders, map[string]any{"revision": "1"}, true},
+	} {
+		mergeMockModelHeaders(t.current, t.new)
+		current := s.brands.Model(t.current["brand"].(string), t.current["model"].(string), t.current)
+		new := s.brands.Model(t.new["brand"].(string), t.new["model"].(string), t.new)
+		c.Check(devicestate.RemodelRequiresReboot(current, new), Equals, t.required, Commentf("%v", t.new))
+	}
+}
+
 func (s *deviceMgrRemodelSuite) TestRemodelFromClassicUnhappy(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
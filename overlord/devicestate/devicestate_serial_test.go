@@ -102,6 +102,11 @@ func (s *deviceMgrSerialSuite) TestSerial(c *C) {
 	serial, err := devicestate.Serial(s.state)
 	c.Assert(err, IsNil)
 	c.Check(serial.Serial(), Equals, "8989")
+
+	// the serial is used by the refresh rollout policy
+	serialStr, err := snapstate.DeviceSerial(s.state)
+	c.Assert(err, IsNil)
+	c.Check(serialStr, Equals, "8989")
 }
 
 func (s *deviceMgrSerialSuite) signSerial(c *C, bhv *devicestatetest.DeviceServiceBehavior, headers map[string]any, body []byte) (serial asserts.Assertion, ancillary []asserts.Assertion, err error) {
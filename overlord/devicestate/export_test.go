@@ -49,6 +49,8 @@ import (
 )
 
 var (
+	RemodelRequiresReboot = remodelRequiresReboot
+
 	SystemForPreseeding         = systemForPreseeding
 	GetUserDetailsFromAssertion = getUserDetailsFromAssertion
 	ShouldRequestSerial         = shouldRequestSerial
@@ -172,6 +174,10 @@ func MockSnapstateUpdateOne(mock func(ctx context.Context, st *state.State, goal
 	return testutil.Mock(&snapstateUpdateOne, mock)
 }
 
+func MockSnapstateCheckMaintenanceWindow(mock func(st *state.State) error) (restore func()) {
+	return testutil.Mock(&snapstateCheckMaintenanceWindow, mock)
+}
+
 func MockSnapstateInstallOne(mock func(ctx context.Context, st *state.State, goal snapstate.InstallGoal, opts snapstate.Options) (*snap.Info, *state.TaskSet, error)) (restore func()) {
 	return testutil.Mock(&snapstateInstallOne, mock)
 }
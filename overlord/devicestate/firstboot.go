@@ -25,10 +25,12 @@ import (
 	"fmt"
 	"runtime"
 	"sort"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
@@ -38,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -144,6 +147,41 @@ func maybeEnforceValidationSetsTask(st *state.State, model *asserts.Model, mode
 	return t, nil
 }
 
+// maybeSeedConfdbTask returns a task for importing the initial confdb databags
+// provided by the gadget. This may return nil if the gadget provides none.
+func maybeSeedConfdbTask(st *state.State, gadgetSnap *seed.Snap, model *asserts.Model, mode string) (*state.Task, error) {
+	// Only seed confdb in run-mode, data would be lost otherwise
+	if mode != "run" {
+		logger.Debugf("Postponing seeding of confdb in mode %s", mode)
+		return nil, nil
+	}
+
+	snapf, err := snapfile.Open(gadgetSnap.Path)
+	if err != nil {
+		return nil, err
+	}
+	gadgetInfo, err := gadget.ReadInfoFromSnapFileNoValidate(snapf, model)
+	if err != nil {
+		return nil, err
+	}
+	if len(gadgetInfo.Confdb) == 0 {
+		return nil, nil
+	}
+
+	for ref := range gadgetInfo.Confdb {
+		// the model doesn't list confdb-schemas (yet), so only accept the
+		// ones of the model's brand
+		if account, _, _ := strings.Cut(ref, "/"); account != model.BrandID() {
+			return nil, fmt.Errorf("cannot seed confdb %s: gadget can only provide databags for confdb-schemas of the brand %q", ref, model.BrandID())
+		}
+	}
+
+	t := st.NewTask("seed-confdb", i18n.G("Import initial confdb databags from the gadget"))
+	t.Set("confdb-databags", gadgetInfo.Confdb)
+	t.Set("gadget", gadgetSnap.SnapName())
+	return t, nil
+}
+
 func markSeededTask(st *state.State) *state.Task {
 	return st.NewTask("mark-seeded", i18n.G("Mark system seeded"))
 }
@@ -310,7 +348,13 @@ func (m *DeviceManager) populateStateFromSeedImpl(tm timings.Measurer) ([]*state
 
 	modelIsDangerous := model.Grade() == asserts.ModelDangerous
 
+	// note, we use separate lanes for essential and non-essential snaps so that
+	// failures installing non-essential snaps do not cause essential snap
+	// installations to be undone.
 	essentialLane := st.NewLane()
+	nonEssentialLane := st.NewLane()
+
+	var seedConfdb *state.Task
 	for _, seedSnap := range essentialSeedSnaps {
 		flags := snapstate.Flags{
 			SkipConfigure: true,
@@ -336,6 +380,12 @@ func (m *DeviceManager) populateStateFromSeedImpl(tm timings.Measurer) ([]*state
 			// wait for the previous configTss
 			configTss = chainTs(configTss, configTs)
 		}
+		if info.Type() == snap.TypeGadget {
+			seedConfdb, err = maybeSeedConfdbTask(st, seedSnap, model, mode)
+			if err != nil {
+				return nil, err
+			}
+		}
 		infos = append(infos, info)
 		infoToTs[info] = ts
 	}
@@ -359,13 +409,19 @@ func (m *DeviceManager) populateStateFromSeedImpl(tm timings.Measurer) ([]*state
 		ts.JoinLane(essentialLane)
 	}
 
+	// import the gadget's initial confdb databags once the gadget is
+	// configured and before any non-essential snap is installed
+	if seedConfdb != nil {
+		seedConfdbTs := state.NewTaskSet(seedConfdb)
+		// like for validation-sets, only undo the non-essential lane if this
+		// fails as undoing the essential snaps doesn't work properly
+		seedConfdbTs.JoinLane(nonEssentialLane)
+		tsAll = chainTs(tsAll, seedConfdbTs)
+	}
+
 	// ensure we install in the right order
 	infoToTs = make(map[*snap.Info]*state.TaskSet, len(seedSnaps))
 
-	// note, we use separate lanes for essential and non-essential snaps so that
-	// failures installing non-essential snaps do not cause essential snap
-	// installations to be undone.
-	nonEssentialLane := st.NewLane()
 	for _, seedSnap := range seedSnaps {
 		flags := snapstate.Flags{
 			// for dangerous models, allow all devmode snaps
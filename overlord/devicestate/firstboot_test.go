@@ -1123,6 +1123,221 @@ snaps:
 	c.Check(seeded, Equals, true)
 }
 
+func (s *firstBoot16BaseTest) makeConfdbSchema(c *C, accountID, name string) asserts.Assertion {
+	headers := map[string]any{
+		"authority-id": accountID,
+		"account-id":   accountID,
+		"name":         name,
+		"views": map[string]any{
+			"wifi-setup": map[string]any{
+				"rules": []any{
+					map[string]any{"request": "ssid", "storage": "wifi.ssid"},
+				},
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	body := []byte(`{
+  "storage": {
+    "schema": {
+      "wifi": {
+        "schema": {
+          "retries": "int",
+          "ssid": "string"
+        }
+      }
+    }
+  }
+}`)
+	a, err := s.Brands.Signing(accountID).Sign(asserts.ConfdbSchemaType, headers, body, "")
+	c.Assert(err, IsNil)
+	return a
+}
+
+func (s *firstBoot16Suite) TestPopulateFromSeedGadgetConfdbHappy(c *C) {
+	bloader := boottest.MockUC16Bootenv(bootloadertest.Mock("mock", c.MkDir()))
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+	bloader.SetBootKernel("pc-kernel_1.snap")
+	bloader.SetBootBase("core_1.snap")
+
+	const confdbYaml = `
+confdb:
+  my-brand/network:
+    wifi:
+      ssid: my-ssid
+      retries: 3
+`
+	coreFname, kernelFname, gadgetFname := s.makeCoreSnaps(c, confdbYaml)
+
+	// add a model assertion and its chain
+	assertsChain := s.makeModelAssertionChain(c, "my-model", nil)
+	s.WriteAssertions("model.asserts", assertsChain...)
+	s.WriteAssertions("confdb.asserts", s.makeConfdbSchema(c, "my-brand", "network"))
+
+	// create a seed.yaml
+	content := []byte(fmt.Sprintf(`
+snaps:
+ - name: core
+   file: %s
+ - name: pc-kernel
+   file: %s
+ - name: pc
+   file: %s
+`, coreFname, kernelFname, gadgetFname))
+	err := os.WriteFile(filepath.Join(dirs.SnapSeedDir, "seed.yaml"), content, 0644)
+	c.Assert(err, IsNil)
+
+	// run the firstboot stuff
+	s.startOverlord(c)
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	tsAll, err := devicestate.PopulateStateFromSeedImpl(s.overlord.DeviceManager(), s.perfTimings)
+	c.Assert(err, IsNil)
+
+	checkSeedTasks(c, tsAll)
+
+	// seeding confdb happens after configuring the gadget
+	var seedConfdb *state.Task
+	for i, ts := range tsAll {
+		if ts.Tasks()[0].Kind() != "seed-confdb" {
+			continue
+		}
+		seedConfdb = ts.Tasks()[0]
+		prevTasks := tsAll[i-1].Tasks()
+		c.Check(prevTasks[0].Kind(), Equals, "run-hook")
+		var hsup hookstate.HookSetup
+		c.Assert(prevTasks[0].Get("hook-setup", &hsup), IsNil)
+		c.Check(hsup.Snap, Equals, "pc")
+		c.Check(seedConfdb.WaitTasks(), testutil.Contains, prevTasks[len(prevTasks)-1])
+	}
+	c.Assert(seedConfdb, NotNil)
+
+	chg := st.NewChange("seed", "run the populate from seed changes")
+	for _, ts := range tsAll {
+		chg.AddAll(ts)
+	}
+	c.Assert(st.Changes(), HasLen, 1)
+
+	// avoid device reg
+	chg1 := st.NewChange("become-operational", "init device")
+	chg1.SetStatus(state.DoingStatus)
+
+	st.Unlock()
+	err = s.overlord.Settle(settleTimeout)
+	st.Lock()
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(err, IsNil)
+
+	var databags map[string]map[string]map[string]any
+	err = st.Get("confdb-databags", &databags)
+	c.Assert(err, IsNil)
+	c.Check(databags, DeepEquals, map[string]map[string]map[string]any{
+		"my-brand": {
+			"network": {
+				"wifi": map[string]any{"ssid": "my-ssid", "retries": float64(3)},
+			},
+		},
+	})
+}
+
+func (s *firstBoot16Suite) TestPopulateFromSeedGadgetConfdbInvalid(c *C) {
+	bloader := boottest.MockUC16Bootenv(bootloadertest.Mock("mock", c.MkDir()))
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+	bloader.SetBootKernel("pc-kernel_1.snap")
+	bloader.SetBootBase("core_1.snap")
+
+	const confdbYaml = `
+confdb:
+  my-brand/network:
+    wifi:
+      ssid: 1
+`
+	coreFname, kernelFname, gadgetFname := s.makeCoreSnaps(c, confdbYaml)
+
+	assertsChain := s.makeModelAssertionChain(c, "my-model", nil)
+	s.WriteAssertions("model.asserts", assertsChain...)
+	s.WriteAssertions("confdb.asserts", s.makeConfdbSchema(c, "my-brand", "network"))
+
+	content := []byte(fmt.Sprintf(`
+snaps:
+ - name: core
+   file: %s
+ - name: pc-kernel
+   file: %s
+ - name: pc
+   file: %s
+`, coreFname, kernelFname, gadgetFname))
+	err := os.WriteFile(filepath.Join(dirs.SnapSeedDir, "seed.yaml"), content, 0644)
+	c.Assert(err, IsNil)
+
+	s.startOverlord(c)
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	tsAll, err := devicestate.PopulateStateFromSeedImpl(s.overlord.DeviceManager(), s.perfTimings)
+	c.Assert(err, IsNil)
+
+	chg := st.NewChange("seed", "run the populate from seed changes")
+	for _, ts := range tsAll {
+		chg.AddAll(ts)
+	}
+
+	// avoid device reg
+	chg1 := st.NewChange("become-operational", "init device")
+	chg1.SetStatus(state.DoingStatus)
+
+	st.Unlock()
+	err = s.overlord.Settle(settleTimeout)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot seed confdb from the gadget: cannot import confdb my-brand/network: .*`)
+
+	var seeded bool
+	err = st.Get("seeded", &seeded)
+	c.Check(err, testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *firstBoot16Suite) TestPopulateFromSeedGadgetConfdbWrongAccount(c *C) {
+	bloader := boottest.MockUC16Bootenv(bootloadertest.Mock("mock", c.MkDir()))
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+	bloader.SetBootKernel("pc-kernel_1.snap")
+	bloader.SetBootBase("core_1.snap")
+
+	const confdbYaml = `
+confdb:
+  other-brand/network:
+    wifi:
+      ssid: my-ssid
+`
+	coreFname, kernelFname, gadgetFname := s.makeCoreSnaps(c, confdbYaml)
+
+	assertsChain := s.makeModelAssertionChain(c, "my-model", nil)
+	s.WriteAssertions("model.asserts", assertsChain...)
+
+	content := []byte(fmt.Sprintf(`
+snaps:
+ - name: core
+   file: %s
+ - name: pc-kernel
+   file: %s
+ - name: pc
+   file: %s
+`, coreFname, kernelFname, gadgetFname))
+	err := os.WriteFile(filepath.Join(dirs.SnapSeedDir, "seed.yaml"), content, 0644)
+	c.Assert(err, IsNil)
+
+	s.startOverlord(c)
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, err = devicestate.PopulateStateFromSeedImpl(s.overlord.DeviceManager(), s.perfTimings)
+	c.Assert(err, ErrorMatches, `cannot seed confdb other-brand/network: gadget can only provide databags for confdb-schemas of the brand "my-brand"`)
+}
+
 func (s *firstBoot16Suite) TestImportAssertionsFromSeedClassicModelMismatch(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -19,14 +19,18 @@
 package devicestate
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/confdbstate"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -127,6 +131,36 @@ func (m *DeviceManager) recordSeededSystem(st *state.State, whatSeeded *seededSy
 	return nil
 }
 
+func (m *DeviceManager) doSeedConfdb(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var databags map[string]json.RawMessage
+	if err := t.Get("confdb-databags", &databags); err != nil {
+		return err
+	}
+	var gadgetName string
+	if err := t.Get("gadget", &gadgetName); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	refs := make([]string, 0, len(databags))
+	for ref := range databags {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		account, schemaName, _ := strings.Cut(ref, "/")
+		// the databag is checked against the confdb-schema from the seed
+		if err := confdbstate.ImportDatabag(st, account, schemaName, databags[ref], gadgetName); err != nil {
+			return fmt.Errorf("cannot seed confdb from the gadget: %v", err)
+		}
+	}
+	return nil
+}
+
 func (m *DeviceManager) doMarkSeeded(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
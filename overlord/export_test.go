@@ -116,3 +116,16 @@ func MockSystemdSdNotify(f func(notifyState string) error) (restore func()) {
 		systemdSdNotify = old
 	}
 }
+
+// MockJournalCompactionMinSize sets the size the state journal needs to reach
+// before it is compacted.
+func MockJournalCompactionMinSize(size int64) (restore func()) {
+	old := journalCompactionMinSize
+	journalCompactionMinSize = size
+	return func() { journalCompactionMinSize = old }
+}
+
+// NewJournalStateBackend returns a journaled state backend for tests.
+func NewJournalStateBackend(path string) state.JournalBackend {
+	return newJournalStateBackend(path, func(time.Duration) {})
+}
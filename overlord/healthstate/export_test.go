@@ -32,3 +32,13 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+var NewHealthHandler = newHealthHandler
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"time"
 
 	"github.com/snapcore/snapd/logger"
@@ -35,7 +36,15 @@ import (
 	"github.com/snapcore/snapd/strutil"
 )
 
-var checkTimeout = 30 * time.Second
+var (
+	checkTimeout = 30 * time.Second
+
+	// healthCheckRetryInterval is how often the health check of a refreshed
+	// snap is run again while it reports that it is waiting.
+	healthCheckRetryInterval = 10 * time.Second
+
+	timeNow = time.Now
+)
 
 func init() {
 	if s, ok := os.LookupEnv("SNAPD_CHECK_HEALTH_HOOK_TIMEOUT"); ok {
@@ -47,6 +56,7 @@ func init() {
 	}
 
 	snapstate.CheckHealthHook = Hook
+	snapstate.UnhealthySnaps = unhealthySnaps
 }
 
 func Hook(st *state.State, snapName string, snapRev snap.Revision) *state.Task {
@@ -110,13 +120,29 @@ type healthHandler struct {
 	context *hookstate.Context
 }
 
-// Before is called just before the hook runs -- nothing to do beyond setting a marker
+// Before is called just before the hook runs -- beyond setting a marker, it
+// delays the first health check of refreshes which can be reverted by the
+// grace period
 func (h *healthHandler) Before() error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	rollback, start, err := h.healthRollback()
+	if err != nil {
+		return err
+	}
+	if rollback != nil && start.IsZero() {
+		task, _ := h.context.Task()
+		task.Set("health-check-start", timeNow())
+		if rollback.GracePeriod > 0 {
+			task.Logf("Waiting %v before checking the health of the snap", rollback.GracePeriod)
+			return &state.Retry{After: rollback.GracePeriod, Reason: "health check grace period"}
+		}
+	}
+
 	// we use the 'health' entry as a marker to not add OnDone to
 	// the snapctl set-health execution
-	h.context.Lock()
 	h.context.Set("health", struct{}{})
-	h.context.Unlock()
 	return nil
 }
 
@@ -143,7 +169,91 @@ func (h *healthHandler) Done() error {
 		}
 	}
 
-	return h.appendHealth(&health)
+	if err := h.appendHealth(&health); err != nil {
+		return err
+	}
+	return h.checkRollback(&health)
+}
+
+// healthRollback returns how the refresh the health check is part of is
+// reverted if the snap does not become healthy, and when the health check
+// was first attempted, or nil if it is not reverted.
+//
+// The state must be locked by the caller.
+func (h *healthHandler) healthRollback() (*snapstate.HealthRollback, time.Time, error) {
+	task, ok := h.context.Task()
+	if !ok {
+		return nil, time.Time{}, nil
+	}
+	var rollback snapstate.HealthRollback
+	if err := task.Get("health-rollback", &rollback); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, time.Time{}, nil
+		}
+		return nil, time.Time{}, err
+	}
+	var start time.Time
+	if err := task.Get("health-check-start", &start); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, time.Time{}, err
+	}
+	return &rollback, start, nil
+}
+
+// checkRollback fails the health check, so that the refresh is reverted, if
+// the snap reported an error or is still waiting past the timeout. While the
+// snap is waiting before that, the health check is retried.
+func (h *healthHandler) checkRollback(health *HealthState) error {
+	st := h.context.State()
+	st.Lock()
+	defer st.Unlock()
+
+	rollback, start, err := h.healthRollback()
+	if err != nil || rollback == nil {
+		return err
+	}
+	switch health.Status {
+	case ErrorStatus:
+		return h.rollback(health, "reported an error")
+	case WaitingStatus:
+		now := timeNow()
+		deadline := start.Add(rollback.GracePeriod + rollback.Timeout)
+		if now.Before(deadline) {
+			after := healthCheckRetryInterval
+			if remaining := deadline.Sub(now); remaining < after {
+				after = remaining
+			}
+			return &state.Retry{After: after, Reason: "waiting for the snap to become healthy"}
+		}
+		return h.rollback(health, fmt.Sprintf("did not become healthy within %v", rollback.Timeout))
+	}
+	return nil
+}
+
+// rollback records a warning and a notice about the refresh being reverted
+// and returns the error failing the health check.
+//
+// The state must be locked by the caller.
+func (h *healthHandler) rollback(health *HealthState, reason string) error {
+	st := h.context.State()
+	snapName := h.context.InstanceName()
+	rev := h.context.SnapRevision()
+
+	msg := fmt.Sprintf("snap %q %s after being refreshed to revision %s", snapName, reason, rev)
+	if health.Message != "" {
+		msg += ": " + health.Message
+	}
+	st.Warnf("%s, reverting the refresh", msg)
+	_, err := st.AddNotice(nil, state.SnapHealthRollbackNotice, snapName, &state.AddNoticeOptions{
+		Data: map[string]string{
+			"revision": rev.String(),
+			"status":   health.Status.String(),
+			"message":  health.Message,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return errors.New(msg)
 }
 
 func (h *healthHandler) Error(err error) (bool, error) {
@@ -225,3 +335,20 @@ func Get(st *state.State, snap string) (*HealthState, error) {
 
 	return &health, nil
 }
+
+// unhealthySnaps returns the snaps whose latest health check reported an
+// error, sorted by name.
+func unhealthySnaps(st *state.State) ([]string, error) {
+	hs, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	var unhealthy []string
+	for name, health := range hs {
+		if health.Status == ErrorStatus {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	sort.Strings(unhealthy)
+	return unhealthy, nil
+}
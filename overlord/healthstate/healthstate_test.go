@@ -20,6 +20,7 @@
 package healthstate_test
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -253,3 +254,146 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), testutil.ErrorIs, state.ErrNoState)
 }
+
+func (s *healthSuite) TestUnhealthySnaps(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	unhealthy, err := snapstate.UnhealthySnaps(s.state)
+	c.Assert(err, check.IsNil)
+	c.Check(unhealthy, check.HasLen, 0)
+
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"foo": {Status: healthstate.ErrorStatus},
+		"bar": {Status: healthstate.OkayStatus},
+		"baz": {Status: healthstate.BlockedStatus},
+		"abc": {Status: healthstate.ErrorStatus},
+	})
+	unhealthy, err = snapstate.UnhealthySnaps(s.state)
+	c.Assert(err, check.IsNil)
+	c.Check(unhealthy, check.DeepEquals, []string{"abc", "foo"})
+}
+
+func (s *healthSuite) rollbackHandler(c *check.C, rollback *snapstate.HealthRollback) (hookstate.Handler, *hookstate.Context, *state.Task) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	task := healthstate.Hook(s.state, "test-snap", snap.R(42))
+	if rollback != nil {
+		task.Set("health-rollback", rollback)
+	}
+	chg := s.state.NewChange("refresh", "...")
+	chg.AddTask(task)
+
+	var hooksup hookstate.HookSetup
+	c.Assert(task.Get("hook-setup", &hooksup), check.IsNil)
+	ctx, err := hookstate.NewContext(task, s.state, &hooksup, nil, "")
+	c.Assert(err, check.IsNil)
+	return healthstate.NewHealthHandler(ctx), ctx, task
+}
+
+func (s *healthSuite) setHealth(ctx *hookstate.Context, status healthstate.HealthStatus, message string) {
+	ctx.Lock()
+	defer ctx.Unlock()
+	ctx.Set("health", &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: time.Now(),
+		Status:    status,
+		Message:   message,
+	})
+}
+
+func (s *healthSuite) TestRollbackNotRequested(c *check.C) {
+	h, ctx, _ := s.rollbackHandler(c, nil)
+	c.Assert(h.Before(), check.IsNil)
+	s.setHealth(ctx, healthstate.ErrorStatus, "boom")
+	c.Assert(h.Done(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+}
+
+func (s *healthSuite) TestRollbackGracePeriod(c *check.C) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	restore := healthstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	h, ctx, task := s.rollbackHandler(c, &snapstate.HealthRollback{
+		GracePeriod: 30 * time.Second,
+		Timeout:     time.Minute,
+	})
+	err := h.Before()
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+	c.Check(err.(*state.Retry).After, check.Equals, 30*time.Second)
+
+	s.state.Lock()
+	var start time.Time
+	c.Check(task.Get("health-check-start", &start), check.IsNil)
+	c.Check(start.Equal(now), check.Equals, true)
+	s.state.Unlock()
+
+	// the health check runs once the grace period is over
+	now = now.Add(30 * time.Second)
+	c.Assert(h.Before(), check.IsNil)
+	s.setHealth(ctx, healthstate.OkayStatus, "")
+	c.Assert(h.Done(), check.IsNil)
+}
+
+func (s *healthSuite) TestRollbackOnError(c *check.C) {
+	h, ctx, _ := s.rollbackHandler(c, &snapstate.HealthRollback{Timeout: time.Minute})
+	c.Assert(h.Before(), check.IsNil)
+	s.setHealth(ctx, healthstate.ErrorStatus, "cannot connect to the database")
+	err := h.Done()
+	c.Check(err, check.ErrorMatches, `snap "test-snap" reported an error after being refreshed to revision 42: cannot connect to the database`)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, `snap "test-snap" reported an error after being refreshed to revision 42: cannot connect to the database, reverting the refresh`)
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapHealthRollbackNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], check.Equals, "test-snap")
+	c.Check(n["last-data"], check.DeepEquals, map[string]any{
+		"revision": "42",
+		"status":   "error",
+		"message":  "cannot connect to the database",
+	})
+}
+
+func (s *healthSuite) TestRollbackWhileWaiting(c *check.C) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	restore := healthstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	h, ctx, _ := s.rollbackHandler(c, &snapstate.HealthRollback{Timeout: 25 * time.Second})
+	c.Assert(h.Before(), check.IsNil)
+
+	// the health check is run again while waiting
+	s.setHealth(ctx, healthstate.WaitingStatus, "starting")
+	err := h.Done()
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+	c.Check(err.(*state.Retry).After, check.Equals, 10*time.Second)
+
+	now = now.Add(20 * time.Second)
+	c.Assert(h.Before(), check.IsNil)
+	s.setHealth(ctx, healthstate.WaitingStatus, "starting")
+	err = h.Done()
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+	c.Check(err.(*state.Retry).After, check.Equals, 5*time.Second)
+
+	now = now.Add(5 * time.Second)
+	c.Assert(h.Before(), check.IsNil)
+	s.setHealth(ctx, healthstate.WaitingStatus, "still starting")
+	err = h.Done()
+	c.Check(err, check.ErrorMatches, `snap "test-snap" did not become healthy within 25s after being refreshed to revision 42: still starting`)
+}
+
+func noticeToMap(c *check.C, notice *state.Notice) map[string]any {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, check.IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), check.IsNil)
+	return n
+}
@@ -187,7 +187,7 @@ func MockNewStatusDecorator(f func(ctx context.Context, isGlobal bool, uid strin
 	return restore
 }
 
-func MockConfdbstateTransactionForSet(f func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error)) (restore func()) {
+func MockConfdbstateTransactionForSet(f func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error)) (restore func()) {
 	old := confdbstateTransactionForSet
 	confdbstateTransactionForSet = f
 	return func() {
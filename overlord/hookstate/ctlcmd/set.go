@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/snapcore/snapd/client/clientutil"
@@ -130,7 +131,7 @@ func (s *setCommand) Execute(args []string) error {
 			return fmt.Errorf(i18n.G("cannot set %s plug: %w"), s.Positional.PlugOrSlotSpec, err)
 		}
 
-		return setConfdbValues(context, name, requests)
+		return setConfdbValues(context, name, requests, s.uid)
 	}
 
 	return s.setInterfaceSetting(context, name)
@@ -244,7 +245,12 @@ func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot
 	return nil
 }
 
-func setConfdbValues(ctx *hookstate.Context, plugName string, requests map[string]any) error {
+func setConfdbValues(ctx *hookstate.Context, plugName string, requests map[string]any, uid string) error {
+	userID, err := strconv.Atoi(uid)
+	if err != nil {
+		return err
+	}
+
 	ctx.Lock()
 	defer ctx.Unlock()
 
@@ -267,7 +273,7 @@ func setConfdbValues(ctx *hookstate.Context, plugName string, requests map[strin
 		return fmt.Errorf("cannot modify confdb in %q hook", ctx.HookName())
 	}
 
-	tx, commitTxFunc, err := confdbstateTransactionForSet(ctx, ctx.State(), view)
+	tx, commitTxFunc, err := confdbstateTransactionForSet(ctx, ctx.State(), view, userID)
 	if err != nil {
 		return err
 	}
@@ -418,7 +418,7 @@ func (s *confdbSuite) TestConfdbSetSingleView(c *C) {
 	s.state.Unlock()
 	c.Assert(err, IsNil)
 
-	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		return tx, nil, nil
 	})
 	defer restore()
@@ -443,7 +443,9 @@ func (s *confdbSuite) TestConfdbSetSingleViewNewTransaction(c *C) {
 	c.Assert(err, IsNil)
 
 	var called bool
-	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := ctlcmd.MockConfdbstateTransactionForSet(func(_ *hookstate.Context, _ *state.State, _ *confdb.View, userID int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+		// the uid of the snapctl caller is recorded
+		c.Check(userID, Equals, 0)
 		return tx, func() (string, <-chan struct{}, error) {
 			called = true
 			waitChan := make(chan struct{})
@@ -471,7 +473,7 @@ func (s *confdbSuite) TestConfdbSetManyViews(c *C) {
 	s.state.Unlock()
 	c.Assert(err, IsNil)
 
-	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		return tx, nil, nil
 	})
 	defer restore()
@@ -527,7 +529,7 @@ func (s *confdbSuite) TestConfdbSetExclamationMark(c *C) {
 	err = tx.Set(parsePath(c, "wifi.psk"), "bar")
 	c.Assert(err, IsNil)
 
-	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		return tx, nil, nil
 	})
 	defer restore()
@@ -552,7 +554,7 @@ func (s *confdbSuite) TestConfdbModifyHooks(c *C) {
 	tx, err := confdbstate.NewTransaction(s.state, s.devAccID, "network")
 	c.Assert(err, IsNil)
 
-	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	restore := ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		return tx, nil, nil
 	})
 	defer restore()
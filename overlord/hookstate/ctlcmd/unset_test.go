@@ -176,7 +176,7 @@ func (s *confdbSuite) TestConfdbUnsetManyViews(c *C) {
 	err = tx.Set(parsePath(c, "wifi.psk"), "bar")
 	c.Assert(err, IsNil)
 
-	ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
+	ctlcmd.MockConfdbstateTransactionForSet(func(*hookstate.Context, *state.State, *confdb.View, int) (*confdbstate.Transaction, confdbstate.CommitTxFunc, error) {
 		return tx, nil, nil
 	})
 
@@ -36,6 +36,10 @@ func MockListenerRegister(f func() (listenerBackend, error)) (restore func()) {
 	return testutil.Mock(&listenerRegister, f)
 }
 
+func MockGadgetAdminRulesFile(f func(st *state.State) string) (restore func()) {
+	return testutil.Mock(&gadgetAdminRulesFile, f)
+}
+
 type fakeListener struct {
 	readyChan        chan struct{}
 	reqsChan         chan *prompting.Request
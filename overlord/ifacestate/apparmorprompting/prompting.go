@@ -20,16 +20,20 @@
 package apparmorprompting
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/interfaces/prompting"
 	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/requestaudit"
 	"github.com/snapcore/snapd/interfaces/prompting/requestprompts"
 	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sandbox/apparmor/notify/listener"
 	"github.com/snapcore/snapd/strutil"
@@ -55,13 +59,16 @@ type Manager interface {
 	Ask(uid uint32, iface, snap string, pid int32, cgroup string, snapdShuttingDown <-chan struct{}) (prompting.OutcomeType, error)
 	Prompts(userID uint32, clientActivity bool) ([]*requestprompts.Prompt, error)
 	PromptWithID(userID uint32, promptID prompting.IDType, clientActivity bool) (*requestprompts.Prompt, error)
-	HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool) ([]prompting.IDType, error)
+	HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool, client string) ([]prompting.IDType, error)
 	Rules(userID uint32, snap string, iface string) ([]*requestrules.Rule, error)
 	AddRule(userID uint32, snap string, iface string, constraintsJSON prompting.ConstraintsJSON) (*requestrules.Rule, error)
 	RemoveRules(userID uint32, snap string, iface string) ([]*requestrules.Rule, error)
 	RuleWithID(userID uint32, ruleID prompting.IDType) (*requestrules.Rule, error)
 	PatchRule(userID uint32, ruleID prompting.IDType, constraintsPatchJSON prompting.ConstraintsJSON) (*requestrules.Rule, error)
 	RemoveRule(userID uint32, ruleID prompting.IDType) (*requestrules.Rule, error)
+	ImportRules(userID uint32, contents []*requestrules.RuleContents) ([]*requestrules.Rule, error)
+	AdminRules(snap string, iface string) ([]*requestrules.AdminRule, error)
+	AuditEntries(filter *requestaudit.Filter) ([]*requestaudit.Entry, error)
 }
 
 // verify that InterfacesRequestsManager implements Manager
@@ -77,6 +84,7 @@ type InterfacesRequestsManager struct {
 	listener listenerBackend
 	prompts  *requestprompts.PromptDB
 	rules    *requestrules.RuleDB
+	audit    *requestaudit.Log
 
 	// listenerAlreadySignalled is closed when the listener readiness is first
 	// observed. If there are still pending unreceived requests from outside
@@ -125,7 +133,40 @@ func New(s *state.State) (m *InterfacesRequestsManager, retErr error) {
 		}
 	}()
 
-	promptsBackend, err := requestprompts.New(notifyPrompt)
+	auditLog, err := requestaudit.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot open request audit log: %w", err)
+	}
+	defer func() {
+		if retErr != nil {
+			auditLog.Close()
+		}
+	}()
+	notifyUnanswered := func(userID uint32, prompt *requestprompts.Prompt, reason string) {
+		entry := &requestaudit.Entry{
+			User:        userID,
+			Snap:        prompt.Snap,
+			PID:         prompt.PID,
+			Interface:   prompt.Interface,
+			Path:        prompt.Constraints.Path(),
+			Permissions: prompt.Constraints.OutstandingPermissions(),
+			DecidedBy:   requestaudit.DecidedByPolicy,
+			PromptID:    prompt.ID.String(),
+			Reason:      reason,
+		}
+		if reason == "expired" {
+			entry.Denied = entry.Permissions
+		} else {
+			// The requests of cancelled prompts are re-received after
+			// snapd restarts, so they are still outstanding.
+			entry.Outstanding = entry.Permissions
+		}
+		if err := auditLog.Record(entry); err != nil {
+			logger.Noticef("cannot record unanswered prompt in audit log: %v", err)
+		}
+	}
+
+	promptsBackend, err := requestprompts.New(notifyPrompt, notifyUnanswered)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open request prompts backend: %w", err)
 	}
@@ -144,11 +185,13 @@ func New(s *state.State) (m *InterfacesRequestsManager, retErr error) {
 			rulesBackend.Close()
 		}
 	}()
+	rulesBackend.LoadAdminRules(gadgetAdminRulesFile(s))
 
 	m = &InterfacesRequestsManager{
 		listener:                 listenerBackend,
 		prompts:                  promptsBackend,
 		rules:                    rulesBackend,
+		audit:                    auditLog,
 		listenerAlreadySignalled: make(chan struct{}),
 		askRequests:              make(chan *prompting.Request),
 		notifyPrompt:             notifyPrompt,
@@ -160,6 +203,26 @@ func New(s *state.State) (m *InterfacesRequestsManager, retErr error) {
 	return m, nil
 }
 
+// gadgetAdminRulesFile returns the path of the file in which the gadget snap
+// may ship admin rules, or an empty string if there is no gadget.
+var gadgetAdminRulesFile = func(st *state.State) string {
+	st.Lock()
+	defer st.Unlock()
+	deviceCtx, err := snapstate.DeviceCtxFromState(st, nil)
+	if err != nil {
+		// no model yet, so no gadget either
+		return ""
+	}
+	gadgetInfo, err := snapstate.GadgetInfo(st, deviceCtx)
+	if err != nil {
+		if !errors.Is(err, state.ErrNoState) {
+			logger.Noticef("cannot get gadget information to load admin rules: %v", err)
+		}
+		return ""
+	}
+	return filepath.Join(gadgetInfo.MountDir(), "meta", "prompting-rules.json")
+}
+
 // Run is the main run loop for the manager, and must be called using tomb.Go.
 func (m *InterfacesRequestsManager) run() error {
 	m.tomb.Go(func() error {
@@ -256,6 +319,11 @@ func (m *InterfacesRequestsManager) listenerReadyForTheFirstTime() <-chan struct
 func (m *InterfacesRequestsManager) handleRequest(req *prompting.Request) error {
 	if req.UID == 0 {
 		// Deny any request for the root user
+		m.recordDecision(req, &requestaudit.Entry{
+			Denied:    req.Permissions,
+			DecidedBy: requestaudit.DecidedByPolicy,
+			Reason:    "request from root user",
+		})
 		return req.Reply(nil)
 	}
 
@@ -266,14 +334,23 @@ func (m *InterfacesRequestsManager) handleRequest(req *prompting.Request) error
 
 	allowedPerms, matchedDenyRule, outstandingPerms, err := m.rules.IsRequestAllowed(req.UID, req.Snap, req.Interface, req.Path, req.Permissions)
 	if err != nil || matchedDenyRule || len(outstandingPerms) == 0 {
+		entry := &requestaudit.Entry{
+			Allowed:   allowedPerms,
+			Denied:    permsNotIn(req.Permissions, allowedPerms),
+			DecidedBy: requestaudit.DecidedByRules,
+			Rules:     m.rules.MatchingRules(req.UID, req.Snap, req.Interface, req.Path, req.Permissions),
+		}
 		switch {
 		case err != nil:
 			logger.Noticef("error while checking request against existing rules: %v", err)
+			entry.DecidedBy = requestaudit.DecidedByPolicy
+			entry.Reason = fmt.Sprintf("error while checking request against existing rules: %v", err)
 		case matchedDenyRule:
 			logger.Debugf("request denied by existing rule: %+v", req)
 		case len(outstandingPerms) == 0:
 			logger.Debugf("request allowed by existing rule: %+v", req)
 		}
+		m.recordDecision(req, entry)
 		// Allow any requested permissions which were explicitly allowed by
 		// existing rules (there may be no such permissions) and auto-deny all
 		// permissions which were not explicitly included in the allowed permissions.
@@ -299,6 +376,12 @@ func (m *InterfacesRequestsManager) handleRequest(req *prompting.Request) error
 		// We weren't able to create a new prompt, so respond with the best
 		// information we have, which is to allow any permissions which were
 		// allowed by existing rules, and auto-deny the rest.
+		m.recordDecision(req, &requestaudit.Entry{
+			Allowed:   allowedPerms,
+			Denied:    permsNotIn(req.Permissions, allowedPerms),
+			DecidedBy: requestaudit.DecidedByPolicy,
+			Reason:    fmt.Sprintf("cannot create prompt: %v", err),
+		})
 		return req.Reply(allowedPerms)
 	}
 
@@ -307,10 +390,51 @@ func (m *InterfacesRequestsManager) handleRequest(req *prompting.Request) error
 	} else {
 		logger.Debugf("adding prompt to internal storage: %+v", newPrompt)
 	}
+	var matchingRules []string
+	if len(allowedPerms) > 0 {
+		matchingRules = m.rules.MatchingRules(req.UID, req.Snap, req.Interface, req.Path, allowedPerms)
+	}
+	m.recordDecision(req, &requestaudit.Entry{
+		Allowed:     allowedPerms,
+		Outstanding: outstandingPerms,
+		DecidedBy:   requestaudit.DecidedByPrompt,
+		PromptID:    newPrompt.ID.String(),
+		Rules:       matchingRules,
+	})
 
 	return nil
 }
 
+// recordDecision completes the given audit log entry with the contents of the
+// given request and records it in the audit log. Failing to record the entry
+// is logged but does not otherwise affect handling the request.
+func (m *InterfacesRequestsManager) recordDecision(req *prompting.Request, entry *requestaudit.Entry) {
+	entry.User = req.UID
+	entry.Snap = req.Snap
+	entry.PID = req.PID
+	entry.Interface = req.Interface
+	entry.Path = req.Path
+	entry.Permissions = req.Permissions
+	m.recordAuditEntry(entry)
+}
+
+func (m *InterfacesRequestsManager) recordAuditEntry(entry *requestaudit.Entry) {
+	if err := m.audit.Record(entry); err != nil {
+		logger.Noticef("cannot record decision for request in audit log: %v", err)
+	}
+}
+
+// permsNotIn returns the permissions in perms which are not in other.
+func permsNotIn(perms []string, other []string) []string {
+	var result []string
+	for _, perm := range perms {
+		if !strutil.ListContains(other, perm) {
+			result = append(result, perm)
+		}
+	}
+	return result
+}
+
 func (m *InterfacesRequestsManager) disconnect() error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -326,6 +450,9 @@ func (m *InterfacesRequestsManager) disconnect() error {
 	if m.prompts != nil {
 		errs = append(errs, m.prompts.Close())
 	}
+	if m.audit != nil {
+		errs = append(errs, m.audit.Close())
+	}
 
 	return strutil.JoinErrors(errs...)
 }
@@ -438,7 +565,10 @@ func (m *InterfacesRequestsManager) PromptWithID(userID uint32, promptID prompti
 //
 // If clientActivity is true, reset the expiration timeout for prompts for
 // the given user.
-func (m *InterfacesRequestsManager) HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool) (satisfiedPromptIDs []prompting.IDType, retErr error) {
+//
+// The given client identifies the prompting client which sent the reply, and
+// is recorded in the audit log.
+func (m *InterfacesRequestsManager) HandleReply(userID uint32, promptID prompting.IDType, replyConstraintsJSON prompting.ConstraintsJSON, outcome prompting.OutcomeType, lifespan prompting.LifespanType, duration string, clientActivity bool, client string) (satisfiedPromptIDs []prompting.IDType, retErr error) {
 	<-m.prompts.Ready()
 
 	m.lock.Lock()
@@ -513,12 +643,36 @@ func (m *InterfacesRequestsManager) HandleReply(userID uint32, promptID promptin
 		}()
 	}
 
+	// The prompt constraints are modified by the reply, so take note of the
+	// outstanding permissions beforehand.
+	entry := &requestaudit.Entry{
+		User:        userID,
+		Snap:        prompt.Snap,
+		PID:         prompt.PID,
+		Interface:   prompt.Interface,
+		Path:        prompt.Constraints.Path(),
+		Permissions: prompt.Constraints.OutstandingPermissions(),
+		DecidedBy:   requestaudit.DecidedByReply,
+		PromptID:    promptID.String(),
+		Client:      client,
+	}
+
 	prompt, retErr = m.prompts.Reply(userID, promptID, outcome, clientActivity)
 	if retErr != nil {
 		// Error should not occur unless the listener has closed
 		return nil, retErr
 	}
 
+	if outcome == prompting.OutcomeAllow {
+		entry.Allowed = entry.Permissions
+	} else {
+		entry.Denied = entry.Permissions
+	}
+	if newRule != nil {
+		entry.Rules = []string{newRule.ID.String()}
+	}
+	m.recordAuditEntry(entry)
+
 	if lifespan == prompting.LifespanSingle {
 		return []prompting.IDType{}, nil
 	}
@@ -535,12 +689,51 @@ func (m *InterfacesRequestsManager) applyRuleToOutstandingPrompts(rule *requestr
 		Snap:      rule.Snap,
 		Interface: rule.Interface,
 	}
+	// The prompt constraints are modified by the new rule, so prepare the
+	// audit log entries for the outstanding prompts beforehand.
+	outstanding, _ := m.prompts.Prompts(rule.User, false)
+	entries := make(map[prompting.IDType]*requestaudit.Entry, len(outstanding))
+	for _, prompt := range outstanding {
+		if prompt.Snap != rule.Snap || prompt.Interface != rule.Interface {
+			continue
+		}
+		entries[prompt.ID] = &requestaudit.Entry{
+			User:        rule.User,
+			Snap:        prompt.Snap,
+			PID:         prompt.PID,
+			Interface:   prompt.Interface,
+			Path:        prompt.Constraints.Path(),
+			Permissions: prompt.Constraints.OutstandingPermissions(),
+			DecidedBy:   requestaudit.DecidedByNewRule,
+			PromptID:    prompt.ID.String(),
+			Rules:       []string{rule.ID.String()},
+		}
+	}
+
 	satisfiedPromptIDs, err := m.prompts.HandleNewRule(metadata, rule.Constraints)
 	if err != nil {
 		// The rule's constraints and outcome were already validated, so an
 		// error should not occur here unless the prompt DB was already closed.
 		logger.Noticef("error when handling new rule: %v", err)
 	}
+
+	for _, id := range satisfiedPromptIDs {
+		entry, ok := entries[id]
+		if !ok {
+			continue
+		}
+		// A prompt is satisfied either when all of its outstanding
+		// permissions are allowed, or when at least one of them is denied,
+		// in which case the others are denied as well.
+		for _, perm := range entry.Permissions {
+			if permEntry, ok := rule.Constraints.Permissions[perm]; ok && permEntry.Outcome == prompting.OutcomeAllow {
+				entry.Allowed = append(entry.Allowed, perm)
+			} else {
+				entry.Denied = append(entry.Denied, perm)
+			}
+		}
+		m.recordAuditEntry(entry)
+	}
 	return satisfiedPromptIDs
 }
 
@@ -662,3 +855,41 @@ func (m *InterfacesRequestsManager) RemoveRule(userID uint32, ruleID prompting.I
 	rule, err := m.rules.RemoveRule(userID, ruleID)
 	return rule, err
 }
+
+// ImportRules creates rules with the given contents for the user with the
+// given user ID and then checks them against outstanding prompts, resolving
+// any prompts which they satisfy. Either all of the rules are imported, or
+// none of them.
+func (m *InterfacesRequestsManager) ImportRules(userID uint32, contents []*requestrules.RuleContents) ([]*requestrules.Rule, error) {
+	<-m.prompts.Ready()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	importedRules, err := m.rules.ImportRules(userID, contents)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range importedRules {
+		m.applyRuleToOutstandingPrompts(rule)
+	}
+	return importedRules, nil
+}
+
+// AdminRules returns the admin rules which apply to the given snap and/or
+// interface, or all admin rules if both are unspecified.
+func (m *InterfacesRequestsManager) AdminRules(snap string, iface string) ([]*requestrules.AdminRule, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.rules.AdminRules(snap, iface), nil
+}
+
+// AuditEntries returns the entries of the audit log of decisions made for
+// requests which match the given filter, from oldest to newest.
+func (m *InterfacesRequestsManager) AuditEntries(filter *requestaudit.Filter) ([]*requestaudit.Entry, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.audit.Entries(filter)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces/prompting"
 	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/requestaudit"
 	"github.com/snapcore/snapd/interfaces/prompting/requestprompts"
 	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/logger"
@@ -63,6 +64,8 @@ func (s *apparmorpromptingSuite) SetUpTest(c *C) {
 
 	s.st = state.New(nil)
 	s.defaultUser = 1000
+
+	s.AddCleanup(apparmorprompting.MockGadgetAdminRulesFile(func(*state.State) string { return "" }))
 }
 
 func requestWithReplyChan(req *prompting.Request) (*prompting.Request, chan []string) {
@@ -195,7 +198,7 @@ func (s *apparmorpromptingSuite) TestStop(c *C) {
 	c.Check(err, Equals, prompting_errors.ErrPromptingClosed)
 	_, err = mgr.PromptWithID(1000, rule.ID, false)
 	c.Check(err, Equals, prompting_errors.ErrPromptingClosed)
-	_, err = mgr.HandleReply(1000, rule.ID, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", true)
+	_, err = mgr.HandleReply(1000, rule.ID, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", true, "")
 	c.Check(err, Equals, prompting_errors.ErrPromptingClosed)
 	_, err = mgr.Rules(1000, "foo", "bar")
 	c.Check(err, IsNil) // rule backend supports getting rules even after closed
@@ -310,7 +313,7 @@ func (s *apparmorpromptingSuite) TestHandleReplySimple(c *C) {
 		"permissions":  json.RawMessage(`["read"]`),
 	}
 	clientActivity := true
-	satisfied, err := mgr.HandleReply(s.defaultUser, prompt.ID, constraintsJSON, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	satisfied, err := mgr.HandleReply(s.defaultUser, prompt.ID, constraintsJSON, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, IsNil)
 	c.Check(satisfied, HasLen, 0)
 
@@ -437,12 +440,12 @@ func (s *apparmorpromptingSuite) TestHandleReplyErrors(c *C) {
 
 	// Wrong user ID
 	clientActivity := true
-	result, err := mgr.HandleReply(s.defaultUser+1, prompt.ID, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	result, err := mgr.HandleReply(s.defaultUser+1, prompt.ID, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, Equals, prompting_errors.ErrPromptNotFound)
 	c.Check(result, IsNil)
 
 	// Wrong prompt ID
-	result, err = mgr.HandleReply(s.defaultUser, prompt.ID+1, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	result, err = mgr.HandleReply(s.defaultUser, prompt.ID+1, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, Equals, prompting_errors.ErrPromptNotFound)
 	c.Check(result, IsNil)
 
@@ -451,7 +454,7 @@ func (s *apparmorpromptingSuite) TestHandleReplyErrors(c *C) {
 		"path-pattern": json.RawMessage(`"/home/test/**"`),
 		"permissions":  json.RawMessage(`["foo"]`),
 	}
-	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, invalidConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, invalidConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, ErrorMatches, "cannot decode request body into prompt reply: invalid permissions for home interface:.*")
 	c.Check(result, IsNil)
 
@@ -460,7 +463,7 @@ func (s *apparmorpromptingSuite) TestHandleReplyErrors(c *C) {
 		"path-pattern": json.RawMessage(`"/home/test/other"`),
 		"permissions":  json.RawMessage(`["read"]`),
 	}
-	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, badPatternConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, badPatternConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, ErrorMatches, "path pattern in reply constraints does not match originally requested path.*")
 	c.Check(result, IsNil)
 
@@ -469,7 +472,7 @@ func (s *apparmorpromptingSuite) TestHandleReplyErrors(c *C) {
 		"path-pattern": json.RawMessage(`"/home/test/foo"`),
 		"permissions":  json.RawMessage(`["write"]`),
 	}
-	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, badPermissionConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity)
+	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, badPermissionConstraints, prompting.OutcomeAllow, prompting.LifespanSingle, "", clientActivity, "")
 	c.Check(err, ErrorMatches, "permissions in reply constraints do not include all requested permissions.*")
 	c.Check(result, IsNil)
 
@@ -488,7 +491,7 @@ func (s *apparmorpromptingSuite) TestHandleReplyErrors(c *C) {
 		"path-pattern": json.RawMessage(`"/home/test/{foo,other}"`),
 		"permissions":  json.RawMessage(`["read"]`),
 	}
-	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, conflictingConstraints, conflictingOutcome, prompting.LifespanForever, "", clientActivity)
+	result, err = mgr.HandleReply(s.defaultUser, prompt.ID, conflictingConstraints, conflictingOutcome, prompting.LifespanForever, "", clientActivity, "")
 	c.Check(err, ErrorMatches, "cannot add rule.*")
 	c.Check(result, IsNil)
 
@@ -597,7 +600,7 @@ func (s *apparmorpromptingSuite) testAskWithOutcome(c *C, outcome prompting.Outc
 	constraintsJSON := prompting.ConstraintsJSON{
 		"permissions": json.RawMessage(`["access"]`),
 	}
-	satisfied, err := mgr.HandleReply(uid, prompt.ID, constraintsJSON, outcome, prompting.LifespanSingle, "", true)
+	satisfied, err := mgr.HandleReply(uid, prompt.ID, constraintsJSON, outcome, prompting.LifespanSingle, "", true, "")
 	c.Check(err, IsNil)
 	c.Check(satisfied, HasLen, 0)
 
@@ -867,6 +870,310 @@ func (s *apparmorpromptingSuite) TestExistingRuleAllowsNewPrompt(c *C) {
 	c.Assert(mgr.Stop(), IsNil)
 }
 
+func (s *apparmorpromptingSuite) TestAdminRulesAndImportRules(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsAdminRulesDir, 0o755), IsNil)
+	err := os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsAdminRulesDir, "corp.json"), []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/test/.ssh/**","permissions":{"read":{"outcome":"deny","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+	gadgetRulesFile := filepath.Join(c.MkDir(), "prompting-rules.json")
+	err = os.WriteFile(gadgetRulesFile, []byte(`{"rules":[
+{"interface":"home","constraints":{"path-pattern":"/home/test/Public/**","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}}
+]}`), 0o644)
+	c.Assert(err, IsNil)
+	restore := apparmorprompting.MockGadgetAdminRulesFile(func(st *state.State) string {
+		c.Check(st, Equals, s.st)
+		return gadgetRulesFile
+	})
+	defer restore()
+
+	_, reqChan, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.st)
+	c.Assert(err, IsNil)
+
+	adminRules, err := mgr.AdminRules("firefox", "home")
+	c.Assert(err, IsNil)
+	c.Assert(adminRules, HasLen, 2)
+	c.Check(adminRules[0].Source, Equals, "admin:corp.json")
+	c.Check(adminRules[1].Source, Equals, "gadget")
+	adminRules, err = mgr.AdminRules("", "camera")
+	c.Assert(err, IsNil)
+	c.Check(adminRules, HasLen, 0)
+
+	// admin rules apply to requests without any rule of the user
+	req, replyChan := requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/.ssh/id_rsa",
+		Permissions: []string{"read"},
+	})
+	s.fillInPartialRequest(req)
+	reqChan <- req
+	allowedPermissions, err := waitForReply(replyChan)
+	c.Assert(err, IsNil)
+	c.Check(allowedPermissions, HasLen, 0)
+
+	// imported rules resolve outstanding prompts
+	whenSent := time.Now()
+	req, replyChan = requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/Documents/foo",
+		Permissions: []string{"read"},
+	})
+	s.fillInPartialRequest(req)
+	reqChan <- req
+	time.Sleep(10 * time.Millisecond)
+	s.checkRecordedPromptNotices(c, whenSent, 1)
+
+	contents := []*requestrules.RuleContents{{
+		Snap:      "firefox",
+		Interface: "home",
+		Constraints: prompting.ConstraintsJSON{
+			"path-pattern": json.RawMessage(`"/home/test/Documents/**"`),
+			"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+		},
+	}}
+	rules, err := mgr.ImportRules(s.defaultUser, contents)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 1)
+	allowedPermissions, err = waitForReply(replyChan)
+	c.Assert(err, IsNil)
+	c.Check(allowedPermissions, DeepEquals, []string{"read"})
+
+	c.Assert(mgr.Stop(), IsNil)
+}
+
+func (s *apparmorpromptingSuite) TestAuditLog(c *C) {
+	_, reqChan, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.st)
+	c.Assert(err, IsNil)
+
+	// requests from the root user are denied
+	req, replyChan := requestWithReplyChan(&prompting.Request{})
+	s.fillInPartialRequest(req)
+	req.UID = 0
+	reqChan <- req
+	_, err = waitForReply(replyChan)
+	c.Assert(err, IsNil)
+
+	// a prompt is created and replied to with a new rule
+	req, replyChan = requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/Documents/foo",
+		Permissions: []string{"read", "write"},
+	})
+	_, prompt := s.simulateRequest(c, reqChan, mgr, req, false)
+	constraintsJSON := prompting.ConstraintsJSON{
+		"path-pattern": json.RawMessage(`"/home/test/Documents/**"`),
+		"permissions":  json.RawMessage(`["read","write"]`),
+	}
+	_, err = mgr.HandleReply(s.defaultUser, prompt.ID, constraintsJSON, prompting.OutcomeAllow, prompting.LifespanForever, "", true, "pid=4321")
+	c.Assert(err, IsNil)
+	_, err = waitForReply(replyChan)
+	c.Assert(err, IsNil)
+	rules, err := mgr.Rules(s.defaultUser, "firefox", "home")
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 1)
+	replyRuleID := rules[0].ID.String()
+
+	// the new rule decides later requests
+	req, replyChan = requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/Documents/bar",
+		Permissions: []string{"read"},
+	})
+	s.fillInPartialRequest(req)
+	reqChan <- req
+	_, err = waitForReply(replyChan)
+	c.Assert(err, IsNil)
+
+	// a rule added later resolves an outstanding prompt
+	req, replyChan = requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/Music/foo",
+		Permissions: []string{"read"},
+	})
+	_, prompt = s.simulateRequest(c, reqChan, mgr, req, false)
+	constraintsJSON = prompting.ConstraintsJSON{
+		"path-pattern": json.RawMessage(`"/home/test/Music/**"`),
+		"permissions":  json.RawMessage(`{"read":{"outcome":"deny","lifespan":"forever"}}`),
+	}
+	addedRule, err := mgr.AddRule(s.defaultUser, "firefox", "home", constraintsJSON)
+	c.Assert(err, IsNil)
+	_, err = waitForReply(replyChan)
+	c.Assert(err, IsNil)
+
+	entries, err := mgr.AuditEntries(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 6)
+	for _, entry := range entries {
+		c.Check(entry.Snap, Equals, "firefox")
+		c.Check(entry.Interface, Equals, "home")
+		c.Check(entry.PID, Equals, int32(1234))
+		c.Check(entry.Timestamp.IsZero(), Equals, false)
+	}
+
+	c.Check(entries[0].User, Equals, uint32(0))
+	c.Check(entries[0].DecidedBy, Equals, requestaudit.DecidedByPolicy)
+	c.Check(entries[0].Denied, DeepEquals, []string{"read"})
+	c.Check(entries[0].Reason, Equals, "request from root user")
+
+	c.Check(entries[1].User, Equals, s.defaultUser)
+	c.Check(entries[1].DecidedBy, Equals, requestaudit.DecidedByPrompt)
+	c.Check(entries[1].Path, Equals, "/home/test/Documents/foo")
+	c.Check(entries[1].Outstanding, DeepEquals, []string{"read", "write"})
+	c.Check(entries[1].PromptID, Not(Equals), "")
+
+	c.Check(entries[2].DecidedBy, Equals, requestaudit.DecidedByReply)
+	c.Check(entries[2].PromptID, Equals, entries[1].PromptID)
+	c.Check(entries[2].Allowed, DeepEquals, []string{"read", "write"})
+	c.Check(entries[2].Rules, DeepEquals, []string{replyRuleID})
+	c.Check(entries[2].Client, Equals, "pid=4321")
+
+	c.Check(entries[3].DecidedBy, Equals, requestaudit.DecidedByRules)
+	c.Check(entries[3].Path, Equals, "/home/test/Documents/bar")
+	c.Check(entries[3].Allowed, DeepEquals, []string{"read"})
+	c.Check(entries[3].Rules, DeepEquals, []string{replyRuleID})
+
+	c.Check(entries[4].DecidedBy, Equals, requestaudit.DecidedByPrompt)
+	c.Check(entries[4].PromptID, Equals, prompt.ID.String())
+
+	c.Check(entries[5].DecidedBy, Equals, requestaudit.DecidedByNewRule)
+	c.Check(entries[5].PromptID, Equals, prompt.ID.String())
+	c.Check(entries[5].Denied, DeepEquals, []string{"read"})
+	c.Check(entries[5].Rules, DeepEquals, []string{addedRule.ID.String()})
+
+	user := uint32(0)
+	entries, err = mgr.AuditEntries(&requestaudit.Filter{User: &user})
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+
+	c.Assert(mgr.Stop(), IsNil)
+}
+
+func (s *apparmorpromptingSuite) TestAuditLogCancelledPrompts(c *C) {
+	_, reqChan, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.st)
+	c.Assert(err, IsNil)
+
+	req, _ := requestWithReplyChan(&prompting.Request{
+		Path:        "/home/test/Documents/foo",
+		Permissions: []string{"read", "write"},
+	})
+	_, prompt := s.simulateRequest(c, reqChan, mgr, req, false)
+
+	// prompts which are still outstanding when snapd stops are cancelled
+	c.Assert(mgr.Stop(), IsNil)
+
+	auditLog, err := requestaudit.Open()
+	c.Assert(err, IsNil)
+	defer auditLog.Close()
+	entries, err := auditLog.Entries(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	c.Check(entries[0].DecidedBy, Equals, requestaudit.DecidedByPrompt)
+	c.Check(entries[0].PromptID, Equals, prompt.ID.String())
+
+	c.Check(entries[1].User, Equals, s.defaultUser)
+	c.Check(entries[1].Snap, Equals, "firefox")
+	c.Check(entries[1].Interface, Equals, "home")
+	c.Check(entries[1].Path, Equals, "/home/test/Documents/foo")
+	c.Check(entries[1].Permissions, DeepEquals, []string{"read", "write"})
+	c.Check(entries[1].DecidedBy, Equals, requestaudit.DecidedByPolicy)
+	c.Check(entries[1].PromptID, Equals, prompt.ID.String())
+	c.Check(entries[1].Reason, Equals, "cancelled")
+	// the request is re-received once snapd restarts, so it is not denied
+	c.Check(entries[1].Denied, HasLen, 0)
+	c.Check(entries[1].Outstanding, DeepEquals, []string{"read", "write"})
+}
+
+func (s *apparmorpromptingSuite) TestExistingRuleAllowsNewPromptFileInterfaces(c *C) {
+	_, reqChan, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.st)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		iface       string
+		pathPattern string
+		path        string
+		permissions []string
+	}{
+		{"removable-media", "/media/test/usb/**", "/media/test/usb/foo.txt", []string{"read", "write", "execute"}},
+		{"personal-files", "/home/test/.config/firefox/**", "/home/test/.config/firefox/prefs.js", []string{"read", "write"}},
+	} {
+		permissions := make(map[string]any)
+		for _, perm := range t.permissions {
+			permissions[perm] = map[string]any{"outcome": "allow", "lifespan": "forever"}
+		}
+		permissionsJSON, err := json.Marshal(permissions)
+		c.Assert(err, IsNil)
+		constraints := prompting.ConstraintsJSON{
+			"path-pattern": json.RawMessage(fmt.Sprintf("%q", t.pathPattern)),
+			"permissions":  permissionsJSON,
+		}
+		_, err = mgr.AddRule(s.defaultUser, "firefox", t.iface, constraints)
+		c.Assert(err, IsNil, Commentf("%s", t.iface))
+
+		// A request from the interface is allowed by the rule
+		req, replyChan := requestWithReplyChan(&prompting.Request{
+			Interface:   t.iface,
+			Path:        t.path,
+			Permissions: t.permissions,
+		})
+		s.fillInPartialRequest(req)
+		reqChan <- req
+		allowedPermissions, err := waitForReply(replyChan)
+		c.Assert(err, IsNil, Commentf("%s", t.iface))
+		c.Check(allowedPermissions, DeepEquals, t.permissions)
+
+		// But the rule does not apply to the same path from another interface
+		whenSent := time.Now()
+		req, replyChan = requestWithReplyChan(&prompting.Request{
+			Interface:   "home",
+			Path:        t.path,
+			Permissions: []string{"read"},
+		})
+		s.fillInPartialRequest(req)
+		reqChan <- req
+		time.Sleep(10 * time.Millisecond)
+		_, err = waitForReply(replyChan)
+		c.Check(err, Equals, errNoReply)
+		s.checkRecordedPromptNotices(c, whenSent, 1)
+	}
+
+	// A prompt from a file interface offers the interface permissions
+	whenSent := time.Now()
+	req, _ := requestWithReplyChan(&prompting.Request{
+		Interface:   "personal-files",
+		Path:        "/home/test/.gitconfig",
+		Permissions: []string{"write"},
+	})
+	s.fillInPartialRequest(req)
+	reqChan <- req
+	time.Sleep(10 * time.Millisecond)
+	s.checkRecordedPromptNotices(c, whenSent, 1)
+
+	clientActivity := false
+	prompts, err := mgr.Prompts(s.defaultUser, clientActivity)
+	c.Assert(err, IsNil)
+	var found bool
+	for _, prompt := range prompts {
+		if prompt.Interface != "personal-files" {
+			continue
+		}
+		found = true
+		data, err := json.Marshal(prompt)
+		c.Assert(err, IsNil)
+		c.Check(string(data), Matches, `.*"constraints":\{"path":"/home/test/.gitconfig","requested-permissions":\["write"\],"available-permissions":\["read","write"\]\}.*`)
+	}
+	c.Check(found, Equals, true)
+
+	c.Assert(mgr.Stop(), IsNil)
+}
+
 func (s *apparmorpromptingSuite) checkRecordedPromptNotices(c *C, since time.Time, count int) {
 	s.st.Lock()
 	n := s.st.Notices(&state.NoticeFilter{
@@ -1188,7 +1495,7 @@ func (s *apparmorpromptingSuite) TestReplyNewRuleHandlesExistingPrompt(c *C) {
 		"permissions":  json.RawMessage(`["read"]`),
 	}
 	clientActivity := true
-	satisfiedPromptIDs, err := mgr.HandleReply(s.defaultUser, readPrompt.ID, constraints, prompting.OutcomeDeny, prompting.LifespanTimespan, "10s", clientActivity)
+	satisfiedPromptIDs, err := mgr.HandleReply(s.defaultUser, readPrompt.ID, constraints, prompting.OutcomeDeny, prompting.LifespanTimespan, "10s", clientActivity, "")
 	c.Check(err, IsNil)
 
 	// Check that rw prompt was also satisfied
@@ -1271,7 +1578,7 @@ func (s *apparmorpromptingSuite) testReplyRuleHandlesFuturePrompts(c *C, outcome
 		"permissions":  json.RawMessage(`["read","write"]`),
 	}
 	clientActivity := false
-	satisfiedPromptIDs, err := mgr.HandleReply(s.defaultUser, readPrompt.ID, constraints, outcome, lifespan, duration, clientActivity)
+	satisfiedPromptIDs, err := mgr.HandleReply(s.defaultUser, readPrompt.ID, constraints, outcome, lifespan, duration, clientActivity, "")
 	c.Check(err, IsNil)
 
 	// Check that kernel received reply
@@ -1953,7 +2260,7 @@ func (s *apparmorpromptingSuite) TestPromptsReadyBlocksRepliesNewRules(c *C) {
 	})
 
 	s.testReadyBlocks(c, func(mgr *apparmorprompting.InterfacesRequestsManager) {
-		_, err := mgr.HandleReply(1000, 0, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", false)
+		_, err := mgr.HandleReply(1000, 0, nil, prompting.OutcomeAllow, prompting.LifespanSingle, "", false, "")
 		c.Check(err, Equals, prompting_errors.ErrPromptNotFound)
 	})
 
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lansharestate
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
+type Responder = responder
+
+func MockListen(f func(addr string) (net.Listener, error)) (restore func()) {
+	return testutil.Mock(&listen, f)
+}
+
+func MockInterfaceAddrs(f func(name string) ([]net.Addr, error)) (restore func()) {
+	return testutil.Mock(&interfaceAddrs, f)
+}
+
+func MockLookupIP(f func(host string) ([]net.IP, error)) (restore func()) {
+	return testutil.Mock(&lookupIP, f)
+}
+
+func MockNewResponder(f func(instance string, port int, interfaces []string) (Responder, error)) (restore func()) {
+	return testutil.Mock(&newResponder, f)
+}
+
+func MockBrowse(f func(ctx context.Context, self string, timeout time.Duration) ([]string, error)) (restore func()) {
+	return testutil.Mock(&lanshareBrowse, f)
+}
+
+func MockOsHostname(f func() (string, error)) (restore func()) {
+	return testutil.Mock(&osHostname, f)
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
+
+// WaitBrowse waits for the activities of the manager started by Ensure,
+// other than sharing, to finish.
+func (m *LANShareManager) WaitBrowse() {
+	for {
+		m.mu.Lock()
+		browsing := m.browsing
+		m.mu.Unlock()
+		if !browsing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
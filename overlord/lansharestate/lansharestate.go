@@ -0,0 +1,445 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package lansharestate implements the manager sharing the snaps and
+// components in the download cache with peers on the local network.
+package lansharestate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/lanshare"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	// browseInterval is how often peers are looked for when sharing is
+	// enabled without a configured list of peers.
+	browseInterval = 10 * time.Minute
+	browseTimeout  = 2 * time.Second
+
+	timeNow    = time.Now
+	osHostname = os.Hostname
+
+	listen = func(addr string) (net.Listener, error) {
+		return net.Listen("tcp", addr)
+	}
+	interfaceAddrs = localInterfaceAddrs
+	lookupIP       = net.LookupIP
+	newResponder   = func(instance string, port int, interfaces []string) (responder, error) {
+		return lanshare.NewResponder(instance, port, interfaces)
+	}
+	lanshareBrowse = lanshare.Browse
+)
+
+type responder interface {
+	Serve()
+	Close() error
+}
+
+// discoveredPeersKey is the key of the peers discovered on the local network
+// in the state cache.
+type discoveredPeersKey struct{}
+
+// sharingSettings is the configuration of sharing with peers.
+type sharingSettings struct {
+	enabled bool
+	// peers are the addresses of the configured peers
+	peers []string
+	// interfaces are the network interfaces sharing is restricted to
+	interfaces []string
+}
+
+// sameClients returns whether the peers and network interfaces, which
+// determine who blobs are shared with, are the same in both settings.
+func (s *sharingSettings) sameClients(other *sharingSettings) bool {
+	return strings.Join(s.peers, ",") == strings.Join(other.peers, ",") &&
+		strings.Join(s.interfaces, ",") == strings.Join(other.interfaces, ",")
+}
+
+// sharingConfig returns the configuration of sharing with peers.
+//
+// The state must be locked by the caller.
+func sharingConfig(st *state.State) (*sharingSettings, error) {
+	tr := config.NewTransaction(st)
+	settings := &sharingSettings{}
+	if err := tr.GetMaybe("core", "store.lan-sharing", &settings.enabled); err != nil {
+		return nil, err
+	}
+	if !settings.enabled {
+		return settings, nil
+	}
+	var configured string
+	if err := tr.GetMaybe("core", "store.lan-peers", &configured); err != nil {
+		return nil, err
+	}
+	for _, peer := range strings.Split(configured, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+		addr, err := lanshare.PeerAddress(peer)
+		if err != nil {
+			// validated when configured
+			logger.Noticef("ignoring invalid peer address: %v", err)
+			continue
+		}
+		settings.peers = append(settings.peers, addr)
+	}
+	var interfaces string
+	if err := tr.GetMaybe("core", "store.lan-interfaces", &interfaces); err != nil {
+		return nil, err
+	}
+	settings.interfaces = strutil.CommaSeparatedList(interfaces)
+	return settings, nil
+}
+
+// Peers returns the addresses of the peers on the local network to fetch
+// snaps and components from, or nil if sharing is disabled. These are either
+// the configured peers or, if none are, those discovered through multicast
+// DNS.
+//
+// The state must be locked by the caller.
+func Peers(st *state.State) ([]string, error) {
+	settings, err := sharingConfig(st)
+	if err != nil || !settings.enabled {
+		return nil, err
+	}
+	if len(settings.peers) > 0 {
+		return settings.peers, nil
+	}
+	discovered, _ := st.Cached(discoveredPeersKey{}).([]string)
+	return discovered, nil
+}
+
+// LANShareManager shares the download cache with peers on the local network,
+// announcing it through multicast DNS, and looks for peers doing the same,
+// when enabled through the store.lan-sharing option.
+type LANShareManager struct {
+	state *state.State
+	cache lanshare.Cache
+
+	mu         sync.Mutex
+	settings   *sharingSettings
+	server     *http.Server
+	responder  responder
+	instance   string
+	browsing   bool
+	nextBrowse time.Time
+	wg         sync.WaitGroup
+}
+
+// Manager returns a new LANShareManager.
+func Manager(st *state.State) *LANShareManager {
+	return &LANShareManager{
+		state: st,
+		cache: store.NewCacheManager(dirs.SnapDownloadCacheDir, store.CachePolicy{}),
+	}
+}
+
+// instanceName returns the name under which the device is announced, which
+// is its host name without any domain.
+func instanceName() (string, error) {
+	hostname, err := osHostname()
+	if err != nil {
+		return "", err
+	}
+	if i := strings.IndexByte(hostname, '.'); i >= 0 {
+		hostname = hostname[:i]
+	}
+	if hostname == "" {
+		return "", errors.New("empty host name")
+	}
+	return hostname, nil
+}
+
+// Ensure starts or stops sharing according to the configuration, and
+// periodically looks for peers.
+func (m *LANShareManager) Ensure() error {
+	m.state.Lock()
+	settings, err := sharingConfig(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !settings.enabled {
+		m.stopSharing()
+		return nil
+	}
+	if m.server != nil && !m.settings.sameClients(settings) {
+		// who blobs are shared with changed
+		m.closeSharing()
+	}
+	if m.server == nil {
+		if err := m.startSharing(settings); err != nil {
+			return err
+		}
+	}
+	if len(settings.peers) == 0 && !m.browsing && !timeNow().Before(m.nextBrowse) {
+		m.browsing = true
+		m.wg.Add(1)
+		go m.browse()
+	}
+	return nil
+}
+
+// localInterfaceAddrs returns the addresses of the given network interface,
+// or of all the network interfaces which are up, other than loopback ones, if
+// name is empty.
+func localInterfaceAddrs(name string) ([]net.Addr, error) {
+	if name != "" {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		return ifi.Addrs()
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []net.Addr
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ifiAddrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, ifiAddrs...)
+	}
+	return addrs, nil
+}
+
+// interfaceNetwork is a network which a local interface is part of.
+type interfaceNetwork struct {
+	iface string
+	ipNet *net.IPNet
+}
+
+// sharingNetworks returns the networks the given interfaces, or all of them
+// if none are given, are part of.
+func sharingNetworks(interfaces []string) ([]interfaceNetwork, error) {
+	if len(interfaces) == 0 {
+		interfaces = []string{""}
+	}
+	var networks []interfaceNetwork
+	for _, iface := range interfaces {
+		addrs, err := interfaceAddrs(iface)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				networks = append(networks, interfaceNetwork{iface: iface, ipNet: ipNet})
+			}
+		}
+	}
+	if len(networks) == 0 {
+		return nil, errors.New("no network to share on")
+	}
+	return networks, nil
+}
+
+// allowedClients returns a function accepting only the IP addresses of the
+// given peers if any, or the addresses on the given networks otherwise.
+func allowedClients(peers []string, networks []interfaceNetwork) func(ip net.IP) bool {
+	if len(peers) == 0 {
+		return func(ip net.IP) bool {
+			for _, n := range networks {
+				if n.ipNet.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	var peerIPs []net.IP
+	for _, peer := range peers {
+		host, _, err := net.SplitHostPort(peer)
+		if err != nil {
+			continue
+		}
+		ips, err := lookupIP(host)
+		if err != nil {
+			logger.Noticef("cannot share snaps with peer %s: %v", host, err)
+			continue
+		}
+		peerIPs = append(peerIPs, ips...)
+	}
+	return func(ip net.IP) bool {
+		for _, peerIP := range peerIPs {
+			if peerIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// listenAddresses returns the addresses to listen on, which are those of the
+// given networks if sharing is restricted to some interfaces, or any address
+// otherwise.
+func listenAddresses(interfaces []string, networks []interfaceNetwork) []string {
+	port := strconv.Itoa(lanshare.DefaultPort)
+	if len(interfaces) == 0 {
+		return []string{net.JoinHostPort("", port)}
+	}
+	addrs := make([]string, 0, len(networks))
+	for _, n := range networks {
+		host := n.ipNet.IP.String()
+		if n.ipNet.IP.To4() == nil && n.ipNet.IP.IsLinkLocalUnicast() {
+			host += "%" + n.iface
+		}
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+	return addrs
+}
+
+// startSharing must be called with the manager lock held.
+func (m *LANShareManager) startSharing(settings *sharingSettings) error {
+	networks, err := sharingNetworks(settings.interfaces)
+	if err != nil {
+		return fmt.Errorf("cannot share snaps with peers: %v", err)
+	}
+	var listeners []net.Listener
+	for _, addr := range listenAddresses(settings.interfaces, networks) {
+		l, err := listen(addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("cannot share snaps with peers: %v", err)
+		}
+		listeners = append(listeners, l)
+	}
+	server := &http.Server{
+		Handler:           lanshare.AllowClients(lanshare.NewHandler(m.cache), allowedClients(settings.peers, networks)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	m.server = server
+	m.settings = settings
+	for _, l := range listeners {
+		m.wg.Add(1)
+		go func(l net.Listener) {
+			defer m.wg.Done()
+			if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+				logger.Noticef("cannot share snaps with peers: %v", err)
+			}
+		}(l)
+	}
+
+	instance, err := instanceName()
+	if err != nil {
+		logger.Noticef("cannot announce snap sharing to peers: %v", err)
+		return nil
+	}
+	m.instance = instance
+	port := listeners[0].Addr().(*net.TCPAddr).Port
+	r, err := newResponder(instance, port, settings.interfaces)
+	if err != nil {
+		// peers can still be configured explicitly
+		logger.Noticef("cannot announce snap sharing to peers: %v", err)
+		return nil
+	}
+	m.responder = r
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		r.Serve()
+	}()
+	logger.Noticef("Sharing snaps with peers on port %d.", port)
+	return nil
+}
+
+// closeSharing must be called with the manager lock held.
+func (m *LANShareManager) closeSharing() {
+	if m.responder != nil {
+		m.responder.Close()
+		m.responder = nil
+	}
+	if m.server != nil {
+		m.server.Close()
+		m.server = nil
+	}
+}
+
+// stopSharing must be called with the manager lock held.
+func (m *LANShareManager) stopSharing() {
+	if m.server == nil {
+		return
+	}
+	m.closeSharing()
+	m.nextBrowse = time.Time{}
+	m.state.Lock()
+	m.state.Cache(discoveredPeersKey{}, nil)
+	m.state.Unlock()
+}
+
+func (m *LANShareManager) browse() {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	instance := m.instance
+	m.mu.Unlock()
+
+	peers, err := lanshareBrowse(context.Background(), instance, browseTimeout)
+	if err != nil {
+		logger.Noticef("cannot look for peers to share snaps with: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.browsing = false
+	m.nextBrowse = timeNow().Add(browseInterval)
+	if m.server == nil {
+		// sharing was disabled meanwhile
+		return
+	}
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.state.Cache(discoveredPeersKey{}, peers)
+}
+
+// Stop stops sharing and waits for the activities of the manager to finish.
+func (m *LANShareManager) Stop() {
+	m.mu.Lock()
+	m.closeSharing()
+	m.mu.Unlock()
+	m.wg.Wait()
+}
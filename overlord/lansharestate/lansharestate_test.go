@@ -0,0 +1,306 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lansharestate_test
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/lansharestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type lanshareSuite struct {
+	testutil.BaseTest
+
+	st       *state.State
+	addr     string
+	listened []string
+	networks map[string][]string
+
+	responders []*fakeResponder
+	browsed    []string
+}
+
+var _ = Suite(&lanshareSuite{})
+
+type fakeResponder struct {
+	instance   string
+	port       int
+	interfaces []string
+	closed     chan struct{}
+}
+
+func (r *fakeResponder) Serve() {
+	<-r.closed
+}
+
+func (r *fakeResponder) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func (s *lanshareSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.st = state.New(nil)
+	s.responders = nil
+	s.browsed = nil
+	s.addr = ""
+	s.listened = nil
+	// requests in tests come from the loopback interface
+	s.networks = map[string][]string{
+		"":     {"127.0.0.1/8", "10.0.0.1/24"},
+		"eth0": {"10.0.0.1/24", "fe80::1/64"},
+	}
+
+	s.AddCleanup(lansharestate.MockListen(func(addr string) (net.Listener, error) {
+		s.listened = append(s.listened, addr)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err == nil {
+			s.addr = l.Addr().String()
+		}
+		return l, err
+	}))
+	s.AddCleanup(lansharestate.MockInterfaceAddrs(func(name string) ([]net.Addr, error) {
+		cidrs, ok := s.networks[name]
+		if !ok {
+			return nil, fmt.Errorf("no such network interface")
+		}
+		var addrs []net.Addr
+		for _, cidr := range cidrs {
+			ip, ipNet, err := net.ParseCIDR(cidr)
+			c.Assert(err, IsNil)
+			ipNet.IP = ip
+			addrs = append(addrs, ipNet)
+		}
+		return addrs, nil
+	}))
+	s.AddCleanup(lansharestate.MockLookupIP(func(host string) ([]net.IP, error) {
+		if host == "device-2.local" {
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}))
+	s.AddCleanup(lansharestate.MockNewResponder(func(instance string, port int, interfaces []string) (lansharestate.Responder, error) {
+		r := &fakeResponder{instance: instance, port: port, interfaces: interfaces, closed: make(chan struct{})}
+		s.responders = append(s.responders, r)
+		return r, nil
+	}))
+	s.AddCleanup(lansharestate.MockOsHostname(func() (string, error) {
+		return "device-1.example.com", nil
+	}))
+	s.AddCleanup(lansharestate.MockBrowse(func(ctx context.Context, self string, timeout time.Duration) ([]string, error) {
+		s.browsed = append(s.browsed, self)
+		return []string{"10.0.0.2:44747"}, nil
+	}))
+}
+
+func (s *lanshareSuite) set(c *C, key string, value any) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", key, value), IsNil)
+	tr.Commit()
+}
+
+func (s *lanshareSuite) peers(c *C) []string {
+	s.st.Lock()
+	defer s.st.Unlock()
+	peers, err := lansharestate.Peers(s.st)
+	c.Assert(err, IsNil)
+	return peers
+}
+
+func (s *lanshareSuite) TestDisabled(c *C) {
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.addr, Equals, "")
+	c.Check(s.responders, HasLen, 0)
+	c.Check(s.browsed, HasLen, 0)
+
+	// configured peers are not used while sharing is disabled
+	s.set(c, "store.lan-peers", "10.0.0.3")
+	c.Check(s.peers(c), IsNil)
+}
+
+func (s *lanshareSuite) TestSharingWithDiscoveredPeers(c *C) {
+	content := "some snap"
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	digest := fmt.Sprintf("%x", h.Sum(nil))
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, digest), []byte(content), 0600), IsNil)
+
+	s.set(c, "store.lan-sharing", true)
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	c.Check(s.listened, DeepEquals, []string{":44747"})
+
+	// the download cache is shared
+	rsp, err := http.Get("http://" + s.addr + "/v1/blobs/" + digest)
+	c.Assert(err, IsNil)
+	data, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, content)
+
+	// and announced
+	c.Assert(s.responders, HasLen, 1)
+	c.Check(s.responders[0].instance, Equals, "device-1")
+	c.Check(fmt.Sprintf("127.0.0.1:%d", s.responders[0].port), Equals, s.addr)
+
+	// peers were looked for, skipping the device itself
+	c.Check(s.browsed, DeepEquals, []string{"device-1"})
+	c.Check(s.peers(c), DeepEquals, []string{"10.0.0.2:44747"})
+
+	// peers are not looked for again until the interval expired
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	c.Check(s.browsed, HasLen, 1)
+	restore := lansharestate.MockTimeNow(func() time.Time { return time.Now().Add(time.Hour) })
+	defer restore()
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	c.Check(s.browsed, HasLen, 2)
+
+	// disabling sharing stops it
+	s.set(c, "store.lan-sharing", false)
+	c.Assert(m.Ensure(), IsNil)
+	_, err = http.Get("http://" + s.addr + "/v1/blobs/" + digest)
+	c.Check(err, NotNil)
+	select {
+	case <-s.responders[0].closed:
+	default:
+		c.Errorf("responder was not closed")
+	}
+	c.Check(s.peers(c), IsNil)
+}
+
+func (s *lanshareSuite) TestConfiguredPeers(c *C) {
+	s.set(c, "store.lan-sharing", true)
+	s.set(c, "store.lan-peers", "10.0.0.3, 10.0.0.4:8000")
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	c.Check(s.addr, Not(Equals), "")
+	// configured peers are used as is
+	c.Check(s.browsed, HasLen, 0)
+	c.Check(s.peers(c), DeepEquals, []string{"10.0.0.3:44747", "10.0.0.4:8000"})
+}
+
+func (s *lanshareSuite) writeBlob(c *C, content string) (digest string) {
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	digest = fmt.Sprintf("%x", h.Sum(nil))
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, digest), []byte(content), 0600), IsNil)
+	return digest
+}
+
+func (s *lanshareSuite) status(c *C, path string) int {
+	rsp, err := http.Get("http://" + s.addr + path)
+	c.Assert(err, IsNil)
+	rsp.Body.Close()
+	return rsp.StatusCode
+}
+
+func (s *lanshareSuite) TestSharingOnlyOnLocalNetworks(c *C) {
+	digest := s.writeBlob(c, "some snap")
+	// the device is not on the network of the client
+	s.networks[""] = []string{"10.0.0.1/24"}
+
+	s.set(c, "store.lan-sharing", true)
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	c.Check(s.status(c, "/v1/blobs/"+digest), Equals, 403)
+}
+
+func (s *lanshareSuite) TestSharingOnlyWithConfiguredPeers(c *C) {
+	digest := s.writeBlob(c, "some snap")
+
+	s.set(c, "store.lan-sharing", true)
+	s.set(c, "store.lan-peers", "10.0.0.3")
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	// the client is on a local network but not a configured peer
+	c.Check(s.status(c, "/v1/blobs/"+digest), Equals, 403)
+
+	// sharing is restarted when the peers change
+	s.set(c, "store.lan-peers", "10.0.0.3,device-2.local:8000")
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, HasLen, 2)
+	c.Check(s.status(c, "/v1/blobs/"+digest), Equals, 200)
+
+	// but not otherwise
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, HasLen, 2)
+}
+
+func (s *lanshareSuite) TestSharingOnConfiguredInterfaces(c *C) {
+	s.set(c, "store.lan-sharing", true)
+	s.set(c, "store.lan-interfaces", "eth0")
+	m := lansharestate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	m.WaitBrowse()
+	// only the addresses of the interface are listened on
+	c.Check(s.listened, DeepEquals, []string{"10.0.0.1:44747", "[fe80::1%eth0]:44747"})
+	// and announced on
+	c.Assert(s.responders, HasLen, 1)
+	c.Check(s.responders[0].interfaces, DeepEquals, []string{"eth0"})
+
+	s.set(c, "store.lan-interfaces", "wlan0")
+	err := m.Ensure()
+	c.Check(err, ErrorMatches, "cannot share snaps with peers: no such network interface")
+}
@@ -35,10 +35,12 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/certstate"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/clusterstate"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/confdbstate"
@@ -50,6 +52,7 @@ import (
 	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/lansharestate"
 	"github.com/snapcore/snapd/overlord/notices"
 	"github.com/snapcore/snapd/overlord/patch"
 	"github.com/snapcore/snapd/overlord/restart"
@@ -63,6 +66,7 @@ import (
 	_ "github.com/snapcore/snapd/overlord/snapstate/agentnotify"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
+	"github.com/snapcore/snapd/overlord/storemirrorstate"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/systemd"
@@ -120,6 +124,7 @@ type Overlord struct {
 	shotMgr       *snapshotstate.SnapshotManager
 	fdeMgr        *fdestate.FDEManager
 	noticeMgr     *notices.NoticeManager
+	changeArchive *changearchive.Archive
 	confdbMgr     *confdbstate.ConfdbManager
 	deviceMgmtMgr *devicemgmtstate.DeviceMgmtManager
 	certStateMgr  *certstate.CertManager
@@ -137,9 +142,14 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		inited: true,
 	}
 
-	backend := &overlordStateBackend{
-		path:         dirs.SnapStateFile,
-		ensureBefore: o.ensureBefore,
+	var backend state.Backend
+	if features.StateJournal.IsEnabled() {
+		backend = newJournalStateBackend(dirs.SnapStateFile, o.ensureBefore)
+	} else {
+		backend = &overlordStateBackend{
+			path:         dirs.SnapStateFile,
+			ensureBefore: o.ensureBefore,
+		}
 	}
 	s, restartMgr, err := o.loadState(backend, restartHandler)
 	if err != nil {
@@ -148,6 +158,11 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 
 	o.noticeMgr = notices.NewNoticeManager(s)
 
+	o.changeArchive = changearchive.New(dirs.SnapChangesArchiveDir)
+	s.Lock()
+	s.AddChangePrunedHandler(o.archivePrunedChange)
+	s.Unlock()
+
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
 
@@ -205,6 +220,8 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	o.addManager(snapshotstate.Manager(s, o.runner))
 	o.addManager(confdbstate.Manager(s, hookMgr, o.runner))
 	o.addManager(certstate.Manager(s, o.runner))
+	o.addManager(lansharestate.Manager(s))
+	o.addManager(storemirrorstate.Manager(s))
 
 	if err := configstateInit(s, hookMgr); err != nil {
 		return nil, err
@@ -336,15 +353,16 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return s, restartMgr, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
-	if err != nil {
-		return nil, nil, fmt.Errorf("cannot read the state file: %s", err)
+	if _, ok := backend.(state.JournalBackend); !ok {
+		// the state-journal feature was disabled
+		if err := state.FoldJournal(dirs.SnapStateFile); err != nil {
+			return nil, nil, err
+		}
 	}
-	defer r.Close()
 
 	var s *state.State
 	timings.Run(perfTimings, "read-state", "read snapd state from disk", func(tm timings.Measurer) {
-		s, err = state.ReadState(backend, r)
+		s, err = state.ReadStateFile(backend, dirs.SnapStateFile)
 	})
 	if err != nil {
 		return nil, nil, err
@@ -366,6 +384,14 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 	return s, restartMgr, nil
 }
 
+// archivePrunedChange keeps the given change, which is being pruned from the
+// state, in the changes archive for later inspection.
+func (o *Overlord) archivePrunedChange(chg *state.Change) {
+	if err := o.changeArchive.Add(changearchive.FromChange(chg)); err != nil {
+		logger.Noticef("Cannot archive change %s: %v", chg.ID(), err)
+	}
+}
+
 func initRestart(s *state.State, curBootID string, restartHandler restart.Handler) (*restart.RestartManager, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -552,6 +578,13 @@ func (o *Overlord) Stop() error {
 		err = o.loopTomb.Wait()
 	}
 	o.stateEng.Stop()
+	// the state might next be read by a snapd which does not know about
+	// the state journal, like one reverted to
+	if st := o.State(); st.UsesJournal() {
+		st.Lock()
+		st.StopJournal()
+		st.Unlock()
+	}
 	if o.stateFLock != nil {
 		// This will also unlock the file
 		o.stateFLock.Close()
@@ -747,6 +780,11 @@ func (o *Overlord) NoticeManager() *notices.NoticeManager {
 	return o.noticeMgr
 }
 
+// ChangeArchive returns the archive of the changes pruned from the state.
+func (o *Overlord) ChangeArchive() *changearchive.Archive {
+	return o.changeArchive
+}
+
 // ConfdbManager returns the manager responsible for accesses to confdb.
 func (o *Overlord) ConfdbManager() *confdbstate.ConfdbManager {
 	return o.confdbMgr
@@ -780,6 +818,7 @@ func MockWithState(s *state.State) *Overlord {
 	}
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
+	o.changeArchive = changearchive.New(dirs.SnapChangesArchiveDir)
 
 	return o
 }
@@ -39,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/dirs/dirstest"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
@@ -248,6 +249,105 @@ func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	c.Check(got, DeepEquals, expected)
 }
 
+func (ovs *overlordSuite) TestNewWithStateJournal(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version))
+	err := os.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(os.WriteFile(features.StateJournal.ControlFile(), nil, 0644), IsNil)
+	journalPath := state.JournalPath(dirs.SnapStateFile)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	st := o.State()
+	// the first checkpoint rewrites the state file
+	st.Lock()
+	st.Set("other", "data")
+	st.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"other":"data"`)
+	c.Check(journalPath, testutil.FileEquals, state.JournalHeader(readFile(c, dirs.SnapStateFile)))
+
+	st.Lock()
+	st.Set("some", "other-data")
+	st.Unlock()
+
+	// the next change went to the journal, on top of the state file
+	c.Check(journalPath, testutil.FileContains, `{"data":{"some":"other-data"}}`)
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), "other-data")
+
+	// and is read back from it
+	st2, err := state.ReadStateFile(nil, dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	st2.Lock()
+	var some string
+	c.Check(st2.Get("some", &some), IsNil)
+	st2.Unlock()
+	c.Check(some, Equals, "other-data")
+
+	// stopping folds the journal into the state file, for a snapd which
+	// does not know about it
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"other-data"`)
+	c.Check(journalPath, testutil.FileEquals, state.JournalHeader(readFile(c, dirs.SnapStateFile)))
+
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	st = o.State()
+	st.Lock()
+	c.Check(st.Get("some", &some), IsNil)
+	st.Unlock()
+	c.Check(some, Equals, "other-data")
+	st.Lock()
+	st.Set("some", "more-data")
+	st.Unlock()
+	c.Assert(o.Stop(), IsNil)
+
+	// disabling the feature folds the journal back into the state file
+	c.Assert(os.Remove(features.StateJournal.ControlFile()), IsNil)
+	o, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(journalPath, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"more-data"`)
+	st = o.State()
+	st.Lock()
+	c.Check(st.Get("some", &some), IsNil)
+	st.Unlock()
+	c.Check(some, Equals, "more-data")
+	c.Assert(o.Stop(), IsNil)
+}
+
+func readFile(c *C, path string) []byte {
+	data, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	return data
+}
+
+func (ovs *overlordSuite) TestJournalStateBackendCompaction(c *C) {
+	restore := overlord.MockJournalCompactionMinSize(10)
+	defer restore()
+
+	b := overlord.NewJournalStateBackend(dirs.SnapStateFile)
+	c.Check(b.NeedsCompaction(), Equals, true)
+	c.Check(b.AppendDelta([]byte(`{}`)), ErrorMatches, "internal error: cannot append to the state journal before checkpointing")
+
+	base := []byte(`{"data":{}}`)
+	c.Assert(b.Checkpoint(base), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, base)
+	journalPath := state.JournalPath(dirs.SnapStateFile)
+	c.Check(journalPath, testutil.FileEquals, state.JournalHeader(base))
+	c.Check(b.NeedsCompaction(), Equals, false)
+
+	// the journal is compacted once its deltas are as large as the base
+	c.Assert(b.AppendDelta([]byte(`{"data":{"a":1}}`)), IsNil)
+	c.Check(journalPath, testutil.FileEquals, string(state.JournalHeader(base))+`{"data":{"a":1}}`+"\n")
+	c.Check(b.NeedsCompaction(), Equals, true)
+
+	base = []byte(`{"data":{"a":1}}`)
+	c.Assert(b.Checkpoint(base), IsNil)
+	c.Check(journalPath, testutil.FileEquals, state.JournalHeader(base))
+	c.Check(b.NeedsCompaction(), Equals, false)
+}
+
 func (ovs *overlordSuite) TestNewWithStateSnapmgrUpdate(c *C) {
 	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level))
 	err := os.WriteFile(dirs.SnapStateFile, fakeState, 0600)
@@ -660,6 +760,33 @@ func (ovs *overlordSuite) TestEnsureLoopPrune(c *C) {
 	c.Assert(t1.Status(), Equals, state.HoldStatus)
 }
 
+func (ovs *overlordSuite) TestPruneArchivesChanges(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	defer o.Stop()
+
+	st := o.State()
+	st.Lock()
+	t1 := st.NewTask("foo", "...")
+	t1.Logf("some log")
+	chg := st.NewChange("prune", "...")
+	chg.AddTask(t1)
+	t1.SetStatus(state.DoneStatus)
+	c.Assert(chg.IsReady(), Equals, true)
+	st.Prune(time.Now(), 0, time.Hour, 100)
+	c.Check(st.Change(chg.ID()), IsNil)
+	st.Unlock()
+
+	archived, err := o.ChangeArchive().Changes()
+	c.Assert(err, IsNil)
+	c.Assert(archived, HasLen, 1)
+	c.Check(archived[0].ID, Equals, chg.ID())
+	c.Check(archived[0].Kind, Equals, "prune")
+	c.Assert(archived[0].Tasks, HasLen, 1)
+	c.Check(archived[0].Tasks[0].Log, HasLen, 1)
+	c.Check(filepath.Join(dirs.SnapChangesArchiveDir, "changes.log"), testutil.FilePresent)
+}
+
 func (ovs *overlordSuite) TestEnsureLoopPruneRunsMultipleTimes(c *C) {
 	restoreIntv := overlord.MockPruneInterval(100*time.Millisecond, 5*time.Millisecond, 1*time.Hour)
 	defer restoreIntv()
@@ -20,6 +20,8 @@
 package restart
 
 import (
+	"time"
+
 	"github.com/snapcore/snapd/boot"
 )
 
@@ -32,3 +34,13 @@ var (
 func RestartParametersInit(rt *RestartParameters, snapName string, restartType RestartType, rebootInfo *boot.RebootInfo) {
 	rt.init(snapName, restartType, rebootInfo)
 }
+
+var FindMaintenanceWindow = findMaintenanceWindow
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -0,0 +1,249 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package restart
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var timeNow = time.Now
+
+// maintenanceWindowHorizon is how far ahead we look for the next
+// maintenance window, it covers schedules that only match some weeks of
+// a month (e.g. mon1, sat5).
+const maintenanceWindowHorizon = 62
+
+// ParseMaintenanceWindows parses a maintenance.windows specification,
+// using the same syntax as refresh.timer. Each window needs to be a time
+// range, single points in time and randomized or split ranges make no
+// sense for maintenance windows. A schedule with only days (e.g. "sat")
+// covers the whole day.
+func ParseMaintenanceWindows(spec string) ([]*timeutil.Schedule, error) {
+	schedules, err := timeutil.ParseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, sched := range schedules {
+		for _, span := range sched.ClockSpans {
+			if span.Start == span.End {
+				return nil, fmt.Errorf("maintenance window %q is not a time range", span)
+			}
+			if span.Spread || span.Split > 0 {
+				return nil, fmt.Errorf("maintenance window %q cannot be spread or split", span)
+			}
+		}
+	}
+	return schedules, nil
+}
+
+func maintenanceWindows(st *state.State) ([]*timeutil.Schedule, error) {
+	var spec string
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "maintenance.windows", &spec); err != nil {
+		return nil, err
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	return ParseMaintenanceWindows(spec)
+}
+
+// findMaintenanceWindow returns the window of the given schedules that
+// includes t or, if t is outside all of them, the earliest window starting
+// after t. The returned window is zero if none could be found.
+func findMaintenanceWindow(schedules []*timeutil.Schedule, t time.Time) (window timeutil.ScheduleWindow, open bool) {
+	y, m, d := t.Date()
+	// start with the previous day as windows may cross midnight
+	for i := -1; i <= maintenanceWindowHorizon; i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, t.Location())
+		for _, sched := range schedules {
+			if !scheduleMatchesDay(sched, day) {
+				continue
+			}
+			var windows []timeutil.ScheduleWindow
+			if len(sched.ClockSpans) == 0 {
+				windows = []timeutil.ScheduleWindow{{Start: day, End: day.AddDate(0, 0, 1)}}
+			}
+			for _, span := range sched.ClockSpans {
+				windows = append(windows, span.Window(day))
+			}
+			for _, w := range windows {
+				if !t.Before(w.Start) && t.Before(w.End) {
+					return w, true
+				}
+				if w.Start.After(t) && (window.IsZero() || w.Start.Before(window.Start)) {
+					window = w
+				}
+			}
+		}
+		// windows start on the day they are generated for, so nothing
+		// found on a later day can start earlier
+		if !window.IsZero() && i >= 0 {
+			break
+		}
+	}
+	return window, false
+}
+
+func scheduleMatchesDay(sched *timeutil.Schedule, day time.Time) bool {
+	if len(sched.WeekSpans) == 0 {
+		return true
+	}
+	for _, ws := range sched.WeekSpans {
+		if ws.Match(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// InMaintenanceWindow returns whether changes requiring a reboot of the
+// system may run at the given time according to the maintenance.windows
+// configuration. If they may not, the start of the next maintenance window
+// is also returned, it is zero if no window could be found. Without any
+// configured windows maintenance is always allowed.
+func InMaintenanceWindow(st *state.State, t time.Time) (open bool, next time.Time, err error) {
+	schedules, err := maintenanceWindows(st)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if len(schedules) == 0 {
+		return true, time.Time{}, nil
+	}
+	window, open := findMaintenanceWindow(schedules, t)
+	if open {
+		return true, time.Time{}, nil
+	}
+	return false, window.Start, nil
+}
+
+// PendingReboot describes a system reboot that was requested outside of
+// the maintenance windows and is deferred until the next one opens.
+type PendingReboot struct {
+	RestartType       RestartType         `json:"restart-type"`
+	RebootRequired    bool                `json:"reboot-required,omitempty"`
+	BootloaderOptions *bootloader.Options `json:"bootloader-options,omitempty"`
+	// BootID is the boot id at the time of the request, the reboot is
+	// not pending anymore once the system was rebooted by other means.
+	BootID      string    `json:"boot-id"`
+	RequestedAt time.Time `json:"requested-at"`
+	// NextWindow is the start of the maintenance window the reboot was
+	// deferred to at the time of the request.
+	NextWindow time.Time `json:"next-window,omitempty"`
+}
+
+func (pr *PendingReboot) rebootInfo() *boot.RebootInfo {
+	if !pr.RebootRequired && pr.BootloaderOptions == nil {
+		return nil
+	}
+	return &boot.RebootInfo{
+		RebootRequired:    pr.RebootRequired,
+		BootloaderOptions: pr.BootloaderOptions,
+	}
+}
+
+// PendingRebootStatus returns the system reboot currently deferred until
+// the next maintenance window, or nil if there is none.
+func PendingRebootStatus(st *state.State) (*PendingReboot, error) {
+	var pr PendingReboot
+	if err := st.Get("pending-reboot", &pr); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// maybeDeferReboot defers a system reboot if it was requested outside of
+// the maintenance windows. It returns whether the reboot was deferred.
+func (rm *RestartManager) maybeDeferReboot(t RestartType, rebootInfo *boot.RebootInfo) (bool, error) {
+	// only the reboots required by changes are deferred, the immediate
+	// ones are explicit requests
+	if t != RestartSystem {
+		return false, nil
+	}
+	st := rm.state
+	now := timeNow()
+	open, next, err := InMaintenanceWindow(st, now)
+	if err != nil || open {
+		return false, err
+	}
+	pr := &PendingReboot{
+		RestartType: t,
+		BootID:      rm.bootID,
+		RequestedAt: now,
+		NextWindow:  next,
+	}
+	if rebootInfo != nil {
+		pr.RebootRequired = rebootInfo.RebootRequired
+		pr.BootloaderOptions = rebootInfo.BootloaderOptions
+	}
+	st.Set("pending-reboot", pr)
+
+	data := map[string]string{}
+	if !next.IsZero() {
+		data["next-window"] = next.Format(time.RFC3339)
+		st.EnsureBefore(next.Sub(now))
+	}
+	if _, err := st.AddNotice(nil, state.PendingRebootNotice, "-", &state.AddNoticeOptions{Data: data}); err != nil {
+		return true, err
+	}
+	logger.Noticef("Deferring system restart until the next maintenance window")
+	return true, nil
+}
+
+// maybeRebootInMaintenanceWindow performs a deferred reboot once the
+// maintenance window opens.
+func (rm *RestartManager) maybeRebootInMaintenanceWindow() error {
+	st := rm.state
+	pr, err := PendingRebootStatus(st)
+	if err != nil || pr == nil {
+		return err
+	}
+	if pr.BootID != rm.bootID {
+		// the system was rebooted in the meantime
+		st.Set("pending-reboot", nil)
+		return nil
+	}
+	now := timeNow()
+	open, next, err := InMaintenanceWindow(st, now)
+	if err != nil {
+		return err
+	}
+	if !open {
+		if !next.IsZero() {
+			st.EnsureBefore(next.Sub(now))
+		}
+		return nil
+	}
+	logger.Noticef("Maintenance window is open, performing deferred system restart")
+	rm.request(pr.RestartType, pr.rebootInfo())
+	return nil
+}
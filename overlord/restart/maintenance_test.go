@@ -0,0 +1,306 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package restart_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type maintenanceSuite struct{}
+
+var _ = Suite(&maintenanceSuite{})
+
+func mustParseTime(c *C, s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	c.Assert(err, IsNil)
+	return t
+}
+
+func (s *maintenanceSuite) TestParseMaintenanceWindows(c *C) {
+	schedules, err := restart.ParseMaintenanceWindows("mon-fri,02:00-04:00,,sat")
+	c.Assert(err, IsNil)
+	c.Check(schedules, HasLen, 2)
+
+	_, err = restart.ParseMaintenanceWindows("02:00")
+	c.Check(err, ErrorMatches, `maintenance window "02:00" is not a time range`)
+	_, err = restart.ParseMaintenanceWindows("02:00~03:00")
+	c.Check(err, ErrorMatches, `maintenance window "02:00~03:00" cannot be spread or split`)
+	_, err = restart.ParseMaintenanceWindows("sometime")
+	c.Check(err, ErrorMatches, `cannot parse "sometime": .*`)
+}
+
+func (s *maintenanceSuite) TestFindMaintenanceWindow(c *C) {
+	for _, tc := range []struct {
+		windows string
+		now     string
+		open    bool
+		start   string
+		end     string
+	}{
+		// 2026-10-14 is a Wednesday
+		{"mon-fri,02:00-04:00", "2026-10-14 03:00", true, "2026-10-14 02:00", "2026-10-14 04:00"},
+		{"mon-fri,02:00-04:00", "2026-10-14 04:00", false, "2026-10-15 02:00", "2026-10-15 04:00"},
+		{"mon-fri,02:00-04:00", "2026-10-14 01:00", false, "2026-10-14 02:00", "2026-10-14 04:00"},
+		{"mon-fri,02:00-04:00", "2026-10-16 12:00", false, "2026-10-19 02:00", "2026-10-19 04:00"},
+		// windows crossing midnight
+		{"22:00-02:00", "2026-10-14 01:00", true, "2026-10-13 22:00", "2026-10-14 02:00"},
+		{"22:00-02:00", "2026-10-14 23:00", true, "2026-10-14 22:00", "2026-10-15 02:00"},
+		{"22:00-02:00", "2026-10-14 12:00", false, "2026-10-14 22:00", "2026-10-15 02:00"},
+		// whole days
+		{"sat", "2026-10-17 18:00", true, "2026-10-17 00:00", "2026-10-18 00:00"},
+		{"sat", "2026-10-14 18:00", false, "2026-10-17 00:00", "2026-10-18 00:00"},
+		// several schedules, the earliest window is picked
+		{"sun,10:00-11:00,,fri,20:00-21:00", "2026-10-14 12:00", false, "2026-10-16 20:00", "2026-10-16 21:00"},
+		{"fri,20:00-21:00,,04:00-05:00", "2026-10-16 12:00", false, "2026-10-16 20:00", "2026-10-16 21:00"},
+		// monthly windows
+		{"sat1,01:00-03:00", "2026-10-14 12:00", false, "2026-11-07 01:00", "2026-11-07 03:00"},
+	} {
+		comment := Commentf("%q at %s", tc.windows, tc.now)
+		schedules, err := restart.ParseMaintenanceWindows(tc.windows)
+		c.Assert(err, IsNil, comment)
+		window, open := restart.FindMaintenanceWindow(schedules, mustParseTime(c, tc.now))
+		c.Check(open, Equals, tc.open, comment)
+		c.Check(window.Start.Equal(mustParseTime(c, tc.start)), Equals, true, comment)
+		c.Check(window.End.Equal(mustParseTime(c, tc.end)), Equals, true, comment)
+	}
+}
+
+func setMaintenanceWindows(c *C, st *state.State, windows string) {
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "maintenance.windows", windows), IsNil)
+	tr.Commit()
+}
+
+func (s *maintenanceSuite) TestInMaintenanceWindow(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	now := mustParseTime(c, "2026-10-14 12:00")
+
+	// no windows, maintenance is always allowed
+	open, next, err := restart.InMaintenanceWindow(st, now)
+	c.Assert(err, IsNil)
+	c.Check(open, Equals, true)
+	c.Check(next.IsZero(), Equals, true)
+
+	setMaintenanceWindows(c, st, "02:00-04:00")
+	open, next, err = restart.InMaintenanceWindow(st, now)
+	c.Assert(err, IsNil)
+	c.Check(open, Equals, false)
+	c.Check(next.Equal(mustParseTime(c, "2026-10-15 02:00")), Equals, true)
+
+	open, _, err = restart.InMaintenanceWindow(st, mustParseTime(c, "2026-10-15 02:30"))
+	c.Assert(err, IsNil)
+	c.Check(open, Equals, true)
+}
+
+func (s *maintenanceSuite) TestRequestDeferredUntilMaintenanceWindow(c *C) {
+	now := mustParseTime(c, "2026-10-14 12:00")
+	restore := restart.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	h := &testHandler{}
+	mgr, err := restart.Manager(st, "boot-id-1", h)
+	c.Assert(err, IsNil)
+	setMaintenanceWindows(c, st, "02:00-04:00")
+
+	rebootInfo := &boot.RebootInfo{
+		RebootRequired:    true,
+		BootloaderOptions: &bootloader.Options{Role: bootloader.RoleRunMode},
+	}
+	restart.Request(st, restart.RestartSystem, rebootInfo)
+	c.Check(h.restartRequested, Equals, false)
+	c.Check(restart.Pending(st), Equals, restart.RestartUnset)
+
+	pr, err := restart.PendingRebootStatus(st)
+	c.Assert(err, IsNil)
+	c.Assert(pr, NotNil)
+	c.Check(pr.RestartType, Equals, restart.RestartSystem)
+	c.Check(pr.BootID, Equals, "boot-id-1")
+	c.Check(pr.RequestedAt.Equal(now), Equals, true)
+	c.Check(pr.NextWindow.Equal(mustParseTime(c, "2026-10-15 02:00")), Equals, true)
+
+	var fromBootID string
+	c.Check(st.Get("system-restart-from-boot-id", &fromBootID), testutil.ErrorIs, state.ErrNoState)
+
+	notices := st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.PendingRebootNotice}})
+	c.Assert(notices, HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], Equals, "-")
+	c.Check(n["last-data"], DeepEquals, map[string]any{"next-window": "2026-10-15T02:00:00" + now.Format("Z07:00")})
+
+	// still outside the window
+	st.Unlock()
+	err = mgr.Ensure()
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Check(h.restartRequested, Equals, false)
+
+	// the window opens
+	now = mustParseTime(c, "2026-10-15 02:01")
+	st.Unlock()
+	err = mgr.Ensure()
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Check(h.restartRequested, Equals, true)
+	c.Check(h.restartType, Equals, restart.RestartSystem)
+	c.Check(h.rebootInfo, DeepEquals, rebootInfo)
+	c.Check(restart.Pending(st), Equals, restart.RestartSystem)
+
+	pr, err = restart.PendingRebootStatus(st)
+	c.Assert(err, IsNil)
+	c.Check(pr, IsNil)
+	c.Assert(st.Get("system-restart-from-boot-id", &fromBootID), IsNil)
+	c.Check(fromBootID, Equals, "boot-id-1")
+}
+
+func (s *maintenanceSuite) TestRequestInsideMaintenanceWindow(c *C) {
+	restore := restart.MockTimeNow(func() time.Time { return mustParseTime(c, "2026-10-14 03:00") })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	h := &testHandler{}
+	_, err := restart.Manager(st, "boot-id-1", h)
+	c.Assert(err, IsNil)
+	setMaintenanceWindows(c, st, "02:00-04:00")
+
+	restart.Request(st, restart.RestartSystem, nil)
+	c.Check(h.restartRequested, Equals, true)
+	pr, err := restart.PendingRebootStatus(st)
+	c.Assert(err, IsNil)
+	c.Check(pr, IsNil)
+}
+
+func (s *maintenanceSuite) TestRequestImmediateRestartsNotDeferred(c *C) {
+	restore := restart.MockTimeNow(func() time.Time { return mustParseTime(c, "2026-10-14 12:00") })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setMaintenanceWindows(c, st, "02:00-04:00")
+
+	for _, t := range []restart.RestartType{restart.RestartSystemNow, restart.RestartSystemHaltNow, restart.RestartSystemPoweroffNow, restart.RestartDaemon} {
+		h := &testHandler{}
+		_, err := restart.Manager(st, "boot-id-1", h)
+		c.Assert(err, IsNil)
+		restart.Request(st, t, nil)
+		c.Check(h.restartRequested, Equals, true)
+		c.Check(h.restartType, Equals, t)
+	}
+}
+
+func (s *maintenanceSuite) TestChangeRestartDeferredUnlessUndoing(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+	restore = restart.MockTimeNow(func() time.Time { return mustParseTime(c, "2026-10-14 12:00") })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setMaintenanceWindows(c, st, "02:00-04:00")
+
+	for _, status := range []state.Status{state.DoneStatus, state.UndoneStatus} {
+		h := &testHandler{}
+		_, err := restart.Manager(st, "boot-id-1", h)
+		c.Assert(err, IsNil)
+		st.Set("pending-reboot", nil)
+
+		chg := st.NewChange("test", "...")
+		t := st.NewTask("waiting", "...")
+		chg.AddTask(t)
+
+		err = restart.FinishTaskWithRestart(t, status, restart.RestartSystem, "some-snap", nil)
+		c.Assert(err, IsNil)
+		restart.ProcessRestartForChange(chg, state.DefaultStatus, state.WaitStatus)
+
+		pr, err := restart.PendingRebootStatus(st)
+		c.Assert(err, IsNil)
+		if status == state.UndoneStatus {
+			// undoing is never deferred
+			c.Check(h.restartRequested, Equals, true)
+			c.Check(pr, IsNil)
+		} else {
+			c.Check(h.restartRequested, Equals, false)
+			c.Check(pr, NotNil)
+		}
+	}
+}
+
+func (s *maintenanceSuite) TestPendingRebootDroppedAfterReboot(c *C) {
+	restore := restart.MockTimeNow(func() time.Time { return mustParseTime(c, "2026-10-14 12:00") })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	h := &testHandler{}
+	mgr, err := restart.Manager(st, "boot-id-1", h)
+	c.Assert(err, IsNil)
+	setMaintenanceWindows(c, st, "02:00-04:00")
+
+	restart.Request(st, restart.RestartSystem, nil)
+	pr, err := restart.PendingRebootStatus(st)
+	c.Assert(err, IsNil)
+	c.Assert(pr, NotNil)
+
+	// the system was rebooted manually
+	restart.ReplaceBootID(st, "boot-id-2")
+	st.Unlock()
+	err = mgr.Ensure()
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Check(h.restartRequested, Equals, false)
+
+	pr, err = restart.PendingRebootStatus(st)
+	c.Assert(err, IsNil)
+	c.Check(pr, IsNil)
+}
+
+func noticeToMap(c *C, notice *state.Notice) map[string]any {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), IsNil)
+	return n
+}
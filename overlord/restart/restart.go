@@ -197,10 +197,13 @@ func ClearReboot(st *state.State) {
 	st.Set("system-restart-from-boot-id", nil)
 }
 
-// Ensure implements StateManager.Ensure. Required by StateEngine, we
-// actually do nothing here.
+// Ensure implements StateManager.Ensure. It performs system restarts
+// that were deferred until a maintenance window opens.
 func (m *RestartManager) Ensure() error {
-	return nil
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	return m.maybeRebootInMaintenanceWindow()
 }
 
 // StartUp implements StateStarterUp.Startup.
@@ -359,12 +362,28 @@ func restartManager(st *state.State, errMsg string) *RestartManager {
 }
 
 // Request asks for a restart of the managing process.
+// System restarts of type RestartSystem requested outside of the
+// configured maintenance windows are deferred until the next window opens,
+// unless they were requested by tasks undoing their change.
 // The state needs to be locked to request a restart.
 func Request(st *state.State, t RestartType, rebootInfo *boot.RebootInfo) {
 	rm := restartManager(st, "internal error: cannot request a restart before RestartManager initialization")
+	deferred, err := rm.maybeDeferReboot(t, rebootInfo)
+	if err != nil {
+		logger.Noticef("cannot check maintenance windows for system restart: %v", err)
+	}
+	if deferred {
+		return
+	}
+	rm.request(t, rebootInfo)
+}
+
+func (rm *RestartManager) request(t RestartType, rebootInfo *boot.RebootInfo) {
+	st := rm.state
 	switch t {
 	case RestartSystem, RestartSystemNow, RestartSystemHaltNow, RestartSystemPoweroffNow:
 		st.Set("system-restart-from-boot-id", rm.bootID)
+		st.Set("pending-reboot", nil)
 	}
 	atomic.StoreInt32(&rm.restarting, int32(t))
 	rm.handleRestart(t, rebootInfo)
@@ -558,6 +577,9 @@ func FinishTaskWithRestart(t *state.Task, status state.Status, restartType Resta
 		snapName = "snapd"
 	}
 	rp.init(snapName, restartType, rebootInfo)
+	if status == state.UndoneStatus {
+		rp.Undo = true
+	}
 
 	// set restart parameters before call to markTaskForRestart as that
 	// can trigger a new change status
@@ -661,7 +683,15 @@ func processRestartForChange(chg *state.Change, old, new state.Status) {
 		logger.Noticef("Postponing restart until a manual system restart allows to continue")
 		return
 	}
-	Request(chg.State(), rp.RestartType, &boot.RebootInfo{RebootRequired: true, BootloaderOptions: rp.BootloaderOptions})
+	rebootInfo := &boot.RebootInfo{RebootRequired: true, BootloaderOptions: rp.BootloaderOptions}
+	if rp.Undo {
+		// undoing brings the system back to a known good state, this is
+		// never deferred until a maintenance window
+		rm := restartManager(chg.State(), "internal error: cannot request a restart before RestartManager initialization")
+		rm.request(rp.RestartType, rebootInfo)
+		return
+	}
+	Request(chg.State(), rp.RestartType, rebootInfo)
 }
 
 // MockAfterRestartForChange is added solely for unit test purposes, to help simulate restarts.
@@ -28,6 +28,8 @@ type RestartParameters struct {
 	SnapName          string              `json:"snap-name,omitempty"`
 	RestartType       RestartType         `json:"restart-type,omitempty"`
 	BootloaderOptions *bootloader.Options `json:"bootloader-options,omitempty"`
+	// Undo is set when the restart was requested while undoing the change.
+	Undo bool `json:"undo,omitempty"`
 }
 
 // These are the restart-types that are relevant for the restart parameters. They
@@ -303,6 +303,26 @@ func EstimateSnapshotSize(si *snap.Info, usernames []string, dirOpts *dirs.SnapD
 
 // Save a snapshot
 func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	return save(ctx, id, si, cfg, usernames, dynSnapshotOpts, dirOpts, false, nil)
+}
+
+// SaveDeduplicated saves a snapshot like Save, but keeps the snap data in the
+// chunk store shared by all snapshots, so only data that is not already there
+// from other snapshots takes up additional space. The size of such snapshots
+// is that of the uncompressed data.
+func SaveDeduplicated(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	return save(ctx, id, si, cfg, usernames, dynSnapshotOpts, dirOpts, true, nil)
+}
+
+// SaveEncrypted saves a snapshot like Save, but encrypts its archives with a
+// new random key, which is in turn protected as described by the secret. The
+// archives are authenticated, so any tampering with them is detected when
+// checking or restoring the snapshot.
+func SaveEncrypted(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, secret *Secret) (*client.Snapshot, error) {
+	return save(ctx, id, si, cfg, usernames, dynSnapshotOpts, dirOpts, false, secret)
+}
+
+func save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, deduplicate bool, secret *Secret) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
@@ -342,11 +362,28 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 	// if things worked, we'll commit (and Cancel becomes a NOP)
 	defer aw.Cancel()
 
+	addSnapDir := addSnapDirToZip
+	systemArchiveName := archiveName
+	userArchiveNameFor := userArchiveName
+	if deduplicate {
+		addSnapDir = addSnapDirToChunkStore
+		systemArchiveName = chunkedArchiveName
+		userArchiveNameFor = userChunkedArchiveName
+	}
+	if secret != nil {
+		dataKey, enc, err := newEncryption(secret)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Encryption = enc
+		addSnapDir = encryptedSnapDirAdder(dataKey)
+	}
+
 	w := zip.NewWriter(aw)
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
-	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+	if err := addSnapDir(ctx, snapshot, w, "root", systemArchiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
 		return nil, err
 	}
 
@@ -358,7 +395,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 	savingUserData = true
 	for _, usr := range users {
 		snapDataDir := filepath.Dir(si.UserDataDir(usr.HomeDir, dirOpts))
-		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+		if err := addSnapDir(ctx, snapshot, w, usr.Username, userArchiveNameFor(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
 			return nil, err
 		}
 	}
@@ -400,7 +437,7 @@ var isTesting = snapdenv.Testing()
 // to the snapshot. If one doesn't exist, it's ignored. If none exists, the
 // operation is skipped.
 func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
-	paths, err := pathsForSnapshot(snapDir, snapshot)
+	paths, expExcludePaths, err := snapDirArchivePaths(snapshot, snapDir, savingUserData, excludePaths)
 	if err != nil {
 		return err
 	}
@@ -409,6 +446,36 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		return nil
 	}
 
+	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths, nil)
+}
+
+// encryptedSnapDirAdder returns a function adding snap dirs to the snapshot
+// like addSnapDirToZip, but encrypting the archives with the given key.
+func encryptedSnapDirAdder(dataKey []byte) func(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+	return func(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+		paths, expExcludePaths, err := snapDirArchivePaths(snapshot, snapDir, savingUserData, excludePaths)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+		return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths, dataKey)
+	}
+}
+
+// snapDirArchivePaths returns the paths under 'snapDir' to archive for the
+// snapshot, along with the exclusions that apply to them.
+func snapDirArchivePaths(snapshot *client.Snapshot, snapDir string, savingUserData bool, excludePaths []string) (paths, expExcludePaths []string, err error) {
+	paths, err = pathsForSnapshot(snapDir, snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, nil, nil
+	}
+
 	expandSnapDataDirs := func(varName string) string {
 		// Validation of the environment variables has already been performed.
 		// We just need to make sure that we consider the right variables
@@ -431,7 +498,6 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		return "-"
 	}
 
-	var expExcludePaths []string
 	for _, excludePath := range excludePaths {
 		expandedPath := os.Expand(excludePath, expandSnapDataDirs)
 		// "-" is the sentinel returned by expandSnapDataDirs() if the
@@ -442,24 +508,62 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		expExcludePaths = append(expExcludePaths, expandedPath)
 	}
 
-	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths)
+	return paths, expExcludePaths, nil
 }
 
 // addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
 // directory before creating the archive so that parent dirs are not added.
-func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
+// If 'dataKey' is not nil the archive is encrypted with it; the hash and size
+// recorded are then those of the encrypted archive.
+func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string, dataKey []byte) error {
 	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
 	if err != nil {
 		return err
 	}
 
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+
+	out := io.MultiWriter(archiveWriter, hasher, &sz)
+	var ew *encryptingWriter
+	if dataKey != nil {
+		ew, err = newEncryptingWriter(out, dataKey, entry)
+		if err != nil {
+			return err
+		}
+		out = ew
+	}
+
+	if err := runTarCreate(ctx, username, tarCreateArgs(true, paths, excludePaths), out); err != nil {
+		return err
+	}
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			return err
+		}
+	}
+
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.Size()
+
+	return nil
+}
+
+// tarCreateArgs returns the arguments for tar to create an archive of
+// 'paths', optionally compressed.
+func tarCreateArgs(compress bool, paths []string, excludePaths []string) []string {
 	tarArgs := []string{
 		"--create",
-		"--sparse", "--gzip",
+		"--sparse",
+	}
+	if compress {
+		tarArgs = append(tarArgs, "--gzip")
+	}
+	tarArgs = append(tarArgs,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
-	}
+	)
 
 	for _, path := range excludePaths {
 		tarArgs = append(tarArgs, fmt.Sprintf("--exclude=%s", path))
@@ -471,11 +575,14 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		tarArgs = append(tarArgs, "--directory", parent, dir)
 	}
 
-	var sz osutil.Sizer
-	hasher := crypto.SHA3_384.New()
+	return tarArgs
+}
 
+// runTarCreate runs tar as the given user to create an archive, writing it
+// to 'stdout'.
+func runTarCreate(ctx context.Context, username string, tarArgs []string, stdout io.Writer) error {
 	cmd := tarAsUser(ctx, username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stdout = stdout
 
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
@@ -505,9 +612,6 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
-	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
-	snapshot.Size += sz.Size()
-
 	return nil
 }
 
@@ -880,6 +984,14 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 			continue
 		}
 
+		if strings.HasPrefix(header.Name, chunksExportPrefix) {
+			// chunks come ahead of the snapshots that need them
+			if err := importChunk(strings.TrimPrefix(header.Name, chunksExportPrefix), tr); err != nil {
+				return snapNames, err
+			}
+			continue
+		}
+
 		// Format of the snapshot import is:
 		//     $setID_.....
 		// But because the setID is local this will not be correct
@@ -926,6 +1038,9 @@ type SnapshotExport struct {
 	// open snapshot files
 	snapshotFiles []*os.File
 
+	// names of the chunks from the chunk store the snapshots need
+	chunks []string
+
 	// contentHash of the full snapshot
 	contentHash []byte
 
@@ -941,6 +1056,7 @@ type SnapshotExport struct {
 func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
 	var snapshotSet client.SnapshotSet
+	chunks := make(map[string]bool)
 
 	defer func() {
 		// cleanup any open FDs if anything goes wrong
@@ -959,6 +1075,14 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 		if reader.SetID == setID {
 			snapshotSet.Snapshots = append(snapshotSet.Snapshots, &reader.Snapshot)
 
+			names, err := reader.chunkNames()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				chunks[name] = true
+			}
+
 			// Duplicate the file descriptor of the reader
 			// we were handed as Iter() closes those as
 			// soon as this unnamed returns. We re-package
@@ -988,6 +1112,10 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
 	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	for name := range chunks {
+		se.chunks = append(se.chunks, name)
+	}
+	sort.Strings(se.chunks)
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -1055,6 +1183,15 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		return err
 	}
 
+	// write out the chunks needed by deduplicated snapshots, ahead of the
+	// snapshots so that these can be checked as soon as they are imported
+	for _, name := range se.chunks {
+		if err := se.streamChunkTo(tw, name); err != nil {
+			return err
+		}
+		files = append(files, chunksExportPrefix+name)
+	}
+
 	// write out the individual snapshots
 	for _, snapshotFile := range se.snapshotFiles {
 		stat, err := snapshotFile.Stat()
@@ -1119,3 +1256,32 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 
 	return nil
 }
+
+func (se *SnapshotExport) streamChunkTo(tw *tar.Writer, name string) error {
+	// chunks are not kept open, but they won't be pruned while the
+	// snapshots that need them are around
+	f, err := os.Open(chunkPath(name))
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot chunk %q: %v", name, err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     chunksExportPrefix + name,
+		Size:     stat.Size(),
+		Mode:     0600,
+		ModTime:  timeNow(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write header for chunk %q: %v", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("cannot write data for chunk %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,564 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/user"
+)
+
+// Deduplicated snapshots do not carry the tar archives of the snap data in
+// the snapshot zip. Instead, the (uncompressed) tar stream is split into
+// content-defined chunks, each of which is compressed and stored once in a
+// chunk store shared by all snapshots, keyed by the SHA3-384 of its
+// content. The zip then only carries a manifest listing the chunks needed
+// to reassemble the stream.
+
+const (
+	chunksDirName = ".chunks"
+
+	chunkedArchiveName       = "archive.chunks"
+	userChunkedArchiveSuffix = ".chunks"
+
+	// chunksExportPrefix is the prefix of chunk entries in exported
+	// snapshot sets
+	chunksExportPrefix = "chunks/"
+
+	chunkManifestFormat = 1
+)
+
+var (
+	// chunk boundaries are placed where the rolling hash of the data has
+	// all the bits in chunkMask unset, but never before chunkMinSize
+	// bytes, and at most every chunkMaxSize bytes.
+	chunkMinSize = 256 * 1024
+	chunkMask    = uint64(1<<20 - 1)
+	chunkMaxSize = 4 * 1024 * 1024
+
+	chunkNameRegexp = regexp.MustCompile("^[0-9a-f]{96}$")
+)
+
+// gearTable holds the per-byte values of the gear rolling hash used to find
+// chunk boundaries. It must never change, as that would move the chunk
+// boundaries and defeat deduplication against previously saved snapshots.
+var gearTable = func() (table [256]uint64) {
+	// splitmix64 with a fixed seed
+	x := uint64(0x736e617073686f74)
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunkManifest describes an archive kept in the chunk store; it is what is
+// stored in the snapshot zip in place of the archive itself.
+type chunkManifest struct {
+	Format int `json:"format"`
+	// Size and SHA3_384 are those of the whole reassembled tar stream.
+	Size     int64      `json:"size"`
+	SHA3_384 string     `json:"sha3-384"`
+	Chunks   []chunkRef `json:"chunks"`
+}
+
+type chunkRef struct {
+	// SHA3_384 is the hash of the uncompressed chunk, and its name in the
+	// chunk store.
+	SHA3_384 string `json:"sha3-384"`
+	Size     int64  `json:"size"`
+}
+
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPath(name string) string {
+	return filepath.Join(chunksDir(), name[:2], name)
+}
+
+func userChunkedArchiveName(usr *user.User) string {
+	return filepath.Join(userArchivePrefix, usr.Username+userChunkedArchiveSuffix)
+}
+
+// isChunkedEntry returns whether the given snapshot entry is a manifest of
+// an archive kept in the chunk store.
+func isChunkedEntry(entry string) bool {
+	return entry == chunkedArchiveName || (isUserArchive(entry) && filepath.Ext(entry) == userChunkedArchiveSuffix)
+}
+
+// storeChunk adds the given data to the chunk store, unless it is there
+// already. It returns the reference to the chunk and the number of bytes
+// that were actually written to the store.
+func storeChunk(data []byte) (ref chunkRef, written int64, err error) {
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	ref = chunkRef{
+		SHA3_384: fmt.Sprintf("%x", hasher.Sum(nil)),
+		Size:     int64(len(data)),
+	}
+
+	fn := chunkPath(ref.SHA3_384)
+	if osutil.FileExists(fn) {
+		// refresh the modification time so that a concurrent prune does
+		// not consider the chunk abandoned
+		now := timeNow()
+		if err := os.Chtimes(fn, now, now); err != nil {
+			return ref, 0, fmt.Errorf("cannot update snapshot chunk %q: %v", ref.SHA3_384, err)
+		}
+		return ref, 0, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return ref, 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return ref, 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return ref, 0, err
+	}
+	if err := osutil.AtomicWriteFile(fn, buf.Bytes(), 0600, 0); err != nil {
+		return ref, 0, fmt.Errorf("cannot store snapshot chunk %q: %v", ref.SHA3_384, err)
+	}
+
+	return ref, int64(buf.Len()), nil
+}
+
+// chunkWriter is an io.Writer that splits what is written to it into
+// content-defined chunks and adds them to the chunk store.
+type chunkWriter struct {
+	buf      []byte
+	rolling  uint64
+	hasher   hash.Hash
+	manifest chunkManifest
+	// written is the number of bytes actually written to the chunk store
+	written int64
+	err     error
+}
+
+func newChunkWriter() *chunkWriter {
+	return &chunkWriter{
+		hasher:   crypto.SHA3_384.New(),
+		manifest: chunkManifest{Format: chunkManifestFormat},
+	}
+}
+
+// boundary returns how many bytes of p complete the current chunk, or -1 if
+// the chunk does not end within p.
+func (cw *chunkWriter) boundary(p []byte) int {
+	l := len(cw.buf)
+	for i, b := range p {
+		l++
+		cw.rolling = (cw.rolling << 1) + gearTable[b]
+		if l >= chunkMaxSize || (l >= chunkMinSize && cw.rolling&chunkMask == 0) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n := len(p)
+	cw.hasher.Write(p)
+	cw.manifest.Size += int64(n)
+
+	for len(p) > 0 {
+		i := cw.boundary(p)
+		if i < 0 {
+			cw.buf = append(cw.buf, p...)
+			break
+		}
+		cw.buf = append(cw.buf, p[:i]...)
+		p = p[i:]
+		if err := cw.flush(); err != nil {
+			cw.err = err
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+func (cw *chunkWriter) flush() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	ref, written, err := storeChunk(cw.buf)
+	if err != nil {
+		return err
+	}
+	cw.manifest.Chunks = append(cw.manifest.Chunks, ref)
+	cw.written += written
+	cw.buf = cw.buf[:0]
+	cw.rolling = 0
+	return nil
+}
+
+// Close stores any pending data and returns the manifest of everything
+// written.
+func (cw *chunkWriter) Close() (*chunkManifest, error) {
+	if cw.err != nil {
+		return nil, cw.err
+	}
+	if err := cw.flush(); err != nil {
+		return nil, err
+	}
+	cw.manifest.SHA3_384 = fmt.Sprintf("%x", cw.hasher.Sum(nil))
+	return &cw.manifest, nil
+}
+
+// gzipFile is the uncompressed content of a chunk in the store.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (gf *gzipFile) Close() error {
+	gf.Reader.Close()
+	return gf.f.Close()
+}
+
+func openChunk(name string) (io.ReadCloser, error) {
+	f, err := os.Open(chunkPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open snapshot chunk %q: %v", name, err)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot read snapshot chunk %q: %v", name, err)
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+// chunkReader reassembles a stream from the chunks in the store, checking
+// the size and hash of every chunk as it is read.
+type chunkReader struct {
+	chunks []chunkRef
+
+	cur    io.ReadCloser
+	curRef chunkRef
+	n      int64
+	hasher hash.Hash
+}
+
+func newChunkReader(chunks []chunkRef) *chunkReader {
+	return &chunkReader{
+		chunks: chunks,
+		hasher: crypto.SHA3_384.New(),
+	}
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.cur == nil {
+			if len(cr.chunks) == 0 {
+				return 0, io.EOF
+			}
+			cr.curRef, cr.chunks = cr.chunks[0], cr.chunks[1:]
+			rc, err := openChunk(cr.curRef.SHA3_384)
+			if err != nil {
+				return 0, err
+			}
+			cr.cur = rc
+			cr.n = 0
+			cr.hasher.Reset()
+		}
+
+		n, err := cr.cur.Read(p)
+		cr.hasher.Write(p[:n])
+		cr.n += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+
+		cr.cur.Close()
+		cr.cur = nil
+		if cr.n != cr.curRef.Size {
+			return n, fmt.Errorf("snapshot chunk %.7s… size (%d) does not match actual (%d)", cr.curRef.SHA3_384, cr.curRef.Size, cr.n)
+		}
+		if actualHash := fmt.Sprintf("%x", cr.hasher.Sum(nil)); actualHash != cr.curRef.SHA3_384 {
+			return n, fmt.Errorf("snapshot chunk %.7s… does not match its hash (%.7s…)", cr.curRef.SHA3_384, actualHash)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.cur == nil {
+		return nil
+	}
+	err := cr.cur.Close()
+	cr.cur = nil
+	return err
+}
+
+// addSnapDirToChunkStore is like addSnapDirToZip, but the archive is added to
+// the chunk store, with only its manifest going into the snapshot.
+func addSnapDirToChunkStore(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+	paths, expExcludePaths, err := snapDirArchivePaths(snapshot, snapDir, savingUserData, excludePaths)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	cw := newChunkWriter()
+	// no compression here, it would keep unchanged data from producing
+	// the same chunks; chunks are compressed individually instead
+	if err := runTarCreate(ctx, username, tarCreateArgs(false, paths, expExcludePaths), cw); err != nil {
+		return err
+	}
+	manifest, err := cw.Close()
+	if err != nil {
+		return err
+	}
+	logger.Debugf("Snapshot #%d of %q entry %q needed %d new bytes in the chunk store.", snapshot.SetID, snapshot.Snap, entry, cw.written)
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	if _, err := manifestWriter.Write(buf); err != nil {
+		return err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(buf)
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += manifest.Size
+
+	return nil
+}
+
+// chunkManifest returns the manifest in the given entry of the snapshot,
+// after checking it against the snapshot metadata.
+func (r *Reader) chunkManifest(entry string) (*chunkManifest, error) {
+	body, _, err := zipMember(r.File, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(buf)
+	expectedHash := r.SHA3_384[entry]
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return nil, fmt.Errorf("snapshot entry %q expected hash (%.7s…) does not match actual (%.7s…)", entry, expectedHash, actualHash)
+	}
+
+	var manifest chunkManifest
+	if err := json.Unmarshal(buf, &manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode chunk manifest of snapshot entry %q: %v", entry, err)
+	}
+	if manifest.Format != chunkManifestFormat {
+		return nil, fmt.Errorf("unsupported chunk manifest format %d in snapshot entry %q", manifest.Format, entry)
+	}
+	for _, ref := range manifest.Chunks {
+		if !chunkNameRegexp.MatchString(ref.SHA3_384) {
+			return nil, fmt.Errorf("invalid chunk %q in snapshot entry %q", ref.SHA3_384, entry)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// checkChunks checks that the chunks listed in the manifest in the given
+// entry are all present and reassemble the original stream.
+func (r *Reader) checkChunks(ctx context.Context, entry string) error {
+	manifest, err := r.chunkManifest(entry)
+	if err != nil {
+		return err
+	}
+
+	cr := newChunkReader(manifest.Chunks)
+	defer cr.Close()
+
+	hasher := crypto.SHA3_384.New()
+	size, err := io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), cr)
+	if err != nil {
+		return fmt.Errorf("snapshot entry %q: %v", entry, err)
+	}
+	if size != manifest.Size {
+		return fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, manifest.Size, size)
+	}
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != manifest.SHA3_384 {
+		return fmt.Errorf("snapshot entry %q expected hash (%.7s…) does not match actual (%.7s…)", entry, manifest.SHA3_384, actualHash)
+	}
+	return nil
+}
+
+// chunkNames returns the names of the chunks in the store that the snapshot
+// needs.
+func (r *Reader) chunkNames() ([]string, error) {
+	var names []string
+	for entry := range r.SHA3_384 {
+		if !isChunkedEntry(entry) {
+			continue
+		}
+		manifest, err := r.chunkManifest(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range manifest.Chunks {
+			names = append(names, ref.SHA3_384)
+		}
+	}
+	return names, nil
+}
+
+// importChunk adds a chunk from an exported snapshot set to the chunk store,
+// verifying it matches its name.
+func importChunk(name string, r io.Reader) error {
+	if !chunkNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid chunk name %q in import stream", name)
+	}
+	fn := chunkPath(name)
+	if osutil.FileExists(fn) {
+		now := timeNow()
+		return os.Chtimes(fn, now, now)
+	}
+
+	// a chunk compresses to no more than a handful of bytes over its
+	// size, so anything larger than this is not a chunk we produced
+	compressed, err := io.ReadAll(io.LimitReader(r, int64(chunkMaxSize)+4096))
+	if err != nil {
+		return fmt.Errorf("cannot read chunk %q from import stream: %v", name, err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("cannot read chunk %q from import stream: %v", name, err)
+	}
+	hasher := crypto.SHA3_384.New()
+	if _, err := io.Copy(hasher, io.LimitReader(gz, int64(chunkMaxSize))); err != nil {
+		return fmt.Errorf("cannot read chunk %q from import stream: %v", name, err)
+	}
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != name {
+		return fmt.Errorf("chunk %.7s… in import stream does not match its hash (%.7s…)", name, actualHash)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fn, compressed, 0600, 0)
+}
+
+// PruneChunks removes the chunks in the chunk store that are not needed by
+// any snapshot, returning how many were removed.
+//
+// Chunks written or reused after pruning started are kept, as are all chunks
+// while any snapshot is being imported, but PruneChunks must not run
+// concurrently with snapshot saves as these reference already present
+// chunks before the snapshot itself is written.
+func PruneChunks(ctx context.Context) (pruned int, err error) {
+	dir := chunksDir()
+	if !osutil.IsDirectory(dir) {
+		return 0, nil
+	}
+	start := timeNow()
+
+	importing, err := filepathGlob(filepath.Join(dirs.SnapshotsDir, importingFnGlob))
+	if err != nil {
+		return 0, err
+	}
+	if len(importing) > 0 {
+		// the imported snapshot is not visible until it is complete
+		return 0, nil
+	}
+
+	needed := make(map[string]bool)
+	err = Iter(ctx, func(r *Reader) error {
+		if r.Broken != "" {
+			return fmt.Errorf("cannot determine chunks needed by broken snapshot %q: %s", r.Name(), r.Broken)
+		}
+		names, err := r.chunkNames()
+		if err != nil {
+			return fmt.Errorf("cannot determine chunks needed by snapshot %q: %v", r.Name(), err)
+		}
+		for _, name := range names {
+			needed[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		if needed[fi.Name()] || !fi.ModTime().Before(start) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		pruned++
+		return nil
+	})
+	if err != nil {
+		return pruned, fmt.Errorf("cannot prune snapshot chunks: %v", err)
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,339 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func chunkNames(c *check.C) []string {
+	var names []string
+	err := filepath.Walk(backend.ChunksDir(), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			names = append(names, fi.Name())
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	c.Assert(err, check.IsNil)
+	sort.Strings(names)
+	return names
+}
+
+func (s *snapshotSuite) writeRandomData(c *check.C, fn string, size int, seed int64) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	c.Assert(os.WriteFile(fn, data, 0644), check.IsNil)
+	// tar records modification times, keep them stable
+	c.Assert(os.Chtimes(fn, time.Unix(1e9, 0), time.Unix(1e9, 0)), check.IsNil)
+}
+
+func (s *snapshotSuite) saveDeduplicated(c *check.C, setID uint64) *client.Snapshot {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.SaveDeduplicated(context.TODO(), setID, info, nil, []string{"snapuser"}, nil, nil)
+	c.Assert(err, check.IsNil)
+	return shw
+}
+
+func (s *snapshotSuite) TestDeduplicatedRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup(nil)
+	defer backend.MockChunkSizes(64, 0xff, 1024)()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}}
+	s.writeRandomData(c, filepath.Join(info.DataDir(), "big"), 16*1024, 1)
+
+	cfg := map[string]any{"some-setting": false}
+	shw, err := backend.SaveDeduplicated(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
+	c.Check(shw.Size > 16*1024, check.Equals, true)
+	c.Check(len(chunkNames(c)) > 1, check.Equals, true)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(shr.Conf, check.DeepEquals, cfg)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+	c.Check(shr.Check(context.TODO(), []string{"snapuser"}), check.IsNil)
+
+	oldroot := s.root
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	// the chunk store lives under the (old) snapshots directory
+	c.Assert(os.MkdirAll(filepath.Join(newroot, filepath.Dir(dirs.StripRootDir(dirs.SnapshotsDir))), 0755), check.IsNil)
+	c.Assert(os.Symlink(dirs.SnapshotsDir, filepath.Join(newroot, dirs.StripRootDir(dirs.SnapshotsDir))), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	diff := exec.Command("diff", "-urN", "-x*.zip", "-x.chunks", "-xsnapshots", oldroot, newroot)
+	c.Check(diff.Run(), check.NotNil)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	diff = exec.Command("diff", "-urN", "-x*.zip", "-x.chunks", "-xsnapshots", oldroot, newroot)
+	output, err := diff.CombinedOutput()
+	c.Check(err, check.IsNil, check.Commentf("%s", output))
+}
+
+func (s *snapshotSuite) TestDeduplicatedStoresOnlyChangedChunks(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockChunkSizes(64, 0xff, 1024)()
+
+	info := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	big := filepath.Join(info.DataDir(), "big")
+	s.writeRandomData(c, big, 64*1024, 1)
+
+	s.saveDeduplicated(c, 1)
+	first := chunkNames(c)
+	c.Assert(len(first) > 32, check.Equals, true)
+
+	// nothing changed, nothing new is stored
+	s.saveDeduplicated(c, 2)
+	c.Check(chunkNames(c), check.DeepEquals, first)
+
+	// change a few bytes in the middle of the data
+	data, err := os.ReadFile(big)
+	c.Assert(err, check.IsNil)
+	copy(data[32*1024:], "something different")
+	c.Assert(os.WriteFile(big, data, 0644), check.IsNil)
+	c.Assert(os.Chtimes(big, time.Unix(1e9, 0), time.Unix(1e9, 0)), check.IsNil)
+
+	s.saveDeduplicated(c, 3)
+	third := chunkNames(c)
+	added := len(third) - len(first)
+	c.Check(added > 0, check.Equals, true)
+	c.Check(added <= 4, check.Equals, true, check.Commentf("%d new chunks", added))
+
+	sets, err := backend.List(context.TODO(), 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 3)
+}
+
+func (s *snapshotSuite) TestDeduplicatedCheckMissingChunk(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	shw := s.saveDeduplicated(c, 1)
+
+	names := chunkNames(c)
+	c.Assert(names, check.Not(check.HasLen), 0)
+	for _, name := range names {
+		c.Assert(os.Remove(filepath.Join(backend.ChunksDir(), name[:2], name)), check.IsNil)
+	}
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `snapshot entry ".*": cannot open snapshot chunk .*`)
+}
+
+func (s *snapshotSuite) TestDeduplicatedCheckCorruptChunk(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	shw := s.saveDeduplicated(c, 1)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("not the data you are looking for"))
+	gz.Close()
+	for _, name := range chunkNames(c) {
+		c.Assert(os.WriteFile(filepath.Join(backend.ChunksDir(), name[:2], name), buf.Bytes(), 0600), check.IsNil)
+	}
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `snapshot entry ".*": snapshot chunk .* (size|does not match).*`)
+
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Check(err, check.ErrorMatches, `.*snapshot chunk .* (size|does not match).*|cannot unpack archive.*`)
+}
+
+func (s *snapshotSuite) TestPruneChunks(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockChunkSizes(64, 0xff, 1024)()
+
+	info := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	big := filepath.Join(info.DataDir(), "big")
+	s.writeRandomData(c, big, 16*1024, 1)
+	sh1 := s.saveDeduplicated(c, 1)
+	first := chunkNames(c)
+
+	s.writeRandomData(c, big, 16*1024, 2)
+	sh2 := s.saveDeduplicated(c, 2)
+	both := chunkNames(c)
+	c.Assert(len(both) > len(first), check.Equals, true)
+
+	// nothing to prune while both sets are around
+	defer backend.MockTimeNow(func() time.Time { return time.Now().Add(time.Hour) })()
+	pruned, err := backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+
+	// nor while importing
+	c.Assert(os.Remove(backend.Filename(sh1)), check.IsNil)
+	importing := filepath.Join(dirs.SnapshotsDir, "3_importing")
+	c.Assert(os.WriteFile(importing, nil, 0644), check.IsNil)
+	pruned, err = backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+	c.Assert(os.Remove(importing), check.IsNil)
+
+	pruned, err = backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(pruned > 0, check.Equals, true)
+	c.Check(chunkNames(c), check.HasLen, len(both)-pruned)
+
+	shr, err := backend.Open(backend.Filename(sh2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestPruneChunksKeepsRecentChunks(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	shw := s.saveDeduplicated(c, 1)
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	names := chunkNames(c)
+	c.Assert(names, check.Not(check.HasLen), 0)
+
+	// chunks written after pruning started might be about to be used
+	defer backend.MockTimeNow(func() time.Time { return time.Now().Add(-time.Hour) })()
+	pruned, err := backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+	c.Check(chunkNames(c), check.DeepEquals, names)
+}
+
+func (s *snapshotSuite) TestPruneChunksNoStore(c *check.C) {
+	pruned, err := backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(pruned, check.Equals, 0)
+}
+
+func (s *snapshotSuite) TestDeduplicatedExportImportRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	ctx := context.TODO()
+	shw := s.saveDeduplicated(c, 12)
+	names := chunkNames(c)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+
+	// the chunks come ahead of the snapshot that needs them
+	var entries []string
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		entries = append(entries, hdr.Name)
+	}
+	expected := []string{"content.json"}
+	for _, name := range names {
+		expected = append(expected, "chunks/"+name)
+	}
+	expected = append(expected, "12_hello-snap_v1.33_42.zip", "export.json")
+	c.Check(entries, check.DeepEquals, expected)
+
+	// now import it, with neither the snapshot nor its chunks around
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	c.Assert(os.RemoveAll(backend.ChunksDir()), check.IsNil)
+
+	snapNames, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+	c.Check(chunkNames(c), check.DeepEquals, names)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestImportBadChunk(c *check.C) {
+	var gzbuf bytes.Buffer
+	gz := gzip.NewWriter(&gzbuf)
+	gz.Write([]byte("some data"))
+	gz.Close()
+
+	for _, t := range []struct {
+		name, content, err string
+	}{
+		{"not-a-hash", gzbuf.String(), `.*invalid chunk name "not-a-hash" in import stream`},
+		{"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", gzbuf.String(), `.*chunk 0123456… in import stream does not match its hash .*`},
+		{"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "not gzip", `.*cannot read chunk .* from import stream: .*`},
+	} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		c.Assert(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "chunks/" + t.name, Size: int64(len(t.content)), Mode: 0600}), check.IsNil)
+		_, err := tw.Write([]byte(t.content))
+		c.Assert(err, check.IsNil)
+		c.Assert(tw.Close(), check.IsNil)
+
+		_, err = backend.Import(context.TODO(), 123, &buf, nil)
+		c.Check(err, check.ErrorMatches, t.err)
+		c.Check(chunkNames(c), check.HasLen, 0)
+	}
+}
@@ -0,0 +1,393 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/secboot"
+)
+
+const (
+	// KeyProtectionPassphrase protects the key of an encrypted snapshot
+	// with a key derived from a passphrase.
+	KeyProtectionPassphrase = "passphrase"
+	// KeyProtectionDevice protects the key of an encrypted snapshot with
+	// a key sealed by the TPM of the device, stored in the encrypted
+	// ubuntu-save partition.
+	KeyProtectionDevice = "device"
+
+	encryptionCipher = "aes-256-gcm"
+	encryptionKDF    = "argon2id"
+
+	dataKeySize = 32
+	saltSize    = 16
+	// the archives are encrypted in segments of this size, each with
+	// its own authentication tag, so that they can be decrypted as a
+	// stream
+	encryptedSegmentSize = 64 * 1024
+	noncePrefixSize      = 8
+)
+
+var (
+	// argon2id parameters for new passphrase protected snapshots, the
+	// ones used are recorded in the snapshot
+	kdfTime    uint32 = 4
+	kdfMemory  uint32 = 64 * 1024 // in KiB
+	kdfThreads uint8  = 1
+
+	randRead = rand.Read
+
+	secbootNewDeviceBoundKey     = secboot.NewDeviceBoundKey
+	secbootRecoverDeviceBoundKey = secboot.RecoverDeviceBoundKey
+)
+
+// ErrSnapshotLocked is returned when the data of an encrypted snapshot is
+// accessed before unlocking it.
+var ErrSnapshotLocked = errors.New("snapshot is encrypted and has not been unlocked")
+
+// ErrBadPassphrase is returned when unlocking a snapshot with a wrong
+// passphrase.
+var ErrBadPassphrase = errors.New("cannot unlock snapshot: wrong passphrase")
+
+// A Secret describes how the key of an encrypted snapshot is protected.
+type Secret struct {
+	// KeyProtection is either KeyProtectionPassphrase or
+	// KeyProtectionDevice.
+	KeyProtection string
+	// Passphrase is used with KeyProtectionPassphrase.
+	Passphrase string
+}
+
+// deviceKeyFile returns the path of the key protecting the keys of snapshots
+// encrypted with KeyProtectionDevice.
+func deviceKeyFile() string {
+	return filepath.Join(dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir), "snapshot.key")
+}
+
+// CanUseDeviceKey returns an error if snapshots cannot be encrypted with
+// KeyProtectionDevice on this system, because ubuntu-save is not encrypted.
+func CanUseDeviceKey() error {
+	if !device.HasEncryptedMarkerUnder(dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir)) {
+		return fmt.Errorf("cannot use device key: system is not using full disk encryption")
+	}
+	return nil
+}
+
+// deviceKey returns the device key, creating it if asked to. The key is
+// stored sealed by the TPM, so it cannot be used when sealing is not
+// available.
+func deviceKey(create bool) ([]byte, error) {
+	if err := CanUseDeviceKey(); err != nil {
+		return nil, err
+	}
+	fn := deviceKeyFile()
+	sealed, err := os.ReadFile(fn)
+	if err == nil {
+		key, err := secbootRecoverDeviceBoundKey(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unseal device key: %v", err)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("cannot use device key: invalid size %d", len(key))
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("cannot read device key: %v", err)
+	}
+	key, sealed, err := secbootNewDeviceBoundKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot seal device key: %v", err)
+	}
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("cannot use device key: invalid size %d", len(key))
+	}
+	if err := osutil.AtomicWriteFile(fn, sealed, 0600, 0); err != nil {
+		return nil, fmt.Errorf("cannot write device key: %v", err)
+	}
+	return key, nil
+}
+
+// keyEncryptionKey returns the key wrapping the data key of a snapshot with
+// the given encryption.
+func keyEncryptionKey(enc *client.SnapshotEncryption, passphrase string, create bool) ([]byte, error) {
+	switch enc.KeyProtection {
+	case KeyProtectionPassphrase:
+		if passphrase == "" {
+			return nil, fmt.Errorf("passphrase cannot be empty")
+		}
+		if enc.KDF != encryptionKDF {
+			return nil, fmt.Errorf("unsupported key derivation function %q", enc.KDF)
+		}
+		return argon2.IDKey([]byte(passphrase), enc.Salt, enc.KDFTime, enc.KDFMemory, enc.KDFThreads, dataKeySize), nil
+	case KeyProtectionDevice:
+		return deviceKey(create)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot key protection %q", enc.KeyProtection)
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newEncryption returns a new random data key along with the snapshot
+// encryption metadata carrying it, wrapped as requested by the secret.
+func newEncryption(secret *Secret) (dataKey []byte, enc *client.SnapshotEncryption, err error) {
+	enc = &client.SnapshotEncryption{
+		Cipher:        encryptionCipher,
+		KeyProtection: secret.KeyProtection,
+	}
+	if secret.KeyProtection == KeyProtectionPassphrase {
+		enc.KDF = encryptionKDF
+		enc.KDFTime = kdfTime
+		enc.KDFMemory = kdfMemory
+		enc.KDFThreads = kdfThreads
+		enc.Salt = make([]byte, saltSize)
+		if _, err := randRead(enc.Salt); err != nil {
+			return nil, nil, err
+		}
+	}
+	kek, err := keyEncryptionKey(enc, secret.Passphrase, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey = make([]byte, dataKeySize)
+	if _, err := randRead(dataKey); err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := randRead(nonce); err != nil {
+		return nil, nil, err
+	}
+	enc.WrappedKey = aead.Seal(nonce, nonce, dataKey, []byte(enc.KeyProtection))
+
+	return dataKey, enc, nil
+}
+
+// unwrapDataKey returns the data key of a snapshot with the given encryption.
+func unwrapDataKey(enc *client.SnapshotEncryption, passphrase string) ([]byte, error) {
+	if enc.Cipher != encryptionCipher {
+		return nil, fmt.Errorf("unsupported snapshot cipher %q", enc.Cipher)
+	}
+	kek, err := keyEncryptionKey(enc, passphrase, false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc.WrappedKey) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid wrapped snapshot key")
+	}
+	nonce, wrapped := enc.WrappedKey[:aead.NonceSize()], enc.WrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, wrapped, []byte(enc.KeyProtection))
+	if err != nil {
+		if enc.KeyProtection == KeyProtectionPassphrase {
+			return nil, ErrBadPassphrase
+		}
+		return nil, fmt.Errorf("cannot unlock snapshot with device key")
+	}
+	return dataKey, nil
+}
+
+// segmentNonce returns the nonce of the given segment of an archive.
+func segmentNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	return nonce
+}
+
+// segmentAdditionalData ties a segment to its archive, and marks the last
+// segment so that truncated archives are detected.
+func segmentAdditionalData(entry string, final bool) []byte {
+	ad := append([]byte(entry), 0)
+	if final {
+		ad[len(ad)-1] = 1
+	}
+	return ad
+}
+
+// encryptingWriter encrypts what is written to it in segments; the last
+// segment is written on Close.
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	entry   string
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+func newEncryptingWriter(w io.Writer, dataKey []byte, entry string) (*encryptingWriter, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := randRead(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		w:      w,
+		aead:   aead,
+		entry:  entry,
+		prefix: prefix,
+		buf:    make([]byte, 0, encryptedSegmentSize),
+	}, nil
+}
+
+func (ew *encryptingWriter) seal(final bool) error {
+	if ew.counter == ^uint32(0) {
+		return fmt.Errorf("snapshot archive too large to encrypt")
+	}
+	sealed := ew.aead.Seal(nil, segmentNonce(ew.prefix, ew.counter), ew.buf, segmentAdditionalData(ew.entry, final))
+	ew.counter++
+	ew.buf = ew.buf[:0]
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// a full segment is only sealed once more data follows, as it
+		// could be the last one
+		if len(ew.buf) == encryptedSegmentSize {
+			if err := ew.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		k := copy(ew.buf[len(ew.buf):encryptedSegmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+k]
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Close writes the last segment; it does not close the underlying writer.
+func (ew *encryptingWriter) Close() error {
+	return ew.seal(true)
+}
+
+// decryptingReader authenticates and decrypts what was written by an
+// encryptingWriter.
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	entry   string
+	prefix  []byte
+	counter uint32
+	segment []byte
+	plain   []byte
+	final   bool
+	err     error
+}
+
+func newDecryptingReader(r io.Reader, dataKey []byte, entry string) (*decryptingReader, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:       bufio.NewReader(r),
+		aead:    aead,
+		entry:   entry,
+		segment: make([]byte, encryptedSegmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (dr *decryptingReader) next() error {
+	if dr.prefix == nil {
+		dr.prefix = make([]byte, noncePrefixSize)
+		if _, err := io.ReadFull(dr.r, dr.prefix); err != nil {
+			return fmt.Errorf("cannot decrypt snapshot entry %q: truncated", dr.entry)
+		}
+	}
+	n, err := io.ReadFull(dr.r, dr.segment)
+	switch err {
+	case nil:
+		// a full segment is the last one if nothing follows it
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			dr.final = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF, io.EOF:
+		dr.final = true
+	default:
+		return err
+	}
+	plain, err := dr.aead.Open(dr.segment[:0], segmentNonce(dr.prefix, dr.counter), dr.segment[:n], segmentAdditionalData(dr.entry, dr.final))
+	if err != nil {
+		return fmt.Errorf("cannot decrypt snapshot entry %q: authentication failed", dr.entry)
+	}
+	dr.counter++
+	dr.plain = plain
+	return nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.final {
+			return 0, io.EOF
+		}
+		if err := dr.next(); err != nil {
+			dr.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
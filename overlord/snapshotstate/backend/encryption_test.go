@@ -0,0 +1,246 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var testDataKey = bytes.Repeat([]byte{42}, 32)
+
+func encryptStream(c *check.C, data []byte, entry string) []byte {
+	var buf bytes.Buffer
+	w, err := backend.NewEncryptingWriter(&buf, testDataKey, entry)
+	c.Assert(err, check.IsNil)
+	// write in odd sizes to cross segment boundaries
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := w.Write(data[:n])
+		c.Assert(err, check.IsNil)
+		data = data[n:]
+	}
+	c.Assert(w.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func decryptStream(c *check.C, enc []byte, entry string) ([]byte, error) {
+	r, err := backend.NewDecryptingReader(bytes.NewReader(enc), testDataKey, entry)
+	c.Assert(err, check.IsNil)
+	return io.ReadAll(r)
+}
+
+func (s *snapshotSuite) TestEncryptionStreamRoundtrip(c *check.C) {
+	for _, size := range []int{0, 1, backend.EncryptedSegmentSize - 1, backend.EncryptedSegmentSize, 3*backend.EncryptedSegmentSize + 7} {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+
+		enc := encryptStream(c, data, "archive.tgz")
+		if size > 64 {
+			c.Check(bytes.Contains(enc, data[:64]), check.Equals, false)
+		}
+
+		dec, err := decryptStream(c, enc, "archive.tgz")
+		c.Assert(err, check.IsNil, check.Commentf("size %d", size))
+		c.Check(dec, check.DeepEquals, data, check.Commentf("size %d", size))
+	}
+}
+
+func (s *snapshotSuite) TestEncryptionStreamTampering(c *check.C) {
+	data := make([]byte, 2*backend.EncryptedSegmentSize+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	enc := encryptStream(c, data, "archive.tgz")
+
+	// flipped bit
+	tampered := append([]byte(nil), enc...)
+	tampered[len(tampered)/2] ^= 1
+	_, err := decryptStream(c, tampered, "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": authentication failed`)
+
+	// truncated at a segment boundary
+	_, err = decryptStream(c, enc[:8+backend.EncryptedSegmentSize+16], "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": authentication failed`)
+
+	// moved to another entry
+	_, err = decryptStream(c, enc, "user/snapuser.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "user/snapuser.tgz": authentication failed`)
+
+	_, err = decryptStream(c, enc[:4], "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": truncated`)
+}
+
+func (s *snapshotSuite) TestEncryptedRoundtripPassphrase(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup(nil)
+	defer backend.MockKDFParams(1, 64)()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]any{"some-setting": false}
+	secret := &backend.Secret{KeyProtection: backend.KeyProtectionPassphrase, Passphrase: "s3kr1t"}
+	shw, err := backend.SaveEncrypted(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, secret)
+	c.Assert(err, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Assert(shw.Encryption, check.NotNil)
+	c.Check(shw.Encryption.Cipher, check.Equals, "aes-256-gcm")
+	c.Check(shw.Encryption.KeyProtection, check.Equals, "passphrase")
+	c.Check(shw.Encryption.KDF, check.Equals, "argon2id")
+	c.Check(shw.Encryption.KDFTime, check.Equals, uint32(1))
+	c.Check(shw.Encryption.KDFMemory, check.Equals, uint32(64))
+	c.Check(shw.Encryption.Salt, check.HasLen, 16)
+	c.Check(shw.Encryption.WrappedKey, check.HasLen, 12+32+16)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.IsEncrypted(), check.Equals, true)
+	c.Check(shr.Encryption, check.DeepEquals, shw.Encryption)
+	// the metadata is not encrypted
+	c.Check(shr.Conf, check.DeepEquals, cfg)
+
+	c.Check(shr.Check(context.TODO(), nil), check.Equals, backend.ErrSnapshotLocked)
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Check(err, check.Equals, backend.ErrSnapshotLocked)
+
+	c.Check(shr.Unlock("wrong"), check.Equals, backend.ErrBadPassphrase)
+	c.Check(shr.Unlock(""), check.ErrorMatches, "passphrase cannot be empty")
+	c.Assert(shr.Unlock("s3kr1t"), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	oldroot := s.root
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	diff := exec.Command("diff", "-urN", "-x*.zip", "-xsnapshots", oldroot, newroot)
+	output, err := diff.CombinedOutput()
+	c.Check(err, check.IsNil, check.Commentf("%s", output))
+}
+
+func (s *snapshotSuite) TestEncryptedDeviceKey(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+	secret := &backend.Secret{KeyProtection: backend.KeyProtectionDevice}
+
+	deviceKey := bytes.Repeat([]byte{7}, 32)
+	defer backend.MockDeviceBoundKey(func() ([]byte, []byte, error) {
+		return deviceKey, []byte("sealed-key"), nil
+	}, func(sealed []byte) ([]byte, error) {
+		switch string(sealed) {
+		case "sealed-key":
+			return deviceKey, nil
+		case "other-sealed-key":
+			return bytes.Repeat([]byte{1}, 32), nil
+		}
+		return nil, fmt.Errorf("cannot unseal")
+	})()
+
+	_, err := backend.SaveEncrypted(context.TODO(), 12, info, nil, nil, nil, nil, secret)
+	c.Check(err, check.ErrorMatches, "cannot use device key: system is not using full disk encryption")
+	c.Check(backend.CanUseDeviceKey(), check.NotNil)
+
+	fdeDir := dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir)
+	c.Assert(os.MkdirAll(fdeDir, 0700), check.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(fdeDir, "marker"), []byte("marker"), 0600), check.IsNil)
+	c.Check(backend.CanUseDeviceKey(), check.IsNil)
+
+	shw, err := backend.SaveEncrypted(context.TODO(), 12, info, nil, nil, nil, nil, secret)
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encryption.KeyProtection, check.Equals, "device")
+	c.Check(shw.Encryption.KDF, check.Equals, "")
+	c.Check(shw.Encryption.Salt, check.IsNil)
+
+	// only the sealed key is stored
+	keyFile := filepath.Join(fdeDir, "snapshot.key")
+	c.Check(keyFile, testutil.FileEquals, "sealed-key")
+	fi, err := os.Stat(keyFile)
+	c.Assert(err, check.IsNil)
+	c.Check(fi.Mode().Perm(), check.Equals, os.FileMode(0600))
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Assert(shr.Unlock(""), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	// a different device cannot unlock it
+	c.Assert(os.WriteFile(keyFile, []byte("other-sealed-key"), 0600), check.IsNil)
+	c.Check(shr.Unlock(""), check.ErrorMatches, "cannot unlock snapshot with device key")
+	c.Assert(os.WriteFile(keyFile, []byte("garbage"), 0600), check.IsNil)
+	c.Check(shr.Unlock(""), check.ErrorMatches, "cannot unseal device key: cannot unseal")
+	c.Assert(os.Remove(keyFile), check.IsNil)
+	c.Check(shr.Unlock(""), check.ErrorMatches, "cannot read device key: .*")
+	c.Check(keyFile, testutil.FileAbsent)
+}
+
+func (s *snapshotSuite) TestEncryptedDeviceKeySealingUnavailable(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+	secret := &backend.Secret{KeyProtection: backend.KeyProtectionDevice}
+
+	defer backend.MockDeviceBoundKey(func() ([]byte, []byte, error) {
+		return nil, nil, fmt.Errorf("TPM device is not enabled")
+	}, func(sealed []byte) ([]byte, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})()
+
+	fdeDir := dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir)
+	c.Assert(os.MkdirAll(fdeDir, 0700), check.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(fdeDir, "marker"), []byte("marker"), 0600), check.IsNil)
+
+	_, err := backend.SaveEncrypted(context.TODO(), 12, info, nil, nil, nil, nil, secret)
+	c.Check(err, check.ErrorMatches, "cannot seal device key: TPM device is not enabled")
+	c.Check(filepath.Join(fdeDir, "snapshot.key"), testutil.FileAbsent)
+}
+
+func (s *snapshotSuite) TestUnlockNotEncrypted(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encryption, check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.IsEncrypted(), check.Equals, false)
+	c.Check(shr.Unlock("whatever"), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+}
@@ -21,6 +21,7 @@ package backend
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"time"
@@ -44,6 +45,17 @@ var (
 	AddSnapDirToZip = addSnapDirToZip
 )
 
+func MockDeviceBoundKey(newKey func() (key, sealed []byte, err error), recoverKey func(sealed []byte) ([]byte, error)) (restore func()) {
+	oldNew := secbootNewDeviceBoundKey
+	oldRecover := secbootRecoverDeviceBoundKey
+	secbootNewDeviceBoundKey = newKey
+	secbootRecoverDeviceBoundKey = recoverKey
+	return func() {
+		secbootNewDeviceBoundKey = oldNew
+		secbootRecoverDeviceBoundKey = oldRecover
+	}
+}
+
 func MockIsTesting(newIsTesting bool) func() {
 	oldIsTesting := isTesting
 	isTesting = newIsTesting
@@ -156,3 +168,33 @@ func MockReadSnapshotYaml(f func(si *snap.Info) (*snap.SnapshotOptions, error))
 		snapReadSnapshotYaml = oldReadSnapshotYaml
 	}
 }
+
+func MockChunkSizes(minSize int, mask uint64, maxSize int) (restore func()) {
+	oldMinSize, oldMask, oldMaxSize := chunkMinSize, chunkMask, chunkMaxSize
+	chunkMinSize, chunkMask, chunkMaxSize = minSize, mask, maxSize
+	return func() {
+		chunkMinSize, chunkMask, chunkMaxSize = oldMinSize, oldMask, oldMaxSize
+	}
+}
+
+func ChunksDir() string {
+	return chunksDir()
+}
+
+func MockKDFParams(time, memory uint32) (restore func()) {
+	oldTime, oldMemory := kdfTime, kdfMemory
+	kdfTime, kdfMemory = time, memory
+	return func() {
+		kdfTime, kdfMemory = oldTime, oldMemory
+	}
+}
+
+func NewEncryptingWriter(w io.Writer, dataKey []byte, entry string) (io.WriteCloser, error) {
+	return newEncryptingWriter(w, dataKey, entry)
+}
+
+func NewDecryptingReader(r io.Reader, dataKey []byte, entry string) (io.Reader, error) {
+	return newDecryptingReader(r, dataKey, entry)
+}
+
+const EncryptedSegmentSize = encryptedSegmentSize
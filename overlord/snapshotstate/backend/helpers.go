@@ -70,12 +70,16 @@ func userArchiveName(usr *user.User) string {
 }
 
 func isUserArchive(entry string) bool {
-	return strings.HasPrefix(entry, userArchivePrefix) && strings.HasSuffix(entry, userArchiveSuffix)
+	return strings.HasPrefix(entry, userArchivePrefix) && (strings.HasSuffix(entry, userArchiveSuffix) || strings.HasSuffix(entry, userChunkedArchiveSuffix))
 }
 
 func entryUsername(entry string) string {
 	// this _will_ panic if !isUserArchive(entry)
-	return entry[len(userArchivePrefix) : len(entry)-len(userArchiveSuffix)]
+	suffix := userArchiveSuffix
+	if strings.HasSuffix(entry, userChunkedArchiveSuffix) {
+		suffix = userChunkedArchiveSuffix
+	}
+	return entry[len(userArchivePrefix) : len(entry)-len(suffix)]
 }
 
 type bySnap []*client.Snapshot
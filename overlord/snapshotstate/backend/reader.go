@@ -50,6 +50,10 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	// the key the archives of an encrypted snapshot are encrypted with,
+	// once unlocked
+	dataKey []byte
 }
 
 // Open a Snapshot given its full filename.
@@ -142,6 +146,38 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 	return reader, nil
 }
 
+// IsEncrypted returns whether the archives of the snapshot are encrypted.
+func (r *Reader) IsEncrypted() bool {
+	return r.Encryption != nil
+}
+
+// Unlock makes the data of an encrypted snapshot available for checking and
+// restoring. The passphrase is only used for snapshots whose key is protected
+// by one; it is a no-op for snapshots that are not encrypted.
+func (r *Reader) Unlock(passphrase string) error {
+	if r.Encryption == nil {
+		return nil
+	}
+	dataKey, err := unwrapDataKey(r.Encryption, passphrase)
+	if err != nil {
+		return err
+	}
+	r.dataKey = dataKey
+	return nil
+}
+
+// decrypting returns a reader decrypting the given entry's archive read from
+// body, or body itself if the snapshot is not encrypted.
+func (r *Reader) decrypting(body io.Reader, entry string) (io.Reader, error) {
+	if r.Encryption == nil {
+		return body, nil
+	}
+	if r.dataKey == nil {
+		return nil, ErrSnapshotLocked
+	}
+	return newDecryptingReader(body, r.dataKey, entry)
+}
+
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
 	body, reportedSize, err := zipMember(r.File, entry)
 	if err != nil {
@@ -150,9 +186,23 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 	defer body.Close()
 
 	expectedHash := r.SHA3_384[entry]
-	readSize, err := io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
-	if err != nil {
-		return err
+	var readSize int64
+	if r.Encryption != nil {
+		// authenticate the whole archive as well
+		var sz osutil.Sizer
+		dr, err := r.decrypting(io.TeeReader(body, io.MultiWriter(hasher, &sz)), entry)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(osutil.ContextWriter(ctx), dr); err != nil {
+			return err
+		}
+		readSize = sz.Size()
+	} else {
+		readSize, err = io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
+		if err != nil {
+			return err
+		}
 	}
 
 	if readSize != reportedSize {
@@ -165,8 +215,13 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 	return nil
 }
 
-// Check that the data contained in the snapshot matches its hashsums.
+// Check that the data contained in the snapshot matches its hashsums. The
+// archives of encrypted snapshots are also authenticated, which requires the
+// snapshot to be unlocked.
 func (r *Reader) Check(ctx context.Context, usernames []string) error {
+	if r.Encryption != nil && r.dataKey == nil {
+		return ErrSnapshotLocked
+	}
 	sort.Strings(usernames)
 
 	hasher := crypto.SHA3_384.New()
@@ -183,6 +238,12 @@ func (r *Reader) Check(ctx context.Context, usernames []string) error {
 			return err
 		}
 		hasher.Reset()
+
+		if isChunkedEntry(entry) {
+			if err := r.checkChunks(ctx, entry); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -206,6 +267,10 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 	}()
 
+	if r.Encryption != nil && r.dataKey == nil {
+		return rs, ErrSnapshotLocked
+	}
+
 	sort.Strings(usernames)
 	isRoot := sys.Geteuid() == 0
 	si := snap.MinimalPlaceInfo(r.Snap, r.Revision)
@@ -229,7 +294,7 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		gid := sys.GroupID(osutil.NoChown)
 
 		if !isUser {
-			if entry != archiveName {
+			if entry != archiveName && entry != chunkedArchiveName {
 				// hmmm
 				logf("Skipping restore of unknown entry %q.", entry)
 				continue
@@ -310,22 +375,30 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, expectedHash, compressed, err := r.archive(entry)
 		if err != nil {
 			return rs, err
 		}
 
-		expectedHash := r.SHA3_384[entry]
+		tr, err := r.decrypting(io.TeeReader(body, io.MultiWriter(hasher, &sz)), entry)
+		if err != nil {
+			body.Close()
+			return rs, err
+		}
 
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		tarArgs := []string{
+			"--extract",
+			"--preserve-permissions", "--preserve-order",
+		}
+		if compressed {
+			tarArgs = append(tarArgs, "--gunzip")
+		}
+		tarArgs = append(tarArgs, "--directory", tempdir)
 
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
 		// special cases we'd need to consider otherwise
-		cmd := tarAsUser(ctx, username,
-			"--extract",
-			"--preserve-permissions", "--preserve-order", "--gunzip",
-			"--directory", tempdir)
+		cmd := tarAsUser(ctx, username, tarArgs...)
 		cmd.Env = []string{}
 		cmd.Stdin = tr
 		matchCounter := &strutil.MatchCounter{N: 1}
@@ -337,13 +410,23 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 
 		// cmd is cancellable if ctx is a cancellable context
-		if err = cmd.Run(); err != nil {
+		err = cmd.Run()
+		// done with the archive of this entry, don't hold on to it
+		// while restoring the next ones
+		body.Close()
+		if err != nil {
+			if dr, ok := tr.(*decryptingReader); ok && dr.err != nil {
+				return rs, dr.err
+			}
 			matches, count := matchCounter.Matches()
 			if count > 0 {
 				return rs, fmt.Errorf("cannot unpack archive: %s (and %d more)", matches[0], count-1)
 			}
 			return rs, fmt.Errorf("tar failed: %v", err)
 		}
+		if dr, ok := tr.(*decryptingReader); ok && dr.err != nil {
+			return rs, dr.err
+		}
 
 		if sz.Size() != expectedSize {
 			return rs, fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
@@ -377,6 +460,22 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 	return rs, nil
 }
 
+// archive returns the archive in the given entry of the snapshot along with
+// its expected size and hash, reassembling it from the chunk store for
+// deduplicated snapshots.
+func (r *Reader) archive(entry string) (body io.ReadCloser, size int64, sha3_384 string, compressed bool, err error) {
+	if !isChunkedEntry(entry) {
+		body, size, err = zipMember(r.File, entry)
+		return body, size, r.SHA3_384[entry], true, err
+	}
+
+	manifest, err := r.chunkManifest(entry)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	return newChunkReader(manifest.Chunks), manifest.Size, manifest.SHA3_384, false, nil
+}
+
 // moveFile moves file from the sourceDir to the targetDir. Directories moved
 // and created are registered in the RestoreState.
 func moveFile(rs *RestoreState, file, sourceDir, targetDir string) error {
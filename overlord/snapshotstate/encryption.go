@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var backendCanUseDeviceKey = backend.CanUseDeviceKey
+
+// PassphraseRequiredError is returned when restoring or checking a snapshot
+// set encrypted with a passphrase without providing it first.
+type PassphraseRequiredError struct {
+	SetID uint64
+}
+
+func (e *PassphraseRequiredError) Error() string {
+	return fmt.Sprintf("snapshot set #%d is encrypted, a passphrase is required", e.SetID)
+}
+
+func validateSecret(secret *backend.Secret) error {
+	if secret == nil {
+		return fmt.Errorf("internal error: no snapshot encryption secret")
+	}
+	switch secret.KeyProtection {
+	case backend.KeyProtectionPassphrase:
+		if secret.Passphrase == "" {
+			return fmt.Errorf("cannot encrypt snapshot: passphrase cannot be empty")
+		}
+	case backend.KeyProtectionDevice:
+		if err := backendCanUseDeviceKey(); err != nil {
+			return fmt.Errorf("cannot encrypt snapshot: %v", err)
+		}
+	default:
+		return fmt.Errorf("cannot encrypt snapshot: unsupported key protection %q", secret.KeyProtection)
+	}
+	return nil
+}
+
+// Passphrases are never written to the state, they are only cached in
+// memory, keyed by snapshot set, for the tasks that need them.
+type snapshotPassphrasesKey struct{}
+
+func setSnapshotPassphrase(st *state.State, setID uint64, passphrase string) {
+	passphrases, _ := st.Cached(snapshotPassphrasesKey{}).(map[uint64]string)
+	if passphrases == nil {
+		passphrases = make(map[uint64]string)
+		st.Cache(snapshotPassphrasesKey{}, passphrases)
+	}
+	passphrases[setID] = passphrase
+}
+
+func snapshotPassphrase(st *state.State, setID uint64) string {
+	passphrases, _ := st.Cached(snapshotPassphrasesKey{}).(map[uint64]string)
+	return passphrases[setID]
+}
+
+// UsePassphrase makes the passphrase of an encrypted snapshot set available
+// to the tasks restoring or checking it, which are created afterwards.
+// Note that the state must be locked by the caller.
+func UsePassphrase(st *state.State, setID uint64, passphrase string) {
+	if passphrase == "" {
+		return
+	}
+	setSnapshotPassphrase(st, setID, passphrase)
+}
+
+// dropUnusedPassphrases forgets the passphrases of the snapshot sets that
+// have no pending tasks anymore.
+// Note that the state must be locked by the caller.
+func dropUnusedPassphrases(st *state.State) {
+	passphrases, _ := st.Cached(snapshotPassphrasesKey{}).(map[uint64]string)
+	if len(passphrases) == 0 {
+		return
+	}
+	inUse := make(map[uint64]bool, len(passphrases))
+	for _, task := range st.Tasks() {
+		if task.Status().Ready() {
+			continue
+		}
+		var snapshot snapshotSetup
+		if err := task.Get("snapshot-setup", &snapshot); err == nil {
+			inUse[snapshot.SetID] = true
+		}
+	}
+	for setID := range passphrases {
+		if !inUse[setID] {
+			delete(passphrases, setID)
+		}
+	}
+}
+
+// snapshotSecret returns the passphrase to unlock the snapshot of the task
+// with, if its key is protected by one.
+// Note that the state must be locked by the caller.
+func snapshotSecret(st *state.State, snapshot *snapshotSetup) (passphrase string, err error) {
+	if snapshot.Encryption != backend.KeyProtectionPassphrase {
+		return "", nil
+	}
+	passphrase = snapshotPassphrase(st, snapshot.SetID)
+	if passphrase == "" {
+		return "", fmt.Errorf("cannot unlock snapshot set #%d: passphrase is not available anymore", snapshot.SetID)
+	}
+	return passphrase, nil
+}
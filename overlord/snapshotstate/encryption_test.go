@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (snapshotSuite) TestSaveEncryptedPassphrase(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{"a-snap": {Active: true}}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	secret := &backend.Secret{KeyProtection: backend.KeyProtectionPassphrase, Passphrase: "s3kr1t"}
+	setID, saved, taskset, err := snapshotstate.SaveEncrypted(st, nil, nil, nil, secret)
+	c.Assert(err, check.IsNil)
+	c.Check(saved, check.DeepEquals, []string{"a-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var snapshot map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]any{
+		"set-id":     1.,
+		"snap":       "a-snap",
+		"current":    "unset",
+		"encryption": "passphrase",
+	})
+	// the passphrase is only kept in memory
+	c.Check(snapshotstate.SnapshotPassphrase(st, setID), check.Equals, "s3kr1t")
+	data, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Not(check.Matches), "(?s).*s3kr1t.*")
+
+	// and dropped once the tasks are done
+	chg := st.NewChange("save-snapshot", "...")
+	chg.AddAll(taskset)
+	snapshotstate.DropUnusedPassphrases(st)
+	c.Check(snapshotstate.SnapshotPassphrase(st, setID), check.Equals, "s3kr1t")
+	tasks[0].SetStatus(state.DoneStatus)
+	snapshotstate.DropUnusedPassphrases(st)
+	c.Check(snapshotstate.SnapshotPassphrase(st, setID), check.Equals, "")
+}
+
+func (snapshotSuite) TestSaveEncryptedErrors(c *check.C) {
+	defer snapshotstate.MockBackendCanUseDeviceKey(func() error {
+		return errors.New("no FDE here")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, t := range []struct {
+		secret *backend.Secret
+		err    string
+	}{
+		{&backend.Secret{KeyProtection: "passphrase"}, "cannot encrypt snapshot: passphrase cannot be empty"},
+		{&backend.Secret{KeyProtection: "device"}, "cannot encrypt snapshot: no FDE here"},
+		{&backend.Secret{KeyProtection: "tpm"}, `cannot encrypt snapshot: unsupported key protection "tpm"`},
+	} {
+		_, _, _, err := snapshotstate.SaveEncrypted(st, nil, nil, nil, t.secret)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (snapshotSuite) TestRestoreAndCheckNeedPassphrase(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		return f(&backend.Reader{
+			Snapshot: client.Snapshot{
+				SetID:      42,
+				Snap:       "a-snap",
+				Encryption: &client.SnapshotEncryption{KeyProtection: "passphrase"},
+			},
+			File: shotfile,
+		})
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	c.Check(err, check.FitsTypeOf, &snapshotstate.PassphraseRequiredError{})
+	c.Check(err, check.ErrorMatches, "snapshot set #42 is encrypted, a passphrase is required")
+	_, _, err = snapshotstate.Check(st, 42, nil, nil)
+	c.Check(err, check.FitsTypeOf, &snapshotstate.PassphraseRequiredError{})
+
+	snapshotstate.UsePassphrase(st, 42, "s3kr1t")
+
+	_, taskset, err := snapshotstate.Restore(st, 42, nil, nil)
+	c.Assert(err, check.IsNil)
+	var snapshot map[string]any
+	c.Assert(taskset.Tasks()[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["encryption"], check.Equals, "passphrase")
+
+	_, taskset, err = snapshotstate.Check(st, 42, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(taskset.Tasks()[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["encryption"], check.Equals, "passphrase")
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		c.Fatal("unexpected plain save")
+		return nil, nil
+	})()
+	var secrets []backend.Secret
+	defer snapshotstate.MockBackendSaveEncrypted(func(_ context.Context, id uint64, si *snap.Info, _ map[string]any, _ []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, secret *backend.Secret) (*client.Snapshot, error) {
+		c.Check(id, check.Equals, uint64(42))
+		c.Check(si, check.DeepEquals, &snapInfo)
+		secrets = append(secrets, *secret)
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{
+		"set-id":     42,
+		"snap":       "a-snap",
+		"encryption": "passphrase",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Check(err, check.ErrorMatches, `cannot unlock snapshot set #42: passphrase is not available anymore`)
+
+	st.Lock()
+	snapshotstate.UsePassphrase(st, 42, "s3kr1t")
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	st.Lock()
+	task.Set("snapshot-setup", map[string]any{
+		"set-id":     42,
+		"snap":       "a-snap",
+		"encryption": "device",
+	})
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	c.Check(secrets, check.DeepEquals, []backend.Secret{
+		{KeyProtection: "passphrase", Passphrase: "s3kr1t"},
+		{KeyProtection: "device"},
+	})
+}
+
+func (rs *readerSuite) TestDoRestoreAndCheckEncrypted(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: &client.SnapshotEncryption{KeyProtection: "passphrase"}},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendUnlock(func(_ *backend.Reader, passphrase string) error {
+		rs.calls = append(rs.calls, "unlock "+passphrase)
+		if passphrase != "s3kr1t" {
+			return backend.ErrBadPassphrase
+		}
+		return nil
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	rs.task.Set("snapshot-setup", map[string]any{
+		"set-id":     1,
+		"snap":       "a-snap",
+		"filename":   "/some/1_file.zip",
+		"encryption": "passphrase",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Check(err, check.ErrorMatches, `cannot unlock snapshot set #1: passphrase is not available anymore`)
+	err = snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Check(err, check.ErrorMatches, `cannot unlock snapshot set #1: passphrase is not available anymore`)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config"})
+
+	rs.calls = nil
+	st.Lock()
+	snapshotstate.UsePassphrase(st, 1, "wrong")
+	st.Unlock()
+	err = snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Check(err, check.Equals, backend.ErrBadPassphrase)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "unlock wrong"})
+
+	rs.calls = nil
+	st.Lock()
+	snapshotstate.UsePassphrase(st, 1, "s3kr1t")
+	st.Unlock()
+	c.Assert(snapshotstate.DoRestore(rs.task, &tomb.Tomb{}), check.IsNil)
+	c.Assert(snapshotstate.DoCheck(rs.task, &tomb.Tomb{}), check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{
+		"get config", "open", "unlock s3kr1t", "restore", "set config",
+		"open", "unlock s3kr1t", "check",
+	})
+}
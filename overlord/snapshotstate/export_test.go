@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -45,9 +46,11 @@ var (
 	CleanupRestore             = cleanupRestore
 	DoCheck                    = doCheck
 	DoForget                   = doForget
+	DoOffload                  = doOffload
 	SaveExpiration             = saveExpiration
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
+	ChunksLock                 = &chunksLock
 
 	SetSnapshotOpInProgress = setSnapshotOpInProgress
 
@@ -171,6 +174,22 @@ func MockBackendCleanupAbandonedImports(f func() (int, error)) (restore func())
 	}
 }
 
+func MockBackendSaveDeduplicated(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error)) (restore func()) {
+	old := backendSaveDedup
+	backendSaveDedup = f
+	return func() {
+		backendSaveDedup = old
+	}
+}
+
+func MockBackendPruneChunks(f func(context.Context) (int, error)) (restore func()) {
+	old := backendPruneChunks
+	backendPruneChunks = f
+	return func() {
+		backendPruneChunks = old
+	}
+}
+
 func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error)) (restore func()) {
 	old := backendEstimateSnapshotSize
 	backendEstimateSnapshotSize = f
@@ -215,3 +234,70 @@ func MockGetSnapDirOptions(f func(*state.State, string) (*dirs.SnapDirOptions, e
 		getSnapDirOpts = old
 	}
 }
+
+func MockRemoteNew(f func(*remote.Config) (remote.Target, error)) (restore func()) {
+	old := remoteNew
+	remoteNew = f
+	return func() {
+		remoteNew = old
+	}
+}
+
+func MockOsHostname(f func() (string, error)) (restore func()) {
+	old := osHostname
+	osHostname = f
+	return func() {
+		osHostname = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func ApplyRetention(ctx context.Context, t remote.Target, keep int, maxAge time.Duration, host, uploaded string, now time.Time) error {
+	return applyRetention(ctx, t, &snapshotTarget{Keep: keep, MaxAge: maxAge}, host, uploaded, now)
+}
+
+var (
+	SnapshotPassphrase    = snapshotPassphrase
+	DropUnusedPassphrases = dropUnusedPassphrases
+)
+
+func MockBackendSaveEncrypted(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.Secret) (*client.Snapshot, error)) (restore func()) {
+	old := backendSaveEncrypted
+	backendSaveEncrypted = f
+	return func() {
+		backendSaveEncrypted = old
+	}
+}
+
+func MockBackendUnlock(f func(*backend.Reader, string) error) (restore func()) {
+	old := backendUnlock
+	backendUnlock = f
+	return func() {
+		backendUnlock = old
+	}
+}
+
+func MockBackendCanUseDeviceKey(f func() error) (restore func()) {
+	old := backendCanUseDeviceKey
+	backendCanUseDeviceKey = f
+	return func() {
+		backendCanUseDeviceKey = old
+	}
+}
+
+var DoPruneScheduledSnapshots = doPruneScheduledSnapshots
+
+func ScheduledSetsToForget(times map[uint64]time.Time, keepLast, keepDaily, keepWeekly int) map[uint64]bool {
+	sets := make([]scheduledSet, 0, len(times))
+	for setID, tm := range times {
+		sets = append(sets, scheduledSet{SetID: setID, Time: tm})
+	}
+	return scheduledSetsToForget(sets, &snapshotRetention{KeepLast: keepLast, KeepDaily: keepDaily, KeepWeekly: keepWeekly})
+}
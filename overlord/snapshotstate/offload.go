@@ -0,0 +1,308 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	remoteNew  = remote.New
+	osHostname = os.Hostname
+	timeNow    = time.Now
+)
+
+// ErrNoSnapshotTarget is returned when an operation needs a snapshot target
+// but none is configured.
+var ErrNoSnapshotTarget = errors.New("no snapshot target configured")
+
+// snapshotTarget is the configuration of the remote target snapshot sets
+// are offloaded to.
+type snapshotTarget struct {
+	remote.Config
+
+	// Keep is the number of sets of this host to keep on the target,
+	// 0 means no limit.
+	Keep int
+	// MaxAge is how long sets of this host are kept on the target, 0
+	// means no limit.
+	MaxAge time.Duration
+}
+
+// snapshotTargetConfig returns the configured snapshot target, or nil if
+// there is none. The state must be locked by the caller.
+func snapshotTargetConfig(st *state.State) (*snapshotTarget, error) {
+	tr := config.NewTransaction(st)
+	var target snapshotTarget
+	for key, value := range map[string]*string{
+		"type":       &target.Type,
+		"url":        &target.URL,
+		"region":     &target.Region,
+		"access-key": &target.AccessKey,
+		"secret-key": &target.SecretKey,
+		"identity":   &target.Identity,
+	} {
+		if err := tr.GetMaybe("core", "snapshots.target."+key, value); err != nil {
+			return nil, err
+		}
+	}
+	if target.Type == "" {
+		return nil, nil
+	}
+
+	var keepStr, maxAgeStr string
+	if err := tr.GetMaybe("core", "snapshots.target.keep", &keepStr); err != nil {
+		return nil, err
+	}
+	if err := tr.GetMaybe("core", "snapshots.target.max-age", &maxAgeStr); err != nil {
+		return nil, err
+	}
+	// both were validated when set
+	if keepStr != "" {
+		target.Keep, _ = strconv.Atoi(keepStr)
+	}
+	if maxAgeStr != "" {
+		target.MaxAge, _ = time.ParseDuration(maxAgeStr)
+	}
+	return &target, nil
+}
+
+var invalidHostnameChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// offloadHost returns the name under which this system's snapshot sets are
+// stored on the target.
+func offloadHost() string {
+	host, err := osHostname()
+	if err != nil {
+		logger.Noticef("cannot get hostname, using \"localhost\": %v", err)
+		host = ""
+	}
+	host = invalidHostnameChars.ReplaceAllString(host, "-")
+	if host == "" {
+		host = "localhost"
+	}
+	return host
+}
+
+// addOffloadTask adds a task to offload the snapshot set to the configured
+// snapshot target, if any, after the tasks already in the task set.
+func addOffloadTask(st *state.State, setID uint64, ts *state.TaskSet) error {
+	target, err := snapshotTargetConfig(st)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return nil
+	}
+	task := st.NewTask("offload-snapshot", fmt.Sprintf("Offload snapshot set #%d to %s target", setID, target.Type))
+	task.Set("snapshot-setup", &snapshotSetup{SetID: setID})
+	task.WaitAll(ts)
+	ts.AddTask(task)
+	return nil
+}
+
+func doOffload(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var snapshot snapshotSetup
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return taskGetErrMsg(task, err, "snapshot")
+	}
+	setID := snapshot.SetID
+	target, err := snapshotTargetConfig(st)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		task.Logf("No snapshot target configured anymore, snapshot set #%d stays local", setID)
+		return nil
+	}
+	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
+		return err
+	}
+	setSnapshotOpInProgress(st, setID, "offload-snapshot")
+	defer UnsetSnapshotOpInProgress(st, setID)
+
+	st.Unlock()
+	name, err := offload(tomb.Context(nil), setID, target)
+	st.Lock()
+	// the set is saved locally already, failing here would undo that
+	if err != nil {
+		task.Errorf("cannot offload snapshot set #%d: %v", setID, err)
+		st.Warnf("cannot offload snapshot set #%d to %s target: %v", setID, target.Type, err)
+		return nil
+	}
+	task.Logf("Offloaded snapshot set #%d as %q", setID, name)
+
+	return nil
+}
+
+func offload(ctx context.Context, setID uint64, target *snapshotTarget) (string, error) {
+	t, err := remoteNew(&target.Config)
+	if err != nil {
+		return "", err
+	}
+	export, err := backendNewSnapshotExport(ctx, setID)
+	if err != nil {
+		return "", err
+	}
+	defer export.Close()
+	if err := export.Init(); err != nil {
+		return "", err
+	}
+
+	host := offloadHost()
+	now := timeNow()
+	name := remote.ObjectName(host, now, setID)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(export.StreamTo(pw))
+	}()
+	err = t.Put(ctx, name, pr, export.Size())
+	// unblock the exporter if the upload stopped early
+	pr.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if err := applyRetention(ctx, t, target, host, name, now); err != nil {
+		logger.Noticef("cannot apply snapshot target retention: %v", err)
+	}
+	return name, nil
+}
+
+// applyRetention removes the sets of the given host which are beyond the
+// configured retention from the target, never removing the just uploaded
+// set.
+func applyRetention(ctx context.Context, t remote.Target, target *snapshotTarget, host, uploaded string, now time.Time) error {
+	if target.Keep == 0 && target.MaxAge == 0 {
+		return nil
+	}
+	objs, err := remote.Objects(ctx, t)
+	if err != nil {
+		return err
+	}
+	var own []*remote.Object
+	for _, obj := range objs {
+		if obj.Host == host {
+			own = append(own, obj)
+		}
+	}
+	for i, obj := range own {
+		if obj.Name == uploaded {
+			continue
+		}
+		tooMany := target.Keep > 0 && len(own)-i > target.Keep
+		tooOld := target.MaxAge > 0 && now.Sub(obj.Time) > target.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := t.Delete(ctx, obj.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Target returns the configured snapshot target along with the snapshot sets
+// stored there.
+// Note that the state must not be locked by the caller.
+func Target(ctx context.Context, st *state.State) (*client.SnapshotTarget, error) {
+	st.Lock()
+	target, err := snapshotTargetConfig(st)
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrNoSnapshotTarget
+	}
+
+	t, err := remoteNew(&target.Config)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := remote.Objects(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	result := &client.SnapshotTarget{
+		Type:      target.Type,
+		URL:       target.URL,
+		Snapshots: make([]client.RemoteSnapshot, 0, len(objs)),
+	}
+	for _, obj := range objs {
+		result.Snapshots = append(result.Snapshots, client.RemoteSnapshot{
+			Name:  obj.Name,
+			Host:  obj.Host,
+			SetID: obj.SetID,
+			Time:  obj.Time,
+		})
+	}
+	return result, nil
+}
+
+// Fetch downloads the named snapshot set from the configured snapshot target
+// and imports it.
+// Note that the state must not be locked by the caller.
+func Fetch(ctx context.Context, st *state.State, name string) (setID uint64, snapNames []string, err error) {
+	if _, err := remote.ParseObjectName(name); err != nil {
+		return 0, nil, err
+	}
+	st.Lock()
+	target, err := snapshotTargetConfig(st)
+	st.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+	if target == nil {
+		return 0, nil, ErrNoSnapshotTarget
+	}
+
+	t, err := remoteNew(&target.Config)
+	if err != nil {
+		return 0, nil, err
+	}
+	r, err := t.Get(ctx, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer r.Close()
+
+	return Import(ctx, st, r)
+}
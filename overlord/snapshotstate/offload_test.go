@@ -0,0 +1,362 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/osutil/user"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+// memTarget is an in-memory stand-in for a remote snapshot target
+type memTarget struct {
+	objects map[string][]byte
+	putErr  error
+}
+
+func newMemTarget() *memTarget {
+	return &memTarget{objects: make(map[string][]byte)}
+}
+
+func (t *memTarget) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	if t.putErr != nil {
+		return t.putErr
+	}
+	data, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("short upload")
+	}
+	t.objects[name] = data
+	return nil
+}
+
+func (t *memTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := t.objects[name]
+	if !ok {
+		return nil, remote.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (t *memTarget) List(ctx context.Context) ([]string, error) {
+	names := make([]string, 0, len(t.objects))
+	for name := range t.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (t *memTarget) Delete(ctx context.Context, name string) error {
+	delete(t.objects, name)
+	return nil
+}
+
+func (t *memTarget) names() []string {
+	names, _ := t.List(context.Background())
+	return names
+}
+
+func setSnapshotTarget(c *check.C, st *state.State, conf map[string]string) {
+	tr := config.NewTransaction(st)
+	for k, v := range conf {
+		c.Assert(tr.Set("core", "snapshots.target."+k, v), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *snapshotSuite) mockTarget(c *check.C) *memTarget {
+	target := newMemTarget()
+	s.AddCleanup(snapshotstate.MockRemoteNew(func(cfg *remote.Config) (remote.Target, error) {
+		c.Check(cfg.Type, check.Equals, "ssh")
+		c.Check(cfg.URL, check.Equals, "ssh://backup@host/srv/snapshots")
+		return target, nil
+	}))
+	s.AddCleanup(snapshotstate.MockOsHostname(func() (string, error) { return "my_host", nil }))
+	s.AddCleanup(snapshotstate.MockTimeNow(func() time.Time {
+		return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	}))
+	return target
+}
+
+func (snapshotSuite) TestSaveAddsOffloadTask(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, name := range []string{"a-snap", "b-snap"} {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			}),
+			Current: snap.R(1),
+		})
+	}
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap", "b-snap"}, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(saved, check.DeepEquals, []string{"a-snap", "b-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	offload := tasks[2]
+	c.Check(offload.Kind(), check.Equals, "offload-snapshot")
+	c.Check(offload.Summary(), check.Equals, fmt.Sprintf("Offload snapshot set #%d to ssh target", setID))
+	c.Check(offload.WaitTasks(), check.DeepEquals, tasks[:2])
+	var snapshot map[string]any
+	c.Assert(offload.Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["set-id"], check.Equals, float64(setID))
+}
+
+func (s *snapshotSuite) TestOffloadAndFetch(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	target := s.mockTarget(c)
+
+	homedir := filepath.Join(dirs.GlobalRootDir, "home", "a-user")
+	defer backend.MockUserLookup(func(username string) (*user.User, error) {
+		return &user.User{
+			Uid:      fmt.Sprint(sys.Geteuid()),
+			Username: username,
+			HomeDir:  homedir,
+		}, nil
+	})()
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}
+	snapInfo := snaptest.MockSnap(c, "{name: a-snap, version: v1}", sideInfo)
+	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", "a-snap", "1", "canary"), 0755), check.IsNil)
+	_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	st := state.New(nil)
+	st.Lock()
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+	task := st.NewTask("offload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{"set-id": 42})
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoOffload(task, &tomb.Tomb{}), check.IsNil)
+
+	const name = "my-host_20260102T030405Z_42.snapshot"
+	c.Check(target.names(), check.DeepEquals, []string{name})
+	st.Lock()
+	c.Check(task.Log(), check.HasLen, 1)
+	c.Check(task.Log()[0], check.Matches, `.* INFO Offloaded snapshot set #42 as "`+name+`"`)
+	c.Check(st.Cached("snapshot-ops"), check.DeepEquals, map[uint64]string{})
+	st.Unlock()
+
+	// the set is gone locally but can be fetched back from the target
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "42_*"))
+	c.Assert(err, check.IsNil)
+	c.Assert(matches, check.HasLen, 1)
+	c.Assert(os.Remove(matches[0]), check.IsNil)
+
+	setID, snapNames, err := snapshotstate.Fetch(context.TODO(), st, name)
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Not(check.Equals), uint64(42))
+	c.Check(snapNames, check.DeepEquals, []string{"a-snap"})
+
+	st.Lock()
+	defer st.Unlock()
+	sets, err := snapshotstate.List(context.TODO(), st, setID, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 1)
+	c.Assert(sets[0].Snapshots, check.HasLen, 1)
+	c.Check(sets[0].Snapshots[0].Snap, check.Equals, "a-snap")
+}
+
+func (s *snapshotSuite) TestOffloadFailureOnlyWarns(c *check.C) {
+	defer snapshotstate.MockRemoteNew(func(cfg *remote.Config) (remote.Target, error) {
+		return nil, errors.New("boom")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+	task := st.NewTask("offload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{"set-id": 42})
+	st.Unlock()
+
+	err := snapshotstate.DoOffload(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Assert(task.Log(), check.HasLen, 1)
+	c.Check(task.Log()[0], check.Matches, `.* ERROR cannot offload snapshot set #42: boom`)
+	warnings := st.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, "cannot offload snapshot set #42 to ssh target: boom")
+}
+
+func (s *snapshotSuite) TestOffloadNoTarget(c *check.C) {
+	defer snapshotstate.MockRemoteNew(func(cfg *remote.Config) (remote.Target, error) {
+		c.Fatal("unexpected call")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	task := st.NewTask("offload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{"set-id": 42})
+	st.Unlock()
+
+	err := snapshotstate.DoOffload(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Assert(task.Log(), check.HasLen, 1)
+	c.Check(task.Log()[0], check.Matches, `.* INFO No snapshot target configured anymore, snapshot set #42 stays local`)
+}
+
+func (s *snapshotSuite) TestOffloadConflictsWithForget(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+	snapshotstate.SetSnapshotOpInProgress(st, 42, "forget-snapshot")
+	task := st.NewTask("offload-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{"set-id": 42})
+	st.Unlock()
+
+	err := snapshotstate.DoOffload(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #42 while operation forget-snapshot is in progress`)
+}
+
+func (snapshotSuite) TestForgetChecksOffloadConflicts(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	snapshotstate.SetSnapshotOpInProgress(st, 42, "offload-snapshot")
+
+	_, _, err := snapshotstate.Forget(st, 42, nil)
+	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while operation offload-snapshot is in progress`)
+}
+
+func (s *snapshotSuite) TestApplyRetention(c *check.C) {
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	for _, t := range []struct {
+		keep   int
+		maxAge time.Duration
+		left   []string
+	}{
+		{0, 0, []string{"box_20260101T000000Z_1.snapshot", "box_20260105T000000Z_2.snapshot", "box_20260109T000000Z_3.snapshot", "box_20260110T000000Z_4.snapshot", "other_20260101T000000Z_1.snapshot"}},
+		{2, 0, []string{"box_20260109T000000Z_3.snapshot", "box_20260110T000000Z_4.snapshot", "other_20260101T000000Z_1.snapshot"}},
+		{0, 3 * day, []string{"box_20260109T000000Z_3.snapshot", "box_20260110T000000Z_4.snapshot", "other_20260101T000000Z_1.snapshot"}},
+		{3, 7 * day, []string{"box_20260105T000000Z_2.snapshot", "box_20260109T000000Z_3.snapshot", "box_20260110T000000Z_4.snapshot", "other_20260101T000000Z_1.snapshot"}},
+		// the just uploaded set is never removed
+		{1, 0, []string{"box_20260109T000000Z_3.snapshot", "box_20260110T000000Z_4.snapshot", "other_20260101T000000Z_1.snapshot"}},
+	} {
+		target := newMemTarget()
+		for _, name := range []string{
+			"box_20260101T000000Z_1.snapshot",
+			"box_20260105T000000Z_2.snapshot",
+			"box_20260109T000000Z_3.snapshot",
+			"box_20260110T000000Z_4.snapshot",
+			"other_20260101T000000Z_1.snapshot",
+			"unrelated",
+		} {
+			target.objects[name] = nil
+		}
+		err := snapshotstate.ApplyRetention(context.TODO(), target, t.keep, t.maxAge, "box", "box_20260109T000000Z_3.snapshot", now)
+		c.Assert(err, check.IsNil)
+		c.Check(target.names(), check.DeepEquals, append(t.left, "unrelated"), check.Commentf("keep %v max-age %v", t.keep, t.maxAge))
+	}
+}
+
+func (s *snapshotSuite) TestTarget(c *check.C) {
+	target := s.mockTarget(c)
+	target.objects["b_20260102T000000Z_3.snapshot"] = nil
+	target.objects["a_20260101T000000Z_9.snapshot"] = nil
+	target.objects["unrelated"] = nil
+
+	st := state.New(nil)
+	st.Lock()
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+	st.Unlock()
+
+	result, err := snapshotstate.Target(context.TODO(), st)
+	c.Assert(err, check.IsNil)
+	c.Check(result, check.DeepEquals, &client.SnapshotTarget{
+		Type: "ssh",
+		URL:  "ssh://backup@host/srv/snapshots",
+		Snapshots: []client.RemoteSnapshot{
+			{Name: "a_20260101T000000Z_9.snapshot", Host: "a", SetID: 9, Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Name: "b_20260102T000000Z_3.snapshot", Host: "b", SetID: 3, Time: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+	})
+}
+
+func (s *snapshotSuite) TestTargetNotConfigured(c *check.C) {
+	st := state.New(nil)
+
+	_, err := snapshotstate.Target(context.TODO(), st)
+	c.Check(err, check.Equals, snapshotstate.ErrNoSnapshotTarget)
+	_, _, err = snapshotstate.Fetch(context.TODO(), st, "a_20260101T000000Z_9.snapshot")
+	c.Check(err, check.Equals, snapshotstate.ErrNoSnapshotTarget)
+}
+
+func (s *snapshotSuite) TestFetchErrors(c *check.C) {
+	s.mockTarget(c)
+	defer snapshotstate.MockBackendImport(func(context.Context, uint64, io.Reader, *backend.ImportFlags) ([]string, error) {
+		c.Fatal("unexpected import")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	setSnapshotTarget(c, st, map[string]string{"type": "ssh", "url": "ssh://backup@host/srv/snapshots"})
+	st.Unlock()
+
+	_, _, err := snapshotstate.Fetch(context.TODO(), st, "../etc/passwd")
+	c.Check(err, check.ErrorMatches, `invalid snapshot object name "../etc/passwd"`)
+	_, _, err = snapshotstate.Fetch(context.TODO(), st, "a_20260101T000000Z_9.snapshot")
+	c.Check(err, check.Equals, remote.ErrNotFound)
+}
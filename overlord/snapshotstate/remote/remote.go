@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package remote implements the remote targets snapshot sets can be
+// offloaded to.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound is returned when the requested object is not present on the
+// target.
+var ErrNotFound = errors.New("snapshot not found on target")
+
+// Target is a remote place to store exported snapshot sets in.
+type Target interface {
+	// Put stores size bytes read from r under the given name.
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// Get returns the content stored under the given name.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of all the objects stored on the target.
+	List(ctx context.Context) ([]string, error)
+	// Delete removes the object stored under the given name.
+	Delete(ctx context.Context, name string) error
+}
+
+const (
+	// TypeS3 is a bucket (and optional key prefix) on a S3-compatible
+	// object storage service.
+	TypeS3 = "s3"
+	// TypeSSH is a directory on a host reachable over ssh.
+	TypeSSH = "ssh"
+)
+
+// Config describes how to reach a snapshot target.
+type Config struct {
+	// Type is one of TypeS3 or TypeSSH.
+	Type string
+	// URL is http(s)://host[:port]/bucket[/prefix] for TypeS3 and
+	// ssh://[user@]host[:port]/path for TypeSSH.
+	URL string
+
+	// Region, AccessKey and SecretKey are used to sign requests to S3.
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	// Identity is the path to the ssh private key to use, if any.
+	Identity string
+}
+
+// New returns the target described by the given configuration.
+func New(cfg *Config) (Target, error) {
+	switch cfg.Type {
+	case TypeS3:
+		return newS3Target(cfg)
+	case TypeSSH:
+		return newSSHTarget(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot target type %q", cfg.Type)
+	}
+}
+
+// Validate checks the given configuration without contacting the target.
+func Validate(cfg *Config) error {
+	_, err := New(cfg)
+	return err
+}
+
+// Object describes a snapshot set stored on a target.
+type Object struct {
+	// Name is the name the set is stored under.
+	Name string
+	// Host is the hostname of the system the set was offloaded from.
+	Host string
+	// Time is when the set was offloaded.
+	Time time.Time
+	// SetID is the ID the set had on the system it was offloaded from.
+	SetID uint64
+}
+
+const objectTimeFormat = "20060102T150405Z"
+
+var objectNameRegexp = regexp.MustCompile(`^([a-zA-Z0-9.-]+)_([0-9]{8}T[0-9]{6}Z)_([0-9]+)\.snapshot$`)
+
+// ObjectName returns the name to store the given snapshot set under.
+func ObjectName(host string, t time.Time, setID uint64) string {
+	return fmt.Sprintf("%s_%s_%d.snapshot", host, t.UTC().Format(objectTimeFormat), setID)
+}
+
+// ParseObjectName parses names as returned by ObjectName.
+func ParseObjectName(name string) (*Object, error) {
+	m := objectNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("invalid snapshot object name %q", name)
+	}
+	t, err := time.Parse(objectTimeFormat, m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot object name %q: %v", name, err)
+	}
+	setID, err := strconv.ParseUint(m[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot object name %q: %v", name, err)
+	}
+	return &Object{Name: name, Host: m[1], Time: t, SetID: setID}, nil
+}
+
+// Objects returns the snapshot sets stored on the target, ignoring anything
+// else stored there. The returned list is sorted oldest first.
+func Objects(ctx context.Context, t Target) ([]*Object, error) {
+	names, err := t.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*Object, 0, len(names))
+	for _, name := range names {
+		obj, err := ParseObjectName(name)
+		if err != nil {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objectLess(objs[i], objs[j]) })
+	return objs, nil
+}
+
+func objectLess(a, b *Object) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type remoteSuite struct{}
+
+var _ = check.Suite(&remoteSuite{})
+
+func (s *remoteSuite) TestObjectNameRoundtrip(c *check.C) {
+	t := time.Date(2026, 3, 4, 5, 6, 7, 0, time.FixedZone("X", 3600))
+	name := remote.ObjectName("my-host.lan", t, 42)
+	c.Check(name, check.Equals, "my-host.lan_20260304T040607Z_42.snapshot")
+
+	obj, err := remote.ParseObjectName(name)
+	c.Assert(err, check.IsNil)
+	c.Check(obj.Name, check.Equals, name)
+	c.Check(obj.Host, check.Equals, "my-host.lan")
+	c.Check(obj.Time.Equal(t), check.Equals, true)
+	c.Check(obj.SetID, check.Equals, uint64(42))
+}
+
+func (s *remoteSuite) TestParseObjectNameInvalid(c *check.C) {
+	for _, name := range []string{
+		"",
+		"foo",
+		"host_20260304T040607Z_42.snapshot.partial",
+		"host_20260304T040607Z_x.snapshot",
+		"host_20261304T040607Z_42.snapshot",
+		"ho/st_20260304T040607Z_42.snapshot",
+		"_20260304T040607Z_42.snapshot",
+	} {
+		_, err := remote.ParseObjectName(name)
+		c.Check(err, check.ErrorMatches, `invalid snapshot object name .*`, check.Commentf("%q", name))
+	}
+}
+
+type listOnlyTarget struct {
+	remote.Target
+	names []string
+}
+
+func (t *listOnlyTarget) List(context.Context) ([]string, error) {
+	return t.names, nil
+}
+
+func (s *remoteSuite) TestObjectsSortedAndFiltered(c *check.C) {
+	t := &listOnlyTarget{names: []string{
+		"b_20260102T000000Z_3.snapshot",
+		"unrelated.txt",
+		"a_20260101T000000Z_9.snapshot",
+		"a_20260102T000000Z_2.snapshot",
+	}}
+	objs, err := remote.Objects(context.Background(), t)
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.Name)
+	}
+	c.Check(names, check.DeepEquals, []string{
+		"a_20260101T000000Z_9.snapshot",
+		"a_20260102T000000Z_2.snapshot",
+		"b_20260102T000000Z_3.snapshot",
+	})
+}
+
+func (s *remoteSuite) TestNewErrors(c *check.C) {
+	for _, t := range []struct {
+		cfg remote.Config
+		err string
+	}{
+		{remote.Config{}, `unsupported snapshot target type ""`},
+		{remote.Config{Type: "ftp"}, `unsupported snapshot target type "ftp"`},
+		{remote.Config{Type: "s3", URL: "ftp://host/bucket"}, `invalid S3 snapshot target URL "ftp://host/bucket": scheme must be https or http`},
+		{remote.Config{Type: "s3", URL: "https:///bucket"}, `invalid S3 snapshot target URL "https:///bucket": missing host`},
+		{remote.Config{Type: "s3", URL: "https://host/"}, `invalid S3 snapshot target URL "https://host/": missing bucket`},
+		{remote.Config{Type: "s3", URL: "https://host/b?x=1"}, `invalid S3 snapshot target URL "https://host/b\?x=1": only host and path are supported`},
+		{remote.Config{Type: "s3", URL: "https://host/b"}, `S3 snapshot target requires an access key and a secret key`},
+		{remote.Config{Type: "ssh", URL: "sftp://host/dir"}, `invalid ssh snapshot target URL "sftp://host/dir": scheme must be ssh`},
+		{remote.Config{Type: "ssh", URL: "ssh://host"}, `invalid ssh snapshot target URL "ssh://host": missing directory`},
+		{remote.Config{Type: "ssh", URL: "ssh:///dir"}, `invalid ssh snapshot target URL "ssh:///dir": missing host`},
+		{remote.Config{Type: "ssh", URL: "ssh://u:p@host/dir"}, `invalid ssh snapshot target URL "ssh://u:p@host/dir": passwords are not supported, use an identity`},
+		{remote.Config{Type: "ssh", URL: "ssh://host/dir", Identity: "id_rsa"}, `ssh snapshot target identity must be an absolute path`},
+	} {
+		c.Check(remote.Validate(&t.cfg), check.ErrorMatches, t.err, check.Commentf("%+v", t.cfg))
+	}
+
+	c.Check(remote.Validate(&remote.Config{Type: "s3", URL: "https://host/b/p", AccessKey: "a", SecretKey: "s"}), check.IsNil)
+	c.Check(remote.Validate(&remote.Config{Type: "ssh", URL: "ssh://me@host:2222/srv/snaps", Identity: "/root/.ssh/id"}), check.IsNil)
+}
@@ -0,0 +1,332 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/httputil"
+)
+
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+var timeNow = time.Now
+
+// s3Target stores snapshot sets as objects in a bucket of a S3-compatible
+// service, using path-style requests signed with AWS signature version 4.
+type s3Target struct {
+	client *http.Client
+
+	scheme string
+	host   string
+	bucket string
+	prefix string
+
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func newS3Target(cfg *Config) (*s3Target, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 snapshot target URL: %v", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid S3 snapshot target URL %q: scheme must be https or http", cfg.URL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 snapshot target URL %q: missing host", cfg.URL)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid S3 snapshot target URL %q: only host and path are supported", cfg.URL)
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid S3 snapshot target URL %q: missing bucket", cfg.URL)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 snapshot target requires an access key and a secret key")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	return &s3Target{
+		client:    httputil.NewHTTPClient(&httputil.ClientOptions{Proxy: http.ProxyFromEnvironment}),
+		scheme:    u.Scheme,
+		host:      u.Host,
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+	}, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3ResponseError(rsp *http.Response, what string) error {
+	var e s3Error
+	// errors are small, do not read unbounded bodies
+	if err := xml.NewDecoder(io.LimitReader(rsp.Body, 64*1024)).Decode(&e); err == nil && e.Code != "" {
+		return fmt.Errorf("cannot %s: %s: %s", what, e.Code, e.Message)
+	}
+	return fmt.Errorf("cannot %s: unexpected status %q", what, rsp.Status)
+}
+
+func (t *s3Target) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	req, err := t.newRequest(ctx, "PUT", t.prefix+name, nil, r, size)
+	if err != nil {
+		return err
+	}
+	rsp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot upload %q: %v", name, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != 200 {
+		return s3ResponseError(rsp, fmt.Sprintf("upload %q", name))
+	}
+	return nil
+}
+
+func (t *s3Target) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := t.newRequest(ctx, "GET", t.prefix+name, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download %q: %v", name, err)
+	}
+	switch rsp.StatusCode {
+	case 200:
+		return rsp.Body, nil
+	case 404:
+		rsp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer rsp.Body.Close()
+		return nil, s3ResponseError(rsp, fmt.Sprintf("download %q", name))
+	}
+}
+
+type s3ListBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+func (t *s3Target) List(ctx context.Context) ([]string, error) {
+	var names []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if t.prefix != "" {
+			query.Set("prefix", t.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := t.newRequest(ctx, "GET", "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := t.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list snapshot target: %v", err)
+		}
+		if rsp.StatusCode != 200 {
+			err := s3ResponseError(rsp, "list snapshot target")
+			rsp.Body.Close()
+			return nil, err
+		}
+		var result s3ListBucketResult
+		err = xml.NewDecoder(rsp.Body).Decode(&result)
+		rsp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode snapshot target listing: %v", err)
+		}
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, t.prefix)
+			// only direct children of the prefix
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			names = append(names, name)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return names, nil
+}
+
+func (t *s3Target) Delete(ctx context.Context, name string) error {
+	req, err := t.newRequest(ctx, "DELETE", t.prefix+name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	rsp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot delete %q: %v", name, err)
+	}
+	defer rsp.Body.Close()
+	// deleting a missing object is not an error in S3
+	if rsp.StatusCode != 204 && rsp.StatusCode != 200 {
+		return s3ResponseError(rsp, fmt.Sprintf("delete %q", name))
+	}
+	return nil
+}
+
+// newRequest builds a signed request for the given key in the bucket; an
+// empty key addresses the bucket itself.
+func (t *s3Target) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	path := "/" + t.bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u := &url.URL{
+		Scheme:   t.scheme,
+		Host:     t.host,
+		Path:     path,
+		RawPath:  s3URIEncode(path, false),
+		RawQuery: s3CanonicalQuery(query),
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := s3EmptyPayload
+	if body != nil {
+		req.ContentLength = size
+		// the content is streamed, so it cannot be hashed upfront
+		payloadHash = s3UnsignedPayload
+	}
+	t.sign(req, payloadHash, timeNow())
+	return req, nil
+}
+
+func (t *s3Target) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(s3TimeFormat)
+	date := now.Format(s3DateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + t.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := s3SigningKey(t.secretKey, date, t.region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3SigningKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+// s3URIEncode encodes s as required by AWS signature version 4, i.e. every
+// byte except the unreserved characters is percent-encoded.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
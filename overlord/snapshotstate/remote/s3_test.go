@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// fakeS3 is a minimal stand-in for a S3-compatible service holding a single
+// bucket
+type fakeS3 struct {
+	c       *check.C
+	bucket  string
+	objects map[string][]byte
+	// pageSize limits the number of keys per listing
+	pageSize int
+	requests []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+
+	auth := r.Header.Get("Authorization")
+	f.c.Check(auth, check.Matches, `AWS4-HMAC-SHA256 Credential=access/20260102/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}`)
+	f.c.Check(r.Header.Get("X-Amz-Date"), check.Equals, "20260102T030405Z")
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	if path == r.URL.Path {
+		w.WriteHeader(404)
+		fmt.Fprintf(w, "<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>")
+		return
+	}
+	key := strings.TrimPrefix(path, "/")
+
+	switch {
+	case r.Method == "GET" && key == "":
+		f.list(w, r)
+	case r.Method == "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(404)
+			fmt.Fprintf(w, "<Error><Code>NoSuchKey</Code><Message>no such key</Message></Error>")
+			return
+		}
+		w.Write(data)
+	case r.Method == "PUT":
+		f.c.Check(r.Header.Get("X-Amz-Content-Sha256"), check.Equals, "UNSIGNED-PAYLOAD")
+		data, err := io.ReadAll(r.Body)
+		f.c.Assert(err, check.IsNil)
+		f.c.Check(int64(len(data)), check.Equals, r.ContentLength)
+		f.objects[key] = data
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(405)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f.c.Check(q.Get("list-type"), check.Equals, "2")
+	prefix := q.Get("prefix")
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if token := q.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := len(keys)
+	truncated := false
+	if f.pageSize > 0 && end-start > f.pageSize {
+		end = start + f.pageSize
+		truncated = true
+	}
+	fmt.Fprintf(w, "<ListBucketResult><IsTruncated>%v</IsTruncated>", truncated)
+	for _, k := range keys[start:end] {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", k, len(f.objects[k]))
+	}
+	if truncated {
+		fmt.Fprintf(w, "<NextContinuationToken>%d</NextContinuationToken>", end)
+	}
+	fmt.Fprintf(w, "</ListBucketResult>")
+}
+
+type s3Suite struct {
+	testutil.BaseTest
+
+	fake   *fakeS3
+	server *httptest.Server
+	target remote.Target
+}
+
+var _ = check.Suite(&s3Suite{})
+
+func (s *s3Suite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.fake = &fakeS3{c: c, bucket: "snaps", objects: make(map[string][]byte)}
+	s.server = httptest.NewServer(s.fake)
+	s.AddCleanup(s.server.Close)
+	s.AddCleanup(remote.MockTimeNow(func() time.Time {
+		return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	}))
+
+	var err error
+	s.target, err = remote.New(&remote.Config{
+		Type:      "s3",
+		URL:       s.server.URL + "/snaps/my%20box",
+		Region:    "eu-west-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *s3Suite) TestPutGetListDelete(c *check.C) {
+	ctx := context.Background()
+	s.fake.objects["elsewhere/other.snapshot"] = []byte("not ours")
+	s.fake.objects["my box/nested/deeper.snapshot"] = []byte("not ours either")
+
+	err := s.target.Put(ctx, "one.snapshot", strings.NewReader("hello world"), 11)
+	c.Assert(err, check.IsNil)
+	err = s.target.Put(ctx, "two.snapshot", strings.NewReader("bye"), 3)
+	c.Assert(err, check.IsNil)
+	c.Check(string(s.fake.objects["my box/one.snapshot"]), check.Equals, "hello world")
+
+	names, err := s.target.List(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"one.snapshot", "two.snapshot"})
+
+	rc, err := s.target.Get(ctx, "one.snapshot")
+	c.Assert(err, check.IsNil)
+	data, err := io.ReadAll(rc)
+	c.Assert(err, check.IsNil)
+	c.Check(rc.Close(), check.IsNil)
+	c.Check(string(data), check.Equals, "hello world")
+
+	c.Assert(s.target.Delete(ctx, "one.snapshot"), check.IsNil)
+	_, err = s.target.Get(ctx, "one.snapshot")
+	c.Check(err, check.Equals, remote.ErrNotFound)
+
+	c.Check(s.fake.requests, check.DeepEquals, []string{
+		"PUT /snaps/my%20box/one.snapshot",
+		"PUT /snaps/my%20box/two.snapshot",
+		"GET /snaps?list-type=2&prefix=my%20box%2F",
+		"GET /snaps/my%20box/one.snapshot",
+		"DELETE /snaps/my%20box/one.snapshot",
+		"GET /snaps/my%20box/one.snapshot",
+	})
+}
+
+func (s *s3Suite) TestListPaginated(c *check.C) {
+	s.fake.pageSize = 2
+	for i := 0; i < 5; i++ {
+		s.fake.objects[fmt.Sprintf("my box/%d.snapshot", i)] = nil
+	}
+
+	names, err := s.target.List(context.Background())
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"0.snapshot", "1.snapshot", "2.snapshot", "3.snapshot", "4.snapshot"})
+	c.Check(s.fake.requests, check.HasLen, 3)
+	c.Check(s.fake.requests[2], check.Equals, "GET /snaps?continuation-token=4&list-type=2&prefix=my%20box%2F")
+}
+
+func (s *s3Suite) TestErrorFromService(c *check.C) {
+	target, err := remote.New(&remote.Config{
+		Type:      "s3",
+		URL:       s.server.URL + "/other-bucket",
+		Region:    "eu-west-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	c.Assert(err, check.IsNil)
+
+	_, err = target.List(context.Background())
+	c.Check(err, check.ErrorMatches, `cannot list snapshot target: NoSuchBucket: The specified bucket does not exist`)
+	err = target.Put(context.Background(), "x.snapshot", strings.NewReader("x"), 1)
+	c.Check(err, check.ErrorMatches, `cannot upload "x.snapshot": NoSuchBucket: The specified bucket does not exist`)
+}
+
+func (s *s3Suite) TestSigningKey(c *check.C) {
+	// example from the AWS signature version 4 documentation
+	key := remote.S3SigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	c.Check(hex.EncodeToString(key), check.Equals, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d")
+}
+
+func (s *s3Suite) TestURIEncode(c *check.C) {
+	c.Check(remote.S3URIEncode("/a b/c~d_e.f-g/ü+=", false), check.Equals, "/a%20b/c~d_e.f-g/%C3%BC%2B%3D")
+	c.Check(remote.S3URIEncode("a/b", true), check.Equals, "a%2Fb")
+}
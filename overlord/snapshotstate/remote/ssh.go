@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// sshTarget stores snapshot sets as files in a directory on a host reachable
+// over ssh, by running simple shell commands there.
+type sshTarget struct {
+	destination string
+	port        string
+	dir         string
+	identity    string
+}
+
+func newSSHTarget(cfg *Config) (*sshTarget, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL: %v", err)
+	}
+	if u.Scheme != "ssh" {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL %q: scheme must be ssh", cfg.URL)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL %q: missing host", cfg.URL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL %q: only user, host, port and path are supported", cfg.URL)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL %q: passwords are not supported, use an identity", cfg.URL)
+	}
+	dir := path.Clean("/" + u.Path)
+	if dir == "/" {
+		return nil, fmt.Errorf("invalid ssh snapshot target URL %q: missing directory", cfg.URL)
+	}
+	if cfg.Identity != "" && !strings.HasPrefix(cfg.Identity, "/") {
+		return nil, fmt.Errorf("ssh snapshot target identity must be an absolute path")
+	}
+
+	destination := u.Hostname()
+	if u.User != nil && u.User.Username() != "" {
+		destination = u.User.Username() + "@" + destination
+	}
+	return &sshTarget{
+		destination: destination,
+		port:        u.Port(),
+		dir:         dir,
+		identity:    cfg.Identity,
+	}, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (t *sshTarget) path(name string) string {
+	return shellQuote(path.Join(t.dir, name))
+}
+
+func (t *sshTarget) command(ctx context.Context, remoteCmd string) *exec.Cmd {
+	args := []string{"-o", "BatchMode=yes"}
+	if t.port != "" {
+		args = append(args, "-p", t.port)
+	}
+	if t.identity != "" {
+		args = append(args, "-i", t.identity)
+	}
+	args = append(args, "--", t.destination, remoteCmd)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+func (t *sshTarget) run(ctx context.Context, remoteCmd string, stdin io.Reader) ([]byte, error) {
+	cmd := t.command(ctx, remoteCmd)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, osutil.OutputErr(stderr.Bytes(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (t *sshTarget) Put(ctx context.Context, name string, r io.Reader, size int64) (err error) {
+	partial := t.path(name + ".partial")
+	defer func() {
+		if err != nil {
+			// best effort, not bound to ctx as it might be what failed
+			t.run(context.Background(), "rm -f -- "+partial, nil)
+		}
+	}()
+
+	// cat succeeds whenever its input ends, so the upload is only moved in
+	// place once all of it is known to have made it to the target
+	cr := &countingReader{r: io.LimitReader(r, size)}
+	remoteCmd := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(t.dir), partial)
+	if _, err := t.run(ctx, remoteCmd, cr); err != nil {
+		return fmt.Errorf("cannot upload %q: %v", name, err)
+	}
+	if cr.n != size {
+		return fmt.Errorf("cannot upload %q: expected %d bytes but only %d were read", name, size, cr.n)
+	}
+
+	out, err := t.run(ctx, "wc -c < "+partial, nil)
+	if err != nil {
+		return fmt.Errorf("cannot upload %q: %v", name, err)
+	}
+	if uploaded := strings.TrimSpace(string(out)); uploaded != strconv.FormatInt(size, 10) {
+		return fmt.Errorf("cannot upload %q: expected %d bytes but %s were stored", name, size, uploaded)
+	}
+
+	if _, err := t.run(ctx, fmt.Sprintf("mv -f %s %s", partial, t.path(name)), nil); err != nil {
+		return fmt.Errorf("cannot upload %q: %v", name, err)
+	}
+	return nil
+}
+
+type sshReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (r *sshReader) Close() error {
+	r.ReadCloser.Close()
+	if err := r.cmd.Wait(); err != nil {
+		return osutil.OutputErr(r.stderr.Bytes(), err)
+	}
+	return nil
+}
+
+func (t *sshTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	// tell a missing file apart from other failures before streaming
+	out, err := t.run(ctx, fmt.Sprintf("if test -f %s; then echo yes; fi", t.path(name)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download %q: %v", name, err)
+	}
+	if strings.TrimSpace(string(out)) != "yes" {
+		return nil, ErrNotFound
+	}
+
+	cmd := t.command(ctx, "cat "+t.path(name))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot download %q: %v", name, err)
+	}
+	return &sshReader{ReadCloser: stdout, cmd: cmd, stderr: &stderr}, nil
+}
+
+func (t *sshTarget) List(ctx context.Context) ([]string, error) {
+	dir := shellQuote(t.dir)
+	out, err := t.run(ctx, fmt.Sprintf("if test -d %s; then ls -1 -- %s; fi", dir, dir), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list snapshot target: %v", err)
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\n") {
+		if name == "" || strings.HasSuffix(name, ".partial") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (t *sshTarget) Delete(ctx context.Context, name string) error {
+	if _, err := t.run(ctx, "rm -f -- "+t.path(name), nil); err != nil {
+		return fmt.Errorf("cannot delete %q: %v", name, err)
+	}
+	return nil
+}
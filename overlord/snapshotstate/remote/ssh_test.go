@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sshSuite struct {
+	testutil.BaseTest
+
+	dir    string
+	ssh    *testutil.MockCmd
+	target remote.Target
+}
+
+var _ = check.Suite(&sshSuite{})
+
+func (s *sshSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.dir = filepath.Join(c.MkDir(), "it's here")
+	// run the remote command locally, it is always the last argument
+	s.ssh = testutil.MockCommand(c, "ssh", `for last; do :; done; exec sh -c "$last"`)
+	s.AddCleanup(s.ssh.Restore)
+
+	var err error
+	s.target, err = remote.New(&remote.Config{
+		Type:     "ssh",
+		URL:      "ssh://backup@storage.lan:2222" + strings.ReplaceAll(s.dir, " ", "%20"),
+		Identity: "/etc/snapd-backup/id",
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *sshSuite) TestPutGetListDelete(c *check.C) {
+	ctx := context.Background()
+
+	names, err := s.target.List(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.HasLen, 0)
+
+	err = s.target.Put(ctx, "one.snapshot", strings.NewReader("hello world and more"), 11)
+	c.Assert(err, check.IsNil)
+	c.Check(filepath.Join(s.dir, "one.snapshot"), testutil.FileEquals, "hello world")
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "two.snapshot.partial"), nil, 0644), check.IsNil)
+
+	names, err = s.target.List(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"one.snapshot"})
+
+	rc, err := s.target.Get(ctx, "one.snapshot")
+	c.Assert(err, check.IsNil)
+	data, err := io.ReadAll(rc)
+	c.Assert(err, check.IsNil)
+	c.Check(rc.Close(), check.IsNil)
+	c.Check(string(data), check.Equals, "hello world")
+
+	c.Assert(s.target.Delete(ctx, "one.snapshot"), check.IsNil)
+	c.Check(filepath.Join(s.dir, "one.snapshot"), testutil.FileAbsent)
+	_, err = s.target.Get(ctx, "one.snapshot")
+	c.Check(err, check.Equals, remote.ErrNotFound)
+
+	calls := s.ssh.Calls()
+	c.Assert(calls, check.Not(check.HasLen), 0)
+	c.Check(calls[0][:8], check.DeepEquals, []string{
+		"ssh", "-o", "BatchMode=yes", "-p", "2222", "-i", "/etc/snapd-backup/id", "--",
+	})
+	c.Check(calls[0][8], check.Equals, "backup@storage.lan")
+}
+
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("disk on fire")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *sshSuite) TestPutIncomplete(c *check.C) {
+	ctx := context.Background()
+
+	err := s.target.Put(ctx, "short.snapshot", strings.NewReader("hello"), 11)
+	c.Check(err, check.ErrorMatches, `cannot upload "short.snapshot": expected 11 bytes but only 5 were read`)
+	c.Check(filepath.Join(s.dir, "short.snapshot"), testutil.FileAbsent)
+	c.Check(filepath.Join(s.dir, "short.snapshot.partial"), testutil.FileAbsent)
+
+	err = s.target.Put(ctx, "failed.snapshot", &failingReader{data: "hello"}, 11)
+	c.Check(err, check.ErrorMatches, `cannot upload "failed.snapshot": .*disk on fire.*`)
+	c.Check(filepath.Join(s.dir, "failed.snapshot"), testutil.FileAbsent)
+	c.Check(filepath.Join(s.dir, "failed.snapshot.partial"), testutil.FileAbsent)
+}
+
+func (s *sshSuite) TestPutStoredSizeMismatch(c *check.C) {
+	// the target lost some of the data on the way
+	ssh := testutil.MockCommand(c, "ssh", `for last; do :; done
+case "$last" in
+wc\ *) echo 3;;
+*) exec sh -c "$last";;
+esac`)
+	defer ssh.Restore()
+
+	err := s.target.Put(context.Background(), "one.snapshot", strings.NewReader("hello world"), 11)
+	c.Check(err, check.ErrorMatches, `cannot upload "one.snapshot": expected 11 bytes but 3 were stored`)
+	c.Check(filepath.Join(s.dir, "one.snapshot"), testutil.FileAbsent)
+	c.Check(filepath.Join(s.dir, "one.snapshot.partial"), testutil.FileAbsent)
+}
+
+func (s *sshSuite) TestErrors(c *check.C) {
+	ssh := testutil.MockCommand(c, "ssh", `echo "Permission denied (publickey)." >&2; exit 255`)
+	defer ssh.Restore()
+
+	ctx := context.Background()
+	_, err := s.target.List(ctx)
+	c.Check(err, check.ErrorMatches, `cannot list snapshot target: Permission denied \(publickey\).`)
+	err = s.target.Put(ctx, "x.snapshot", strings.NewReader("x"), 1)
+	c.Check(err, check.ErrorMatches, `cannot upload "x.snapshot": Permission denied \(publickey\).`)
+	_, err = s.target.Get(ctx, "x.snapshot")
+	c.Check(err, check.ErrorMatches, `cannot download "x.snapshot": Permission denied \(publickey\).`)
+	err = s.target.Delete(ctx, "x.snapshot")
+	c.Check(err, check.ErrorMatches, `cannot delete "x.snapshot": Permission denied \(publickey\).`)
+}
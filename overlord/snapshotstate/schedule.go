@@ -0,0 +1,469 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var scheduledSnapshotChangeKind = swfeats.RegisterChangeKind("scheduled-snapshot")
+
+var (
+	// maximum time between scheduled snapshots, whatever the schedule
+	maxScheduledSnapshotDelay = 31 * 24 * time.Hour
+
+	// number of scheduled sets kept when no retention is configured
+	defaultScheduledSnapshotKeepLast = 7
+)
+
+// snapshotSchedule is the configuration of scheduled snapshots.
+type snapshotSchedule struct {
+	Schedule    []*timeutil.Schedule
+	ScheduleStr string
+	// Snaps are the snaps to save, all active snaps if empty
+	Snaps []string
+}
+
+// snapshotRetention is the retention policy of scheduled snapshot sets: a
+// set is kept if it is one of the KeepLast most recent sets, or the most
+// recent set of one of the KeepDaily most recent days, or of one of the
+// KeepWeekly most recent weeks, that have sets.
+type snapshotRetention struct {
+	KeepLast   int
+	KeepDaily  int
+	KeepWeekly int
+}
+
+// snapshotScheduleConfig returns the configured snapshot schedule, or nil
+// if there is none. The state must be locked by the caller.
+func snapshotScheduleConfig(st *state.State) (*snapshotSchedule, error) {
+	tr := config.NewTransaction(st)
+	var scheduleStr, snapsStr string
+	if err := tr.GetMaybe("core", "snapshots.schedule", &scheduleStr); err != nil {
+		return nil, err
+	}
+	if scheduleStr == "" {
+		return nil, nil
+	}
+	if err := tr.GetMaybe("core", "snapshots.scheduled.snaps", &snapsStr); err != nil {
+		return nil, err
+	}
+	schedule, err := timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		// validated when set
+		return nil, fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+	}
+	return &snapshotSchedule{
+		Schedule:    schedule,
+		ScheduleStr: scheduleStr,
+		Snaps:       strutil.CommaSeparatedList(snapsStr),
+	}, nil
+}
+
+// snapshotRetentionConfig returns the configured retention policy of
+// scheduled snapshot sets. The state must be locked by the caller.
+func snapshotRetentionConfig(st *state.State) (*snapshotRetention, error) {
+	tr := config.NewTransaction(st)
+	var retention snapshotRetention
+	for key, value := range map[string]*int{
+		"keep-last":   &retention.KeepLast,
+		"keep-daily":  &retention.KeepDaily,
+		"keep-weekly": &retention.KeepWeekly,
+	} {
+		var valueStr string
+		if err := tr.GetMaybe("core", "snapshots.scheduled."+key, &valueStr); err != nil {
+			return nil, err
+		}
+		if valueStr != "" {
+			// validated when set
+			*value, _ = strconv.Atoi(valueStr)
+		}
+	}
+	if retention.KeepLast == 0 && retention.KeepDaily == 0 && retention.KeepWeekly == 0 {
+		retention.KeepLast = defaultScheduledSnapshotKeepLast
+	}
+	return &retention, nil
+}
+
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == scheduledSnapshotChangeKind && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeTakeScheduledSnapshot takes a snapshot set of the scheduled snaps when
+// the snapshots.schedule says it's time to.
+func (mgr *SnapshotManager) maybeTakeScheduledSnapshot() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	if err := st.Get("seeded", &seeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	schedule, err := snapshotScheduleConfig(st)
+	if err != nil {
+		return err
+	}
+	if schedule == nil {
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = ""
+		return nil
+	}
+	if schedule.ScheduleStr != mgr.lastSnapshotSchedule {
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = schedule.ScheduleStr
+	}
+
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	now := timeNow()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		var last time.Time
+		if err := st.Get("last-scheduled-snapshot", &last); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if last.IsZero() {
+			// the schedule starts when it is first seen
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule.Schedule, last, maxScheduledSnapshotDelay))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if now.Before(mgr.nextScheduledSnapshot) {
+		return nil
+	}
+
+	chg, err := scheduledSnapshot(st, schedule)
+	if err != nil {
+		var conflictErr *snapstate.ChangeConflictError
+		if errors.As(err, &conflictErr) {
+			// retry on next Ensure
+			logger.Debugf("Postponing scheduled snapshot: %v", err)
+			return nil
+		}
+	}
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	if err != nil {
+		return err
+	}
+	if chg != nil {
+		st.EnsureBefore(0)
+	}
+	return nil
+}
+
+// scheduledSnapshot creates the change taking a scheduled snapshot set and
+// then pruning the scheduled sets as per their retention policy. It
+// returns a nil change if there is nothing to save.
+func scheduledSnapshot(st *state.State, schedule *snapshotSchedule) (*state.Change, error) {
+	var names []string
+	if len(schedule.Snaps) == 0 {
+		var err error
+		names, err = allActiveSnapNames(st)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		all, err := snapstateAll(st)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range schedule.Snaps {
+			if snapst, ok := all[name]; !ok || !snapst.Active {
+				logger.Noticef("Skipping snap %q in scheduled snapshot: not installed or not active", name)
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	setID, saved, ts, err := Save(st, names, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range ts.Tasks() {
+		if task.Kind() != "save-snapshot" {
+			continue
+		}
+		var snapshot snapshotSetup
+		if err := task.Get("snapshot-setup", &snapshot); err != nil {
+			return nil, taskGetErrMsg(task, err, "snapshot")
+		}
+		snapshot.Scheduled = true
+		task.Set("snapshot-setup", &snapshot)
+	}
+
+	prune := st.NewTask("prune-scheduled-snapshots", "Prune scheduled snapshot sets")
+	prune.WaitAll(ts)
+	ts.AddTask(prune)
+
+	msg := fmt.Sprintf("Save scheduled snapshot set #%d of snaps %s", setID, strutil.Quoted(saved))
+	chg := st.NewChange(scheduledSnapshotChangeKind, msg)
+	chg.AddAll(ts)
+	chg.Set("set-id", setID)
+	chg.Set("snap-names", saved)
+	return chg, nil
+}
+
+// scheduledSet is a scheduled snapshot set found on disk.
+type scheduledSet struct {
+	SetID uint64
+	Time  time.Time
+	Files []string
+}
+
+// scheduledSetsToForget returns the scheduled sets that fall out of the
+// retention policy.
+func scheduledSetsToForget(sets []scheduledSet, retention *snapshotRetention) map[uint64]bool {
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Time.After(sets[j].Time)
+	})
+
+	keep := make(map[uint64]bool, len(sets))
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, set := range sets {
+		if i < retention.KeepLast {
+			keep[set.SetID] = true
+		}
+		day := set.Time.Local().Format("2006-01-02")
+		if !days[day] && len(days) < retention.KeepDaily {
+			days[day] = true
+			keep[set.SetID] = true
+		}
+		year, week := set.Time.Local().ISOWeek()
+		weekStr := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekStr] && len(weeks) < retention.KeepWeekly {
+			weeks[weekStr] = true
+			keep[set.SetID] = true
+		}
+	}
+
+	forget := make(map[uint64]bool)
+	for _, set := range sets {
+		if !keep[set.SetID] {
+			forget[set.SetID] = true
+		}
+	}
+	return forget
+}
+
+// scheduledSetIDs returns the IDs of the scheduled snapshot sets recorded in
+// the state.
+// The state must be locked by the caller.
+func scheduledSetIDs(st *state.State) ([]uint64, error) {
+	var snapshots map[uint64]*snapshotState
+	if err := st.Get("snapshots", &snapshots); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	var setIDs []uint64
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.Scheduled {
+			setIDs = append(setIDs, setID)
+		}
+	}
+	sort.Slice(setIDs, func(i, j int) bool { return setIDs[i] < setIDs[j] })
+	return setIDs, nil
+}
+
+// scheduledSetsOnDisk returns the given snapshot sets as found on disk, sets
+// which are gone are left out. It does not need the state lock.
+func scheduledSetsOnDisk(setIDs []uint64) (map[uint64]*scheduledSet, error) {
+	sets := make(map[uint64]*scheduledSet, len(setIDs))
+	for _, setID := range setIDs {
+		sets[setID] = nil
+	}
+	err := backendIter(context.TODO(), func(r *backend.Reader) error {
+		set, ok := sets[r.SetID]
+		if !ok {
+			return nil
+		}
+		if set == nil {
+			set = &scheduledSet{SetID: r.SetID, Time: r.Time}
+			sets[r.SetID] = set
+		} else if r.Time.Before(set.Time) {
+			set.Time = r.Time
+		}
+		set.Files = append(set.Files, r.Name())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for setID, set := range sets {
+		if set == nil {
+			delete(sets, setID)
+		}
+	}
+	return sets, nil
+}
+
+// forgetScheduledSets drops from the state the given scheduled sets which are
+// gone from disk or fall out of the retention policy, and marks the latter
+// as being forgotten. Sets with conflicting operations are left for next time.
+// It returns the sets whose files are to be removed, sorted by set ID.
+// The state must be locked by the caller.
+func forgetScheduledSets(st *state.State, setIDs []uint64, onDisk map[uint64]*scheduledSet, retention *snapshotRetention) ([]*scheduledSet, error) {
+	var gone []uint64
+	sets := make([]scheduledSet, 0, len(onDisk))
+	for _, setID := range setIDs {
+		set, ok := onDisk[setID]
+		if !ok {
+			gone = append(gone, setID)
+			continue
+		}
+		sets = append(sets, *set)
+	}
+	if err := removeSnapshotState(st, gone...); err != nil {
+		return nil, err
+	}
+
+	forget := scheduledSetsToForget(sets, retention)
+	var forgotten []*scheduledSet
+	for _, setID := range setIDs {
+		if !forget[setID] {
+			continue
+		}
+		// forget needs to conflict with check, restore and offload
+		if err := checkSnapshotConflict(st, setID, "export-snapshot",
+			"check-snapshot", "restore-snapshot", "offload-snapshot", "forget-snapshot"); err != nil {
+			// there is a conflict, the set is retried next time
+			continue
+		}
+		// as with forgetSnapshotSets, the state goes first so that a
+		// failing removal is not retried forever
+		if err := removeSnapshotState(st, setID); err != nil {
+			return nil, fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", setID, err)
+		}
+		setSnapshotOpInProgress(st, setID, "forget-snapshot")
+		forgotten = append(forgotten, onDisk[setID])
+	}
+	return forgotten, nil
+}
+
+func removeScheduledSetFiles(sets []*scheduledSet) error {
+	for _, set := range sets {
+		for _, name := range set.Files {
+			if err := osRemove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("cannot remove snapshot file %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func doPruneScheduledSnapshots(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	retention, err := snapshotRetentionConfig(st)
+	var setIDs []uint64
+	if err == nil {
+		setIDs, err = scheduledSetIDs(st)
+	}
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// going through and removing the archives can take a while, it is
+	// done without holding the state lock
+	onDisk, err := scheduledSetsOnDisk(setIDs)
+	if err != nil {
+		return fmt.Errorf("cannot list scheduled snapshot sets: %v", err)
+	}
+
+	st.Lock()
+	forgotten, err := forgetScheduledSets(st, setIDs, onDisk, retention)
+	st.Unlock()
+	if err != nil {
+		return fmt.Errorf("cannot prune scheduled snapshot sets: %v", err)
+	}
+
+	err = removeScheduledSetFiles(forgotten)
+
+	st.Lock()
+	defer st.Unlock()
+	for _, set := range forgotten {
+		UnsetSnapshotOpInProgress(st, set.SetID)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot prune scheduled snapshot sets: %v", err)
+	}
+
+	prunedStrs := make([]string, 0, len(forgotten))
+	for _, set := range forgotten {
+		prunedStrs = append(prunedStrs, strconv.FormatUint(set.SetID, 10))
+	}
+	if len(prunedStrs) > 0 {
+		requestPruneChunks(st)
+		task.Logf("Forgot scheduled snapshot sets %s", strings.Join(prunedStrs, ", "))
+	}
+	chg := task.Change()
+	var setID uint64
+	if err := chg.Get("set-id", &setID); err != nil {
+		return err
+	}
+	var snapNames []string
+	if err := chg.Get("snap-names", &snapNames); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	_, err = st.AddNotice(nil, state.SnapshotScheduleNotice, strconv.FormatUint(setID, 10), &state.AddNoticeOptions{
+		Data: map[string]string{
+			"change-id": chg.ID(),
+			"snaps":     strings.Join(snapNames, ","),
+			"pruned":    strings.Join(prunedStrs, ","),
+		},
+	})
+	return err
+}
@@ -0,0 +1,269 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (snapshotSuite) TestScheduledSetsToForget(c *check.C) {
+	// a set every 12h, from newest (1) to oldest (30) over 15 days
+	newest := time.Date(2026, 3, 20, 18, 0, 0, 0, time.Local)
+	times := make(map[uint64]time.Time)
+	for i := uint64(1); i <= 30; i++ {
+		times[i] = newest.Add(-time.Duration(i-1) * 12 * time.Hour)
+	}
+	setsOf := func(ids ...uint64) map[uint64]bool {
+		m := make(map[uint64]bool)
+		for _, id := range ids {
+			m[id] = true
+		}
+		return m
+	}
+	allBut := func(ids ...uint64) map[uint64]bool {
+		keep := setsOf(ids...)
+		m := make(map[uint64]bool)
+		for id := range times {
+			if !keep[id] {
+				m[id] = true
+			}
+		}
+		return m
+	}
+
+	c.Check(snapshotstate.ScheduledSetsToForget(times, 3, 0, 0), check.DeepEquals, allBut(1, 2, 3))
+	// the newest set of each of the 3 most recent days
+	c.Check(snapshotstate.ScheduledSetsToForget(times, 0, 3, 0), check.DeepEquals, allBut(1, 3, 5))
+	// 2026-03-20 is a Friday, weeks start on Monday so set 10 is the
+	// oldest of the week and set 11 the newest of the week before
+	c.Check(snapshotstate.ScheduledSetsToForget(times, 0, 0, 2), check.DeepEquals, allBut(1, 11))
+	c.Check(snapshotstate.ScheduledSetsToForget(times, 2, 2, 3), check.DeepEquals, allBut(1, 2, 3, 11, 25))
+	c.Check(snapshotstate.ScheduledSetsToForget(times, 50, 0, 0), check.HasLen, 0)
+	c.Check(snapshotstate.ScheduledSetsToForget(nil, 1, 1, 1), check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshot(c *check.C) {
+	defer snapshotstate.MockBackendIter(func(context.Context, func(*backend.Reader) error) error { return nil })()
+	defer snapshotstate.MockSnapstateCheckChangeConflictMany(func(*state.State, []string, string) error { return nil })()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	for _, name := range []string{"a-snap", "b-snap"} {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			}),
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "0:00-23:59")
+	tr.Set("core", "snapshots.scheduled.snaps", "a-snap,c-snap")
+	tr.Commit()
+	last := time.Now().Add(-72 * time.Hour)
+	st.Set("last-scheduled-snapshot", last)
+
+	// nothing happens until seeded
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+
+	st.Set("seeded", true)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Save scheduled snapshot set #1 of snaps "a-snap"`)
+	var setID uint64
+	c.Assert(chg.Get("set-id", &setID), check.IsNil)
+	c.Check(setID, check.Equals, uint64(1))
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"a-snap"})
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Kind(), check.Equals, "save-snapshot")
+	var snapshot map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]any{
+		"set-id":    1.,
+		"snap":      "a-snap",
+		"current":   "unset",
+		"scheduled": true,
+	})
+	c.Check(tasks[1].Kind(), check.Equals, "prune-scheduled-snapshots")
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+
+	var newLast time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &newLast), check.IsNil)
+	c.Check(newLast.After(last), check.Equals, true)
+
+	// no new run while one is in flight
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotUnset(c *check.C) {
+	defer snapshotstate.MockBackendIter(func(context.Context, func(*backend.Reader) error) error { return nil })()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+	st.Set("seeded", true)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Check(st.Get("last-scheduled-snapshot", &last), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *snapshotSuite) TestDoPruneScheduledSnapshots(c *check.C) {
+	dir := c.MkDir()
+	newest := time.Now()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for setID := uint64(1); setID <= 5; setID++ {
+			shotfile, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d_a-snap.zip", setID)))
+			c.Assert(err, check.IsNil)
+			defer shotfile.Close()
+			err = f(&backend.Reader{
+				Snapshot: client.Snapshot{
+					SetID: setID,
+					Snap:  "a-snap",
+					Time:  newest.Add(-time.Duration(5-setID) * time.Hour),
+				},
+				File: shotfile,
+			})
+			c.Assert(err, check.IsNil)
+		}
+		return nil
+	})()
+	st := state.New(nil)
+
+	var removed []string
+	defer snapshotstate.MockOsRemove(func(name string) error {
+		// the archives are removed without holding the state lock,
+		// the sets being marked as forgotten meanwhile
+		st.Lock()
+		defer st.Unlock()
+		c.Check(st.Cached("snapshot-ops"), check.DeepEquals, map[uint64]string{
+			2: "forget-snapshot",
+			3: "forget-snapshot",
+		})
+		removed = append(removed, filepath.Base(name))
+		return nil
+	})()
+	pruned := 0
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int, error) {
+		pruned++
+		return 0, nil
+	})()
+
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.scheduled.keep-last", "2")
+	tr.Commit()
+	st.Set("snapshots", map[uint64]any{
+		// set 1 is an automatic snapshot, left alone
+		1: map[string]any{"expiry-time": "2037-02-12T12:50:00Z"},
+		2: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+		3: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+		4: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+		5: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+		// set 9 is gone
+		9: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+	})
+
+	chg := st.NewChange("scheduled-snapshot", "...")
+	chg.Set("set-id", 5)
+	chg.Set("snap-names", []string{"a-snap"})
+	task := st.NewTask("prune-scheduled-snapshots", "...")
+	chg.AddTask(task)
+
+	st.Unlock()
+	err := snapshotstate.DoPruneScheduledSnapshots(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+
+	c.Check(removed, check.DeepEquals, []string{"2_a-snap.zip", "3_a-snap.zip"})
+	// pruning the chunk store is left to Ensure
+	c.Check(pruned, check.Equals, 0)
+	var prunePending bool
+	c.Assert(st.Get("snapshot-chunks-prune-pending", &prunePending), check.IsNil)
+	c.Check(prunePending, check.Equals, true)
+	var snapshots map[uint64]any
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.HasLen, 3)
+	c.Check(snapshots[1], check.NotNil)
+	c.Check(snapshots[4], check.NotNil)
+	c.Check(snapshots[5], check.NotNil)
+	c.Check(st.Cached("snapshot-ops"), check.DeepEquals, map[uint64]string{})
+
+	notices := st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapshotScheduleNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	data, err := json.Marshal(notices[0])
+	c.Assert(err, check.IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(data, &n), check.IsNil)
+	c.Check(n["key"], check.Equals, "5")
+	c.Check(n["last-data"], check.DeepEquals, map[string]any{
+		"change-id": chg.ID(),
+		"snaps":     "a-snap",
+		"pruned":    "2,3",
+	})
+}
@@ -22,14 +22,17 @@ package snapshotstate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
@@ -45,6 +48,9 @@ var (
 	configSetSnapConfig  = config.SetSnapConfig
 	backendOpen          = backend.Open
 	backendSave          = backend.Save
+	backendSaveDedup     = backend.SaveDeduplicated
+	backendSaveEncrypted = backend.SaveEncrypted
+	backendUnlock        = (*backend.Reader).Unlock
 	backendImport        = backend.Import
 	backendRestore       = (*backend.Reader).Restore // TODO: look into using an interface instead
 	backendCheck         = (*backend.Reader).Check
@@ -52,17 +58,25 @@ var (
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandonedImports = backend.CleanupAbandonedImports
+	backendPruneChunks             = backend.PruneChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
 	getSnapDirOpts = snapstate.GetSnapDirOpts
 )
 
+// chunksLock keeps deduplicated snapshot saves, which reuse the data already
+// in the chunk store, from running concurrently with pruning that data.
+var chunksLock sync.RWMutex
+
 // SnapshotManager takes snapshots of active snaps
 type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	nextScheduledSnapshot time.Time
+	lastSnapshotSchedule  string
 }
 
 // Manager returns a new SnapshotManager
@@ -74,6 +88,8 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 	runner.AddHandler("check-snapshot", doCheck, nil)
 	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
 	runner.AddHandler("cleanup-after-restore", doCleanupAfterRestore, nil)
+	runner.AddHandler("offload-snapshot", doOffload, nil)
+	runner.AddHandler("prune-scheduled-snapshots", doPruneScheduledSnapshots, nil)
 
 	manager := &SnapshotManager{
 		state: st,
@@ -85,12 +101,23 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 // Ensure is part of the overlord.StateManager interface.
 func (mgr *SnapshotManager) Ensure() error {
+	mgr.state.Lock()
+	dropUnusedPassphrases(mgr.state)
+	mgr.state.Unlock()
+
+	if err := mgr.maybeTakeScheduledSnapshot(); err != nil {
+		logger.Noticef("cannot take scheduled snapshot: %v", err)
+	}
+
 	// process expired snapshots once a day.
+	var err error
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		err = mgr.forgetExpiredSnapshots()
 	}
 
-	return nil
+	mgr.maybePruneChunks()
+
+	return err
 }
 
 func (mgr *SnapshotManager) StartUp() error {
@@ -113,10 +140,28 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return nil
 	}
 
-	err = backendIter(context.TODO(), func(r *backend.Reader) error {
-		// forget needs to conflict with check and restore
-		if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
-			"check-snapshot", "restore-snapshot"); err != nil {
+	if err := forgetSnapshotSets(mgr.state, sets); err != nil {
+		return fmt.Errorf("cannot process expired snapshots: %v", err)
+	}
+	requestPruneChunks(mgr.state)
+
+	// only reset time if there are no sets left because of conflicts
+	if len(sets) == 0 {
+		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	}
+
+	return nil
+}
+
+// forgetSnapshotSets removes the given snapshot sets, skipping the ones
+// with conflicting operations in progress; the sets that were removed are
+// deleted from sets.
+// The state needs to be locked by the caller.
+func forgetSnapshotSets(st *state.State, sets map[uint64]bool) error {
+	return backendIter(context.TODO(), func(r *backend.Reader) error {
+		// forget needs to conflict with check, restore and offload
+		if err := checkSnapshotConflict(st, r.SetID, "export-snapshot",
+			"check-snapshot", "restore-snapshot", "offload-snapshot"); err != nil {
 			// there is a conflict, do nothing and we will retry this set on next Ensure().
 			return nil
 		}
@@ -126,7 +171,7 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 			// to automatically remove this snapshot again and will leave it on the disk (so the user can still try to remove it manually);
 			// this is better than the other way around where a failing osRemove would be retried forever because snapshot would never
 			// leave the state.
-			if err := removeSnapshotState(mgr.state, r.SetID); err != nil {
+			if err := removeSnapshotState(st, r.SetID); err != nil {
 				return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", r.SetID, err)
 			}
 			if err := osRemove(r.Name()); err != nil {
@@ -135,22 +180,11 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		}
 		return nil
 	})
-
-	if err != nil {
-		return fmt.Errorf("cannot process expired snapshots: %v", err)
-	}
-
-	// only reset time if there are no sets left because of conflicts
-	if len(sets) == 0 {
-		mgr.lastForgetExpiredSnapshotTime = time.Now()
-	}
-
-	return nil
 }
 
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
-	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" {
-		// check and forget don't affect snaps
+	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" || k == "offload-snapshot" {
+		// check, forget and offload don't affect snaps
 		// (this could also be written k != save && k != restore, but it's safer this way around)
 		return nil, nil
 	}
@@ -170,6 +204,10 @@ type snapshotSetup struct {
 	Filename string                `json:"filename,omitempty"`
 	Current  snap.Revision         `json:"current"`
 	Auto     bool                  `json:"auto,omitempty"`
+	// Scheduled is set when saving a set as per snapshots.schedule
+	Scheduled bool `json:"scheduled,omitempty"`
+	// Encryption is the key protection of encrypted snapshots
+	Encryption string `json:"encryption,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -215,6 +253,11 @@ func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg
 			return nil, nil, nil, err
 		}
 	}
+	if snapshot.Scheduled {
+		if err := saveSnapshotState(st, snapshot.SetID, &snapshotState{Scheduled: true}); err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
 	return snapshot, cur, cfg, nil
 }
@@ -228,12 +271,36 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 	st.Lock()
 	opts, err := getSnapDirOpts(st, snapshot.Snap)
+	var deduplicate bool
+	if err == nil {
+		deduplicate, err = features.Flag(config.NewTransaction(st), features.SnapshotDeduplication)
+	}
+	var passphrase string
+	if err == nil {
+		passphrase, err = snapshotSecret(st, snapshot)
+	}
 	st.Unlock()
 	if err != nil {
 		return err
 	}
 
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
+	save := backendSave
+	switch {
+	case snapshot.Encryption != "":
+		// the chunk store is not encrypted, so encrypted snapshots are
+		// never deduplicated
+		secret := &backend.Secret{KeyProtection: snapshot.Encryption, Passphrase: passphrase}
+		save = func(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+			return backendSaveEncrypted(ctx, id, si, cfg, usernames, dynOpts, dirOpts, secret)
+		}
+	case deduplicate:
+		// the data reused from the chunk store must not be pruned
+		// before the snapshot referencing it is written
+		chunksLock.RLock()
+		defer chunksLock.RUnlock()
+		save = backendSaveDedup
+	}
+	_, err = save(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
@@ -244,27 +311,44 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 // prepareRestore does the steps of doRestore that require the state lock
 // before the backend Restore call.
-func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[string]any, reader *backend.Reader, err error) {
+func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[string]any, passphrase string, err error) {
 	st := task.State()
 
 	st.Lock()
 	defer st.Unlock()
 
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
-		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
+		return nil, nil, "", taskGetErrMsg(task, err, "snapshot")
 	}
 
 	oldCfg, err = unmarshalSnapConfig(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, "", err
 	}
-	reader, err = backendOpen(snapshot.Filename, backend.ExtractFnameSetID)
+	passphrase, err = snapshotSecret(st, snapshot)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("cannot open snapshot: %v", err)
+		return nil, nil, "", err
 	}
-	// note given the Open succeeded, caller needs to close it when done
 
-	return snapshot, oldCfg, reader, nil
+	return snapshot, oldCfg, passphrase, nil
+}
+
+// openSnapshot opens the snapshot of the task, unlocking it if it is
+// encrypted. Unlocking can take a while, so it must be called without the
+// state lock held.
+func openSnapshot(snapshot *snapshotSetup, passphrase string) (*backend.Reader, error) {
+	reader, err := backendOpen(snapshot.Filename, backend.ExtractFnameSetID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	if reader.IsEncrypted() {
+		if err := backendUnlock(reader, passphrase); err != nil {
+			reader.Close()
+			return nil, err
+		}
+	}
+	// note given the Open succeeded, caller needs to close it when done
+	return reader, nil
 }
 
 // marshalSnapConfig encodes cfg to JSON and returns raw JSON message, unless
@@ -298,7 +382,11 @@ func unmarshalSnapConfig(st *state.State, snapName string) (map[string]any, erro
 }
 
 func doRestore(task *state.Task, tomb *tomb.Tomb) error {
-	snapshot, oldCfg, reader, err := prepareRestore(task)
+	snapshot, oldCfg, passphrase, err := prepareRestore(task)
+	if err != nil {
+		return err
+	}
+	reader, err := openSnapshot(snapshot, passphrase)
 	if err != nil {
 		return err
 	}
@@ -422,14 +510,19 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	st := task.State()
 	st.Lock()
 	err := task.Get("snapshot-setup", &snapshot)
-	st.Unlock()
 	if err != nil {
+		st.Unlock()
 		return taskGetErrMsg(task, err, "snapshot")
 	}
+	passphrase, err := snapshotSecret(st, &snapshot)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
 
-	reader, err := backendOpen(snapshot.Filename, backend.ExtractFnameSetID)
+	reader, err := openSnapshot(&snapshot, passphrase)
 	if err != nil {
-		return fmt.Errorf("cannot open snapshot: %v", err)
+		return err
 	}
 	defer reader.Close()
 
@@ -458,7 +551,54 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+
+	requestPruneChunks(st)
+	return nil
+}
+
+// requestPruneChunks asks for the data of deduplicated snapshots that is no
+// longer needed by any snapshot to be removed by the next Ensure.
+// The state must be locked by the caller.
+func requestPruneChunks(st *state.State) {
+	st.Set("snapshot-chunks-prune-pending", true)
+	st.EnsureBefore(0)
+}
+
+// maybePruneChunks removes the data of deduplicated snapshots that is no
+// longer needed by any snapshot, if requested. Walking the chunk store can
+// take a while so this is done without holding the state lock; chunksLock
+// keeps deduplicated saves from running meanwhile instead.
+func (mgr *SnapshotManager) maybePruneChunks() {
+	if !chunksLock.TryLock() {
+		// a snapshot being saved may be reusing data that no other
+		// snapshot needs anymore, try again on the next Ensure
+		return
+	}
+	defer chunksLock.Unlock()
+
+	st := mgr.state
+	st.Lock()
+	var pending bool
+	err := st.Get("snapshot-chunks-prune-pending", &pending)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		st.Unlock()
+		logger.Noticef("Cannot prune data of deduplicated snapshots: %v", err)
+		return
+	}
+	if pending {
+		st.Set("snapshot-chunks-prune-pending", nil)
+	}
+	st.Unlock()
+	if !pending {
+		return
+	}
+
+	if _, err := backendPruneChunks(context.TODO()); err != nil {
+		logger.Noticef("Cannot prune data of deduplicated snapshots: %v", err)
+	}
 }
 
 func delayedCrossMgrInit() {
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -55,6 +56,8 @@ func (snapshotSuite) TestManager(c *check.C) {
 		"check-snapshot",
 		"cleanup-after-restore",
 		"forget-snapshot",
+		"offload-snapshot",
+		"prune-scheduled-snapshots",
 		"restore-snapshot",
 		"save-snapshot",
 	})
@@ -91,6 +94,12 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	restore := mockFakeSnapshot(c)
 	defer restore()
 
+	pruned := 0
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int, error) {
+		pruned++
+		return 0, nil
+	})()
+
 	st := state.New(nil)
 	runner := state.NewTaskRunner(st)
 	mgr := snapshotstate.Manager(st, runner)
@@ -114,6 +123,7 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	c.Check(expirations, check.DeepEquals, map[uint64]any{
 		2: map[string]any{"expiry-time": "2037-02-12T12:50:00Z"}})
 	c.Check(removedSnapshot, check.Matches, ".*/foo.zip")
+	c.Check(pruned, check.Equals, 1)
 }
 
 func (snapshotSuite) TestEnsureForgetsSnapshotsRunsRegularly(c *check.C) {
@@ -357,6 +367,51 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	c.Check(checkOpts, check.Equals, true)
 }
 
+func (snapshotSuite) TestDoSaveDeduplicated(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+
+	var calls []string
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		calls = append(calls, "save")
+		return nil, nil
+	})()
+	defer snapshotstate.MockBackendSaveDeduplicated(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		c.Check(id, check.Equals, uint64(42))
+		c.Check(si, check.DeepEquals, &snapInfo)
+		calls = append(calls, "save-deduplicated")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(calls, check.DeepEquals, []string{"save"})
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.snapshot-deduplication", true)
+	tr.Commit()
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(calls, check.DeepEquals, []string{"save", "save-deduplicated"})
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
@@ -590,6 +645,10 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 		snapshotstate.MockBackendCleanup(func(*backend.RestoreState) {
 			rs.calls = append(rs.calls, "cleanup")
 		}),
+		snapshotstate.MockBackendPruneChunks(func(context.Context) (int, error) {
+			rs.calls = append(rs.calls, "prune")
+			return 0, nil
+		}),
 	}
 }
 
@@ -846,7 +905,89 @@ func (rs *readerSuite) TestDoRemove(c *check.C) {
 	})()
 	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
 	c.Assert(err, check.IsNil)
+	// pruning is left to Ensure
 	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+
+	st := rs.task.State()
+	st.Lock()
+	defer st.Unlock()
+	var pending bool
+	c.Assert(st.Get("snapshot-chunks-prune-pending", &pending), check.IsNil)
+	c.Check(pending, check.Equals, true)
+}
+
+func (rs *readerSuite) TestEnsurePrunesChunks(c *check.C) {
+	st := rs.task.State()
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	// nothing to prune
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(rs.calls, check.HasLen, 0)
+
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune"})
+
+	// only once
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune"})
+}
+
+func (rs *readerSuite) TestEnsurePrunesChunksWithoutStateLock(c *check.C) {
+	st := rs.task.State()
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int, error) {
+		// the state lock is not held while pruning
+		st.Lock()
+		st.Unlock()
+		rs.calls = append(rs.calls, "prune")
+		return 0, nil
+	})()
+
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune"})
+}
+
+func (rs *readerSuite) TestEnsureNoPruneWhileSaving(c *check.C) {
+	st := rs.task.State()
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+
+	// a deduplicated save is in progress
+	snapshotstate.ChunksLock.RLock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	snapshotstate.ChunksLock.RUnlock()
+	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+
+	// pruning is retried once the save is done
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune"})
+}
+
+func (rs *readerSuite) TestEnsurePruneErrorLogged(c *check.C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int, error) {
+		return 0, errors.New("boom")
+	})()
+
+	st := rs.task.State()
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(logbuf.String(), testutil.Contains, "Cannot prune data of deduplicated snapshots: boom")
 }
 
 func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
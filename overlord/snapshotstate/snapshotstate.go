@@ -53,6 +53,9 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// Scheduled is set for the sets taken as per snapshots.schedule,
+	// which are subject to its retention policy.
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -154,6 +157,14 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
+	return saveSnapshotState(st, setID, &snapshotState{
+		ExpiryTime: expiryTime,
+	})
+}
+
+// saveSnapshotState saves the record of the given snapshot set, in the state.
+// The state needs to be locked by the caller.
+func saveSnapshotState(st *state.State, setID uint64, snapshotSet *snapshotState) error {
 	var snapshots map[uint64]*json.RawMessage
 	err := st.Get("snapshots", &snapshots)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -162,9 +173,7 @@ func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
 	if snapshots == nil {
 		snapshots = make(map[uint64]*json.RawMessage)
 	}
-	data, err := json.Marshal(&snapshotState{
-		ExpiryTime: expiryTime,
-	})
+	data, err := json.Marshal(snapshotSet)
 	if err != nil {
 		return err
 	}
@@ -207,7 +216,9 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
-		if snapshotSet.ExpiryTime.Before(cutoffTime) {
+		// scheduled sets have no expiry time, they are pruned as per
+		// their retention policy instead
+		if !snapshotSet.ExpiryTime.IsZero() && snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
 	}
@@ -219,6 +230,17 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 // snapshot set when deciding whether to check/forget/restore it.
 type snapshotSnapSummaries []*snapshotSnapSummary
 
+// needsPassphrase returns whether any of the snapshots is encrypted with a
+// passphrase protected key.
+func (summaries snapshotSnapSummaries) needsPassphrase() bool {
+	for _, summary := range summaries {
+		if summary.encryption == backend.KeyProtectionPassphrase {
+			return true
+		}
+	}
+	return false
+}
+
 func (summaries snapshotSnapSummaries) snapNames() []string {
 	names := make([]string, len(summaries))
 	for i, summary := range summaries {
@@ -228,10 +250,11 @@ func (summaries snapshotSnapSummaries) snapNames() []string {
 }
 
 type snapshotSnapSummary struct {
-	snap     string
-	snapID   string
-	filename string
-	epoch    snap.Epoch
+	snap       string
+	snapID     string
+	filename   string
+	epoch      snap.Epoch
+	encryption string
 }
 
 // snapSummariesInSnapshotSet goes looking for the requested snaps in the
@@ -243,12 +266,16 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 		if r.SetID == setID {
 			found = true
 			if len(requested) == 0 || strutil.SortedListContains(requested, r.Snap) {
-				summaries = append(summaries, &snapshotSnapSummary{
+				summary := &snapshotSnapSummary{
 					filename: r.Name(),
 					snap:     r.Snap,
 					snapID:   r.SnapID,
 					epoch:    r.Epoch,
-				})
+				}
+				if r.Encryption != nil {
+					summary.encryption = r.Encryption.KeyProtection
+				}
+				summaries = append(summaries, summary)
 			}
 		}
 
@@ -365,9 +392,10 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 
 			// trying to import identical snapshot; instead return set ID of
 			// the existing one and reset its expiry time.
-			// XXX: at the moment expiry-time is the only attribute so we can
-			// just remove the record. If we ever add more attributes this needs
-			// to reset expiry-time only.
+			// XXX: removing the record also takes a scheduled set out of
+			// the schedule retention, which is fine for a set the user
+			// imported explicitly. If we ever add more attributes this
+			// needs to reset expiry-time only.
 			if err := removeSnapshotState(st, dupErr.SetID); err != nil {
 				return 0, nil, err
 			}
@@ -381,6 +409,21 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 // Save creates a taskset for taking snapshots of snaps' data.
 // Note that the state must be locked by the caller.
 func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	return save(st, instanceNames, users, options, nil)
+}
+
+// SaveEncrypted creates a taskset for taking encrypted snapshots of snaps'
+// data, with the key of each snapshot protected as described by the secret.
+// A passphrase is only kept in memory until the tasks are done.
+// Note that the state must be locked by the caller.
+func SaveEncrypted(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, secret *backend.Secret) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if err := validateSecret(secret); err != nil {
+		return 0, nil, nil, err
+	}
+	return save(st, instanceNames, users, options, secret)
+}
+
+func save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, secret *backend.Secret) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -409,6 +452,14 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		return 0, nil, nil, err
 	}
 
+	var encryption string
+	if secret != nil {
+		encryption = secret.KeyProtection
+		if secret.KeyProtection == backend.KeyProtectionPassphrase {
+			setSnapshotPassphrase(st, setID, secret.Passphrase)
+		}
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, name := range instanceNames {
@@ -416,10 +467,11 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		task := st.NewTask("save-snapshot", desc)
 
 		snapshot := snapshotSetup{
-			SetID:   setID,
-			Snap:    name,
-			Users:   users,
-			Options: options[name],
+			SetID:      setID,
+			Snap:       name,
+			Users:      users,
+			Options:    options[name],
+			Encryption: encryption,
 		}
 
 		task.Set("snapshot-setup", &snapshot)
@@ -437,6 +489,10 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		ts.AddTask(task)
 	}
 
+	if err := addOffloadTask(st, setID, ts); err != nil {
+		return 0, nil, nil, err
+	}
+
 	return setID, instanceNames, ts, nil
 }
 
@@ -490,6 +546,10 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		return nil, nil, err
 	}
 
+	if summaries.needsPassphrase() && snapshotPassphrase(st, setID) == "" {
+		return nil, nil, &PassphraseRequiredError{SetID: setID}
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, summary := range summaries {
@@ -514,11 +574,12 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
 		task := st.NewTask("restore-snapshot", desc)
 		snapshot := snapshotSetup{
-			SetID:    setID,
-			Snap:     summary.snap,
-			Users:    users,
-			Filename: summary.filename,
-			Current:  current,
+			SetID:      setID,
+			Snap:       summary.snap,
+			Users:      users,
+			Filename:   summary.filename,
+			Current:    current,
+			Encryption: summary.encryption,
 		}
 		task.Set("snapshot-setup", &snapshot)
 		// see the note about snapshots not using lanes, above.
@@ -551,16 +612,21 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 		return nil, nil, err
 	}
 
+	if summaries.needsPassphrase() && snapshotPassphrase(st, setID) == "" {
+		return nil, nil, &PassphraseRequiredError{SetID: setID}
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, summary := range summaries {
 		desc := fmt.Sprintf("Check data of snap %q in snapshot set #%d", summary.snap, setID)
 		task := st.NewTask("check-snapshot", desc)
 		snapshot := snapshotSetup{
-			SetID:    setID,
-			Snap:     summary.snap,
-			Users:    users,
-			Filename: summary.filename,
+			SetID:      setID,
+			Snap:       summary.snap,
+			Users:      users,
+			Filename:   summary.filename,
+			Encryption: summary.encryption,
 		}
 		task.Set("snapshot-setup", &snapshot)
 		ts.AddTask(task)
@@ -572,9 +638,10 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 // Forget creates a taskset for deletinig a snapshot.
 // Note that the state must be locked by the caller.
 func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []string, ts *state.TaskSet, err error) {
-	// forget needs to conflict with check, restore, import and export.
+	// forget needs to conflict with check, restore, import, export and
+	// offload.
 	if err := checkSnapshotConflict(st, setID, "export-snapshot",
-		"check-snapshot", "restore-snapshot"); err != nil {
+		"check-snapshot", "restore-snapshot", "offload-snapshot"); err != nil {
 		return nil, nil, err
 	}
 
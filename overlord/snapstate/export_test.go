@@ -642,3 +642,5 @@ var ArrangeInstallTasksForSingleReboot = arrangeInstallTasksForSingleReboot
 func MockProcessDelayedSecurityBackendEffects(f func(st *state.State, lanes []int, joinLane int) (ts *state.TaskSet)) (restore func()) {
 	return testutil.Mock(&ProcessDelayedSecurityBackendEffects, f)
 }
+
+var RolloutBucket = rolloutBucket
@@ -3974,6 +3974,9 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := pruneSnapsHold(st, snapsup.InstanceName()); err != nil {
 			return err
 		}
+		if err := pruneRolloutRevisions(st, snapsup.InstanceName()); err != nil {
+			return err
+		}
 
 		// Remove configuration associated with this snap.
 		err = config.DeleteSnapConfig(st, snapsup.InstanceName())
@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// defaultHealthCheckTimeout is how long, after the grace period, a refreshed
+// snap can report that it is waiting before the refresh is reverted.
+const defaultHealthCheckTimeout = 5 * time.Minute
+
+// HealthRollback is set, as "health-rollback", on the health check task of
+// refreshes which are reverted if the snap does not become healthy.
+type HealthRollback struct {
+	// GracePeriod is how long to wait after the snap is linked before
+	// running its health check for the first time.
+	GracePeriod time.Duration `json:"grace-period,omitempty"`
+	// Timeout is how long, after the grace period, the health check is
+	// run again while the snap reports that it is waiting.
+	Timeout time.Duration `json:"timeout"`
+}
+
+func parseHealthCheckDuration(tr *config.Transaction, option string, def time.Duration) (time.Duration, error) {
+	var value string
+	if err := tr.GetMaybe("core", option, &value); err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s: %v", option, err)
+	}
+	return d, nil
+}
+
+// healthRollback returns how the health of the given snap is checked after
+// it is refreshed, or nil if the refresh is not reverted when the snap does
+// not become healthy, as set by the refresh.health-check.* core options.
+func healthRollback(st *state.State, instanceName string) (*HealthRollback, error) {
+	tr := config.NewTransaction(st)
+	// the option can be a boolean or a string, as with other flags
+	var all any
+	if err := tr.GetMaybe("core", "refresh.health-check.rollback", &all); err != nil {
+		return nil, err
+	}
+	if fmt.Sprint(all) != "true" {
+		var snaps string
+		if err := tr.GetMaybe("core", "refresh.health-check.rollback-snaps", &snaps); err != nil {
+			return nil, err
+		}
+		if !strutil.ListContains(strutil.CommaSeparatedList(snaps), instanceName) {
+			return nil, nil
+		}
+	}
+
+	gracePeriod, err := parseHealthCheckDuration(tr, "refresh.health-check.grace-period", 0)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseHealthCheckDuration(tr, "refresh.health-check.timeout", defaultHealthCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &HealthRollback{
+		GracePeriod: gracePeriod,
+		Timeout:     timeout,
+	}, nil
+}
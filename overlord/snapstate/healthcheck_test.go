@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) TestUpdateTasksHealthRollback(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/edge",
+		Sequence:        snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}}),
+		Current:         snap.R(7),
+		SnapType:        "app",
+	})
+
+	for _, tc := range []struct {
+		conf     map[string]any
+		rollback *snapstate.HealthRollback
+	}{
+		{conf: map[string]any{}},
+		{conf: map[string]any{"rollback": false}},
+		{conf: map[string]any{"rollback-snaps": "other-snap"}},
+		{
+			conf:     map[string]any{"rollback": true},
+			rollback: &snapstate.HealthRollback{Timeout: 5 * time.Minute},
+		},
+		{
+			conf:     map[string]any{"rollback": "true", "grace-period": "30s", "timeout": "1m"},
+			rollback: &snapstate.HealthRollback{GracePeriod: 30 * time.Second, Timeout: time.Minute},
+		},
+		{
+			conf:     map[string]any{"rollback-snaps": "other-snap,some-snap", "grace-period": "2m"},
+			rollback: &snapstate.HealthRollback{GracePeriod: 2 * time.Minute, Timeout: 5 * time.Minute},
+		},
+	} {
+		tr := config.NewTransaction(s.state)
+		for _, key := range []string{"rollback", "rollback-snaps", "grace-period", "timeout"} {
+			tr.Set("core", "refresh.health-check."+key, tc.conf[key])
+		}
+		tr.Commit()
+
+		ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+		c.Assert(err, IsNil)
+		healthCheck := ts.MaybeEdge(snapstate.EndEdge)
+		c.Assert(healthCheck, NotNil)
+
+		var rollback snapstate.HealthRollback
+		err = healthCheck.Get("health-rollback", &rollback)
+		if tc.rollback == nil {
+			c.Check(err, testutil.ErrorIs, state.ErrNoState, Commentf("%v", tc.conf))
+		} else {
+			c.Check(err, IsNil)
+			c.Check(&rollback, DeepEquals, tc.rollback, Commentf("%v", tc.conf))
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallTasksNoHealthRollback(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.health-check.rollback", true)
+	tr.Commit()
+
+	// installs cannot be reverted to a previous revision
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	healthCheck := ts.MaybeEdge(snapstate.EndEdge)
+	c.Assert(healthCheck, NotNil)
+	var rollback snapstate.HealthRollback
+	c.Check(healthCheck.Get("health-rollback", &rollback), testutil.ErrorIs, state.ErrNoState)
+}
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ErrOutsideMaintenanceWindow is returned when an operation requiring a
+// reboot is started outside of the maintenance windows set with
+// maintenance.windows.
+var ErrOutsideMaintenanceWindow = errors.New("operations requiring a reboot are deferred until the next maintenance window")
+
+// refreshRequiresReboot returns whether refreshing the given snap requires
+// a reboot of the device.
+func refreshRequiresReboot(snapName string, snapType snap.Type, deviceCtx DeviceContext) bool {
+	if !boot.SnapTypeParticipatesInBoot(snapType, deviceCtx) {
+		return false
+	}
+	switch snapType {
+	case snap.TypeKernel:
+		return snapName == deviceCtx.Kernel()
+	case snap.TypeGadget:
+		return snapName == deviceCtx.Gadget()
+	case snap.TypeBase, snap.TypeOS:
+		base := deviceCtx.Base()
+		if base == "" {
+			base = "core"
+		}
+		return snapName == base
+	}
+	return false
+}
+
+// CheckMaintenanceWindow checks whether an operation requiring a reboot may
+// start now. Such operations are only started inside the maintenance
+// windows set with maintenance.windows, otherwise an error wrapping
+// ErrOutsideMaintenanceWindow is returned.
+func CheckMaintenanceWindow(st *state.State) error {
+	open, next, err := restart.InMaintenanceWindow(st, timeNow())
+	if err != nil || open {
+		return err
+	}
+	if next.IsZero() {
+		return ErrOutsideMaintenanceWindow
+	}
+	return fmt.Errorf("%w at %s", ErrOutsideMaintenanceWindow, next.Format(time.RFC3339))
+}
+
+// checkMaintenanceWindow checks whether a refresh of the given snap may
+// start now, see CheckMaintenanceWindow.
+func checkMaintenanceWindow(st *state.State, snapst *SnapState, snapType snap.Type, opts Options) error {
+	deviceCtx, err := DeviceCtx(st, nil, opts.DeviceCtx)
+	if err != nil {
+		return err
+	}
+	if deviceCtx.ForRemodeling() {
+		// remodels are checked as a whole when they are started
+		return nil
+	}
+	if !refreshRequiresReboot(snapst.InstanceName(), snapType, deviceCtx) {
+		return nil
+	}
+	return CheckMaintenanceWindow(st)
+}
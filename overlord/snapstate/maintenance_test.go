@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestAutoRefreshRebootingSnapsDeferredToMaintenanceWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, sn := range []struct {
+		name string
+		typ  snap.Type
+	}{
+		{"some-snap", snap.TypeApp},
+		{"kernel", snap.TypeKernel},
+	} {
+		snapstate.Set(s.state, sn.name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: sn.name, SnapID: sn.name + "-id", Revision: snap.R(1)},
+			}),
+			Current:  snap.R(1),
+			SnapType: string(sn.typ),
+		})
+	}
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "maintenance.windows", "02:00-04:00")
+	tr.Commit()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	// outside of the window the kernel is held back
+	names, _, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	// manual refreshes of all snaps hold it back as well
+	names, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, nil)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	// explicit refreshes of it fail
+	_, _, err = snapstate.UpdateMany(context.Background(), s.state, []string{"kernel"}, nil, s.user.ID, nil)
+	c.Assert(err, ErrorMatches, `cannot refresh snap "kernel": operations requiring a reboot are deferred until the next maintenance window at 2026-03-02T02:00:00.*`)
+	c.Check(errors.Is(err, snapstate.ErrOutsideMaintenanceWindow), Equals, true)
+	_, err = snapstate.Update(s.state, "kernel", nil, s.user.ID, snapstate.Flags{})
+	c.Check(errors.Is(err, snapstate.ErrOutsideMaintenanceWindow), Equals, true)
+
+	now = time.Date(2026, 3, 2, 2, 30, 0, 0, time.Local)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"kernel", "some-snap"})
+}
@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// RefreshPlan describes what a refresh would do. It is built from the task
+// sets of the refresh without running them.
+type RefreshPlan struct {
+	Snaps []*RefreshPlanSnap
+	// DownloadSize is the total size of the snaps and components that
+	// need to be downloaded.
+	DownloadSize int64
+	// RebootRequired is set if any of the refreshed snaps requires a
+	// reboot of the device.
+	RebootRequired bool
+}
+
+// RefreshPlanSnap describes the refresh of a single snap.
+type RefreshPlanSnap struct {
+	InstanceName    string
+	Type            snap.Type
+	Channel         string
+	CurrentRevision snap.Revision
+	Revision        snap.Revision
+	DownloadSize    int64
+	Components      []RefreshPlanComponent
+	// Prerequisites are the base and default content providers of the
+	// snap, missing ones are installed as part of the refresh.
+	Prerequisites []RefreshPlanPrerequisite
+	// AutoConnect is set if the interfaces of the new revision will be
+	// auto-connected.
+	AutoConnect    bool
+	RebootRequired bool
+	// Tasks are the summaries of the tasks of the refresh, in order.
+	Tasks []string
+}
+
+// RefreshPlanComponent describes the refresh of a component of a snap.
+type RefreshPlanComponent struct {
+	Name            string
+	CurrentRevision snap.Revision
+	Revision        snap.Revision
+	DownloadSize    int64
+}
+
+// RefreshPlanPrerequisite is a snap needed by a refreshed snap.
+type RefreshPlanPrerequisite struct {
+	Name      string
+	Installed bool
+	// Content lists the content tags the prerequisite provides to the
+	// snap, they are auto-connected.
+	Content []string
+}
+
+// NewRefreshPlan builds the plan of a refresh from its task sets. The task
+// sets are only inspected, they are expected to be discarded afterwards.
+func NewRefreshPlan(st *state.State, tss []*state.TaskSet, deviceCtx DeviceContext) (*RefreshPlan, error) {
+	deviceCtx, err := DeviceCtx(st, nil, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RefreshPlan{}
+	byName := make(map[string]*RefreshPlanSnap)
+	for _, ts := range tss {
+		var ps *RefreshPlanSnap
+		for _, t := range ts.Tasks() {
+			if ps == nil && t.Has("snap-setup") {
+				snapsup, err := TaskSnapSetup(t)
+				if err != nil {
+					return nil, err
+				}
+				ps = byName[snapsup.InstanceName()]
+				if ps == nil {
+					ps, err = newRefreshPlanSnap(st, snapsup, deviceCtx)
+					if err != nil {
+						return nil, err
+					}
+					byName[ps.InstanceName] = ps
+					plan.Snaps = append(plan.Snaps, ps)
+				}
+			}
+			if ps == nil {
+				// tasks not related to a snap, e.g. pruning of
+				// auto-aliases
+				continue
+			}
+			ps.Tasks = append(ps.Tasks, t.Summary())
+			if t.Kind() == "auto-connect" {
+				ps.AutoConnect = true
+			}
+			if t.Has("component-setup") {
+				if err := ps.addComponent(st, t); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, ps := range plan.Snaps {
+		plan.DownloadSize += ps.DownloadSize
+		for _, comp := range ps.Components {
+			plan.DownloadSize += comp.DownloadSize
+		}
+		if ps.RebootRequired {
+			plan.RebootRequired = true
+		}
+	}
+	return plan, nil
+}
+
+func newRefreshPlanSnap(st *state.State, snapsup *SnapSetup, deviceCtx DeviceContext) (*RefreshPlanSnap, error) {
+	var snapst SnapState
+	if err := Get(st, snapsup.InstanceName(), &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	ps := &RefreshPlanSnap{
+		InstanceName:    snapsup.InstanceName(),
+		Type:            snapsup.Type,
+		Channel:         snapsup.Channel,
+		CurrentRevision: snapst.Current,
+		Revision:        snapsup.Revision(),
+		RebootRequired:  refreshRequiresReboot(snapsup.InstanceName(), snapsup.Type, deviceCtx),
+	}
+	if snapsup.DownloadInfo != nil && snapsup.SnapPath == "" {
+		ps.DownloadSize = snapsup.DownloadInfo.Size
+	}
+
+	prereqs, err := refreshPlanPrerequisites(st, snapsup)
+	if err != nil {
+		return nil, err
+	}
+	ps.Prerequisites = prereqs
+	return ps, nil
+}
+
+func refreshPlanPrerequisites(st *state.State, snapsup *SnapSetup) ([]RefreshPlanPrerequisite, error) {
+	// the same snaps doPrerequisites considers
+	switch snapsup.Type {
+	case snap.TypeOS, snap.TypeBase, snap.TypeKernel, snap.TypeGadget, snap.TypeSnapd:
+		return nil, nil
+	}
+
+	content := make(map[string][]string, len(snapsup.PrereqContentAttrs)+1)
+	base := defaultCoreSnapName
+	if snapsup.Base != "" {
+		base = snapsup.Base
+	}
+	if base != "none" {
+		content[base] = nil
+	}
+	for name, attrs := range snapsup.PrereqContentAttrs {
+		content[name] = append(content[name], attrs...)
+	}
+	for _, name := range snapsup.Prereq {
+		if _, ok := content[name]; !ok {
+			content[name] = nil
+		}
+	}
+
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prereqs := make([]RefreshPlanPrerequisite, 0, len(names))
+	for _, name := range names {
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, err
+		}
+		prereqs = append(prereqs, RefreshPlanPrerequisite{
+			Name:      name,
+			Installed: snapst.IsInstalled(),
+			Content:   content[name],
+		})
+	}
+	return prereqs, nil
+}
+
+func (ps *RefreshPlanSnap) addComponent(st *state.State, t *state.Task) error {
+	var compsup ComponentSetup
+	if err := t.Get("component-setup", &compsup); err != nil {
+		return err
+	}
+	name := compsup.ComponentName()
+	for _, comp := range ps.Components {
+		if comp.Name == name {
+			return nil
+		}
+	}
+
+	var snapst SnapState
+	if err := Get(st, ps.InstanceName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	comp := RefreshPlanComponent{
+		Name:     name,
+		Revision: compsup.Revision(),
+	}
+	if snapst.IsInstalled() {
+		if csi := snapst.CurrentComponentSideInfo(compsup.CompSideInfo.Component); csi != nil {
+			comp.CurrentRevision = csi.Revision
+		}
+	}
+	if compsup.DownloadInfo != nil && compsup.CompPath == "" {
+		comp.DownloadSize = compsup.DownloadInfo.Size
+	}
+	ps.Components = append(ps.Components, comp)
+	return nil
+}
@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestNewRefreshPlan(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, sn := range []struct {
+		name string
+		typ  snap.Type
+	}{
+		{"some-snap", snap.TypeApp},
+		{"kernel", snap.TypeKernel},
+	} {
+		snapstate.Set(s.state, sn.name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: sn.name, SnapID: sn.name + "-id", Revision: snap.R(1)},
+			}),
+			Current:  snap.R(1),
+			SnapType: string(sn.typ),
+		})
+	}
+
+	_, tss, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, 0, nil)
+	c.Assert(err, IsNil)
+	taskCount := s.state.TaskCount()
+
+	plan, err := snapstate.NewRefreshPlan(s.state, tss, nil)
+	c.Assert(err, IsNil)
+	// the plan does not touch the tasks
+	c.Check(s.state.TaskCount(), Equals, taskCount)
+
+	c.Assert(plan.Snaps, HasLen, 2)
+	c.Check(plan.RebootRequired, Equals, true)
+
+	kernel := plan.Snaps[0]
+	c.Check(kernel.InstanceName, Equals, "kernel")
+	c.Check(kernel.Type, Equals, snap.TypeKernel)
+	c.Check(kernel.CurrentRevision, Equals, snap.R(1))
+	c.Check(kernel.Revision, Equals, snap.R(11))
+	c.Check(kernel.RebootRequired, Equals, true)
+	c.Check(kernel.Prerequisites, HasLen, 0)
+	c.Check(kernel.AutoConnect, Equals, true)
+
+	someSnap := plan.Snaps[1]
+	c.Check(someSnap.InstanceName, Equals, "some-snap")
+	c.Check(someSnap.CurrentRevision, Equals, snap.R(1))
+	c.Check(someSnap.Revision, Equals, snap.R(11))
+	c.Check(someSnap.RebootRequired, Equals, false)
+	c.Check(someSnap.Prerequisites, DeepEquals, []snapstate.RefreshPlanPrerequisite{
+		{Name: "core", Installed: true},
+	})
+	c.Check(someSnap.AutoConnect, Equals, true)
+	c.Check(someSnap.Tasks[0], Equals, `Ensure prerequisites for "some-snap" are available`)
+	c.Check(someSnap.Tasks, HasLen, len(tss[1].Tasks()))
+
+	c.Check(plan.DownloadSize, Equals, kernel.DownloadSize+someSnap.DownloadSize)
+
+	// tasks can then be discarded
+	for _, ts := range tss {
+		s.state.DiscardTasks(ts.Tasks())
+	}
+	c.Check(s.state.TaskCount(), Equals, 0)
+}
@@ -0,0 +1,292 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// hooks setup by devicestate and healthstate
+var (
+	// DeviceSerial returns the serial of the device, or an error wrapping
+	// state.ErrNoState if it has none yet.
+	DeviceSerial func(st *state.State) (string, error)
+	// UnhealthySnaps returns the snaps which reported an error from their
+	// latest health check.
+	UnhealthySnaps func(st *state.State) ([]string, error)
+)
+
+// rolloutBuckets is the number of buckets devices are spread over by the
+// refresh rollout policy.
+const rolloutBuckets = 100
+
+var (
+	errRolloutDelayed = errors.New("revision is still soaking for the bucket of the device")
+	errRolloutHeld    = errors.New("auto-refreshes are held because of unhealthy snaps")
+)
+
+// rolloutRevision records when a revision of a snap was first seen as an
+// auto-refresh candidate.
+type rolloutRevision struct {
+	Revision  snap.Revision `json:"revision"`
+	FirstSeen time.Time     `json:"first-seen"`
+}
+
+// rolloutPolicy is the refresh rollout policy configured through the
+// refresh.rollout.* core options.
+type rolloutPolicy struct {
+	soak            time.Duration
+	holdOnUnhealthy bool
+}
+
+func (p *rolloutPolicy) enabled() bool {
+	return p.soak > 0 || p.holdOnUnhealthy
+}
+
+func getRolloutPolicy(st *state.State) (*rolloutPolicy, error) {
+	tr := config.NewTransaction(st)
+	var soak string
+	if err := tr.GetMaybe("core", "refresh.rollout.soak", &soak); err != nil {
+		return nil, err
+	}
+	var policy rolloutPolicy
+	if soak != "" {
+		d, err := time.ParseDuration(soak)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse refresh.rollout.soak: %v", err)
+		}
+		policy.soak = d
+	}
+	// the option can be a boolean or a string, as with other flags
+	var hold any
+	if err := tr.GetMaybe("core", "refresh.rollout.hold-on-unhealthy", &hold); err != nil {
+		return nil, err
+	}
+	policy.holdOnUnhealthy = fmt.Sprint(hold) == "true"
+	return &policy, nil
+}
+
+// rolloutBucket returns the bucket of the device, from 0 to 99, derived from
+// its serial. Devices without a serial yet go last.
+func rolloutBucket(st *state.State) (int, error) {
+	if DeviceSerial == nil {
+		return rolloutBuckets - 1, nil
+	}
+	serial, err := DeviceSerial(st)
+	if errors.Is(err, state.ErrNoState) {
+		return rolloutBuckets - 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	h := sha256.Sum256([]byte(serial))
+	return int(binary.BigEndian.Uint64(h[:8]) % rolloutBuckets), nil
+}
+
+// rolloutDelay returns how long new revisions soak before being
+// auto-refreshed to by devices in the given bucket.
+func rolloutDelay(soak time.Duration, bucket int) time.Duration {
+	return soak * time.Duration(bucket) / rolloutBuckets
+}
+
+func unhealthySnaps(st *state.State) ([]string, error) {
+	if UnhealthySnaps == nil {
+		return nil, nil
+	}
+	return UnhealthySnaps(st)
+}
+
+func rolloutRevisions(st *state.State) (map[string]*rolloutRevision, error) {
+	var revs map[string]*rolloutRevision
+	if err := st.Get("refresh-rollout", &revs); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if revs == nil {
+		revs = make(map[string]*rolloutRevision)
+	}
+	return revs, nil
+}
+
+// pruneRolloutRevisions removes the given snaps from the revisions tracked by
+// the refresh rollout policy.
+func pruneRolloutRevisions(st *state.State, snaps ...string) error {
+	revs, err := rolloutRevisions(st)
+	if err != nil {
+		return err
+	}
+	if len(revs) == 0 {
+		return nil
+	}
+	for _, snapName := range snaps {
+		delete(revs, snapName)
+	}
+	if len(revs) == 0 {
+		st.Set("refresh-rollout", nil)
+	} else {
+		st.Set("refresh-rollout", revs)
+	}
+	return nil
+}
+
+// checkRefreshRollout checks whether an auto-refresh of the given snap to
+// the target revision is allowed by the refresh rollout policy.
+//
+// If the refresh is held because snaps are unhealthy, errRolloutHeld is
+// returned, and if the revision has not soaked long enough for the bucket
+// of the device, errRolloutDelayed is returned.
+func checkRefreshRollout(st *state.State, snapst *SnapState, targetRevision snap.Revision, opts Options) error {
+	if !opts.Flags.IsAutoRefresh {
+		return nil
+	}
+	policy, err := getRolloutPolicy(st)
+	if err != nil {
+		return err
+	}
+	if !policy.enabled() {
+		return nil
+	}
+	snapName := snapst.InstanceName()
+
+	if policy.holdOnUnhealthy {
+		unhealthy, err := unhealthySnaps(st)
+		if err != nil {
+			return err
+		}
+		// unhealthy snaps themselves can still be refreshed, a new
+		// revision might fix them
+		if len(unhealthy) > 0 && !strutil.ListContains(unhealthy, snapName) {
+			logger.Noticef("auto-refresh of snap %q held by the rollout policy: unhealthy snaps %s", snapName, strings.Join(unhealthy, ", "))
+			return errRolloutHeld
+		}
+	}
+
+	if policy.soak == 0 {
+		return nil
+	}
+	revs, err := rolloutRevisions(st)
+	if err != nil {
+		return err
+	}
+	now := timeNow()
+	seen := revs[snapName]
+	if seen == nil || seen.Revision != targetRevision {
+		seen = &rolloutRevision{Revision: targetRevision, FirstSeen: now}
+		revs[snapName] = seen
+		st.Set("refresh-rollout", revs)
+	}
+	bucket, err := rolloutBucket(st)
+	if err != nil {
+		return err
+	}
+	due := seen.FirstSeen.Add(rolloutDelay(policy.soak, bucket))
+	if now.Before(due) {
+		logger.Noticef("auto-refresh of snap %q to revision %s delayed by the rollout policy until %s", snapName, targetRevision, due.Format(time.RFC3339))
+		return errRolloutDelayed
+	}
+	return nil
+}
+
+// RolloutPending is a revision of a snap which is soaking before being
+// auto-refreshed to.
+type RolloutPending struct {
+	Snap     string
+	Revision snap.Revision
+	Due      time.Time
+}
+
+// RolloutStatus is the status of the refresh rollout policy.
+type RolloutStatus struct {
+	// Bucket is the bucket of the device, from 0 to 99.
+	Bucket int
+	// Soak is the soak period of new revisions for the last bucket.
+	Soak time.Duration
+	// Delay is how long new revisions soak for the bucket of the device.
+	Delay time.Duration
+	// Held lists the unhealthy snaps holding auto-refreshes, if any.
+	Held []string
+	// Pending lists the revisions still soaking, sorted by snap.
+	Pending []RolloutPending
+}
+
+// RefreshRolloutStatus returns the status of the refresh rollout policy, or
+// nil if none is configured.
+func RefreshRolloutStatus(st *state.State) (*RolloutStatus, error) {
+	policy, err := getRolloutPolicy(st)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.enabled() {
+		return nil, nil
+	}
+	bucket, err := rolloutBucket(st)
+	if err != nil {
+		return nil, err
+	}
+	status := &RolloutStatus{
+		Bucket: bucket,
+		Soak:   policy.soak,
+		Delay:  rolloutDelay(policy.soak, bucket),
+	}
+	if policy.holdOnUnhealthy {
+		if status.Held, err = unhealthySnaps(st); err != nil {
+			return nil, err
+		}
+	}
+	revs, err := rolloutRevisions(st)
+	if err != nil {
+		return nil, err
+	}
+	now := timeNow()
+	for snapName, seen := range revs {
+		var snapst SnapState
+		if err := Get(st, snapName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, err
+		}
+		// skip revisions already refreshed to or of removed snaps
+		if !snapst.IsInstalled() || snapst.Current == seen.Revision {
+			continue
+		}
+		due := seen.FirstSeen.Add(status.Delay)
+		if !now.Before(due) {
+			continue
+		}
+		status.Pending = append(status.Pending, RolloutPending{
+			Snap:     snapName,
+			Revision: seen.Revision,
+			Due:      due,
+		})
+	}
+	sort.Slice(status.Pending, func(i, j int) bool {
+		return status.Pending[i].Snap < status.Pending[j].Snap
+	})
+	return status, nil
+}
@@ -0,0 +1,225 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) setupRollout(c *C, soak string, holdOnUnhealthy bool) {
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			}),
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.rollout.soak", soak)
+	tr.Set("core", "refresh.rollout.hold-on-unhealthy", holdOnUnhealthy)
+	tr.Commit()
+
+	// "serial-1" is in bucket 79
+	snapstate.DeviceSerial = func(st *state.State) (string, error) {
+		return "serial-1", nil
+	}
+	s.AddCleanup(func() { snapstate.DeviceSerial = nil })
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutBucket(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.DeviceSerial = func(st *state.State) (string, error) {
+		return "serial-2", nil
+	}
+	defer func() { snapstate.DeviceSerial = nil }()
+	bucket, err := snapstate.RolloutBucket(s.state)
+	c.Assert(err, IsNil)
+	c.Check(bucket, Equals, 74)
+
+	// devices without a serial go last
+	snapstate.DeviceSerial = func(st *state.State) (string, error) {
+		return "", fmt.Errorf("no serial yet: %w", state.ErrNoState)
+	}
+	bucket, err = snapstate.RolloutBucket(s.state)
+	c.Assert(err, IsNil)
+	c.Check(bucket, Equals, 99)
+
+	snapstate.DeviceSerial = func(st *state.State) (string, error) {
+		return "", fmt.Errorf("boom")
+	}
+	_, err = snapstate.RolloutBucket(s.state)
+	c.Check(err, ErrorMatches, "boom")
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutSoak(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRollout(c, "100h", false)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	names, _, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, HasLen, 0)
+
+	status, err := snapstate.RefreshRolloutStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, &snapstate.RolloutStatus{
+		Bucket: 79,
+		Soak:   100 * time.Hour,
+		Delay:  79 * time.Hour,
+		Pending: []snapstate.RolloutPending{
+			{Snap: "some-other-snap", Revision: snap.R(11), Due: now.Add(79 * time.Hour)},
+			{Snap: "some-snap", Revision: snap.R(11), Due: now.Add(79 * time.Hour)},
+		},
+	})
+
+	// still soaking, when first seen is kept
+	start := now
+	now = start.Add(78 * time.Hour)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, HasLen, 0)
+
+	// a new revision soaks again from when it is first seen
+	s.fakeStore.refreshRevnos["some-snap-id"] = snap.R(12)
+	now = start.Add(80 * time.Hour)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap"})
+
+	status, err = snapstate.RefreshRolloutStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(status.Pending, DeepEquals, []snapstate.RolloutPending{
+		{Snap: "some-snap", Revision: snap.R(12), Due: now.Add(79 * time.Hour)},
+	})
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutIgnoresManualRefreshes(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRollout(c, "100h", true)
+	snapstate.UnhealthySnaps = func(st *state.State) ([]string, error) {
+		return []string{"foo"}, nil
+	}
+	defer func() { snapstate.UnhealthySnaps = nil }()
+
+	names, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap", "some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutHoldOnUnhealthy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRollout(c, "", true)
+	unhealthy := []string{"some-snap"}
+	snapstate.UnhealthySnaps = func(st *state.State) ([]string, error) {
+		return unhealthy, nil
+	}
+	defer func() { snapstate.UnhealthySnaps = nil }()
+
+	// only the unhealthy snap itself can be refreshed
+	names, _, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	status, err := snapstate.RefreshRolloutStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, &snapstate.RolloutStatus{
+		Bucket: 79,
+		Held:   []string{"some-snap"},
+	})
+
+	unhealthy = nil
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap", "some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutStatusDisabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	status, err := snapstate.RefreshRolloutStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(status, IsNil)
+
+	s.setupRollout(c, "0s", false)
+	status, err = snapstate.RefreshRolloutStatus(s.state)
+	c.Assert(err, IsNil)
+	c.Check(status, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutPrunedOnRemove(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRollout(c, "100h", false)
+
+	names, _, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, HasLen, 0)
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settle(c)
+	c.Assert(chg.Err(), IsNil)
+
+	var revs map[string]any
+	c.Assert(s.state.Get("refresh-rollout", &revs), IsNil)
+	c.Check(revs, HasLen, 1)
+	c.Check(revs["some-other-snap"], NotNil)
+
+	chg = s.state.NewChange("remove", "remove a snap")
+	ts, err = snapstate.Remove(s.state, "some-other-snap", snap.R(0), nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settle(c)
+	c.Assert(chg.Err(), IsNil)
+
+	c.Check(s.state.Get("refresh-rollout", &revs), testutil.ErrorIs, state.ErrNoState)
+}
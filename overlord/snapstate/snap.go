@@ -432,6 +432,16 @@ func (sc *snapInstallChoreographer) AfterLinkSnapAndPostReboot(st *state.State,
 	}
 
 	healthCheck := CheckHealthHook(st, sc.snapsup.InstanceName(), sc.snapsup.Revision())
+	// only refreshes can be reverted to a previous revision
+	if sc.snapst.IsInstalled() && !sc.snapsup.Flags.Revert {
+		rollback, err := healthRollback(st, sc.snapsup.InstanceName())
+		if err != nil {
+			return nil, err
+		}
+		if rollback != nil {
+			healthCheck.Set("health-rollback", rollback)
+		}
+	}
 	s.Append(healthCheck)
 	s.UpdateEdge(healthCheck, EndEdge)
 
@@ -1624,6 +1624,26 @@ func doUpdate(st *state.State, requested []string, updates []update, opts Option
 			return nil, false, nil, err
 		}
 
+		if err := checkRefreshRollout(st, &up.SnapState, up.Setup.Revision(), opts); err != nil {
+			if errors.Is(err, errRolloutDelayed) || errors.Is(err, errRolloutHeld) {
+				// held back by the refresh rollout policy
+				continue
+			}
+			return nil, false, nil, err
+		}
+
+		if err := checkMaintenanceWindow(st, &up.SnapState, up.Setup.Type, opts); err != nil {
+			if !errors.Is(err, ErrOutsideMaintenanceWindow) {
+				return nil, false, nil, err
+			}
+			if opts.Flags.IsAutoRefresh || refreshAll {
+				// requires a reboot, held back until a maintenance window
+				logger.Noticef("refresh of snap %q held back: %v", up.Setup.InstanceName(), err)
+				continue
+			}
+			return nil, false, nil, fmt.Errorf("cannot refresh snap %q: %w", up.Setup.InstanceName(), err)
+		}
+
 		// keep track of any snaps that we requested to refresh actually got
 		// their revisions changed. if any did, pass that up to the caller so
 		// that they may set up a re-refresh if applicable
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 )
 
 // Status is used for status values for changes and tasks.
@@ -268,7 +269,7 @@ func (c *Change) Summary() string {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value any) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.data.set(key, value)
 }
 
@@ -445,8 +446,14 @@ func shouldSkipChangeUpdateNotice(old, new Status) bool {
 	return (old == new) || (old == DoingStatus && new == DoStatus) || (old == UndoingStatus && new == UndoStatus)
 }
 
+var changesCompleted = metrics.NewCounterVec("snapd_changes_completed",
+	"Number of changes which became ready, by kind and status.", "kind", "status")
+
 func (c *Change) notifyStatusChange(new Status) {
 	if c.lastObservedStatus != new {
+		if metrics.Enabled() && new.Ready() && !c.lastObservedStatus.Ready() {
+			changesCompleted.Inc(c.kind, new.String())
+		}
 		c.state.notifyChangeStatusChangedHandlers(c, c.lastObservedStatus, new)
 		c.lastObservedStatus = new
 	}
@@ -456,12 +463,13 @@ func (c *Change) notifyStatusChange(new Status) {
 			logger.Panicf(`internal error: failed to add "change-update" notice on status change: %v`, err)
 		}
 		c.lastRecordedNoticeStatus = new
+		c.state.changeModified(c.id)
 	}
 }
 
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.status = s
 	if s.Ready() {
 		c.markReady()
@@ -477,6 +485,7 @@ func (c *Change) markReady() {
 	}
 	if c.readyTime.IsZero() {
 		c.readyTime = timeNow()
+		c.state.changeModified(c.id)
 	}
 }
 
@@ -552,6 +561,7 @@ func (c *Change) taskCleanChanged() {
 		}
 	}
 	c.clean = true
+	c.state.changeModified(c.id)
 }
 
 // SpawnTime returns the time when the change was created.
@@ -626,18 +636,19 @@ func (c *Change) State() *State {
 // AddTask registers a task as required for the state change to
 // be accomplished.
 func (c *Change) AddTask(t *Task) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	if t.change != "" {
 		panic(fmt.Sprintf("internal error: cannot add one %q task to multiple changes", t.Kind()))
 	}
 	t.change = c.id
 	c.taskIDs = addOnce(c.taskIDs, t.ID())
+	c.state.taskModified(t.id)
 }
 
 // AddAll registers all tasks in the set as required for the state
 // change to be accomplished.
 func (c *Change) AddAll(ts *TaskSet) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	for _, t := range ts.tasks {
 		c.AddTask(t)
 	}
@@ -676,7 +687,7 @@ func (c *Change) LaneTasks(lanes ...int) []*Task {
 // Abort flags the change for cancellation, whether in progress or not.
 // Cancellation will proceed at the next ensure pass.
 func (c *Change) Abort() {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	tasks := make([]*Task, len(c.taskIDs))
 	for i, tid := range c.taskIDs {
 		tasks[i] = c.state.tasks[tid]
@@ -688,14 +699,14 @@ func (c *Change) Abort() {
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
 func (c *Change) AbortLanes(lanes []int) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.abortLanes(lanes, make(map[int]bool), make(map[string]bool))
 }
 
 // AbortUnreadyLanes aborts the tasks from lanes that aren't fully ready, where
 // a ready lane is one in which all tasks are ready.
 func (c *Change) AbortUnreadyLanes() {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.abortUnreadyLanes()
 }
 
@@ -113,9 +113,22 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 	}
 	defer f.Close()
 
+	journal, err := openJournal(srcStatePath)
+	if err != nil {
+		return err
+	}
+	if journal != nil {
+		defer journal.Close()
+	}
+
 	// No need to lock/unlock the state here, srcState should not be
 	// in use at all.
-	srcState, err := ReadState(nil, f)
+	var srcState *State
+	if journal != nil {
+		srcState, err = ReadStateWithJournal(nil, f, journal)
+	} else {
+		srcState, err = ReadState(nil, f)
+	}
 	if err != nil {
 		return err
 	}
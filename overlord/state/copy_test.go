@@ -111,6 +111,23 @@ func (ss *stateSuite) TestCopyStateIntegration(c *C) {
 	c.Check(string(dstContent), Equals, `{"data":{"auth":{"last-id":1,"users":[{"id":1,"email":"some@user.com","macaroon":"1234","store-macaroon":"5678","store-discharges":["9012345"]}]}}`+stateSuffix)
 }
 
+func (ss *stateSuite) TestCopyStateWithJournal(c *C) {
+	srcStateFile := filepath.Join(c.MkDir(), "src-state.json")
+	err := os.WriteFile(srcStateFile, srcStateContent, 0644)
+	c.Assert(err, IsNil)
+	journal := append(state.JournalHeader(srcStateContent), `{"data":{"auth":{"last-id":2}}}`+"\n"...)
+	err = os.WriteFile(state.JournalPath(srcStateFile), journal, 0644)
+	c.Assert(err, IsNil)
+
+	dstStateFile := filepath.Join(c.MkDir(), "dst-state.json")
+	err = state.CopyState(srcStateFile, dstStateFile, []string{"auth.last-id"})
+	c.Assert(err, IsNil)
+
+	dstContent, err := os.ReadFile(dstStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(dstContent), Equals, `{"data":{"auth":{"last-id":2}}`+stateSuffix)
+}
+
 var srcStateContent1 = []byte(`{
     "data": {
         "A": {"B": [{"C": 1}, {"D": 2}]},
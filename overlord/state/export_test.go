@@ -20,6 +20,7 @@
 package state
 
 import (
+	"sort"
 	"time"
 )
 
@@ -76,3 +77,17 @@ func (s *State) NumNotices() int {
 func (s *State) GetLastNoticeTimestamp() time.Time {
 	return s.getLastNoticeTimestamp()
 }
+
+// ModifiedEntries returns the keys of the data entries and the IDs of the
+// changes and tasks modified since the state was last checkpointed.
+func (s *State) ModifiedEntries() (data, changes, tasks []string) {
+	keys := func(m map[string]bool) []string {
+		res := make([]string, 0, len(m))
+		for k := range m {
+			res = append(res, k)
+		}
+		sort.Strings(res)
+		return res
+	}
+	return keys(s.modifiedEntries.data), keys(s.modifiedEntries.changes), keys(s.modifiedEntries.tasks)
+}
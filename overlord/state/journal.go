@@ -0,0 +1,484 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// A JournalBackend is a Backend which persists the state as a base checkpoint
+// followed by a journal of the deltas applied to it since.
+//
+// The state calls Checkpoint with the whole state the first time it is
+// unlocked after being created or read, and whenever NeedsCompaction returns
+// true. Otherwise it calls AppendDelta with only the entries which changed
+// since the previous checkpoint or delta. A successful Checkpoint must start
+// a new, empty journal on top of the given data, which can be identified
+// with JournalHeader.
+type JournalBackend interface {
+	Backend
+	// AppendDelta persists the given delta at the end of the journal.
+	AppendDelta(delta []byte) error
+	// NeedsCompaction returns whether the journal should be folded into a
+	// new base checkpoint.
+	NeedsCompaction() bool
+}
+
+// JournalPath returns the path of the journal kept next to the given state
+// file by a JournalBackend.
+func JournalPath(stateFile string) string {
+	base := strings.TrimSuffix(filepath.Base(stateFile), filepath.Ext(stateFile))
+	return filepath.Join(filepath.Dir(stateFile), base+".journal")
+}
+
+// journalHeader is the first line of a journal, which identifies the base
+// checkpoint the deltas following it apply to.
+type journalHeader struct {
+	Base string `json:"base"`
+}
+
+func checkpointID(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// JournalHeader returns the header line, including its newline, of a journal
+// of deltas applying to the given base checkpoint.
+func JournalHeader(base []byte) []byte {
+	header, err := json.Marshal(journalHeader{Base: checkpointID(base)})
+	if err != nil {
+		logger.Panicf("internal error: could not marshal journal header: %v", err)
+	}
+	return append(header, '\n')
+}
+
+// stateDelta holds the entries of the state which changed since the
+// previous checkpoint or delta. Removed entries are mapped to nil.
+type stateDelta struct {
+	Data    map[string]*json.RawMessage `json:"data,omitempty"`
+	Changes map[string]*json.RawMessage `json:"changes,omitempty"`
+	Tasks   map[string]*json.RawMessage `json:"tasks,omitempty"`
+	Meta    *json.RawMessage            `json:"meta,omitempty"`
+}
+
+// entryHashes holds the hashes of the serialization of the entries of the
+// state, as persisted by a JournalBackend.
+type entryHashes struct {
+	// hashes maps the entries, as "data/<key>", "change/<id>",
+	// "task/<id>" or "meta", to their hash.
+	hashes map[string][sha256.Size]byte
+}
+
+// stateEntries holds the serialization of each entry of the state.
+type stateEntries struct {
+	data    map[string]*json.RawMessage
+	changes map[string]*json.RawMessage
+	tasks   map[string]*json.RawMessage
+	meta    stateMeta
+	rawMeta json.RawMessage
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal state for checkpointing: %v", err)
+	}
+	return data
+}
+
+func (s *State) entries() *stateEntries {
+	s.reading()
+	e := &stateEntries{
+		data:    make(map[string]*json.RawMessage, len(s.data)),
+		changes: make(map[string]*json.RawMessage, len(s.changes)),
+		tasks:   make(map[string]*json.RawMessage, len(s.tasks)),
+		meta:    s.meta(),
+	}
+	for k, v := range s.data {
+		e.data[k] = v
+	}
+	for id, chg := range s.changes {
+		raw := mustMarshal(chg)
+		e.changes[id] = &raw
+	}
+	for id, t := range s.tasks {
+		raw := mustMarshal(t)
+		e.tasks[id] = &raw
+	}
+	e.rawMeta = mustMarshal(e.meta)
+	return e
+}
+
+func rawHash(raw *json.RawMessage) [sha256.Size]byte {
+	if raw == nil {
+		return sha256.Sum256(nil)
+	}
+	return sha256.Sum256(*raw)
+}
+
+func (e *stateEntries) hashes() *entryHashes {
+	hashes := make(map[string][sha256.Size]byte, len(e.data)+len(e.changes)+len(e.tasks)+1)
+	for k, raw := range e.data {
+		hashes["data/"+k] = rawHash(raw)
+	}
+	for id, raw := range e.changes {
+		hashes["change/"+id] = rawHash(raw)
+	}
+	for id, raw := range e.tasks {
+		hashes["task/"+id] = rawHash(raw)
+	}
+	hashes["meta"] = sha256.Sum256(e.rawMeta)
+	return &entryHashes{hashes: hashes}
+}
+
+// full returns the serialization of the whole state, as done by
+// MarshalJSON, without serializing its changes and tasks again.
+func (e *stateEntries) full() []byte {
+	return mustMarshal(rawState{
+		Data:      e.data,
+		Changes:   e.changes,
+		Tasks:     e.tasks,
+		stateMeta: e.meta,
+	})
+}
+
+// update applies the given updates, mapping entries to their new hash or to
+// nil if they were removed.
+func (h *entryHashes) update(updates map[string]*[sha256.Size]byte) {
+	for key, hash := range updates {
+		if hash == nil {
+			delete(h.hashes, key)
+			continue
+		}
+		h.hashes[key] = *hash
+	}
+}
+
+// deltaBuilder collects the entries of the state which changed since they
+// were last persisted, along with the updates to their hashes.
+type deltaBuilder struct {
+	prev    *entryHashes
+	updates map[string]*[sha256.Size]byte
+}
+
+// changed returns whether the entry with the given key, serialized as raw or
+// nil if it was removed, differs from the one last persisted.
+func (b *deltaBuilder) changed(key string, raw *json.RawMessage) bool {
+	prevHash, ok := b.prev.hashes[key]
+	if raw == nil {
+		if !ok {
+			return false
+		}
+		b.updates[key] = nil
+		return true
+	}
+	hash := rawHash(raw)
+	if ok && hash == prevHash {
+		return false
+	}
+	b.updates[key] = &hash
+	return true
+}
+
+func addDeltaEntry(entries map[string]*json.RawMessage, k string, raw *json.RawMessage) map[string]*json.RawMessage {
+	if entries == nil {
+		entries = make(map[string]*json.RawMessage)
+	}
+	entries[k] = raw
+	return entries
+}
+
+// delta returns the serialization of the entries of the state which changed
+// since the given hashes were persisted, along with the updates to the hashes,
+// or nil if none did. Only the entries which were modified since the state was
+// last checkpointed are serialized.
+func (s *State) delta(prev *entryHashes) ([]byte, map[string]*[sha256.Size]byte) {
+	b := &deltaBuilder{
+		prev:    prev,
+		updates: make(map[string]*[sha256.Size]byte),
+	}
+	var d stateDelta
+	for k := range s.modifiedEntries.data {
+		raw := s.data[k]
+		if b.changed("data/"+k, raw) {
+			d.Data = addDeltaEntry(d.Data, k, raw)
+		}
+	}
+	for id := range s.modifiedEntries.changes {
+		var raw *json.RawMessage
+		if chg, ok := s.changes[id]; ok {
+			data := mustMarshal(chg)
+			raw = &data
+		}
+		if b.changed("change/"+id, raw) {
+			d.Changes = addDeltaEntry(d.Changes, id, raw)
+		}
+	}
+	for id := range s.modifiedEntries.tasks {
+		var raw *json.RawMessage
+		if t, ok := s.tasks[id]; ok {
+			data := mustMarshal(t)
+			raw = &data
+		}
+		if b.changed("task/"+id, raw) {
+			d.Tasks = addDeltaEntry(d.Tasks, id, raw)
+		}
+	}
+	rawMeta := mustMarshal(s.meta())
+	if b.changed("meta", &rawMeta) {
+		d.Meta = &rawMeta
+	}
+	if len(b.updates) == 0 {
+		return nil, nil
+	}
+	return mustMarshal(d), b.updates
+}
+
+// prepareCheckpoint returns a function persisting the current state through
+// the backend, to be retried until it succeeds.
+func (s *State) prepareCheckpoint() func() error {
+	jb, ok := s.backend.(JournalBackend)
+	if !ok {
+		data := s.checkpointData()
+		return func() error {
+			return s.backend.Checkpoint(data)
+		}
+	}
+
+	if s.checkpointed == nil || s.journalStopped || jb.NeedsCompaction() {
+		entries := s.entries()
+		hashes := entries.hashes()
+		data := entries.full()
+		return func() error {
+			if err := jb.Checkpoint(data); err != nil {
+				return err
+			}
+			s.checkpointed = hashes
+			return nil
+		}
+	}
+
+	delta, updates := s.delta(s.checkpointed)
+	if delta == nil {
+		return func() error { return nil }
+	}
+	return func() error {
+		if err := jb.AppendDelta(delta); err != nil {
+			return err
+		}
+		s.checkpointed.update(updates)
+		return nil
+	}
+}
+
+// UsesJournal returns whether the state persists itself through a
+// JournalBackend. The state does not need to be locked.
+func (s *State) UsesJournal() bool {
+	_, ok := s.backend.(JournalBackend)
+	return ok
+}
+
+// StopJournal makes the state persist itself through a JournalBackend only
+// with full checkpoints from now on, starting with one when it is unlocked,
+// so that the state file holds the whole state. It is meant for when snapd
+// stops, as the state might next be read by a snapd which does not know
+// about the journal. It does nothing for other backends.
+// The state must be locked by the caller.
+func (s *State) StopJournal() {
+	if !s.UsesJournal() {
+		return
+	}
+	s.writing()
+	s.journalStopped = true
+}
+
+// rawState is the serialized state with its changes and tasks left
+// undecoded.
+type rawState struct {
+	Data    map[string]*json.RawMessage `json:"data"`
+	Changes map[string]*json.RawMessage `json:"changes"`
+	Tasks   map[string]*json.RawMessage `json:"tasks"`
+	stateMeta
+}
+
+func applyDeltaEntries(entries map[string]*json.RawMessage, delta map[string]*json.RawMessage) map[string]*json.RawMessage {
+	if len(delta) > 0 && entries == nil {
+		entries = make(map[string]*json.RawMessage, len(delta))
+	}
+	for k, raw := range delta {
+		if raw == nil {
+			delete(entries, k)
+			continue
+		}
+		entries[k] = raw
+	}
+	return entries
+}
+
+// applyJournal returns the given base checkpoint with the deltas from the
+// journal applied to it. A journal which does not apply to base is ignored,
+// as is anything following an incomplete or corrupted delta, which is what
+// is left behind if snapd was interrupted while appending to it.
+func applyJournal(base []byte, journal io.Reader) ([]byte, error) {
+	r := bufio.NewReader(journal)
+	line, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot read state journal: %v", err)
+	}
+	var header journalHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.Base != checkpointID(base) {
+		logger.Noticef("ignoring state journal which does not apply to the state")
+		return base, nil
+	}
+
+	var st rawState
+	if err := json.Unmarshal(base, &st); err != nil {
+		return nil, fmt.Errorf("cannot read state: %s", err)
+	}
+	applied := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				logger.Noticef("ignoring incomplete delta at the end of the state journal")
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read state journal: %v", err)
+		}
+		var delta stateDelta
+		if err := json.Unmarshal(line, &delta); err != nil {
+			logger.Noticef("ignoring state journal after %d deltas: %v", applied, err)
+			break
+		}
+		st.Data = applyDeltaEntries(st.Data, delta.Data)
+		st.Changes = applyDeltaEntries(st.Changes, delta.Changes)
+		st.Tasks = applyDeltaEntries(st.Tasks, delta.Tasks)
+		if delta.Meta != nil {
+			var meta stateMeta
+			if err := json.Unmarshal(*delta.Meta, &meta); err != nil {
+				return nil, fmt.Errorf("cannot read state journal: %v", err)
+			}
+			st.stateMeta = meta
+		}
+		applied++
+	}
+	if applied == 0 {
+		return base, nil
+	}
+	return json.Marshal(st)
+}
+
+// ReadStateWithJournal returns the state deserialized from r with the deltas
+// from the given journal of a JournalBackend, if not nil, applied.
+//
+// The first checkpoint of the returned state through a JournalBackend is a
+// full one, so that the journal is compacted.
+func ReadStateWithJournal(backend Backend, r io.Reader, journal io.Reader) (*State, error) {
+	if journal == nil {
+		return ReadState(backend, r)
+	}
+	base, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state: %s", err)
+	}
+	data, err := applyJournal(base, journal)
+	if err != nil {
+		return nil, err
+	}
+	return ReadState(backend, bytes.NewReader(data))
+}
+
+// ReadStateFile returns the state deserialized from the given state file,
+// with the deltas from the journal next to it, if any, applied.
+func ReadStateFile(backend Backend, path string) (*State, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state file: %s", err)
+	}
+	defer r.Close()
+
+	journal, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if journal == nil {
+		return ReadState(backend, r)
+	}
+	defer journal.Close()
+	return ReadStateWithJournal(backend, r, journal)
+}
+
+// FoldJournal applies the deltas from the journal next to the given state
+// file, if any, to it and removes the journal, so that the state file holds
+// the whole state for readers which do not know about the journal.
+func FoldJournal(stateFile string) error {
+	journal, err := openJournal(stateFile)
+	if err != nil || journal == nil {
+		return err
+	}
+	defer journal.Close()
+
+	base, err := os.ReadFile(stateFile)
+	if err != nil {
+		return fmt.Errorf("cannot read the state file: %s", err)
+	}
+	data, err := applyJournal(base, journal)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, base) {
+		if err := osutil.AtomicWriteFile(stateFile, data, 0600, 0); err != nil {
+			return fmt.Errorf("cannot fold the state journal: %v", err)
+		}
+	}
+	if err := os.Remove(JournalPath(stateFile)); err != nil {
+		return fmt.Errorf("cannot fold the state journal: %v", err)
+	}
+	return nil
+}
+
+// openJournal opens the journal next to the given state file, returning nil
+// if there is none.
+func openJournal(stateFile string) (*os.File, error) {
+	f, err := os.Open(JournalPath(stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state journal: %s", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,489 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct{}
+
+var _ = Suite(&journalSuite{})
+
+type fakeJournalBackend struct {
+	base    []byte
+	journal bytes.Buffer
+
+	checkpoints int
+	deltas      [][]byte
+	compact     bool
+	error       func() error
+}
+
+func (b *fakeJournalBackend) Checkpoint(data []byte) error {
+	if b.error != nil {
+		if err := b.error(); err != nil {
+			return err
+		}
+	}
+	b.checkpoints++
+	b.base = data
+	b.journal.Reset()
+	b.journal.Write(state.JournalHeader(data))
+	b.compact = false
+	return nil
+}
+
+func (b *fakeJournalBackend) AppendDelta(delta []byte) error {
+	if b.error != nil {
+		if err := b.error(); err != nil {
+			return err
+		}
+	}
+	b.deltas = append(b.deltas, delta)
+	b.journal.Write(delta)
+	b.journal.WriteByte('\n')
+	return nil
+}
+
+func (b *fakeJournalBackend) NeedsCompaction() bool {
+	return b.compact
+}
+
+func (b *fakeJournalBackend) EnsureBefore(d time.Duration) {}
+
+func (b *fakeJournalBackend) read(c *C) *state.State {
+	st, err := state.ReadStateWithJournal(nil, bytes.NewReader(b.base), bytes.NewReader(b.journal.Bytes()))
+	c.Assert(err, IsNil)
+	return st
+}
+
+func marshalState(c *C, st *state.State) map[string]any {
+	st.Lock()
+	defer st.Unlock()
+	data, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	var m map[string]any
+	c.Assert(json.Unmarshal(data, &m), IsNil)
+	return m
+}
+
+// readBack returns the serialization of the state as read back from its
+// full serialization.
+func readBack(c *C, st *state.State) map[string]any {
+	st.Lock()
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	return marshalState(c, st2)
+}
+
+func (js *journalSuite) TestJournalPath(c *C) {
+	c.Check(state.JournalPath("/var/lib/snapd/state.json"), Equals, "/var/lib/snapd/state.journal")
+	c.Check(state.JournalPath("foo"), Equals, "foo.journal")
+}
+
+func (js *journalSuite) TestCheckpointThenDeltas(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Set("b", "foo")
+	st.Unlock()
+
+	// the first checkpoint is a full one
+	c.Check(b.checkpoints, Equals, 1)
+	c.Check(b.deltas, HasLen, 0)
+
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 1)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(string(b.deltas[0]), Equals, `{"data":{"a":2}}`)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Set("b", nil)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	var delta map[string]map[string]any
+	c.Assert(json.Unmarshal(b.deltas[1], &delta), IsNil)
+	c.Check(delta["data"], DeepEquals, map[string]any{"b": nil})
+	c.Check(delta["changes"], HasLen, 1)
+	c.Check(delta["tasks"], HasLen, 1)
+	c.Check(delta["meta"]["last-change-id"], Equals, 1.0)
+
+	// setting an entry to the same value does not add a delta
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.deltas, HasLen, 2)
+
+	st.Lock()
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 3)
+	delta = nil
+	c.Assert(json.Unmarshal(b.deltas[2], &delta), IsNil)
+	c.Check(delta["data"], IsNil)
+	c.Check(delta["tasks"], HasLen, 1)
+
+	// the state read back from the base and journal is the same
+	st2 := b.read(c)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Check(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 2)
+	c.Check(st2.Get("b", &a), testutil.ErrorIs, state.ErrNoState)
+	c.Assert(st2.Changes(), HasLen, 1)
+	c.Check(st2.Changes()[0].Tasks()[0].Status(), Equals, state.DoneStatus)
+}
+
+func (js *journalSuite) TestModifiedEntries(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	st.Lock()
+	data, changes, tasks := st.ModifiedEntries()
+	c.Check(data, HasLen, 0)
+	c.Check(changes, HasLen, 0)
+	c.Check(tasks, HasLen, 0)
+
+	// only the modified task is tracked
+	t1.Set("foo", "bar")
+	data, changes, tasks = st.ModifiedEntries()
+	c.Check(data, HasLen, 0)
+	c.Check(changes, HasLen, 0)
+	c.Check(tasks, DeepEquals, []string{t1.ID()})
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(string(b.deltas[0]), Matches, `\{"tasks":\{"`+t1.ID()+`":\{.*\}\}\}`)
+
+	// both tasks are modified when one waits for the other
+	st.Lock()
+	t2.WaitFor(t1)
+	_, _, tasks = st.ModifiedEntries()
+	c.Check(tasks, DeepEquals, []string{t1.ID(), t2.ID()})
+	st.Unlock()
+
+	// the change becoming ready is tracked along with its tasks
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.DoneStatus)
+	_, changes, tasks = st.ModifiedEntries()
+	c.Check(changes, DeepEquals, []string{chg.ID()})
+	c.Check(tasks, DeepEquals, []string{t1.ID(), t2.ID()})
+	st.Unlock()
+
+	// and so is pruning it
+	st.Lock()
+	st.Prune(time.Now(), time.Hour, time.Hour, 0)
+	data, changes, tasks = st.ModifiedEntries()
+	c.Check(data, HasLen, 0)
+	c.Check(changes, DeepEquals, []string{chg.ID()})
+	c.Check(tasks, DeepEquals, []string{t1.ID(), t2.ID()})
+	st.Unlock()
+	var delta map[string]map[string]any
+	c.Assert(json.Unmarshal(b.deltas[len(b.deltas)-1], &delta), IsNil)
+	c.Check(delta["changes"], DeepEquals, map[string]any{chg.ID(): nil})
+	c.Check(delta["tasks"], DeepEquals, map[string]any{t1.ID(): nil, t2.ID(): nil})
+
+	// the state read back from the base and journal is the same
+	c.Check(b.checkpoints, Equals, 1)
+	st2 := b.read(c)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+}
+
+func (js *journalSuite) TestCompaction(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 1)
+	c.Check(b.deltas, HasLen, 1)
+
+	b.compact = true
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 2)
+	c.Check(b.deltas, HasLen, 1)
+
+	// the base alone holds the state, as written by MarshalJSON
+	st2, err := state.ReadState(nil, bytes.NewReader(b.base))
+	c.Assert(err, IsNil)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+	c.Check(b.read(c), NotNil)
+
+	// deltas after compaction are relative to the new base
+	st.Lock()
+	st.Set("c", true)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	c.Check(string(b.deltas[1]), Equals, `{"data":{"c":true}}`)
+}
+
+func (js *journalSuite) TestReadStateCompactsOnFirstUnlock(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	b2 := &fakeJournalBackend{}
+	st2, err := state.ReadStateWithJournal(b2, bytes.NewReader(b.base), bytes.NewReader(b.journal.Bytes()))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	st2.Set("b", 1)
+	st2.Unlock()
+	c.Check(b2.checkpoints, Equals, 1)
+	c.Check(b2.deltas, HasLen, 0)
+}
+
+func (js *journalSuite) TestCheckpointRetry(c *C) {
+	restore := state.MockCheckpointRetryDelay(time.Millisecond, time.Second)
+	defer restore()
+
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+
+	failures := 2
+	b.error = func() error {
+		if failures > 0 {
+			failures--
+			return errors.New("boom")
+		}
+		return nil
+	}
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(failures, Equals, 0)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(st.Modified(), Equals, false)
+}
+
+func (js *journalSuite) TestReadStateWithJournalIgnoresTornDelta(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	b.journal.WriteString(`{"data":{"a":3`)
+
+	st2 := b.read(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Check(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 2)
+}
+
+func (js *journalSuite) TestReadStateWithJournalIgnoresCorruptDelta(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	b.journal.WriteString("garbage\n")
+	b.journal.WriteString(`{"data":{"a":3}}` + "\n")
+
+	st2 := b.read(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Check(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 1)
+}
+
+func (js *journalSuite) TestReadStateWithJournalIgnoresOtherBase(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	// the base was rewritten but the journal was not reset
+	other := bytes.Replace(b.base, []byte(`"a":1`), []byte(`"a":5`), 1)
+	st2, err := state.ReadStateWithJournal(nil, bytes.NewReader(other), bytes.NewReader(b.journal.Bytes()))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Check(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 5)
+}
+
+func (js *journalSuite) TestReadStateFile(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "state.json")
+
+	_, err := state.ReadStateFile(nil, path)
+	c.Check(err, ErrorMatches, "cannot read the state file: open .*: no such file or directory")
+
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	c.Assert(os.WriteFile(path, b.base, 0600), IsNil)
+
+	// without a journal
+	st2, err := state.ReadStateFile(nil, path)
+	c.Assert(err, IsNil)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Assert(os.WriteFile(state.JournalPath(path), b.journal.Bytes(), 0600), IsNil)
+
+	st2, err = state.ReadStateFile(nil, path)
+	c.Assert(err, IsNil)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+}
+
+func (js *journalSuite) TestStopJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 1)
+	c.Check(b.deltas, HasLen, 1)
+
+	// stopping the journal checkpoints the whole state right away
+	st.Lock()
+	st.StopJournal()
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 2)
+	st2, err := state.ReadState(nil, bytes.NewReader(b.base))
+	c.Assert(err, IsNil)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+
+	// and from then on
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Check(b.checkpoints, Equals, 3)
+	c.Check(b.deltas, HasLen, 1)
+}
+
+func (js *journalSuite) TestUsesJournal(c *C) {
+	c.Check(state.New(&fakeJournalBackend{}).UsesJournal(), Equals, true)
+	c.Check(state.New(&fakeStateBackend{}).UsesJournal(), Equals, false)
+	c.Check(state.New(nil).UsesJournal(), Equals, false)
+}
+
+func (js *journalSuite) TestStopJournalOtherBackend(c *C) {
+	b := &fakeStateBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+
+	// nothing to fold, nothing is checkpointed
+	st.Lock()
+	st.StopJournal()
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+}
+
+func (js *journalSuite) TestFoldJournal(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "state.json")
+	journalPath := state.JournalPath(path)
+
+	// nothing to do without a journal
+	c.Check(state.FoldJournal(path), IsNil)
+
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Assert(os.WriteFile(path, b.base, 0600), IsNil)
+	c.Assert(os.WriteFile(journalPath, b.journal.Bytes(), 0600), IsNil)
+
+	c.Assert(state.FoldJournal(path), IsNil)
+	c.Check(journalPath, testutil.FileAbsent)
+	// the state file alone holds the state
+	data, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Check(marshalState(c, st2), DeepEquals, readBack(c, st))
+}
+
+func (js *journalSuite) TestFoldJournalNoStateFile(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "state.json")
+	c.Assert(os.WriteFile(state.JournalPath(path), nil, 0600), IsNil)
+
+	c.Check(state.FoldJournal(path), ErrorMatches, "cannot read the state file: open .*: no such file or directory")
+}
@@ -307,11 +307,25 @@ const (
 	// expired. The key for interfaces-requests-rule-update notices is the
 	// rule ID.
 	InterfacesRequestsRuleUpdateNotice NoticeType = "interfaces-requests-rule-update"
+
+	// Recorded whenever a scheduled snapshot run completes. The key for
+	// snapshot-schedule notices is the ID of the snapshot set taken.
+	SnapshotScheduleNotice NoticeType = "snapshot-schedule"
+
+	// Recorded whenever a refresh is reverted because the snap did not
+	// become healthy after it. The key for snap-health-rollback notices is
+	// the instance name of the snap.
+	SnapHealthRollbackNotice NoticeType = "snap-health-rollback"
+
+	// Recorded whenever a system restart required by a change is deferred
+	// until the next maintenance window. The key for pending-reboot
+	// notices is always "-".
+	PendingRebootNotice NoticeType = "pending-reboot"
 )
 
 func (t NoticeType) Valid() bool {
 	switch t {
-	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice:
+	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice, SnapshotScheduleNotice, SnapHealthRollbackNotice, PendingRebootNotice:
 		return true
 	}
 	return false
@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 )
 
@@ -129,9 +130,24 @@ type State struct {
 	// task/changes observing
 	taskHandlers   map[int]func(t *Task, old, new Status) (remove bool)
 	changeHandlers map[int]func(chg *Change, old, new Status)
+	pruneHandlers  map[int]func(chg *Change)
+
+	// checkpointed holds the hashes of the entries of the state as last
+	// persisted by a JournalBackend, or is nil if the next checkpoint must
+	// persist the whole state.
+	checkpointed *entryHashes
+	// modifiedEntries holds the entries which were added, modified or removed
+	// since the state was last checkpointed.
+	modifiedEntries modifiedEntries
+	// journalStopped is set once every checkpoint through a
+	// JournalBackend must persist the whole state.
+	journalStopped bool
 
 	lockWaitStart int64
 	lockHoldStart int64
+	// lockAcquired is when the state lock was last acquired, if metrics
+	// were being collected then.
+	lockAcquired time.Time
 }
 
 // New returns a new empty state.
@@ -148,6 +164,7 @@ func New(backend Backend) *State {
 		pendingChangeByAttr: make(map[string]func(*Change) bool),
 		taskHandlers:        make(map[int]func(t *Task, old Status, new Status) bool),
 		changeHandlers:      make(map[int]func(chg *Change, old Status, new Status)),
+		pruneHandlers:       make(map[int]func(chg *Change)),
 	}
 	// The noticeCond.L must be the same as the lock which is held during
 	// WaitNotices, since noticeCond.Wait() will unlock noticeCond.L.
@@ -160,13 +177,31 @@ func (s *State) Modified() bool {
 	return s.modified
 }
 
+var (
+	lockWaitDuration = metrics.NewHistogramVec("snapd_state_lock_wait_seconds",
+		"Time spent waiting to acquire the state lock.", metrics.DefaultDurationBuckets)
+	lockHoldDuration = metrics.NewHistogramVec("snapd_state_lock_hold_seconds",
+		"Time the state lock was held for, including checkpointing the state.", metrics.DefaultDurationBuckets)
+)
+
 // Lock acquires the state lock.
 func (s *State) Lock() {
 	lockWait := lockTimestamp()
+	collectMetrics := metrics.Enabled()
+	var waitStart time.Time
+	if collectMetrics {
+		waitStart = time.Now()
+	}
 	s.mu.Lock()
 	atomic.AddInt32(&s.muC, 1)
 	s.lockWaitStart = lockWait
 	s.lockHoldStart = lockTimestamp()
+	if collectMetrics {
+		s.lockAcquired = time.Now()
+		lockWaitDuration.Observe(s.lockAcquired.Sub(waitStart).Seconds())
+	} else {
+		s.lockAcquired = time.Time{}
+	}
 }
 
 func (s *State) reading() {
@@ -182,21 +217,72 @@ func (s *State) writing() {
 	}
 }
 
+// writingChange is like writing and also records that the change with the
+// given ID was modified.
+func (s *State) writingChange(id string) {
+	s.writing()
+	s.changeModified(id)
+}
+
+// writingTask is like writing and also records that the task with the given
+// ID was modified.
+func (s *State) writingTask(id string) {
+	s.writing()
+	s.taskModified(id)
+}
+
+// modifiedEntries holds the keys of data entries and the IDs of changes and
+// tasks which were modified.
+type modifiedEntries struct {
+	data    map[string]bool
+	changes map[string]bool
+	tasks   map[string]bool
+}
+
+func markModified(modified *map[string]bool, key string) {
+	if *modified == nil {
+		*modified = make(map[string]bool)
+	}
+	(*modified)[key] = true
+}
+
+func (s *State) dataModified(key string) {
+	markModified(&s.modifiedEntries.data, key)
+}
+
+func (s *State) changeModified(id string) {
+	markModified(&s.modifiedEntries.changes, id)
+}
+
+func (s *State) taskModified(id string) {
+	markModified(&s.modifiedEntries.tasks, id)
+}
+
+// checkpointDone records that the state was persisted as it is now.
+func (s *State) checkpointDone() {
+	s.modified = false
+	s.modifiedEntries = modifiedEntries{}
+}
+
 func (s *State) unlock() {
 	atomic.AddInt32(&s.muC, -1)
 	lockWaitStart, lockHoldStart := s.lockWaitStart, s.lockHoldStart
 	s.lockWaitStart, s.lockHoldStart = 0, 0
 	lockHoldEnd := lockTimestamp()
+	// metrics could have been enabled while the lock was held
+	lockAcquired := s.lockAcquired
 	s.mu.Unlock()
+	if !lockAcquired.IsZero() {
+		lockHoldDuration.Observe(time.Since(lockAcquired).Seconds())
+	}
 	maybeSaveLockTime(lockWaitStart, lockHoldStart, lockHoldEnd)
 }
 
-type marshalledState struct {
-	Data     map[string]*json.RawMessage `json:"data"`
-	Changes  map[string]*Change          `json:"changes"`
-	Tasks    map[string]*Task            `json:"tasks"`
-	Warnings []*Warning                  `json:"warnings,omitempty"`
-	Notices  []*Notice                   `json:"notices,omitempty"`
+// stateMeta holds the parts of the state other than its data, changes and
+// tasks.
+type stateMeta struct {
+	Warnings []*Warning `json:"warnings,omitempty"`
+	Notices  []*Notice  `json:"notices,omitempty"`
 
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
@@ -206,13 +292,15 @@ type marshalledState struct {
 	LastNoticeTimestamp time.Time `json:"last-notice-timestamp,omitzero"`
 }
 
-// MarshalJSON makes State a json.Marshaller
-func (s *State) MarshalJSON() ([]byte, error) {
-	s.reading()
-	return json.Marshal(marshalledState{
-		Data:     s.data,
-		Changes:  s.changes,
-		Tasks:    s.tasks,
+type marshalledState struct {
+	Data    map[string]*json.RawMessage `json:"data"`
+	Changes map[string]*Change          `json:"changes"`
+	Tasks   map[string]*Task            `json:"tasks"`
+	stateMeta
+}
+
+func (s *State) meta() stateMeta {
+	return stateMeta{
 		Warnings: s.flattenWarnings(),
 		Notices:  s.flattenNotices(),
 
@@ -222,6 +310,17 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		LastNoticeId: s.lastNoticeId,
 
 		LastNoticeTimestamp: s.getLastNoticeTimestamp(),
+	}
+}
+
+// MarshalJSON makes State a json.Marshaller
+func (s *State) MarshalJSON() ([]byte, error) {
+	s.reading()
+	return json.Marshal(marshalledState{
+		Data:      s.data,
+		Changes:   s.changes,
+		Tasks:     s.tasks,
+		stateMeta: s.meta(),
 	})
 }
 
@@ -236,6 +335,8 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.data = unmarshalled.Data
 	s.changes = unmarshalled.Changes
 	s.tasks = unmarshalled.Tasks
+	// everything was replaced, so persist the whole state next
+	s.checkpointed = nil
 	s.unflattenWarnings(unmarshalled.Warnings)
 	s.unflattenNotices(unmarshalled.Notices)
 	s.lastChangeId = unmarshalled.LastChangeId
@@ -295,15 +396,16 @@ func (s *State) Unlock() {
 	defer s.unlock()
 
 	if !s.modified || s.backend == nil {
+		s.checkpointDone()
 		return
 	}
 
-	data := s.checkpointData()
+	checkpoint := s.prepareCheckpoint()
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
-			s.modified = false
+		if err = checkpoint(); err == nil {
+			s.checkpointDone()
 			return
 		}
 		time.Sleep(unlockCheckpointRetryInterval)
@@ -362,6 +464,7 @@ func (s *State) Has(key string) bool {
 func (s *State) Set(key string, value any) {
 	s.writing()
 	s.data.set(key, value)
+	s.dataModified(key)
 }
 
 // Cached returns the cached value associated with the provided key.
@@ -389,6 +492,7 @@ func (s *State) NewChange(kind, summary string) *Change {
 	id := strconv.Itoa(s.lastChangeId)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
+	s.changeModified(id)
 	// Add change-update notice for newly spawned change
 	// NOTE: Implies State.writing()
 	if err := chg.addNotice(); err != nil {
@@ -429,6 +533,7 @@ func (s *State) NewTask(kind, summary string) *Task {
 	id := strconv.Itoa(s.lastTaskId)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
+	s.taskModified(id)
 	return t
 }
 
@@ -477,6 +582,20 @@ func (s *State) TaskCount() int {
 	return len(s.tasks)
 }
 
+// DiscardTasks removes the given tasks from the state. It is meant for
+// tasks that were created only to be inspected and will never run, tasks
+// already linked to a change are left alone.
+func (s *State) DiscardTasks(tasks []*Task) {
+	s.writing()
+	for _, t := range tasks {
+		if t.Change() != nil {
+			continue
+		}
+		delete(s.tasks, t.ID())
+		s.taskModified(t.ID())
+	}
+}
+
 func (s *State) tasksIn(tids []string) []*Task {
 	res := make([]*Task, len(tids))
 	for i, tid := range tids {
@@ -539,6 +658,7 @@ NextChange:
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
 				delete(s.changes, chg.ID())
+				s.changeModified(chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				for attr, pending := range s.pendingChangeByAttr {
 					if chg.Has(attr) && pending(chg) {
@@ -552,10 +672,13 @@ NextChange:
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
 			s.writing()
+			s.notifyChangePrunedHandlers(chg)
 			for _, t := range chg.Tasks() {
 				delete(s.tasks, t.ID())
+				s.taskModified(t.ID())
 			}
 			delete(s.changes, chg.ID())
+			s.changeModified(chg.ID())
 			readyChangesCount--
 		}
 	}
@@ -565,6 +688,7 @@ NextChange:
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
 			s.writing()
 			delete(s.tasks, tid)
+			s.taskModified(tid)
 		}
 	}
 }
@@ -655,6 +779,31 @@ func (s *State) notifyChangeStatusChangedHandlers(chg *Change, old, new Status)
 	}
 }
 
+// AddChangePrunedHandler adds a callback function that will be invoked
+// whenever a ready Change is about to be pruned from the state, with its
+// tasks still accessible.
+func (s *State) AddChangePrunedHandler(f func(chg *Change)) (id int) {
+	s.reading()
+	id = s.lastHandlerId
+	s.lastHandlerId++
+	s.pruneHandlers[id] = f
+	return id
+}
+
+// RemoveChangePrunedHandler removes the callback function with the given id,
+// as returned by AddChangePrunedHandler.
+func (s *State) RemoveChangePrunedHandler(id int) {
+	s.reading()
+	delete(s.pruneHandlers, id)
+}
+
+func (s *State) notifyChangePrunedHandlers(chg *Change) {
+	s.reading()
+	for _, f := range s.pruneHandlers {
+		f(chg)
+	}
+}
+
 // SaveTimings implements timings.GetSaver
 func (s *State) SaveTimings(timings any) {
 	s.Set("timings", timings)
@@ -676,6 +825,7 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s.cache = make(map[any]any)
 	s.pendingChangeByAttr = make(map[string]func(*Change) bool)
 	s.changeHandlers = make(map[int]func(chg *Change, old Status, new Status))
+	s.pruneHandlers = make(map[int]func(chg *Change))
 	s.taskHandlers = make(map[int]func(t *Task, old Status, new Status) bool)
 	return s, err
 }
@@ -24,11 +24,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -57,6 +59,50 @@ func (ss *stateSuite) TestLockUnlock(c *C) {
 	st.Unlock()
 }
 
+// lockMetricsCounts returns how many times waiting for and holding the state
+// lock were measured.
+func lockMetricsCounts(c *C) (wait, hold string) {
+	var buf bytes.Buffer
+	c.Assert(metrics.WriteOpenMetrics(&buf, metrics.DefaultRegistry), IsNil)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "snapd_state_lock_wait_seconds_count ") {
+			wait = line
+		}
+		if strings.HasPrefix(line, "snapd_state_lock_hold_seconds_count ") {
+			hold = line
+		}
+	}
+	return wait, hold
+}
+
+func (ss *stateSuite) TestLockMetrics(c *C) {
+	st := state.New(nil)
+	// metrics are not collected by default
+	wait, hold := lockMetricsCounts(c)
+	st.Lock()
+	st.Unlock()
+	wait1, hold1 := lockMetricsCounts(c)
+	c.Check(wait1, Equals, wait)
+	c.Check(hold1, Equals, hold)
+
+	metrics.SetEnabled(true)
+	defer metrics.SetEnabled(false)
+	st.Lock()
+	st.Unlock()
+	wait2, hold2 := lockMetricsCounts(c)
+	c.Check(wait2, Not(Equals), wait1)
+	c.Check(hold2, Not(Equals), hold1)
+
+	// nor the time the lock was held if they were enabled meanwhile
+	metrics.SetEnabled(false)
+	st.Lock()
+	metrics.SetEnabled(true)
+	st.Unlock()
+	wait3, hold3 := lockMetricsCounts(c)
+	c.Check(wait3, Equals, wait2)
+	c.Check(hold3, Equals, hold2)
+}
+
 func (ss *stateSuite) TestUnlocker(c *C) {
 	st := state.New(nil)
 	unlocker := st.Unlocker()
@@ -597,6 +643,7 @@ func (ss *stateSuite) TestEmptyStateDataAndCheckpointReadAndSet(c *C) {
 		"pendingChangeByAttr",
 		"taskHandlers",
 		"changeHandlers",
+		"pruneHandlers",
 	})
 }
 
@@ -759,6 +806,27 @@ func (ss *stateSuite) TestNewTaskHiddenUntilLinked(c *C) {
 	c.Check(st.Task(t1.ID()), IsNil)
 }
 
+func (ss *stateSuite) TestDiscardTasks(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("check", "...")
+	t2 := st.NewTask("check", "...")
+	t3 := st.NewTask("check", "...")
+	chg.AddTask(t3)
+	c.Check(st.TaskCount(), Equals, 3)
+
+	// tasks linked to a change are kept
+	st.DiscardTasks([]*state.Task{t1, t3})
+	c.Check(st.TaskCount(), Equals, 2)
+	c.Check(st.Task(t3.ID()), Equals, t3)
+
+	st.DiscardTasks([]*state.Task{t2})
+	c.Check(st.TaskCount(), Equals, 1)
+}
+
 func (ss *stateSuite) TestMethodEntrance(c *C) {
 	st := state.New(&fakeStateBackend{})
 
@@ -777,6 +845,7 @@ func (ss *stateSuite) TestMethodEntrance(c *C) {
 		func() { st.UnshowAllWarnings() },
 		func() { st.AddNotice(nil, state.WarningNotice, "foo", nil) },
 		func() { st.DrainNotices(nil) },
+		func() { st.DiscardTasks(nil) },
 	}
 
 	reads := []func(){
@@ -880,6 +949,43 @@ func (ss *stateSuite) TestPrune(c *C) {
 	c.Check(st.AllWarnings(), HasLen, 1)
 }
 
+func (ss *stateSuite) TestPruneChangePrunedHandler(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+
+	t1 := st.NewTask("foo", "...")
+	t1.Logf("some log")
+	chg1 := st.NewChange("prune", "...")
+	chg1.AddTask(t1)
+	state.MockChangeTimes(chg1, now.Add(-pruneWait), now.Add(-pruneWait))
+
+	t2 := st.NewTask("foo", "...")
+	chg2 := st.NewChange("ready-but-recent", "...")
+	chg2.AddTask(t2)
+	state.MockChangeTimes(chg2, now.Add(-pruneWait), now.Add(-pruneWait/2))
+
+	var pruned []string
+	id := st.AddChangePrunedHandler(func(chg *state.Change) {
+		// the tasks of the change are still accessible
+		c.Assert(chg.Tasks(), HasLen, 1)
+		c.Check(chg.Tasks()[0].Log(), HasLen, 1)
+		pruned = append(pruned, chg.ID())
+	})
+
+	st.Prune(now.AddDate(-1, 0, 0), pruneWait, 3*pruneWait, 100)
+	c.Check(pruned, DeepEquals, []string{chg1.ID()})
+	c.Check(st.Change(chg1.ID()), IsNil)
+
+	st.RemoveChangePrunedHandler(id)
+	st.Prune(now.AddDate(-1, 0, 0), pruneWait, 3*pruneWait, 0)
+	c.Check(st.Change(chg2.ID()), IsNil)
+	c.Check(pruned, DeepEquals, []string{chg1.ID()})
+}
+
 func (ss *stateSuite) TestRegisterPendingChangeByAttr(c *C) {
 	st := state.New(&fakeStateBackend{})
 	st.Lock()
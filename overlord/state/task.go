@@ -264,7 +264,7 @@ func (t *Task) SetStatus(new Status) {
 		panic("Task.SetStatus() called with WaitStatus, which is not allowed. Use SetToWait() instead")
 	}
 
-	t.state.writing()
+	t.state.writingTask(t.id)
 	old := t.status
 	if new == DoneStatus && old == AbortStatus {
 		// if the task is in AbortStatus (because some other task ran
@@ -285,7 +285,7 @@ func (t *Task) SetToWait(resultStatus Status) {
 		panic("Task.SetToWait() cannot be invoked with either of DefaultStatus or WaitStatus")
 	}
 
-	t.state.writing()
+	t.state.writingTask(t.id)
 	old := t.status
 	if old == AbortStatus {
 		// if the task is in AbortStatus (because some other task ran
@@ -316,7 +316,7 @@ func (t *Task) IsClean() bool {
 //
 // Cleaning a task must only be done after the change is ready.
 func (t *Task) SetClean() {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	if t.clean {
 		return
 	}
@@ -356,7 +356,7 @@ func (t *Task) Progress() (label string, done, total int) {
 func (t *Task) SetProgress(label string, done, total int) {
 	// Only mark state for checkpointing if progress is final.
 	if total > 0 && done == total {
-		t.state.writing()
+		t.state.writingTask(t.id)
 	} else {
 		t.state.reading()
 	}
@@ -387,12 +387,12 @@ func (t *Task) AtTime() time.Time {
 }
 
 func (t *Task) accumulateDoingTime(duration time.Duration) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.doingTime += duration
 }
 
 func (t *Task) accumulateUndoingTime(duration time.Duration) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.undoingTime += duration
 }
 
@@ -451,20 +451,20 @@ func (t *Task) Log() []string {
 
 // Logf logs information about the progress of the task.
 func (t *Task) Logf(format string, args ...any) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.addLog(LogInfo, format, args)
 }
 
 // Errorf logs error information about the progress of the task.
 func (t *Task) Errorf(format string, args ...any) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.addLog(LogError, format, args)
 }
 
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value any) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.data.set(key, value)
 }
 
@@ -483,7 +483,7 @@ func (t *Task) Has(key string) bool {
 
 // Clear disassociates the value from key.
 func (t *Task) Clear(key string) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	delete(t.data, key)
 }
 
@@ -498,9 +498,10 @@ func addOnce(set []string, s string) []string {
 
 // WaitFor registers another task as a requirement for t to make progress.
 func (t *Task) WaitFor(another *Task) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.waitTasks = addOnce(t.waitTasks, another.id)
 	another.haltTasks = addOnce(another.haltTasks, t.id)
+	t.state.taskModified(another.id)
 }
 
 // WaitAll registers all the tasks in the set as a requirement for t
@@ -540,13 +541,13 @@ func (t *Task) Lanes() []int {
 // JoinLane registers the task in the provided lane. Tasks in different lanes
 // abort independently on errors. See Change.AbortLane for details.
 func (t *Task) JoinLane(lane int) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.lanes = append(t.lanes, lane)
 }
 
 // At schedules the task, if it's not ready, to happen no earlier than when, if when is the zero time any previous special scheduling is suppressed.
 func (t *Task) At(when time.Time) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	iszero := when.IsZero()
 	if t.Status().Ready() && !iszero {
 		return
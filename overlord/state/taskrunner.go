@@ -26,8 +26,12 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 )
 
+var taskRetries = metrics.NewCounterVec("snapd_task_retries",
+	"Number of times tasks asked to be retried, by task kind.", "kind")
+
 // HandlerFunc is the type of function for the handlers
 type HandlerFunc func(task *Task, tomb *tomb.Tomb) error
 
@@ -256,6 +260,9 @@ func (r *TaskRunner) run(t *Task) {
 
 		switch x := err.(type) {
 		case *Retry:
+			if metrics.Enabled() {
+				taskRetries.Inc(t.Kind())
+			}
 			// Handler asked to be called again later.
 			if t.Status() == AbortStatus {
 				// Would work without it but might take two ensures.
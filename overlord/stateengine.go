@@ -22,12 +22,30 @@ package overlord
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
+var (
+	ensureDuration = metrics.NewHistogramVec("snapd_ensure_duration_seconds",
+		"Duration of the ensure passes of all managers.", metrics.DefaultDurationBuckets)
+	managerEnsureDuration = metrics.NewHistogramVec("snapd_manager_ensure_duration_seconds",
+		"Duration of the ensure passes of each manager.", metrics.DefaultDurationBuckets, "manager")
+	managerEnsureErrors = metrics.NewCounterVec("snapd_manager_ensure_errors",
+		"Number of ensure passes of each manager which failed.", "manager")
+)
+
+// managerName returns the name of the given manager for metrics, e.g.
+// "snapstate.SnapManager".
+func managerName(m StateManager) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", m), "*")
+}
+
 // StateManager is implemented by types responsible for observing
 // the system and manipulating it to reflect the desired state.
 type StateManager interface {
@@ -155,13 +173,25 @@ func (se *StateEngine) Ensure() error {
 		return fmt.Errorf("state engine already stopped")
 	}
 	var errs []error
+	collectMetrics := metrics.Enabled()
+	start := time.Now()
 	for _, m := range se.managers {
+		mgrStart := time.Now()
 		err := m.Ensure()
+		if collectMetrics {
+			managerEnsureDuration.Observe(time.Since(mgrStart).Seconds(), managerName(m))
+		}
 		if err != nil {
 			logger.Noticef("state ensure error: %v", err)
+			if collectMetrics {
+				managerEnsureErrors.Inc(managerName(m))
+			}
 			errs = append(errs, err)
 		}
 	}
+	if collectMetrics {
+		ensureDuration.Observe(time.Since(start).Seconds())
+	}
 	if len(errs) != 0 {
 		return &ensureError{errs}
 	}
@@ -32,8 +32,10 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/lansharestate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // A Backend exposes device information and device identity
@@ -265,6 +267,73 @@ func (sc *storeContext) StoreOffline() (bool, error) {
 	return offline, nil
 }
 
+// LANPeers returns the addresses of the peers on the local network to fetch
+// snaps and components from, if sharing them is enabled.
+func (sc *storeContext) LANPeers() ([]string, error) {
+	sc.state.Lock()
+	defer sc.state.Unlock()
+
+	return lansharestate.Peers(sc.state)
+}
+
+// BandwidthPolicy returns the policy to apply to the traffic with the store
+// as set by the store.bandwidth.* configuration options, or nil if none is
+// set.
+func (sc *storeContext) BandwidthPolicy() (*store.BandwidthPolicy, error) {
+	sc.state.Lock()
+	defer sc.state.Unlock()
+
+	tr := config.NewTransaction(sc.state)
+	var schedule, budget string
+	if err := tr.GetMaybe("core", "store.bandwidth.schedule", &schedule); err != nil {
+		return nil, err
+	}
+	if err := tr.GetMaybe("core", "store.bandwidth.monthly-budget", &budget); err != nil {
+		return nil, err
+	}
+	if schedule == "" && budget == "" {
+		return nil, nil
+	}
+
+	windows, err := store.ParseBandwidthSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	policy := &store.BandwidthPolicy{Windows: windows}
+	if budget != "" {
+		policy.MonthlyBudget, err = strutil.ParseByteSize(budget)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse store bandwidth monthly budget: %v", err)
+		}
+	}
+	return policy, nil
+}
+
+// BandwidthUsage returns the recorded usage of the store bandwidth, or nil if
+// none was recorded yet.
+func (sc *storeContext) BandwidthUsage() (*store.BandwidthUsage, error) {
+	sc.state.Lock()
+	defer sc.state.Unlock()
+
+	var usage store.BandwidthUsage
+	if err := sc.state.Get("store-bandwidth-usage", &usage); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// SetBandwidthUsage records the usage of the store bandwidth.
+func (sc *storeContext) SetBandwidthUsage(usage *store.BandwidthUsage) error {
+	sc.state.Lock()
+	defer sc.state.Unlock()
+
+	sc.state.Set("store-bandwidth-usage", usage)
+	return nil
+}
+
 func (sc *storeContext) WithSnapStoreDelta() bool {
 	sc.state.Lock()
 	defer sc.state.Unlock()
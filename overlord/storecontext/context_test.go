@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -524,3 +525,66 @@ func (s *storeCtxSuite) TestStoreOffline(c *C) {
 	c.Check(err, IsNil)
 	c.Check(offline, Equals, true)
 }
+
+func (s *storeCtxSuite) TestLANPeers(c *C) {
+	b := &testBackend{}
+	storeCtx := storecontext.NewComposed(s.state, b, b, b)
+
+	peers, err := storeCtx.LANPeers()
+	c.Assert(err, IsNil)
+	c.Check(peers, IsNil)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.lan-sharing", true), IsNil)
+	c.Assert(tr.Set("core", "store.lan-peers", "10.0.0.1,10.0.0.2:8000"), IsNil)
+	tr.Commit()
+	s.state.Unlock()
+
+	peers, err = storeCtx.LANPeers()
+	c.Assert(err, IsNil)
+	c.Check(peers, DeepEquals, []string{"10.0.0.1:44747", "10.0.0.2:8000"})
+}
+
+func (s *storeCtxSuite) TestBandwidthPolicy(c *C) {
+	b := &testBackend{}
+	storeCtx := storecontext.NewComposed(s.state, b, b, b)
+
+	policy, err := storeCtx.BandwidthPolicy()
+	c.Assert(err, IsNil)
+	c.Check(policy, IsNil)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.bandwidth.schedule", "22:00-06:00=pause"), IsNil)
+	c.Assert(tr.Set("core", "store.bandwidth.monthly-budget", "2GB"), IsNil)
+	tr.Commit()
+	s.state.Unlock()
+
+	policy, err = storeCtx.BandwidthPolicy()
+	c.Assert(err, IsNil)
+	c.Check(policy, DeepEquals, &store.BandwidthPolicy{
+		Windows: []*store.BandwidthWindow{{
+			Start:  timeutil.Clock{Hour: 22},
+			End:    timeutil.Clock{Hour: 6},
+			Paused: true,
+		}},
+		MonthlyBudget: 2 * 1000 * 1000 * 1000,
+	})
+}
+
+func (s *storeCtxSuite) TestBandwidthUsage(c *C) {
+	b := &testBackend{}
+	storeCtx := storecontext.NewComposed(s.state, b, b, b)
+
+	usage, err := storeCtx.BandwidthUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, IsNil)
+
+	err = storeCtx.SetBandwidthUsage(&store.BandwidthUsage{Month: "2026-03", Bytes: 1234})
+	c.Assert(err, IsNil)
+
+	usage, err = storeCtx.BandwidthUsage()
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &store.BandwidthUsage{Month: "2026-03", Bytes: 1234})
+}
@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package storemirrorstate implements the manager serving a read-only
+// store mirror to other devices, from the store, download cache and
+// assertion database of this device.
+package storemirrorstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/mirror"
+)
+
+var listen = func(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+// defaultAddress is the address the mirror is served on unless another one
+// is configured, which only lets the device itself use it.
+const defaultAddress = "127.0.0.1"
+
+// mirrorSettings is the configuration of the mirror.
+type mirrorSettings struct {
+	enabled bool
+	// address is the address to serve the mirror on
+	address string
+	port    int
+	// deviceAuth is whether requests to the store on behalf of other
+	// devices are authorized as coming from this device
+	deviceAuth bool
+}
+
+// mirrorConfig returns the configuration of the mirror.
+//
+// The state must be locked by the caller.
+func mirrorConfig(st *state.State) (*mirrorSettings, error) {
+	tr := config.NewTransaction(st)
+	settings := &mirrorSettings{}
+	if err := tr.GetMaybe("core", "store.mirror", &settings.enabled); err != nil {
+		return nil, err
+	}
+	if !settings.enabled {
+		return settings, nil
+	}
+	settings.address = defaultAddress
+	if err := tr.GetMaybe("core", "store.mirror-address", &settings.address); err != nil {
+		return nil, err
+	}
+	settings.port = mirror.DefaultPort
+	if err := tr.GetMaybe("core", "store.mirror-port", &settings.port); err != nil {
+		return nil, err
+	}
+	if err := tr.GetMaybe("core", "store.mirror-device-auth", &settings.deviceAuth); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// StoreMirrorManager serves the store mirror API to other devices when
+// enabled through the store.mirror option, on the address set by the
+// store.mirror-address option.
+type StoreMirrorManager struct {
+	state *state.State
+	cache mirror.Cache
+
+	mu       sync.Mutex
+	server   *http.Server
+	settings mirrorSettings
+	wg       sync.WaitGroup
+}
+
+// Manager returns a new StoreMirrorManager.
+func Manager(st *state.State) *StoreMirrorManager {
+	return &StoreMirrorManager{
+		state: st,
+		cache: store.NewCacheManager(dirs.SnapDownloadCacheDir, store.CachePolicy{}),
+	}
+}
+
+// Ensure starts or stops the mirror according to the configuration.
+func (m *StoreMirrorManager) Ensure() error {
+	m.state.Lock()
+	settings, err := mirrorConfig(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.server != nil && *settings != m.settings {
+		m.server.Close()
+		m.server = nil
+	}
+	if !settings.enabled || m.server != nil {
+		return nil
+	}
+
+	// downloads are kept next to the download cache, so that they can
+	// be linked into it
+	downloadDir := filepath.Join(filepath.Dir(dirs.SnapDownloadCacheDir), "mirror")
+	addr := net.JoinHostPort(settings.address, strconv.Itoa(settings.port))
+	l, err := listen(addr)
+	if err != nil {
+		return fmt.Errorf("cannot serve store mirror: %v", err)
+	}
+	server := &http.Server{
+		Handler: mirror.NewHandler(&mirror.Config{
+			Store:       stateStore{m.state},
+			Assertions:  stateAssertions{m.state},
+			Cache:       m.cache,
+			DownloadDir: downloadDir,
+			DeviceAuth:  settings.deviceAuth,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	m.server = server
+	m.settings = *settings
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Noticef("cannot serve store mirror: %v", err)
+		}
+	}()
+	logger.Noticef("Serving store mirror on %s.", addr)
+	return nil
+}
+
+// Stop stops the mirror and waits for it to finish.
+func (m *StoreMirrorManager) Stop() {
+	m.mu.Lock()
+	if m.server != nil {
+		m.server.Close()
+		m.server = nil
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// stateStore is the store of the device, looked up for each request as it
+// can change, e.g. after a remodel.
+type stateStore struct {
+	st *state.State
+}
+
+func (s stateStore) backend() (mirror.Backend, error) {
+	s.st.Lock()
+	sto := snapstate.Store(s.st, nil)
+	s.st.Unlock()
+	backend, ok := sto.(mirror.Backend)
+	if !ok {
+		return nil, errors.New("internal error: store cannot be mirrored")
+	}
+	return backend, nil
+}
+
+func (s stateStore) MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	backend, err := s.backend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.MirrorSnapInfo(ctx, name, query, opts)
+}
+
+func (s stateStore) MirrorRefresh(ctx context.Context, body []byte, opts *store.MirrorOptions) (*http.Response, error) {
+	backend, err := s.backend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.MirrorRefresh(ctx, body, opts)
+}
+
+func (s stateStore) MirrorAssertion(ctx context.Context, assertType *asserts.AssertionType, key []string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	backend, err := s.backend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.MirrorAssertion(ctx, assertType, key, query, opts)
+}
+
+func (s stateStore) Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	backend, err := s.backend()
+	if err != nil {
+		return err
+	}
+	return backend.Download(ctx, name, targetPath, downloadInfo, pbar, user, dlOpts)
+}
+
+// stateAssertions is the assertion database of the device.
+type stateAssertions struct {
+	st *state.State
+}
+
+func (a stateAssertions) Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	a.st.Lock()
+	defer a.st.Unlock()
+	return assertstate.DB(a.st).Find(assertType, headers)
+}
+
+func (a stateAssertions) FindSequence(assertType *asserts.AssertionType, sequenceHeaders map[string]string, after, maxFormat int) (asserts.SequenceMember, error) {
+	a.st.Lock()
+	defer a.st.Unlock()
+	return assertstate.DB(a.st).FindSequence(assertType, sequenceHeaders, after, maxFormat)
+}
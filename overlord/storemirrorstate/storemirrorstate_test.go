@@ -0,0 +1,230 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package storemirrorstate_test
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storemirrorstate"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type storeMirrorSuite struct {
+	testutil.BaseTest
+
+	st       *state.State
+	addrs    []string
+	listened []string
+	requests []string
+
+	storeSigning *assertstest.StoreStack
+}
+
+var _ = Suite(&storeMirrorSuite{})
+
+type fakeStore struct {
+	storetest.Store
+
+	s *storeMirrorSuite
+}
+
+func (f *fakeStore) MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	f.s.requests = append(f.s.requests, fmt.Sprintf("info %s device-auth:%v", name, opts.DeviceAuth))
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	rec.WriteHeader(200)
+	io.WriteString(rec, `{"name": "foo", "channel-map": [{"download": {"url": "https://upstream/foo.snap", "sha3-384": "`+digest("foo snap")+`"}}]}`)
+	return rec.Result(), nil
+}
+
+func digest(content string) string {
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (s *storeMirrorSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.st = state.New(nil)
+	s.addrs = nil
+	s.listened = nil
+	s.requests = nil
+
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+
+	s.st.Lock()
+	assertstate.ReplaceDB(s.st, db)
+	snapstate.ReplaceStore(s.st, &fakeStore{s: s})
+	s.st.Unlock()
+
+	s.AddCleanup(storemirrorstate.MockListen(func(addr string) (net.Listener, error) {
+		s.listened = append(s.listened, addr)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err == nil {
+			s.addrs = append(s.addrs, l.Addr().String())
+		}
+		return l, err
+	}))
+}
+
+func (s *storeMirrorSuite) set(c *C, key string, value any) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", key, value), IsNil)
+	tr.Commit()
+}
+
+func (s *storeMirrorSuite) get(c *C, path string) (int, []byte) {
+	rsp, err := http.Get("http://" + s.addrs[len(s.addrs)-1] + path)
+	c.Assert(err, IsNil)
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	c.Assert(err, IsNil)
+	return rsp.StatusCode, data
+}
+
+func (s *storeMirrorSuite) TestDisabled(c *C) {
+	m := storemirrorstate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, HasLen, 0)
+}
+
+func (s *storeMirrorSuite) TestMirror(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, digest("foo snap")), []byte("foo snap"), 0600), IsNil)
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	s.st.Lock()
+	c.Assert(assertstate.Add(s.st, s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(assertstate.Add(s.st, acct), IsNil)
+	s.st.Unlock()
+
+	s.set(c, "store.mirror", true)
+	m := storemirrorstate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	// only the device itself can use the mirror by default
+	c.Check(s.listened, DeepEquals, []string{"127.0.0.1:44748"})
+	// nothing to do once started
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, HasLen, 1)
+
+	// requests are passed on to the store of the device
+	status, data := s.get(c, "/v2/snaps/info/foo")
+	c.Check(status, Equals, 200)
+	var info struct {
+		ChannelMap []struct {
+			Download struct {
+				URL string `json:"url"`
+			} `json:"download"`
+		} `json:"channel-map"`
+	}
+	c.Assert(json.Unmarshal(data, &info), IsNil)
+	c.Assert(info.ChannelMap, HasLen, 1)
+	downloadURL := "http://" + s.addrs[0] + "/v2/mirror/downloads/" + digest("foo snap")
+	c.Check(info.ChannelMap[0].Download.URL, Equals, downloadURL)
+	// anonymously by default
+	c.Check(s.requests, DeepEquals, []string{"info foo device-auth:false"})
+
+	// snaps are served from the download cache
+	status, data = s.get(c, "/v2/mirror/downloads/"+digest("foo snap"))
+	c.Check(status, Equals, 200)
+	c.Check(string(data), Equals, "foo snap")
+
+	// and assertions from the assertion database
+	status, data = s.get(c, "/v2/assertions/account/"+acct.AccountID())
+	c.Check(status, Equals, 200)
+	c.Check(data, DeepEquals, asserts.Encode(acct))
+	c.Check(s.requests, HasLen, 1)
+
+	// changing the address restarts the mirror
+	s.set(c, "store.mirror-address", "192.168.1.10")
+	s.set(c, "store.mirror-port", 8080)
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, DeepEquals, []string{"127.0.0.1:44748", "192.168.1.10:8080"})
+	_, err := http.Get("http://" + s.addrs[0] + "/v2/snaps/info/foo")
+	c.Check(err, NotNil)
+	status, _ = s.get(c, "/v2/mirror/downloads/"+digest("foo snap"))
+	c.Check(status, Equals, 200)
+
+	// disabling the mirror stops it
+	s.set(c, "store.mirror", false)
+	c.Assert(m.Ensure(), IsNil)
+	_, err = http.Get("http://" + s.addrs[1] + "/v2/snaps/info/foo")
+	c.Check(err, NotNil)
+}
+
+func (s *storeMirrorSuite) TestMirrorDeviceAuth(c *C) {
+	s.set(c, "store.mirror", true)
+	m := storemirrorstate.Manager(s.st)
+	defer m.Stop()
+
+	c.Assert(m.Ensure(), IsNil)
+	status, _ := s.get(c, "/v2/snaps/info/foo")
+	c.Check(status, Equals, 200)
+
+	// authorizing requests as coming from the device is opt-in, and
+	// restarts the mirror
+	s.set(c, "store.mirror-device-auth", true)
+	c.Assert(m.Ensure(), IsNil)
+	c.Check(s.listened, HasLen, 2)
+	status, _ = s.get(c, "/v2/snaps/info/foo")
+	c.Check(status, Equals, 200)
+	c.Check(s.requests, DeepEquals, []string{
+		"info foo device-auth:false",
+		"info foo device-auth:true",
+	})
+}
@@ -221,3 +221,11 @@ func (f *fakeActivateContext) State() *ActivateState {
 func NewActivateContext(ctx context.Context) (ActivateContext, error) {
 	return &fakeActivateContext{}, nil
 }
+
+func NewDeviceBoundKey() (key, sealed []byte, err error) {
+	return nil, nil, errBuildWithoutSecboot
+}
+
+func RecoverDeviceBoundKey(sealed []byte) ([]byte, error) {
+	return nil, errBuildWithoutSecboot
+}
@@ -5250,6 +5250,64 @@ func (s *secbootSuite) TestAddContainerTPMProtectedKey(c *C) {
 	}
 }
 
+func (s *secbootSuite) TestNewDeviceBoundKey(c *C) {
+	mockErr := errors.New("some error")
+
+	for idx, tc := range []struct {
+		tpmEnabled  bool
+		tpmErr      error
+		sealErr     error
+		sealCalls   int
+		expectedErr string
+	}{
+		{tpmEnabled: false, expectedErr: "TPM device is not enabled"},
+		{tpmEnabled: true, tpmErr: mockErr, expectedErr: "cannot connect to TPM: some error"},
+		{tpmEnabled: true, sealErr: mockErr, sealCalls: 1, expectedErr: "cannot seal key: some error"},
+		{tpmEnabled: true, sealCalls: 1},
+	} {
+		c.Logf("tc: %v", idx)
+
+		tpm, restore := mockSbTPMConnection(c, tc.tpmErr)
+		defer restore()
+
+		defer secboot.MockIsTPMEnabled(func(tpm *sb_tpm2.Connection) bool {
+			return tc.tpmEnabled
+		})()
+
+		sealCalls := 0
+		defer secboot.MockSbNewTPMProtectedKey(func(t *sb_tpm2.Connection, params *sb_tpm2.ProtectKeyParams) (protectedKey *sb.KeyData, primaryKey sb.PrimaryKey, unlockKey sb.DiskUnlockKey, err error) {
+			sealCalls++
+			c.Assert(t, Equals, tpm)
+			c.Check(params.PCRPolicyCounterHandle, Equals, tpm2.HandleNull)
+			c.Check(params.Role, Equals, "device-bound")
+			c.Check(params.PrimaryKey, IsNil)
+			return &sb.KeyData{}, sb.PrimaryKey{}, sb.DiskUnlockKey{'u', 'n', 'l', 'o', 'c', 'k'}, tc.sealErr
+		})()
+
+		key, sealed, err := secboot.NewDeviceBoundKey()
+		c.Check(sealCalls, Equals, tc.sealCalls)
+		if tc.expectedErr != "" {
+			c.Assert(err, ErrorMatches, tc.expectedErr)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Check(key, DeepEquals, []byte("unlock"))
+		c.Check(sealed, Not(HasLen), 0)
+	}
+}
+
+func (s *secbootSuite) TestRecoverDeviceBoundKeyError(c *C) {
+	defer secboot.MockSbReadKeyData(func(reader sb.KeyDataReader) (*sb.KeyData, error) {
+		data, err := io.ReadAll(reader)
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, "sealed")
+		return nil, errors.New("some error")
+	})()
+
+	_, err := secboot.RecoverDeviceBoundKey([]byte("sealed"))
+	c.Check(err, ErrorMatches, "cannot read sealed key: some error")
+}
+
 func (s *secbootSuite) TestResealKeyTPM(c *C) {
 	kd := &sb.KeyData{}
 
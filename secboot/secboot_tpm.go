@@ -1456,3 +1456,63 @@ func AddContainerTPMProtectedKey(devicePath, slotName string, params *ProtectKey
 	keyData := keyData{kd: protectedKey}
 	return keyData.WriteTokenAtomic(devicePath, slotName)
 }
+
+// deviceBoundKeyRole is the role of the keys created by NewDeviceBoundKey.
+const deviceBoundKeyRole = "device-bound"
+
+// keyDataBuffer holds serialized key data in memory.
+type keyDataBuffer struct {
+	bytes.Buffer
+}
+
+func (b *keyDataBuffer) Commit() error {
+	return nil
+}
+
+func (b *keyDataBuffer) ReadableName() string {
+	return "device bound key"
+}
+
+// NewDeviceBoundKey returns a new random key along with its sealed form.
+// The key is sealed by the TPM without being bound to the boot chain, so
+// that it can only be recovered with RecoverDeviceBoundKey on this device.
+func NewDeviceBoundKey() (key, sealed []byte, err error) {
+	tpm, err := sbConnectToDefaultTPM()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to TPM: %v", err)
+	}
+	defer tpm.Close()
+	if !isTPMEnabled(tpm) {
+		return nil, nil, errors.New("TPM device is not enabled")
+	}
+
+	creationParams := &sb_tpm2.ProtectKeyParams{
+		PCRProfile:             sb_tpm2.NewPCRProtectionProfile(),
+		Role:                   deviceBoundKeyRole,
+		PCRPolicyCounterHandle: tpm2.HandleNull,
+	}
+	protectedKey, _, unlockKey, err := sbNewTPMProtectedKey(tpm, creationParams)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot seal key: %v", err)
+	}
+	buf := &keyDataBuffer{}
+	if err := protectedKey.WriteAtomic(buf); err != nil {
+		return nil, nil, fmt.Errorf("cannot serialize sealed key: %v", err)
+	}
+	return unlockKey, buf.Bytes(), nil
+}
+
+// RecoverDeviceBoundKey returns the key sealed by NewDeviceBoundKey.
+func RecoverDeviceBoundKey(sealed []byte) ([]byte, error) {
+	buf := &keyDataBuffer{}
+	buf.Write(sealed)
+	keyData, err := sbReadKeyData(buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read sealed key: %v", err)
+	}
+	key, _, err := keyData.RecoverKeys()
+	if err != nil {
+		return nil, fmt.Errorf("cannot unseal key: %v", err)
+	}
+	return key, nil
+}
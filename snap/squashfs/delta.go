@@ -267,6 +267,22 @@ func formatStoreString(id DeltaFormat) string {
 	return "unexpected"
 }
 
+// String returns the identifier of the delta format used by the store.
+func (f DeltaFormat) String() string {
+	return formatStoreString(f)
+}
+
+// DeltaFormatFromString returns the delta format with the given identifier,
+// as used by the store.
+func DeltaFormatFromString(id string) (DeltaFormat, error) {
+	for _, f := range []DeltaFormat{Xdelta3Format, SnapXdelta3Format} {
+		if formatStoreString(f) == id {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported delta format %q", id)
+}
+
 type DeltaFormatOpts struct {
 	WithSnapDeltaFormat bool
 }
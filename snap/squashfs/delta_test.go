@@ -24,6 +24,7 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -941,3 +942,132 @@ func (s *DeltaTestSuite) TestApplyDeltaSnapXdelta3RunManyError(c *C) {
 	c.Check(os.IsNotExist(err), Equals, true,
 		Commentf("Temp dir %s was not cleaned up during ApplyDelta failure", capturedTempDir))
 }
+
+func (s *DeltaTestSuite) TestDeltaFormatFromString(c *C) {
+	for _, f := range []squashfs.DeltaFormat{squashfs.Xdelta3Format, squashfs.SnapXdelta3Format} {
+		parsed, err := squashfs.DeltaFormatFromString(f.String())
+		c.Assert(err, IsNil)
+		c.Check(parsed, Equals, f)
+	}
+	c.Check(squashfs.Xdelta3Format.String(), Equals, "xdelta3")
+	c.Check(squashfs.SnapXdelta3Format.String(), Equals, "snap-1-1-xdelta3")
+
+	_, err := squashfs.DeltaFormatFromString("bsdiff")
+	c.Check(err, ErrorMatches, `unsupported delta format "bsdiff"`)
+}
+
+// fakeXdelta3 "encodes" a delta by prefixing the target with the xdelta3
+// magic, which is enough for a round trip through GenerateDelta and
+// ApplyDelta.
+const fakeXdelta3 = `
+decode=
+while [ $# -gt 2 ]; do
+	case "$1" in
+		-d) decode=1;;
+		-s) shift;;
+	esac
+	shift
+done
+if [ -n "$decode" ]; then
+	tail -c +5 "$1" > "$2"
+else
+	{ printf '\326\303\304\000'; cat "$1"; } > "$2"
+fi
+`
+
+func (s *DeltaTestSuite) TestDeltaRoundTripPlain(c *C) {
+	xdelta3 := testutil.MockCommand(c, "xdelta3", fakeXdelta3)
+	defer xdelta3.Restore()
+	defer squashfs.MockCommandFromSystemSnapWithContext(
+		func(ctx context.Context, cmd string, args ...string) (*exec.Cmd, error) {
+			c.Check(cmd, Equals, "/usr/bin/xdelta3")
+			return exec.CommandContext(ctx, xdelta3.Exe(), args...), nil
+		})()
+
+	src := filepath.Join(dirs.GlobalRootDir, "source.snap")
+	c.Assert(os.WriteFile(src, []byte("source content"), 0644), IsNil)
+	dst := filepath.Join(dirs.GlobalRootDir, "target.snap")
+	c.Assert(os.WriteFile(dst, []byte("target content"), 0644), IsNil)
+	delta := filepath.Join(dirs.GlobalRootDir, "delta")
+
+	err := squashfs.GenerateDelta(context.Background(), src, dst, delta, squashfs.Xdelta3Format)
+	c.Assert(err, IsNil)
+
+	rebuilt := filepath.Join(dirs.GlobalRootDir, "rebuilt.snap")
+	err = squashfs.ApplyDelta(context.Background(), src, delta, rebuilt)
+	c.Assert(err, IsNil)
+	c.Check(rebuilt, testutil.FileEquals, "target content")
+	c.Check(xdelta3.Calls(), DeepEquals, [][]string{
+		{"xdelta3", "-3", "-f", "-e", "-s", src, dst, delta},
+		{"xdelta3", "-f", "-d", "-s", src, delta, rebuilt},
+	})
+}
+
+func (s *DeltaTestSuite) TestDeltaRoundTripRealXdelta3(c *C) {
+	if _, err := exec.LookPath("xdelta3"); err != nil {
+		c.Skip("xdelta3 is not available")
+	}
+	defer squashfs.MockCommandFromSystemSnapWithContext(
+		func(ctx context.Context, cmd string, args ...string) (*exec.Cmd, error) {
+			c.Check(cmd, Equals, "/usr/bin/xdelta3")
+			return exec.CommandContext(ctx, "xdelta3", args...), nil
+		})()
+
+	source := bytes.Repeat([]byte("some snap content\n"), 4096)
+	target := append(bytes.Replace(source, []byte("some"), []byte("other"), 100), "appended content\n"...)
+	src := filepath.Join(dirs.GlobalRootDir, "source.snap")
+	c.Assert(os.WriteFile(src, source, 0644), IsNil)
+	dst := filepath.Join(dirs.GlobalRootDir, "target.snap")
+	c.Assert(os.WriteFile(dst, target, 0644), IsNil)
+	delta := filepath.Join(dirs.GlobalRootDir, "delta")
+
+	err := squashfs.GenerateDelta(context.Background(), src, dst, delta, squashfs.Xdelta3Format)
+	c.Assert(err, IsNil)
+	// the delta is a real xdelta3 one
+	deltaContent, err := os.ReadFile(delta)
+	c.Assert(err, IsNil)
+	c.Assert(len(deltaContent) < len(target), Equals, true)
+
+	rebuilt := filepath.Join(dirs.GlobalRootDir, "rebuilt.snap")
+	err = squashfs.ApplyDelta(context.Background(), src, delta, rebuilt)
+	c.Assert(err, IsNil)
+	c.Check(rebuilt, testutil.FileEquals, target)
+}
+
+func (s *DeltaTestSuite) TestDeltaRoundTripRealTools(c *C) {
+	for _, tool := range []string{"mksquashfs", "unsquashfs", "xdelta3"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			c.Skip(fmt.Sprintf("%s is not available", tool))
+		}
+	}
+	defer squashfs.MockCommandFromSystemSnapWithContext(
+		func(ctx context.Context, cmd string, args ...string) (*exec.Cmd, error) {
+			return exec.CommandContext(ctx, filepath.Base(cmd), args...), nil
+		})()
+
+	mksnap := func(name string, files map[string]string) string {
+		dir := c.MkDir()
+		for p, content := range files {
+			c.Assert(os.WriteFile(filepath.Join(dir, p), []byte(content), 0644), IsNil)
+		}
+		snapPath := filepath.Join(dirs.GlobalRootDir, name)
+		c.Assert(squashfs.New(snapPath).Build(dir, nil), IsNil)
+		return snapPath
+	}
+	src := mksnap("source.snap", map[string]string{"a": "some content", "b": "other content"})
+	dst := mksnap("target.snap", map[string]string{"a": "some changed content", "c": "other content"})
+
+	for _, format := range []squashfs.DeltaFormat{squashfs.Xdelta3Format, squashfs.SnapXdelta3Format} {
+		delta := filepath.Join(dirs.GlobalRootDir, "delta."+format.String())
+		err := squashfs.GenerateDelta(context.Background(), src, dst, delta, format)
+		c.Assert(err, IsNil, Commentf("%s", format))
+
+		rebuilt := filepath.Join(dirs.GlobalRootDir, "rebuilt-"+format.String()+".snap")
+		err = squashfs.ApplyDelta(context.Background(), src, delta, rebuilt)
+		c.Assert(err, IsNil, Commentf("%s", format))
+
+		expected, err := os.ReadFile(dst)
+		c.Assert(err, IsNil)
+		c.Check(rebuilt, testutil.FileEquals, expected, Commentf("%s", format))
+	}
+}
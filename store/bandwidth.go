@@ -0,0 +1,446 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var (
+	bandwidthTimeNow = time.Now
+	bandwidthAfter   = time.After
+
+	// bandwidthUsageSaveThreshold is the number of bytes received from the
+	// store after which the usage is saved, even while a transfer is
+	// ongoing.
+	bandwidthUsageSaveThreshold int64 = 1024 * 1024
+
+	bandwidthUsed = metrics.NewGaugeVec("snapd_store_bandwidth_used_bytes",
+		"Number of bytes received from the store in the current month.")
+)
+
+// BandwidthWindow is a time of day window with its own bandwidth policy.
+type BandwidthWindow struct {
+	// Start and End are the local times of day the window starts and
+	// ends at. A window ending before it starts spans midnight.
+	Start timeutil.Clock
+	End   timeutil.Clock
+	// RateLimit is the maximum rate, in bytes per second, at which data is
+	// received from the store during the window, or 0 if unlimited.
+	RateLimit int64
+	// Paused is set if downloads are paused during the window.
+	Paused bool
+}
+
+func (w *BandwidthWindow) String() string {
+	var policy string
+	switch {
+	case w.Paused:
+		policy = "pause"
+	case w.RateLimit == 0:
+		policy = "unlimited"
+	default:
+		policy = fmt.Sprintf("%d", w.RateLimit)
+	}
+	return fmt.Sprintf("%s-%s=%s", w.Start, w.End, policy)
+}
+
+func clockMinutes(c timeutil.Clock) int {
+	return c.Hour*60 + c.Minute
+}
+
+// includes returns whether the window includes the given local time.
+func (w *BandwidthWindow) includes(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	start, end := clockMinutes(w.Start), clockMinutes(w.End)
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// end returns when the window including the given time ends.
+func (w *BandwidthWindow) end(t time.Time) time.Time {
+	end := w.End.Time(t)
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// ParseBandwidthSchedule parses a comma-separated list of time of day windows
+// with their policy, as in "09:00-17:00=256KB,22:00-06:00=unlimited". The
+// policy of a window is either a rate limit in bytes per second, "unlimited"
+// or "pause". Windows must not overlap.
+func ParseBandwidthSchedule(schedule string) ([]*BandwidthWindow, error) {
+	var windows []*BandwidthWindow
+	for _, entry := range strutil.CommaSeparatedList(schedule) {
+		span, policy, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("cannot parse bandwidth window %q: missing policy", entry)
+		}
+		startStr, endStr, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("cannot parse bandwidth window %q: invalid time span %q", entry, span)
+		}
+		start, err := timeutil.ParseClock(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse bandwidth window %q: %v", entry, err)
+		}
+		end, err := timeutil.ParseClock(endStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse bandwidth window %q: %v", entry, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("cannot parse bandwidth window %q: empty time span", entry)
+		}
+		w := &BandwidthWindow{Start: start, End: end}
+		switch policy {
+		case "pause":
+			w.Paused = true
+		case "unlimited":
+		default:
+			rate, err := strutil.ParseByteSize(policy)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse bandwidth window %q: %v", entry, err)
+			}
+			if rate <= 0 {
+				return nil, fmt.Errorf("cannot parse bandwidth window %q: rate limit must be positive", entry)
+			}
+			w.RateLimit = rate
+		}
+		windows = append(windows, w)
+	}
+
+	// check for overlaps minute by minute, there are only 1440 of them
+	var owners [24 * 60]*BandwidthWindow
+	for _, w := range windows {
+		start, end := clockMinutes(w.Start), clockMinutes(w.End)
+		for m := start; m != end; m = (m + 1) % len(owners) {
+			if other := owners[m]; other != nil {
+				return nil, fmt.Errorf("bandwidth windows %s and %s overlap", other, w)
+			}
+			owners[m] = w
+		}
+	}
+	return windows, nil
+}
+
+// BandwidthPolicy is the policy applied to the traffic with the store.
+type BandwidthPolicy struct {
+	// Windows are the time of day windows with their own policy. Traffic
+	// is not limited outside of them.
+	Windows []*BandwidthWindow
+	// MonthlyBudget is the number of bytes which can be received from the
+	// store in a calendar month, or 0 if unlimited. Once it is exhausted,
+	// downloads are refused until the next month, while other requests
+	// still go through.
+	MonthlyBudget int64
+}
+
+func (p *BandwidthPolicy) window(t time.Time) *BandwidthWindow {
+	if p == nil {
+		return nil
+	}
+	for _, w := range p.Windows {
+		if w.includes(t) {
+			return w
+		}
+	}
+	return nil
+}
+
+// pausedUntil returns when the window pausing downloads at the given time
+// ends, or the zero time if downloads are not paused.
+func (p *BandwidthPolicy) pausedUntil(t time.Time) time.Time {
+	w := p.window(t)
+	if w == nil || !w.Paused {
+		return time.Time{}
+	}
+	return w.end(t)
+}
+
+func (p *BandwidthPolicy) rateLimit(t time.Time) int64 {
+	if w := p.window(t); w != nil {
+		return w.RateLimit
+	}
+	return 0
+}
+
+// BandwidthUsage is the number of bytes received from the store in a month.
+type BandwidthUsage struct {
+	// Month is the month, as in "2026-01".
+	Month string `json:"month"`
+	Bytes int64  `json:"bytes"`
+}
+
+func bandwidthMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// BandwidthBudgetError is returned when downloading from the store is refused
+// since the monthly bandwidth budget is exhausted.
+type BandwidthBudgetError struct {
+	Budget int64
+	Month  string
+}
+
+func (e *BandwidthBudgetError) Error() string {
+	return fmt.Sprintf("cannot download from the store: monthly bandwidth budget of %s exhausted for %s", strutil.SizeToStr(e.Budget), e.Month)
+}
+
+var errDownloadPaused = errors.New("download paused by the bandwidth policy")
+
+// bandwidthMeter tracks and limits the traffic with the store according to
+// the bandwidth policy.
+type bandwidthMeter struct {
+	dauthCtx DeviceAndAuthContext
+
+	mu sync.Mutex
+	// usage is nil until loaded
+	usage   *BandwidthUsage
+	unsaved int64
+	rate    int64
+	bucket  *ratelimit.Bucket
+}
+
+func (m *bandwidthMeter) policy() *BandwidthPolicy {
+	if m == nil || m.dauthCtx == nil {
+		return nil
+	}
+	policy, err := m.dauthCtx.BandwidthPolicy()
+	if err != nil {
+		logger.Noticef("cannot get the store bandwidth policy: %v", err)
+		return nil
+	}
+	return policy
+}
+
+// currentUsage returns the usage for the current month, loading it if
+// needed.
+//
+// The caller must hold the meter lock.
+func (m *bandwidthMeter) currentUsage(now time.Time) *BandwidthUsage {
+	month := bandwidthMonth(now)
+	if m.usage == nil {
+		m.usage = &BandwidthUsage{}
+		if m.dauthCtx != nil {
+			usage, err := m.dauthCtx.BandwidthUsage()
+			if err != nil {
+				logger.Noticef("cannot get the store bandwidth usage: %v", err)
+			} else if usage != nil {
+				m.usage = usage
+			}
+		}
+	}
+	if m.usage.Month != month {
+		m.usage = &BandwidthUsage{Month: month}
+	}
+	return m.usage
+}
+
+// add records that n bytes were received from the store, and returns the
+// usage to save, if it is time to.
+func (m *bandwidthMeter) add(n int64) *BandwidthUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := m.currentUsage(bandwidthTimeNow())
+	usage.Bytes += n
+	m.unsaved += n
+	if metrics.Enabled() {
+		bandwidthUsed.Set(float64(usage.Bytes))
+	}
+	if m.unsaved < bandwidthUsageSaveThreshold {
+		return nil
+	}
+	return m.takeUnsaved()
+}
+
+// takeUnsaved returns a copy of the usage to save if some of it is unsaved.
+//
+// The caller must hold the meter lock.
+func (m *bandwidthMeter) takeUnsaved() *BandwidthUsage {
+	if m.unsaved == 0 || m.usage == nil {
+		return nil
+	}
+	m.unsaved = 0
+	usage := *m.usage
+	return &usage
+}
+
+func (m *bandwidthMeter) save(usage *BandwidthUsage) {
+	if usage == nil || m.dauthCtx == nil {
+		return
+	}
+	if err := m.dauthCtx.SetBandwidthUsage(usage); err != nil {
+		logger.Noticef("cannot save the store bandwidth usage: %v", err)
+	}
+}
+
+func (m *bandwidthMeter) flush() {
+	m.mu.Lock()
+	usage := m.takeUnsaved()
+	m.mu.Unlock()
+	m.save(usage)
+}
+
+// limiter returns the bucket limiting the rate of the traffic with the
+// store, or nil if it is not limited.
+func (m *bandwidthMeter) limiter(rate int64) *ratelimit.Bucket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rate != m.rate {
+		m.rate = rate
+		m.bucket = nil
+		if rate > 0 {
+			m.bucket = ratelimit.NewBucketWithRate(float64(rate), 2*rate)
+		}
+	}
+	return m.bucket
+}
+
+// checkDownload returns errDownloadPaused if downloads are paused, or a
+// BandwidthBudgetError if the monthly budget is exhausted.
+func (m *bandwidthMeter) checkDownload(policy *BandwidthPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	now := bandwidthTimeNow()
+	if policy.MonthlyBudget > 0 {
+		m.mu.Lock()
+		usage := m.currentUsage(now)
+		exhausted := usage.Bytes >= policy.MonthlyBudget
+		m.mu.Unlock()
+		if exhausted {
+			return &BandwidthBudgetError{Budget: policy.MonthlyBudget, Month: usage.Month}
+		}
+	}
+	if !policy.pausedUntil(now).IsZero() {
+		return errDownloadPaused
+	}
+	return nil
+}
+
+// waitWhilePaused blocks while downloads are paused by the bandwidth policy
+// and returns the policy to apply to the download. It returns a
+// BandwidthBudgetError if the monthly budget is exhausted.
+func (m *bandwidthMeter) waitWhilePaused(ctx context.Context, name string) (*BandwidthPolicy, error) {
+	for {
+		policy := m.policy()
+		err := m.checkDownload(policy)
+		if err != errDownloadPaused {
+			return policy, err
+		}
+		now := bandwidthTimeNow()
+		until := policy.pausedUntil(now)
+		logger.Noticef("Download of %q paused by the bandwidth policy until %s.", name, until.Format(time.RFC3339))
+		select {
+		case <-bandwidthAfter(until.Sub(now)):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the download has been cancelled: %s", ctx.Err())
+		}
+	}
+}
+
+// bandwidthReader counts and limits the data received from the store.
+type bandwidthReader struct {
+	io.ReadCloser
+	meter  *bandwidthMeter
+	policy *BandwidthPolicy
+}
+
+func (r *bandwidthReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.meter.save(r.meter.add(int64(n)))
+		if bucket := r.meter.limiter(r.policy.rateLimit(bandwidthTimeNow())); bucket != nil {
+			bucket.Wait(int64(n))
+		}
+	}
+	return n, err
+}
+
+func (r *bandwidthReader) Close() error {
+	r.meter.flush()
+	return r.ReadCloser.Close()
+}
+
+// downloadReader stops a download once it is paused by the bandwidth policy
+// or once the monthly budget is exhausted.
+type downloadReader struct {
+	io.Reader
+	meter  *bandwidthMeter
+	policy *BandwidthPolicy
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	if err := r.meter.checkDownload(r.policy); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// bandwidthTransport applies the bandwidth policy to the responses from the
+// store.
+type bandwidthTransport struct {
+	http.RoundTripper
+	meter *bandwidthMeter
+}
+
+func (t *bandwidthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// downloads carry the policy they already got, so that it is not
+	// retrieved again for each of their responses
+	policy, ok := req.Context().Value(bandwidthPolicyContextKey{}).(*BandwidthPolicy)
+	if !ok {
+		policy = t.meter.policy()
+	}
+	resp.Body = &bandwidthReader{
+		ReadCloser: resp.Body,
+		meter:      t.meter,
+		policy:     policy,
+	}
+	return resp, nil
+}
+
+type bandwidthPolicyContextKey struct{}
+
+// withBandwidthPolicy returns a context carrying the bandwidth policy to
+// apply to the responses of the requests made with it.
+func withBandwidthPolicy(parent context.Context, policy *BandwidthPolicy) context.Context {
+	return context.WithValue(parent, bandwidthPolicyContextKey{}, policy)
+}
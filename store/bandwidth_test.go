@@ -0,0 +1,294 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type bandwidthSuite struct{}
+
+var _ = Suite(&bandwidthSuite{})
+
+func clock(hour, minute int) timeutil.Clock {
+	return timeutil.Clock{Hour: hour, Minute: minute}
+}
+
+func (s *bandwidthSuite) TestParseBandwidthSchedule(c *C) {
+	windows, err := store.ParseBandwidthSchedule("09:00-17:00=256kB, 17:00-18:00=unlimited,22:00-06:00=pause")
+	c.Assert(err, IsNil)
+	c.Check(windows, DeepEquals, []*store.BandwidthWindow{
+		{Start: clock(9, 0), End: clock(17, 0), RateLimit: 256000},
+		{Start: clock(17, 0), End: clock(18, 0)},
+		{Start: clock(22, 0), End: clock(6, 0), Paused: true},
+	})
+
+	windows, err = store.ParseBandwidthSchedule("")
+	c.Assert(err, IsNil)
+	c.Check(windows, HasLen, 0)
+}
+
+func (s *bandwidthSuite) TestParseBandwidthScheduleErrors(c *C) {
+	for _, t := range []struct {
+		schedule string
+		err      string
+	}{
+		{"09:00-17:00", `cannot parse bandwidth window "09:00-17:00": missing policy`},
+		{"09:00=1MB", `cannot parse bandwidth window "09:00=1MB": invalid time span "09:00"`},
+		{"9-17:00=1MB", `cannot parse bandwidth window "9-17:00=1MB": .*`},
+		{"09:00-09:00=1MB", `cannot parse bandwidth window "09:00-09:00=1MB": empty time span`},
+		{"09:00-17:00=fast", `cannot parse bandwidth window "09:00-17:00=fast": cannot parse "fast": .*`},
+		{"09:00-17:00=0B", `cannot parse bandwidth window "09:00-17:00=0B": rate limit must be positive`},
+		{"09:00-17:00=1MB,16:00-18:00=pause", `bandwidth windows 09:00-17:00=1000000 and 16:00-18:00=pause overlap`},
+		{"22:00-06:00=1MB,05:00-07:00=pause", `bandwidth windows 22:00-06:00=1000000 and 05:00-07:00=pause overlap`},
+	} {
+		_, err := store.ParseBandwidthSchedule(t.schedule)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.schedule))
+	}
+}
+
+func (s *bandwidthSuite) TestBandwidthPolicyWindow(c *C) {
+	windows, err := store.ParseBandwidthSchedule("09:00-17:00=1MB,22:00-06:00=pause")
+	c.Assert(err, IsNil)
+	policy := &store.BandwidthPolicy{Windows: windows}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 10, hour, minute, 0, 0, time.Local)
+	}
+	c.Check(policy.Window(at(8, 59)), IsNil)
+	c.Check(policy.Window(at(9, 0)), Equals, windows[0])
+	c.Check(policy.Window(at(16, 59)), Equals, windows[0])
+	c.Check(policy.Window(at(17, 0)), IsNil)
+	c.Check(policy.Window(at(23, 0)), Equals, windows[1])
+	c.Check(policy.Window(at(0, 30)), Equals, windows[1])
+	c.Check(policy.Window(at(6, 0)), IsNil)
+
+	c.Check(policy.PausedUntil(at(12, 0)).IsZero(), Equals, true)
+	c.Check(policy.PausedUntil(at(23, 0)), DeepEquals, time.Date(2026, 3, 11, 6, 0, 0, 0, time.Local))
+	c.Check(policy.PausedUntil(at(1, 0)), DeepEquals, at(6, 0))
+
+	var noPolicy *store.BandwidthPolicy
+	c.Check(noPolicy.Window(at(12, 0)), IsNil)
+}
+
+func (s *bandwidthSuite) TestBandwidthBudgetError(c *C) {
+	err := &store.BandwidthBudgetError{Budget: 10 * 1000 * 1000 * 1000, Month: "2026-03"}
+	c.Check(err, ErrorMatches, "cannot download from the store: monthly bandwidth budget of 10GB exhausted for 2026-03")
+}
+
+func serveWithRange(c *C, content []byte, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		*requests = append(*requests, rng)
+		if rng == "" {
+			w.Write(content)
+			return
+		}
+		var offset int
+		_, err := fmt.Sscanf(rng, "bytes=%d-", &offset)
+		c.Assert(err, IsNil)
+		w.WriteHeader(206)
+		w.Write(content[offset:])
+	}))
+}
+
+func sha3_384(content []byte) string {
+	h := crypto.SHA3_384.New()
+	h.Write(content)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (s *storeDownloadSuite) TestDownloadWaitsWhilePaused(c *C) {
+	content := []byte("some snap content")
+	var requests []string
+	mockServer := serveWithRange(c, content, &requests)
+	defer mockServer.Close()
+
+	windows, err := store.ParseBandwidthSchedule("22:00-06:00=pause")
+	c.Assert(err, IsNil)
+	dauthCtx := &testDauthContext{c: c, device: s.device, bandwidthPolicy: &store.BandwidthPolicy{Windows: windows}}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, time.Local)
+	restore := store.MockBandwidthTimeNow(func() time.Time { return now })
+	defer restore()
+	var waited []time.Duration
+	restore = store.MockBandwidthAfter(func(d time.Duration) <-chan time.Time {
+		waited = append(waited, d)
+		now = now.Add(d)
+		ch := make(chan time.Time, 1)
+		ch <- now
+		return ch
+	})
+	defer restore()
+
+	info := &snap.Info{}
+	info.DownloadURL = mockServer.URL
+	info.Sha3_384 = sha3_384(content)
+	info.Size = int64(len(content))
+
+	path := filepath.Join(c.MkDir(), "foo_1.snap")
+	err = sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(waited, DeepEquals, []time.Duration{7 * time.Hour})
+	c.Check(requests, DeepEquals, []string{""})
+	c.Check(s.logbuf.String(), testutil.Contains, `Download of "foo" paused by the bandwidth policy until 2026-03-11T06:00:00`)
+}
+
+func (s *storeDownloadSuite) TestDownloadPausedAndResumed(c *C) {
+	content := make([]byte, 50000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	var requests []string
+	mockServer := serveWithRange(c, content, &requests)
+	defer mockServer.Close()
+
+	windows, err := store.ParseBandwidthSchedule("10:00-11:00=pause")
+	c.Assert(err, IsNil)
+	dauthCtx := &testDauthContext{c: c, device: s.device, bandwidthPolicy: &store.BandwidthPolicy{Windows: windows}}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	// save the usage on every read to know how much was downloaded
+	restore := store.MockBandwidthUsageSaveThreshold(1)
+	defer restore()
+	// the pause window starts once half of the snap was downloaded
+	var mu sync.Mutex
+	resumed := false
+	restore = store.MockBandwidthTimeNow(func() time.Time {
+		usage, _ := dauthCtx.BandwidthUsage()
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case resumed:
+			return time.Date(2026, 3, 10, 11, 0, 0, 0, time.Local)
+		case usage != nil && usage.Bytes >= int64(len(content)/2):
+			return time.Date(2026, 3, 10, 10, 0, 0, 0, time.Local)
+		default:
+			return time.Date(2026, 3, 10, 9, 59, 0, 0, time.Local)
+		}
+	})
+	defer restore()
+	restore = store.MockBandwidthAfter(func(d time.Duration) <-chan time.Time {
+		c.Check(d, Equals, time.Hour)
+		mu.Lock()
+		defer mu.Unlock()
+		resumed = true
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	})
+	defer restore()
+
+	info := &snap.Info{}
+	info.DownloadURL = mockServer.URL
+	info.Sha3_384 = sha3_384(content)
+	info.Size = int64(len(content))
+
+	path := filepath.Join(c.MkDir(), "foo_1.snap")
+	err = sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, content)
+	c.Assert(requests, HasLen, 2)
+	c.Check(requests[0], Equals, "")
+	c.Check(requests[1], Matches, "bytes=[0-9]+-")
+	c.Check(requests[1], Not(Equals), "bytes=0-")
+}
+
+func (s *storeDownloadSuite) TestDownloadBudgetExhausted(c *C) {
+	var requests []string
+	mockServer := serveWithRange(c, []byte("content"), &requests)
+	defer mockServer.Close()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	restore := store.MockBandwidthTimeNow(func() time.Time { return now })
+	defer restore()
+
+	dauthCtx := &testDauthContext{
+		c:               c,
+		device:          s.device,
+		bandwidthPolicy: &store.BandwidthPolicy{MonthlyBudget: 1000},
+		bandwidthUsage:  &store.BandwidthUsage{Month: "2026-03", Bytes: 1000},
+	}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	info := &snap.Info{}
+	info.DownloadURL = mockServer.URL
+	info.Sha3_384 = sha3_384([]byte("content"))
+	info.Size = int64(len("content"))
+
+	path := filepath.Join(c.MkDir(), "foo_1.snap")
+	err := sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, ErrorMatches, "cannot download from the store: monthly bandwidth budget of 1kB exhausted for 2026-03")
+	c.Check(err, FitsTypeOf, &store.BandwidthBudgetError{})
+	c.Check(requests, HasLen, 0)
+
+	// the budget is renewed the next month
+	now = time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)
+	err = sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(requests, HasLen, 1)
+	c.Check(dauthCtx.bandwidthUsage, DeepEquals, &store.BandwidthUsage{Month: "2026-04", Bytes: int64(len("content"))})
+}
+
+func (s *storeDownloadSuite) TestDownloadCountsBandwidthUsage(c *C) {
+	content := []byte("some snap content")
+	var requests []string
+	mockServer := serveWithRange(c, content, &requests)
+	defer mockServer.Close()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	restore := store.MockBandwidthTimeNow(func() time.Time { return now })
+	defer restore()
+
+	dauthCtx := &testDauthContext{
+		c:              c,
+		device:         s.device,
+		bandwidthUsage: &store.BandwidthUsage{Month: "2026-03", Bytes: 100},
+	}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	info := &snap.Info{}
+	info.DownloadURL = mockServer.URL
+	info.Sha3_384 = sha3_384(content)
+	info.Size = int64(len(content))
+
+	path := filepath.Join(c.MkDir(), "foo_1.snap")
+	err := sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(dauthCtx.bandwidthUsage, DeepEquals, &store.BandwidthUsage{Month: "2026-03", Bytes: 100 + int64(len(content))})
+	// the policy is retrieved once for the download, not again for its
+	// response
+	c.Check(dauthCtx.bandwidthPolicyCalls, Equals, 1)
+}
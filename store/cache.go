@@ -20,6 +20,7 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -32,6 +33,8 @@ import (
 
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/store/lanshare"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
 )
@@ -104,6 +107,11 @@ type CacheManager struct {
 	cleanupLock sync.RWMutex
 	cacheDir    string
 	cachePolicy CachePolicy
+
+	// deltaLock is held while generating a delta, lastDelta is when the
+	// last generation started
+	deltaLock sync.Mutex
+	lastDelta time.Time
 }
 
 // NewCacheManager returns a new CacheManager with the given cacheDir and the
@@ -195,6 +203,92 @@ func (cm *CacheManager) count() int {
 	return 0
 }
 
+// DeltaCacheKey returns the cache key of the delta in the given format from
+// the blob with the sourceDigest SHA3-384 digest to the one with targetDigest.
+func DeltaCacheKey(format, sourceDigest, targetDigest string) string {
+	return fmt.Sprintf("delta_%s_%s_%s", format, sourceDigest, targetDigest)
+}
+
+var (
+	squashfsGenerateDelta = squashfs.GenerateDelta
+
+	// deltaGenerationInterval is the minimum time between the start of two
+	// delta generations
+	deltaGenerationInterval = time.Minute
+	// deltaGenerationTimeout is the maximum time generating a delta can take
+	deltaGenerationTimeout = 5 * time.Minute
+)
+
+// ErrDeltaBusy is returned by DeltaPath when a delta cannot be generated
+// right now, as another one is being generated or one was generated too
+// recently.
+var ErrDeltaBusy = fmt.Errorf("cannot generate delta: %w", lanshare.ErrBusy)
+
+// DeltaPath returns the path of the delta in the given format from the blob
+// with the sourceDigest SHA3-384 digest to the one with targetDigest. The
+// delta is generated from the cached blobs and added to the cache if it is
+// not there yet. An error wrapping fs.ErrNotExist is returned if either blob
+// is missing from the cache.
+//
+// Deltas are generated one at a time, at most once per
+// deltaGenerationInterval, and ErrDeltaBusy is returned instead of waiting
+// for that.
+func (cm *CacheManager) DeltaPath(ctx context.Context, format, sourceDigest, targetDigest string) (string, error) {
+	deltaFormat, err := squashfs.DeltaFormatFromString(format)
+	if err != nil {
+		return "", err
+	}
+	key := DeltaCacheKey(format, sourceDigest, targetDigest)
+	if p := cm.GetPath(key); p != "" {
+		return p, nil
+	}
+
+	// generating deltas is expensive, do not queue requests for it
+	if !cm.deltaLock.TryLock() {
+		return "", ErrDeltaBusy
+	}
+	defer cm.deltaLock.Unlock()
+
+	// it may have been generated meanwhile
+	if p := cm.GetPath(key); p != "" {
+		return p, nil
+	}
+	sourcePath := cm.GetPath(sourceDigest)
+	if sourcePath == "" {
+		return "", fmt.Errorf("cannot generate delta: source %w", fs.ErrNotExist)
+	}
+	targetPath := cm.GetPath(targetDigest)
+	if targetPath == "" {
+		return "", fmt.Errorf("cannot generate delta: target %w", fs.ErrNotExist)
+	}
+
+	now := time.Now()
+	if !cm.lastDelta.IsZero() && now.Sub(cm.lastDelta) < deltaGenerationInterval {
+		return "", ErrDeltaBusy
+	}
+	cm.lastDelta = now
+
+	// generate next to the cache so that the delta can be linked into it
+	tmpDir, err := os.MkdirTemp(cm.cacheDir, ".delta-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	deltaPath := filepath.Join(tmpDir, key)
+	ctx, cancel := context.WithTimeout(ctx, deltaGenerationTimeout)
+	defer cancel()
+	if err := squashfsGenerateDelta(ctx, sourcePath, targetPath, deltaPath, deltaFormat); err != nil {
+		return "", fmt.Errorf("cannot generate delta: %v", err)
+	}
+	if err := cm.Put(key, deltaPath); err != nil {
+		return "", err
+	}
+	if p := cm.GetPath(key); p != "" {
+		return p, nil
+	}
+	return "", fmt.Errorf("cannot generate delta: delta %w", fs.ErrNotExist)
+}
+
 // path returns the full path of the given content in the cache
 func (cm *CacheManager) path(cacheKey string) string {
 	return filepath.Join(cm.cacheDir, cacheKey)
@@ -20,8 +20,10 @@
 package store_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -31,6 +33,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -869,3 +872,108 @@ func (s *cachePolicySuite) TestApplyContinuesOnError(c *C) {
 	c.Check(removedCount, Equals, 1)
 	c.Check(removedSize, Equals, uint64(100))
 }
+
+func (s *cacheSuite) TestDeltaPath(c *C) {
+	c.Assert(s.cm.Put("source-digest", s.makeTestFile(c, "source", "rev 1")), IsNil)
+	c.Assert(s.cm.Put("target-digest", s.makeTestFile(c, "target", "rev 2")), IsNil)
+
+	var generated []string
+	restore := store.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, delta string, deltaFormat squashfs.DeltaFormat) error {
+		generated = append(generated, fmt.Sprintf("%s:%s:%s", filepath.Base(sourceSnap), filepath.Base(targetSnap), deltaFormat))
+		return os.WriteFile(delta, []byte("delta"), 0644)
+	})
+	defer restore()
+
+	p, err := s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	c.Assert(err, IsNil)
+	key := store.DeltaCacheKey("xdelta3", "source-digest", "target-digest")
+	c.Check(key, Equals, "delta_xdelta3_source-digest_target-digest")
+	c.Check(p, Equals, filepath.Join(s.cm.CacheDir(), key))
+	c.Check(p, testutil.FileEquals, "delta")
+	c.Check(generated, DeepEquals, []string{"source-digest:target-digest:xdelta3"})
+
+	// the delta is generated only once
+	p, err = s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, filepath.Join(s.cm.CacheDir(), key))
+	c.Check(generated, HasLen, 1)
+
+	// and nothing is left behind
+	entries, err := os.ReadDir(s.cm.CacheDir())
+	c.Assert(err, IsNil)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	c.Check(names, DeepEquals, []string{key, "source-digest", "target-digest"})
+}
+
+func (s *cacheSuite) TestDeltaPathErrors(c *C) {
+	c.Assert(s.cm.Put("source-digest", s.makeTestFile(c, "source", "rev 1")), IsNil)
+	restore := store.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, delta string, deltaFormat squashfs.DeltaFormat) error {
+		return errors.New("boom")
+	})
+	defer restore()
+
+	_, err := s.cm.DeltaPath(context.Background(), "bsdiff", "source-digest", "target-digest")
+	c.Check(err, ErrorMatches, `unsupported delta format "bsdiff"`)
+
+	_, err = s.cm.DeltaPath(context.Background(), "xdelta3", "missing-digest", "source-digest")
+	c.Check(err, ErrorMatches, "cannot generate delta: source file does not exist")
+	c.Check(errors.Is(err, fs.ErrNotExist), Equals, true)
+	_, err = s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "missing-digest")
+	c.Check(err, ErrorMatches, "cannot generate delta: target file does not exist")
+	c.Check(errors.Is(err, fs.ErrNotExist), Equals, true)
+
+	c.Assert(s.cm.Put("target-digest", s.makeTestFile(c, "target", "rev 2")), IsNil)
+	_, err = s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	c.Check(err, ErrorMatches, "cannot generate delta: boom")
+	c.Check(errors.Is(err, fs.ErrNotExist), Equals, false)
+	entries, err := os.ReadDir(s.cm.CacheDir())
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 2)
+}
+
+func (s *cacheSuite) TestDeltaPathBusy(c *C) {
+	restore := store.MockDeltaGenerationInterval(time.Hour)
+	defer restore()
+	restore = store.MockDeltaGenerationTimeout(time.Minute)
+	defer restore()
+	var generated []string
+	restore = store.MockSquashfsGenerateDelta(func(ctx context.Context, sourceSnap, targetSnap, delta string, deltaFormat squashfs.DeltaFormat) error {
+		// generation is bounded in time
+		deadline, ok := ctx.Deadline()
+		c.Check(ok, Equals, true)
+		c.Check(time.Until(deadline) <= time.Minute, Equals, true)
+		generated = append(generated, filepath.Base(targetSnap))
+		return os.WriteFile(delta, []byte("delta"), 0644)
+	})
+	defer restore()
+
+	for _, key := range []string{"source-digest", "target-digest", "other-digest"} {
+		c.Assert(s.cm.Put(key, s.makeTestFile(c, key, key)), IsNil)
+	}
+
+	// requests do not wait for another delta being generated
+	s.cm.DeltaLock().Lock()
+	_, err := s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	s.cm.DeltaLock().Unlock()
+	c.Check(err, Equals, store.ErrDeltaBusy)
+	c.Check(generated, HasLen, 0)
+
+	p, err := s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	c.Assert(err, IsNil)
+	c.Check(generated, DeepEquals, []string{"target-digest"})
+
+	// deltas are not generated again too soon
+	_, err = s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "other-digest")
+	c.Check(err, Equals, store.ErrDeltaBusy)
+	c.Check(generated, HasLen, 1)
+
+	// but existing ones are always provided
+	s.cm.DeltaLock().Lock()
+	defer s.cm.DeltaLock().Unlock()
+	p2, err := s.cm.DeltaPath(context.Background(), "xdelta3", "source-digest", "target-digest")
+	c.Assert(err, IsNil)
+	c.Check(p2, Equals, p)
+}
@@ -51,6 +51,20 @@ type DeviceAndAuthContext interface {
 
 	// WithSnapStoreDelta returns whether snap store delta format experimental flag is set or not.
 	WithSnapStoreDelta() bool
+
+	// LANPeers returns the addresses of the peers on the local network to
+	// fetch snaps and components from before the store, if sharing them
+	// is enabled.
+	LANPeers() ([]string, error)
+
+	// BandwidthPolicy returns the policy to apply to the traffic with the
+	// store, or nil if there is none.
+	BandwidthPolicy() (*BandwidthPolicy, error)
+	// BandwidthUsage returns the recorded usage of the store bandwidth,
+	// or nil if none was recorded yet.
+	BandwidthUsage() (*BandwidthUsage, error)
+	// SetBandwidthUsage records the usage of the store bandwidth.
+	SetBandwidthUsage(usage *BandwidthUsage) error
 }
 
 // DeviceSessionRequestParams gathers the assertions and information to be sent to request a device session.
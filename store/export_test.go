@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"net/url"
 	"os/exec"
+	"sync"
 	"time"
 
 	"github.com/juju/ratelimit"
@@ -276,3 +277,39 @@ type (
 )
 
 var ReportFetchAssertionsError = reportFetchAssertionsError
+
+func MockBandwidthTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&bandwidthTimeNow, f)
+}
+
+func MockBandwidthAfter(f func(d time.Duration) <-chan time.Time) (restore func()) {
+	return testutil.Mock(&bandwidthAfter, f)
+}
+
+func MockBandwidthUsageSaveThreshold(n int64) (restore func()) {
+	return testutil.Mock(&bandwidthUsageSaveThreshold, n)
+}
+
+func (p *BandwidthPolicy) Window(t time.Time) *BandwidthWindow {
+	return p.window(t)
+}
+
+func (p *BandwidthPolicy) PausedUntil(t time.Time) time.Time {
+	return p.pausedUntil(t)
+}
+
+func MockSquashfsGenerateDelta(f func(ctx context.Context, sourceSnap, targetSnap, delta string, deltaFormat squashfs.DeltaFormat) error) (restore func()) {
+	return testutil.Mock(&squashfsGenerateDelta, f)
+}
+
+func MockDeltaGenerationInterval(d time.Duration) (restore func()) {
+	return testutil.Mock(&deltaGenerationInterval, d)
+}
+
+func MockDeltaGenerationTimeout(d time.Duration) (restore func()) {
+	return testutil.Mock(&deltaGenerationTimeout, d)
+}
+
+func (cm *CacheManager) DeltaLock() *sync.Mutex {
+	return &cm.deltaLock
+}
@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lanshare
+
+import (
+	"net"
+	"time"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
+var (
+	BrowseQuery    = browseQuery
+	Announcement   = announcement
+	ParseMessage   = parseMessage
+	AnnouncedPeers = announcedPeers
+	FetchTimeout   = fetchTimeout
+)
+
+func (r *Responder) Reply(query []byte) []byte {
+	return r.reply(query)
+}
+
+func MockListenMulticastUDP(f func(network string, ifi *net.Interface, gaddr *net.UDPAddr) (*net.UDPConn, error)) (restore func()) {
+	return testutil.Mock(&listenMulticastUDP, f)
+}
+
+func MockListenUDP(f func(network string, laddr *net.UDPAddr) (*net.UDPConn, error)) (restore func()) {
+	return testutil.Mock(&listenUDP, f)
+}
+
+func MockTransferTimeouts(responseHeader time.Duration, minRate int64) (restore func()) {
+	restore1 := testutil.Mock(&responseHeaderTimeout, responseHeader)
+	restore2 := testutil.Mock(&minTransferRate, minRate)
+	return func() {
+		restore2()
+		restore1()
+	}
+}
+
+func MockInterfaceByName(f func(name string) (*net.Interface, error)) (restore func()) {
+	return testutil.Mock(&interfaceByName, f)
+}
+
+func MockMDNSAddr(addr *net.UDPAddr) (restore func()) {
+	return testutil.Mock(&mdnsAddr, addr)
+}
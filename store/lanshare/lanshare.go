@@ -0,0 +1,415 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package lanshare shares the snaps and components in the download cache of
+// snapd with peers on the same local network, so that devices can fetch
+// blobs from each other before falling back to the store.
+//
+// Blobs fetched from peers are always verified against their expected
+// SHA3-384 digest, as taken from the snap-revision or snap-resource-revision
+// assertions, so sharing does not change what is trusted.
+package lanshare
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "golang.org/x/crypto/sha3"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// DefaultPort is the TCP port on which blobs are shared, unless another one
+// is given for a peer.
+const DefaultPort = 44747
+
+const (
+	blobsPath  = "/v1/blobs"
+	deltasPath = "/v1/deltas"
+)
+
+var (
+	connectTimeout        = 5 * time.Second
+	responseHeaderTimeout = 10 * time.Second
+	// transfers from peers are given as long as this minimum rate
+	// allows, so that stalled peers are given up on
+	minTransferRate int64 = 256 * 1024 // bytes per second
+	// maxUnknownSizeFetchTime bounds the transfers of unknown size
+	maxUnknownSizeFetchTime = 30 * time.Minute
+
+	// retryBusyAfter is when peers are told to retry when deltas cannot
+	// be generated right now
+	retryBusyAfter = time.Minute
+)
+
+// Cache is the download cache whose blobs are shared.
+type Cache interface {
+	// GetPath returns the path of the blob with the given cache key.
+	GetPath(cacheKey string) string
+}
+
+// DeltaCache is a Cache which can also provide deltas between its blobs.
+type DeltaCache interface {
+	Cache
+	// DeltaPath returns the path of the delta in the given format from
+	// the blob with sourceDigest to the one with targetDigest, generating
+	// it if needed. It returns an error wrapping fs.ErrNotExist if either
+	// blob is not available, or one wrapping ErrBusy if the delta cannot be
+	// generated right now.
+	DeltaPath(ctx context.Context, format, sourceDigest, targetDigest string) (string, error)
+}
+
+// ErrBusy is returned, possibly wrapped, by DeltaCache.DeltaPath when a delta
+// cannot be generated right now.
+var ErrBusy = errors.New("busy")
+
+// validDigest returns whether the given string is a hex encoded SHA3-384
+// digest, as used for cache keys.
+func validDigest(digest string) bool {
+	if len(digest) != 2*crypto.SHA3_384.Size() {
+		return false
+	}
+	for _, r := range digest {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewHandler returns an http.Handler serving the blobs from the given cache.
+//
+// GET /v1/blobs/<sha3-384> returns the blob with that digest. The content of
+// the cache is not listed, peers need to know the digest of what they ask
+// for.
+//
+// If the cache is a DeltaCache, GET /v1/deltas/<format>/<source>/<target>
+// also returns the delta in the given format between the blobs with the
+// source and target digests, or 503 if the cache is too busy to generate it.
+func NewHandler(cache Cache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(blobsPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		digest := strings.TrimPrefix(r.URL.Path, blobsPath+"/")
+		if !validDigest(digest) {
+			http.NotFound(w, r)
+			return
+		}
+		serveFile(w, r, cache.GetPath(digest))
+	})
+	if deltaCache, ok := cache.(DeltaCache); ok {
+		mux.HandleFunc(deltasPath+"/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			parts := strings.Split(strings.TrimPrefix(r.URL.Path, deltasPath+"/"), "/")
+			if len(parts) != 3 || parts[0] == "" || !validDigest(parts[1]) || !validDigest(parts[2]) {
+				http.NotFound(w, r)
+				return
+			}
+			path, err := deltaCache.DeltaPath(r.Context(), parts[0], parts[1], parts[2])
+			if errors.Is(err, ErrBusy) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryBusyAfter/time.Second)))
+				http.Error(w, "busy generating deltas", http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					logger.Noticef("cannot provide shared delta: %v", err)
+				}
+				http.NotFound(w, r)
+				return
+			}
+			serveFile(w, r, path)
+		})
+	}
+	return mux
+}
+
+// AllowClients returns an http.Handler passing the requests of the clients
+// whose IP address is accepted by allowed to h, and refusing the others.
+func AllowClients(h http.Handler, allowed func(ip net.IP) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		// drop the zone of link-local IPv6 addresses
+		if i := strings.IndexByte(host, '%'); i >= 0 {
+			host = host[:i]
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !allowed(ip) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// PeerAddress returns the address of a peer given as a host with an optional
+// port, using DefaultPort if none is given.
+func PeerAddress(peer string) (string, error) {
+	if peer == "" {
+		return "", errors.New("empty peer address")
+	}
+	host, port, err := net.SplitHostPort(peer)
+	if err != nil {
+		// no port
+		if strings.Contains(peer, "/") {
+			return "", fmt.Errorf("invalid peer address %q", peer)
+		}
+		return net.JoinHostPort(strings.Trim(peer, "[]"), strconv.Itoa(DefaultPort)), nil
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid peer address %q: missing host", peer)
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return "", fmt.Errorf("invalid peer address %q: invalid port", peer)
+	}
+	return peer, nil
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			// peers are on the local network, never go through a proxy
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: connectTimeout,
+			}).DialContext,
+			ResponseHeaderTimeout: responseHeaderTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errors.New("redirects are not followed")
+		},
+	}
+}
+
+// ErrNotFound is returned by Fetch when none of the peers had the blob.
+var ErrNotFound = errors.New("no peer has the blob")
+
+// get requests the given path from the peer. ErrNotFound is returned if the
+// peer does not have it, or cannot provide it right now.
+func get(ctx context.Context, cli *http.Client, peer, path string) (*http.Response, error) {
+	url := fmt.Sprintf("http://%s%s", peer, path)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return rsp, nil
+	case http.StatusNotFound, http.StatusServiceUnavailable:
+		rsp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		rsp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q", rsp.Status)
+	}
+}
+
+// fetchTimeout returns how long fetching a blob of the given size from a
+// peer may take overall.
+func fetchTimeout(size int64) time.Duration {
+	if size <= 0 {
+		return maxUnknownSizeFetchTime
+	}
+	return responseHeaderTimeout + time.Duration(size/minTransferRate)*time.Second
+}
+
+// fetchFrom downloads the blob from the given peer into w, checking its size
+// and digest.
+func fetchFrom(ctx context.Context, cli *http.Client, peer, digest string, size int64, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout(size))
+	defer cancel()
+	rsp, err := get(ctx, cli, peer, blobsPath+"/"+digest)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if size > 0 && rsp.ContentLength >= 0 && rsp.ContentLength != size {
+		return fmt.Errorf("unexpected size %d, expected %d", rsp.ContentLength, size)
+	}
+
+	h := crypto.SHA3_384.New()
+	// do not read more than expected from peers
+	body := io.Reader(rsp.Body)
+	if size > 0 {
+		body = io.LimitReader(rsp.Body, size+1)
+	}
+	n, err := io.Copy(io.MultiWriter(w, h), body)
+	if err != nil {
+		return err
+	}
+	if size > 0 && n != size {
+		return fmt.Errorf("unexpected size %d, expected %d", n, size)
+	}
+	if actual := fmt.Sprintf("%x", h.Sum(nil)); actual != digest {
+		return fmt.Errorf("sha3-384 mismatch: got %s but expected %s", actual, digest)
+	}
+	return nil
+}
+
+// Fetch downloads the blob with the given SHA3-384 digest and size, if
+// known, from the first of the given peers which has it, and places it at
+// targetPath. It returns the peer the blob was fetched from.
+//
+// Blobs which do not match the digest are discarded and the next peer is
+// tried. ErrNotFound is returned if no peer had the blob.
+func Fetch(ctx context.Context, peers []string, digest string, size int64, targetPath string) (peer string, err error) {
+	if !validDigest(digest) {
+		return "", fmt.Errorf("invalid sha3-384 digest %q", digest)
+	}
+	cli := newHTTPClient()
+	var errs []string
+	for _, peer := range peers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		f, err := osutil.NewAtomicFile(targetPath, 0600, 0, osutil.NoChown, osutil.NoChown)
+		if err != nil {
+			return "", err
+		}
+		err = fetchFrom(ctx, cli, peer, digest, size, f)
+		if err == nil {
+			if err := f.Commit(); err != nil {
+				return "", err
+			}
+			return peer, nil
+		}
+		f.Cancel()
+		if err != ErrNotFound {
+			errs = append(errs, fmt.Sprintf("%s: %v", peer, err))
+		}
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("cannot fetch blob from peers: %s", strings.Join(errs, "; "))
+	}
+	return "", ErrNotFound
+}
+
+// fetchDeltaFrom downloads the delta from the given peer into w, refusing
+// deltas larger than maxSize.
+func fetchDeltaFrom(ctx context.Context, cli *http.Client, peer, format, sourceDigest, targetDigest string, maxSize int64, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout(maxSize))
+	defer cancel()
+	rsp, err := get(ctx, cli, peer, fmt.Sprintf("%s/%s/%s/%s", deltasPath, format, sourceDigest, targetDigest))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if maxSize > 0 && rsp.ContentLength > maxSize {
+		return fmt.Errorf("delta of size %d is larger than %d", rsp.ContentLength, maxSize)
+	}
+	body := io.Reader(rsp.Body)
+	if maxSize > 0 {
+		body = io.LimitReader(rsp.Body, maxSize+1)
+	}
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return err
+	}
+	if maxSize > 0 && n > maxSize {
+		return fmt.Errorf("delta is larger than %d", maxSize)
+	}
+	return nil
+}
+
+// FetchDelta downloads the delta in the given format from the blob with
+// sourceDigest to the one with targetDigest from the first of the given
+// peers which can provide it, and places it at targetPath. Deltas larger
+// than maxSize, if set, are refused. It returns the peer the delta was
+// fetched from.
+//
+// Deltas cannot be verified by themselves, the blob they are applied to
+// must be verified against targetDigest instead. ErrNotFound is returned if
+// no peer could provide the delta.
+func FetchDelta(ctx context.Context, peers []string, format, sourceDigest, targetDigest string, maxSize int64, targetPath string) (peer string, err error) {
+	if !validDigest(sourceDigest) {
+		return "", fmt.Errorf("invalid sha3-384 digest %q", sourceDigest)
+	}
+	if !validDigest(targetDigest) {
+		return "", fmt.Errorf("invalid sha3-384 digest %q", targetDigest)
+	}
+	if format == "" || strings.Contains(format, "/") {
+		return "", fmt.Errorf("invalid delta format %q", format)
+	}
+	cli := newHTTPClient()
+	var errs []string
+	for _, peer := range peers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		f, err := osutil.NewAtomicFile(targetPath, 0600, 0, osutil.NoChown, osutil.NoChown)
+		if err != nil {
+			return "", err
+		}
+		err = fetchDeltaFrom(ctx, cli, peer, format, sourceDigest, targetDigest, maxSize, f)
+		if err == nil {
+			if err := f.Commit(); err != nil {
+				return "", err
+			}
+			return peer, nil
+		}
+		f.Cancel()
+		if err != ErrNotFound {
+			errs = append(errs, fmt.Sprintf("%s: %v", peer, err))
+		}
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("cannot fetch delta from peers: %s", strings.Join(errs, "; "))
+	}
+	return "", ErrNotFound
+}
@@ -0,0 +1,394 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lanshare_test
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/store/lanshare"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type lanshareSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&lanshareSuite{})
+
+type dirCache string
+
+func (d dirCache) GetPath(cacheKey string) string {
+	return filepath.Join(string(d), cacheKey)
+}
+
+func digest(data string) string {
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(data))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// newPeer returns a stand-in peer sharing the given blobs, by digest.
+func (s *lanshareSuite) newPeer(c *C, blobs map[string]string) (peer string, cache dirCache) {
+	cache = dirCache(c.MkDir())
+	for key, data := range blobs {
+		c.Assert(os.WriteFile(cache.GetPath(key), []byte(data), 0644), IsNil)
+	}
+	srv := httptest.NewServer(lanshare.NewHandler(cache))
+	s.AddCleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://"), cache
+}
+
+func (s *lanshareSuite) TestHandlerNoList(c *C) {
+	data := "snap data"
+	peer, _ := s.newPeer(c, map[string]string{digest(data): data})
+
+	// the content of the cache is not listed
+	for _, path := range []string{"/v1/blobs", "/v1/blobs/"} {
+		rsp, err := http.Get("http://" + peer + path)
+		c.Assert(err, IsNil)
+		rsp.Body.Close()
+		c.Check(rsp.StatusCode, Equals, 404, Commentf(path))
+	}
+}
+
+func (s *lanshareSuite) TestAllowClients(c *C) {
+	data := "snap data"
+	cache := dirCache(c.MkDir())
+	c.Assert(os.WriteFile(cache.GetPath(digest(data)), []byte(data), 0644), IsNil)
+
+	var allow bool
+	var clients []string
+	srv := httptest.NewServer(lanshare.AllowClients(lanshare.NewHandler(cache), func(ip net.IP) bool {
+		clients = append(clients, ip.String())
+		return allow
+	}))
+	defer srv.Close()
+
+	rsp, err := http.Get(srv.URL + "/v1/blobs/" + digest(data))
+	c.Assert(err, IsNil)
+	rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 403)
+
+	allow = true
+	rsp, err = http.Get(srv.URL + "/v1/blobs/" + digest(data))
+	c.Assert(err, IsNil)
+	body, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(rsp.StatusCode, Equals, 200)
+	c.Check(string(body), Equals, data)
+
+	c.Check(clients, DeepEquals, []string{"127.0.0.1", "127.0.0.1"})
+}
+
+func (s *lanshareSuite) TestHandlerBlob(c *C) {
+	data := "snap data"
+	peer, _ := s.newPeer(c, map[string]string{digest(data): data})
+
+	rsp, err := http.Get("http://" + peer + "/v1/blobs/" + digest(data))
+	c.Assert(err, IsNil)
+	defer rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 200)
+	body, err := io.ReadAll(rsp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Equals, data)
+
+	for _, path := range []string{
+		"/v1/blobs/" + digest("other"),
+		"/v1/blobs/../../etc/passwd",
+		"/v1/blobs/foo",
+	} {
+		rsp, err := http.Get("http://" + peer + path)
+		c.Assert(err, IsNil)
+		rsp.Body.Close()
+		c.Check(rsp.StatusCode, Equals, 404, Commentf(path))
+	}
+
+	rsp, err = http.Post("http://"+peer+"/v1/blobs/"+digest(data), "text/plain", nil)
+	c.Assert(err, IsNil)
+	rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 405)
+}
+
+func (s *lanshareSuite) TestPeerAddress(c *C) {
+	for _, t := range []struct {
+		peer, addr, err string
+	}{
+		{"10.0.0.1", "10.0.0.1:44747", ""},
+		{"10.0.0.1:8000", "10.0.0.1:8000", ""},
+		{"device-1.local", "device-1.local:44747", ""},
+		{"[fe80::1]:8000", "[fe80::1]:8000", ""},
+		{"fe80::1", "[fe80::1]:44747", ""},
+		{"", "", "empty peer address"},
+		{":8000", "", `invalid peer address ":8000": missing host`},
+		{"10.0.0.1:0", "", `invalid peer address "10.0.0.1:0": invalid port`},
+		{"http://10.0.0.1", "", `invalid peer address "http://10.0.0.1".*`},
+	} {
+		addr, err := lanshare.PeerAddress(t.peer)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.peer))
+			continue
+		}
+		c.Check(err, IsNil, Commentf(t.peer))
+		c.Check(addr, Equals, t.addr)
+	}
+}
+
+func (s *lanshareSuite) TestFetch(c *C) {
+	data := "snap data"
+	d := digest(data)
+	missing, _ := s.newPeer(c, nil)
+	good, _ := s.newPeer(c, map[string]string{d: data})
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	peer, err := lanshare.Fetch(context.Background(), []string{missing, good}, d, int64(len(data)), target)
+	c.Assert(err, IsNil)
+	c.Check(peer, Equals, good)
+	c.Check(target, testutil.FileEquals, data)
+}
+
+func (s *lanshareSuite) TestFetchVerifiesDigest(c *C) {
+	data := "snap data"
+	d := digest(data)
+	// a peer serving tampered data under the expected digest
+	bad, _ := s.newPeer(c, map[string]string{d: "snap dat4"})
+	good, _ := s.newPeer(c, map[string]string{d: data})
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	peer, err := lanshare.Fetch(context.Background(), []string{bad, good}, d, int64(len(data)), target)
+	c.Assert(err, IsNil)
+	c.Check(peer, Equals, good)
+	c.Check(target, testutil.FileEquals, data)
+
+	_, err = lanshare.Fetch(context.Background(), []string{bad}, d, int64(len(data)), target+".2")
+	c.Check(err, ErrorMatches, `cannot fetch blob from peers: .*: sha3-384 mismatch: got [0-9a-f]+ but expected `+d)
+	c.Check(target+".2", testutil.FileAbsent)
+}
+
+func (s *lanshareSuite) TestFetchChecksSize(c *C) {
+	data := "snap data"
+	d := digest(data)
+	peer, _ := s.newPeer(c, map[string]string{d: data})
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	_, err := lanshare.Fetch(context.Background(), []string{peer}, d, 4, target)
+	c.Check(err, ErrorMatches, `cannot fetch blob from peers: .*: unexpected size 9, expected 4`)
+	c.Check(target, testutil.FileAbsent)
+}
+
+func (s *lanshareSuite) TestFetchNotFound(c *C) {
+	missing, _ := s.newPeer(c, nil)
+	target := filepath.Join(c.MkDir(), "foo.snap")
+
+	_, err := lanshare.Fetch(context.Background(), []string{missing}, digest("data"), 4, target)
+	c.Check(err, Equals, lanshare.ErrNotFound)
+	_, err = lanshare.Fetch(context.Background(), nil, digest("data"), 4, target)
+	c.Check(err, Equals, lanshare.ErrNotFound)
+
+	_, err = lanshare.Fetch(context.Background(), []string{missing}, "foo", 4, target)
+	c.Check(err, ErrorMatches, `invalid sha3-384 digest "foo"`)
+}
+
+func (s *lanshareSuite) TestFetchUnreachablePeer(c *C) {
+	data := "snap data"
+	d := digest(data)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	unreachable := strings.TrimPrefix(srv.URL, "http://")
+	srv.Close()
+	good, _ := s.newPeer(c, map[string]string{d: data})
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	peer, err := lanshare.Fetch(context.Background(), []string{unreachable, good}, d, int64(len(data)), target)
+	c.Assert(err, IsNil)
+	c.Check(peer, Equals, good)
+}
+
+func (s *lanshareSuite) TestFetchTimeout(c *C) {
+	c.Check(lanshare.FetchTimeout(0), Equals, 30*time.Minute)
+	c.Check(lanshare.FetchTimeout(1024), Equals, 10*time.Second)
+	c.Check(lanshare.FetchTimeout(512*1024*1024), Equals, 10*time.Second+2048*time.Second)
+}
+
+func (s *lanshareSuite) TestFetchStalledPeer(c *C) {
+	defer lanshare.MockTransferTimeouts(200*time.Millisecond, 1024*1024)()
+
+	data := "snap data"
+	d := digest(data)
+	// a peer sending the headers and part of the blob, then nothing
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write([]byte(data[:4]))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	stalled := strings.TrimPrefix(srv.URL, "http://")
+	good, _ := s.newPeer(c, map[string]string{d: data})
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	start := time.Now()
+	peer, err := lanshare.Fetch(context.Background(), []string{stalled, good}, d, int64(len(data)), target)
+	c.Assert(err, IsNil)
+	c.Check(peer, Equals, good)
+	c.Check(target, testutil.FileEquals, data)
+	c.Check(time.Since(start) < 5*time.Second, Equals, true)
+
+	_, err = lanshare.Fetch(context.Background(), []string{stalled}, d, int64(len(data)), target+".2")
+	c.Check(err, ErrorMatches, `cannot fetch blob from peers: .*: context deadline exceeded`)
+}
+
+// deltaCache is a dirCache providing deltas as "delta:<format>:<source>:<target>"
+// files, standing in for their generation, unless it has a "busy" file.
+type deltaCache struct {
+	dirCache
+}
+
+func (d deltaCache) DeltaPath(ctx context.Context, format, sourceDigest, targetDigest string) (string, error) {
+	if _, err := os.Stat(d.GetPath("busy")); err == nil {
+		return "", fmt.Errorf("cannot generate delta: %w", lanshare.ErrBusy)
+	}
+	for _, key := range []string{sourceDigest, targetDigest} {
+		if _, err := os.Stat(d.GetPath(key)); err != nil {
+			return "", err
+		}
+	}
+	if format != "xdelta3" {
+		return "", fmt.Errorf("unsupported delta format %q", format)
+	}
+	p := d.GetPath("delta")
+	data := fmt.Sprintf("delta:%s:%s:%s", format, sourceDigest, targetDigest)
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func (s *lanshareSuite) newDeltaPeer(c *C, blobs map[string]string) string {
+	cache := deltaCache{dirCache(c.MkDir())}
+	for key, data := range blobs {
+		c.Assert(os.WriteFile(cache.GetPath(key), []byte(data), 0644), IsNil)
+	}
+	srv := httptest.NewServer(lanshare.NewHandler(cache))
+	s.AddCleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (s *lanshareSuite) TestHandlerDelta(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	peer := s.newDeltaPeer(c, map[string]string{src: "rev 1", dst: "rev 2"})
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/v1/deltas/xdelta3/%s/%s", peer, src, dst))
+	c.Assert(err, IsNil)
+	defer rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 200)
+	body, err := io.ReadAll(rsp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Equals, fmt.Sprintf("delta:xdelta3:%s:%s", src, dst))
+
+	for _, path := range []string{
+		// missing blob
+		fmt.Sprintf("/v1/deltas/xdelta3/%s/%s", src, digest("other")),
+		// unsupported format
+		fmt.Sprintf("/v1/deltas/bsdiff/%s/%s", src, dst),
+		fmt.Sprintf("/v1/deltas/xdelta3/%s", src),
+		fmt.Sprintf("/v1/deltas//%s/%s", src, dst),
+		"/v1/deltas/xdelta3/foo/bar",
+	} {
+		rsp, err := http.Get("http://" + peer + path)
+		c.Assert(err, IsNil)
+		rsp.Body.Close()
+		c.Check(rsp.StatusCode, Equals, 404, Commentf(path))
+	}
+}
+
+func (s *lanshareSuite) TestHandlerDeltaBusy(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	peer := s.newDeltaPeer(c, map[string]string{src: "rev 1", dst: "rev 2", "busy": ""})
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/v1/deltas/xdelta3/%s/%s", peer, src, dst))
+	c.Assert(err, IsNil)
+	rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 503)
+	c.Check(rsp.Header.Get("Retry-After"), Equals, "60")
+}
+
+func (s *lanshareSuite) TestHandlerNoDeltas(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	peer, _ := s.newPeer(c, map[string]string{src: "rev 1", dst: "rev 2"})
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/v1/deltas/xdelta3/%s/%s", peer, src, dst))
+	c.Assert(err, IsNil)
+	rsp.Body.Close()
+	c.Check(rsp.StatusCode, Equals, 404)
+}
+
+func (s *lanshareSuite) TestFetchDelta(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	missing := s.newDeltaPeer(c, map[string]string{dst: "rev 2"})
+	busy := s.newDeltaPeer(c, map[string]string{src: "rev 1", dst: "rev 2", "busy": ""})
+	good := s.newDeltaPeer(c, map[string]string{src: "rev 1", dst: "rev 2"})
+
+	target := filepath.Join(c.MkDir(), "foo.delta")
+	peer, err := lanshare.FetchDelta(context.Background(), []string{missing, busy, good}, "xdelta3", src, dst, 1000, target)
+	c.Assert(err, IsNil)
+	c.Check(peer, Equals, good)
+	c.Check(target, testutil.FileEquals, fmt.Sprintf("delta:xdelta3:%s:%s", src, dst))
+
+	_, err = lanshare.FetchDelta(context.Background(), []string{missing, busy}, "xdelta3", src, dst, 1000, target+".2")
+	c.Check(err, Equals, lanshare.ErrNotFound)
+}
+
+func (s *lanshareSuite) TestFetchDeltaChecksSize(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	peer := s.newDeltaPeer(c, map[string]string{src: "rev 1", dst: "rev 2"})
+
+	target := filepath.Join(c.MkDir(), "foo.delta")
+	_, err := lanshare.FetchDelta(context.Background(), []string{peer}, "xdelta3", src, dst, 10, target)
+	c.Check(err, ErrorMatches, `cannot fetch delta from peers: .*: delta of size 207 is larger than 10`)
+	c.Check(target, testutil.FileAbsent)
+}
+
+func (s *lanshareSuite) TestFetchDeltaInvalid(c *C) {
+	src, dst := digest("rev 1"), digest("rev 2")
+	target := filepath.Join(c.MkDir(), "foo.delta")
+
+	_, err := lanshare.FetchDelta(context.Background(), nil, "xdelta3", "foo", dst, 0, target)
+	c.Check(err, ErrorMatches, `invalid sha3-384 digest "foo"`)
+	_, err = lanshare.FetchDelta(context.Background(), nil, "xdelta3", src, "bar", 0, target)
+	c.Check(err, ErrorMatches, `invalid sha3-384 digest "bar"`)
+	_, err = lanshare.FetchDelta(context.Background(), nil, "../xdelta3", src, dst, 0, target)
+	c.Check(err, ErrorMatches, `invalid delta format "../xdelta3"`)
+}
@@ -0,0 +1,442 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lanshare
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// ServiceName is the DNS-SD service under which peers announce themselves
+// through multicast DNS.
+const ServiceName = "_snapd-lanshare._tcp.local."
+
+const (
+	dnsTypePTR = 12
+	dnsTypeSRV = 33
+	dnsClassIN = 1
+	// dnsClassUnicastResponse is set in the class of questions to ask for a
+	// unicast response, and dnsClassCacheFlush in the class of unique
+	// records.
+	dnsClassUnicastResponse = 0x8000
+	dnsClassCacheFlush      = 0x8000
+
+	dnsFlagResponse      = 0x8000
+	dnsFlagAuthoritative = 0x0400
+
+	mdnsTTL = 120
+)
+
+var (
+	mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+	listenMulticastUDP = net.ListenMulticastUDP
+	listenUDP          = net.ListenUDP
+	interfaceByName    = net.InterfaceByName
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func appendHeader(b []byte, id, flags, questions, answers uint16) []byte {
+	b = appendUint16(b, id)
+	b = appendUint16(b, flags)
+	b = appendUint16(b, questions)
+	b = appendUint16(b, answers)
+	// no authority nor additional records
+	return append(b, 0, 0, 0, 0)
+}
+
+func appendRecord(b []byte, name string, typ, class uint16, rdata []byte) []byte {
+	b = appendName(b, name)
+	b = appendUint16(b, typ)
+	b = appendUint16(b, class)
+	b = appendUint32(b, mdnsTTL)
+	b = appendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+// browseQuery returns a multicast DNS query for the peers sharing blobs.
+func browseQuery() []byte {
+	b := appendHeader(nil, 0, 0, 1, 0)
+	b = appendName(b, ServiceName)
+	b = appendUint16(b, dnsTypePTR)
+	return appendUint16(b, dnsClassIN|dnsClassUnicastResponse)
+}
+
+// instanceName returns the DNS-SD instance name of the given peer.
+func instanceName(instance string) string {
+	return instance + "." + ServiceName
+}
+
+// announcement returns the multicast DNS response announcing that the given
+// instance shares blobs on the given port.
+func announcement(id uint16, instance string, port int) []byte {
+	b := appendHeader(nil, id, dnsFlagResponse|dnsFlagAuthoritative, 0, 2)
+	b = appendRecord(b, ServiceName, dnsTypePTR, dnsClassIN, appendName(nil, instanceName(instance)))
+	srv := []byte{0, 0, 0, 0}
+	srv = appendUint16(srv, uint16(port))
+	srv = appendName(srv, instance+".local.")
+	return appendRecord(b, instanceName(instance), dnsTypeSRV, dnsClassIN|dnsClassCacheFlush, srv)
+}
+
+var errMalformed = errors.New("malformed DNS message")
+
+// readName reads the possibly compressed name at the given offset of msg,
+// returning it and the offset following it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformed
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			off++
+			if end < 0 {
+				end = off
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errMalformed
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+type dnsMessage struct {
+	id        uint16
+	flags     uint16
+	questions []dnsQuestion
+	records   []dnsRecord
+}
+
+type dnsQuestion struct {
+	name string
+	typ  uint16
+}
+
+type dnsRecord struct {
+	name string
+	typ  uint16
+	// target is the target of PTR and SRV records
+	target string
+	// port is the port of SRV records
+	port uint16
+}
+
+func parseMessage(msg []byte) (*dnsMessage, error) {
+	if len(msg) < 12 {
+		return nil, errMalformed
+	}
+	m := &dnsMessage{
+		id:    binary.BigEndian.Uint16(msg[0:]),
+		flags: binary.BigEndian.Uint16(msg[2:]),
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	rrcount := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errMalformed
+		}
+		m.questions = append(m.questions, dnsQuestion{
+			name: strings.ToLower(name),
+			typ:  binary.BigEndian.Uint16(msg[next:]),
+		})
+		off = next + 4
+	}
+	for i := 0; i < rrcount; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errMalformed
+		}
+		rr := dnsRecord{
+			name: strings.ToLower(name),
+			typ:  binary.BigEndian.Uint16(msg[next:]),
+		}
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdlen > len(msg) {
+			return nil, errMalformed
+		}
+		switch rr.typ {
+		case dnsTypePTR:
+			if rr.target, _, err = readName(msg, rdata); err != nil {
+				return nil, err
+			}
+		case dnsTypeSRV:
+			if rdlen < 7 {
+				return nil, errMalformed
+			}
+			rr.port = binary.BigEndian.Uint16(msg[rdata+4:])
+			if rr.target, _, err = readName(msg, rdata+6); err != nil {
+				return nil, err
+			}
+		}
+		m.records = append(m.records, rr)
+		off = rdata + rdlen
+	}
+	return m, nil
+}
+
+// announcedPeers returns the ports announced in the given response, by
+// instance name.
+func announcedPeers(m *dnsMessage) map[string]int {
+	if m.flags&dnsFlagResponse == 0 {
+		return nil
+	}
+	service := strings.ToLower(ServiceName)
+	instances := make(map[string]bool)
+	for _, rr := range m.records {
+		if rr.typ == dnsTypePTR && rr.name == service {
+			instances[strings.ToLower(rr.target)] = true
+		}
+	}
+	peers := make(map[string]int)
+	for _, rr := range m.records {
+		if rr.typ == dnsTypeSRV && instances[rr.name] && rr.port != 0 {
+			peers[strings.TrimSuffix(rr.name, "."+service)] = int(rr.port)
+		}
+	}
+	return peers
+}
+
+// Browse queries the local network through multicast DNS for peers sharing
+// blobs, collecting responses until the timeout expires. It returns the
+// addresses of the peers found, except for the given instance, which is
+// meant to be the one announced by this device.
+func Browse(ctx context.Context, self string, timeout time.Duration) ([]string, error) {
+	conn, err := listenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot browse for peers: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo(browseQuery(), mdnsAddr); err != nil {
+		return nil, fmt.Errorf("cannot browse for peers: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	self = strings.ToLower(self)
+	seen := make(map[string]bool)
+	var peers []string
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return peers, nil
+			}
+			return peers, fmt.Errorf("cannot browse for peers: %v", err)
+		}
+		m, err := parseMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for instance, port := range announcedPeers(m) {
+			if instance == self {
+				continue
+			}
+			peer := net.JoinHostPort(addr.IP.String(), strconv.Itoa(port))
+			if !seen[peer] {
+				seen[peer] = true
+				peers = append(peers, peer)
+			}
+		}
+	}
+}
+
+// Responder answers multicast DNS queries for peers sharing blobs, announcing
+// this device.
+type Responder struct {
+	instance string
+	port     int
+
+	conns     []*net.UDPConn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewResponder returns a responder announcing the given instance, sharing
+// blobs on the given port, on the given network interfaces or on the default
+// multicast interface if none are given. Serve must be called for it to
+// answer queries.
+func NewResponder(instance string, port int, interfaces []string) (*Responder, error) {
+	if instance == "" || strings.Contains(instance, ".") {
+		return nil, fmt.Errorf("invalid instance name %q", instance)
+	}
+	r := &Responder{
+		instance: instance,
+		port:     port,
+		done:     make(chan struct{}),
+	}
+	if len(interfaces) == 0 {
+		conn, err := listenMulticastUDP("udp4", nil, mdnsAddr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot listen for multicast DNS queries: %v", err)
+		}
+		r.conns = append(r.conns, conn)
+		return r, nil
+	}
+	for _, name := range interfaces {
+		conn, err := listenOnInterface(name)
+		if err != nil {
+			r.closeConns()
+			return nil, fmt.Errorf("cannot listen for multicast DNS queries on %s: %v", name, err)
+		}
+		r.conns = append(r.conns, conn)
+	}
+	return r, nil
+}
+
+func listenOnInterface(name string) (*net.UDPConn, error) {
+	ifi, err := interfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return listenMulticastUDP("udp4", ifi, mdnsAddr)
+}
+
+func (r *Responder) closeConns() error {
+	var firstErr error
+	for _, conn := range r.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// reply returns the response to the given query, or nil if the query is not
+// about peers sharing blobs.
+func (r *Responder) reply(query []byte) []byte {
+	m, err := parseMessage(query)
+	if err != nil || m.flags&dnsFlagResponse != 0 {
+		return nil
+	}
+	service := strings.ToLower(ServiceName)
+	for _, q := range m.questions {
+		if q.name == service && q.typ == dnsTypePTR {
+			return announcement(m.id, r.instance, r.port)
+		}
+	}
+	return nil
+}
+
+// Serve answers queries until the responder is closed.
+func (r *Responder) Serve() {
+	var wg sync.WaitGroup
+	for _, conn := range r.conns {
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			r.serve(conn)
+		}(conn)
+	}
+	wg.Wait()
+}
+
+func (r *Responder) serve(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			logger.Noticef("cannot read multicast DNS query: %v", err)
+			return
+		}
+		rsp := r.reply(buf[:n])
+		if rsp == nil {
+			continue
+		}
+		// answer directly to the querier, which also covers queries
+		// from resolvers not listening on the multicast DNS port
+		if _, err := conn.WriteToUDP(rsp, addr); err != nil {
+			logger.Debugf("cannot answer multicast DNS query from %v: %v", addr, err)
+		}
+	}
+}
+
+// Close stops the responder.
+func (r *Responder) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		err = r.closeConns()
+	})
+	return err
+}
@@ -0,0 +1,186 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lanshare_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/store/lanshare"
+)
+
+type mdnsSuite struct{}
+
+var _ = Suite(&mdnsSuite{})
+
+func (s *mdnsSuite) TestReplyToBrowseQuery(c *C) {
+	r, restore := newTestResponder(c, "device-1", 44747)
+	defer restore()
+	defer r.Close()
+
+	rsp := r.Reply(lanshare.BrowseQuery())
+	c.Assert(rsp, NotNil)
+	m, err := lanshare.ParseMessage(rsp)
+	c.Assert(err, IsNil)
+	c.Check(lanshare.AnnouncedPeers(m), DeepEquals, map[string]int{"device-1": 44747})
+
+	// responses and unrelated queries are not answered
+	c.Check(r.Reply(rsp), IsNil)
+	c.Check(r.Reply([]byte("garbage")), IsNil)
+}
+
+func (s *mdnsSuite) TestAnnouncedPeersCompressedNames(c *C) {
+	// a response as sent by other implementations, with the names in the
+	// SRV record compressed
+	msg := []byte{
+		0, 0, 0x84, 0, 0, 0, 0, 2, 0, 0, 0, 0,
+		// offset 12: _snapd-lanshare._tcp.local PTR
+		15, '_', 's', 'n', 'a', 'p', 'd', '-', 'l', 'a', 'n', 's', 'h', 'a', 'r', 'e',
+		4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, 12, 0, 1, 0, 0, 0, 120, 0, 11,
+		// offset 50: Device-2 pointing to offset 12
+		8, 'D', 'e', 'v', 'i', 'c', 'e', '-', '2', 0xc0, 12,
+		// SRV record named by a pointer to offset 50
+		0xc0, 50, 0, 33, 0x80, 1, 0, 0, 0, 120, 0, 8,
+		0, 0, 0, 0, 0x1f, 0x40, 0xc0, 50,
+	}
+	m, err := lanshare.ParseMessage(msg)
+	c.Assert(err, IsNil)
+	c.Check(lanshare.AnnouncedPeers(m), DeepEquals, map[string]int{"device-2": 8000})
+}
+
+func (s *mdnsSuite) TestParseMalformed(c *C) {
+	good := lanshare.Announcement(1, "device-1", 44747)
+	for i := 0; i < len(good); i++ {
+		// truncated messages are rejected without panicking
+		m, err := lanshare.ParseMessage(good[:i])
+		if err == nil {
+			c.Check(lanshare.AnnouncedPeers(m), HasLen, 0)
+		}
+	}
+	// pointer loop
+	_, err := lanshare.ParseMessage([]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 12, 0, 1})
+	c.Check(err, ErrorMatches, "malformed DNS message")
+}
+
+func (s *mdnsSuite) TestNewResponderInvalidInstance(c *C) {
+	_, err := lanshare.NewResponder("foo.bar", 44747, nil)
+	c.Check(err, ErrorMatches, `invalid instance name "foo.bar"`)
+}
+
+func (s *mdnsSuite) TestNewResponderInterfaces(c *C) {
+	defer lanshare.MockInterfaceByName(func(name string) (*net.Interface, error) {
+		if name == "missing0" {
+			return nil, fmt.Errorf("no such network interface")
+		}
+		return &net.Interface{Name: name}, nil
+	})()
+	var listened []string
+	var conns []*net.UDPConn
+	defer lanshare.MockListenMulticastUDP(func(network string, ifi *net.Interface, gaddr *net.UDPAddr) (*net.UDPConn, error) {
+		c.Assert(ifi, NotNil)
+		listened = append(listened, ifi.Name)
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		conns = append(conns, conn)
+		return conn, err
+	})()
+
+	r, err := lanshare.NewResponder("device-1", 8000, []string{"eth0", "wlan0"})
+	c.Assert(err, IsNil)
+	c.Check(listened, DeepEquals, []string{"eth0", "wlan0"})
+
+	// queries are answered on every interface
+	done := make(chan struct{})
+	go func() {
+		r.Serve()
+		close(done)
+	}()
+	for _, conn := range conns {
+		client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+		c.Assert(err, IsNil)
+		_, err = client.Write(lanshare.BrowseQuery())
+		c.Assert(err, IsNil)
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 9000)
+		n, err := client.Read(buf)
+		c.Assert(err, IsNil)
+		m, err := lanshare.ParseMessage(buf[:n])
+		c.Assert(err, IsNil)
+		c.Check(lanshare.AnnouncedPeers(m), DeepEquals, map[string]int{"device-1": 8000})
+		client.Close()
+	}
+	c.Assert(r.Close(), IsNil)
+	<-done
+
+	// the listeners are closed when one of the interfaces cannot be used
+	listened = nil
+	conns = nil
+	_, err = lanshare.NewResponder("device-1", 8000, []string{"eth0", "missing0"})
+	c.Assert(err, ErrorMatches, "cannot listen for multicast DNS queries on missing0: no such network interface")
+	c.Check(listened, DeepEquals, []string{"eth0"})
+	c.Assert(conns, HasLen, 1)
+	_, err = conns[0].Write([]byte("x"))
+	c.Check(err, NotNil)
+}
+
+// newTestResponder returns a responder listening on a loopback socket rather
+// than on the multicast DNS group.
+func newTestResponder(c *C, instance string, port int) (*lanshare.Responder, func()) {
+	restore := lanshare.MockListenMulticastUDP(func(network string, ifi *net.Interface, gaddr *net.UDPAddr) (*net.UDPConn, error) {
+		return net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	})
+	r, err := lanshare.NewResponder(instance, port, nil)
+	c.Assert(err, IsNil)
+	return r, restore
+}
+
+func (s *mdnsSuite) TestBrowse(c *C) {
+	var addrs []*net.UDPAddr
+	restore := lanshare.MockListenMulticastUDP(func(network string, ifi *net.Interface, gaddr *net.UDPAddr) (*net.UDPConn, error) {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err == nil {
+			addrs = append(addrs, conn.LocalAddr().(*net.UDPAddr))
+		}
+		return conn, err
+	})
+	defer restore()
+
+	r1, err := lanshare.NewResponder("device-1", 8000, nil)
+	c.Assert(err, IsNil)
+	defer r1.Close()
+	go r1.Serve()
+
+	// stand in for the multicast group with the address of the responder
+	restore = lanshare.MockMDNSAddr(addrs[0])
+	defer restore()
+
+	peers, err := lanshare.Browse(context.Background(), "device-0", 200*time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Check(peers, DeepEquals, []string{"127.0.0.1:8000"})
+
+	// the device itself is skipped
+	peers, err = lanshare.Browse(context.Background(), "device-1", 200*time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Check(peers, HasLen, 0)
+}
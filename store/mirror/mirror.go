@@ -0,0 +1,579 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package mirror implements a read-only store-compatible HTTP API, through
+// which a device serves the snap info, refresh, assertion and download
+// requests of other devices from its own store, download cache and
+// assertion database. Other devices use the mirror like any proxy store, via
+// a store assertion carrying its URL and the proxy.store option.
+package mirror
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/store"
+)
+
+// DefaultPort is the TCP port on which the mirror is served, unless
+// another one is configured.
+const DefaultPort = 44748
+
+const (
+	infoPath       = "/v2/snaps/info/"
+	refreshPath    = "/v2/snaps/refresh"
+	assertionsPath = "/v2/assertions/"
+	downloadsPath  = "/v2/mirror/downloads/"
+)
+
+// maxRequestSize is the maximum size of the body of refresh requests.
+const maxRequestSize = 4 * 1024 * 1024
+
+// maxKnownDownloads is the maximum number of snaps and components that can
+// be downloaded through the mirror without being in its cache, those being
+// the ones in the most recent responses of the store.
+const maxKnownDownloads = 4096
+
+// forwardedHeaders are the headers of requests describing the requesting
+// device and its request, which are passed on to the store.
+var forwardedHeaders = []string{
+	"Accept",
+	"Snap-Classic",
+	"Snap-Device-Architecture",
+	"Snap-Device-Capabilities",
+	"Snap-Device-Series",
+	"Snap-Refresh-Managed",
+	"Snap-Refresh-Reason",
+}
+
+// forwardedQueries are the query parameters of the requests passed on to
+// the store, by path prefix.
+var forwardedQueries = map[string][]string{
+	infoPath:       {"architecture", "fields"},
+	assertionsPath: {"max-format", "sequence"},
+}
+
+// Backend is the store the mirror serves requests from, usually the store of
+// the mirroring device.
+type Backend interface {
+	MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *store.MirrorOptions) (*http.Response, error)
+	MirrorRefresh(ctx context.Context, body []byte, opts *store.MirrorOptions) (*http.Response, error)
+	MirrorAssertion(ctx context.Context, assertType *asserts.AssertionType, key []string, query url.Values, opts *store.MirrorOptions) (*http.Response, error)
+	Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error
+}
+
+// Assertions is the assertion database the mirror serves assertions from
+// before asking its store.
+type Assertions interface {
+	Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error)
+	FindSequence(assertType *asserts.AssertionType, sequenceHeaders map[string]string, after, maxFormat int) (asserts.SequenceMember, error)
+}
+
+// Cache is the download cache the mirror serves snaps and components from.
+type Cache interface {
+	// GetPath returns the path of the blob with the given cache key, or
+	// an empty string if it is not in the cache.
+	GetPath(cacheKey string) string
+}
+
+// Config holds what the mirror serves requests from.
+type Config struct {
+	Store      Backend
+	Assertions Assertions
+	Cache      Cache
+	// DownloadDir is where snaps and components missing from the cache
+	// are downloaded to, before the store puts them into the cache.
+	DownloadDir string
+	// DeviceAuth is whether the requests passed on to the store, and the
+	// downloads, are authorized as coming from the mirroring device. They
+	// are anonymous otherwise.
+	DeviceAuth bool
+}
+
+type download struct {
+	name string
+	info snap.DownloadInfo
+}
+
+type handler struct {
+	cfg Config
+
+	mu        sync.Mutex
+	downloads map[string]*download
+}
+
+// NewHandler returns an http.Handler serving the store mirror API:
+//
+//   - GET /v2/snaps/info/<name> and POST /v2/snaps/refresh are passed on to
+//     the store, with the download URLs in the results pointing to the mirror
+//   - GET /v2/assertions/<type>/<primary-key> is served from the assertion
+//     database, or else passed on to the store
+//   - GET /v2/mirror/downloads/<sha3-384> returns the snap or component with
+//     the given digest, from the cache or else downloaded from the store
+//
+// Fetching assertions through refresh requests is not supported, clients
+// then fall back to fetching them one by one.
+func NewHandler(cfg *Config) http.Handler {
+	return &handler{
+		cfg:       *cfg,
+		downloads: make(map[string]*download),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, infoPath) && (r.Method == "GET" || r.Method == "HEAD"):
+		h.serveInfo(w, r)
+	case r.URL.Path == refreshPath && r.Method == "POST":
+		h.serveRefresh(w, r)
+	case strings.HasPrefix(r.URL.Path, assertionsPath) && (r.Method == "GET" || r.Method == "HEAD"):
+		h.serveAssertion(w, r)
+	case strings.HasPrefix(r.URL.Path, downloadsPath) && (r.Method == "GET" || r.Method == "HEAD"):
+		h.serveDownload(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// forwardedQuery returns the query parameters of the request which are
+// passed on to the store for requests with the given path prefix.
+func forwardedQuery(r *http.Request, prefix string) url.Values {
+	query := r.URL.Query()
+	forwarded := make(url.Values)
+	for _, name := range forwardedQueries[prefix] {
+		if v, ok := query[name]; ok {
+			forwarded[name] = v
+		}
+	}
+	return forwarded
+}
+
+func (h *handler) serveInfo(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, infoPath)
+	if naming.ValidateSnap(name) != nil {
+		http.NotFound(w, r)
+		return
+	}
+	query := forwardedQuery(r, infoPath)
+	h.forward(w, r, func(ctx context.Context, opts *store.MirrorOptions) (*http.Response, error) {
+		return h.cfg.Store.MirrorSnapInfo(ctx, name, query, opts)
+	}, nil)
+}
+
+// errorListEntry is an entry of the error list of store responses.
+type errorListEntry struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var errFetchAssertionsUnsupported = errorListEntry{
+	Code:    "fetch-assertions-unsupported",
+	Message: "fetching assertions through refresh requests is not supported by the store mirror",
+}
+
+func (h *handler) serveRefresh(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		http.Error(w, "cannot read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRequestSize {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var req map[string]json.RawMessage
+	var actions []json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "cannot decode request", http.StatusBadRequest)
+		return
+	}
+	if raw, ok := req["actions"]; ok {
+		if err := json.Unmarshal(raw, &actions); err != nil {
+			http.Error(w, "cannot decode request actions", http.StatusBadRequest)
+			return
+		}
+	}
+
+	kept := make([]json.RawMessage, 0, len(actions))
+	for _, action := range actions {
+		var a struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(action, &a); err != nil {
+			http.Error(w, "cannot decode request actions", http.StatusBadRequest)
+			return
+		}
+		if a.Action == "fetch-assertions" {
+			continue
+		}
+		kept = append(kept, action)
+	}
+	refresh := func(ctx context.Context, opts *store.MirrorOptions) (*http.Response, error) {
+		return h.cfg.Store.MirrorRefresh(ctx, body, opts)
+	}
+	if len(kept) == len(actions) {
+		h.forward(w, r, refresh, nil)
+		return
+	}
+
+	errorList := []errorListEntry{errFetchAssertionsUnsupported}
+	var current []json.RawMessage
+	if raw, ok := req["context"]; ok {
+		json.Unmarshal(raw, &current)
+	}
+	if len(kept) == 0 && len(current) == 0 {
+		// nothing left to ask the store
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"results":    []any{},
+			"error-list": errorList,
+		})
+		return
+	}
+	req["actions"], err = json.Marshal(kept)
+	if err == nil {
+		body, err = json.Marshal(req)
+	}
+	if err != nil {
+		http.Error(w, "cannot encode request", http.StatusInternalServerError)
+		return
+	}
+	h.forward(w, r, refresh, errorList)
+}
+
+// forward passes the request on to the store through send, and writes back
+// the response. The download URLs in JSON responses are replaced with ones
+// pointing to the mirror, and the given errors are added to their error
+// list.
+func (h *handler) forward(w http.ResponseWriter, r *http.Request, send func(ctx context.Context, opts *store.MirrorOptions) (*http.Response, error), errorList []errorListEntry) {
+	opts := &store.MirrorOptions{
+		DeviceAuth: h.cfg.DeviceAuth,
+		Header:     make(map[string]string, len(forwardedHeaders)),
+	}
+	for _, name := range forwardedHeaders {
+		if v := r.Header.Get(name); v != "" {
+			opts.Header[name] = v
+		}
+	}
+	resp, err := send(r.Context(), opts)
+	if err != nil {
+		logger.Noticef("cannot pass on request to the store: %v", err)
+		http.Error(w, "cannot reach the store", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, name := range []string{"Content-Type", "Snap-Store-Version", "WWW-Authenticate", "X-Suggested-Currency"} {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != 200 || !strings.HasPrefix(contentType, "application/json") || r.Method == "HEAD" {
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	var doc any
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		logger.Noticef("cannot decode store response: %v", err)
+		http.Error(w, "cannot decode store response", http.StatusBadGateway)
+		return
+	}
+	h.rewriteDownloads(doc, mirrorURL(r), "")
+	if m, ok := doc.(map[string]any); ok && len(errorList) > 0 {
+		list, _ := m["error-list"].([]any)
+		for _, e := range errorList {
+			list = append(list, e)
+		}
+		m["error-list"] = list
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+// mirrorURL returns the URL of the mirror as seen by the requesting device.
+func mirrorURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// rewriteDownloads replaces the download URLs in the given decoded JSON
+// with ones pointing to the mirror, remembering what they were. name is the
+// name of the snap or component the value refers to, if known.
+func (h *handler) rewriteDownloads(v any, base, name string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if n, ok := v["name"].(string); ok {
+			name = n
+		}
+		for key, value := range v {
+			if dl, ok := value.(map[string]any); ok && key == "download" {
+				h.rewriteDownload(dl, base, name)
+				continue
+			}
+			h.rewriteDownloads(value, base, name)
+		}
+	case []any:
+		for _, value := range v {
+			h.rewriteDownloads(value, base, name)
+		}
+	}
+}
+
+func (h *handler) rewriteDownload(dl map[string]any, base, name string) {
+	// only full snaps and components are mirrored
+	delete(dl, "deltas")
+
+	digest, _ := dl["sha3-384"].(string)
+	upstream, _ := dl["url"].(string)
+	if !validDigest(digest) || upstream == "" {
+		return
+	}
+	var size int64
+	if n, ok := dl["size"].(json.Number); ok {
+		size, _ = n.Int64()
+	}
+
+	h.mu.Lock()
+	if _, ok := h.downloads[digest]; !ok && len(h.downloads) >= maxKnownDownloads {
+		// forget about older downloads
+		h.downloads = make(map[string]*download)
+	}
+	h.downloads[digest] = &download{
+		name: name,
+		info: snap.DownloadInfo{
+			DownloadURL: upstream,
+			Size:        size,
+			Sha3_384:    digest,
+		},
+	}
+	h.mu.Unlock()
+
+	dl["url"] = base + downloadsPath + digest
+}
+
+// validDigest returns whether the given string is a hex encoded SHA3-384
+// digest, as used for cache keys.
+func validDigest(digest string) bool {
+	if len(digest) != 2*crypto.SHA3_384.Size() {
+		return false
+	}
+	for _, r := range digest {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// assertionRequest returns the type and the primary key, or sequence key if
+// a sequence is requested, of the requested assertion, or false if the
+// request is not for a valid one.
+func assertionRequest(r *http.Request) (assertType *asserts.AssertionType, key []string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, assertionsPath), "/")
+	assertType = asserts.Type(parts[0])
+	if assertType == nil {
+		return nil, nil, false
+	}
+	key = parts[1:]
+	var err error
+	if r.URL.Query().Get("sequence") != "" {
+		if !assertType.SequenceForming() {
+			return nil, nil, false
+		}
+		_, err = asserts.HeadersFromSequenceKey(assertType, key)
+	} else {
+		_, err = asserts.HeadersFromPrimaryKey(assertType, key)
+	}
+	if err != nil {
+		return nil, nil, false
+	}
+	for _, k := range key {
+		if k == "." || k == ".." {
+			return nil, nil, false
+		}
+	}
+	return assertType, key, true
+}
+
+// serveAssertion serves the requested assertion from the assertion
+// database, or else passes the request on to the store.
+func (h *handler) serveAssertion(w http.ResponseWriter, r *http.Request) {
+	assertType, key, ok := assertionRequest(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if h.serveLocalAssertion(w, r, assertType, key) {
+		return
+	}
+	query := forwardedQuery(r, assertionsPath)
+	h.forward(w, r, func(ctx context.Context, opts *store.MirrorOptions) (*http.Response, error) {
+		return h.cfg.Store.MirrorAssertion(ctx, assertType, key, query, opts)
+	}, nil)
+}
+
+// serveLocalAssertion serves the requested assertion from the assertion
+// database and returns whether it could.
+func (h *handler) serveLocalAssertion(w http.ResponseWriter, r *http.Request, assertType *asserts.AssertionType, key []string) bool {
+	if h.cfg.Assertions == nil {
+		return false
+	}
+	query := r.URL.Query()
+	maxFormat := assertType.MaxSupportedFormat()
+	if v := query.Get("max-format"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		maxFormat = n
+	}
+
+	var a asserts.Assertion
+	if seq := query.Get("sequence"); seq != "" {
+		headers, err := asserts.HeadersFromSequenceKey(assertType, key)
+		if err != nil {
+			return false
+		}
+		if seq == "latest" {
+			a, err = h.cfg.Assertions.FindSequence(assertType, headers, -1, maxFormat)
+		} else {
+			if n, err := strconv.Atoi(seq); err != nil || n <= 0 {
+				return false
+			}
+			headers[assertType.PrimaryKey[len(assertType.PrimaryKey)-1]] = seq
+			a, err = h.cfg.Assertions.Find(assertType, headers)
+		}
+		if err != nil {
+			return false
+		}
+	} else {
+		headers, err := asserts.HeadersFromPrimaryKey(assertType, key)
+		if err != nil {
+			return false
+		}
+		a, err = h.cfg.Assertions.Find(assertType, headers)
+		if err != nil {
+			return false
+		}
+	}
+	if a.Format() > maxFormat {
+		return false
+	}
+
+	w.Header().Set("Content-Type", asserts.MediaType)
+	if r.Method != "HEAD" {
+		w.Write(asserts.Encode(a))
+	}
+	return true
+}
+
+func (h *handler) serveDownload(w http.ResponseWriter, r *http.Request) {
+	digest := strings.TrimPrefix(r.URL.Path, downloadsPath)
+	if !validDigest(digest) {
+		http.NotFound(w, r)
+		return
+	}
+	if serveFile(w, r, h.cfg.Cache.GetPath(digest)) {
+		return
+	}
+
+	h.mu.Lock()
+	dl := h.downloads[digest]
+	h.mu.Unlock()
+	if dl == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(dl.info.Size, 10))
+		return
+	}
+
+	if err := os.MkdirAll(h.cfg.DownloadDir, 0700); err != nil {
+		logger.Noticef("cannot download %q for the store mirror: %v", dl.name, err)
+		http.Error(w, "cannot download from the store", http.StatusInternalServerError)
+		return
+	}
+	dir, err := os.MkdirTemp(h.cfg.DownloadDir, "download-")
+	if err != nil {
+		logger.Noticef("cannot download %q for the store mirror: %v", dl.name, err)
+		http.Error(w, "cannot download from the store", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	targetPath := filepath.Join(dir, digest)
+	info := dl.info
+	dlOpts := &store.DownloadOptions{NoDeviceAuth: !h.cfg.DeviceAuth}
+	if err := h.cfg.Store.Download(r.Context(), dl.name, targetPath, &info, progress.Null, nil, dlOpts); err != nil {
+		logger.Noticef("cannot download %q for the store mirror: %v", dl.name, err)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		http.Error(w, "cannot download from the store", http.StatusBadGateway)
+		return
+	}
+	serveFile(w, r, targetPath)
+}
+
+// serveFile serves the regular file at the given path, if there is one, and
+// returns whether it did.
+func serveFile(w http.ResponseWriter, r *http.Request, path string) bool {
+	if path == "" {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+	return true
+}
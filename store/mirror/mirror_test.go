@@ -0,0 +1,466 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mirror_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/mirror"
+	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type mirrorSuite struct {
+	testutil.BaseTest
+
+	upstream     *fakeStore
+	cache        fakeCache
+	db           *asserts.Database
+	storeSigning *assertstest.StoreStack
+
+	server *httptest.Server
+	client *store.Store
+}
+
+var _ = Suite(&mirrorSuite{})
+
+type upstreamRequest struct {
+	method     string
+	path       string
+	query      url.Values
+	header     map[string]string
+	body       string
+	deviceAuth bool
+}
+
+// fakeStore is the store of the mirroring device.
+type fakeStore struct {
+	storetest.Store
+
+	c         *C
+	requests  []upstreamRequest
+	responses map[string]func(body []byte) (int, string, string)
+	downloads []string
+	blobs     map[string][]byte
+}
+
+func (f *fakeStore) MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	return f.request("GET", "v2/snaps/info/"+name, query, nil, opts)
+}
+
+func (f *fakeStore) MirrorRefresh(ctx context.Context, body []byte, opts *store.MirrorOptions) (*http.Response, error) {
+	return f.request("POST", "v2/snaps/refresh", nil, body, opts)
+}
+
+func (f *fakeStore) MirrorAssertion(ctx context.Context, assertType *asserts.AssertionType, key []string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	return f.request("GET", "v2/assertions/"+assertType.Name+"/"+strings.Join(key, "/"), query, nil, opts)
+}
+
+func (f *fakeStore) request(method, path string, query url.Values, body []byte, opts *store.MirrorOptions) (*http.Response, error) {
+	f.requests = append(f.requests, upstreamRequest{
+		method:     method,
+		path:       path,
+		query:      query,
+		header:     opts.Header,
+		body:       string(body),
+		deviceAuth: opts.DeviceAuth,
+	})
+	respond := f.responses[path]
+	if respond == nil {
+		f.c.Fatalf("unexpected store request for %q", path)
+	}
+	status, contentType, respBody := respond(body)
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", contentType)
+	rec.WriteHeader(status)
+	io.WriteString(rec, respBody)
+	return rec.Result(), nil
+}
+
+func (f *fakeStore) Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	f.downloads = append(f.downloads, fmt.Sprintf("%s %s no-device-auth:%v", name, downloadInfo.DownloadURL, dlOpts.NoDeviceAuth))
+	blob, ok := f.blobs[downloadInfo.Sha3_384]
+	if !ok {
+		return fmt.Errorf("cannot download %q", name)
+	}
+	return os.WriteFile(targetPath, blob, 0644)
+}
+
+type fakeCache map[string]string
+
+func (c fakeCache) GetPath(cacheKey string) string {
+	return c[cacheKey]
+}
+
+func digest(blob []byte) string {
+	h := crypto.SHA3_384.New()
+	h.Write(blob)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *mirrorSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.upstream = &fakeStore{
+		c:         c,
+		responses: make(map[string]func([]byte) (int, string, string)),
+		blobs:     make(map[string][]byte),
+	}
+	s.cache = make(fakeCache)
+
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	s.db = db
+
+	s.server = httptest.NewServer(mirror.NewHandler(&mirror.Config{
+		Store:       s.upstream,
+		Assertions:  s.db,
+		Cache:       s.cache,
+		DownloadDir: c.MkDir(),
+	}))
+	s.AddCleanup(s.server.Close)
+
+	mirrorURL, err := url.Parse(s.server.URL)
+	c.Assert(err, IsNil)
+	// the store of a device pointed at the mirror
+	s.client = store.New(&store.Config{
+		StoreBaseURL:      mirrorURL,
+		AssertionsBaseURL: mirrorURL,
+		Architecture:      "arm64",
+		Series:            "16",
+	}, nil)
+}
+
+const infoJSON = `{
+  "name": "foo",
+  "snap-id": "foo-id",
+  "snap": {"name": "foo", "snap-id": "foo-id", "publisher": {"id": "foo-publisher", "username": "foo"}},
+  "channel-map": [
+    {"channel": {"architecture": "arm64", "name": "stable", "risk": "stable", "track": "latest", "released-at": "2026-01-01T00:00:00Z"},
+     "revision": 2, "version": "2.0", "type": "app", "confinement": "strict",
+     "snap-yaml": "name: foo\nversion: 2.0\napps:\n  foo:\n    command: bin/foo\n",
+     "download": {"url": "https://upstream/download/foo_2.snap", "size": %d, "sha3-384": %q,
+                  "deltas": [{"format": "xdelta3", "source": 1, "target": 2, "url": "https://upstream/download/foo_1_2.delta", "size": 1, "sha3-384": "abc"}]}}
+  ]
+}`
+
+func (s *mirrorSuite) TestSnapInfo(c *C) {
+	blob := []byte("foo snap")
+	s.upstream.responses["v2/snaps/info/foo"] = func([]byte) (int, string, string) {
+		return 200, "application/json", fmt.Sprintf(infoJSON, len(blob), digest(blob))
+	}
+
+	info, err := s.client.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.SnapName(), Equals, "foo")
+	c.Check(info.Revision, Equals, snap.R(2))
+	// the snap yaml went through unchanged
+	c.Check(info.Apps, HasLen, 1)
+	c.Check(info.DownloadInfo, DeepEquals, snap.DownloadInfo{
+		DownloadURL: s.server.URL + "/v2/mirror/downloads/" + digest(blob),
+		Size:        int64(len(blob)),
+		Sha3_384:    digest(blob),
+	})
+
+	c.Assert(s.upstream.requests, HasLen, 1)
+	req := s.upstream.requests[0]
+	c.Check(req.method, Equals, "GET")
+	c.Check(req.query.Get("architecture"), Equals, "arm64")
+	// the request is made for the device using the mirror
+	c.Check(req.header["Snap-Device-Architecture"], Equals, "arm64")
+	c.Check(req.header["Snap-Device-Series"], Equals, "16")
+	c.Check(req.header["Authorization"], Equals, "")
+	// and is anonymous
+	c.Check(req.deviceAuth, Equals, false)
+}
+
+func (s *mirrorSuite) TestSnapInfoQuery(c *C) {
+	s.upstream.responses["v2/snaps/info/foo"] = func([]byte) (int, string, string) {
+		return 404, "application/json", `{"error-list": []}`
+	}
+
+	resp, err := http.Get(s.server.URL + "/v2/snaps/info/foo?fields=revision&architecture=arm64&other=bar")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 404)
+
+	// only the known query parameters are passed on
+	c.Assert(s.upstream.requests, HasLen, 1)
+	c.Check(s.upstream.requests[0].query, DeepEquals, url.Values{
+		"fields":       {"revision"},
+		"architecture": {"arm64"},
+	})
+}
+
+func (s *mirrorSuite) TestDeviceAuth(c *C) {
+	blob := []byte("foo snap")
+	s.upstream.blobs[digest(blob)] = blob
+	s.upstream.responses["v2/snaps/info/foo"] = func([]byte) (int, string, string) {
+		return 200, "application/json", fmt.Sprintf(infoJSON, len(blob), digest(blob))
+	}
+	server := httptest.NewServer(mirror.NewHandler(&mirror.Config{
+		Store:       s.upstream,
+		Cache:       s.cache,
+		DownloadDir: c.MkDir(),
+		DeviceAuth:  true,
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v2/snaps/info/foo")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 200)
+	resp, err = http.Get(server.URL + "/v2/mirror/downloads/" + digest(blob))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 200)
+
+	// requests are authorized as coming from the device only when asked
+	c.Assert(s.upstream.requests, HasLen, 1)
+	c.Check(s.upstream.requests[0].deviceAuth, Equals, true)
+	c.Check(s.upstream.downloads, DeepEquals, []string{"foo https://upstream/download/foo_2.snap no-device-auth:false"})
+}
+
+func (s *mirrorSuite) TestInvalidRequests(c *C) {
+	for _, path := range []string{
+		"/v2/snaps/info/",
+		"/v2/snaps/info/..%2Frefresh",
+		"/v2/snaps/info/foo%2F..%2F..%2Fassertions",
+		"/v2/snaps/info/Foo_Bar",
+		"/v2/snaps/info/foo_instance",
+		"/v2/assertions/",
+		"/v2/assertions/no-such-type/foo",
+		"/v2/assertions/account",
+		"/v2/assertions/account/..",
+		"/v2/assertions/account/%2E%2E",
+		"/v2/assertions/account/foo/bar",
+		"/v2/assertions/account/..%2F..%2Fsnaps%2Finfo%2Ffoo",
+		"/v2/assertions/snap-declaration/16/..",
+		"/v2/assertions/snap-declaration/16/foo-id?sequence=latest",
+		"/v2/assertions/validation-set/16/acc-id/..?sequence=latest",
+	} {
+		resp, err := http.Get(s.server.URL + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404, Commentf(path))
+	}
+	// nothing was passed on to the store
+	c.Check(s.upstream.requests, HasLen, 0)
+}
+
+func (s *mirrorSuite) TestSnapInfoNotFound(c *C) {
+	s.upstream.responses["v2/snaps/info/foo"] = func([]byte) (int, string, string) {
+		return 404, "application/json", `{"error-list": [{"code": "resource-not-found", "message": "No snap named 'foo' found in series '16'."}]}`
+	}
+
+	_, err := s.client.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *mirrorSuite) TestDownload(c *C) {
+	blob := []byte("foo snap")
+	s.upstream.blobs[digest(blob)] = blob
+	s.upstream.responses["v2/snaps/info/foo"] = func([]byte) (int, string, string) {
+		return 200, "application/json", fmt.Sprintf(infoJSON, len(blob), digest(blob))
+	}
+
+	info, err := s.client.SnapInfo(context.Background(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "foo_2.snap")
+	err = s.client.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, blob)
+	// the mirror downloaded it from its store
+	// anonymously
+	c.Check(s.upstream.downloads, DeepEquals, []string{"foo https://upstream/download/foo_2.snap no-device-auth:true"})
+
+	// once in the cache of the mirror, it is served from there
+	cached := filepath.Join(c.MkDir(), "cached")
+	c.Assert(os.WriteFile(cached, blob, 0644), IsNil)
+	s.cache[digest(blob)] = cached
+	s.upstream.downloads = nil
+
+	target = filepath.Join(c.MkDir(), "foo_2.snap")
+	err = s.client.Download(context.Background(), "foo", target, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, blob)
+	c.Check(s.upstream.downloads, HasLen, 0)
+}
+
+func (s *mirrorSuite) TestDownloadUnknown(c *C) {
+	blob := []byte("foo snap")
+	resp, err := http.Get(s.server.URL + "/v2/mirror/downloads/" + digest(blob))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 404)
+
+	resp, err = http.Get(s.server.URL + "/v2/mirror/downloads/../../etc/passwd")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 404)
+	c.Check(s.upstream.downloads, HasLen, 0)
+}
+
+func (s *mirrorSuite) TestRefresh(c *C) {
+	blob := []byte("foo snap")
+	s.upstream.responses["v2/snaps/refresh"] = func(body []byte) (int, string, string) {
+		return 200, "application/json", fmt.Sprintf(`{"results": [{
+  "result": "refresh", "instance-key": "foo-id", "snap-id": "foo-id", "name": "foo", "effective-channel": "stable",
+  "snap": {"name": "foo", "snap-id": "foo-id", "revision": 2, "version": "2.0", "type": "app",
+           "download": {"url": "https://upstream/download/foo_2.snap", "size": %d, "sha3-384": %q}}
+}]}`, len(blob), digest(blob))
+	}
+
+	results, _, err := s.client.SnapAction(context.Background(), []*store.CurrentSnap{{
+		InstanceName:    "foo",
+		SnapID:          "foo-id",
+		Revision:        snap.R(1),
+		TrackingChannel: "latest/stable",
+	}}, []*store.SnapAction{{
+		Action:       "refresh",
+		InstanceName: "foo",
+		SnapID:       "foo-id",
+	}}, nil, nil, &store.RefreshOptions{Scheduled: true})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Info.Revision, Equals, snap.R(2))
+	c.Check(results[0].Info.DownloadURL, Equals, s.server.URL+"/v2/mirror/downloads/"+digest(blob))
+
+	c.Assert(s.upstream.requests, HasLen, 1)
+	req := s.upstream.requests[0]
+	c.Check(req.method, Equals, "POST")
+	c.Check(req.header["Snap-Refresh-Reason"], Equals, "scheduled")
+	var body map[string]any
+	c.Assert(json.Unmarshal([]byte(req.body), &body), IsNil)
+	c.Check(body["context"], HasLen, 1)
+	c.Check(body["actions"], HasLen, 1)
+}
+
+func (s *mirrorSuite) TestRefreshFetchAssertionsUnsupported(c *C) {
+	s.upstream.responses["v2/snaps/refresh"] = func(body []byte) (int, string, string) {
+		return 200, "application/json", `{"results": [{"result": "install", "instance-key": "install-1", "snap-id": "bar-id", "name": "bar", "snap": {"name": "bar", "snap-id": "bar-id", "revision": 3}}]}`
+	}
+
+	post := func(body string) map[string]any {
+		resp, err := http.Post(s.server.URL+"/v2/snaps/refresh", "application/json", strings.NewReader(body))
+		c.Assert(err, IsNil)
+		defer resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, 200)
+		var result map[string]any
+		c.Assert(json.NewDecoder(resp.Body).Decode(&result), IsNil)
+		return result
+	}
+	unsupported := map[string]any{
+		"code":    "fetch-assertions-unsupported",
+		"message": "fetching assertions through refresh requests is not supported by the store mirror",
+	}
+
+	// only assertions are requested, the store is not asked
+	result := post(`{"context": [], "actions": [{"action": "fetch-assertions", "key": "g1", "assertions": [{"type": "snap-declaration", "primary-key": ["16", "foo-id"]}]}]}`)
+	c.Check(result, DeepEquals, map[string]any{
+		"results":    []any{},
+		"error-list": []any{unsupported},
+	})
+	c.Check(s.upstream.requests, HasLen, 0)
+
+	// fetching assertions is dropped from requests for snaps
+	result = post(`{"context": [], "actions": [{"action": "install", "instance-key": "install-1", "name": "bar", "epoch": null}, {"action": "fetch-assertions", "key": "g1"}]}`)
+	c.Check(result["error-list"], DeepEquals, []any{unsupported})
+	c.Check(result["results"], HasLen, 1)
+	c.Assert(s.upstream.requests, HasLen, 1)
+	c.Check(s.upstream.requests[0].body, Equals, `{"actions":[{"action":"install","instance-key":"install-1","name":"bar","epoch":null}],"context":[]}`)
+}
+
+func (s *mirrorSuite) TestAssertionFromDatabase(c *C) {
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	c.Assert(s.db.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(s.db.Add(acct), IsNil)
+
+	a, err := s.client.Assertion(asserts.AccountType, []string{acct.AccountID()}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a, DeepEquals, acct)
+	c.Check(s.upstream.requests, HasLen, 0)
+}
+
+func (s *mirrorSuite) TestAssertionFromStore(c *C) {
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	s.upstream.responses["v2/assertions/account/"+acct.AccountID()] = func([]byte) (int, string, string) {
+		return 200, asserts.MediaType, string(asserts.Encode(acct))
+	}
+	s.upstream.responses["v2/assertions/account/missing"] = func([]byte) (int, string, string) {
+		return 404, "application/problem+json", `{"error-list": [{"code": "not-found", "message": "not found"}]}`
+	}
+
+	a, err := s.client.Assertion(asserts.AccountType, []string{acct.AccountID()}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a, DeepEquals, acct)
+
+	_, err = s.client.Assertion(asserts.AccountType, []string{"missing"}, nil)
+	c.Check(err, FitsTypeOf, &asserts.NotFoundError{})
+	c.Check(s.upstream.requests, HasLen, 2)
+}
+
+func (s *mirrorSuite) TestReadOnly(c *C) {
+	for _, tc := range []struct{ method, path string }{
+		{"POST", "/v2/snaps/info/foo"},
+		{"GET", "/v2/snaps/refresh"},
+		{"DELETE", "/v2/assertions/account/foo"},
+		{"GET", "/api/v1/snaps/auth/nonces"},
+		{"POST", "/v2/mirror/downloads/foo"},
+	} {
+		req, err := http.NewRequest(tc.method, s.server.URL+tc.path, bytes.NewReader(nil))
+		c.Assert(err, IsNil)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404, Commentf("%s %s", tc.method, tc.path))
+	}
+	c.Check(s.upstream.requests, HasLen, 0)
+}
@@ -162,6 +162,8 @@ type Store struct {
 
 	dauthCtx DeviceAndAuthContext
 
+	bandwidth *bandwidthMeter
+
 	mu                sync.Mutex
 	suggestedCurrency string
 
@@ -394,6 +396,7 @@ func New(cfg *Config, dauthCtx DeviceAndAuthContext) *Store {
 		infoFields:         infoFields,
 		findFields:         findFields,
 		dauthCtx:           dauthCtx,
+		bandwidth:          &bandwidthMeter{dauthCtx: dauthCtx},
 		proxy:              cfg.Proxy,
 		proxyConnectHeader: proxyConnectHeader,
 		userAgent:          userAgent,
@@ -471,7 +474,15 @@ func (s *Store) newHTTPClient(opts *httputil.ClientOptions) *http.Client {
 	opts.ExtraSSLCerts = &httputil.ExtraSSLCertsFromDir{
 		Dir: dirs.SnapdStoreSSLCertsDir,
 	}
-	return httputilNewHTTPClient(opts)
+	client := httputilNewHTTPClient(opts)
+	if s.bandwidth != nil {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		client.Transport = &bandwidthTransport{RoundTripper: transport, meter: s.bandwidth}
+	}
+	return client
 }
 
 func (s *Store) defaultSnapQuery() url.Values {
@@ -583,6 +594,7 @@ type deviceAuthNeed int
 const (
 	deviceAuthPreferred deviceAuthNeed = iota
 	deviceAuthCustomStoreOnly
+	deviceAuthNever
 )
 
 // requestOptions specifies parameters for store requests.
@@ -600,6 +612,7 @@ type requestOptions struct {
 	//  - deviceAuthPreferred: should be provided if available
 	//  - deviceAuthCustomStoreOnly: should be provided only in case
 	//    of a custom store
+	//  - deviceAuthNever: must not be provided, not even to a custom store
 	DeviceAuthNeed deviceAuthNeed
 }
 
@@ -828,7 +841,8 @@ func (s *Store) newRequest(ctx context.Context, reqOptions *requestOptions, user
 
 	customStore := s.setStoreID(req, reqOptions.APILevel)
 	authOpts := AuthorizeOptions{apiLevel: reqOptions.APILevel}
-	authOpts.deviceAuth = customStore || reqOptions.DeviceAuthNeed != deviceAuthCustomStoreOnly
+	authOpts.deviceAuth = reqOptions.DeviceAuthNeed != deviceAuthNever &&
+		(customStore || reqOptions.DeviceAuthNeed != deviceAuthCustomStoreOnly)
 	if authOpts.deviceAuth {
 		err := s.EnsureDeviceSession()
 		if err != nil && err != ErrNoSerial {
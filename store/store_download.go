@@ -44,12 +44,14 @@ import (
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/snapdtool"
+	"github.com/snapcore/snapd/store/lanshare"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -113,6 +115,9 @@ type DownloadOptions struct {
 	RateLimit           int64
 	Scheduled           bool
 	LeavePartialOnError bool
+	// NoDeviceAuth is whether the download must not be authorized as
+	// coming from this device.
+	NoDeviceAuth bool
 }
 
 // Download downloads the snap addressed by download info and returns its
@@ -136,6 +141,10 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		return nil
 	}
 
+	if s.downloadFromLANPeers(ctx, name, targetPath, downloadInfo) {
+		return nil
+	}
+
 	if len(s.supportedDeltaFormats()) > 0 {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 		if len(downloadInfo.Deltas) > 0 {
@@ -350,6 +359,9 @@ func downloadReqOpts(storeURL *url.URL, cdnHeader string, opts *DownloadOptions)
 	if opts != nil && opts.Scheduled {
 		reqOptions.ExtraHeaders["Snap-Refresh-Reason"] = "scheduled"
 	}
+	if opts != nil && opts.NoDeviceAuth {
+		reqOptions.DeviceAuthNeed = deviceAuthNever
+	}
 
 	return &reqOptions
 }
@@ -464,8 +476,62 @@ func (w *TransferSpeedMonitoringWriter) Err() error {
 
 var ratelimitReader = ratelimit.Reader
 
+// lanPeers returns the peers on the local network to fetch the given snap or
+// component from, if any.
+func (s *Store) lanPeers(name string) []string {
+	if s.dauthCtx == nil {
+		return nil
+	}
+	peers, err := s.dauthCtx.LANPeers()
+	if err != nil {
+		logger.Noticef("cannot get peers to fetch %s from: %v", name, err)
+		return nil
+	}
+	return peers
+}
+
+// downloadFromLANPeers tries to fetch the blob from peers on the local
+// network, if sharing is enabled, placing it in the cache. It returns whether
+// the blob was fetched.
+func (s *Store) downloadFromLANPeers(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo) bool {
+	if downloadInfo.Sha3_384 == "" {
+		return false
+	}
+	peers := s.lanPeers(name)
+	if len(peers) == 0 {
+		return false
+	}
+	peer, err := lanshare.Fetch(ctx, peers, downloadInfo.Sha3_384, downloadInfo.Size, targetPath)
+	if err != nil {
+		if err != lanshare.ErrNotFound {
+			logger.Noticef("Cannot fetch %s from peers: %v", name, err)
+		}
+		return false
+	}
+	logger.Debugf("Fetched %s from peer %s.", name, peer)
+	if metrics.Enabled() {
+		lanshareFetches.Inc()
+	}
+	if err := s.cacher.Put(downloadInfo.Sha3_384, targetPath); err != nil {
+		logger.Noticef("Cannot place blob for %s fetched from peer in cache: %v", name, err)
+	}
+	return true
+}
+
 var download = downloadImpl
 
+var (
+	downloadedBytes = metrics.NewCounterVec("snapd_store_download_bytes",
+		"Number of bytes downloaded from the store for snaps and components.")
+	downloads = metrics.NewCounterVec("snapd_store_downloads",
+		"Number of downloads from the store, by result.", "result")
+	downloadDuration = metrics.NewHistogramVec("snapd_store_download_duration_seconds",
+		"Duration of successful downloads from the store.",
+		[]float64{1, 5, 10, 30, 60, 120, 300, 600, 1800})
+	lanshareFetches = metrics.NewCounterVec("snapd_store_lan_peer_fetches",
+		"Number of snaps and components fetched from peers on the local network instead of the store.")
+)
+
 // download writes an http.Request showing a progress.Meter
 func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 	if dlOpts == nil {
@@ -512,6 +578,10 @@ func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user
 		if cancelled(downloadCtx) {
 			return fmt.Errorf("the download has been cancelled: %s", downloadCtx.Err())
 		}
+		policy, err := s.bandwidth.waitWhilePaused(downloadCtx, name)
+		if err != nil {
+			return err
+		}
 		var resp *http.Response
 		cli := s.newHTTPClient(nil) // XXX: there's no timeout defined for this client, and the context is context.TODO(), so it won't be cancelled
 		oldCheckRedirect := cli.CheckRedirect
@@ -525,7 +595,7 @@ func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user
 			dropAuthorization(req, &AuthorizeOptions{deviceAuth: true, apiLevel: reqOptions.APILevel})
 			return oldCheckRedirect(req, via)
 		}
-		resp, finalErr = s.doRequest(downloadCtx, cli, reqOptions, user)
+		resp, finalErr = s.doRequest(withBandwidthPolicy(downloadCtx, policy), cli, reqOptions, user)
 		if cancelled(downloadCtx) {
 			return fmt.Errorf("the download has been cancelled: %s", downloadCtx.Err())
 		}
@@ -577,10 +647,17 @@ func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user
 			bucket := ratelimit.NewBucketWithRate(float64(limit), 2*limit)
 			limiter = ratelimitReader(resp.Body, bucket)
 		}
+		if policy != nil {
+			limiter = &downloadReader{Reader: limiter, meter: s.bandwidth, policy: policy}
+		}
 
 		stopMonitorCh := tc.Monitor()
-		_, finalErr = io.Copy(mw, limiter)
+		var n int64
+		n, finalErr = io.Copy(mw, limiter)
 		close(stopMonitorCh)
+		if metrics.Enabled() {
+			downloadedBytes.Add(float64(n))
+		}
 		pbar.Finished()
 
 		if err := tc.Err(); err != nil {
@@ -592,6 +669,19 @@ func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user
 			return fmt.Errorf("the download has been cancelled: %s", downloadCtx.Err())
 		}
 
+		if finalErr == errDownloadPaused {
+			// resume once the bandwidth policy allows it again,
+			// the time spent paused does not count as retries
+			resp.Body.Close()
+			var seekerr error
+			resume, seekerr = w.Seek(0, io.SeekEnd)
+			if seekerr != nil {
+				finalErr = seekerr
+				break
+			}
+			attempt = retry.Start(downloadRetryStrategy, nil)
+			continue
+		}
 		if finalErr != nil {
 			if httputil.ShouldRetryAttempt(attempt, finalErr) {
 				// error while downloading should resume
@@ -611,9 +701,18 @@ func downloadImpl(ctx context.Context, name, sha3_384, downloadURL string, user
 		}
 		break
 	}
-	if finalErr == nil {
+	collectMetrics := metrics.Enabled()
+	if finalErr != nil {
+		if collectMetrics {
+			downloads.Inc("error")
+		}
+	} else {
 		// not using quantity.FormatFoo as this is just for debug
 		dt := time.Since(startTime)
+		if collectMetrics {
+			downloads.Inc("success")
+			downloadDuration.Observe(dt.Seconds())
+		}
 		r := dlSize / dt.Seconds()
 		var p rune
 		for _, p = range " kMGTPEZY" {
@@ -829,9 +928,14 @@ var applyDelta = func(ctx context.Context, s *Store, name string, deltaPath stri
 
 var squashfsApplyDelta = squashfs.ApplyDelta
 
-func (s *Store) applyDeltaImpl(ctx context.Context, name string, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, targetSha3_384 string) error {
+// deltaSourcePath returns the path of the local revision the delta applies to.
+func deltaSourcePath(name string, deltaInfo *snap.DeltaInfo) string {
 	snapBase := fmt.Sprintf("%s_%d.snap", name, deltaInfo.FromRevision)
-	snapPath := filepath.Join(dirs.SnapBlobDir, snapBase)
+	return filepath.Join(dirs.SnapBlobDir, snapBase)
+}
+
+func (s *Store) applyDeltaImpl(ctx context.Context, name string, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, targetSha3_384 string) error {
+	snapPath := deltaSourcePath(name, deltaInfo)
 
 	if !osutil.FileExists(snapPath) {
 		return fmt.Errorf("snap %q revision %d not found at %s", name, deltaInfo.FromRevision, snapPath)
@@ -886,6 +990,10 @@ func (s *Store) downloadAndApplyDelta(ctx context.Context, name, targetPath stri
 	deltaPath := fmt.Sprintf("%s.%s-%d-to-%d.partial", targetPath,
 		deltaInfo.Format, deltaInfo.FromRevision, deltaInfo.ToRevision)
 
+	if s.applyDeltaFromLANPeers(ctx, name, deltaPath, deltaInfo, targetPath, downloadInfo) {
+		return nil
+	}
+
 	w, err := os.OpenFile(deltaPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
@@ -914,6 +1022,52 @@ func (s *Store) downloadAndApplyDelta(ctx context.Context, name, targetPath stri
 	return nil
 }
 
+// applyDeltaFromLANPeers fetches the delta from peers on the local network
+// and applies it, returning whether the target was successfully rebuilt.
+// Peers provide deltas keyed by the digests of the revisions they go from
+// and to, generating them from their download cache if needed.
+func (s *Store) applyDeltaFromLANPeers(ctx context.Context, name, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, downloadInfo *snap.DownloadInfo) bool {
+	if downloadInfo.Sha3_384 == "" {
+		return false
+	}
+	peers := s.lanPeers(name)
+	if len(peers) == 0 {
+		return false
+	}
+	digest, _, err := osutil.FileDigest(deltaSourcePath(name, deltaInfo), crypto.SHA3_384)
+	if err != nil {
+		logger.Debugf("Cannot compute the digest of the delta source for %q: %v", name, err)
+		return false
+	}
+	sourceDigest := fmt.Sprintf("%x", digest)
+
+	defer os.Remove(deltaPath)
+
+	// a delta as large as the snap itself is of no use
+	peer, err := lanshare.FetchDelta(ctx, peers, deltaInfo.Format, sourceDigest, downloadInfo.Sha3_384, downloadInfo.Size, deltaPath)
+	if err != nil {
+		if err != lanshare.ErrNotFound {
+			logger.Noticef("Cannot fetch delta for %s from peers: %v", name, err)
+		}
+		return false
+	}
+	// the rebuilt target is verified against its expected digest
+	if err := applyDelta(ctx, s, name, deltaPath, deltaInfo, targetPath, downloadInfo.Sha3_384); err != nil {
+		logger.Noticef("Cannot apply delta for %s fetched from peer %s: %v", name, peer, err)
+		return false
+	}
+	logger.Debugf("Fetched delta for %s from peer %s.", name, peer)
+	if metrics.Enabled() {
+		lanshareFetches.Inc()
+	}
+	// keep the delta around for other peers
+	key := DeltaCacheKey(deltaInfo.Format, sourceDigest, downloadInfo.Sha3_384)
+	if err := s.cacher.Put(key, deltaPath); err != nil {
+		logger.Noticef("Cannot place delta for %s fetched from peer in cache: %v", name, err)
+	}
+	return true
+}
+
 // CacheDownloads returns the configured cache policy.
 func (s *Store) CachePolicy() CachePolicy {
 	return s.cfg.CachePolicy
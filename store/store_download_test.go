@@ -48,6 +48,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/lanshare"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -858,6 +859,153 @@ func (s *storeDownloadSuite) TestDownloadCacheMiss(c *C) {
 	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("the-snaps-sha3_384:%s", path)})
 }
 
+func (s *storeDownloadSuite) TestDownloadFromLANPeer(c *C) {
+	content := "fetched from a peer"
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	digest := fmt.Sprintf("%x", h.Sum(nil))
+
+	peerCache := store.NewCacheManager(c.MkDir(), store.CachePolicy{})
+	c.Assert(peerCache.Put(digest, s.makeFile(c, content)), IsNil)
+	peer := httptest.NewServer(lanshare.NewHandler(peerCache))
+	defer peer.Close()
+	// a peer without the snap is skipped
+	otherPeer := httptest.NewServer(lanshare.NewHandler(store.NewCacheManager(c.MkDir(), store.CachePolicy{})))
+	defer otherPeer.Close()
+
+	dauthCtx := &testDauthContext{c: c, device: s.device, lanPeers: []string{
+		strings.TrimPrefix(otherPeer.URL, "http://"),
+		strings.TrimPrefix(peer.URL, "http://"),
+	}}
+	sto := store.New(&store.Config{}, dauthCtx)
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	restore := sto.MockCacher(obs)
+	defer restore()
+
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatalf("the store should not be used when a peer has the snap")
+		return nil
+	})
+	defer restore()
+
+	info := &snap.Info{}
+	info.Sha3_384 = digest
+	info.Size = int64(len(content))
+
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("%s:%s", digest, path)})
+}
+
+func (s *storeDownloadSuite) TestDownloadFromLANPeerMismatchFallsBackToStore(c *C) {
+	content := "from the store"
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	digest := fmt.Sprintf("%x", h.Sum(nil))
+
+	// the peer has tampered data under the digest of the snap
+	peerCache := store.NewCacheManager(c.MkDir(), store.CachePolicy{})
+	c.Assert(peerCache.Put(digest, s.makeFile(c, "from a peer...")), IsNil)
+	peer := httptest.NewServer(lanshare.NewHandler(peerCache))
+	defer peer.Close()
+
+	dauthCtx := &testDauthContext{c: c, device: s.device, lanPeers: []string{
+		strings.TrimPrefix(peer.URL, "http://"),
+	}}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	downloadWasCalled := false
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		downloadWasCalled = true
+		_, err := w.Write([]byte(content))
+		return err
+	})
+	defer restore()
+
+	info := &snap.Info{}
+	info.Sha3_384 = digest
+	info.Size = int64(len(content))
+
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(downloadWasCalled, Equals, true)
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(s.logbuf.String(), testutil.Contains, "Cannot fetch foo from peers: cannot fetch blob from peers: ")
+	c.Check(s.logbuf.String(), testutil.Contains, "sha3-384 mismatch")
+}
+
+func (s *storeDownloadSuite) TestDownloadDeltaFromLANPeer(c *C) {
+	sha3 := func(content string) string {
+		h := crypto.SHA3_384.New()
+		h.Write([]byte(content))
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+	source, target := "revision 0", "revision 1"
+
+	// the peer does not have the new revision, but has the delta to it
+	peerCache := store.NewCacheManager(c.MkDir(), store.CachePolicy{})
+	deltaKey := store.DeltaCacheKey("xdelta3", sha3(source), sha3(target))
+	c.Assert(peerCache.Put(sha3(source), s.makeFile(c, source)), IsNil)
+	c.Assert(peerCache.Put(deltaKey, s.makeFile(c, "delta")), IsNil)
+	peer := httptest.NewServer(lanshare.NewHandler(peerCache))
+	defer peer.Close()
+
+	oldRevBlob := filepath.Join(dirs.SnapBlobDir, "foo_0.snap")
+	c.Assert(os.MkdirAll(filepath.Dir(oldRevBlob), 0755), IsNil)
+	c.Assert(os.WriteFile(oldRevBlob, []byte(source), 0644), IsNil)
+
+	dauthCtx := &testDauthContext{c: c, device: s.device, lanPeers: []string{
+		strings.TrimPrefix(peer.URL, "http://"),
+	}}
+	sto := store.New(&store.Config{}, dauthCtx)
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	restore := sto.MockCacher(obs)
+	defer restore()
+
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatalf("the store should not be used when a peer has the delta")
+		return nil
+	})
+	defer restore()
+	restore = store.MockApplyDelta(func(_ context.Context, s *store.Store, name string, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, targetSha3_384 string) error {
+		c.Check(deltaPath, testutil.FileEquals, "delta")
+		c.Check(targetSha3_384, Equals, sha3(target))
+		return os.WriteFile(targetPath, []byte(target), 0600)
+	})
+	defer restore()
+
+	info := &snap.Info{}
+	info.Sha3_384 = sha3(target)
+	info.Size = int64(len(target))
+	info.DownloadURL = "http://download.url/get"
+	info.Deltas = []snap.DeltaInfo{{
+		FromRevision: 0,
+		ToRevision:   1,
+		Format:       "xdelta3",
+		DownloadURL:  "http://delta.download.url/get",
+	}}
+
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := sto.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, target)
+	deltaPath := path + ".xdelta3-0-to-1.partial"
+	c.Check(obs.puts, DeepEquals, []string{
+		fmt.Sprintf("%s:%s", deltaKey, deltaPath),
+		fmt.Sprintf("%s:%s", sha3(target), path),
+	})
+	c.Check(deltaPath, testutil.FileAbsent)
+}
+
+func (s *storeDownloadSuite) makeFile(c *C, content string) string {
+	p := filepath.Join(c.MkDir(), "blob")
+	c.Assert(os.WriteFile(p, []byte(content), 0644), IsNil)
+	return p
+}
+
 func (s *storeDownloadSuite) TestDownloadDeltaCacheMiss(c *C) {
 	obs := &cacheObserver{inCache: map[string]bool{}}
 	restore := s.store.MockCacher(obs)
@@ -1356,6 +1504,27 @@ func (s *storeDownloadSuite) TestDownloadRedirectHideAuthHeaders(c *C) {
 	c.Assert(targetFn, testutil.FileEquals, "test-download")
 }
 
+func (s *storeDownloadSuite) TestDownloadNoDeviceAuth(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, exists := r.Header["X-Device-Authorization"]
+		c.Check(exists, Equals, false)
+		io.WriteString(w, "test-download")
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	snap := &snap.Info{}
+	snap.DownloadURL = mockServer.URL
+
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	err := sto.Download(s.ctx, "foo", targetFn, &snap.DownloadInfo, nil, nil, &store.DownloadOptions{NoDeviceAuth: true})
+	c.Assert(err, IsNil)
+	c.Assert(targetFn, testutil.FileEquals, "test-download")
+}
+
 func (s *storeDownloadSuite) TestDownloadNoCheckRedirectPanic(c *C) {
 	restore := store.MockHttputilNewHTTPClient(func(opts *httputil.ClientOptions) *http.Client {
 		client := httputil.NewHTTPClient(opts)
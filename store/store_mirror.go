@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+// MirrorOptions holds options for the requests sent to the store on behalf
+// of a store mirror.
+type MirrorOptions struct {
+	// DeviceAuth is whether requests are authorized as coming from this
+	// device. Otherwise they are anonymous, so that other devices only get
+	// what the store serves to any device.
+	DeviceAuth bool
+	// Header holds headers describing the device the mirror serves, which
+	// override the ones this device would send.
+	Header map[string]string
+}
+
+// mirrorRequest sends a request for the given URL on behalf of a store
+// mirror and returns the response as is.
+func (s *Store) mirrorRequest(ctx context.Context, method string, u *url.URL, body []byte, opts *MirrorOptions) (*http.Response, error) {
+	if opts == nil {
+		opts = &MirrorOptions{}
+	}
+	reqOptions := &requestOptions{
+		Method:       method,
+		URL:          u,
+		Accept:       jsonContentType,
+		APILevel:     apiV2Endps,
+		ExtraHeaders: opts.Header,
+		Data:         body,
+	}
+	if !opts.DeviceAuth {
+		reqOptions.DeviceAuthNeed = deviceAuthNever
+	}
+	if body != nil {
+		reqOptions.ContentType = jsonContentType
+	}
+	// never on behalf of a user of this device
+	return s.doRequest(ctx, s.client, reqOptions, nil)
+}
+
+// MirrorSnapInfo requests the info of the snap with the given name on behalf
+// of a store mirror, with the given query, and returns the response as is.
+func (s *Store) MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *MirrorOptions) (*http.Response, error) {
+	if err := naming.ValidateSnap(name); err != nil {
+		return nil, err
+	}
+	u, err := s.endpointURL(path.Join(snapInfoEndpPath, name), query)
+	if err != nil {
+		return nil, err
+	}
+	return s.mirrorRequest(ctx, "GET", u, nil, opts)
+}
+
+// MirrorRefresh sends the given refresh request on behalf of a store mirror
+// and returns the response as is.
+func (s *Store) MirrorRefresh(ctx context.Context, body []byte, opts *MirrorOptions) (*http.Response, error) {
+	u, err := s.endpointURL(snapActionEndpPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.mirrorRequest(ctx, "POST", u, body, opts)
+}
+
+// MirrorAssertion requests the assertion of the given type with the given
+// primary key, or sequence key if the query asks for a sequence, on behalf of
+// a store mirror and returns the response as is.
+func (s *Store) MirrorAssertion(ctx context.Context, assertType *asserts.AssertionType, key []string, query url.Values, opts *MirrorOptions) (*http.Response, error) {
+	if len(key) == 0 || len(key) > len(assertType.PrimaryKey) {
+		return nil, fmt.Errorf("invalid key for %q assertion", assertType.Name)
+	}
+	for _, k := range key {
+		if k == "" || k == "." || k == ".." || strings.ContainsAny(k, "/\\") {
+			return nil, fmt.Errorf("invalid key for %q assertion: %q", assertType.Name, k)
+		}
+	}
+	u, err := s.assertionsEndpointURL(path.Join(assertType.Name, path.Join(key...)), query)
+	if err != nil {
+		return nil, err
+	}
+	return s.mirrorRequest(ctx, "GET", u, nil, opts)
+}
@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/store"
+)
+
+type storeMirrorSuite struct {
+	baseStoreSuite
+}
+
+var _ = Suite(&storeMirrorSuite{})
+
+func (s *storeMirrorSuite) TestMirrorRequests(c *C) {
+	var requests []string
+	var deviceAuth []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		deviceAuth = append(deviceAuth, r.Header.Get("Snap-Device-Authorization"))
+		switch r.URL.Path {
+		case "/v2/snaps/refresh":
+			c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+			// given headers override the ones of the device
+			c.Check(r.Header.Get("Snap-Device-Architecture"), Equals, "riscv64")
+			c.Check(r.Header.Get("Snap-Device-Series"), Equals, "16")
+			body, err := io.ReadAll(r.Body)
+			c.Assert(err, IsNil)
+			c.Check(string(body), Equals, `{"context":[],"actions":[]}`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(200)
+			io.WriteString(w, `{"results":[]}`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&store.Config{
+		StoreBaseURL:      mockServerURL,
+		AssertionsBaseURL: mockServerURL,
+		Series:            "16",
+	}, dauthCtx)
+
+	resp, err := sto.MirrorRefresh(s.ctx, []byte(`{"context":[],"actions":[]}`), &store.MirrorOptions{
+		Header: map[string]string{"Snap-Device-Architecture": "riscv64"},
+	})
+	c.Assert(err, IsNil)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(resp.StatusCode, Equals, 200)
+	c.Check(string(body), Equals, `{"results":[]}`)
+
+	resp, err = sto.MirrorSnapInfo(s.ctx, "foo", url.Values{"fields": {"revision"}}, nil)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 404)
+
+	resp, err = sto.MirrorAssertion(s.ctx, asserts.AccountType, []string{"foo"}, url.Values{"max-format": {"0"}}, &store.MirrorOptions{DeviceAuth: true})
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 404)
+
+	c.Check(requests, DeepEquals, []string{
+		"POST /v2/snaps/refresh?",
+		"GET /v2/snaps/info/foo?fields=revision",
+		"GET /v2/assertions/account/foo?max-format=0",
+	})
+	// requests are only authorized as coming from the device when asked
+	c.Check(deviceAuth, DeepEquals, []string{"", "", `Macaroon root="device-macaroon"`})
+}
+
+func (s *storeMirrorSuite) TestMirrorRequestsInvalid(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request for %q", r.URL.Path)
+	}))
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	sto := store.New(&store.Config{
+		StoreBaseURL:      mockServerURL,
+		AssertionsBaseURL: mockServerURL,
+	}, nil)
+
+	for _, name := range []string{"", "../refresh", "foo/../../bar", "Foo"} {
+		_, err := sto.MirrorSnapInfo(s.ctx, name, nil, nil)
+		c.Check(err, NotNil, Commentf(name))
+	}
+	for _, key := range [][]string{nil, {""}, {".."}, {"."}, {"foo/bar"}, {"foo", "bar"}} {
+		_, err := sto.MirrorAssertion(s.ctx, asserts.AccountType, key, nil, nil)
+		c.Check(err, ErrorMatches, `invalid key for "account" assertion.*`, Commentf("%q", key))
+	}
+}
@@ -248,6 +248,13 @@ type testDauthContext struct {
 	storeOffline bool
 
 	cloudInfo *auth.CloudInfo
+
+	lanPeers []string
+
+	bandwidthPolicy      *store.BandwidthPolicy
+	bandwidthMu          sync.Mutex
+	bandwidthUsage       *store.BandwidthUsage
+	bandwidthPolicyCalls int
 }
 
 func (dac *testDauthContext) Device() (*auth.DeviceState, error) {
@@ -325,6 +332,35 @@ func (sc *testDauthContext) WithSnapStoreDelta() bool {
 	return true
 }
 
+func (dac *testDauthContext) LANPeers() ([]string, error) {
+	return dac.lanPeers, nil
+}
+
+func (dac *testDauthContext) BandwidthPolicy() (*store.BandwidthPolicy, error) {
+	dac.bandwidthMu.Lock()
+	dac.bandwidthPolicyCalls++
+	dac.bandwidthMu.Unlock()
+	return dac.bandwidthPolicy, nil
+}
+
+func (dac *testDauthContext) BandwidthUsage() (*store.BandwidthUsage, error) {
+	dac.bandwidthMu.Lock()
+	defer dac.bandwidthMu.Unlock()
+	if dac.bandwidthUsage == nil {
+		return nil, nil
+	}
+	usage := *dac.bandwidthUsage
+	return &usage, nil
+}
+
+func (dac *testDauthContext) SetBandwidthUsage(usage *store.BandwidthUsage) error {
+	dac.bandwidthMu.Lock()
+	defer dac.bandwidthMu.Unlock()
+	u := *usage
+	dac.bandwidthUsage = &u
+	return nil
+}
+
 func (dac *testDauthContext) CloudInfo() (*auth.CloudInfo, error) {
 	return dac.cloudInfo, nil
 }
@@ -22,6 +22,8 @@ package storetest
 import (
 	"context"
 	"io"
+	"net/http"
+	"net/url"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
@@ -134,3 +136,15 @@ func (Store) CleanDownloadsCache() error {
 func (Store) ExchangeMessages(ctx context.Context, req *store.MessageExchangeRequest) (*store.MessageExchangeResponse, error) {
 	panic("ExchangeMessages not expected")
 }
+
+func (Store) MirrorSnapInfo(ctx context.Context, name string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	panic("MirrorSnapInfo not expected")
+}
+
+func (Store) MirrorRefresh(ctx context.Context, body []byte, opts *store.MirrorOptions) (*http.Response, error) {
+	panic("MirrorRefresh not expected")
+}
+
+func (Store) MirrorAssertion(ctx context.Context, assertType *asserts.AssertionType, key []string, query url.Values, opts *store.MirrorOptions) (*http.Response, error) {
+	panic("MirrorAssertion not expected")
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/snapcore/snapd/tests/lib/fakestore/refresh"
+)
+
+type cmdNewRequestMessage struct {
+	Positional struct {
+		Body string `description:"Path to the body of the request-message"`
+	} `positional-args:"yes"`
+
+	TopDir      string `long:"dir" description:"Directory to be used by the store to keep and serve snaps, <dir>/messages is used for the message queue"`
+	HeadersJSON string `long:"headers-json" description:"Path to JSON encoded request-message headers"`
+}
+
+func (x *cmdNewRequestMessage) Execute(args []string) error {
+	headers := map[string]any{}
+	if x.HeadersJSON != "" {
+		content, err := os.ReadFile(x.HeadersJSON)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(content, &headers); err != nil {
+			return err
+		}
+	}
+
+	if x.Positional.Body == "" {
+		return fmt.Errorf("body argument must be specified")
+	}
+
+	p, err := refresh.NewRequestMessage(x.TopDir, x.Positional.Body, headers)
+	if err != nil {
+		return err
+	}
+	fmt.Println(p)
+	return nil
+}
+
+var shortNewRequestMessageHelp = "Queue new request-message"
+
+var longNewRequestMessageHelp = `
+Generate a request-message signed with test keys and queue it for
+delivery to devices polling the store messaging endpoint. The headers
+must at least provide the message-kind and the target devices.
+`
+
+func init() {
+	parser.AddCommand("new-request-message", shortNewRequestMessageHelp, longNewRequestMessageHelp,
+		&cmdNewRequestMessage{})
+}
@@ -28,8 +28,10 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/systestkeys"
+	"github.com/snapcore/snapd/randutil"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/tests/lib/fakestore/store"
 )
 
 func snapNameFromPath(snapPath string) string {
@@ -230,3 +232,41 @@ func NewRepair(targetDir string, scriptFilename string, headers map[string]any)
 
 	return writeAssert(a, targetDir)
 }
+
+// NewRequestMessage signs a request-message assertion for the given devices,
+// including the specified file as the body of the assertion, and queues it
+// for delivery by the fake store messaging endpoint.
+func NewRequestMessage(targetDir string, bodyFilename string, headers map[string]any) (string, error) {
+	db, err := newAssertsDB(systestkeys.TestStorePrivKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	fallbacks := map[string]any{
+		"account-id":  "testrootorg",
+		"message-id":  randutil.RandomString(12),
+		"valid-since": now.Add(-time.Minute).Format(time.RFC3339),
+		"valid-until": now.Add(24 * time.Hour).Format(time.RFC3339),
+	}
+	for k, v := range fallbacks {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+
+	body, err := os.ReadFile(bodyFilename)
+	if err != nil {
+		return "", err
+	}
+
+	headers["authority-id"] = "testrootorg"
+	headers["timestamp"] = now.Format(time.RFC3339)
+
+	a, err := db.Sign(asserts.RequestMessageType, headers, body, systestkeys.TestStoreKeyID)
+	if err != nil {
+		return "", err
+	}
+
+	return store.QueueRequestMessage(targetDir, a)
+}
@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/store"
+)
+
+// Layout of the messaging queue below <topDir>/messages:
+//
+//	requests/<token>.assert         request-messages not yet acknowledged by the device
+//	acked/<token>.assert            request-messages acknowledged by the device
+//	responses/<message-id>.assert   response-messages sent by the device
+const (
+	messagesRequestsDir  = "requests"
+	messagesAckedDir     = "acked"
+	messagesResponsesDir = "responses"
+)
+
+func messagesDir(topDir string) string {
+	return filepath.Join(topDir, "messages")
+}
+
+func messageTokens(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, 0, len(matches))
+	for _, m := range matches {
+		tokens = append(tokens, strings.TrimSuffix(filepath.Base(m), ".assert"))
+	}
+	sort.Strings(tokens)
+	return tokens, nil
+}
+
+// QueueRequestMessage queues the given request-message assertion so that it
+// is delivered to devices polling the messaging endpoint of the store serving
+// from topDir. It returns the path of the queued assertion.
+func QueueRequestMessage(topDir string, a asserts.Assertion) (string, error) {
+	if a.Type() != asserts.RequestMessageType {
+		return "", fmt.Errorf("cannot queue %q assertion as a request-message", a.Type().Name)
+	}
+
+	dir := messagesDir(topDir)
+	requestsDir := filepath.Join(dir, messagesRequestsDir)
+	if err := os.MkdirAll(requestsDir, 0755); err != nil {
+		return "", err
+	}
+
+	// tokens are sequential across pending and acknowledged messages so that
+	// they keep growing even once the device has acknowledged everything
+	var seq int
+	for _, sub := range []string{messagesRequestsDir, messagesAckedDir} {
+		tokens, err := messageTokens(filepath.Join(dir, sub))
+		if err != nil {
+			return "", err
+		}
+		seq += len(tokens)
+	}
+
+	p := filepath.Join(requestsDir, fmt.Sprintf("%08d.assert", seq+1))
+	if err := osutil.AtomicWriteFile(p, asserts.Encode(a), 0644, 0); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func messagesError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error-list": []map[string]string{{"code": code, "message": msg}},
+	})
+}
+
+func (s *Store) ackRequestMessages(after string) error {
+	requestsDir := filepath.Join(s.messagesDir, messagesRequestsDir)
+	ackedDir := filepath.Join(s.messagesDir, messagesAckedDir)
+	tokens, err := messageTokens(requestsDir)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token > after {
+			break
+		}
+		if err := os.MkdirAll(ackedDir, 0755); err != nil {
+			return err
+		}
+		fn := token + ".assert"
+		if err := os.Rename(filepath.Join(requestsDir, fn), filepath.Join(ackedDir, fn)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) storeResponseMessages(msgs []store.Message) error {
+	responsesDir := filepath.Join(s.messagesDir, messagesResponsesDir)
+	for _, msg := range msgs {
+		if msg.Format != "assertion" {
+			return fmt.Errorf("unsupported message format %q", msg.Format)
+		}
+		a, err := asserts.Decode([]byte(msg.Data))
+		if err != nil {
+			return fmt.Errorf("cannot decode response-message: %v", err)
+		}
+		if a.Type() != asserts.ResponseMessageType {
+			return fmt.Errorf("unexpected %q assertion in place of a response-message", a.Type().Name)
+		}
+		if err := os.MkdirAll(responsesDir, 0755); err != nil {
+			return err
+		}
+		p := filepath.Join(responsesDir, a.HeaderString("message-id")+".assert")
+		if err := osutil.AtomicWriteFile(p, asserts.Encode(a), 0644, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) pendingRequestMessages(limit int) ([]store.MessageWithToken, int, error) {
+	requestsDir := filepath.Join(s.messagesDir, messagesRequestsDir)
+	tokens, err := messageTokens(requestsDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msgs := []store.MessageWithToken{}
+	for _, token := range tokens {
+		if len(msgs) >= limit {
+			break
+		}
+		data, err := os.ReadFile(filepath.Join(requestsDir, token+".assert"))
+		if err != nil {
+			return nil, 0, err
+		}
+		msgs = append(msgs, store.MessageWithToken{
+			Message: store.Message{Format: "assertion", Data: string(data)},
+			Token:   token,
+		})
+	}
+	return msgs, len(tokens), nil
+}
+
+func (s *Store) messagesEndpoint(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, fmt.Sprintf("unsupported method %q", req.Method), 405)
+		return
+	}
+
+	var exchange store.MessageExchangeRequest
+	dec := json.NewDecoder(req.Body)
+	if err := dec.Decode(&exchange); err != nil {
+		messagesError(w, 400, "invalid-request", fmt.Sprintf("cannot decode request: %v", err))
+		return
+	}
+	if exchange.Limit < 0 {
+		messagesError(w, 400, "invalid-request", fmt.Sprintf("limit must be non-negative, got %d", exchange.Limit))
+		return
+	}
+
+	s.messagesMu.Lock()
+	defer s.messagesMu.Unlock()
+
+	if exchange.After != "" {
+		if err := s.ackRequestMessages(exchange.After); err != nil {
+			messagesError(w, 500, "internal-error", fmt.Sprintf("cannot acknowledge request-messages: %v", err))
+			return
+		}
+	}
+
+	if err := s.storeResponseMessages(exchange.Messages); err != nil {
+		messagesError(w, 400, "invalid-message", err.Error())
+		return
+	}
+
+	msgs, total, err := s.pendingRequestMessages(exchange.Limit)
+	if err != nil {
+		messagesError(w, 500, "internal-error", fmt.Sprintf("cannot collect request-messages: %v", err))
+		return
+	}
+
+	resp := store.MessageExchangeResponse{
+		Messages:             msgs,
+		TotalPendingMessages: total,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(&resp)
+}
@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/systestkeys"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *storeTestSuite) makeRequestMessage(c *C, storeStack *assertstest.StoreStack, id string) asserts.Assertion {
+	now := time.Now()
+	a, err := storeStack.RootSigning.Sign(asserts.RequestMessageType, map[string]any{
+		"authority-id": "canonical",
+		"account-id":   "canonical",
+		"message-id":   id,
+		"message-kind": "snap-install",
+		"devices":      []any{"serial-1.my-model.my-brand"},
+		"valid-since":  now.Add(-time.Hour).UTC().Format(time.RFC3339),
+		"valid-until":  now.Add(time.Hour).UTC().Format(time.RFC3339),
+		"timestamp":    now.UTC().Format(time.RFC3339),
+	}, []byte(`{"snaps": [{"name": "hello"}]}`), "")
+	c.Assert(err, IsNil)
+	return a
+}
+
+func (s *storeTestSuite) exchangeMessages(c *C, req *store.MessageExchangeRequest) (int, []byte) {
+	b, err := json.Marshal(req)
+	c.Assert(err, IsNil)
+	resp, err := s.StorePostJSON("/v2/messages", b)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+
+	var body json.RawMessage
+	c.Assert(json.NewDecoder(resp.Body).Decode(&body), IsNil)
+	return resp.StatusCode, body
+}
+
+func (s *storeTestSuite) TestMessagesEndpointEmpty(c *C) {
+	status, body := s.exchangeMessages(c, &store.MessageExchangeRequest{Limit: 10})
+	c.Check(status, Equals, 200)
+	c.Check(string(body), Equals, `{"messages":[],"total-pending-messages":0}`)
+}
+
+func (s *storeTestSuite) TestMessagesEndpointExchange(c *C) {
+	storeStack := assertstest.NewStoreStack("canonical", nil)
+	topDir := filepath.Dir(s.store.assertDir)
+
+	var queued []asserts.Assertion
+	for _, id := range []string{"msgA", "msgB", "msgC"} {
+		a := s.makeRequestMessage(c, storeStack, id)
+		p, err := QueueRequestMessage(topDir, a)
+		c.Assert(err, IsNil)
+		c.Check(p, Equals, filepath.Join(topDir, "messages", "requests", fmt.Sprintf("%08d.assert", len(queued)+1)))
+		queued = append(queued, a)
+	}
+
+	// fetch the first two messages
+	status, body := s.exchangeMessages(c, &store.MessageExchangeRequest{Limit: 2})
+	c.Assert(status, Equals, 200)
+	var resp store.MessageExchangeResponse
+	c.Assert(json.Unmarshal(body, &resp), IsNil)
+	c.Check(resp.TotalPendingMessages, Equals, 3)
+	c.Assert(resp.Messages, HasLen, 2)
+	for i, msg := range resp.Messages {
+		c.Check(msg.Format, Equals, "assertion")
+		c.Check(msg.Token, Equals, fmt.Sprintf("%08d", i+1))
+		c.Check(msg.Data, Equals, string(asserts.Encode(queued[i])))
+	}
+
+	// acknowledge them and send a response
+	key, _ := assertstest.GenerateKey(752)
+	respMsg, err := asserts.SignWithoutAuthority(asserts.ResponseMessageType, map[string]any{
+		"account-id": "canonical",
+		"message-id": "msgA",
+		"device":     "serial-1.my-model.my-brand",
+		"status":     "success",
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+	}, []byte(`{"change-id": "1"}`), key)
+	c.Assert(err, IsNil)
+
+	status, body = s.exchangeMessages(c, &store.MessageExchangeRequest{
+		After: resp.Messages[1].Token,
+		Limit: 10,
+		Messages: []store.Message{
+			{Format: "assertion", Data: string(asserts.Encode(respMsg))},
+		},
+	})
+	c.Assert(status, Equals, 200)
+	resp = store.MessageExchangeResponse{}
+	c.Assert(json.Unmarshal(body, &resp), IsNil)
+	c.Check(resp.TotalPendingMessages, Equals, 1)
+	c.Assert(resp.Messages, HasLen, 1)
+	c.Check(resp.Messages[0].Token, Equals, "00000003")
+	c.Check(resp.Messages[0].Data, Equals, string(asserts.Encode(queued[2])))
+
+	c.Check(filepath.Join(topDir, "messages", "acked", "00000001.assert"), testutil.FilePresent)
+	c.Check(filepath.Join(topDir, "messages", "acked", "00000002.assert"), testutil.FilePresent)
+	c.Check(filepath.Join(topDir, "messages", "responses", "msgA.assert"), testutil.FileEquals, asserts.Encode(respMsg))
+
+	// tokens keep growing once messages were acknowledged
+	p, err := QueueRequestMessage(topDir, s.makeRequestMessage(c, storeStack, "msgD"))
+	c.Assert(err, IsNil)
+	c.Check(filepath.Base(p), Equals, "00000004.assert")
+
+	// status only
+	status, body = s.exchangeMessages(c, &store.MessageExchangeRequest{})
+	c.Assert(status, Equals, 200)
+	c.Check(string(body), Equals, `{"messages":[],"total-pending-messages":2}`)
+}
+
+func (s *storeTestSuite) TestMessagesEndpointErrors(c *C) {
+	for _, tc := range []struct {
+		req string
+		err string
+	}{
+		{`{"limit": -1}`, `{"error-list":[{"code":"invalid-request","message":"limit must be non-negative, got -1"}]}`},
+		{`{"limit": "x"}`, `{"error-list":[{"code":"invalid-request","message":"cannot decode request: json: cannot unmarshal string into Go struct field MessageExchangeRequest.limit of type int"}]}`},
+		{`{"messages": [{"format": "json", "data": "{}"}]}`, `{"error-list":[{"code":"invalid-message","message":"unsupported message format \"json\""}]}`},
+		{`{"messages": [{"format": "assertion", "data": "foo"}]}`, `{"error-list":[{"code":"invalid-message","message":"cannot decode response-message: assertion content/signature separator not found"}]}`},
+	} {
+		resp, err := s.StorePostJSON("/v2/messages", []byte(tc.req))
+		c.Assert(err, IsNil)
+		var body json.RawMessage
+		c.Assert(json.NewDecoder(resp.Body).Decode(&body), IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 400, Commentf(tc.req))
+		c.Check(string(body), Equals, tc.err, Commentf(tc.req))
+	}
+
+	resp, err := s.StoreGet("/v2/messages")
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 405)
+}
+
+func (s *storeTestSuite) TestQueueRequestMessageWrongType(c *C) {
+	topDir := c.MkDir()
+	_, err := QueueRequestMessage(topDir, systestkeys.TestRootAccount)
+	c.Check(err, ErrorMatches, `cannot queue "account" assertion as a request-message`)
+	c.Check(filepath.Join(topDir, "messages"), testutil.FileAbsent)
+}
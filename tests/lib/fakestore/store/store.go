@@ -35,6 +35,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
@@ -71,6 +72,9 @@ type Store struct {
 	blobDir   string
 	assertDir string
 
+	messagesMu  sync.Mutex
+	messagesDir string
+
 	assertFallback bool
 	fallback       *store.Store
 
@@ -93,6 +97,8 @@ func NewStore(topDir, addr string, assertFallback bool) *Store {
 		blobDir:   topDir,
 		assertDir: filepath.Join(topDir, "asserts"),
 
+		messagesDir: messagesDir(topDir),
+
 		assertFallback: assertFallback,
 		fallback:       sto,
 
@@ -121,6 +127,7 @@ func NewStore(topDir, addr string, assertFallback bool) *Store {
 	mux.HandleFunc("/v2/snaps/refresh", store.snapActionEndpoint)
 
 	mux.HandleFunc("/v2/repairs/", store.repairsEndpoint)
+	mux.HandleFunc("/v2/messages", store.messagesEndpoint)
 
 	return store
 }